@@ -0,0 +1,209 @@
+package merklego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrLeavesNotSorted is returned when proving absence in a tree whose leaves
+// are not sorted by leaf hash.
+var ErrLeavesNotSorted = errors.New("Merkle tree leaves are not sorted by leaf hash")
+
+// AbsenceLeaf is a leaf of an AbsenceProof along with its proof.
+type AbsenceLeaf struct {
+	Index int
+	Block Block
+	Proof []TreeNode
+}
+
+// AbsenceProof proves that a block is not in a FlatMerkleTree whose leaves are
+// sorted by leaf hash, by showing the leaves its hash would sort between.
+type AbsenceProof struct {
+	// Size is the number of blocks of the tree.
+	Size                 int
+	SingleLeafRoot       bool
+	LengthPrefixedLeaves bool
+	// LeafPrefix and NodePrefix are those of the tree, nil for the defaults.
+	LeafPrefix, NodePrefix []byte
+	// PairHash names the pair hash of the tree, as Proof.PairHash does.
+	PairHash string
+	// Hash names the hash function of the tree as registered with
+	// RegisterHash, empty for SHA-256 and "?" for an unregistered one.
+	Hash string
+	// Keyed is set for trees finalized with WithHMACKey, whose key the
+	// proof does not carry.
+	Keyed bool
+
+	// Lower and Upper are the leaves sorting right before and right after
+	// the absent block. Lower is nil when the block sorts before the first
+	// leaf, and Upper when it sorts after the last one.
+	Lower, Upper *AbsenceLeaf
+	// Boundary is the last leaf when Lower is nil and the first one when
+	// Upper is nil. The positions of the first and the last leaf only match
+	// the tree of Size blocks, which an edge leaf alone does not show.
+	Boundary *AbsenceLeaf
+}
+
+// ProveAbsence returns the AbsenceProof of block, which must not be in the
+// tree. The leaves must be sorted by leaf hash, as NewSortedTree and
+// WithSortedLeaves do, and the tree must not use sorted pairs, which would let
+// a leaf be proven at any position.
+func (mt *FlatMerkleTree) ProveAbsence(block Block) (*AbsenceProof, error) {
+	if block == nil {
+		return nil, ErrNilBlock
+	}
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if mt.sortedPairs {
+		return nil, fmt.Errorf("cannot prove absence in a tree with sorted pairs")
+	}
+	if mt.salted() {
+		return nil, ErrSaltedTree
+	}
+
+	first := len(mt.nodes) - len(mt.blocks)
+	leaves := mt.nodes[first : first+mt.count]
+	for i := 1; i < len(leaves); i++ {
+		if bytes.Compare(leaves[i-1], leaves[i]) > 0 {
+			return nil, ErrLeavesNotSorted
+		}
+	}
+
+	hash := mt.hashLeaf(block)
+	i := sort.Search(len(leaves), func(i int) bool {
+		return bytes.Compare(leaves[i], hash) >= 0
+	})
+	if i < len(leaves) && RootEqual(leaves[i], hash) {
+		return nil, fmt.Errorf("block exists: %X", block.Bytes())
+	}
+
+	p := &AbsenceProof{
+		Size:                 mt.count,
+		SingleLeafRoot:       mt.singleLeafRoot,
+		LengthPrefixedLeaves: mt.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(mt.leafPrefix),
+		NodePrefix:           clonePrefix(mt.nodePrefix),
+		PairHash:             pairHashName(mt.pairHash),
+		Hash:                 mt.hashID(),
+		Keyed:                mt.keyed(),
+	}
+	if i > 0 {
+		p.Lower = mt.absenceLeaf(i - 1)
+	} else {
+		p.Boundary = mt.absenceLeaf(mt.count - 1)
+	}
+	if i < mt.count {
+		p.Upper = mt.absenceLeaf(i)
+	} else {
+		p.Boundary = mt.absenceLeaf(0)
+	}
+
+	return p, nil
+}
+
+// absenceLeaf returns the block at index with its proof.
+func (mt *FlatMerkleTree) absenceLeaf(index int) *AbsenceLeaf {
+	idx := len(mt.nodes) - len(mt.blocks) + index
+	l := &AbsenceLeaf{Index: index, Block: Block(copyNode(TreeNode(mt.blocks[index]))), Proof: []TreeNode{}}
+	for ; idx > 0; idx = (idx - 1) / 2 {
+		if idx%2 == 0 {
+			l.Proof = append(l.Proof, copyNode(mt.nodes[idx-1]))
+		} else {
+			l.Proof = append(l.Proof, copyNode(mt.nodes[idx+1]))
+		}
+	}
+
+	return l
+}
+
+// VerifyAbsence checks that p proves block absent from the FlatMerkleTree
+// with the given root, whose leaves are sorted by leaf hash. It returns an
+// error wrapping ErrInvalidProof when it does not. The key of a keyed tree is
+// given in opts with WithHMACKey.
+func VerifyAbsence(root []byte, block Block, p *AbsenceProof, opts ...Option) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+
+	pairHash, err := pairHashOption(p.PairHash)
+	if err != nil {
+		return fmt.Errorf("invalid absence proof: %v: %w", err, ErrInvalidProof)
+	}
+	hashStrategy, err := hashOption(p.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid absence proof: %v: %w", err, ErrInvalidProof)
+	}
+	opts = append([]Option{
+		WithSingleLeafRoot(p.SingleLeafRoot),
+		WithLengthPrefixedLeaves(p.LengthPrefixedLeaves),
+		WithLeafPrefix(p.LeafPrefix),
+		WithNodePrefix(p.NodePrefix),
+		pairHash,
+		hashStrategy,
+	}, opts...)
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if mt.keyed() != p.Keyed {
+		return fmt.Errorf("invalid absence proof: %v: %w", ErrProofKeyed, ErrInvalidProof)
+	}
+	hash := mt.hashLeaf(block)
+	check := func(l *AbsenceLeaf, index int) error {
+		if l.Index != index {
+			return fmt.Errorf("invalid absence proof: got leaf %d, want %d: %w", l.Index, index, ErrInvalidProof)
+		}
+		if err := VerifyFlatProof(root, l.Block, index, p.Size, l.Proof, opts...); err != nil {
+			return fmt.Errorf("invalid absence proof: %v: %w", err, ErrInvalidProof)
+		}
+
+		return nil
+	}
+
+	switch {
+	case p.Lower == nil && p.Upper == nil, p.Lower != nil && p.Upper != nil && p.Boundary != nil:
+		return fmt.Errorf("invalid absence proof: unexpected leaves: %w", ErrInvalidProof)
+	case p.Lower == nil:
+		if p.Boundary == nil {
+			return fmt.Errorf("invalid absence proof: missing boundary leaf: %w", ErrInvalidProof)
+		}
+		if err := check(p.Upper, 0); err != nil {
+			return err
+		}
+		if err := check(p.Boundary, p.Size-1); err != nil {
+			return err
+		}
+	case p.Upper == nil:
+		if p.Boundary == nil {
+			return fmt.Errorf("invalid absence proof: missing boundary leaf: %w", ErrInvalidProof)
+		}
+		if err := check(p.Lower, p.Size-1); err != nil {
+			return err
+		}
+		if err := check(p.Boundary, 0); err != nil {
+			return err
+		}
+	default:
+		if err := check(p.Lower, p.Lower.Index); err != nil {
+			return err
+		}
+		if err := check(p.Upper, p.Lower.Index+1); err != nil {
+			return err
+		}
+	}
+
+	if p.Lower != nil && bytes.Compare(mt.hashLeaf(p.Lower.Block), hash) >= 0 {
+		return fmt.Errorf("invalid absence proof: block %X does not sort after the lower leaf: %w", block.Bytes(), ErrInvalidProof)
+	}
+	if p.Upper != nil && bytes.Compare(hash, mt.hashLeaf(p.Upper.Block)) >= 0 {
+		return fmt.Errorf("invalid absence proof: block %X does not sort before the upper leaf: %w", block.Bytes(), ErrInvalidProof)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package merklego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sortedBlocks returns n blocks sorted by leaf hash.
+func sortedBlocks(n int) []Block {
+	blocks := make([]Block, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return bytes.Compare(hashNode(blocks[i], false), hashNode(blocks[j], false)) < 0
+	})
+
+	return blocks
+}
+
+func TestAbsenceProof(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		blocks := sortedBlocks(n)
+		mt := NewMerkleTree(blocks...)
+		require.NoError(t, mt.Finalize())
+		root, _ := mt.RootHash()
+
+		for _, b := range blocks {
+			_, err := mt.ProveAbsence(b)
+			require.Error(t, err, fmt.Sprintf("blocks: %d, present: %s", n, b))
+		}
+
+		edges := map[string]bool{}
+		for i := 0; i < 40; i++ {
+			absent := Block(fmt.Sprintf("absent-%d", i))
+			msg := fmt.Sprintf("blocks: %d, absent: %s", n, absent)
+
+			p, err := mt.ProveAbsence(absent)
+			require.NoError(t, err, msg)
+			require.NoError(t, VerifyAbsence(root, absent, p), msg)
+
+			switch {
+			case p.Lower == nil:
+				edges["before"] = true
+			case p.Upper == nil:
+				edges["after"] = true
+			}
+
+			// The proof only holds for blocks sorting between its leaves.
+			for _, b := range blocks {
+				require.Error(t, VerifyAbsence(root, b, p), msg)
+			}
+		}
+		require.True(t, edges["before"] && edges["after"], fmt.Sprintf("blocks: %d, edges: %v", n, edges))
+	}
+}
+
+func TestAbsenceProofSize(t *testing.T) {
+	blocks := sortedBlocks(8)
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize())
+	root, _ := mt.RootHash()
+
+	// Leaf 3 of 8 sits where the last leaf of a tree of 6 would, so its
+	// proof also holds as the proof of that leaf.
+	leaf3, leaf0 := mt.absenceLeaf(3), mt.absenceLeaf(0)
+	require.NoError(t, VerifyFlatProof(root, leaf3.Block, 5, 6, leaf3.Proof))
+
+	// Claiming it is the last leaf would prove leaves 4 to 7 absent, which the
+	// first leaf, not where a tree of 6 has it, prevents.
+	leaf3.Index = 5
+	forged := &AbsenceProof{Size: 6, Lower: leaf3, Boundary: leaf0}
+	err := VerifyAbsence(root, blocks[5], forged)
+	require.True(t, errors.Is(err, ErrInvalidProof), fmt.Sprintf("got: %v", err))
+
+	forged.Boundary = nil
+	require.Error(t, VerifyAbsence(root, blocks[5], forged))
+}
+
+func TestAbsenceProofErrors(t *testing.T) {
+	blocks := sortedBlocks(4)
+
+	unsorted := NewMerkleTree(blocks[1], blocks[0], blocks[2], blocks[3])
+	require.NoError(t, unsorted.Finalize())
+	_, err := unsorted.ProveAbsence(Block("absent"))
+	require.Equal(t, ErrLeavesNotSorted, err)
+
+	sortedPairs := NewMerkleTree(blocks...)
+	require.NoError(t, sortedPairs.Finalize(WithSortedPairs(true)))
+	_, err = sortedPairs.ProveAbsence(Block("absent"))
+	require.Error(t, err)
+
+	mt := NewMerkleTree(blocks...)
+	_, err = mt.ProveAbsence(Block("absent"))
+	require.Equal(t, ErrTreeNotFinalized, err)
+	require.NoError(t, mt.Finalize())
+	_, err = mt.ProveAbsence(nil)
+	require.Equal(t, ErrNilBlock, err)
+
+	root, _ := mt.RootHash()
+	p, err := mt.ProveAbsence(Block("absent"))
+	require.NoError(t, err)
+	require.Error(t, VerifyAbsence(root, Block("absent"), &AbsenceProof{Size: 4}))
+
+	both := *p
+	both.Lower, both.Upper, both.Boundary = mt.absenceLeaf(0), mt.absenceLeaf(1), mt.absenceLeaf(3)
+	require.Error(t, VerifyAbsence(root, Block("absent"), &both))
+
+	gap := *p
+	gap.Lower, gap.Upper, gap.Boundary = mt.absenceLeaf(0), mt.absenceLeaf(2), nil
+	require.Error(t, VerifyAbsence(root, Block("absent"), &gap))
+
+	single := NewMerkleTree(Block("only"))
+	require.NoError(t, single.Finalize(WithSingleLeafRoot(true)))
+	singleRoot, _ := single.RootHash()
+	p, err = single.ProveAbsence(Block("absent"))
+	require.NoError(t, err)
+	require.NoError(t, VerifyAbsence(singleRoot, Block("absent"), p))
+}
@@ -0,0 +1,121 @@
+package merklego
+
+import (
+	"fmt"
+	"hash"
+	"sync/atomic"
+)
+
+// ProofItem pairs the hash of a leaf with its Proof.
+type ProofItem struct {
+	Leaf  []byte
+	Proof *Proof
+}
+
+// VerifyBatch checks every item against root, spreading them over workers
+// goroutines, and returns the result of each in order: nil, ErrInvalidProof,
+// or the error of a proof that cannot be checked. An item is valid when its
+// leaf is the leaf hash of its proof and the proof verifies against root, as
+// with Proof.Verify. Each worker reuses one hasher per hash function and two
+// buffers for the whole of its share.
+func VerifyBatch(root []byte, items []ProofItem, workers int) []error {
+	errs := make([]error, len(items))
+	_ = parallelRange(len(items), workers, func(lo, hi int, _ *int32) error {
+		v := newBatchVerifier()
+		for i := lo; i < hi; i++ {
+			errs[i] = v.verify(root, items[i])
+		}
+
+		return nil
+	})
+
+	return errs
+}
+
+// AllValid reports whether every item verifies against root, as VerifyBatch
+// checks them. It stops at the first item that does not.
+func AllValid(root []byte, items []ProofItem, workers int) bool {
+	err := parallelRange(len(items), workers, func(lo, hi int, stop *int32) error {
+		v := newBatchVerifier()
+		for i := lo; i < hi && atomic.LoadInt32(stop) == 0; i++ {
+			if err := v.verify(root, items[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return err == nil
+}
+
+// batchVerifier verifies proofs without allocating per step: it keeps a
+// hasher for each hash function met and sums the nodes of a path into two
+// alternating buffers.
+type batchVerifier struct {
+	hashers map[string]hash.Hash
+	buf     [2][]byte
+	prefix  [1]byte
+}
+
+func newBatchVerifier() *batchVerifier {
+	return &batchVerifier{hashers: make(map[string]hash.Hash, 1), prefix: [1]byte{byte(internalNodePrefix)}}
+}
+
+func (v *batchVerifier) verify(root []byte, item ProofItem) error {
+	p := item.Proof
+	if p == nil {
+		return fmt.Errorf("error: nil proof: %w", ErrInvalidProof)
+	}
+	if p.Compressed() {
+		return fmt.Errorf("error: cannot verify a compressed proof without the known hashes")
+	}
+	if !RootEqual(item.Leaf, p.LeafHash) {
+		return ErrInvalidProof
+	}
+	if p.PairHash != "" || p.Keyed || p.DigestSize != 0 {
+		return p.Verify(root)
+	}
+
+	h, ok := v.hashers[p.Hash]
+	if !ok {
+		hashStrategy, err := LookupHash(p.Hash)
+		if err != nil {
+			return err
+		}
+		h = hashStrategy()
+		v.hashers[p.Hash] = h
+	}
+
+	c := config{sortedPairs: p.SortedPairs}
+	prefix := v.prefix[:]
+	if len(p.NodePrefix) > 0 {
+		prefix = p.NodePrefix
+	}
+	node := p.LeafHash
+	for i, step := range p.Steps {
+		left, right := node, step.Hash
+		if step.Left {
+			left, right = step.Hash, node
+		}
+		left, right = c.orderPair(left, right)
+
+		h.Reset()
+		if p.DomainSeparation {
+			h.Write(prefix)
+		}
+		h.Write(left)
+		h.Write(right)
+		v.buf[i%2] = h.Sum(v.buf[i%2][:0])
+		node = v.buf[i%2]
+	}
+	if p.CountCommitment {
+		node = commitCount(h, p.Size, node)
+	}
+
+	if !RootEqual(node, root) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
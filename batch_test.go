@@ -0,0 +1,101 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// batchItems returns the proofs of the n blocks of a flat tree and its root.
+func batchItems(t testing.TB, n int) ([]byte, []ProofItem) {
+	blocks := make([]Block, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+	}
+	tree := NewMerkleTree(blocks...)
+	require.NoError(t, tree.Finalize())
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+
+	items := make([]ProofItem, n)
+	first := len(tree.nodes) - len(tree.blocks)
+	for i := range items {
+		p := tree.proofAt(first + i)
+		items[i] = ProofItem{Leaf: p.LeafHash, Proof: p}
+	}
+
+	return root, items
+}
+
+func TestVerifyBatch(t *testing.T) {
+	root, items := batchItems(t, 3000)
+
+	tree, err := NewTreeWithOptions(paddingContents(7), WithSortedPairs(true))
+	require.NoError(t, err)
+	for _, l := range tree.RealLeaves() {
+		p, err := tree.Prove(l.Hash)
+		require.NoError(t, err)
+		items = append(items, ProofItem{Leaf: l.Hash, Proof: p})
+	}
+
+	items[1] = ProofItem{Leaf: items[2].Leaf, Proof: items[1].Proof}
+	forged := *items[5].Proof
+	forged.Steps = append([]ProofStep{}, forged.Steps...)
+	forged.Steps[0].Left = !forged.Steps[0].Left
+	items[5].Proof = &forged
+	unknown := *items[7].Proof
+	unknown.Hash = "unknown"
+	items[7].Proof = &unknown
+	items[9].Proof = nil
+
+	for _, workers := range []int{1, 3, 8} {
+		errs := VerifyBatch(root, items, workers)
+		require.Len(t, errs, len(items))
+		for i, err := range errs {
+			switch {
+			case i == 1, i == 5, i == 9, i >= 3000:
+				require.ErrorIs(t, err, ErrInvalidProof, "workers:%d item:%d", workers, i)
+			case i == 7:
+				require.Error(t, err)
+			default:
+				require.NoError(t, err, "workers:%d item:%d", workers, i)
+				require.NoError(t, items[i].Proof.Verify(root))
+			}
+		}
+
+		require.False(t, AllValid(root, items, workers))
+		require.True(t, AllValid(root, items[10:3000], workers))
+	}
+
+	treeRoot, _ := tree.RootHash()
+	require.Equal(t, make([]error, 7), VerifyBatch(treeRoot, items[3000:], 2))
+	require.Empty(t, VerifyBatch(root, nil, 4))
+	require.True(t, AllValid(root, nil, 4))
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	root, items := batchItems(b, 100000)
+	b.ResetTimer()
+
+	b.Run("sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, item := range items {
+				if err := item.Proof.Verify(root); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !AllValid(root, items, workers) {
+					b.Fatal("error: invalid proof")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package merklego
+
+import "crypto/sha256"
+
+func init() {
+	RegisterPairHash("sha256d", BitcoinPairHash)
+}
+
+// BitcoinPairHash hashes two sibling nodes into their parent as Bitcoin does:
+// SHA-256 of SHA-256 of left || right. It is registered as "sha256d".
+func BitcoinPairHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(left)
+	h.Write(right)
+	sum := sha256.Sum256(h.Sum(nil))
+
+	return sum[:]
+}
+
+// NewBitcoinTree creates the merkle tree of the transactions of a Bitcoin
+// block from their txids, in block order, whose root is the merkle root of
+// the block header. Odd levels pair their last node with itself, the pair hash
+// is BitcoinPairHash and a block of a single transaction has its txid as root.
+//
+// Txids and the root are in the internal byte order of Bitcoin, the one of
+// the serialized block; Bitcoin Core and block explorers display them
+// reversed, see ReverseHash. As in Bitcoin, padding by duplication gives a
+// block whose last transactions are repeated the same root as the block
+// without them: callers checking untrusted blocks should reject duplicate
+// txids, for instance with WithRejectDuplicates.
+func NewBitcoinTree(txids [][]byte, opts ...Option) (*MerkleTree, error) {
+	bitcoin := []Option{
+		WithPaddingStrategy(PadDuplicateLast),
+		WithSingleLeafRoot(true),
+		WithPairHash(BitcoinPairHash),
+	}
+
+	return NewTreeFromLeafHashes(txids, sha256.New, append(bitcoin, opts...)...)
+}
+
+// ReverseHash returns a copy of hash with its bytes in reverse order, which
+// converts Bitcoin hashes between their internal byte order and the one they
+// are displayed in.
+func ReverseHash(hash []byte) []byte {
+	out := make([]byte, len(hash))
+	for i, b := range hash {
+		out[len(hash)-1-i] = b
+	}
+
+	return out
+}
@@ -0,0 +1,71 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseHash(t *testing.T) {
+	h := []byte{1, 2, 3}
+	require.Equal(t, []byte{3, 2, 1}, ReverseHash(h))
+	require.Equal(t, []byte{1, 2, 3}, h)
+	require.Empty(t, ReverseHash(nil))
+}
+
+// TestBitcoinBlockHeader checks the root of block 100000 of Bitcoin mainnet
+// against the merkle root of its header.
+func TestBitcoinBlockHeader(t *testing.T) {
+	header, err := hex.DecodeString("0100000050120119172a610421a6c3011dd330d9df07b63616c2cc1f1cd00200000000006657a9252aacd5c0b2940996ecff952228c3067cc38d4885efb5a4ac4247e9f337221b4d4c86041b0f2b5710")
+	require.NoError(t, err)
+	blockHash := sha256.Sum256(header)
+	blockHash = sha256.Sum256(blockHash[:])
+	require.Equal(t, "000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506", hex.EncodeToString(ReverseHash(blockHash[:])))
+	merkleRoot := header[36:68]
+
+	var txids [][]byte
+	for _, s := range []string{
+		"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+		"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+		"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+	} {
+		txid, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		txids = append(txids, ReverseHash(txid))
+	}
+
+	tree, err := NewBitcoinTree(txids)
+	require.NoError(t, err)
+	require.Equal(t, merkleRoot, tree.MerkleRoot())
+	require.Equal(t, "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766", hex.EncodeToString(ReverseHash(tree.MerkleRoot())))
+
+	for _, txid := range txids {
+		p, err := tree.Prove(txid)
+		require.NoError(t, err)
+		require.Equal(t, "sha256d", p.PairHash)
+		require.NoError(t, p.Verify(merkleRoot))
+	}
+
+	// Odd levels pair their last node with itself.
+	three, err := NewBitcoinTree(txids[:3])
+	require.NoError(t, err)
+	left, right := BitcoinPairHash(txids[0], txids[1]), BitcoinPairHash(txids[2], txids[2])
+	require.Equal(t, BitcoinPairHash(left, right), three.MerkleRoot())
+
+	_, err = NewBitcoinTree(append(txids[:3:3], txids[2]), WithRejectDuplicates(true))
+	require.Error(t, err)
+}
+
+// TestBitcoinGenesisBlock checks that the root of a block of a single
+// transaction is its txid.
+func TestBitcoinGenesisBlock(t *testing.T) {
+	txid, err := hex.DecodeString("4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+	require.NoError(t, err)
+
+	tree, err := NewBitcoinTree([][]byte{ReverseHash(txid)})
+	require.NoError(t, err)
+	require.Equal(t, txid, ReverseHash(tree.MerkleRoot()))
+}
@@ -0,0 +1,186 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// TorrentBlockSize is the size of the blocks BitTorrent v2 (BEP 52) hashes
+// files in, 16 KiB, the last block of a file being shorter.
+const TorrentBlockSize = 16 << 10
+
+// TorrentFileTree is the merkle tree BitTorrent v2 (BEP 52) builds over a
+// file: its leaves are the SHA-256 hashes of the TorrentBlockSize blocks of
+// the file, followed by zero hashes up to a power of two, and nodes are the
+// SHA-256 of left || right, with no prefix. Its root is the "pieces root" of
+// the file in the torrent, and its piece layer, the nodes each covering a
+// piece, is what the "piece layers" of the torrent hold for the file.
+type TorrentFileTree struct {
+	pieceLength int
+	size        int64
+	blocks      int
+	// layers[l] holds the nodes of height l, layers[0] the leaves including
+	// the zero hashes that pad them.
+	layers [][]TreeNode
+}
+
+var _ Rooter = (*TorrentFileTree)(nil)
+
+// NewTorrentFileTree reads r to its end and returns its tree for pieces of
+// pieceLength bytes, a power of two of at least TorrentBlockSize as BEP 52
+// requires. The file is read one block at a time.
+func NewTorrentFileTree(r io.Reader, pieceLength int) (*TorrentFileTree, error) {
+	if pieceLength < TorrentBlockSize || pieceLength&(pieceLength-1) != 0 {
+		return nil, fmt.Errorf("piece length %d is not a power of two of at least %d", pieceLength, TorrentBlockSize)
+	}
+
+	t := &TorrentFileTree{pieceLength: pieceLength}
+	var leaves []TreeNode
+	block := make([]byte, TorrentBlockSize)
+	for {
+		n, err := io.ReadFull(r, block)
+		if n > 0 {
+			sum := sha256.Sum256(block[:n])
+			leaves = append(leaves, sum[:])
+			t.size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read block %d: %w", len(leaves), err)
+		}
+	}
+	t.blocks = len(leaves)
+	if t.blocks == 0 {
+		return t, nil
+	}
+
+	width := 1
+	for width < len(leaves) {
+		width <<= 1
+	}
+	zero := make(TreeNode, sha256.Size)
+	for len(leaves) < width {
+		leaves = append(leaves, zero)
+	}
+
+	t.layers = append(t.layers, leaves)
+	for level := leaves; len(level) > 1; {
+		next := make([]TreeNode, len(level)/2)
+		for i := range next {
+			next[i] = torrentPair(level[2*i], level[2*i+1])
+		}
+		t.layers = append(t.layers, next)
+		level = next
+	}
+
+	return t, nil
+}
+
+// torrentPair hashes left and right into their parent as BEP 52 does.
+func torrentPair(left, right []byte) TreeNode {
+	h := sha256.New()
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// RootHash returns the pieces root of the file. Empty files have none, as
+// BEP 52 leaves it out of their torrent, and get ErrNoContent.
+func (t *TorrentFileTree) RootHash() ([]byte, error) {
+	if t.blocks == 0 {
+		return nil, ErrNoContent
+	}
+
+	return copyNode(t.layers[len(t.layers)-1][0]), nil
+}
+
+// Size returns the size of the file in bytes.
+func (t *TorrentFileTree) Size() int64 {
+	return t.size
+}
+
+// pieceHeight returns the height of the nodes covering a piece, capped to the
+// one of the root for files of a single piece.
+func (t *TorrentFileTree) pieceHeight() int {
+	height := 0
+	for TorrentBlockSize<<height < t.pieceLength && height < len(t.layers)-1 {
+		height++
+	}
+
+	return height
+}
+
+// PieceLayer returns the hashes of the pieces of the file, in order, the
+// value the "piece layers" of a torrent map its pieces root to once
+// concatenated. The hash of the last piece covers the zero hashes padding
+// its blocks. Files of at most one piece have no piece layer, their pieces
+// root covering their only piece, and get nil.
+func (t *TorrentFileTree) PieceLayer() [][]byte {
+	if t.size <= int64(t.pieceLength) {
+		return nil
+	}
+
+	pieces := int((t.size + int64(t.pieceLength) - 1) / int64(t.pieceLength))
+	layer := make([][]byte, pieces)
+	for i, node := range t.layers[t.pieceHeight()][:pieces] {
+		layer[i] = copyNode(node)
+	}
+
+	return layer
+}
+
+// BlockProof returns the proof of the block at index in the file against the
+// root of its piece: the hash of the piece in the piece layer, or the pieces
+// root for files of a single piece. The proof is checked with
+// VerifyTorrentBlock and the index of the block in its piece,
+// index % (pieceLength / TorrentBlockSize).
+func (t *TorrentFileTree) BlockProof(index int) ([]TreeNode, error) {
+	if index < 0 || index >= t.blocks {
+		return nil, fmt.Errorf("block index %d out of range [0, %d)", index, t.blocks)
+	}
+
+	height := t.pieceHeight()
+	proof := make([]TreeNode, height)
+	for l := range proof {
+		proof[l] = copyNode(t.layers[l][index>>l^1])
+	}
+
+	return proof, nil
+}
+
+// VerifyTorrentBlock checks that proof, as returned by BlockProof, leads from
+// block, a downloaded block of at most TorrentBlockSize bytes, to pieceRoot,
+// the hash of its piece or the pieces root of a file of a single piece. index
+// is the position of the block in the piece, whose bits give the side of each
+// sibling. Errors wrap ErrInvalidProof.
+func VerifyTorrentBlock(pieceRoot, block []byte, index int, proof []TreeNode) error {
+	if len(block) == 0 || len(block) > TorrentBlockSize {
+		return fmt.Errorf("invalid block of %d bytes: %w", len(block), ErrInvalidProof)
+	}
+	if index < 0 || len(proof) < 63 && index>>len(proof) != 0 {
+		return fmt.Errorf("block index %d out of range for %d siblings: %w", index, len(proof), ErrInvalidProof)
+	}
+
+	sum := sha256.Sum256(block)
+	node := TreeNode(sum[:])
+	for l, sibling := range proof {
+		if len(sibling) != sha256.Size {
+			return fmt.Errorf("invalid %d byte sibling at level %d: %w", len(sibling), l, ErrInvalidProof)
+		}
+		if index>>l&1 == 1 {
+			node = torrentPair(sibling, node)
+		} else {
+			node = torrentPair(node, sibling)
+		}
+	}
+
+	if !RootEqual(node, pieceRoot) {
+		return fmt.Errorf("invalid proof for block %d; got: %X, want: %X: %w", index, node.Bytes(), pieceRoot, ErrInvalidProof)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// torrentFixture returns size bytes of a file whose blocks differ.
+func torrentFixture(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/TorrentBlockSize)
+	}
+
+	return data
+}
+
+func sum256(data ...[]byte) []byte {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+func TestTorrentFileTreeRoots(t *testing.T) {
+	zero := make([]byte, sha256.Size)
+
+	// A file of a single block has its hash as root.
+	data := torrentFixture(1000)
+	tree, err := NewTorrentFileTree(bytes.NewReader(data), TorrentBlockSize)
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, sum256(data), root)
+	require.Nil(t, tree.PieceLayer())
+
+	// Three blocks are padded with a zero hash to four leaves.
+	data = torrentFixture(2*TorrentBlockSize + 5)
+	b0, b1, b2 := data[:TorrentBlockSize], data[TorrentBlockSize:2*TorrentBlockSize], data[2*TorrentBlockSize:]
+	want := sum256(sum256(sum256(b0), sum256(b1)), sum256(sum256(b2), zero))
+	tree, err = NewTorrentFileTree(iotest.HalfReader(bytes.NewReader(data)), 4*TorrentBlockSize)
+	require.NoError(t, err)
+	root, err = tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, want, root)
+	require.Equal(t, int64(len(data)), tree.Size())
+
+	// With pieces of two blocks, the last piece covers the third block and
+	// the zero hash.
+	tree, err = NewTorrentFileTree(bytes.NewReader(data), 2*TorrentBlockSize)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{
+		sum256(sum256(b0), sum256(b1)),
+		sum256(sum256(b2), zero),
+	}, tree.PieceLayer())
+
+	// Empty files have no pieces root.
+	tree, err = NewTorrentFileTree(bytes.NewReader(nil), TorrentBlockSize)
+	require.NoError(t, err)
+	_, err = tree.RootHash()
+	require.ErrorIs(t, err, ErrNoContent)
+	require.Nil(t, tree.PieceLayer())
+
+	for _, pieceLength := range []int{0, TorrentBlockSize / 2, 3 * TorrentBlockSize} {
+		_, err = NewTorrentFileTree(bytes.NewReader(data), pieceLength)
+		require.Error(t, err)
+	}
+}
+
+// torrentReference computes the pieces root of data as BEP 52 defines it,
+// recursively over the leaves padded with zero hashes to a power of two of at
+// least width.
+func torrentReference(data []byte, width int) []byte {
+	var leaves [][]byte
+	for off := 0; off < len(data); off += TorrentBlockSize {
+		end := off + TorrentBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		leaves = append(leaves, sum256(data[off:end]))
+	}
+	for width < len(leaves) {
+		width *= 2
+	}
+	for len(leaves) < width {
+		leaves = append(leaves, make([]byte, sha256.Size))
+	}
+
+	var root func(nodes [][]byte) []byte
+	root = func(nodes [][]byte) []byte {
+		if len(nodes) == 1 {
+			return nodes[0]
+		}
+		return sum256(root(nodes[:len(nodes)/2]), root(nodes[len(nodes)/2:]))
+	}
+
+	return root(leaves)
+}
+
+func TestTorrentPieceLayers(t *testing.T) {
+	const pieceLength = 4 * TorrentBlockSize
+
+	for _, size := range []int{pieceLength, pieceLength + 1, 3*pieceLength - 100, 5 * pieceLength} {
+		data := torrentFixture(size)
+		tree, err := NewTorrentFileTree(bytes.NewReader(data), pieceLength)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		require.Equal(t, torrentReference(data, 1), root, "%d bytes", size)
+
+		layer := tree.PieceLayer()
+		if size <= pieceLength {
+			require.Nil(t, layer)
+			layer = [][]byte{root}
+		}
+		require.Len(t, layer, (size+pieceLength-1)/pieceLength)
+		for piece, pieceRoot := range layer {
+			end := (piece + 1) * pieceLength
+			if end > size {
+				end = size
+			}
+			// A piece is hashed as a file of its own padded to a full piece.
+			require.Equal(t, torrentReference(data[piece*pieceLength:end], pieceLength/TorrentBlockSize), pieceRoot)
+
+			for block := piece * pieceLength / TorrentBlockSize; block*TorrentBlockSize < end; block++ {
+				proof, err := tree.BlockProof(block)
+				require.NoError(t, err)
+				blockEnd := (block + 1) * TorrentBlockSize
+				if blockEnd > size {
+					blockEnd = size
+				}
+				content := data[block*TorrentBlockSize : blockEnd]
+				index := block % (pieceLength / TorrentBlockSize)
+				require.NoError(t, VerifyTorrentBlock(pieceRoot, content, index, proof))
+
+				require.ErrorIs(t, VerifyTorrentBlock(pieceRoot, content[1:], index, proof), ErrInvalidProof)
+				if len(proof) > 0 {
+					require.ErrorIs(t, VerifyTorrentBlock(pieceRoot, content, index^1, proof), ErrInvalidProof)
+					require.ErrorIs(t, VerifyTorrentBlock(pieceRoot, content, index, proof[1:]), ErrInvalidProof)
+				}
+			}
+		}
+	}
+
+	tree, err := NewTorrentFileTree(bytes.NewReader(torrentFixture(100)), pieceLength)
+	require.NoError(t, err)
+	_, err = tree.BlockProof(1)
+	require.Error(t, err)
+	require.ErrorIs(t, VerifyTorrentBlock(make([]byte, 32), nil, 0, nil), ErrInvalidProof)
+	require.ErrorIs(t, VerifyTorrentBlock(make([]byte, 32), make([]byte, TorrentBlockSize+1), 0, nil), ErrInvalidProof)
+}
+
+// torrentCreatorFixture is testdata/torrent/fixture.json, the pieces root and
+// piece layer of testdata/torrent/fixture.bin read back from the torrent its
+// generate.py creates with libtorrent.
+type torrentCreatorFixture struct {
+	PieceLength int      `json:"pieceLength"`
+	PiecesRoot  string   `json:"piecesRoot"`
+	PieceLayer  []string `json:"pieceLayer"`
+}
+
+func TestTorrentCreatorFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/torrent/fixture.json")
+	require.NoError(t, err, "run testdata/torrent/generate.py with libtorrent")
+	var f torrentCreatorFixture
+	require.NoError(t, json.Unmarshal(data, &f))
+
+	content, err := os.ReadFile("testdata/torrent/fixture.bin")
+	require.NoError(t, err)
+	tree, err := NewTorrentFileTree(bytes.NewReader(content), f.PieceLength)
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, f.PiecesRoot, hex.EncodeToString(root))
+
+	var layer []string
+	for _, pieceRoot := range tree.PieceLayer() {
+		layer = append(layer, hex.EncodeToString(pieceRoot))
+	}
+	require.Equal(t, f.PieceLayer, layer)
+}
@@ -0,0 +1,343 @@
+// Package merklecbor encodes merkle-go proofs and FlatMerkleTree snapshots in
+// CBOR (RFC 8949). Encoding is deterministic, following the core
+// deterministic encoding requirements of RFC 8949, so equal values always
+// produce identical bytes. It is a separate package so the core does not
+// depend on a CBOR library.
+package merklecbor
+
+import (
+	"errors"
+	"fmt"
+
+	merklego "github.com/evalir/merkle-go"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// version is the version of the encoded layouts.
+const version = 1
+
+// ErrInvalidEncoding is returned when decoding malformed or structurally
+// invalid data.
+var ErrInvalidEncoding = errors.New("error: invalid merkle cbor encoding")
+
+// proof is the CBOR layout of a merklego.Proof, a map with integer keys.
+// CountCommitment, LengthPrefixedLeaves, the prefixes, PairHash, Keyed, Salt,
+// DigestSize and Layout are left out when unset.
+type proof struct {
+	Version              uint64   `cbor:"1,keyasint"`
+	Hash                 string   `cbor:"2,keyasint"`
+	DomainSeparation     bool     `cbor:"3,keyasint"`
+	SortedPairs          bool     `cbor:"4,keyasint"`
+	Index                uint64   `cbor:"5,keyasint"`
+	Size                 uint64   `cbor:"6,keyasint"`
+	LeafHash             []byte   `cbor:"7,keyasint"`
+	Siblings             [][]byte `cbor:"8,keyasint"`
+	Directions           []byte   `cbor:"9,keyasint"`
+	CountCommitment      bool     `cbor:"10,keyasint,omitempty"`
+	LengthPrefixedLeaves bool     `cbor:"11,keyasint,omitempty"`
+	LeafPrefix           []byte   `cbor:"12,keyasint,omitempty"`
+	NodePrefix           []byte   `cbor:"13,keyasint,omitempty"`
+	PairHash             string   `cbor:"14,keyasint,omitempty"`
+	Keyed                bool     `cbor:"15,keyasint,omitempty"`
+	Salt                 []byte   `cbor:"16,keyasint,omitempty"`
+	DigestSize           uint64   `cbor:"17,keyasint,omitempty"`
+	Layout               uint64   `cbor:"18,keyasint,omitempty"`
+}
+
+// flatSnapshot is the CBOR layout of a merklego.FlatSnapshot. DistinctPadding,
+// LengthPrefixedLeaves, the prefixes, PairHash, Hash, Keyed, Salts,
+// DigestSize and BranchingFactor are left out when unset, so snapshots of
+// trees without them encode as before.
+type flatSnapshot struct {
+	Version              uint64   `cbor:"1,keyasint"`
+	Blocks               [][]byte `cbor:"2,keyasint"`
+	SortedPairs          bool     `cbor:"3,keyasint"`
+	SingleLeafRoot       bool     `cbor:"4,keyasint"`
+	Root                 []byte   `cbor:"5,keyasint"`
+	DistinctPadding      bool     `cbor:"6,keyasint,omitempty"`
+	LengthPrefixedLeaves bool     `cbor:"7,keyasint,omitempty"`
+	LeafPrefix           []byte   `cbor:"8,keyasint,omitempty"`
+	NodePrefix           []byte   `cbor:"9,keyasint,omitempty"`
+	PairHash             string   `cbor:"10,keyasint,omitempty"`
+	Hash                 string   `cbor:"11,keyasint,omitempty"`
+	Keyed                bool     `cbor:"12,keyasint,omitempty"`
+	Salts                [][]byte `cbor:"13,keyasint,omitempty"`
+	DigestSize           uint64   `cbor:"14,keyasint,omitempty"`
+	BranchingFactor      uint64   `cbor:"15,keyasint,omitempty"`
+}
+
+var encMode cbor.EncMode
+
+func init() {
+	var err error
+	if encMode, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+}
+
+// Bounds of the MaxArrayElements option of the CBOR library.
+const (
+	minArrayElements = 16
+	maxArrayElements = 2147483647
+)
+
+// decode decodes data into v within o, which must have its defaults set.
+// Arrays are bounded by maxElements, checked by the library as their length
+// is read, and the error of an array exceeding it names limit.
+func decode(data []byte, v interface{}, o merklego.DecodeOptions, limit string, maxElements int) error {
+	if err := o.Check("MaxInputSize", uint64(len(data)), o.MaxInputSize, ErrInvalidEncoding); err != nil {
+		return err
+	}
+
+	elements := maxElements
+	if elements < minArrayElements {
+		elements = minArrayElements
+	}
+	if elements > maxArrayElements {
+		elements = maxArrayElements
+	}
+	dm, err := cbor.DecOptions{
+		DupMapKey:         cbor.DupMapKeyEnforcedAPF,
+		IndefLength:       cbor.IndefLengthForbidden,
+		TagsMd:            cbor.TagsForbidden,
+		ExtraReturnErrors: cbor.ExtraDecErrorUnknownField,
+		MaxNestedLevels:   4,
+		MaxArrayElements:  elements,
+	}.DecMode()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	if err := dm.Unmarshal(data, v); err != nil {
+		var tooLong *cbor.MaxArrayElementsError
+		if errors.As(err, &tooLong) {
+			// The library does not report the length read, only that it
+			// exceeds the bound.
+			return &merklego.DecodeLimitError{Limit: limit, Value: uint64(elements) + 1, Max: maxElements, Err: ErrInvalidEncoding}
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return nil
+}
+
+// MarshalProof encodes p. All the hashes of p must be as long as its leaf
+// hash.
+func MarshalProof(p *merklego.Proof) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := proof{
+		Version:              version,
+		Hash:                 p.Hash,
+		DomainSeparation:     p.DomainSeparation,
+		SortedPairs:          p.SortedPairs,
+		CountCommitment:      p.CountCommitment,
+		LengthPrefixedLeaves: p.LengthPrefixedLeaves,
+		LeafPrefix:           p.LeafPrefix,
+		NodePrefix:           p.NodePrefix,
+		PairHash:             p.PairHash,
+		Keyed:                p.Keyed,
+		Salt:                 p.Salt,
+		DigestSize:           uint64(p.DigestSize),
+		Layout:               uint64(p.Layout),
+		Index:                uint64(p.Index),
+		Size:                 uint64(p.Size),
+		LeafHash:             p.LeafHash,
+		Siblings:             make([][]byte, len(p.Steps)),
+		Directions:           make([]byte, (len(p.Steps)+7)/8),
+	}
+	for i, s := range p.Steps {
+		if len(s.Hash) != len(p.LeafHash) {
+			return nil, fmt.Errorf("error: cannot encode step %d: %d byte hash in a proof of %d byte hashes", i, len(s.Hash), len(p.LeafHash))
+		}
+
+		w.Siblings[i] = s.Hash
+		if s.Left {
+			w.Directions[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return encMode.Marshal(w)
+}
+
+// UnmarshalProof decodes a proof encoded by MarshalProof, enforcing the same
+// checks as merklego.Proof.UnmarshalBinary within the default
+// merklego.DecodeOptions.
+func UnmarshalProof(data []byte) (*merklego.Proof, error) {
+	return UnmarshalProofWithOptions(data, merklego.DecodeOptions{})
+}
+
+// UnmarshalProofWithOptions decodes a proof encoded by MarshalProof within
+// the limits of o. Input exceeding them is refused with a
+// *merklego.DecodeLimitError wrapping ErrInvalidEncoding.
+func UnmarshalProofWithOptions(data []byte, o merklego.DecodeOptions) (*merklego.Proof, error) {
+	o = o.WithDefaults()
+	var w proof
+	if err := decode(data, &w, o, "MaxProofSteps", o.MaxProofSteps); err != nil {
+		return nil, err
+	}
+	if w.Version != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, w.Version)
+	}
+
+	size := len(w.LeafHash)
+	if size == 0 || size > 0xff {
+		return nil, fmt.Errorf("%w: %d byte leaf hash", ErrInvalidEncoding, size)
+	}
+	if err := o.Check("MaxHashSize", uint64(size), o.MaxHashSize, ErrInvalidEncoding); err != nil {
+		return nil, err
+	}
+
+	steps := len(w.Siblings)
+	if err := o.Check("MaxProofSteps", uint64(steps), o.MaxProofSteps, ErrInvalidEncoding); err != nil {
+		return nil, err
+	}
+	if len(w.Directions) != (steps+7)/8 {
+		return nil, fmt.Errorf("%w: %d direction bytes for %d siblings", ErrInvalidEncoding, len(w.Directions), steps)
+	}
+	if steps%8 != 0 && w.Directions[len(w.Directions)-1]>>(steps%8) != 0 {
+		return nil, fmt.Errorf("%w: unused direction bits set", ErrInvalidEncoding)
+	}
+
+	const maxInt = int(^uint(0) >> 1)
+	if w.Index > uint64(maxInt) || w.Size > uint64(maxInt) {
+		return nil, fmt.Errorf("%w: index %d out of %d leaves", ErrInvalidEncoding, w.Index, w.Size)
+	}
+	if w.Layout > 0xff {
+		return nil, fmt.Errorf("%w: layout %d", ErrInvalidEncoding, w.Layout)
+	}
+	if w.DigestSize != 0 && w.DigestSize != uint64(size) {
+		return nil, fmt.Errorf("%w: %d byte leaf hash in a proof of %d byte digests", ErrInvalidEncoding, size, w.DigestSize)
+	}
+	if err := o.Check("MaxTreeSize", w.Size, o.MaxTreeSize, ErrInvalidEncoding); err != nil {
+		return nil, err
+	}
+	if w.Hash != "" {
+		if _, err := merklego.LookupHash(w.Hash); err != nil {
+			return nil, &merklego.UnknownHashError{Name: w.Hash, Err: ErrInvalidEncoding}
+		}
+	}
+
+	p := &merklego.Proof{
+		Hash:                 w.Hash,
+		DomainSeparation:     w.DomainSeparation,
+		SortedPairs:          w.SortedPairs,
+		CountCommitment:      w.CountCommitment,
+		LengthPrefixedLeaves: w.LengthPrefixedLeaves,
+		LeafPrefix:           w.LeafPrefix,
+		NodePrefix:           w.NodePrefix,
+		PairHash:             w.PairHash,
+		Keyed:                w.Keyed,
+		Salt:                 w.Salt,
+		DigestSize:           int(w.DigestSize),
+		Layout:               merklego.ProofLayout(w.Layout),
+		Index:                int(w.Index),
+		Size:                 int(w.Size),
+		LeafHash:             w.LeafHash,
+	}
+	if steps > 0 {
+		p.Steps = make([]merklego.ProofStep, steps)
+	}
+	for i, sibling := range w.Siblings {
+		if len(sibling) != size {
+			return nil, fmt.Errorf("%w: %d byte sibling %d in a proof of %d byte hashes", ErrInvalidEncoding, len(sibling), i, size)
+		}
+
+		p.Steps[i] = merklego.ProofStep{Hash: sibling, Left: w.Directions[i/8]&(1<<(i%8)) != 0}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return p, nil
+}
+
+// MarshalFlatTree encodes the snapshot of a finalized tree.
+func MarshalFlatTree(mt *merklego.FlatMerkleTree) ([]byte, error) {
+	s, err := mt.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return encMode.Marshal(flatSnapshot{
+		Version:              version,
+		Blocks:               s.Blocks,
+		SortedPairs:          s.SortedPairs,
+		SingleLeafRoot:       s.SingleLeafRoot,
+		Root:                 s.Root,
+		DistinctPadding:      s.DistinctPadding,
+		LengthPrefixedLeaves: s.LengthPrefixedLeaves,
+		LeafPrefix:           s.LeafPrefix,
+		NodePrefix:           s.NodePrefix,
+		PairHash:             s.PairHash,
+		Hash:                 s.Hash,
+		Keyed:                s.Keyed,
+		Salts:                s.Salts,
+		DigestSize:           uint64(s.DigestSize),
+		BranchingFactor:      uint64(s.BranchingFactor),
+	})
+}
+
+// UnmarshalFlatTree decodes a snapshot encoded by MarshalFlatTree and
+// rebuilds the tree, checking that it has the encoded root. It decodes within
+// the default merklego.DecodeOptions.
+func UnmarshalFlatTree(data []byte) (*merklego.FlatMerkleTree, error) {
+	return UnmarshalFlatTreeWithOptions(data, merklego.DecodeOptions{})
+}
+
+// UnmarshalFlatTreeWithOptions decodes a snapshot encoded by MarshalFlatTree
+// within the limits of o, which bound the number of blocks by MaxTreeSize and
+// the root by MaxHashSize, and rebuilds the tree with opts, as
+// merklego.RestoreFlatMerkleTree does; the key of a keyed tree, which is not
+// encoded, is given there with merklego.WithHMACKey. A snapshot naming a hash
+// function that is not registered is refused with a
+// *merklego.UnknownHashError.
+func UnmarshalFlatTreeWithOptions(data []byte, o merklego.DecodeOptions, opts ...merklego.Option) (*merklego.FlatMerkleTree, error) {
+	o = o.WithDefaults()
+	var w flatSnapshot
+	if err := decode(data, &w, o, "MaxTreeSize", o.MaxTreeSize); err != nil {
+		return nil, err
+	}
+	if w.Version != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, w.Version)
+	}
+	if err := o.Check("MaxTreeSize", uint64(len(w.Blocks)), o.MaxTreeSize, ErrInvalidEncoding); err != nil {
+		return nil, err
+	}
+	if err := o.Check("MaxHashSize", uint64(len(w.Root)), o.MaxHashSize, ErrInvalidEncoding); err != nil {
+		return nil, err
+	}
+	if w.DigestSize > uint64(len(w.Root)) {
+		return nil, fmt.Errorf("%w: %d byte root of %d byte digests", ErrInvalidEncoding, len(w.Root), w.DigestSize)
+	}
+	if w.Hash != "" {
+		if _, err := merklego.LookupHash(w.Hash); err != nil {
+			return nil, &merklego.UnknownHashError{Name: w.Hash, Err: ErrInvalidEncoding}
+		}
+	}
+
+	mt, err := merklego.RestoreFlatMerkleTree(&merklego.FlatSnapshot{
+		Blocks:               w.Blocks,
+		SortedPairs:          w.SortedPairs,
+		SingleLeafRoot:       w.SingleLeafRoot,
+		DistinctPadding:      w.DistinctPadding,
+		LengthPrefixedLeaves: w.LengthPrefixedLeaves,
+		LeafPrefix:           w.LeafPrefix,
+		NodePrefix:           w.NodePrefix,
+		PairHash:             w.PairHash,
+		Hash:                 w.Hash,
+		Keyed:                w.Keyed,
+		Salts:                w.Salts,
+		DigestSize:           int(w.DigestSize),
+		BranchingFactor:      int(w.BranchingFactor),
+		Root:                 w.Root,
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+
+	return mt, nil
+}
@@ -0,0 +1,613 @@
+package merklecbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	merklego "github.com/evalir/merkle-go"
+	"github.com/fxamacker/cbor/v2"
+)
+
+func testTree(t *testing.T, n int) *merklego.MerkleTree {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("leaf-%d", i))
+	}
+
+	tree, err := merklego.NewTreeFromBytes(items, merklego.WithDomainSeparation(true))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	return tree
+}
+
+func testFlatTree(t *testing.T, n int, sorted bool) *merklego.FlatMerkleTree {
+	blocks := make([]merklego.Block, n)
+	for i := range blocks {
+		blocks[i] = merklego.Block(fmt.Sprintf("block-%d", i))
+	}
+
+	mt := merklego.NewMerkleTree(blocks...)
+	if err := mt.Finalize(merklego.WithSortedPairs(sorted)); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	return mt
+}
+
+func TestProofRoundTrip(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		tree := testTree(t, n)
+		for i, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+
+			data, err := MarshalProof(p)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+			again, err := MarshalProof(p)
+			if err != nil || !bytes.Equal(data, again) {
+				t.Errorf("[leaves:%d index:%d] error: expected identical encodings got %x and %x", n, i, data, again)
+			}
+
+			decoded, err := UnmarshalProof(data)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+			if !reflect.DeepEqual(decoded, p) {
+				t.Errorf("[leaves:%d index:%d] error: expected %+v got %+v", n, i, p, decoded)
+			}
+			if err := decoded.Verify(tree.MerkleRoot()); err != nil {
+				t.Errorf("[leaves:%d index:%d] error: expected decoded proof to verify got %v", n, i, err)
+			}
+		}
+	}
+}
+
+func TestProofEncodingIsCanonical(t *testing.T) {
+	p := &merklego.Proof{
+		Hash:     "sha256",
+		Index:    1,
+		Size:     2,
+		LeafHash: []byte{0xaa},
+		Steps:    []merklego.ProofStep{{Hash: []byte{0xbb}, Left: true}},
+	}
+
+	data, err := MarshalProof(p)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	want := "a90101026673686132353603f404f405010602" + "0741aa" + "088141bb" + "094101"
+	if got := hex.EncodeToString(data); got != want {
+		t.Errorf("error: expected %s got %s", want, got)
+	}
+}
+
+func TestUnmarshalProofInvalid(t *testing.T) {
+	valid := map[int]interface{}{
+		1: 1, 2: "sha256", 3: false, 4: false, 5: 1, 6: 2,
+		7: []byte{0xaa}, 8: [][]byte{{0xbb}}, 9: []byte{0x01},
+	}
+	encode := func(change func(m map[int]interface{})) []byte {
+		m := map[int]interface{}{}
+		for k, v := range valid {
+			m[k] = v
+		}
+		change(m)
+
+		data, err := encMode.Marshal(m)
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		return data
+	}
+
+	if _, err := UnmarshalProof(encode(func(map[int]interface{}) {})); err != nil {
+		t.Fatalf("error: expected the valid fixture to decode got %v", err)
+	}
+
+	cases := map[string][]byte{
+		"empty":               {},
+		"not a map":           {0x01},
+		"trailing bytes":      append(encode(func(map[int]interface{}) {}), 0x00),
+		"indefinite length":   {0xbf, 0xff},
+		"duplicate key":       {0xa2, 0x01, 0x01, 0x01, 0x01},
+		"unknown field":       encode(func(m map[int]interface{}) { m[15] = 0 }),
+		"count commitment":    encode(func(m map[int]interface{}) { m[10] = 0 }),
+		"length prefixed":     encode(func(m map[int]interface{}) { m[11] = 0 }),
+		"leaf prefix":         encode(func(m map[int]interface{}) { m[12] = 0 }),
+		"pair hash":           encode(func(m map[int]interface{}) { m[14] = 0 }),
+		"version":             encode(func(m map[int]interface{}) { m[1] = 2 }),
+		"empty leaf hash":     encode(func(m map[int]interface{}) { m[7] = []byte{} }),
+		"sibling size":        encode(func(m map[int]interface{}) { m[8] = [][]byte{{0xbb, 0xcc}} }),
+		"direction length":    encode(func(m map[int]interface{}) { m[9] = []byte{} }),
+		"unused direction":    encode(func(m map[int]interface{}) { m[9] = []byte{0x03} }),
+		"index out of range":  encode(func(m map[int]interface{}) { m[5] = 2 }),
+		"zero size":           encode(func(m map[int]interface{}) { m[5], m[6] = 0, 0 }),
+		"too many steps":      encode(func(m map[int]interface{}) { m[8], m[9] = [][]byte{{0xbb}, {0xcc}}, []byte{0} }),
+		"negative index":      encode(func(m map[int]interface{}) { m[5] = -1 }),
+		"oversized tree size": encode(func(m map[int]interface{}) { m[6] = uint64(1) << 63 }),
+	}
+	for name, data := range cases {
+		if _, err := UnmarshalProof(data); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("[case:%s] error: expected ErrInvalidEncoding got %v", name, err)
+		}
+	}
+}
+
+func TestFlatTreeRoundTrip(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		for _, sorted := range []bool{false, true} {
+			mt := testFlatTree(t, n, sorted)
+
+			data, err := MarshalFlatTree(mt)
+			if err != nil {
+				t.Fatalf("[blocks:%d sorted:%v] error: unexpected error: %v", n, sorted, err)
+			}
+			decoded, err := UnmarshalFlatTree(data)
+			if err != nil {
+				t.Fatalf("[blocks:%d sorted:%v] error: unexpected error: %v", n, sorted, err)
+			}
+
+			want, _ := mt.RootHash()
+			got, _ := decoded.RootHash()
+			if !bytes.Equal(got, want) {
+				t.Errorf("[blocks:%d sorted:%v] error: expected root %x got %x", n, sorted, want, got)
+			}
+
+			again, err := MarshalFlatTree(decoded)
+			if err != nil || !bytes.Equal(again, data) {
+				t.Errorf("[blocks:%d sorted:%v] error: expected identical encodings got %x and %x", n, sorted, data, again)
+			}
+		}
+	}
+}
+
+func TestUnmarshalFlatTreeInvalid(t *testing.T) {
+	if _, err := MarshalFlatTree(merklego.NewMerkleTree(merklego.Block("a"))); !errors.Is(err, merklego.ErrTreeNotFinalized) {
+		t.Errorf("error: expected ErrTreeNotFinalized got %v", err)
+	}
+
+	data, err := MarshalFlatTree(testFlatTree(t, 3, false))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	var w flatSnapshot
+	if err := cbor.Unmarshal(data, &w); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	encode := func(change func(w *flatSnapshot)) []byte {
+		c := w
+		c.Blocks = append([][]byte(nil), w.Blocks...)
+		c.Root = append([]byte(nil), w.Root...)
+		change(&c)
+
+		data, err := encMode.Marshal(c)
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		return data
+	}
+
+	cases := map[string][]byte{
+		"truncated":     data[:len(data)-1],
+		"version":       encode(func(w *flatSnapshot) { w.Version = 0 }),
+		"no blocks":     encode(func(w *flatSnapshot) { w.Blocks = nil }),
+		"tampered root": encode(func(w *flatSnapshot) { w.Root[0] ^= 1 }),
+		"tampered leaf": encode(func(w *flatSnapshot) { w.Blocks[1] = []byte("other") }),
+		"settings":      encode(func(w *flatSnapshot) { w.SortedPairs = true }),
+		"unknown hash":  encode(func(w *flatSnapshot) { w.Hash = "unregistered" }),
+	}
+	for name, data := range cases {
+		if _, err := UnmarshalFlatTree(data); !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("[case:%s] error: expected ErrInvalidEncoding got %v", name, err)
+		}
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	tree := testTree(t, 9)
+	p, _ := tree.Prove(tree.Leaves[8].Hash)
+	proofData, err := MarshalProof(p)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	treeData, err := MarshalFlatTree(testFlatTree(t, 20, false))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	for limit, o := range map[string]merklego.DecodeOptions{
+		"MaxInputSize":  {MaxInputSize: len(proofData) - 1},
+		"MaxProofSteps": {MaxProofSteps: 3},
+		"MaxTreeSize":   {MaxTreeSize: 8},
+		"MaxHashSize":   {MaxHashSize: 31},
+	} {
+		_, err := UnmarshalProofWithOptions(proofData, o)
+		var target *merklego.DecodeLimitError
+		if !errors.As(err, &target) || target.Limit != limit || !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("[proof:%s] error: expected a %s limit error got %v", limit, limit, err)
+		}
+	}
+
+	for limit, o := range map[string]merklego.DecodeOptions{
+		"MaxInputSize": {MaxInputSize: len(treeData) - 1},
+		"MaxTreeSize":  {MaxTreeSize: 19},
+		"MaxHashSize":  {MaxHashSize: 31},
+	} {
+		_, err := UnmarshalFlatTreeWithOptions(treeData, o)
+		var target *merklego.DecodeLimitError
+		if !errors.As(err, &target) || target.Limit != limit || !errors.Is(err, merklego.ErrDecodeLimit) {
+			t.Errorf("[tree:%s] error: expected a %s limit error got %v", limit, limit, err)
+		}
+	}
+
+	// The library refuses arrays longer than its bound as their length is
+	// read, before the elements are decoded.
+	huge := []byte{0xa2, 0x01, 0x01, 0x02, 0x9b, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if _, err := UnmarshalFlatTree(huge); !errors.Is(err, merklego.ErrDecodeLimit) {
+		t.Errorf("error: expected ErrDecodeLimit got %v", err)
+	}
+
+	if _, err := UnmarshalProofWithOptions(proofData, merklego.DecodeOptions{MaxProofSteps: 4, MaxTreeSize: 9, MaxHashSize: 32}); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+	if _, err := UnmarshalFlatTreeWithOptions(treeData, merklego.DecodeOptions{MaxTreeSize: 20, MaxHashSize: 32}); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+}
+
+func FuzzUnmarshalProof(f *testing.F) {
+	for n := 1; n <= 5; n++ {
+		tree, _ := merklego.NewTreeFromBytes([][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}[:n])
+		p, _ := tree.Prove(tree.Leaves[n-1].Hash)
+		data, _ := MarshalProof(p)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := UnmarshalProof(data)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidEncoding) {
+				t.Fatalf("error: expected ErrInvalidEncoding got %v", err)
+			}
+			return
+		}
+
+		encoded, err := MarshalProof(p)
+		if err != nil {
+			t.Fatalf("error: cannot encode a decoded proof: %v", err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("error: expected %x to encode back to itself got %x", data, encoded)
+		}
+	})
+}
+
+func FuzzUnmarshalFlatTree(f *testing.F) {
+	for n := 1; n <= 4; n++ {
+		blocks := make([]merklego.Block, n)
+		for i := range blocks {
+			blocks[i] = merklego.Block(fmt.Sprintf("block-%d", i))
+		}
+		mt := merklego.NewMerkleTree(blocks...)
+		if err := mt.Finalize(); err != nil {
+			f.Fatal(err)
+		}
+		data, _ := MarshalFlatTree(mt)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mt, err := UnmarshalFlatTree(data)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidEncoding) {
+				t.Fatalf("error: expected ErrInvalidEncoding got %v", err)
+			}
+			return
+		}
+
+		if _, err := MarshalFlatTree(mt); err != nil {
+			t.Fatalf("error: cannot encode a decoded tree: %v", err)
+		}
+	})
+}
+
+func TestFlatTreeDistinctPadding(t *testing.T) {
+	mt := merklego.NewMerkleTree(merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+	if err := mt.Finalize(merklego.WithDistinctPadding(true)); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	decoded, err := UnmarshalFlatTree(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+}
+
+func TestLengthPrefixedLeaves(t *testing.T) {
+	mt := merklego.NewMerkleTree(merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+	if err := mt.Finalize(merklego.WithLengthPrefixedLeaves(true)); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	decoded, err := UnmarshalFlatTree(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+
+	p, err := mt.Prove(merklego.Block("b"))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if data, err = MarshalProof(p); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	q, err := UnmarshalProof(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("error: expected %+v got %+v", p, q)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	opts := []merklego.Option{merklego.WithLeafPrefix([]byte("P:leaf")), merklego.WithNodePrefix([]byte("P:node"))}
+	mt := merklego.NewMerkleTree(merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+	if err := mt.Finalize(opts...); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	decoded, err := UnmarshalFlatTree(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+
+	tree, err := merklego.NewTreeFromBytes([][]byte{[]byte("a"), []byte("b"), []byte("c")}, opts...)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	p, err := tree.Prove(tree.Leaves[1].Hash)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if data, err = MarshalProof(p); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	q, err := UnmarshalProof(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("error: expected %+v got %+v", p, q)
+	}
+}
+
+func TestHMACKey(t *testing.T) {
+	key := merklego.WithHMACKey([]byte("cbor test key"))
+	mt := merklego.NewMerkleTree(merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+	if err := mt.Finalize(key); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if bytes.Contains(data, []byte("cbor test key")) {
+		t.Errorf("error: expected the key to be left out of the snapshot")
+	}
+	if _, err := UnmarshalFlatTree(data); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("error: expected ErrInvalidEncoding without the key got %v", err)
+	}
+	decoded, err := UnmarshalFlatTreeWithOptions(data, merklego.DecodeOptions{}, key)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+
+	p, err := mt.Prove(merklego.Block("b"))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if data, err = MarshalProof(p); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	q, err := UnmarshalProof(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("error: expected %+v got %+v", p, q)
+	}
+}
+
+func TestSaltedLeaves(t *testing.T) {
+	mt := merklego.NewMerkleTree()
+	for _, b := range []string{"a", "b", "c"} {
+		if err := mt.InsertSalted(merklego.Block(b), []byte("salt of "+b)); err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+	}
+	if err := mt.Finalize(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	decoded, err := UnmarshalFlatTree(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+
+	p, err := mt.Prove(merklego.Block("b"))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if data, err = MarshalProof(p); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	q, err := UnmarshalProof(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("error: expected %+v got %+v", p, q)
+	}
+}
+
+func TestDigestSize(t *testing.T) {
+	mt := merklego.NewMerkleTree(merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+	if err := mt.Finalize(merklego.WithDigestSize(16)); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	data, err := MarshalFlatTree(mt)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	decoded, err := UnmarshalFlatTree(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	want, _ := mt.RootHash()
+	got, _ := decoded.RootHash()
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: expected root %x got %x", want, got)
+	}
+
+	p, err := mt.Prove(merklego.Block("b"))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if data, err = MarshalProof(p); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	q, err := UnmarshalProof(data)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("error: expected %+v got %+v", p, q)
+	}
+	if err := q.Verify(want); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+
+	p.DigestSize = 20
+	if _, err := MarshalProof(p); err == nil {
+		t.Errorf("error: expected an error for a digest size other than the hash length")
+	}
+}
+
+func TestHashNames(t *testing.T) {
+	for _, name := range []string{"sha256", "blake2b-256"} {
+		mt, err := merklego.NewMerkleTreeNamed(name, merklego.Block("a"), merklego.Block("b"), merklego.Block("c"))
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+		if err := mt.Finalize(); err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+		root, _ := mt.RootHash()
+		p, err := mt.Prove(merklego.Block("b"))
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		data, err := MarshalProof(p)
+		if err != nil {
+			t.Fatalf("[%s] error: unexpected error: %v", name, err)
+		}
+		decoded, err := UnmarshalProof(data)
+		if err != nil || decoded.Hash != name {
+			t.Fatalf("[%s] error: expected a proof of %q got %v, %v", name, name, decoded, err)
+		}
+		if err := decoded.Verify(root); err != nil {
+			t.Errorf("[%s] error: unexpected error: %v", name, err)
+		}
+
+		data, err = MarshalFlatTree(mt)
+		if err != nil {
+			t.Fatalf("[%s] error: unexpected error: %v", name, err)
+		}
+		restored, err := UnmarshalFlatTree(data)
+		if err != nil {
+			t.Fatalf("[%s] error: unexpected error: %v", name, err)
+		}
+		if got, _ := restored.RootHash(); !bytes.Equal(got, root) {
+			t.Errorf("[%s] error: expected root %x got %x", name, root, got)
+		}
+
+		var unknown *merklego.UnknownHashError
+		p.Hash = "blake3"
+		data, _ = MarshalProof(p)
+		if _, err := UnmarshalProof(data); !errors.As(err, &unknown) || !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("[%s] error: expected an unknown hash error got %v", name, err)
+		}
+
+		s, _ := mt.Snapshot()
+		var w flatSnapshot
+		data, _ = MarshalFlatTree(mt)
+		if err := cbor.Unmarshal(data, &w); err != nil || w.Hash != s.Hash {
+			t.Fatalf("[%s] error: expected hash %q got %q, %v", name, s.Hash, w.Hash, err)
+		}
+		w.Hash = "blake3"
+		data, _ = encMode.Marshal(w)
+		if _, err := UnmarshalFlatTree(data); !errors.As(err, &unknown) || !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("[%s] error: expected an unknown hash error got %v", name, err)
+		}
+	}
+}
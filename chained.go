@@ -0,0 +1,62 @@
+package merklego
+
+import (
+	"fmt"
+)
+
+// ChainedProof proves a leaf through a stack of trees whose roots are leaves
+// of the tree above, such as shards committed into a tree of shard roots.
+// Links go from the tree holding the leaf up to the topmost tree, each with
+// its own hash function and options.
+//
+// The leaf of a link is the value the tree commits to: a block of a
+// FlatMerkleTree or the item hash of a MerkleTree. The leaf of every link
+// but the first is the root computed from the link below.
+type ChainedProof struct {
+	Links []*Proof
+}
+
+// VerifyChained checks that p leads from leaf, through every link, to
+// superRoot. The leaf hash of each link must be the hash of its leaf in that
+// link's tree, and the root computed from the last link must be superRoot.
+// It returns an error wrapping ErrInvalidProof when they are not.
+func VerifyChained(superRoot []byte, leaf []byte, p *ChainedProof) error {
+	if len(p.Links) == 0 {
+		return fmt.Errorf("error: empty chained proof: %w", ErrInvalidProof)
+	}
+
+	for i, link := range p.Links {
+		if link.Compressed() {
+			return fmt.Errorf("error: cannot verify compressed link %d of a chained proof", i)
+		}
+		if err := link.Validate(); err != nil {
+			return fmt.Errorf("error: link %d of a chained proof: %w", i, err)
+		}
+
+		pair, err := link.pairHasher(nil)
+		if err != nil {
+			return fmt.Errorf("error: link %d of a chained proof: %w", i, err)
+		}
+
+		leafHash, err := link.hashLeaf(leaf, nil)
+		if err != nil {
+			return err
+		}
+		if !RootEqual(leafHash, link.LeafHash) {
+			return fmt.Errorf("error: link %d of a chained proof: leaf hash mismatch: %w", i, ErrInvalidProof)
+		}
+
+		leaf, _ = foldSteps(link.LeafHash, link.Steps, func(left, right []byte) ([]byte, error) {
+			return pair(left, right), nil
+		})
+		if leaf, err = link.commitRoot(leaf, nil); err != nil {
+			return err
+		}
+	}
+
+	if !RootEqual(leaf, superRoot) {
+		return fmt.Errorf("error: chained proof root mismatch; got: %X, want: %X: %w", leaf, superRoot, ErrInvalidProof)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// chainedTrees builds three levels: flat shards of blocks, a SHA-512 tree
+// with domain separation over groups of shard roots, and a SHA-256 tree over
+// the roots of the groups. It returns the proof of block b of shard s and the
+// top root.
+func chainedTrees(t *testing.T, s, b int) ([]byte, *ChainedProof) {
+	var shardRoots [][]byte
+	var shardProof *Proof
+	for i := 0; i < 6; i++ {
+		blocks := make([]Block, 5)
+		for j := range blocks {
+			blocks[j] = Block(fmt.Sprintf("shard-%d-block-%d", i, j))
+		}
+		shard := NewMerkleTree(blocks...)
+		require.NoError(t, shard.Finalize())
+		root, err := shard.RootHash()
+		require.NoError(t, err)
+		shardRoots = append(shardRoots, root)
+		if i == s {
+			shardProof, err = shard.Prove(blocks[b])
+			require.NoError(t, err)
+		}
+	}
+
+	var groupRoots []Storable
+	var groupProof *Proof
+	for g := 0; g < 3; g++ {
+		contents := []Storable{TestHashContent{hash: shardRoots[2*g]}, TestHashContent{hash: shardRoots[2*g+1]}}
+		group, err := NewTreeWithOptions(contents, WithHashStrategy(sha512.New), WithDomainSeparation(true))
+		require.NoError(t, err)
+		groupRoots = append(groupRoots, TestHashContent{hash: group.MerkleRoot()})
+		if g == s/2 {
+			groupProof, err = group.Prove(group.RealLeaves()[s%2].Hash)
+			require.NoError(t, err)
+		}
+	}
+
+	top, err := NewTree(groupRoots)
+	require.NoError(t, err)
+	topProof, err := top.Prove(top.RealLeaves()[s/2].Hash)
+	require.NoError(t, err)
+
+	return top.MerkleRoot(), &ChainedProof{Links: []*Proof{shardProof, groupProof, topProof}}
+}
+
+func TestVerifyChained(t *testing.T) {
+	for s := 0; s < 6; s++ {
+		for b := 0; b < 5; b++ {
+			root, p := chainedTrees(t, s, b)
+			require.Equal(t, "sha512", p.Links[1].Hash)
+			require.NoError(t, VerifyChained(root, Block(fmt.Sprintf("shard-%d-block-%d", s, b)), p), "shard:%d block:%d", s, b)
+
+			other := Block(fmt.Sprintf("shard-%d-block-%d", s, (b+1)%5))
+			require.ErrorIs(t, VerifyChained(root, other, p), ErrInvalidProof)
+
+			require.ErrorIs(t, VerifyChained(root, Block(fmt.Sprintf("shard-%d-block-%d", s, b)), &ChainedProof{Links: p.Links[:2]}), ErrInvalidProof)
+		}
+	}
+
+	root, p := chainedTrees(t, 3, 1)
+	leaf := Block("shard-3-block-1")
+
+	_, swapped := chainedTrees(t, 2, 1)
+	p.Links[1] = swapped.Links[1]
+	require.ErrorIs(t, VerifyChained(root, leaf, p), ErrInvalidProof)
+
+	require.ErrorIs(t, VerifyChained(root, leaf, &ChainedProof{}), ErrInvalidProof)
+
+	_, p = chainedTrees(t, 3, 1)
+	p.Links[2].Hash = "unknown"
+	require.Error(t, VerifyChained(root, leaf, p))
+}
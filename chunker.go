@@ -0,0 +1,153 @@
+package merklego
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// chunkWindow is the number of bytes the rolling hash of a Chunker covers.
+const chunkWindow = 64
+
+// buzTable maps every byte to the 32 bit value the rolling hash of a Chunker
+// mixes in for it. The values are drawn from a fixed seed with SplitMix64 so
+// that chunk boundaries never change across releases.
+var buzTable = func() (table [256]uint32) {
+	seed := uint64(0x6d65726b6c65676f)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = uint32(z ^ z>>31)
+	}
+
+	return table
+}()
+
+// ChunkerOptions bounds the size of the chunks of a Chunker. Zero values take
+// the defaults of 2 KiB, 8 KiB and 64 KiB.
+type ChunkerOptions struct {
+	// MinSize is the size below which a chunk is never cut, at least 64.
+	MinSize int
+	// AvgSize is the average size of the chunks beyond MinSize, rounded down
+	// to a power of two: a chunk is cut after a byte where the low
+	// log2(AvgSize) bits of the rolling hash are zero.
+	AvgSize int
+	// MaxSize is the size at which a chunk is always cut.
+	MaxSize int
+}
+
+// withDefaults returns o with the defaults of its zero fields.
+func (o ChunkerOptions) withDefaults() ChunkerOptions {
+	if o.MinSize == 0 {
+		o.MinSize = 2 << 10
+	}
+	if o.AvgSize == 0 {
+		o.AvgSize = 8 << 10
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = 64 << 10
+	}
+
+	return o
+}
+
+// check returns an error for bounds that are out of order or too small.
+func (o ChunkerOptions) check() error {
+	if o.MinSize < chunkWindow || o.AvgSize < o.MinSize || o.MaxSize < o.AvgSize {
+		return fmt.Errorf("invalid chunk sizes: min %d, avg %d, max %d", o.MinSize, o.AvgSize, o.MaxSize)
+	}
+
+	return nil
+}
+
+// Chunker splits a stream into content-defined chunks: a chunk ends after a
+// byte where a buzhash of the last 64 bytes hits a pattern, so boundaries
+// depend on the content around them and not on offsets. Inserting or removing
+// bytes only changes the chunks around the edit: the following ones are cut
+// at the same boundaries, and hash to the same leaves, as before.
+type Chunker struct {
+	r    *bufio.Reader
+	opts ChunkerOptions
+	mask uint32
+	err  error
+}
+
+// ChunkReader returns a Chunker of the content of r, bounded by opts. Invalid
+// bounds are reported by the first call to Next.
+func ChunkReader(r io.Reader, opts ChunkerOptions) *Chunker {
+	opts = opts.withDefaults()
+	c := &Chunker{r: bufio.NewReaderSize(r, opts.MaxSize), opts: opts, err: opts.check()}
+	c.mask = 1<<uint(bits.Len(uint(opts.AvgSize))-1) - 1
+
+	return c
+}
+
+// Next returns the next chunk, at least MinSize and at most MaxSize bytes
+// unless it is the last one, or io.EOF once the content is exhausted. The
+// chunk is a new slice.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	chunk := make([]byte, 0, c.opts.AvgSize)
+	var h uint32
+	for len(chunk) < c.opts.MaxSize {
+		b, err := c.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			c.err = err
+			return nil, err
+		}
+
+		chunk = append(chunk, b)
+		h = bits.RotateLeft32(h, 1) ^ buzTable[b]
+		if len(chunk) > chunkWindow {
+			h ^= bits.RotateLeft32(buzTable[chunk[len(chunk)-1-chunkWindow]], chunkWindow)
+		}
+		if len(chunk) >= c.opts.MinSize && h&c.mask == 0 {
+			return chunk, nil
+		}
+	}
+
+	if len(chunk) == 0 {
+		c.err = io.EOF
+		return nil, io.EOF
+	}
+
+	return chunk, nil
+}
+
+// NewTreeFromChunkedReader returns the FlatMerkleTree of the chunks of the
+// content of r, cut by a Chunker bounded by chunkOpts, finalized with opts.
+func NewTreeFromChunkedReader(r io.Reader, chunkOpts ChunkerOptions, opts ...Option) (*FlatMerkleTree, error) {
+	c := ChunkReader(r, chunkOpts)
+	tree := NewMerkleTree()
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := tree.Insert(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if len(tree.blocks) == 0 {
+		return nil, ErrEmptyMerkleTree
+	}
+
+	if err := tree.Finalize(opts...); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
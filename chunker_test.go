@@ -0,0 +1,118 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// chunks returns the chunks of data cut with opts.
+func chunks(t *testing.T, data []byte, opts ChunkerOptions) [][]byte {
+	var out [][]byte
+	c := ChunkReader(bytes.NewReader(data), opts)
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, chunk)
+	}
+}
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+
+	return data
+}
+
+func TestChunkerBounds(t *testing.T) {
+	data := randomData(1, 1<<20)
+	for _, opts := range []ChunkerOptions{
+		{},
+		{MinSize: 64, AvgSize: 256, MaxSize: 1024},
+		{MinSize: 4 << 10, AvgSize: 4 << 10, MaxSize: 4 << 10},
+	} {
+		out := chunks(t, data, opts)
+		opts = opts.withDefaults()
+		require.Equal(t, data, bytes.Join(out, nil))
+		for i, chunk := range out {
+			require.LessOrEqual(t, len(chunk), opts.MaxSize)
+			if i < len(out)-1 {
+				require.GreaterOrEqual(t, len(chunk), opts.MinSize)
+			}
+		}
+		// Chunks average a little more than AvgSize, MinSize being skipped.
+		avg := len(data) / len(out)
+		require.Greater(t, avg, opts.AvgSize/2)
+		require.Less(t, avg, 2*opts.AvgSize+opts.MinSize)
+	}
+
+	// Boundaries only depend on the content.
+	require.Equal(t, chunks(t, data, ChunkerOptions{}), chunks(t, append([]byte(nil), data...), ChunkerOptions{}))
+}
+
+func TestChunkerInsertion(t *testing.T) {
+	data := randomData(2, 1<<20)
+	edited := append(append(append([]byte(nil), data[:1000]...), "inserted bytes"...), data[1000:]...)
+
+	before := map[[sha256.Size]byte]bool{}
+	original := chunks(t, data, ChunkerOptions{})
+	for _, chunk := range original {
+		before[sha256.Sum256(chunk)] = true
+	}
+	changed := 0
+	for _, chunk := range chunks(t, edited, ChunkerOptions{}) {
+		if !before[sha256.Sum256(chunk)] {
+			changed++
+		}
+	}
+	require.Greater(t, len(original), 64)
+	require.LessOrEqual(t, changed, 2)
+
+	// Fixed-size chunks all shift.
+	fixed := ChunkerOptions{MinSize: 8 << 10, AvgSize: 8 << 10, MaxSize: 8 << 10}
+	before = map[[sha256.Size]byte]bool{}
+	for _, chunk := range chunks(t, data, fixed) {
+		before[sha256.Sum256(chunk)] = true
+	}
+	changed = 0
+	out := chunks(t, edited, fixed)
+	for _, chunk := range out {
+		if !before[sha256.Sum256(chunk)] {
+			changed++
+		}
+	}
+	require.Equal(t, len(out), changed)
+}
+
+func TestNewTreeFromChunkedReader(t *testing.T) {
+	data := randomData(3, 200<<10)
+	tree, err := NewTreeFromChunkedReader(bytes.NewReader(data), ChunkerOptions{}, WithDistinctPadding(true))
+	require.NoError(t, err)
+
+	want := NewMerkleTree()
+	for _, chunk := range chunks(t, data, ChunkerOptions{}) {
+		require.NoError(t, want.Insert(chunk))
+	}
+	require.NoError(t, want.Finalize(WithDistinctPadding(true)))
+	require.Equal(t, want.LeafCount(), tree.LeafCount())
+	wantRoot, err := want.RootHash()
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, wantRoot, root)
+
+	_, err = NewTreeFromChunkedReader(bytes.NewReader(nil), ChunkerOptions{})
+	require.ErrorIs(t, err, ErrEmptyMerkleTree)
+	_, err = NewTreeFromChunkedReader(bytes.NewReader(data), ChunkerOptions{MinSize: 16})
+	require.Error(t, err)
+	_, err = NewTreeFromChunkedReader(bytes.NewReader(data), ChunkerOptions{MinSize: 4096, AvgSize: 1024})
+	require.Error(t, err)
+}
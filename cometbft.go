@@ -0,0 +1,91 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CometProof is the simple merkle proof of CometBFT, its crypto/merkle.Proof:
+// the number of leaves of the tree, the index and leaf hash of the proven
+// item, and the aunts, the siblings of its path from the leaf up, which are
+// the RFC 6962 audit path of a LogTree. Its JSON encoding is the one of
+// CometBFT, integers being written as strings.
+type CometProof struct {
+	Total    int64    `json:"total,string"`
+	Index    int64    `json:"index,string"`
+	LeafHash []byte   `json:"leaf_hash"`
+	Aunts    [][]byte `json:"aunts,omitempty"`
+}
+
+// cometLogTree returns the LogTree of items, CometBFT hashing a nil item as an
+// empty one.
+func cometLogTree(items [][]byte) *LogTree {
+	t := &LogTree{}
+	for _, item := range items {
+		if item == nil {
+			item = []byte{}
+		}
+		_ = t.Append(Block(item))
+	}
+
+	return t
+}
+
+// CometHashFromByteSlices returns the root CometBFT's merkle.HashFromByteSlices
+// computes over items, with which CometBFT commits to block data and
+// validator sets. Its trees are shaped and hashed as RFC 6962 shapes them, so
+// it is the root of the LogTree of items, SHA-256 of the empty string when
+// there are none.
+func CometHashFromByteSlices(items [][]byte) []byte {
+	root, _ := cometLogTree(items).RootHash()
+	return root
+}
+
+// CometProofsFromByteSlices returns the root of items and the proof of each
+// of them, as CometBFT's merkle.ProofsFromByteSlices does.
+func CometProofsFromByteSlices(items [][]byte) ([]byte, []*CometProof) {
+	t := cometLogTree(items)
+	root, _ := t.RootHash()
+
+	proofs := make([]*CometProof, len(items))
+	for i := range items {
+		path, _ := t.InclusionProof(i, len(items))
+		p := &CometProof{
+			Total:    int64(len(items)),
+			Index:    int64(i),
+			LeafHash: copyNode(t.levels[0][i]),
+			Aunts:    make([][]byte, len(path)),
+		}
+		for j, aunt := range path {
+			p.Aunts[j] = aunt
+		}
+		proofs[i] = p
+	}
+
+	return root, proofs
+}
+
+// Verify checks that p proves leaf to be an item of the tree with the given
+// root, as the Verify method of CometBFT's proofs does. Errors wrap
+// ErrInvalidProof.
+func (p *CometProof) Verify(root, leaf []byte) error {
+	if p.Total <= 0 {
+		return fmt.Errorf("invalid proof: total %d must be positive: %w", p.Total, ErrInvalidProof)
+	}
+	if p.Index < 0 || p.Index >= p.Total {
+		return fmt.Errorf("invalid proof: index %d out of range [0, %d): %w", p.Index, p.Total, ErrInvalidProof)
+	}
+	if leaf == nil {
+		leaf = []byte{}
+	}
+	if !bytes.Equal(hashNode(leaf, false), p.LeafHash) {
+		return fmt.Errorf("invalid proof: leaf hash mismatch; got: %X, want: %X: %w", hashNode(leaf, false).Bytes(), p.LeafHash, ErrInvalidProof)
+	}
+
+	aunts := make([]TreeNode, len(p.Aunts))
+	for i, aunt := range p.Aunts {
+		aunts[i] = aunt
+	}
+
+	return VerifyInclusion(root, Block(leaf), int(p.Index), int(p.Total), aunts)
+}
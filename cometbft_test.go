@@ -0,0 +1,77 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCometHashFromByteSlices(t *testing.T) {
+	// The test cases of TestHashFromByteSlices of CometBFT's crypto/merkle.
+	testCases := map[string]struct {
+		slices [][]byte
+		want   string
+	}{
+		"nil":          {nil, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		"empty":        {[][]byte{}, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		"single":       {[][]byte{{1, 2, 3}}, "054edec1d0211f624fed0cbca9d4f9400b0e491c43742af2c5b0abebf0c990d8"},
+		"single blank": {[][]byte{{}}, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d"},
+		"two":          {[][]byte{{1, 2, 3}, {4, 5, 6}}, "82e6cfce00453804379b53962939eaa7906b39904be0813fcadd31b100773c4b"},
+		"many":         {[][]byte{{1, 2}, {3, 4}, {5, 6}, {7, 8}, {9, 10}}, "f326493eceab4f2d9ffbc78c59432a0a005d6ea98392045c74df5d14a113be18"},
+	}
+	for name, tc := range testCases {
+		require.Equal(t, tc.want, hex.EncodeToString(CometHashFromByteSlices(tc.slices)), name)
+
+		root, proofs := CometProofsFromByteSlices(tc.slices)
+		require.Equal(t, tc.want, hex.EncodeToString(root), name)
+		require.Len(t, proofs, len(tc.slices), name)
+	}
+
+	// A nil item hashes as an empty one.
+	require.Equal(t, CometHashFromByteSlices([][]byte{{}}), CometHashFromByteSlices([][]byte{nil}))
+}
+
+func TestCometProofs(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		items := make([][]byte, n)
+		for i := range items {
+			items[i] = []byte(fmt.Sprintf("item-%d", i))
+		}
+
+		root, proofs := CometProofsFromByteSlices(items)
+		for i, p := range proofs {
+			msg := fmt.Sprintf("n: %d, i: %d", n, i)
+			require.Equal(t, int64(n), p.Total, msg)
+			require.Equal(t, int64(i), p.Index, msg)
+			require.NoError(t, p.Verify(root, items[i]), msg)
+			require.ErrorIs(t, p.Verify(root, []byte("other")), ErrInvalidProof, msg)
+			if n > 1 {
+				require.ErrorIs(t, p.Verify(root, items[(i+1)%n]), ErrInvalidProof, msg)
+			}
+		}
+	}
+
+	root, proofs := CometProofsFromByteSlices([][]byte{{1, 2}, {3, 4}, {5, 6}, {7, 8}, {9, 10}})
+	p := *proofs[2]
+	for name, change := range map[string]func(p *CometProof){
+		"total":    func(p *CometProof) { p.Total = 0 },
+		"index":    func(p *CometProof) { p.Index = 5 },
+		"negative": func(p *CometProof) { p.Index = -1 },
+		"aunts":    func(p *CometProof) { p.Aunts = p.Aunts[:1] },
+		"aunt":     func(p *CometProof) { p.Aunts = [][]byte{p.Aunts[1], p.Aunts[0]} },
+	} {
+		c := p
+		change(&c)
+		require.ErrorIs(t, c.Verify(root, []byte{5, 6}), ErrInvalidProof, name)
+	}
+
+	data, err := json.Marshal(&p)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"total":"5","index":"2","leaf_hash":`)
+	var decoded CometProof
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, p, decoded)
+}
@@ -0,0 +1,294 @@
+package merklego
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// noNode marks a missing child or parent in a compactNode.
+const noNode = -1
+
+// compactNode is a node of a CompactTree. It refers to its neighbours by their
+// position in the node slice and to its hash by its position in the hash slab.
+type compactNode struct {
+	left, right, parent int32
+	hashLen             uint16
+	leaf, dup           bool
+	hashOff             int64
+}
+
+// CompactTree is a merkle tree storing all its nodes in a single slice and all
+// their hashes in a single byte slab, with nodes referring to each other by
+// index instead of by pointer. It holds no pointers per node, so it is far
+// cheaper for the garbage collector than a MerkleTree of the same size, and
+// its roots are identical to the ones of a MerkleTree built with the same
+// options. Items are not retained.
+type CompactTree struct {
+	nodes     []compactNode
+	hashes    []byte
+	root      int32
+	leafCount int
+
+	// hasher carries the configuration of the tree and its hashing helpers;
+	// it is never built.
+	hasher *MerkleTree
+}
+
+// CompactNode is a read-only handle on a node of a CompactTree.
+type CompactNode struct {
+	t *CompactTree
+	i int32
+}
+
+// NewCompactTree creates a new CompactTree with the Storable contents in
+// content, configured by the same options as a MerkleTree.
+func NewCompactTree(content []Storable, opts ...Option) (*CompactTree, error) {
+	if len(content) == 0 {
+		return nil, ErrNoContent
+	}
+	if len(content) > math.MaxInt32/2-64 {
+		return nil, fmt.Errorf("error: cannot make a compact merkle tree of %d items", len(content))
+	}
+
+	t := &CompactTree{hasher: newMerkleTree(opts), leafCount: len(content)}
+	if err := t.build(content); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// build hashes the leaves and then the levels above them, following the
+// padding rules of buildTree and buildIntermediate.
+func (t *CompactTree) build(content []Storable) error {
+	h := t.hasher
+
+	leafHashes := make([][]byte, len(content))
+	err := parallelRange(len(content), h.workers, func(lo, hi int, stop *int32) error {
+		for i := lo; i < hi && atomic.LoadInt32(stop) == 0; i++ {
+			hash, err := h.contentHash(content[i])
+			if err != nil {
+				return &ContentError{Index: i, Err: err}
+			}
+			leafHashes[i] = hash
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.nodes = make([]compactNode, 0, 2*len(content)+64)
+	t.hashes = make([]byte, 0, (2*len(content)+64)*h.hashers.size)
+	level := make([]int32, len(content), len(content)+1)
+	for i, hash := range leafHashes {
+		t.nodes = append(t.nodes, compactNode{
+			left: noNode, right: noNode, parent: noNode,
+			hashOff: int64(len(t.hashes)), hashLen: uint16(len(hash)),
+			leaf: true,
+		})
+		t.hashes = append(t.hashes, hash...)
+		level[i] = int32(i)
+	}
+
+	level = t.padLevel(level)
+	for t.needsPairing(level) {
+		next, err := t.pairLevel(level)
+		if err != nil {
+			return err
+		}
+		if len(next) == 1 {
+			level = next
+			break
+		}
+		level = t.padLevel(next)
+	}
+	t.root = level[0]
+
+	return nil
+}
+
+// needsPairing reports whether level must be hashed into a parent level, as
+// MerkleTree.needsPairing does.
+func (t *CompactTree) needsPairing(level []int32) bool {
+	return len(level) > 1 || t.nodes[level[0]].leaf && t.hasher.padding != PadPromote && !t.hasher.singleLeafRoot
+}
+
+// padLevel appends a duplicate of the last node of an odd level when the tree
+// uses PadDuplicateLast, as MerkleTree.padLevel does.
+func (t *CompactTree) padLevel(level []int32) []int32 {
+	if t.hasher.padding != PadDuplicateLast || len(level)%2 == 0 || !t.needsPairing(level) {
+		return level
+	}
+
+	dup := t.nodes[level[len(level)-1]]
+	dup.parent = noNode
+	dup.dup = true
+	t.nodes = append(t.nodes, dup)
+
+	return append(level, int32(len(t.nodes)-1))
+}
+
+// pairLevel hashes the nodes of level pairwise into the next level.
+func (t *CompactTree) pairLevel(level []int32) ([]int32, error) {
+	h := t.hasher
+	size := h.hashers.size
+	pairs := (len(level) + 1) / 2
+	if len(level)%2 == 1 && h.padding == PadPromote {
+		pairs = len(level) / 2
+	}
+
+	first := int32(len(t.nodes))
+	hashOff := len(t.hashes)
+	t.hashes = append(t.hashes, make([]byte, pairs*size)...)
+
+	next := make([]int32, 0, (len(level)+1)/2+1)
+	for j := 0; j < pairs; j++ {
+		left, right := level[2*j], level[2*j]
+		if 2*j+1 < len(level) {
+			right = level[2*j+1]
+		}
+
+		t.nodes = append(t.nodes, compactNode{
+			left: left, right: right, parent: noNode,
+			hashOff: int64(hashOff + j*size), hashLen: uint16(size),
+		})
+		t.nodes[left].parent = first + int32(j)
+		t.nodes[right].parent = first + int32(j)
+		next = append(next, first+int32(j))
+	}
+	if pairs < (len(level)+1)/2 {
+		next = append(next, level[len(level)-1])
+	}
+
+	err := parallelRange(pairs, h.workers, func(lo, hi int, stop *int32) error {
+		for j := lo; j < hi && atomic.LoadInt32(stop) == 0; j++ {
+			n := &t.nodes[first+int32(j)]
+			off := int(n.hashOff)
+			hash, err := h.appendPair(t.hashes[off:off:off+size], t.hash(n.left), t.hash(n.right))
+			if err != nil {
+				return err
+			}
+			if len(hash) != size {
+				return fmt.Errorf("error: pair hash of %d bytes in a tree of %d byte hashes", len(hash), size)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// hash returns the hash of the node at i, which belongs to the slab.
+func (t *CompactTree) hash(i int32) []byte {
+	n := &t.nodes[i]
+	return t.hashes[n.hashOff : n.hashOff+int64(n.hashLen) : n.hashOff+int64(n.hashLen)]
+}
+
+// MerkleRoot returns a copy of the merkle root of the tree.
+func (t *CompactTree) MerkleRoot() []byte {
+	return append([]byte(nil), t.hash(t.root)...)
+}
+
+// LeafCount returns the number of items the tree was built from.
+func (t *CompactTree) LeafCount() int {
+	return t.leafCount
+}
+
+// Root returns the root node of the tree.
+func (t *CompactTree) Root() CompactNode {
+	return CompactNode{t: t, i: t.root}
+}
+
+// Leaf returns the leaf of the item at index, the padding duplicate excluded.
+func (t *CompactTree) Leaf(index int) (CompactNode, bool) {
+	if index < 0 || index >= t.leafCount {
+		return CompactNode{}, false
+	}
+
+	return CompactNode{t: t, i: int32(index)}, true
+}
+
+// MerklePathAt returns the merkle path of the leaf of the item at index.
+func (t *CompactTree) MerklePathAt(index int) ([]ProofStep, error) {
+	if index < 0 || index >= t.leafCount {
+		return nil, fmt.Errorf("error: leaf index %d out of range [0, %d)", index, t.leafCount)
+	}
+
+	path := make([]ProofStep, 0, 32)
+	for i := int32(index); t.nodes[i].parent != noNode; i = t.nodes[i].parent {
+		p := t.nodes[t.nodes[i].parent]
+		if p.left == i {
+			path = append(path, ProofStep{Hash: t.hash(p.right)})
+		} else {
+			path = append(path, ProofStep{Hash: t.hash(p.left), Left: true})
+		}
+	}
+
+	return detachPath(path), nil
+}
+
+// VerifyMerklePath checks that path leads from the leaf of content to the
+// merkle root of the tree, returning ErrInvalidProof when it does not.
+func (t *CompactTree) VerifyMerklePath(content Storable, path []ProofStep) error {
+	hash, err := t.hasher.contentHash(content)
+	if err != nil {
+		return err
+	}
+
+	root, err := foldSteps(hash, path, t.hasher.hashPair)
+	if err != nil {
+		return err
+	}
+
+	if !RootEqual(root, t.hash(t.root)) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// Hash returns the hash of the node. It belongs to the tree and must be
+// treated as read-only.
+func (n CompactNode) Hash() []byte {
+	return n.t.hash(n.i)
+}
+
+// Left returns the left child of the node, if any.
+func (n CompactNode) Left() (CompactNode, bool) {
+	return n.t.node(n.t.nodes[n.i].left)
+}
+
+// Right returns the right child of the node, if any.
+func (n CompactNode) Right() (CompactNode, bool) {
+	return n.t.node(n.t.nodes[n.i].right)
+}
+
+// Parent returns the parent of the node, if any.
+func (n CompactNode) Parent() (CompactNode, bool) {
+	return n.t.node(n.t.nodes[n.i].parent)
+}
+
+// IsLeaf reports whether the node holds the hash of an item.
+func (n CompactNode) IsLeaf() bool {
+	return n.t.nodes[n.i].leaf
+}
+
+// IsDuplicate reports whether the node is a padding duplicate.
+func (n CompactNode) IsDuplicate() bool {
+	return n.t.nodes[n.i].dup
+}
+
+func (t *CompactTree) node(i int32) (CompactNode, bool) {
+	if i == noNode {
+		return CompactNode{}, false
+	}
+
+	return CompactNode{t: t, i: i}, true
+}
@@ -0,0 +1,165 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestCompactTreeRoots(t *testing.T) {
+	configs := map[string][]Option{
+		"default":    nil,
+		"separated":  {WithDomainSeparation(true)},
+		"sorted":     {WithSortedPairs(true)},
+		"selfPair":   {WithPaddingStrategy(PadSelfPair)},
+		"promote":    {WithPaddingStrategy(PadPromote)},
+		"singleLeaf": {WithSingleLeafRoot(true)},
+		"workers":    {WithWorkers(4)},
+	}
+	for name, opts := range configs {
+		for n := 1; n <= 33; n++ {
+			contents := paddingContents(n)
+			expected, err := NewTreeWithOptions(contents, opts...)
+			if err != nil {
+				t.Fatalf("[%s leaves:%d] error: unexpected error: %v", name, n, err)
+			}
+
+			tree, err := NewCompactTree(contents, opts...)
+			if err != nil {
+				t.Fatalf("[%s leaves:%d] error: unexpected error: %v", name, n, err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+				t.Errorf("[%s leaves:%d] error: expected root %x got %x", name, n, expected.MerkleRoot(), tree.MerkleRoot())
+			}
+
+			for i, c := range contents {
+				path, err := tree.MerklePathAt(i)
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", name, n, i, err)
+				}
+				if err := tree.VerifyMerklePath(c, path); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid path got %v", name, n, i, err)
+				}
+				if err := expected.VerifyMerklePath(c, path); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected path valid for the pointer tree got %v", name, n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestCompactTreeLargeRoot(t *testing.T) {
+	contents := syntheticContents(5000)
+	expected, _ := NewTree(contents)
+
+	tree, err := NewCompactTree(contents, WithWorkers(4))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), tree.MerkleRoot())
+	}
+}
+
+func TestCompactTreeTraversal(t *testing.T) {
+	contents := paddingContents(5)
+	expected, _ := NewTree(contents)
+	tree, _ := NewCompactTree(contents)
+
+	var walk func(n CompactNode, e *Node)
+	walk = func(n CompactNode, e *Node) {
+		if !bytes.Equal(n.Hash(), e.Hash) || n.IsLeaf() != e.IsLeaf() || n.IsDuplicate() != e.IsDuplicate() {
+			t.Errorf("error: expected node %v got %x leaf=%t dup=%t", e, n.Hash(), n.IsLeaf(), n.IsDuplicate())
+		}
+
+		left, hasLeft := n.Left()
+		right, hasRight := n.Right()
+		if hasLeft != (e.Left != nil) || hasRight != (e.Right != nil) {
+			t.Fatalf("error: expected children of %v to match", e)
+		}
+		if hasLeft && !e.IsDuplicate() {
+			if p, ok := left.Parent(); !ok || p != n {
+				t.Errorf("error: expected the parent of the left child of %v to link back", e)
+			}
+			walk(left, e.Left)
+			walk(right, e.Right)
+		}
+	}
+	walk(tree.Root(), expected.Root)
+
+	if _, ok := tree.Root().Parent(); ok {
+		t.Errorf("error: expected the root to have no parent")
+	}
+	if leaf, ok := tree.Leaf(4); !ok || !bytes.Equal(leaf.Hash(), expected.Leaves[4].Hash) {
+		t.Errorf("error: expected leaf 4 to match")
+	}
+	if _, ok := tree.Leaf(5); ok {
+		t.Errorf("error: expected no leaf past the items")
+	}
+	if tree.LeafCount() != 5 {
+		t.Errorf("error: expected 5 leaves got %d", tree.LeafCount())
+	}
+}
+
+func TestCompactTreeErrors(t *testing.T) {
+	if _, err := NewCompactTree(nil); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+	if _, err := NewCompactTree([]Storable{TestSHA256Content{x: "a"}, TestFailingContent{}}); err == nil {
+		t.Errorf("error: expected a content error")
+	}
+
+	tree, _ := NewCompactTree(paddingContents(3))
+	if _, err := tree.MerklePathAt(3); err == nil {
+		t.Errorf("error: expected out of range error")
+	}
+	path, _ := tree.MerklePathAt(0)
+	if err := tree.VerifyMerklePath(TestSHA256Content{x: "forged"}, path); err != ErrInvalidProof {
+		t.Errorf("error: expected ErrInvalidProof got %v", err)
+	}
+}
+
+const benchmarkCompactLeaves = 5000000
+
+// benchmarkTreeBuild builds a tree of benchmarkCompactLeaves leaves per
+// iteration and reports the heap it keeps alive and the GC pause it causes.
+func benchmarkTreeBuild(b *testing.B, build func([]Storable) (interface{}, error)) {
+	contents := syntheticContents(benchmarkCompactLeaves)
+
+	var tree interface{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		tree, err = build(contents)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	b.ReportMetric(float64(after.HeapAlloc), "heap-bytes")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs), "gc-pause-ns")
+	runtime.KeepAlive(tree)
+}
+
+func BenchmarkCompactTree(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		build func([]Storable) (interface{}, error)
+	}{
+		{"pointer", func(c []Storable) (interface{}, error) { return NewTree(c) }},
+		{"compact", func(c []Storable) (interface{}, error) { return NewCompactTree(c) }},
+	} {
+		b.Run(fmt.Sprintf("%s-%d", bc.name, benchmarkCompactLeaves), func(b *testing.B) {
+			benchmarkTreeBuild(b, bc.build)
+		})
+	}
+}
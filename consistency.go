@@ -0,0 +1,107 @@
+package merklego
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// VerifyConsistency checks that proof, as LogTree.ConsistencyProof returns
+// it, shows the tree of oldSize leaves with root oldRoot to be a prefix of the
+// tree of newSize leaves with root newRoot, following the consistency proofs of RFC 6962 and the verification
+// algorithm of RFC 9162, section 2.1.4.2. Trees are shaped as in RFC 6962,
+// splitting n leaves after the largest power of two below n, and internal
+// nodes are hashed as in a FlatMerkleTree, SHA-256 over a 0x01 prefix and
+// both children.
+//
+// An empty tree is consistent with any tree, and a tree with itself, both
+// with an empty proof. Every proof of the wrong length for the sizes is
+// rejected before any hashing. Errors wrap ErrInvalidProof.
+func VerifyConsistency(oldRoot, newRoot []byte, oldSize, newSize int, proof []TreeNode) error {
+	if oldSize < 0 || oldSize > newSize {
+		return fmt.Errorf("invalid consistency proof: tree size %d is not a prefix of %d: %w", oldSize, newSize, ErrInvalidProof)
+	}
+
+	want := consistencyProofSize(uint64(oldSize), uint64(newSize))
+	if len(proof) != want {
+		return fmt.Errorf("invalid consistency proof: got %d nodes, want %d for sizes %d and %d: %w", len(proof), want, oldSize, newSize, ErrInvalidProof)
+	}
+
+	switch {
+	case oldSize == 0:
+		return nil
+	case oldSize == newSize:
+		if !RootEqual(oldRoot, newRoot) {
+			return fmt.Errorf("invalid consistency proof: roots of the same size differ: %w", ErrInvalidProof)
+		}
+		return nil
+	}
+
+	// The root of a power of two sized tree is a node of the new tree, which
+	// the proof leaves to the verifier.
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([]TreeNode{oldRoot}, proof...)
+	}
+
+	fn, sn := uint64(oldSize-1), uint64(newSize-1)
+	for fn&1 == 1 {
+		fn, sn = fn>>1, sn>>1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if fn&1 == 1 || fn == sn {
+			fr = hashChildrenRFC(c, fr)
+			sr = hashChildrenRFC(c, sr)
+			for fn != 0 && fn&1 == 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			sr = hashChildrenRFC(sr, c)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+
+	if !RootEqual(fr, oldRoot) {
+		return fmt.Errorf("invalid consistency proof: old root mismatch; got: %X, want: %X: %w", fr.Bytes(), oldRoot, ErrInvalidProof)
+	}
+	if !RootEqual(sr, newRoot) {
+		return fmt.Errorf("invalid consistency proof: new root mismatch; got: %X, want: %X: %w", sr.Bytes(), newRoot, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// consistencyProofSize returns the number of nodes of the consistency proof
+// between trees of m and n leaves, 0 < m <= n: one per level the verification
+// walks up from the boundary of the old tree, the old root excepted when m is
+// a power of two.
+func consistencyProofSize(m, n uint64) int {
+	if m == 0 || m == n {
+		return 0
+	}
+
+	// Shift both sizes down to the level of the largest complete subtree
+	// ending at the boundary; the old tree is a single node there.
+	level := uint(bits.TrailingZeros64(m))
+	index, last := (m-1)>>level, (n-1)>>level
+
+	size := inclusionProofSize(index, last+1)
+	if index != 0 {
+		// The old root is not a node of the new tree: the proof starts with
+		// the largest complete subtree ending at the boundary.
+		size++
+	}
+
+	return size
+}
+
+// inclusionProofSize returns the number of nodes of the inclusion proof of
+// the leaf at index in a tree of size leaves shaped as in RFC 6962: the
+// siblings of its path up to where it meets the path of the last leaf, then
+// the left siblings above that point.
+func inclusionProofSize(index, size uint64) int {
+	inner := bits.Len64(index ^ (size - 1))
+	border := bits.OnesCount64(index >> uint(inner))
+
+	return inner + border
+}
@@ -0,0 +1,175 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcLeaves returns the leaf hashes of n leaves.
+func rfcLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = hashNode([]byte(fmt.Sprintf("leaf-%d", i)), false)
+	}
+
+	return leaves
+}
+
+// rfcSplit returns the largest power of two smaller than n.
+func rfcSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// rfcRoot is MTH of RFC 6962, section 2.1, over leaf hashes.
+func rfcRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := rfcSplit(len(leaves))
+	return hashNode(append(append([]byte(nil), rfcRoot(leaves[:k])...), rfcRoot(leaves[k:])...), true)
+}
+
+// rfcSubproof is SUBPROOF of RFC 6962, section 2.1.2.
+func rfcSubproof(m int, leaves [][]byte, complete bool) []TreeNode {
+	n := len(leaves)
+	if m == n {
+		if complete {
+			return nil
+		}
+		return []TreeNode{rfcRoot(leaves)}
+	}
+
+	k := rfcSplit(n)
+	if m <= k {
+		return append(rfcSubproof(m, leaves[:k], complete), rfcRoot(leaves[k:]))
+	}
+
+	return append(rfcSubproof(m-k, leaves[k:], false), rfcRoot(leaves[:k]))
+}
+
+func TestVerifyConsistencyMatrix(t *testing.T) {
+	leaves := rfcLeaves(70)
+	for n := 1; n <= len(leaves); n++ {
+		newRoot := rfcRoot(leaves[:n])
+		for m := 0; m <= n; m++ {
+			msg := fmt.Sprintf("sizes: %d, %d", m, n)
+			oldRoot := rfcRoot(leaves[:m])
+
+			var proof []TreeNode
+			if m > 0 && m < n {
+				proof = rfcSubproof(m, leaves[:n], true)
+			}
+			require.NoError(t, VerifyConsistency(oldRoot, newRoot, m, n, proof), msg)
+
+			if m == 0 {
+				continue
+			}
+
+			for i := range proof {
+				tampered := append([]TreeNode(nil), proof...)
+				tampered[i] = append(TreeNode(nil), proof[i]...)
+				tampered[i][0] ^= 1
+				require.True(t, errors.Is(VerifyConsistency(oldRoot, newRoot, m, n, tampered), ErrInvalidProof), msg)
+			}
+
+			require.Error(t, VerifyConsistency(oldRoot, newRoot, m, n, append(proof, newRoot)), msg)
+			if len(proof) > 0 {
+				require.Error(t, VerifyConsistency(oldRoot, newRoot, m, n, proof[:len(proof)-1]), msg)
+				require.Error(t, VerifyConsistency(newRoot, oldRoot, m, n, proof), msg)
+			}
+			if m < n {
+				require.Error(t, VerifyConsistency(oldRoot, newRoot, m+1, n, proof), msg)
+				require.Error(t, VerifyConsistency(oldRoot, rfcRoot(leaves[:n-1]), m, n, proof), msg)
+			}
+			if m > 1 {
+				require.Error(t, VerifyConsistency(rfcRoot(leaves[:m-1]), newRoot, m, n, proof), msg)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyDegenerate(t *testing.T) {
+	leaves := rfcLeaves(8)
+	root := rfcRoot(leaves)
+
+	// The empty tree is a prefix of any tree, whatever its root.
+	require.NoError(t, VerifyConsistency(nil, root, 0, 8, nil))
+	require.Error(t, VerifyConsistency(nil, root, 0, 8, []TreeNode{root}))
+
+	require.NoError(t, VerifyConsistency(root, root, 8, 8, nil))
+	require.Error(t, VerifyConsistency(root, rfcRoot(leaves[:7]), 8, 8, nil))
+	require.Error(t, VerifyConsistency(root, root, 8, 8, []TreeNode{root}))
+
+	// The root of a power of two sized tree is not part of its proof.
+	old := rfcRoot(leaves[:4])
+	require.NoError(t, VerifyConsistency(old, root, 4, 8, []TreeNode{rfcRoot(leaves[4:])}))
+	require.Error(t, VerifyConsistency(old, root, 4, 8, []TreeNode{old, rfcRoot(leaves[4:])}))
+
+	require.Error(t, VerifyConsistency(root, root, 8, 4, nil))
+	require.Error(t, VerifyConsistency(root, root, -1, 4, nil))
+	require.Error(t, VerifyConsistency(root, root, -2, -1, nil))
+}
+
+func TestVerifyConsistencyRFC6962(t *testing.T) {
+	// The trees of RFC 6962, section 2.1.3, with the test leaves of the
+	// certificate transparency reference implementations.
+	inputs := []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	leaves := make([][]byte, 7)
+	for i := range leaves {
+		data, _ := hex.DecodeString(inputs[i])
+		leaves[i] = hashNode(data, false)
+	}
+
+	roots := map[int]string{
+		3: "aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		4: "d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		6: "76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		7: "ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	}
+	for size, want := range roots {
+		require.Equal(t, want, hex.EncodeToString(rfcRoot(leaves[:size])), fmt.Sprintf("size: %d", size))
+	}
+
+	for _, sizes := range [][2]int{{1, 7}, {3, 7}, {4, 7}, {6, 7}} {
+		proof := rfcSubproof(sizes[0], leaves, true)
+		require.NoError(t, VerifyConsistency(rfcRoot(leaves[:sizes[0]]), rfcRoot(leaves), sizes[0], sizes[1], proof))
+	}
+}
+
+func FuzzVerifyConsistency(f *testing.F) {
+	leaves := rfcLeaves(16)
+	f.Add(3, 7, []byte{})
+	f.Add(4, 16, rfcRoot(leaves[4:8]))
+	f.Add(0, 0, []byte{})
+	f.Add(-5, 1<<62, []byte{1, 2, 3})
+
+	f.Fuzz(func(t *testing.T, oldSize, newSize int, data []byte) {
+		// Split data into a proof of nodes of up to 32 bytes.
+		var proof []TreeNode
+		for len(data) > 0 && len(proof) < 200 {
+			n := int(data[0])%32 + 1
+			if n > len(data) {
+				n = len(data)
+			}
+			proof = append(proof, data[:n])
+			data = data[n:]
+		}
+
+		_ = VerifyConsistency(rfcRoot(leaves[:8]), rfcRoot(leaves), oldSize, newSize, proof)
+	})
+}
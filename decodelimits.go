@@ -0,0 +1,203 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Default limits of a zero DecodeOptions.
+const (
+	// DefaultMaxInputSize is the largest encoding decoded, in bytes.
+	DefaultMaxInputSize = 32 << 20
+	// DefaultMaxProofSteps is the most siblings a decoded proof may have,
+	// enough for a tree of 2^64 leaves.
+	DefaultMaxProofSteps = maxProofSteps
+	// DefaultMaxTreeSize is the most leaves a decoded proof may claim or a
+	// decoded tree may have.
+	DefaultMaxTreeSize = 1 << 30
+	// DefaultMaxHashSize is the longest hash decoded, in bytes, the size of a
+	// SHA-512 digest.
+	DefaultMaxHashSize = 64
+)
+
+// ErrDecodeLimit is matched by every *DecodeLimitError.
+var ErrDecodeLimit = errors.New("error: decoding limit exceeded")
+
+// DecodeLimitError is returned by decoders for input exceeding one of the
+// limits of DecodeOptions. It is raised as soon as the offending length is
+// read, before anything proportional to it is allocated. It matches
+// ErrDecodeLimit and unwraps to the invalid encoding error of the decoder.
+type DecodeLimitError struct {
+	// Limit names the DecodeOptions field exceeded.
+	Limit string
+	Value uint64
+	Max   int
+	Err   error
+}
+
+func (e *DecodeLimitError) Error() string {
+	return fmt.Sprintf("%v: %s %d exceeds %d", e.Err, e.Limit, e.Value, e.Max)
+}
+
+// Is reports whether target is ErrDecodeLimit.
+func (e *DecodeLimitError) Is(target error) bool {
+	return target == ErrDecodeLimit
+}
+
+// Unwrap returns the invalid encoding error of the decoder.
+func (e *DecodeLimitError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeOptions bounds what decoders accept from untrusted input. A zero
+// field takes its default, so the zero DecodeOptions applies the defaults,
+// which are also the limits of UnmarshalBinary, UnmarshalJSON and
+// DecodeProofString.
+type DecodeOptions struct {
+	// MaxInputSize bounds the length of the encoding.
+	MaxInputSize int
+	// MaxProofSteps bounds the number of siblings of a proof.
+	MaxProofSteps int
+	// MaxTreeSize bounds the number of leaves of a proof or a tree.
+	MaxTreeSize int
+	// MaxHashSize bounds the length of every hash.
+	MaxHashSize int
+}
+
+// WithDefaults returns o with its zero fields set to their defaults.
+func (o DecodeOptions) WithDefaults() DecodeOptions {
+	if o.MaxInputSize <= 0 {
+		o.MaxInputSize = DefaultMaxInputSize
+	}
+	if o.MaxProofSteps <= 0 {
+		o.MaxProofSteps = DefaultMaxProofSteps
+	}
+	if o.MaxTreeSize <= 0 {
+		o.MaxTreeSize = DefaultMaxTreeSize
+	}
+	if o.MaxHashSize <= 0 {
+		o.MaxHashSize = DefaultMaxHashSize
+	}
+
+	return o
+}
+
+// Check returns a *DecodeLimitError wrapping err if value exceeds max, the
+// limit named limit.
+func (o DecodeOptions) Check(limit string, value uint64, max int, err error) error {
+	if value > uint64(max) {
+		return &DecodeLimitError{Limit: limit, Value: value, Max: max, Err: err}
+	}
+
+	return nil
+}
+
+// UnmarshalProof decodes a proof encoded by MarshalBinary within the limits
+// of o.
+func (o DecodeOptions) UnmarshalProof(data []byte) (*Proof, error) {
+	p := &Proof{}
+	if err := p.unmarshalBinary(data, o.WithDefaults()); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// DecodeProofString decodes a proof encoded by EncodeString within the limits
+// of o, returning the same errors as the DecodeProofString function.
+func (o DecodeOptions) DecodeProofString(s string) (*Proof, error) {
+	o = o.WithDefaults()
+	if err := o.Check("MaxInputSize", uint64(len(s)), o.MaxInputSize, ErrInvalidProofEncoding); err != nil {
+		return nil, &CorruptProofError{Err: err}
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &CorruptProofError{Err: err}
+	}
+
+	p := &Proof{}
+	if err := p.decodeBinary(data, o); err != nil {
+		return nil, &CorruptProofError{Err: err}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// UnmarshalProofJSON decodes the JSON encoding of a proof within the limits of
+// o, rejecting unknown fields.
+func (o DecodeOptions) UnmarshalProofJSON(data []byte) (*Proof, error) {
+	p := &Proof{}
+	if err := p.unmarshalJSON(data, o.WithDefaults()); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// UnmarshalTreeJSON decodes into m a tree encoded by MerkleTree.MarshalJSON
+// within the limits of o.
+func (o DecodeOptions) UnmarshalTreeJSON(data []byte, m *MerkleTree) error {
+	return m.unmarshalJSON(data, o.WithDefaults())
+}
+
+// jsonProof has the fields of Proof without its methods.
+type jsonProof Proof
+
+// UnmarshalJSON decodes the JSON encoding of a proof, as produced by
+// json.Marshal, within the default DecodeOptions. Unknown fields are
+// rejected, as are proofs Validate refuses, and proofs naming a hash function
+// that is not registered are refused with an UnknownHashError.
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	return p.unmarshalJSON(data, DecodeOptions{}.WithDefaults())
+}
+
+func (p *Proof) unmarshalJSON(data []byte, o DecodeOptions) error {
+	if err := o.Check("MaxInputSize", uint64(len(data)), o.MaxInputSize, ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+
+	var decoded jsonProof
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&decoded); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProofEncoding, err)
+	}
+
+	if decoded.Size < 0 || decoded.Index < 0 {
+		return fmt.Errorf("%w: index %d out of %d leaves", ErrInvalidProofEncoding, decoded.Index, decoded.Size)
+	}
+
+	err := o.Check("MaxProofSteps", uint64(len(decoded.Steps)), o.MaxProofSteps, ErrInvalidProofEncoding)
+	if err == nil {
+		err = o.Check("MaxTreeSize", uint64(decoded.Size), o.MaxTreeSize, ErrInvalidProofEncoding)
+	}
+	if err == nil {
+		err = o.Check("MaxHashSize", uint64(len(decoded.LeafHash)), o.MaxHashSize, ErrInvalidProofEncoding)
+	}
+	for i := 0; err == nil && i < len(decoded.Steps); i++ {
+		err = o.Check("MaxHashSize", uint64(len(decoded.Steps[i].Hash)), o.MaxHashSize, ErrInvalidProofEncoding)
+	}
+	if err == nil {
+		err = checkHashName(decoded.Hash, ErrInvalidProofEncoding)
+	}
+	if err != nil {
+		return err
+	}
+
+	// An empty prefix is the default one, which json.Marshal leaves out.
+	decoded.LeafPrefix, decoded.NodePrefix = clonePrefix(decoded.LeafPrefix), clonePrefix(decoded.NodePrefix)
+	if reason := (*Proof)(&decoded).invalid(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidProofEncoding, reason)
+	}
+	*p = Proof(decoded)
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// limitProof returns the proof of the last leaf of a SHA-256 tree of n leaves
+// and its binary encoding.
+func limitProof(t *testing.T, n int) (*Proof, []byte) {
+	tree, err := NewTree(paddingContents(n))
+	require.NoError(t, err)
+	p, err := tree.Prove(tree.Leaves[n-1].Hash)
+	require.NoError(t, err)
+	data, err := p.MarshalBinary()
+	require.NoError(t, err)
+
+	return p, data
+}
+
+func requireLimit(t *testing.T, err error, limit string, encoding error) {
+	t.Helper()
+
+	var target *DecodeLimitError
+	require.ErrorAs(t, err, &target)
+	require.Equal(t, limit, target.Limit)
+	require.ErrorIs(t, err, ErrDecodeLimit)
+	require.ErrorIs(t, err, encoding)
+}
+
+func TestDecodeOptionsDefaults(t *testing.T) {
+	o := DecodeOptions{}.WithDefaults()
+	require.Equal(t, DecodeOptions{
+		MaxInputSize:  DefaultMaxInputSize,
+		MaxProofSteps: DefaultMaxProofSteps,
+		MaxTreeSize:   DefaultMaxTreeSize,
+		MaxHashSize:   DefaultMaxHashSize,
+	}, o)
+
+	set := DecodeOptions{MaxInputSize: 1, MaxProofSteps: 2, MaxTreeSize: 3, MaxHashSize: 4}
+	require.Equal(t, set, set.WithDefaults())
+}
+
+func TestDecodeLimitHugeStepCount(t *testing.T) {
+	// A proof claiming 2^40 siblings in a few bytes.
+	data := append([]byte{proofVersion, 32, 0, 6}, "sha256"...)
+	data = append(data, 0, 1)
+	var varint [binary.MaxVarintLen64]byte
+	data = append(data, varint[:binary.PutUvarint(varint[:], 1<<40)]...)
+
+	var p Proof
+	requireLimit(t, p.UnmarshalBinary(data), "MaxProofSteps", ErrInvalidProofEncoding)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = p.UnmarshalBinary(data)
+	})
+	require.LessOrEqual(t, allocs, 2.0)
+}
+
+func TestDecodeLimitsBinary(t *testing.T) {
+	_, data := limitProof(t, 9)
+
+	cases := map[string]DecodeOptions{
+		"MaxInputSize":  {MaxInputSize: len(data) - 1},
+		"MaxProofSteps": {MaxProofSteps: 3},
+		"MaxTreeSize":   {MaxTreeSize: 8},
+		"MaxHashSize":   {MaxHashSize: 31},
+	}
+	for limit, o := range cases {
+		_, err := o.UnmarshalProof(data)
+		requireLimit(t, err, limit, ErrInvalidProofEncoding)
+	}
+
+	p, err := DecodeOptions{MaxInputSize: len(data), MaxProofSteps: 4, MaxTreeSize: 9, MaxHashSize: 32}.UnmarshalProof(data)
+	require.NoError(t, err)
+	encoded, err := p.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, data, encoded)
+}
+
+func TestDecodeLimitsProofString(t *testing.T) {
+	p, _ := limitProof(t, 9)
+	s, err := p.EncodeString()
+	require.NoError(t, err)
+
+	_, err = DecodeOptions{MaxProofSteps: 3}.DecodeProofString(s)
+	var corrupt *CorruptProofError
+	require.ErrorAs(t, err, &corrupt)
+	requireLimit(t, err, "MaxProofSteps", ErrInvalidProofEncoding)
+
+	_, err = DecodeOptions{MaxInputSize: len(s) - 1}.DecodeProofString(s)
+	requireLimit(t, err, "MaxInputSize", ErrInvalidProofEncoding)
+
+	decoded, err := DecodeOptions{}.DecodeProofString(s)
+	require.NoError(t, err)
+	require.Equal(t, p, decoded)
+}
+
+func TestDecodeLimitsProofJSON(t *testing.T) {
+	p, _ := limitProof(t, 9)
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	cases := map[string]DecodeOptions{
+		"MaxInputSize":  {MaxInputSize: len(data) - 1},
+		"MaxProofSteps": {MaxProofSteps: 3},
+		"MaxTreeSize":   {MaxTreeSize: 8},
+		"MaxHashSize":   {MaxHashSize: 31},
+	}
+	for limit, o := range cases {
+		_, err := o.UnmarshalProofJSON(data)
+		requireLimit(t, err, limit, ErrInvalidProofEncoding)
+	}
+
+	var decoded Proof
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, p, &decoded)
+
+	for name, bad := range map[string]string{
+		"unknown field":  `{"Hash":"sha256","Extra":1}`,
+		"negative size":  `{"Hash":"sha256","Size":-1}`,
+		"negative index": `{"Hash":"sha256","Size":1,"Index":-1}`,
+		"not an object":  `[1,2]`,
+	} {
+		err := json.Unmarshal([]byte(bad), &decoded)
+		require.ErrorIs(t, err, ErrInvalidProofEncoding, name)
+	}
+}
+
+func TestDecodeLimitsTreeJSON(t *testing.T) {
+	tree, err := NewTree(paddingContents(9))
+	require.NoError(t, err)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	cases := map[string]DecodeOptions{
+		"MaxInputSize": {MaxInputSize: len(data) - 1},
+		"MaxTreeSize":  {MaxTreeSize: 8},
+		"MaxHashSize":  {MaxHashSize: 31},
+	}
+	for limit, o := range cases {
+		err := o.UnmarshalTreeJSON(data, &MerkleTree{})
+		requireLimit(t, err, limit, ErrInvalidTreeJSON)
+	}
+
+	var decoded MerkleTree
+	require.NoError(t, DecodeOptions{MaxTreeSize: 9, MaxHashSize: 32}.UnmarshalTreeJSON(data, &decoded))
+	want, _ := tree.RootHash()
+	got, err := decoded.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestDecodeLimitsTreeHead(t *testing.T) {
+	h := TreeHead{Size: 3, Root: make([]byte, DefaultMaxHashSize+1)}
+	data, err := h.MarshalBinary()
+	require.NoError(t, err)
+	requireLimit(t, (&TreeHead{}).UnmarshalBinary(data), "MaxHashSize", ErrInvalidTreeHead)
+
+	js, err := h.MarshalJSON()
+	require.NoError(t, err)
+	requireLimit(t, (&TreeHead{}).UnmarshalJSON(js), "MaxHashSize", ErrInvalidTreeHead)
+}
+
+func TestReadProofsRecordLimit(t *testing.T) {
+	record := `{"Hash":"` + strings.Repeat("a", maxProofRecordSize) + `"}` + "\n"
+	err := ReadProofs(strings.NewReader(record), ProofJSON, func(*Proof) error { return nil })
+	require.Error(t, err)
+
+	var varint [binary.MaxVarintLen64]byte
+	huge := varint[:binary.PutUvarint(varint[:], 1<<40)]
+	err = ReadProofs(bytes.NewReader(huge), ProofBinary, func(*Proof) error { return nil })
+	require.ErrorIs(t, err, ErrInvalidProofEncoding)
+}
+
+func FuzzProofUnmarshalJSON(f *testing.F) {
+	for n := 1; n <= 5; n++ {
+		tree, _ := NewTreeWithOptions(paddingContents(n), WithSortedPairs(n%2 == 0))
+		p, _ := tree.Prove(tree.Leaves[n-1].Hash)
+		data, _ := json.Marshal(p)
+		f.Add(data)
+	}
+	f.Add([]byte(`{"Steps":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p Proof
+		if err := p.UnmarshalJSON(data); err != nil {
+			require.ErrorIs(t, err, ErrInvalidProofEncoding)
+			return
+		}
+
+		require.LessOrEqual(t, len(p.Steps), DefaultMaxProofSteps)
+		require.LessOrEqual(t, len(p.LeafHash), DefaultMaxHashSize)
+		encoded, err := json.Marshal(&p)
+		require.NoError(t, err)
+		var again Proof
+		require.NoError(t, again.UnmarshalJSON(encoded))
+		require.Equal(t, p, again)
+	})
+}
+
+func FuzzTreeHeadUnmarshal(f *testing.F) {
+	for _, name := range []string{"", "sha256"} {
+		h := TreeHead{Size: 5, Root: bytes.Repeat([]byte{7}, 32), Hash: name}
+		data, _ := h.MarshalBinary()
+		js, _ := h.MarshalJSON()
+		f.Add(data)
+		f.Add(js)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var h TreeHead
+		if err := h.UnmarshalBinary(data); err == nil {
+			encoded, err := h.MarshalBinary()
+			require.NoError(t, err)
+			require.Equal(t, data, encoded)
+		} else {
+			require.ErrorIs(t, err, ErrInvalidTreeHead)
+		}
+
+		if err := h.UnmarshalJSON(data); err != nil {
+			require.ErrorIs(t, err, ErrInvalidTreeHead)
+			return
+		}
+		require.LessOrEqual(t, len(h.Root), DefaultMaxHashSize)
+	})
+}
+
+func FuzzReadProofs(f *testing.F) {
+	mt := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	if err := mt.Finalize(); err != nil {
+		f.Fatal(err)
+	}
+	for _, format := range []ProofFormat{ProofJSON, ProofBinary, ProofBase64} {
+		var buf bytes.Buffer
+		if err := mt.ExportProofs(&buf, format); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(uint8(format), buf.Bytes())
+	}
+
+	stop := errors.New("stop")
+	f.Fuzz(func(t *testing.T, format uint8, data []byte) {
+		read := 0
+		err := ReadProofs(bytes.NewReader(data), ProofFormat(format%3), func(p *Proof) error {
+			require.NoError(t, p.Validate())
+			if read++; read > len(data) {
+				return stop
+			}
+			return nil
+		})
+		require.NotErrorIs(t, err, stop)
+	})
+}
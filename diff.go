@@ -0,0 +1,100 @@
+package merklego
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Diff returns the indexes of the blocks on which the finalized trees a and b
+// differ, in increasing order: those whose leaf hashes differ, and those only
+// one of them holds when their sizes differ. Both trees must be binary and
+// hash their leaves the same way, or every block differs.
+//
+// Diff descends both trees at once from their roots, skipping the subtrees
+// whose roots match, so trees of the same size differing on d blocks are
+// compared in O(d log n). The nodes of a tree are laid out from its size, and
+// trees of different sizes only share the subtrees covering the same blocks
+// in both: the others are descended down to their leaves, which is O(n) at
+// worst.
+func Diff(a, b *FlatMerkleTree) ([]int, error) {
+	for _, mt := range []*FlatMerkleTree{a, b} {
+		if !mt.finalized {
+			return nil, ErrTreeNotFinalized
+		}
+		if err := mt.checkBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	var diff []int
+	a.diffNode(b, 0, &diff)
+	for i := a.count; i < b.count; i++ {
+		diff = append(diff, i)
+	}
+	sort.Ints(diff)
+
+	return diff, nil
+}
+
+// diffNode appends to diff the indexes of the blocks under the node at pos
+// that b does not hold with the same leaf hash.
+func (mt *FlatMerkleTree) diffNode(b *FlatMerkleTree, pos int, diff *[]int) {
+	if other := mt.counterpart(b, pos); other >= 0 && bytes.Equal(mt.nodes[pos], b.nodes[other]) {
+		return
+	}
+
+	first := len(mt.nodes) - len(mt.blocks)
+	if pos >= first {
+		if i := pos - first; i < mt.count {
+			*diff = append(*diff, i)
+		}
+		return
+	}
+
+	mt.diffNode(b, 2*pos+1, diff)
+	mt.diffNode(b, 2*pos+2, diff)
+}
+
+// counterpart returns the position of the node of b over the same blocks as
+// the node of the tree at pos, -1 if b has none. Nodes over a padding leaf
+// only have one in a tree of the same size.
+func (mt *FlatMerkleTree) counterpart(b *FlatMerkleTree, pos int) int {
+	if mt.count == b.count {
+		return pos
+	}
+
+	lo, hi, ok := mt.span(pos)
+	if !ok || hi >= mt.count || hi >= b.count {
+		return -1
+	}
+
+	other := len(b.nodes) - len(b.blocks) + lo
+	for width := hi - lo + 1; width > 1; width /= 2 {
+		if other == 0 || width%2 != 0 {
+			return -1
+		}
+		other = (other - 1) / 2
+	}
+	if blo, bhi, ok := b.span(other); !ok || blo != lo || bhi != hi {
+		return -1
+	}
+
+	return other
+}
+
+// span returns the indexes of the first and last blocks under the node at
+// pos, padding included. The blocks under a node are consecutive unless it is
+// the root or an ancestor of both the first leaf one level above the others
+// and the last leaf, which have no span.
+func (mt *FlatMerkleTree) span(pos int) (lo, hi int, ok bool) {
+	first := len(mt.nodes) - len(mt.blocks)
+	left, right := pos, pos
+	for left < first {
+		left = 2*left + 1
+	}
+	for right < first {
+		right = 2*right + 2
+	}
+
+	return left - first, right - first, left <= right
+}
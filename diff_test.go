@@ -0,0 +1,105 @@
+package merklego
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// diffTree returns a finalized tree over n blocks, the ones at the indexes of
+// changed being altered.
+func diffTree(t *testing.T, n int, changed ...int) *FlatMerkleTree {
+	blocks := make([]Block, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block %d", i))
+	}
+	for _, i := range changed {
+		blocks[i] = Block(fmt.Sprintf("changed block %d", i))
+	}
+
+	tree := NewMerkleTree(blocks...)
+	require.NoError(t, tree.Finalize())
+
+	return tree
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    *FlatMerkleTree
+		changed []int
+	}{
+		{"identical", diffTree(t, 100), diffTree(t, 100), nil},
+		{"first", diffTree(t, 100), diffTree(t, 100, 0), []int{0}},
+		{"last", diffTree(t, 100), diffTree(t, 100, 99), []int{99}},
+		{"last of odd", diffTree(t, 101), diffTree(t, 101, 100), []int{100}},
+		{"middle", diffTree(t, 100), diffTree(t, 100, 50), []int{50}},
+		{"several", diffTree(t, 77, 3), diffTree(t, 77, 0, 41, 76), []int{0, 3, 41, 76}},
+		{"single block", diffTree(t, 1), diffTree(t, 1, 0), []int{0}},
+		{"appended", diffTree(t, 90), diffTree(t, 100), []int{90, 91, 92, 93, 94, 95, 96, 97, 98, 99}},
+		{"truncated", diffTree(t, 13), diffTree(t, 10), []int{10, 11, 12}},
+		{"resized and changed", diffTree(t, 64, 7), diffTree(t, 66, 60), []int{7, 60, 64, 65}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := Diff(tt.a, tt.b)
+			require.NoError(t, err)
+			require.Equal(t, tt.changed, diff)
+
+			diff, err = Diff(tt.b, tt.a)
+			require.NoError(t, err)
+			require.Equal(t, tt.changed, diff)
+		})
+	}
+}
+
+func TestDiffRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for k := 0; k < 300; k++ {
+		na, nb := 1+rng.Intn(70), 1+rng.Intn(70)
+		var changedA, changedB []int
+		for i := 0; i < na; i++ {
+			if rng.Intn(10) == 0 {
+				changedA = append(changedA, i)
+			}
+		}
+		for i := 0; i < nb; i++ {
+			if rng.Intn(10) == 0 {
+				changedB = append(changedB, i)
+			}
+		}
+		a, b := diffTree(t, na, changedA...), diffTree(t, nb, changedB...)
+
+		var want []int
+		for i := 0; i < na || i < nb; i++ {
+			if i >= na || i >= nb || string(a.blocks[i]) != string(b.blocks[i]) {
+				want = append(want, i)
+			}
+		}
+		diff, err := Diff(a, b)
+		require.NoError(t, err)
+		require.Equal(t, want, diff, "%d and %d blocks", na, nb)
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	a, b := diffTree(t, 1<<12, 4095), diffTree(t, 1<<12+1, 1000, 2047)
+
+	diff, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Equal(t, []int{1000, 2047, 4095, 4096}, diff)
+}
+
+func TestDiffErrors(t *testing.T) {
+	finalized := diffTree(t, 4)
+	_, err := Diff(finalized, NewMerkleTree(Block("a")))
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+
+	kary := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	require.NoError(t, kary.Finalize(WithBranchingFactor(3)))
+	_, err = Diff(kary, finalized)
+	require.ErrorIs(t, err, ErrBranchingFactor)
+}
@@ -0,0 +1,115 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// digestHash adapts a one-shot digest function to hash.Hash, the interface
+// through which the trees hash, by buffering what is written until Sum.
+type digestHash struct {
+	digest func([]byte) []byte
+	size   int
+	buf    []byte
+}
+
+func (d *digestHash) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	return len(p), nil
+}
+
+func (d *digestHash) Sum(b []byte) []byte {
+	return append(b, d.digest(d.buf)...)
+}
+
+func (d *digestHash) Reset() {
+	d.buf = d.buf[:0]
+}
+
+func (d *digestHash) Size() int {
+	return d.size
+}
+
+func (d *digestHash) BlockSize() int {
+	return 1
+}
+
+// digestHashFunc returns a hash strategy hashing with digest, whose digests
+// are size bytes long.
+func digestHashFunc(digest func([]byte) []byte, size int) func() hash.Hash {
+	return func() hash.Hash {
+		return &digestHash{digest: digest, size: size}
+	}
+}
+
+// WithDigestFunc hashes the nodes of a tree with digest, whose digests must be
+// size bytes long, for hash functions only exposed as a function of the whole
+// input, such as Poseidon. It is the counterpart of WithHashStrategy, which it
+// replaces, and the hashes of the tree and of its proofs are size bytes long.
+func WithDigestFunc(digest func([]byte) []byte, size int) Option {
+	return WithHashStrategy(digestHashFunc(digest, size))
+}
+
+// RegisterDigestFunc makes digest, whose digests are size bytes long, known
+// under name as RegisterHash does for a hash strategy.
+func RegisterDigestFunc(name string, digest func([]byte) []byte, size int) {
+	RegisterHash(name, digestHashFunc(digest, size))
+}
+
+// newHash returns a hasher of the hash function set with WithHashStrategy or
+// WithDigestFunc, SHA-256 by default, keyed with the key set with
+// WithHMACKey and truncated to the size set with WithDigestSize.
+func (c *config) newHash() hash.Hash {
+	hashFunc := c.hashFunc
+	if hashFunc == nil {
+		hashFunc = sha256.New
+	}
+
+	return c.hashWith(hashFunc)()
+}
+
+// hashWith returns hashFunc keyed and truncated as the tree hashes.
+func (c *config) hashWith(hashFunc func() hash.Hash) func() hash.Hash {
+	return truncatedHashFunc(hmacHashFunc(hashFunc, c.hmacKey), c.truncatedSize())
+}
+
+// hashID names the hash function of a flat tree in its snapshots and in its
+// proofs other than Proof: empty for the default SHA-256, the name it was
+// registered under otherwise, and "?" if it was not.
+func (mt *FlatMerkleTree) hashID() string {
+	if mt.hashFunc == nil {
+		return ""
+	}
+	if name := hashName(mt.hashFunc); name != "" {
+		return name
+	}
+
+	return unknownPairHash
+}
+
+// hashProofName names the hash function of a flat tree in its Proof and
+// SignedRoot values as a MerkleTree does, and of the append-only trees in
+// their heads: "sha256" by default, the name it was registered under
+// otherwise, and "" if it was not.
+func (c *config) hashProofName() string {
+	if c.hashFunc == nil {
+		return "sha256"
+	}
+
+	return hashName(c.hashFunc)
+}
+
+// hashOption returns the option setting the hash function named name by
+// hashID, or an UnknownHashError if it is unknown.
+func hashOption(name string) (Option, error) {
+	if name == "" {
+		return func(*config) {}, nil
+	}
+
+	hashStrategy, err := LookupHash(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithHashStrategy(hashStrategy), nil
+}
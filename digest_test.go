@@ -0,0 +1,152 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubDigest is a 20-byte digest, to catch hashes assumed to be 32 bytes long.
+func stubDigest(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:20]
+}
+
+// otherStubDigest is a 20-byte digest that is never registered.
+func otherStubDigest(data []byte) []byte {
+	sum := sha256.Sum256(append([]byte("other"), data...))
+	return sum[:20]
+}
+
+func TestDigestHash(t *testing.T) {
+	h := digestHashFunc(stubDigest, 20)()
+	require.Equal(t, 20, h.Size())
+
+	h.Write([]byte("ab"))
+	h.Write([]byte("c"))
+	require.Equal(t, stubDigest([]byte("abc")), h.Sum(nil))
+	require.Equal(t, append([]byte("x"), stubDigest([]byte("abc"))...), h.Sum([]byte("x")))
+
+	h.Reset()
+	h.Write([]byte("d"))
+	require.Equal(t, stubDigest([]byte("d")), h.Sum(nil))
+}
+
+func TestDigestFuncFlatTree(t *testing.T) {
+	blocks := []Block{Block("a"), Block("b"), Block("c"), Block("d"), Block("e")}
+
+	unregistered := NewMerkleTree(blocks...)
+	require.NoError(t, unregistered.Finalize(WithDigestFunc(otherStubDigest, 20)))
+	p, err := unregistered.Prove(blocks[0])
+	require.NoError(t, err)
+	require.Empty(t, p.Hash)
+	rp, err := unregistered.ProveRange(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, "?", rp.Hash)
+
+	RegisterDigestFunc("stub-20", stubDigest, 20)
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(WithDigestFunc(stubDigest, 20), WithDistinctPadding(true)))
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+	require.Len(t, root, 20)
+
+	for i, b := range blocks {
+		proof, err := mt.Proof(b)
+		require.NoError(t, err)
+		require.NoError(t, mt.Verify(b, proof))
+		require.NoError(t, VerifyFlatProof(root, b, i, len(blocks), proof, WithDigestFunc(stubDigest, 20), WithDistinctPadding(true)))
+		require.Error(t, VerifyFlatProof(root, b, i, len(blocks), proof, WithDistinctPadding(true)))
+
+		leaf := stubDigest(append([]byte{leafNodePrefix}, b...))
+		require.NoError(t, VerifyFlatLeafHash(root, leaf, i, len(blocks), proof, WithDigestFunc(stubDigest, 20), WithDistinctPadding(true)))
+		require.ErrorContains(t, VerifyFlatLeafHash(root, leaf, i, len(blocks), proof, WithDistinctPadding(true)), "want 32")
+
+		p, err := mt.Prove(b)
+		require.NoError(t, err)
+		require.Equal(t, "stub-20", p.Hash)
+		require.Equal(t, leaf, p.LeafHash)
+		require.NoError(t, p.Verify(root))
+
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, p, &decoded)
+	}
+
+	rp, err = mt.ProveRange(1, 4)
+	require.NoError(t, err)
+	require.Equal(t, "stub-20", rp.Hash)
+	require.NoError(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2], blocks[3]}, rp))
+	rp.Hash = ""
+	require.ErrorIs(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2], blocks[3]}, rp), ErrInvalidProof)
+	rp.Hash = "unregistered"
+	require.ErrorIs(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2], blocks[3]}, rp), ErrInvalidProof)
+
+	op, err := mt.ProveOrder(blocks[0], blocks[4])
+	require.NoError(t, err)
+	require.NoError(t, VerifyOrder(root, blocks[0], blocks[4], op))
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, "stub-20", s.Hash)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	got, err := restored.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, root, got)
+
+	sorted := NewSortedTree(blocks[:4]...)
+	require.NoError(t, sorted.Finalize(WithDigestFunc(stubDigest, 20)))
+	sortedRoot, err := sorted.RootHash()
+	require.NoError(t, err)
+	ap, err := sorted.ProveAbsence(Block("z"))
+	require.NoError(t, err)
+	require.NoError(t, VerifyAbsence(sortedRoot, Block("z"), ap))
+}
+
+func TestDigestFuncMerkleTree(t *testing.T) {
+	RegisterDigestFunc("stub-20", stubDigest, 20)
+	items := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	opts := []Option{WithDigestFunc(stubDigest, 20), WithDomainSeparation(true)}
+
+	tree, err := NewTreeFromBytes(items, opts...)
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Len(t, root, 20)
+	parsed, err := ParseRoot("0x"+hex.EncodeToString(root), opts...)
+	require.NoError(t, err)
+	require.Equal(t, root, parsed)
+
+	var known [][]byte
+	for i, l := range tree.RealLeaves() {
+		require.Len(t, l.Hash, 20)
+		p, err := tree.Prove(l.Hash)
+		require.NoError(t, err)
+		require.Equal(t, "stub-20", p.Hash)
+		require.NoError(t, tree.VerifyProof(l.Hash, p))
+		require.NoError(t, p.Verify(root))
+		require.NoError(t, VerifyHex("0x"+hex.EncodeToString(root), "0x"+hex.EncodeToString(l.Hash), p.HexSiblings(), i, opts...))
+
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, len(data), p.EncodedSize(ProofBinary))
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, p, &decoded)
+
+		c, err := p.Compress(known)
+		require.NoError(t, err)
+		data, err = c.MarshalBinary()
+		require.NoError(t, err)
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, c, &decoded)
+		for _, s := range p.Steps {
+			known = append(known, s.Hash)
+		}
+	}
+}
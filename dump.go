@@ -0,0 +1,72 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dump writes the tree to w level by level, starting with the root, with
+// every node on its own line holding its full hex hash. Each level is indented
+// one step deeper than its parent; leaves show their position in Leaves and
+// padding duplicates are marked dup.
+func (m *MerkleTree) Dump(w io.Writer) error {
+	var err error
+	m.WalkLevels(func(level int, nodes []*Node) bool {
+		indent := strings.Repeat("  ", level)
+		if _, err = fmt.Fprintf(w, "%slevel %d:\n", indent, level); err != nil {
+			return false
+		}
+
+		for _, n := range nodes {
+			line := indent + "  " + hex.EncodeToString(n.Hash)
+			if n.leaf {
+				line += fmt.Sprintf(" leaf %d", n.index)
+			}
+			if n.dup {
+				line += " dup"
+			}
+			if _, err = fmt.Fprintln(w, line); err != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// Dump writes the node array of a finalized tree to w grouped by level, with
+// every node on its own line holding its array index and full hex hash.
+// Nodes holding a block's leaf hash are marked leaf.
+func (mt *FlatMerkleTree) Dump(w io.Writer) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+
+	k := mt.branching()
+	firstLeaf := len(mt.nodes) - len(mt.blocks)
+	for level, lo := 0, 0; lo < len(mt.nodes); level, lo = level+1, k*lo+1 {
+		if _, err := fmt.Fprintf(w, "level %d:\n", level); err != nil {
+			return err
+		}
+
+		hi := k*lo + 1
+		if hi > len(mt.nodes) {
+			hi = len(mt.nodes)
+		}
+		for i := lo; i < hi; i++ {
+			line := fmt.Sprintf("  [%d] %s", i, hex.EncodeToString(mt.nodes[i]))
+			if i >= firstLeaf {
+				line += " leaf"
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
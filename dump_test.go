@@ -0,0 +1,82 @@
+package merklego
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// checkGolden compares got with the content of testdata/name, rewriting the
+// file instead when the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("error: cannot update %s: %v", path, err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error: cannot read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("error: %s mismatch\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestDump(t *testing.T) {
+	tree, err := NewTree(paddingContents(3))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tree.Dump(&buf); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	checkGolden(t, "dump.golden", buf.Bytes())
+}
+
+func TestFlatDump(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	if err := mt.Dump(&bytes.Buffer{}); err != ErrTreeNotFinalized {
+		t.Errorf("error: expected ErrTreeNotFinalized got %v", err)
+	}
+	if err := mt.Finalize(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := mt.Dump(&buf); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	checkGolden(t, "flat_dump.golden", buf.Bytes())
+}
+
+func TestNodeString(t *testing.T) {
+	tree, _ := NewTree(paddingContents(1))
+
+	leaf := tree.Leaves[0].String()
+	if !strings.HasSuffix(leaf, "... leaf {leaf-0}") || len(leaf) != len("0123456789abcdef... leaf {leaf-0}") {
+		t.Errorf("error: unexpected leaf string %q", leaf)
+	}
+	if dup := tree.Leaves[1].String(); !strings.Contains(dup, " leaf dup ") {
+		t.Errorf("error: unexpected duplicate string %q", dup)
+	}
+	if root := tree.Root.String(); strings.Contains(root, "leaf") || !strings.HasSuffix(root, "...") {
+		t.Errorf("error: unexpected root string %q", root)
+	}
+
+	tree.ReleaseItems()
+	if leaf := tree.Leaves[0].String(); !strings.HasSuffix(leaf, "... leaf") {
+		t.Errorf("error: unexpected released leaf string %q", leaf)
+	}
+}
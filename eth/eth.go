@@ -0,0 +1,76 @@
+// Package merkleeth builds merkle trees whose roots and proofs are accepted by
+// the usual Solidity verifiers, such as OpenZeppelin's MerkleProof: nodes are
+// hashed with keccak-256, sibling pairs are sorted and no domain separation
+// prefix is added. StandardTree reproduces OpenZeppelin's StandardMerkleTree.
+// It is a separate package so the core stays free of Ethereum conventions.
+package merkleeth
+
+import (
+	"hash"
+	"math/big"
+
+	merklego "github.com/evalir/merkle-go"
+	"golang.org/x/crypto/sha3"
+)
+
+func init() {
+	merklego.RegisterHash("keccak256", NewKeccak256)
+}
+
+// NewKeccak256 returns a keccak-256 hasher, the legacy Keccak hash of
+// Ethereum, which is not the standardized SHA3-256. It is registered as
+// "keccak256".
+func NewKeccak256() hash.Hash {
+	return sha3.NewLegacyKeccak256()
+}
+
+// Keccak256 returns the keccak-256 hash of the concatenation of data.
+func Keccak256(data ...[]byte) []byte {
+	h := NewKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// EthereumMode sets what Solidity verifiers expect: keccak-256, sorted pairs,
+// no domain separation, and the last node of an odd level promoted unchanged,
+// as merkletreejs and the Uniswap merkle distributor build their trees. Leaf
+// hashes are taken as they are, see EthLeaf.
+func EthereumMode() merklego.Option {
+	return merklego.WithOptions(
+		merklego.WithHashStrategy(NewKeccak256),
+		merklego.WithSortedPairs(true),
+		merklego.WithDomainSeparation(false),
+		merklego.WithPaddingStrategy(merklego.PadPromote),
+	)
+}
+
+// ABIEncode returns abi.encode(addr, amount), the address and the uint256
+// each padded to 32 bytes. amount must fit in a uint256.
+func ABIEncode(addr [20]byte, amount *big.Int) []byte {
+	enc := make([]byte, 64)
+	copy(enc[12:32], addr[:])
+	amount.FillBytes(enc[32:])
+
+	return enc
+}
+
+// EthLeaf returns the leaf of addr holding amount: keccak-256 of their
+// ABIEncode encoding, which a contract recomputes as
+// keccak256(abi.encode(account, amount)). amount must fit in a uint256.
+func EthLeaf(addr [20]byte, amount *big.Int) merklego.Block {
+	return merklego.Block(Keccak256(ABIEncode(addr, amount)))
+}
+
+// NewTree creates the tree of leaves, leaf hashes such as those of EthLeaf,
+// in EthereumMode.
+func NewTree(leaves []merklego.Block, opts ...merklego.Option) (*merklego.MerkleTree, error) {
+	hashes := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		hashes[i] = l
+	}
+
+	return merklego.NewTreeFromLeafHashes(hashes, NewKeccak256, append([]merklego.Option{EthereumMode()}, opts...)...)
+}
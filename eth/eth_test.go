@@ -0,0 +1,97 @@
+package merkleeth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	merklego "github.com/evalir/merkle-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeccak256(t *testing.T) {
+	require.Equal(t, "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470", hex.EncodeToString(Keccak256()))
+	require.Equal(t, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45", hex.EncodeToString(Keccak256([]byte("abc"))))
+	require.Equal(t, Keccak256([]byte("abc")), Keccak256([]byte("a"), []byte("bc")))
+}
+
+// fixture is testdata/tree.json, generated by testdata/tree.js with
+// merkletreejs.
+type fixture struct {
+	Root   string `json:"root"`
+	Claims []struct {
+		Address string   `json:"address"`
+		Amount  string   `json:"amount"`
+		Leaf    string   `json:"leaf"`
+		Proof   []string `json:"proof"`
+	} `json:"claims"`
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestEthereumFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/tree.json")
+	require.NoError(t, err)
+	var f fixture
+	require.NoError(t, json.Unmarshal(data, &f))
+
+	leaves := make([]merklego.Block, len(f.Claims))
+	for i, c := range f.Claims {
+		var addr [20]byte
+		copy(addr[:], decodeHex(t, c.Address))
+		amount, ok := new(big.Int).SetString(c.Amount, 10)
+		require.True(t, ok)
+
+		leaves[i] = EthLeaf(addr, amount)
+		require.Equal(t, decodeHex(t, c.Leaf), []byte(leaves[i]), "claim %d", i)
+	}
+
+	tree, err := NewTree(leaves)
+	require.NoError(t, err)
+	root := decodeHex(t, f.Root)
+	require.Equal(t, root, tree.MerkleRoot())
+
+	for i, c := range f.Claims {
+		p, err := tree.Prove(leaves[i])
+		require.NoError(t, err)
+		require.Equal(t, "keccak256", p.Hash)
+		require.NoError(t, p.Verify(root))
+
+		require.Len(t, p.Steps, len(c.Proof), "claim %d", i)
+		for j, s := range p.Steps {
+			require.Equal(t, decodeHex(t, c.Proof[j]), s.Hash, "claim %d step %d", i, j)
+		}
+	}
+}
+
+func TestEthereumModeOptions(t *testing.T) {
+	leaves := []merklego.Block{EthLeaf([20]byte{1}, big.NewInt(1)), EthLeaf([20]byte{2}, big.NewInt(2))}
+	plain, err := NewTree(leaves)
+	require.NoError(t, err)
+	separated, err := NewTree(leaves, merklego.WithDomainSeparation(true))
+	require.NoError(t, err)
+	require.NotEqual(t, plain.MerkleRoot(), separated.MerkleRoot())
+
+	// Sorted pairs make the root independent of the order of siblings.
+	swapped, err := NewTree([]merklego.Block{leaves[1], leaves[0]})
+	require.NoError(t, err)
+	require.Equal(t, plain.MerkleRoot(), swapped.MerkleRoot())
+	require.Equal(t, plain.MerkleRoot(), Keccak256(minMax(leaves[0], leaves[1])...))
+}
+
+// minMax returns a and b in ascending byte order.
+func minMax(a, b []byte) [][]byte {
+	if string(a) > string(b) {
+		a, b = b, a
+	}
+
+	return [][]byte{a, b}
+}
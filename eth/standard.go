@@ -0,0 +1,124 @@
+package merkleeth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	merklego "github.com/evalir/merkle-go"
+)
+
+// StandardTree is a tree built as OpenZeppelin's StandardMerkleTree builds
+// it, whose root and proofs are accepted by MerkleProof.verify against trees
+// of their JavaScript library. Leaves are hashed twice with keccak-256, sorted
+// by hash and laid out in a complete binary tree; sibling pairs are sorted
+// before being hashed.
+type StandardTree struct {
+	// nodes holds the tree in the order of the library: the children of node
+	// i are 2i+1 and 2i+2, and the sorted leaves are the last nodes, in
+	// reverse order.
+	nodes [][]byte
+	// positions maps the hex encoded hash of every leaf to its node.
+	positions map[string]int
+}
+
+var (
+	_ merklego.Rooter   = (*StandardTree)(nil)
+	_ merklego.Prover   = (*StandardTree)(nil)
+	_ merklego.Verifier = (*StandardTree)(nil)
+)
+
+// StandardLeafHash returns the hash of leaf, the ABI encoding of a value, in a
+// StandardTree: keccak256(keccak256(leaf)).
+func StandardLeafHash(leaf []byte) []byte {
+	return Keccak256(Keccak256(leaf))
+}
+
+// NewStandardTree creates the StandardTree of leaves, the ABI encodings of its
+// values as StandardMerkleTree.of encodes them with its leaf encoding, for
+// instance with ABIEncode for [address, uint256] values.
+func NewStandardTree(leaves [][]byte) (*StandardTree, error) {
+	if len(leaves) == 0 {
+		return nil, merklego.ErrNoContent
+	}
+
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = StandardLeafHash(leaf)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i], hashes[j]) < 0
+	})
+
+	t := &StandardTree{
+		nodes:     make([][]byte, 2*len(hashes)-1),
+		positions: make(map[string]int, len(hashes)),
+	}
+	for i, h := range hashes {
+		pos := len(t.nodes) - 1 - i
+		t.nodes[pos] = h
+		if _, ok := t.positions[hex.EncodeToString(h)]; !ok {
+			t.positions[hex.EncodeToString(h)] = pos
+		}
+	}
+	for i := len(t.nodes) - 1 - len(hashes); i >= 0; i-- {
+		t.nodes[i] = Keccak256(sortedPair(t.nodes[2*i+1], t.nodes[2*i+2])...)
+	}
+
+	return t, nil
+}
+
+// sortedPair returns a and b in ascending byte order.
+func sortedPair(a, b []byte) [][]byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+
+	return [][]byte{a, b}
+}
+
+// RootHash returns the root of the tree.
+func (t *StandardTree) RootHash() ([]byte, error) {
+	return append([]byte(nil), t.nodes[0]...), nil
+}
+
+// Prove returns the Proof of leaf, the ABI encoding of a value of the tree.
+// Its steps are the proof StandardMerkleTree.getProof returns, and its Index
+// the position of the leaf among the leaves sorted by hash.
+func (t *StandardTree) Prove(leaf []byte) (*merklego.Proof, error) {
+	hash := StandardLeafHash(leaf)
+	pos, ok := t.positions[hex.EncodeToString(hash)]
+	if !ok {
+		return nil, fmt.Errorf("error: leaf %x does not exist", leaf)
+	}
+
+	leaves := (len(t.nodes) + 1) / 2
+	p := &merklego.Proof{
+		Hash:        "keccak256",
+		SortedPairs: true,
+		Layout:      merklego.LayoutReversedHeap,
+		Index:       len(t.nodes) - 1 - pos,
+		Size:        leaves,
+		LeafHash:    hash,
+	}
+	for ; pos > 0; pos = (pos - 1) / 2 {
+		if pos%2 == 1 {
+			p.Steps = append(p.Steps, merklego.ProofStep{Hash: append([]byte(nil), t.nodes[pos+1]...)})
+		} else {
+			p.Steps = append(p.Steps, merklego.ProofStep{Hash: append([]byte(nil), t.nodes[pos-1]...), Left: true})
+		}
+	}
+
+	return p, nil
+}
+
+// VerifyProof checks that p is a proof of leaf, the ABI encoding of a value,
+// leading to the root of the tree.
+func (t *StandardTree) VerifyProof(leaf []byte, p *merklego.Proof) error {
+	if !bytes.Equal(StandardLeafHash(leaf), p.LeafHash) {
+		return fmt.Errorf("error: proof is not for leaf %x: %w", leaf, merklego.ErrInvalidProof)
+	}
+
+	return p.Verify(t.nodes[0])
+}
@@ -0,0 +1,122 @@
+package merkleeth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	merklego "github.com/evalir/merkle-go"
+	"github.com/stretchr/testify/require"
+)
+
+// standardDump is testdata/standard.json, the StandardMerkleTree dump written
+// by testdata/standard.js with @openzeppelin/merkle-tree, with the root and
+// the proof of every value.
+type standardDump struct {
+	Format       string   `json:"format"`
+	LeafEncoding []string `json:"leafEncoding"`
+	Tree         []string `json:"tree"`
+	Values       []struct {
+		Value     [2]string `json:"value"`
+		TreeIndex int       `json:"treeIndex"`
+	} `json:"values"`
+	Root   string `json:"root"`
+	Proofs []struct {
+		Value [2]string `json:"value"`
+		Proof []string  `json:"proof"`
+	} `json:"proofs"`
+}
+
+// encodeValue returns the ABI encoding of an [address, uint256] value.
+func encodeValue(t *testing.T, value [2]string) []byte {
+	var addr [20]byte
+	copy(addr[:], decodeHex(t, value[0]))
+	amount, ok := new(big.Int).SetString(value[1], 10)
+	require.True(t, ok)
+
+	return ABIEncode(addr, amount)
+}
+
+func TestStandardTreeDump(t *testing.T) {
+	data, err := os.ReadFile("testdata/standard.json")
+	require.NoError(t, err)
+	var d standardDump
+	require.NoError(t, json.Unmarshal(data, &d))
+	require.Equal(t, "standard-v1", d.Format)
+	require.Equal(t, []string{"address", "uint256"}, d.LeafEncoding)
+
+	leaves := make([][]byte, len(d.Values))
+	for i, v := range d.Values {
+		leaves[i] = encodeValue(t, v.Value)
+	}
+	tree, err := NewStandardTree(leaves)
+	require.NoError(t, err)
+
+	require.Len(t, tree.nodes, len(d.Tree))
+	for i, n := range d.Tree {
+		require.Equal(t, decodeHex(t, n), tree.nodes[i], "node %d", i)
+	}
+	for i, v := range d.Values {
+		require.Equal(t, v.TreeIndex, tree.positions[hex.EncodeToString(StandardLeafHash(leaves[i]))], "value %d", i)
+	}
+
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, decodeHex(t, d.Root), root)
+
+	for i, pr := range d.Proofs {
+		leaf := encodeValue(t, pr.Value)
+		p, err := tree.Prove(leaf)
+		require.NoError(t, err)
+		require.Len(t, p.Steps, len(pr.Proof), "proof %d", i)
+		for j, s := range p.Steps {
+			require.Equal(t, decodeHex(t, pr.Proof[j]), s.Hash, "proof %d step %d", i, j)
+		}
+		require.NoError(t, tree.VerifyProof(leaf, p))
+		require.NoError(t, p.Verify(root))
+	}
+}
+
+// TestStandardTreeReadme checks the root of the example of the README of
+// @openzeppelin/merkle-tree.
+func TestStandardTreeReadme(t *testing.T) {
+	tree, err := NewStandardTree([][]byte{
+		encodeValue(t, [2]string{"0x1111111111111111111111111111111111111111", "5000000000000000000"}),
+		encodeValue(t, [2]string{"0x2222222222222222222222222222222222222222", "2500000000000000000"}),
+	})
+	require.NoError(t, err)
+
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, "d4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77", hex.EncodeToString(root))
+}
+
+func TestStandardTree(t *testing.T) {
+	_, err := NewStandardTree(nil)
+	require.ErrorIs(t, err, merklego.ErrNoContent)
+
+	leaf := ABIEncode([20]byte{1}, big.NewInt(7))
+	single, err := NewStandardTree([][]byte{leaf})
+	require.NoError(t, err)
+	root, err := single.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, StandardLeafHash(leaf), root)
+	p, err := single.Prove(leaf)
+	require.NoError(t, err)
+	require.Empty(t, p.Steps)
+	require.NoError(t, p.Verify(root))
+
+	other := ABIEncode([20]byte{2}, big.NewInt(7))
+	_, err = single.Prove(other)
+	require.Error(t, err)
+	require.ErrorIs(t, single.VerifyProof(other, p), merklego.ErrInvalidProof)
+
+	// The order of the values does not change the tree.
+	a, err := NewStandardTree([][]byte{leaf, other})
+	require.NoError(t, err)
+	b, err := NewStandardTree([][]byte{other, leaf})
+	require.NoError(t, err)
+	require.Equal(t, a.nodes, b.nodes)
+}
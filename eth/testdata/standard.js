@@ -0,0 +1,23 @@
+// Generates standard.json: the dump of an OpenZeppelin StandardMerkleTree of
+// [address, uint256] values, in the "standard-v1" format of
+// StandardMerkleTree.dump, with the root and the proof of every value.
+//
+//	npm install && node standard.js > standard.json
+
+const { StandardMerkleTree } = require('@openzeppelin/merkle-tree');
+
+const values = [
+  ['0x1111111111111111111111111111111111111111', '5000000000000000000'],
+  ['0x2222222222222222222222222222222222222222', '2500000000000000000'],
+  ['0x3333333333333333333333333333333333333333', '42'],
+  ['0x4444444444444444444444444444444444444444', '0'],
+  ['0x5555555555555555555555555555555555555555', '1'],
+  ['0x6666666666666666666666666666666666666666', '115792089237316195423570985008687907853269984665640564039457584007913129639935'],
+];
+const tree = StandardMerkleTree.of(values, ['address', 'uint256']);
+
+console.log(JSON.stringify({
+  ...tree.dump(),
+  root: tree.root,
+  proofs: values.map((value, i) => ({ value, proof: tree.getProof(i) })),
+}, null, 2));
@@ -0,0 +1,31 @@
+// Generates tree.json: the leaves, root and proofs of a merkletreejs MerkleTree
+// built with { sortPairs: true } over keccak-256 leaves of
+// abi.encode(address, uint256), the layout of the Uniswap merkle distributor.
+//
+//	npm install && node tree.js > tree.json
+
+const { MerkleTree } = require('merkletreejs');
+const keccak256 = require('keccak256');
+const { AbiCoder } = require('ethers');
+
+const coder = AbiCoder.defaultAbiCoder();
+
+const claims = [
+  ['0x1111111111111111111111111111111111111111', '1000000000000000000'],
+  ['0x2222222222222222222222222222222222222222', '2500000000000000000'],
+  ['0x3333333333333333333333333333333333333333', '42'],
+  ['0x4444444444444444444444444444444444444444', '0'],
+  ['0x5555555555555555555555555555555555555555', '115792089237316195423570985008687907853269984665640564039457584007913129639935'],
+];
+const leaves = claims.map(([address, amount]) => keccak256(coder.encode(['address', 'uint256'], [address, amount])));
+const tree = new MerkleTree(leaves, keccak256, { sortPairs: true });
+
+console.log(JSON.stringify({
+  root: tree.getHexRoot(),
+  claims: claims.map(([address, amount], i) => ({
+    address,
+    amount,
+    leaf: '0x' + leaves[i].toString('hex'),
+    proof: tree.getHexProof(leaves[i], i),
+  })),
+}, null, 2));
@@ -0,0 +1,138 @@
+package merklego
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// NewMerkleTreeFromFile returns the tree of the file at path, read in blocks
+// of chunkSize bytes, as NewMerkleTreeFromReader does, and the size of the
+// file.
+func NewMerkleTreeFromFile(path string, chunkSize int, opts ...Option) (*FlatMerkleTree, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	return NewMerkleTreeFromReader(f, chunkSize, opts...)
+}
+
+// NewMerkleTreeFromReader reads r to its end in blocks of chunkSize bytes, the
+// last one being shorter, and returns their FlatMerkleTree finalized with opts
+// and the number of bytes read. The root is the one of a tree the blocks were
+// inserted into, but the tree only holds their leaf hashes: only a few blocks
+// are in memory at once whatever the size of the content, and the proofs of
+// the blocks are found by index, as ProofsEach and ProveRange give them.
+// Options that need every block at once, WithSortedLeaves,
+// WithRejectDuplicates and WithRandomSalts, are refused, as are branching
+// factors other than 2.
+//
+// The blocks are hashed across the goroutines set with WithWorkers, or
+// GOMAXPROCS of them by default, while the next ones are read.
+func NewMerkleTreeFromReader(r io.Reader, chunkSize int, opts ...Option) (*FlatMerkleTree, int64, error) {
+	if chunkSize <= 0 {
+		return nil, 0, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+
+	mt := NewMerkleTree()
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkStreamed(); err != nil {
+		return nil, 0, err
+	}
+	workers := mt.workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	type job struct {
+		index int
+		buf   []byte
+	}
+	// Every buffer is either being read, queued or hashed.
+	free := make(chan []byte, 2*workers)
+	for i := 0; i < cap(free); i++ {
+		free <- make([]byte, chunkSize)
+	}
+	jobs := make(chan job, workers)
+
+	var (
+		mu     sync.Mutex
+		leaves []TreeNode
+		wg     sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				leaf := mt.hashLeaf(j.buf)
+				free <- j.buf[:chunkSize]
+
+				mu.Lock()
+				for len(leaves) <= j.index {
+					leaves = append(leaves, nil)
+				}
+				leaves[j.index] = leaf
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var size int64
+	var err error
+	for i := 0; ; i++ {
+		buf := <-free
+		n, rerr := io.ReadFull(r, buf)
+		size += int64(n)
+		if n > 0 {
+			jobs <- job{index: i, buf: buf[:n]}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			err = fmt.Errorf("cannot read block %d: %w", i, rerr)
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(leaves) == 0 {
+		return nil, 0, ErrEmptyMerkleTree
+	}
+
+	mt.finalizeLeafHashes(leaves)
+
+	return mt, size, nil
+}
+
+// finalizeLeafHashes finalizes a binary tree over the blocks of the leaf
+// hashes leaves, without the blocks themselves.
+func (mt *FlatMerkleTree) finalizeLeafHashes(leaves []TreeNode) {
+	mt.count = len(leaves)
+	if len(leaves)%2 != 0 && !(len(leaves) == 1 && mt.singleLeafRoot) {
+		leaves = append(leaves, mt.paddingLeaf(leaves[len(leaves)-1]))
+	}
+
+	mt.blocks = make([]Block, len(leaves))
+	mt.nodes = make([]TreeNode, 2*len(leaves)-1)
+	copy(mt.nodes[len(mt.nodes)-len(leaves):], leaves)
+	mt.root = mt.finalize(0)
+	if mt.nodeIndex {
+		mt.indexNodes()
+	}
+	mt.hashedOnly = true
+	mt.finalized = true
+}
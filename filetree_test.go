@@ -0,0 +1,119 @@
+package merklego
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMerkleTreeFromReader(t *testing.T) {
+	data := randomData(4, 100_000)
+	for _, chunkSize := range []int{1000, 4096, 33_333, 100_000, 1 << 20} {
+		for _, workers := range []int{1, 4} {
+			opts := []Option{WithWorkers(workers), WithDistinctPadding(true)}
+			tree, size, err := NewMerkleTreeFromReader(iotest.HalfReader(bytes.NewReader(data)), chunkSize, opts...)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(data)), size)
+
+			want := NewMerkleTree()
+			for off := 0; off < len(data); off += chunkSize {
+				end := off + chunkSize
+				if end > len(data) {
+					end = len(data)
+				}
+				require.NoError(t, want.Insert(data[off:end]))
+			}
+			require.NoError(t, want.Finalize(opts...))
+			require.Equal(t, want.LeafCount(), tree.LeafCount())
+			require.Equal(t, want.nodes, tree.nodes)
+
+			root, err := tree.RootHash()
+			require.NoError(t, err)
+			require.NoError(t, tree.ProofsEach(func(index int, proof []TreeNode) error {
+				return VerifyFlatProof(root, want.blocks[index], index, tree.LeafCount(), proof, opts...)
+			}))
+		}
+	}
+}
+
+func TestNewMerkleTreeFromReaderErrors(t *testing.T) {
+	_, _, err := NewMerkleTreeFromReader(bytes.NewReader(nil), 1024)
+	require.ErrorIs(t, err, ErrEmptyMerkleTree)
+	_, _, err = NewMerkleTreeFromReader(bytes.NewReader([]byte("data")), 0)
+	require.Error(t, err)
+	_, _, err = NewMerkleTreeFromReader(bytes.NewReader([]byte("data")), 2, WithSortedLeaves(true))
+	require.Error(t, err)
+
+	failure := errors.New("disk on fire")
+	_, _, err = NewMerkleTreeFromReader(iotest.ErrReader(failure), 16)
+	require.ErrorIs(t, err, failure)
+
+	_, _, err = NewMerkleTreeFromFile(filepath.Join(t.TempDir(), "missing"), 16)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// The blocks are not held.
+	tree, _, err := NewMerkleTreeFromReader(bytes.NewReader([]byte("abcdef")), 2)
+	require.NoError(t, err)
+	_, err = tree.Prove([]byte("ab"))
+	require.Error(t, err)
+	_, err = tree.Snapshot()
+	require.Error(t, err)
+	p, err := tree.ProveRange(1, 2)
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.NoError(t, VerifyRange(root, 1, [][]byte{[]byte("cd")}, p))
+}
+
+func TestNewMerkleTreeFromFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("hashes a 256 MiB file twice")
+	}
+
+	const size = 256 << 20
+	const chunkSize = 1 << 20
+	path := filepath.Join(t.TempDir(), "sparse")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(size))
+	for _, off := range []int64{0, 12345678, size/2 + 7, size - 5} {
+		_, err := f.WriteAt([]byte("hello"), off)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	tree, n, err := NewMerkleTreeFromFile(path, chunkSize, WithWorkers(8))
+	require.NoError(t, err)
+	runtime.ReadMemStats(&after)
+	require.Equal(t, int64(size), n)
+	require.Equal(t, size/chunkSize, tree.LeafCount())
+	// The reading buffers and the nodes, not the content of the file.
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(size/8))
+
+	// The same root a block at a time, on a single goroutine.
+	naive, err := NewRootHasher(size / chunkSize)
+	require.NoError(t, err)
+	f, err = os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	buf := make([]byte, chunkSize)
+	for i := 0; i < size/chunkSize; i++ {
+		_, err := f.ReadAt(buf, int64(i)*chunkSize)
+		require.NoError(t, err)
+		require.NoError(t, naive.Write(buf))
+	}
+	want, err := naive.Sum()
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, want, root)
+}
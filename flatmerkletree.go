@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 )
 
 var (
@@ -16,9 +17,14 @@ var (
 	ErrTreeNotFinalized     = errors.New("Merkle tree not finalized")
 )
 
-var (
-	internalNodePrefix = '\x01'
-	leafNodePrefix     = '\x00'
+// The default prefixes of internal node and leaf hashes, and the prefix of the
+// padding leaf of WithDistinctPadding. They are constants: trees set their own
+// with WithLeafPrefix and WithNodePrefix rather than the process changing them
+// for every tree.
+const (
+	internalNodePrefix = 0x01
+	leafNodePrefix     = 0x00
+	paddingNodePrefix  = 0x02
 )
 
 type (
@@ -27,6 +33,17 @@ type (
 		nodes     []TreeNode
 		root      TreeNode
 		finalized bool
+		count     int
+		// positions maps the hash of every node to its lowest position,
+		// when the tree is finalized with WithNodeIndex.
+		positions map[string]int
+		// salts holds the salt of every block, nil for unsalted ones, and is
+		// nil when no block is salted.
+		salts [][]byte
+		// hashedOnly is set for trees built from the leaf hashes of their
+		// blocks, whose blocks are nil.
+		hashedOnly bool
+		config
 	}
 
 	TreeNode []byte
@@ -49,6 +66,158 @@ func NewMerkleTree(blocks ...Block) *FlatMerkleTree {
 	}
 }
 
+// NewSortedTree builds a non-finalized Merkle Tree with the blocks provided,
+// which Finalize orders by leaf hash as WithSortedLeaves does.
+func NewSortedTree(blocks ...Block) *FlatMerkleTree {
+	mt := NewMerkleTree(blocks...)
+	mt.sortedLeaves = true
+
+	return mt
+}
+
+// NewMerkleTreeNamed builds a non-finalized Merkle Tree with the blocks
+// provided, hashed with the hash function registered under name, for a choice
+// made at runtime. It returns an UnknownHashError if name is not registered.
+func NewMerkleTreeNamed(name string, blocks ...Block) (*FlatMerkleTree, error) {
+	hashStrategy, err := LookupHash(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mt := NewMerkleTree(blocks...)
+	mt.hashFunc = hashStrategy
+
+	return mt, nil
+}
+
+// FlatSnapshot is the state of a finalized FlatMerkleTree, from which
+// RestoreFlatMerkleTree rebuilds an identical tree.
+type FlatSnapshot struct {
+	Blocks         [][]byte
+	SortedPairs    bool
+	SingleLeafRoot bool
+	// DistinctPadding is set for trees finalized with WithDistinctPadding.
+	DistinctPadding bool
+	// LengthPrefixedLeaves is set for trees finalized with
+	// WithLengthPrefixedLeaves.
+	LengthPrefixedLeaves bool
+	// LeafPrefix and NodePrefix are the prefixes set with WithLeafPrefix and
+	// WithNodePrefix, nil for the defaults.
+	LeafPrefix, NodePrefix []byte
+	// PairHash names the pair hash of the tree, as Proof.PairHash does.
+	PairHash string
+	// Hash names the hash function of the tree as registered with
+	// RegisterHash, empty for SHA-256 and "?" for an unregistered one.
+	Hash string
+	// Keyed is set for trees finalized with WithHMACKey, whose key the
+	// snapshot does not hold.
+	Keyed bool
+	// DigestSize is the size hashes are truncated to with WithDigestSize, 0
+	// for full digests.
+	DigestSize int
+	// Salts holds the salt of every block, nil for unsalted ones, and is nil
+	// for trees without salted blocks.
+	Salts [][]byte
+	// BranchingFactor is the branching factor set with WithBranchingFactor,
+	// 0 for binary trees.
+	BranchingFactor int
+	Root            []byte
+}
+
+// Snapshot returns the blocks, settings and root of a finalized tree.
+func (mt *FlatMerkleTree) Snapshot() (*FlatSnapshot, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if mt.hashedOnly {
+		return nil, errors.New("cannot snapshot a tree that does not hold its blocks")
+	}
+
+	s := &FlatSnapshot{
+		Blocks:               make([][]byte, mt.count),
+		SortedPairs:          mt.sortedPairs,
+		SingleLeafRoot:       mt.singleLeafRoot,
+		DistinctPadding:      mt.distinctPadding,
+		LengthPrefixedLeaves: mt.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(mt.leafPrefix),
+		NodePrefix:           clonePrefix(mt.nodePrefix),
+		PairHash:             pairHashName(mt.pairHash),
+		Hash:                 mt.hashID(),
+		Keyed:                mt.keyed(),
+		DigestSize:           mt.truncatedSize(),
+		Root:                 copyNode(mt.root),
+	}
+	for i, b := range mt.blocks[:mt.count] {
+		s.Blocks[i] = copyNode(TreeNode(b))
+	}
+	if k := mt.branching(); k != 2 {
+		s.BranchingFactor = k
+	}
+	if mt.salted() {
+		s.Salts = make([][]byte, mt.count)
+		for i := range s.Salts {
+			s.Salts[i] = clonePrefix(mt.saltAt(i))
+		}
+	}
+
+	return s, nil
+}
+
+// RestoreFlatMerkleTree finalizes a tree over the blocks of s with its
+// settings, and checks that it has the root recorded in s. The snapshot of a
+// keyed tree is restored with its key given in opts with WithHMACKey; other
+// options in opts override the settings of s.
+func RestoreFlatMerkleTree(s *FlatSnapshot, opts ...Option) (*FlatMerkleTree, error) {
+	blocks := make([]Block, len(s.Blocks))
+	for i, b := range s.Blocks {
+		if b == nil {
+			return nil, ErrNilBlock
+		}
+		blocks[i] = Block(copyNode(b))
+	}
+	if s.Salts != nil && len(s.Salts) != len(s.Blocks) {
+		return nil, fmt.Errorf("snapshot of %d blocks has %d salts", len(s.Blocks), len(s.Salts))
+	}
+
+	pairHash, err := pairHashOption(s.PairHash)
+	if err != nil {
+		return nil, err
+	}
+	hashStrategy, err := hashOption(s.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	mt := NewMerkleTree(blocks...)
+	for _, salt := range s.Salts {
+		mt.salts = append(mt.salts, clonePrefix(salt))
+	}
+	settings := []Option{
+		WithSortedPairs(s.SortedPairs),
+		WithSingleLeafRoot(s.SingleLeafRoot),
+		WithDistinctPadding(s.DistinctPadding),
+		WithLengthPrefixedLeaves(s.LengthPrefixedLeaves),
+		WithLeafPrefix(s.LeafPrefix),
+		WithNodePrefix(s.NodePrefix),
+		pairHash,
+		hashStrategy,
+		WithDigestSize(s.DigestSize),
+		WithBranchingFactor(s.BranchingFactor),
+	}
+	if err := mt.Finalize(append(settings, opts...)...); err != nil {
+		return nil, err
+	}
+	if mt.keyed() != s.Keyed {
+		return nil, fmt.Errorf("snapshot keyed with HMAC: %t, restored with a key: %t", s.Keyed, mt.keyed())
+	}
+
+	if !RootEqual(mt.root, s.Root) {
+		return nil, fmt.Errorf("snapshot root mismatch; got: %X, want: %X", mt.root.Bytes(), s.Root)
+	}
+
+	return mt, nil
+}
+
 func (mt *FlatMerkleTree) String() (s string) {
 	if rh, err := mt.RootHash(); err == nil {
 		s = fmt.Sprintf("0x%s", hex.EncodeToString(rh))
@@ -57,6 +226,17 @@ func (mt *FlatMerkleTree) String() (s string) {
 	return
 }
 
+// LeafCount returns the number of blocks of the tree. The duplicate of the
+// last block that Finalize appends to an odd number of blocks only pads the
+// tree and is not counted.
+func (mt *FlatMerkleTree) LeafCount() int {
+	if !mt.finalized {
+		return len(mt.blocks)
+	}
+
+	return mt.count
+}
+
 // RootHash returns the root hash of the Merkle Tree.
 func (mt *FlatMerkleTree) RootHash() ([]byte, error) {
 	if !mt.finalized {
@@ -90,6 +270,10 @@ func (mt *FlatMerkleTree) Insert(block Block) error {
 //
 // For any given node (starting at the block), add it's sibling to the proof
 // and then set the current node to their parent, until the root is reached.
+//
+// The proof of the last of an odd number of blocks is the one of its real
+// index; the padding duplicate Finalize appends after it has no proof of its
+// own, and the verifiers refuse a path claiming it as a block.
 func (mt *FlatMerkleTree) Proof(block Block) ([]TreeNode, error) {
 	if block == nil {
 		return nil, ErrNilBlock
@@ -98,38 +282,81 @@ func (mt *FlatMerkleTree) Proof(block Block) ([]TreeNode, error) {
 	if !mt.finalized {
 		return nil, ErrTreeNotFinalized
 	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
 
 	idx, err := mt.findLeaf(block)
 	if err != nil {
 		return nil, err
 	}
 
-	nodeIdx := idx
-	k := 0
-	proof := make([]TreeNode, int(math.Log2(float64(len(mt.nodes)))))
+	return mt.nodePath(idx), nil
+}
 
-	for nodeIdx > 0 {
-		if nodeIdx%2 == 0 {
-			proof[k] = copyNode(mt.nodes[nodeIdx-1])
-		} else {
-			proof[k] = copyNode(mt.nodes[nodeIdx+1])
-		}
-		k++
+// ProofsAll returns the proof of every block of the tree, keyed by the index
+// the block was inserted at. It walks the tree once instead of searching for
+// each block, and the proofs share their nodes, which must not be modified.
+func (mt *FlatMerkleTree) ProofsAll() (map[int][]TreeNode, error) {
+	proofs := make(map[int][]TreeNode, mt.count)
+	err := mt.ProofsEach(func(index int, proof []TreeNode) error {
+		proofs[index] = proof
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return proofs, nil
+}
+
+// ProofsEach calls fn with the proof of every block of the tree in insertion
+// order, without materializing all of them at once. It stops at the first
+// error returned by fn and returns it.
+func (mt *FlatMerkleTree) ProofsEach(fn func(index int, proof []TreeNode) error) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return err
+	}
 
-		nodeIdx = (nodeIdx - 1) / 2
+	// Copy every node once into a single slab shared by all proofs.
+	size := 0
+	for _, n := range mt.nodes {
+		size += len(n)
+	}
+	slab := make([]byte, 0, size)
+	nodes := make([]TreeNode, len(mt.nodes))
+	for i, n := range mt.nodes {
+		slab = append(slab, n...)
+		nodes[i] = TreeNode(slab[len(slab)-len(n) : len(slab) : len(slab)])
 	}
 
-	// Proof was requested for a block on the second to last level
-	// So remove the empty last proof chunk
-	if proof[len(proof)-1] == nil {
-		return proof[:len(proof)-1], nil
+	depth := int(math.Log2(float64(len(mt.nodes))))
+	first := len(mt.nodes) - len(mt.blocks)
+	for i := 0; i < mt.count; i++ {
+		proof := make([]TreeNode, 0, depth)
+		for idx := first + i; idx > 0; idx = (idx - 1) / 2 {
+			if idx%2 == 0 {
+				proof = append(proof, nodes[idx-1])
+			} else {
+				proof = append(proof, nodes[idx+1])
+			}
+		}
+
+		if err := fn(i, proof); err != nil {
+			return err
+		}
 	}
 
-	return proof, nil
+	return nil
 }
 
 // Verify performs a Merkle tree verification for a given block and proof.
-// If the proof can be reconstructed, then the proof is valid.
+// If the proof can be reconstructed, then the proof is valid. The placement of
+// each proof chunk is derived from the index of the block, as VerifyFlatProof
+// does, not from where the tree stores its nodes.
 func (mt *FlatMerkleTree) Verify(block Block, proof []TreeNode) error {
 	if !mt.finalized {
 		return ErrTreeNotFinalized
@@ -140,29 +367,213 @@ func (mt *FlatMerkleTree) Verify(block Block, proof []TreeNode) error {
 		return err
 	}
 
-	currNodeIdx := leafIdx
-	for i, proofChunk := range proof {
-		var reconstructedNode TreeNode
+	index := leafIdx - (len(mt.nodes) - len(mt.blocks))
+
+	return mt.verifyPath(mt.root, block, mt.saltAt(index), index, mt.count, proof)
+}
+
+// VerifyFlatProof checks proof, as returned by Proof, for block against the
+// root of a FlatMerkleTree finalized over size blocks with opts, block being
+// the one at index. The side of each proof chunk is read from the binary
+// representation of the position of the leaf, which only depends on index and
+// size, so no tree is needed to verify a proof.
+func VerifyFlatProof(root []byte, block Block, index, size int, proof []TreeNode, opts ...Option) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+
+	return mt.verifyPath(root, block, nil, index, size, proof)
+}
+
+// VerifyLeafHash checks proof, as returned by Proof, for the leaf whose hash
+// is leafHash, as Verify does for a block. The hash must be the domain
+// separated leaf hash the tree stores, SHA-256 of 0x00 followed by the block,
+// as in the LeafHash of a Proof; the plain SHA-256 of the block is not a leaf
+// of the tree. Internal nodes are hashed as usual, with their 0x01 prefix.
+func (mt *FlatMerkleTree) VerifyLeafHash(leafHash []byte, proof []TreeNode) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+
+	first := len(mt.nodes) - len(mt.blocks)
+	for i := 0; i < mt.count; i++ {
+		if RootEqual(mt.nodes[first+i], leafHash) {
+			return mt.verifyLeaf(mt.root, leafHash, i, mt.count, proof, "leaf hash", leafHash)
+		}
+	}
+
+	if mt.terseErrors {
+		return errors.New("leaf hash does not exist")
+	}
+
+	return fmt.Errorf("leaf hash does not exist: %v", hex.EncodeToString(leafHash))
+}
+
+// VerifyFlatLeafHash checks proof for the leaf whose hash is leafHash, the one
+// at index among size blocks, against root, as VerifyFlatProof does for a
+// block. leafHash must be the domain separated leaf hash described in
+// FlatMerkleTree.VerifyLeafHash.
+func VerifyFlatLeafHash(root, leafHash []byte, index, size int, proof []TreeNode, opts ...Option) error {
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if want := mt.newHash().Size(); len(leafHash) != want {
+		return fmt.Errorf("invalid leaf hash of %d bytes, want %d", len(leafHash), want)
+	}
+
+	return mt.verifyLeaf(root, leafHash, index, size, proof, "leaf hash", leafHash)
+}
+
+// verifyPath folds proof into the leaf of block salted with salt, the one at
+// index among size blocks, and compares the result with root.
+func (mt *FlatMerkleTree) verifyPath(root []byte, block Block, salt []byte, index, size int, proof []TreeNode) error {
+	return mt.verifyLeaf(root, mt.hashSaltedLeaf(salt, block), index, size, proof, "block", block)
+}
+
+// verifyLeaf folds proof into leaf, the leaf hash at index among size blocks,
+// and compares the result with root. Errors name the leaf as what, followed by
+// id.
+func (mt *FlatMerkleTree) verifyLeaf(root []byte, leaf TreeNode, index, size int, proof []TreeNode, what string, id []byte) error {
+	left, err := mt.proofSides(index, size)
+	if err != nil {
+		return err
+	}
+	if len(proof) != len(left) {
+		if mt.terseErrors {
+			return fmt.Errorf("invalid proof for leaf %d: got %d chunks, want %d", index, len(proof), len(left))
+		}
+		return fmt.Errorf("invalid proof for %s %X: got %d chunks, want %d", what, id, len(proof), len(left))
+	}
+
+	node := leaf
 
-		proofNodeBytes := copyNode(proofChunk)
-		currentNodeBytes := copyNode(mt.nodes[currNodeIdx])
+	// The last leaf of an even number of blocks whose left sibling is the
+	// same leaf is indistinguishable from the padding duplicate of a tree of
+	// one block less, which has the same root. Proofs only ever target the
+	// real leaf, the first occurrence of a block, so this path is refused.
+	// Distinct padding gives the two trees different roots.
+	if !mt.distinctPadding && index == size-1 && size%2 == 0 && len(proof) > 0 && left[0] && RootEqual(proof[0], node) {
+		if mt.terseErrors {
+			return fmt.Errorf("invalid proof for leaf %d: padding duplicate", index)
+		}
+		return fmt.Errorf("invalid proof for %s %X: leaf %d of %d is a padding duplicate", what, id, index, size)
+	}
 
-		// Append sibling to the left
-		if currNodeIdx%2 == 0 {
-			reconstructedNode = hashNode(append(proofNodeBytes, currentNodeBytes...), true)
+	for i, chunk := range proof {
+		if left[i] {
+			node = mt.hashChildren(chunk, node)
 		} else {
-			reconstructedNode = hashNode(append(currentNodeBytes, proofNodeBytes...), true)
+			node = mt.hashChildren(node, chunk)
+		}
+	}
+
+	if !RootEqual(node, root) {
+		if mt.terseErrors {
+			return fmt.Errorf("invalid proof for leaf %d of %d", index, size)
 		}
+		return fmt.Errorf("invalid proof for %s %X; got: %X, want: %X", what, id, node.Bytes(), root)
+	}
 
-		parentIdx := (currNodeIdx - 1) / 2
-		parentNode := mt.nodes[parentIdx]
+	return nil
+}
+
+// proofSides returns, from the leaf up, whether each proof chunk of the block
+// at index among size blocks is the left sibling, the tree being stored as
+// LayoutHeap describes. A single block hashed with WithSingleLeafRoot is the
+// root, without any chunk.
+func (mt *FlatMerkleTree) proofSides(index, size int) ([]bool, error) {
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("block index %d out of range [0, %d)", index, size)
+	}
+	if size == 1 && mt.singleLeafRoot {
+		return nil, nil
+	}
 
-		if !bytes.Equal(parentNode.Bytes(), reconstructedNode.Bytes()) {
-			return fmt.Errorf("invalid proof at index %d for block %X; got: %X, want: %X",
-				i, block, reconstructedNode.Bytes(), parentNode.Bytes())
+	return LayoutHeap.sides(index, size), nil
+}
+
+// Prove returns the Proof of block, the first one in the tree if it was
+// inserted several times.
+func (mt *FlatMerkleTree) Prove(block []byte) (*Proof, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+
+	idx, err := mt.findLeaf(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return mt.proofAt(idx), nil
+}
+
+// proofAt returns the Proof of the leaf at position idx of the nodes.
+func (mt *FlatMerkleTree) proofAt(idx int) *Proof {
+	p := &Proof{
+		Hash:                 mt.hashProofName(),
+		DomainSeparation:     true,
+		SortedPairs:          mt.sortedPairs,
+		LengthPrefixedLeaves: mt.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(mt.leafPrefix),
+		NodePrefix:           clonePrefix(mt.nodePrefix),
+		PairHash:             pairHashName(mt.pairHash),
+		Keyed:                mt.keyed(),
+		DigestSize:           mt.truncatedSize(),
+		Salt:                 clonePrefix(mt.saltAt(idx - (len(mt.nodes) - len(mt.blocks)))),
+		Layout:               LayoutHeap,
+		Index:                idx - (len(mt.nodes) - len(mt.blocks)),
+		Size:                 mt.count,
+		LeafHash:             copyNode(mt.nodes[idx]),
+	}
+	for ; idx > 0; idx = (idx - 1) / 2 {
+		if idx%2 == 0 {
+			p.Steps = append(p.Steps, ProofStep{Hash: copyNode(mt.nodes[idx-1]), Left: true})
+		} else {
+			p.Steps = append(p.Steps, ProofStep{Hash: copyNode(mt.nodes[idx+1])})
 		}
+	}
 
-		currNodeIdx = parentIdx
+	return p
+}
+
+// VerifyProof checks that p proves block against the root of the tree. The
+// sides of its steps are derived from p.Index and p.Size, as Verify does. The
+// block of a salted leaf is hashed with p.Salt.
+func (mt *FlatMerkleTree) VerifyProof(block []byte, p *Proof) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+
+	leaf := mt.hashSaltedLeaf(p.Salt, block)
+	if !RootEqual(leaf, p.LeafHash) {
+		if mt.terseErrors {
+			return fmt.Errorf("invalid proof for leaf %d: leaf hash mismatch: %w", p.Index, ErrInvalidProof)
+		}
+		return fmt.Errorf("invalid proof for block %X: leaf hash mismatch: %w", block, ErrInvalidProof)
+	}
+
+	if p.Size != mt.count {
+		return fmt.Errorf("invalid proof for a tree of %d blocks, want %d: %w", p.Size, mt.count, ErrInvalidProof)
+	}
+
+	proof := make([]TreeNode, len(p.Steps))
+	for i, step := range p.Steps {
+		proof[i] = step.Hash
+	}
+	if err := mt.verifyPath(mt.root, block, p.Salt, p.Index, p.Size, proof); err != nil {
+		return fmt.Errorf("%v: %w", err, ErrInvalidProof)
 	}
 
 	return nil
@@ -177,8 +588,14 @@ func (mt *FlatMerkleTree) Verify(block Block, proof []TreeNode) error {
 // pre-image attacks.
 //
 // If there are an odd number of leaf nodes, the last data block will be
-// duplicated to create an even set.
-func (mt *FlatMerkleTree) Finalize() error {
+// duplicated to create an even set, unless the tree holds a single block and
+// WithSingleLeafRoot is set, in which case the root is that block's leaf hash.
+// With WithDistinctPadding the leaf of the duplicate is hashed with a 0x02
+// prefix. Trees of WithBranchingFactor above 2 are padded to fill every node
+// with k children.
+//
+// Options given to Finalize configure how the tree is built and verified.
+func (mt *FlatMerkleTree) Finalize(opts ...Option) error {
 	if len(mt.blocks) == 0 {
 		return fmt.Errorf("Failed to finalize: %s", ErrEmptyMerkleTree)
 	}
@@ -187,7 +604,49 @@ func (mt *FlatMerkleTree) Finalize() error {
 		return ErrTreeAlreadyFinalized
 	}
 
-	if len(mt.blocks)%2 != 0 {
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkBranching(); err != nil {
+		return err
+	}
+	if mt.branching() != 2 && mt.pairHash != nil {
+		return fmt.Errorf("a pair hash cannot hash the %d children of a node", mt.branching())
+	}
+	if err := mt.fillSalts(); err != nil {
+		return err
+	}
+
+	if mt.rejectDuplicates {
+		if err := findDuplicate(len(mt.blocks), func(i int) []byte { return mt.blocks[i] }); err != nil {
+			return err
+		}
+	}
+
+	if mt.sortedLeaves {
+		mt.sortBlocks()
+	}
+
+	mt.count = len(mt.blocks)
+	if mt.salted() {
+		mt.padSalts()
+	}
+	if mt.branching() != 2 {
+		mt.finalizeKary()
+	} else {
+		mt.finalizeBinary()
+	}
+	if mt.nodeIndex {
+		mt.indexNodes()
+	}
+	mt.finalized = true
+
+	return nil
+}
+
+// finalizeBinary pads the blocks and hashes the nodes of a binary tree.
+func (mt *FlatMerkleTree) finalizeBinary() {
+	if len(mt.blocks)%2 != 0 && !(len(mt.blocks) == 1 && mt.singleLeafRoot) {
 		mt.blocks = append(mt.blocks, mt.blocks[len(mt.blocks)-1])
 	}
 
@@ -198,15 +657,58 @@ func (mt *FlatMerkleTree) Finalize() error {
 	// The merkle tree array will then have the first 2 * N - (N + 1) slots
 	// with intermediate nodes, with 0 being the root.
 	j := len(mt.nodes) - len(mt.blocks)
-	for _, b := range mt.blocks {
-		mt.nodes[j] = hashNode(b, false)
+	for i, b := range mt.blocks[:mt.count] {
+		mt.nodes[j] = mt.hashSaltedLeaf(mt.saltAt(i), b)
 		j++
 	}
+	if j < len(mt.nodes) {
+		mt.nodes[j] = mt.paddingLeaf(mt.nodes[j-1])
+	}
 
 	mt.root = mt.finalize(0)
-	mt.finalized = true
+}
 
-	return nil
+// sortBlocks orders the blocks by leaf hash, in a new slice so the one given
+// by the caller is left untouched. Duplicate blocks share a leaf hash and keep
+// their insertion order.
+func (mt *FlatMerkleTree) sortBlocks() {
+	hashes := make([]TreeNode, len(mt.blocks))
+	order := make([]int, len(mt.blocks))
+	for i, b := range mt.blocks {
+		hashes[i] = mt.hashSaltedLeaf(mt.saltAt(i), b)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(hashes[order[i]], hashes[order[j]]) < 0
+	})
+
+	blocks := make([]Block, len(mt.blocks), len(mt.blocks)+1)
+	for i, o := range order {
+		blocks[i] = mt.blocks[o]
+	}
+	if mt.salted() {
+		salts := make([][]byte, len(mt.blocks))
+		for i, o := range order {
+			salts[i] = mt.saltAt(o)
+		}
+		mt.salts = salts
+	}
+	mt.blocks = blocks
+}
+
+// IndexOf returns the index of the first leaf holding block, the position it
+// was sorted to in a tree with sorted leaves.
+func (mt *FlatMerkleTree) IndexOf(block Block) (int, error) {
+	if !mt.finalized {
+		return -1, ErrTreeNotFinalized
+	}
+
+	idx, err := mt.findLeaf(block)
+	if err != nil {
+		return -1, err
+	}
+
+	return idx - (len(mt.nodes) - len(mt.blocks)), nil
 }
 
 func (mt *FlatMerkleTree) finalize(idx int) TreeNode {
@@ -217,15 +719,34 @@ func (mt *FlatMerkleTree) finalize(idx int) TreeNode {
 	left := mt.finalize(2*idx + 1)
 	right := mt.finalize(2*idx + 2)
 
-	mt.nodes[idx] = hashNode(append(left, right...), true)
+	mt.nodes[idx] = mt.hashChildren(left, right)
 
 	return mt.nodes[idx]
 }
 
+// hashChildren hashes two sibling nodes into their parent, ordering them first
+// when the tree uses sorted pairs.
+func (mt *FlatMerkleTree) hashChildren(left, right TreeNode) TreeNode {
+	l, r := mt.orderPair(left, right)
+	if mt.pairHash != nil {
+		return TreeNode(mt.truncate(mt.pairHash(l, r)))
+	}
+
+	h := mt.newHash()
+	h.Write(mt.nodePrefixBytes())
+	h.Write(l)
+	h.Write(r)
+
+	return TreeNode(h.Sum(nil))
+}
+
 func (mt *FlatMerkleTree) findLeaf(block Block) (int, error) {
 	if block == nil {
 		return -1, ErrNilBlock
 	}
+	if mt.hashedOnly {
+		return -1, errors.New("block does not exist: the tree does not hold its blocks")
+	}
 
 	for i := 0; i < len(mt.blocks); i++ {
 		if bytes.Equal(mt.blocks[i].Bytes(), block.Bytes()) {
@@ -233,6 +754,10 @@ func (mt *FlatMerkleTree) findLeaf(block Block) (int, error) {
 		}
 	}
 
+	if mt.terseErrors {
+		return -1, errors.New("block does not exist")
+	}
+
 	return -1, fmt.Errorf("block does not exist: %v", hex.EncodeToString(block))
 }
 
@@ -257,6 +782,20 @@ func hashNode(data []byte, internal bool) TreeNode {
 	return TreeNode(sum[:])
 }
 
+// paddingLeaf returns the leaf padding an odd number of blocks, the last of
+// which has the leaf hash last.
+func (mt *FlatMerkleTree) paddingLeaf(last TreeNode) TreeNode {
+	if !mt.distinctPadding {
+		return last
+	}
+
+	h := mt.newHash()
+	h.Write([]byte{paddingNodePrefix})
+	h.Write(last)
+
+	return TreeNode(h.Sum(nil))
+}
+
 func copyNode(node TreeNode) TreeNode {
 	cpy := make(TreeNode, len(node))
 	copy(cpy, node)
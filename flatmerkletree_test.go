@@ -1,9 +1,13 @@
 package merklego
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -394,3 +398,566 @@ func TestVerify(t *testing.T) {
 		}
 	}
 }
+
+// verifySortedPairs is a reference OpenZeppelin-style verifier: it folds the
+// proof into the leaf hash ordering each pair before hashing, without any
+// position information.
+func verifySortedPairs(root []byte, block Block, proof []TreeNode) bool {
+	leaf := sha256.Sum256(append([]byte{0x00}, block...))
+	computed := leaf[:]
+
+	for _, p := range proof {
+		pair := []byte{0x01}
+		if bytes.Compare(computed, p) <= 0 {
+			pair = append(append(pair, computed...), p...)
+		} else {
+			pair = append(append(pair, p...), computed...)
+		}
+
+		sum := sha256.Sum256(pair)
+		computed = sum[:]
+	}
+
+	return bytes.Equal(computed, root)
+}
+
+func TestSortedPairs(t *testing.T) {
+	dataSets := [][]Block{
+		{Block("blockA"), Block("blockB"), Block("blockC"), Block("blockD"), Block("blockE"), Block("blockF"), Block("blockG"), Block("blockH")},
+		{Block("blockA"), Block("blockB"), Block("blockC"), Block("blockD"), Block("blockE")},
+		{Block("blockA"), Block("blockB")},
+	}
+
+	for i, blocks := range dataSets {
+		sorted := NewMerkleTree(blocks...)
+		require.NoError(t, sorted.Finalize(WithSortedPairs(true)), fmt.Sprintf("unexpected error: test case #%d", i))
+
+		unsorted := NewMerkleTree(blocks...)
+		require.NoError(t, unsorted.Finalize(), fmt.Sprintf("unexpected error: test case #%d", i))
+
+		root, err := sorted.RootHash()
+		require.NoError(t, err, fmt.Sprintf("unexpected error: test case #%d", i))
+
+		for _, b := range blocks {
+			proof, err := sorted.Proof(b)
+			require.NoError(t, err, fmt.Sprintf("unexpected error: test case #%d", i))
+			require.NoError(t, sorted.Verify(b, proof), fmt.Sprintf("invalid proof: test case #%d", i))
+			require.True(t, verifySortedPairs(root, b, proof), fmt.Sprintf("reference verifier rejected proof: test case #%d", i))
+
+			unsortedProof, err := unsorted.Proof(b)
+			require.NoError(t, err, fmt.Sprintf("unexpected error: test case #%d", i))
+			require.NoError(t, unsorted.Verify(b, unsortedProof), fmt.Sprintf("invalid proof: test case #%d", i))
+		}
+	}
+}
+
+func TestSingleLeafRoot(t *testing.T) {
+	block := Block("blockA")
+	leaf := sha256.Sum256(append([]byte{0x00}, block...))
+
+	mt := NewMerkleTree(block)
+	require.NoError(t, mt.Finalize(WithSingleLeafRoot(true)))
+
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, leaf[:], root)
+
+	proof, err := mt.Proof(block)
+	require.NoError(t, err)
+	require.Empty(t, proof)
+	require.NoError(t, mt.Verify(block, proof))
+	require.Error(t, mt.Verify(Block("blockB"), proof))
+
+	legacy := NewMerkleTree(block)
+	require.NoError(t, legacy.Finalize())
+
+	legacyRoot, err := legacy.RootHash()
+	require.NoError(t, err)
+	require.NotEqual(t, leaf[:], legacyRoot)
+	require.Error(t, legacy.Verify(block, []TreeNode{}), "empty proof must not reach the root of a larger tree")
+
+	multi := NewMerkleTree(Block("blockA"), Block("blockB"))
+	require.NoError(t, multi.Finalize(WithSingleLeafRoot(true)))
+
+	multiRoot, err := multi.RootHash()
+	require.NoError(t, err)
+	expectedRoot, _ := hex.DecodeString("526885312f344b1ecf858295f8ccb0205d5a9e34f99eddf899726750183c4d4b")
+	require.Equal(t, expectedRoot, multiRoot)
+}
+
+func TestProofsAll(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		for _, sorted := range []bool{false, true} {
+			blocks := make([]Block, n)
+			for i := range blocks {
+				blocks[i] = Block(fmt.Sprintf("block-%d", i))
+			}
+
+			mt := NewMerkleTree(blocks...)
+			require.Error(t, mt.ProofsEach(func(int, []TreeNode) error { return nil }))
+			require.NoError(t, mt.Finalize(WithSortedPairs(sorted)))
+
+			proofs, err := mt.ProofsAll()
+			require.NoError(t, err)
+			require.Len(t, proofs, n, fmt.Sprintf("blocks: %d", n))
+
+			for i, b := range blocks {
+				expected, err := mt.Proof(b)
+				require.NoError(t, err)
+				require.Equal(t, expected, proofs[i], fmt.Sprintf("blocks: %d, index: %d", n, i))
+				require.NoError(t, mt.Verify(b, proofs[i]), fmt.Sprintf("blocks: %d, index: %d", n, i))
+			}
+		}
+	}
+}
+
+func TestProofsEachStops(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	require.NoError(t, mt.Finalize())
+
+	stop := errors.New("stop")
+	calls := 0
+	err := mt.ProofsEach(func(int, []TreeNode) error {
+		calls++
+		return stop
+	})
+	require.Equal(t, stop, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestRejectDuplicates(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	require.NoError(t, mt.Finalize(WithRejectDuplicates(true)), "padding duplicate must not be rejected")
+
+	dup := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockA"))
+	err := dup.Finalize(WithRejectDuplicates(true))
+	require.Equal(t, &DuplicateError{First: 0, Second: 2}, err)
+	_, err = dup.RootHash()
+	require.Error(t, err)
+
+	permissive := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockA"))
+	require.NoError(t, permissive.Finalize())
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	_, err := mt.Snapshot()
+	require.Equal(t, ErrTreeNotFinalized, err)
+	require.NoError(t, mt.Finalize(WithSortedPairs(true)))
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, s.Blocks, 3, "padding duplicate must not be part of the snapshot")
+
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	root, _ := mt.RootHash()
+	restoredRoot, _ := restored.RootHash()
+	require.Equal(t, root, restoredRoot)
+
+	s.Blocks[0] = []byte("blockD")
+	_, err = RestoreFlatMerkleTree(s)
+	require.Error(t, err)
+}
+
+func TestVerifyFlatProof(t *testing.T) {
+	// Root and proof of blockE among blockA..blockE, see TestVerify.
+	root, _ := hex.DecodeString("8F95978F1B0DD9D3D792CC82E5B21C7C32927C7FE7C8D477BE030AB1654BBC93")
+	proof := make([]TreeNode, 3)
+	for i, p := range []string{
+		"5E6F4831C72462B47E9594F04DC58822FD3AB0A050452C97119E1EC017FAADF2",
+		"9577C5848D134240A957225DD68A3D697C7D937592380C653DFE184F50DD8482",
+		"526885312F344B1ECF858295F8CCB0205D5A9E34F99EDDF899726750183C4D4B",
+	} {
+		proof[i], _ = hex.DecodeString(p)
+	}
+
+	require.NoError(t, VerifyFlatProof(root, Block("blockE"), 4, 5, proof))
+	require.Error(t, VerifyFlatProof(root, Block("blockE"), 3, 5, proof))
+	require.Error(t, VerifyFlatProof(root, Block("blockE"), 4, 6, proof[:2]))
+	require.Error(t, VerifyFlatProof(root, Block("blockE"), 5, 5, proof))
+	require.Error(t, VerifyFlatProof(root, Block("blockD"), 4, 5, proof))
+	require.Error(t, VerifyFlatProof(root, Block("blockE"), 4, 5, []TreeNode{proof[1], proof[0], proof[2]}))
+
+	for n := 1; n <= 17; n++ {
+		for _, sorted := range []bool{false, true} {
+			blocks := make([]Block, n)
+			for i := range blocks {
+				blocks[i] = Block(fmt.Sprintf("block-%d", i))
+			}
+			mt := NewMerkleTree(blocks...)
+			require.NoError(t, mt.Finalize(WithSortedPairs(sorted)))
+			root, err := mt.RootHash()
+			require.NoError(t, err)
+
+			for i, b := range blocks {
+				proof, err := mt.Proof(b)
+				require.NoError(t, err)
+				require.NoError(t, VerifyFlatProof(root, b, i, n, proof, WithSortedPairs(sorted)), fmt.Sprintf("blocks: %d, index: %d", n, i))
+			}
+		}
+	}
+
+	single := NewMerkleTree(Block("blockA"))
+	require.NoError(t, single.Finalize(WithSingleLeafRoot(true)))
+	singleRoot, _ := single.RootHash()
+	require.NoError(t, VerifyFlatProof(singleRoot, Block("blockA"), 0, 1, nil, WithSingleLeafRoot(true)))
+	require.Error(t, VerifyFlatProof(singleRoot, Block("blockA"), 0, 1, nil))
+}
+
+func TestSortedTree(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+
+		expected := NewSortedTree(blocks...)
+		require.NoError(t, expected.Finalize())
+		root, err := expected.RootHash()
+		require.NoError(t, err)
+
+		rng := rand.New(rand.NewSource(int64(n)))
+		for round := 0; round < 5; round++ {
+			shuffled := append([]Block(nil), blocks...)
+			rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			input := append([]Block(nil), shuffled...)
+
+			mt := NewMerkleTree(shuffled...)
+			require.NoError(t, mt.Finalize(WithSortedLeaves(true)))
+			shuffledRoot, err := mt.RootHash()
+			require.NoError(t, err)
+			require.Equal(t, root, shuffledRoot, fmt.Sprintf("blocks: %d, round: %d", n, round))
+			require.Equal(t, input, shuffled[:n], "the blocks of the caller must not be reordered")
+		}
+
+		// Indexes and proofs refer to the sorted positions.
+		for i, b := range sortedBlocks(n) {
+			idx, err := expected.IndexOf(b)
+			require.NoError(t, err)
+			require.Equal(t, i, idx)
+
+			proof, err := expected.Proof(b)
+			require.NoError(t, err)
+			require.NoError(t, VerifyFlatProof(root, b, i, n, proof))
+		}
+	}
+
+	unsorted := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	require.NoError(t, unsorted.Finalize())
+	sorted := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	require.NoError(t, sorted.Finalize(WithSortedLeaves(true)))
+	unsortedRoot, _ := unsorted.RootHash()
+	sortedRoot, _ := sorted.RootHash()
+	require.NotEqual(t, unsortedRoot, sortedRoot)
+}
+
+func TestSortedTreeDuplicates(t *testing.T) {
+	a := NewSortedTree(Block("blockA"), Block("blockB"), Block("blockA"), Block("blockC"))
+	require.NoError(t, a.Finalize())
+	b := NewSortedTree(Block("blockC"), Block("blockA"), Block("blockA"), Block("blockB"))
+	require.NoError(t, b.Finalize())
+
+	rootA, _ := a.RootHash()
+	rootB, _ := b.RootHash()
+	require.Equal(t, rootA, rootB)
+
+	// Duplicates sit next to each other; IndexOf returns the first one.
+	first, err := a.IndexOf(Block("blockA"))
+	require.NoError(t, err)
+	require.Equal(t, a.nodes[len(a.nodes)-len(a.blocks)+first], a.nodes[len(a.nodes)-len(a.blocks)+first+1])
+
+	// Multisets differing in multiplicity have different roots.
+	c := NewSortedTree(Block("blockA"), Block("blockB"), Block("blockC"), Block("blockC"))
+	require.NoError(t, c.Finalize())
+	rootC, _ := c.RootHash()
+	require.NotEqual(t, rootA, rootC)
+
+	dup := NewSortedTree(Block("blockA"), Block("blockB"), Block("blockA"))
+	require.Equal(t, &DuplicateError{First: 0, Second: 2}, dup.Finalize(WithRejectDuplicates(true)))
+
+	_, err = NewSortedTree(Block("blockA")).IndexOf(Block("blockA"))
+	require.Equal(t, ErrTreeNotFinalized, err)
+}
+
+func TestTerseErrors(t *testing.T) {
+	blocks := []Block{Block("blockA"), Block("blockB"), Block("blockC")}
+	verbose := NewMerkleTree(blocks...)
+	require.NoError(t, verbose.Finalize())
+	terse := NewMerkleTree(blocks...)
+	require.NoError(t, terse.Finalize(WithTerseErrors(true)))
+
+	proof, err := verbose.Proof(Block("blockB"))
+	require.NoError(t, err)
+	tampered := append([]TreeNode(nil), proof...)
+	tampered[0] = TreeNode(bytes.Repeat([]byte{0xab}, 32))
+
+	root, _ := verbose.RootHash()
+	secrets := []string{hex.EncodeToString(root), "ABABABAB", "abababab", "626C6F636B", "626c6f636b"}
+
+	require.Contains(t, verbose.Verify(Block("blockB"), tampered).Error(), strings.ToUpper(hex.EncodeToString(root)))
+	require.Contains(t, verbose.Verify(Block("blockB"), tampered[:1]).Error(), "626C6F636B42")
+
+	terseErrs := []error{
+		terse.Verify(Block("blockB"), tampered),
+		terse.Verify(Block("blockB"), tampered[:1]),
+		terse.Verify(Block("blockZ"), proof),
+		VerifyFlatProof(root, Block("blockB"), 1, 3, tampered, WithTerseErrors(true)),
+	}
+	p, err := terse.Prove(Block("blockB"))
+	require.NoError(t, err)
+	terseErrs = append(terseErrs, terse.VerifyProof(Block("blockC"), p))
+
+	for i, err := range terseErrs {
+		require.Error(t, err, fmt.Sprintf("error #%d", i))
+		for _, s := range secrets {
+			require.NotContains(t, strings.ToLower(err.Error()), strings.ToLower(s), fmt.Sprintf("error #%d", i))
+		}
+	}
+	require.Equal(t, "invalid proof for leaf 1 of 3", terseErrs[0].Error())
+}
+
+func TestPaddingDuplicateProofs(t *testing.T) {
+	for _, n := range []int{3, 5} {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		last := blocks[n-1]
+
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize())
+		require.Equal(t, n, tree.LeafCount())
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		// The proof of the last block targets its real position, whose
+		// right sibling is the padding duplicate.
+		proof, err := tree.Proof(last)
+		require.NoError(t, err)
+		want, err := tree.ProveNode(len(tree.nodes) - 2)
+		require.NoError(t, err)
+		require.Equal(t, want, proof)
+		require.NoError(t, tree.Verify(last, proof))
+		require.NoError(t, VerifyFlatProof(root, last, n-1, n, proof))
+
+		p, err := tree.Prove(last)
+		require.NoError(t, err)
+		require.Equal(t, n-1, p.Index)
+		require.Equal(t, n, p.Size)
+		require.False(t, p.Steps[0].Left)
+		require.NoError(t, tree.VerifyProof(last, p))
+
+		// A proof crafted against the padding position, as the last block
+		// of a tree of one more block, has the same root but is refused.
+		padding, err := tree.ProveNode(len(tree.nodes) - 1)
+		require.NoError(t, err)
+		require.Error(t, VerifyFlatProof(root, last, n, n, padding))
+		require.Error(t, VerifyFlatProof(root, last, n, n+1, padding))
+		require.Contains(t, VerifyFlatProof(root, last, n, n+1, padding).Error(), "padding duplicate")
+		require.Contains(t, VerifyFlatProof(root, last, n, n+1, padding, WithTerseErrors(true)).Error(), "padding duplicate")
+
+		forged := *p
+		forged.Index, forged.Size = n, n+1
+		forged.Steps = append([]ProofStep(nil), p.Steps...)
+		forged.Steps[0].Left = true
+		require.ErrorIs(t, tree.VerifyProof(last, &forged), ErrInvalidProof)
+		forged.Size = n
+		require.ErrorIs(t, tree.VerifyProof(last, &forged), ErrInvalidProof)
+	}
+
+	unfinalized := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	require.Equal(t, 3, unfinalized.LeafCount())
+}
+
+func TestVerifyLeafHash(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		for n := 1; n <= 9; n++ {
+			blocks := make([]Block, n)
+			for i := range blocks {
+				blocks[i] = Block(fmt.Sprintf("block-%d", i))
+			}
+			tree := NewMerkleTree(blocks...)
+			require.NoError(t, tree.Finalize(WithSortedPairs(sorted)))
+			root, err := tree.RootHash()
+			require.NoError(t, err)
+
+			for i, b := range blocks {
+				proof, err := tree.Proof(b)
+				require.NoError(t, err)
+				leaf := hashNode(b, false)
+
+				require.NoError(t, tree.VerifyLeafHash(leaf, proof))
+				require.NoError(t, VerifyFlatLeafHash(root, leaf, i, n, proof, WithSortedPairs(sorted)))
+
+				// The plain SHA-256 of the block lacks the 0x00 leaf prefix.
+				raw := sha256.Sum256(b)
+				require.Error(t, tree.VerifyLeafHash(raw[:], proof))
+				require.Error(t, VerifyFlatLeafHash(root, raw[:], i, n, proof, WithSortedPairs(sorted)))
+			}
+		}
+	}
+
+	tree := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	require.ErrorIs(t, tree.VerifyLeafHash(hashNode(Block("a"), false), nil), ErrTreeNotFinalized)
+	require.NoError(t, tree.Finalize())
+	root, _ := tree.RootHash()
+
+	proof, err := tree.Proof(Block("b"))
+	require.NoError(t, err)
+	leaf := hashNode(Block("b"), false)
+	require.Error(t, VerifyFlatLeafHash(root, leaf, 0, 3, proof))
+	require.Error(t, VerifyFlatLeafHash(root, leaf, 1, 3, proof[:1]))
+	require.Error(t, VerifyFlatLeafHash(root, leaf[:31], 1, 3, proof))
+	require.EqualError(t, tree.VerifyLeafHash(make([]byte, 32), proof), "leaf hash does not exist: "+strings.Repeat("00", 32))
+
+	// Internal nodes are not leaves, even when their hash is given.
+	require.Error(t, tree.VerifyLeafHash(tree.nodes[1], proof[1:]))
+}
+
+func TestDistinctPadding(t *testing.T) {
+	a, b, c := Block("A"), Block("B"), Block("C")
+	root := func(blocks []Block, opts ...Option) []byte {
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize(opts...))
+		r, err := tree.RootHash()
+		require.NoError(t, err)
+		return r
+	}
+
+	odd, even := []Block{a, b, c}, []Block{a, b, c, c}
+	require.Equal(t, root(odd), root(even))
+	require.NotEqual(t, root(odd, WithDistinctPadding(true)), root(even, WithDistinctPadding(true)))
+	require.Equal(t, root(even), root(even, WithDistinctPadding(true)))
+	require.NotEqual(t, root(odd), root(odd, WithDistinctPadding(true)))
+
+	for n := 1; n <= 9; n++ {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize(WithDistinctPadding(true)))
+		r, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i, block := range blocks {
+			proof, err := tree.Proof(block)
+			require.NoError(t, err)
+			require.NoError(t, tree.Verify(block, proof))
+			require.NoError(t, VerifyFlatProof(r, block, i, n, proof, WithDistinctPadding(true)))
+
+			p, err := tree.Prove(block)
+			require.NoError(t, err)
+			require.NoError(t, tree.VerifyProof(block, p))
+			require.NoError(t, p.Verify(r))
+		}
+
+		rp, err := tree.ProveRange(0, n)
+		require.NoError(t, err)
+		require.True(t, rp.DistinctPadding)
+		leaves := make([][]byte, n)
+		for i := range blocks {
+			leaves[i] = blocks[i]
+		}
+		require.NoError(t, VerifyRange(r, 0, leaves, rp))
+		if n%2 == 1 && n > 1 {
+			rp.DistinctPadding = false
+			require.ErrorIs(t, VerifyRange(r, 0, leaves, rp), ErrInvalidProof)
+		}
+
+		if n > 1 {
+			op, err := tree.ProveOrder(blocks[0], blocks[n-1])
+			require.NoError(t, err)
+			require.NoError(t, VerifyOrder(r, blocks[0], blocks[n-1], op))
+		}
+
+		s, err := tree.Snapshot()
+		require.NoError(t, err)
+		require.True(t, s.DistinctPadding)
+		restored, err := RestoreFlatMerkleTree(s)
+		require.NoError(t, err)
+		got, _ := restored.RootHash()
+		require.Equal(t, r, got)
+	}
+
+	// The real last block of [A, B, C, C] is proven and verified under the
+	// option, its padding check being no longer needed.
+	tree := NewMerkleTree(even...)
+	require.NoError(t, tree.Finalize(WithDistinctPadding(true)))
+	r, _ := tree.RootHash()
+	proof, err := tree.ProveNode(len(tree.nodes) - 1)
+	require.NoError(t, err)
+	require.NoError(t, VerifyFlatProof(r, c, 3, 4, proof, WithDistinctPadding(true)))
+	require.Error(t, VerifyFlatProof(r, c, 3, 4, proof))
+}
+
+func TestFlatTreePrefixes(t *testing.T) {
+	blocks := []Block{Block("a"), Block("b"), Block("c"), Block("d"), Block("e")}
+	opts := []Option{WithLeafPrefix([]byte("ProtocolName:leaf")), WithNodePrefix([]byte("ProtocolName:node"))}
+
+	plain := NewMerkleTree(blocks...)
+	require.NoError(t, plain.Finalize())
+	defaults := NewMerkleTree(blocks...)
+	require.NoError(t, defaults.Finalize(WithLeafPrefix([]byte{0x00}), WithNodePrefix([]byte{0x01})))
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(opts...))
+
+	plainRoot, _ := plain.RootHash()
+	defaultRoot, _ := defaults.RootHash()
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, plainRoot, defaultRoot)
+	require.NotEqual(t, plainRoot, root)
+
+	leaf := sha256.Sum256([]byte("ProtocolName:leafa"))
+	require.Equal(t, leaf[:], []byte(mt.nodes[len(mt.nodes)-len(mt.blocks)]))
+
+	for i, b := range blocks {
+		proof, err := mt.Proof(b)
+		require.NoError(t, err)
+		require.NoError(t, mt.Verify(b, proof))
+		require.Error(t, plain.Verify(b, proof))
+		require.NoError(t, VerifyFlatProof(root, b, i, len(blocks), proof, opts...))
+		require.Error(t, VerifyFlatProof(root, b, i, len(blocks), proof))
+
+		p, err := mt.Prove(b)
+		require.NoError(t, err)
+		require.Equal(t, []byte("ProtocolName:leaf"), p.LeafPrefix)
+		require.Equal(t, []byte("ProtocolName:node"), p.NodePrefix)
+		require.NoError(t, p.Verify(root))
+		require.ErrorIs(t, p.Verify(plainRoot), ErrInvalidProof)
+		h, err := p.hashLeaf(b, nil)
+		require.NoError(t, err)
+		require.Equal(t, p.LeafHash, h)
+
+		p, err = plain.Prove(b)
+		require.NoError(t, err)
+		require.Nil(t, p.LeafPrefix)
+		require.Nil(t, p.NodePrefix)
+	}
+
+	rp, err := mt.ProveRange(1, 3)
+	require.NoError(t, err)
+	require.NoError(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2]}, rp))
+	rp.NodePrefix = nil
+	require.ErrorIs(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2]}, rp), ErrInvalidProof)
+
+	op, err := mt.ProveOrder(blocks[0], blocks[3])
+	require.NoError(t, err)
+	require.NoError(t, VerifyOrder(root, blocks[0], blocks[3], op))
+
+	sorted := NewSortedTree(blocks[:4]...)
+	require.NoError(t, sorted.Finalize(opts...))
+	sortedRoot, _ := sorted.RootHash()
+	ap, err := sorted.ProveAbsence(Block("z"))
+	require.NoError(t, err)
+	require.NoError(t, VerifyAbsence(sortedRoot, Block("z"), ap))
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	got, _ := restored.RootHash()
+	require.Equal(t, root, got)
+}
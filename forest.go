@@ -0,0 +1,159 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrTreeNotInForest is returned for a tree name a Forest does not hold.
+var ErrTreeNotInForest = errors.New("tree is not in the forest")
+
+// Forest commits to the roots of many named trees, such as the per-tenant
+// trees of a sharded dataset, under a single super-root: the root of a
+// FlatMerkleTree over the (name, root) pairs, ordered by name.
+//
+// The leaf of a pair is the root of the tree salted, as InsertSalted does,
+// with the name encoded as the length of the name as an unsigned varint, as
+// encoding/binary's PutUvarint writes it, then the name: its leaf hash is
+// H(0x00 || uvarint(len(name)) || name || root), which no other pair encodes
+// to. The proof of a tree, as ProveTree returns it, is the Proof of that leaf,
+// its Salt being the encoded name. It chains with the proofs of the leaves of
+// the tree: VerifyChained checks a ChainedProof whose links are the proof of a
+// leaf in the tree then the one of the tree in the forest against the
+// super-root, and ForestTreeName returns the name the last link is for.
+type Forest struct {
+	opts  []Option
+	roots map[string][]byte
+	// tree is the tree over the pairs, nil since they changed.
+	tree  *FlatMerkleTree
+	names []string
+}
+
+// NewForest returns an empty Forest whose tree over the pairs is finalized
+// with opts. Options reordering or rejecting its leaves, WithSortedLeaves,
+// WithRejectDuplicates and WithBranchingFactor, are overridden.
+func NewForest(opts ...Option) *Forest {
+	return &Forest{opts: opts, roots: map[string][]byte{}}
+}
+
+// AddTree sets the root of the tree name, adding the tree to the forest or
+// replacing the root it had.
+func (f *Forest) AddTree(name string, root []byte) error {
+	if len(root) == 0 {
+		return fmt.Errorf("empty root for tree %q", name)
+	}
+
+	f.roots[name] = append([]byte(nil), root...)
+	f.tree = nil
+
+	return nil
+}
+
+// RemoveTree removes the tree name from the forest.
+func (f *Forest) RemoveTree(name string) error {
+	if _, ok := f.roots[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrTreeNotInForest, name)
+	}
+
+	delete(f.roots, name)
+	f.tree = nil
+
+	return nil
+}
+
+// Len returns the number of trees of the forest.
+func (f *Forest) Len() int {
+	return len(f.roots)
+}
+
+// Root returns the super-root of the forest, which must hold a tree.
+func (f *Forest) Root() ([]byte, error) {
+	tree, err := f.build()
+	if err != nil {
+		return nil, err
+	}
+
+	return tree.RootHash()
+}
+
+// RootHash returns Root, so that a Forest is a Rooter.
+func (f *Forest) RootHash() ([]byte, error) {
+	return f.Root()
+}
+
+// ProveTree returns the proof of the root of the tree name in the forest.
+func (f *Forest) ProveTree(name string) (*Proof, error) {
+	if _, ok := f.roots[name]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTreeNotInForest, name)
+	}
+
+	tree, err := f.build()
+	if err != nil {
+		return nil, err
+	}
+
+	i := sort.SearchStrings(f.names, name)
+	return tree.proofAt(len(tree.nodes) - len(tree.blocks) + i), nil
+}
+
+// build returns the tree over the pairs, finalizing it if they changed.
+func (f *Forest) build() (*FlatMerkleTree, error) {
+	if f.tree != nil {
+		return f.tree, nil
+	}
+	if len(f.roots) == 0 {
+		return nil, ErrEmptyMerkleTree
+	}
+
+	names := make([]string, 0, len(f.roots))
+	for name := range f.roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := NewMerkleTree()
+	for _, name := range names {
+		if err := tree.InsertSalted(f.roots[name], forestName(name)); err != nil {
+			return nil, err
+		}
+	}
+	opts := append(append([]Option(nil), f.opts...), WithSortedLeaves(false), WithRejectDuplicates(false), WithBranchingFactor(2))
+	if err := tree.Finalize(opts...); err != nil {
+		return nil, err
+	}
+	f.tree, f.names = tree, names
+
+	return tree, nil
+}
+
+// forestName returns the encoding of name salting the leaf of its tree.
+func forestName(name string) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varint[:], uint64(len(name)))
+
+	return append(varint[:n:n], name...)
+}
+
+// ForestTreeName returns the name of the tree the proof p, as returned by
+// Forest.ProveTree, is for, decoded from its Salt.
+func ForestTreeName(p *Proof) (string, error) {
+	size, n := binary.Uvarint(p.Salt)
+	if n <= 0 || size != uint64(len(p.Salt)-n) {
+		return "", fmt.Errorf("proof salt %X is not a forest tree name", p.Salt)
+	}
+
+	return string(p.Salt[n:]), nil
+}
+
+// VerifyForestTree checks that p, as returned by Forest.ProveTree, shows root
+// to be the root of the tree name in the forest of superRoot.
+func VerifyForestTree(superRoot []byte, name string, root []byte, p *Proof) error {
+	if !bytes.Equal(p.Salt, forestName(name)) {
+		return fmt.Errorf("invalid forest proof: proof of another tree than %q: %w", name, ErrInvalidProof)
+	}
+
+	return VerifyChained(superRoot, root, &ChainedProof{Links: []*Proof{p}})
+}
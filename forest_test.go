@@ -0,0 +1,142 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tenantTrees returns finalized trees of a few blocks per tenant.
+func tenantTrees(t *testing.T, names ...string) map[string]*FlatMerkleTree {
+	trees := map[string]*FlatMerkleTree{}
+	for i, name := range names {
+		tree := NewMerkleTree()
+		for j := 0; j <= i; j++ {
+			tree.Insert(Block(fmt.Sprintf("%s block %d", name, j)))
+		}
+		require.NoError(t, tree.Finalize())
+		trees[name] = tree
+	}
+
+	return trees
+}
+
+func TestForestRoot(t *testing.T) {
+	a, b := sum256([]byte("root a")), sum256([]byte("root b"))
+	forest := NewForest()
+	require.NoError(t, forest.AddTree("tenant-b", b))
+	require.NoError(t, forest.AddTree("a", a))
+
+	leafA := sum256([]byte{leafNodePrefix, 1, 'a'}, a)
+	leafB := sum256([]byte{leafNodePrefix, 8}, []byte("tenant-b"), b)
+	root, err := forest.Root()
+	require.NoError(t, err)
+	require.Equal(t, sum256([]byte{internalNodePrefix}, leafA, leafB), root)
+
+	// The order trees are added in does not matter.
+	other := NewForest()
+	require.NoError(t, other.AddTree("a", a))
+	require.NoError(t, other.AddTree("tenant-b", b))
+	otherRoot, err := other.Root()
+	require.NoError(t, err)
+	require.Equal(t, root, otherRoot)
+
+	// Names are length-prefixed, so moving bytes from a name to a root does
+	// not give the same leaf.
+	ab := NewForest()
+	require.NoError(t, ab.AddTree("ab", []byte("c")))
+	a2 := NewForest()
+	require.NoError(t, a2.AddTree("a", []byte("bc")))
+	abRoot, err := ab.Root()
+	require.NoError(t, err)
+	a2Root, err := a2.Root()
+	require.NoError(t, err)
+	require.NotEqual(t, abRoot, a2Root)
+}
+
+func TestForestProofs(t *testing.T) {
+	names := []string{"acme", "globex", "initech", "umbrella", "hooli"}
+	trees := tenantTrees(t, names...)
+
+	forest := NewForest()
+	for name, tree := range trees {
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		require.NoError(t, forest.AddTree(name, root))
+	}
+	require.Equal(t, len(names), forest.Len())
+	superRoot, err := forest.Root()
+	require.NoError(t, err)
+
+	for name, tree := range trees {
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		p, err := forest.ProveTree(name)
+		require.NoError(t, err)
+		require.NoError(t, VerifyForestTree(superRoot, name, root, p))
+
+		decoded, err := ForestTreeName(p)
+		require.NoError(t, err)
+		require.Equal(t, name, decoded)
+
+		// The proof of a block of the tree chains with the one of the tree.
+		block := Block(fmt.Sprintf("%s block 0", name))
+		leafProof, err := tree.Prove(block)
+		require.NoError(t, err)
+		chained := &ChainedProof{Links: []*Proof{leafProof, p}}
+		require.NoError(t, VerifyChained(superRoot, block, chained))
+		require.Error(t, VerifyChained(superRoot, Block("forged block"), chained))
+	}
+
+	acme, err := forest.ProveTree("acme")
+	require.NoError(t, err)
+	acmeRoot, err := trees["acme"].RootHash()
+	require.NoError(t, err)
+	globexRoot, err := trees["globex"].RootHash()
+	require.NoError(t, err)
+	require.ErrorIs(t, VerifyForestTree(superRoot, "globex", acmeRoot, acme), ErrInvalidProof)
+	require.ErrorIs(t, VerifyForestTree(superRoot, "acme", globexRoot, acme), ErrInvalidProof)
+
+	_, err = forest.ProveTree("missing")
+	require.ErrorIs(t, err, ErrTreeNotInForest)
+	_, err = ForestTreeName(&Proof{Salt: []byte{5, 'a'}})
+	require.Error(t, err)
+}
+
+func TestForestUpdates(t *testing.T) {
+	forest := NewForest()
+	_, err := forest.Root()
+	require.ErrorIs(t, err, ErrEmptyMerkleTree)
+	require.Error(t, forest.AddTree("empty", nil))
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, forest.AddTree(fmt.Sprintf("tenant %d", i), sum256([]byte{byte(i)})))
+	}
+	before, err := forest.Root()
+	require.NoError(t, err)
+	p, err := forest.ProveTree("tenant 3")
+	require.NoError(t, err)
+
+	require.NoError(t, forest.AddTree("tenant 3", sum256([]byte("updated"))))
+	after, err := forest.Root()
+	require.NoError(t, err)
+	require.NotEqual(t, before, after)
+	require.Error(t, VerifyForestTree(after, "tenant 3", sum256([]byte{3}), p))
+
+	p, err = forest.ProveTree("tenant 3")
+	require.NoError(t, err)
+	require.NoError(t, VerifyForestTree(after, "tenant 3", sum256([]byte("updated")), p))
+
+	require.NoError(t, forest.RemoveTree("tenant 3"))
+	require.ErrorIs(t, forest.RemoveTree("tenant 3"), ErrTreeNotInForest)
+	require.Equal(t, 4, forest.Len())
+	_, err = forest.ProveTree("tenant 3")
+	require.ErrorIs(t, err, ErrTreeNotInForest)
+
+	p, err = forest.ProveTree("tenant 4")
+	require.NoError(t, err)
+	removed, err := forest.Root()
+	require.NoError(t, err)
+	require.NoError(t, VerifyForestTree(removed, "tenant 4", sum256([]byte{4}), p))
+}
@@ -2,10 +2,20 @@ module github.com/evalir/merkle-go
 
 go 1.18
 
-require github.com/stretchr/testify v1.7.1
+require (
+	github.com/cosmos/ics23/go v0.10.0
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.17.0
+	golang.org/x/mod v0.12.0
+	google.golang.org/protobuf v1.31.0
+)
 
 require (
+	github.com/cosmos/gogoproto v1.4.3 // indirect
 	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -0,0 +1,43 @@
+package merklego
+
+import (
+	"hash"
+	"sync"
+)
+
+// hasherPool hands out hashers of a single hash strategy, keyed with HMAC for
+// trees built with WithHMACKey and truncated for those built with
+// WithDigestSize, resetting them when they are returned so building a tree
+// does not allocate a hasher per node. It is named after the plain strategy.
+type hasherPool struct {
+	pool sync.Pool
+	size int
+	name string
+	// truncated is the size of the truncated digests, 0 for full ones.
+	truncated int
+}
+
+// newHasherPool returns the pool of the hash function of c, which must be
+// set, as c hashes at the time of the call.
+func newHasherPool(c *config) *hasherPool {
+	p := &hasherPool{name: hashName(c.hashFunc), truncated: c.truncatedSize()}
+	hashFunc := c.hashWith(c.hashFunc)
+	p.pool.New = func() interface{} {
+		return hashFunc()
+	}
+
+	h := p.get()
+	p.size = h.Size()
+	p.put(h)
+
+	return p
+}
+
+func (p *hasherPool) get() hash.Hash {
+	return p.pool.Get().(hash.Hash)
+}
+
+func (p *hasherPool) put(h hash.Hash) {
+	h.Reset()
+	p.pool.Put(h)
+}
@@ -0,0 +1,149 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// hashProbe is hashed by every candidate strategy to recognize a hash function
+// by its output.
+var hashProbe = []byte("merkle-go hash probe")
+
+type namedHash struct {
+	name  string
+	fn    func() hash.Hash
+	probe []byte
+}
+
+var (
+	hashesMu sync.RWMutex
+	hashes   []namedHash
+)
+
+func init() {
+	RegisterHash("sha256", sha256.New)
+	RegisterHash("sha224", sha256.New224)
+	RegisterHash("sha512", sha512.New)
+	RegisterHash("sha384", sha512.New384)
+	RegisterHash("sha512_256", sha512.New512_256)
+	RegisterHash("sha3-256", sha3.New256)
+	RegisterHash("blake2b-256", newBlake2b256)
+	RegisterHash("tiger", newTiger)
+}
+
+// newBlake2b256 returns an unkeyed BLAKE2b-256 hasher.
+func newBlake2b256() hash.Hash {
+	h, _ := blake2b.New256(nil)
+	return h
+}
+
+// UnknownHashError is returned when a hash function is looked up by a name
+// that was never registered with RegisterHash, be it given by the caller or
+// read from a snapshot, a proof or a tree head. Callers can register the
+// function, from a plugin for instance, and try again.
+type UnknownHashError struct {
+	Name string
+	// Err is the invalid encoding error of the decoder that read Name, nil
+	// for a name given by the caller.
+	Err error
+}
+
+func (e *UnknownHashError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: unknown hash function %q", e.Err, e.Name)
+	}
+
+	return fmt.Sprintf("error: unknown hash function %q", e.Name)
+}
+
+// Unwrap returns the invalid encoding error of the decoder, if any.
+func (e *UnknownHashError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterHash makes hashStrategy known under name, so the proofs of trees
+// hashing with it name it and can be verified on their own. Registering a name
+// again replaces its strategy.
+func RegisterHash(name string, hashStrategy func() hash.Hash) {
+	h := hashStrategy()
+	h.Write(hashProbe)
+	nh := namedHash{name: name, fn: hashStrategy, probe: h.Sum(nil)}
+
+	hashesMu.Lock()
+	defer hashesMu.Unlock()
+
+	for i := range hashes {
+		if hashes[i].name == name {
+			hashes[i] = nh
+			return
+		}
+	}
+	hashes = append(hashes, nh)
+}
+
+// hashByName returns the strategy registered under name.
+func hashByName(name string) (func() hash.Hash, bool) {
+	hashesMu.RLock()
+	defer hashesMu.RUnlock()
+
+	for _, nh := range hashes {
+		if nh.name == name {
+			return nh.fn, true
+		}
+	}
+
+	return nil, false
+}
+
+// LookupHash returns the strategy registered under name, or an
+// UnknownHashError. Decoders of the formats naming a hash function, in this
+// package and in its subpackages, use it to refuse input they could not check.
+func LookupHash(name string) (func() hash.Hash, error) {
+	hashStrategy, ok := hashByName(name)
+	if !ok {
+		return nil, &UnknownHashError{Name: name}
+	}
+
+	return hashStrategy, nil
+}
+
+// checkHashName returns an UnknownHashError wrapping err, the invalid
+// encoding error of the decoder, if name, read from an encoding, is not
+// registered. The empty name, recorded for functions that were not registered,
+// is accepted.
+func checkHashName(name string, err error) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := hashByName(name); !ok {
+		return &UnknownHashError{Name: name, Err: err}
+	}
+
+	return nil
+}
+
+// hashName returns the name hashStrategy was registered under, recognizing it
+// by its output, or "" if it is unknown.
+func hashName(hashStrategy func() hash.Hash) string {
+	h := hashStrategy()
+	h.Write(hashProbe)
+	probe := h.Sum(nil)
+
+	hashesMu.RLock()
+	defer hashesMu.RUnlock()
+
+	for _, nh := range hashes {
+		if bytes.Equal(nh.probe, probe) {
+			return nh.name
+		}
+	}
+
+	return ""
+}
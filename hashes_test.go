@@ -0,0 +1,221 @@
+package merklego
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisteredHashes(t *testing.T) {
+	for name, abc := range map[string]string{
+		"sha256":      "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha512_256":  "53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23",
+		"sha3-256":    "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+		"blake2b-256": "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319",
+		"tiger":       "2aab1484e8c158f2bfb8c5ff41b57a525129131c957b5f93",
+	} {
+		hashStrategy, err := LookupHash(name)
+		require.NoError(t, err, name)
+		h := hashStrategy()
+		h.Write([]byte("abc"))
+		require.Equal(t, abc, hex.EncodeToString(h.Sum(nil)), name)
+		require.Equal(t, name, hashName(hashStrategy))
+	}
+
+	_, err := LookupHash("blake3")
+	var unknown *UnknownHashError
+	require.True(t, errors.As(err, &unknown))
+	require.Equal(t, "blake3", unknown.Name)
+	require.EqualError(t, err, `error: unknown hash function "blake3"`)
+}
+
+func TestNewMerkleTreeNamed(t *testing.T) {
+	blocks := []Block{Block("a"), Block("b"), Block("c")}
+
+	mt, err := NewMerkleTreeNamed("blake2b-256", blocks...)
+	require.NoError(t, err)
+	require.NoError(t, mt.Finalize())
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+
+	sha, err := NewMerkleTreeNamed("sha256", blocks...)
+	require.NoError(t, err)
+	require.NoError(t, sha.Finalize())
+	shaRoot, err := sha.RootHash()
+	require.NoError(t, err)
+	require.NotEqual(t, shaRoot, root)
+	plain := NewMerkleTree(blocks...)
+	require.NoError(t, plain.Finalize())
+	plainRoot, err := plain.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, plainRoot, shaRoot)
+
+	p, err := mt.Prove(blocks[1])
+	require.NoError(t, err)
+	require.Equal(t, "blake2b-256", p.Hash)
+	require.NoError(t, p.Verify(root))
+	p.Hash = "blake3"
+	var unknown *UnknownHashError
+	require.True(t, errors.As(p.Verify(root), &unknown))
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, "blake2b-256", s.Hash)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	got, err := restored.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, root, got)
+	s.Hash = "blake3"
+	_, err = RestoreFlatMerkleTree(s)
+	require.True(t, errors.As(err, &unknown))
+
+	signer, _ := testSigningKeys(t)
+	sr, err := mt.SignRoot(signer, crypto.Hash(0))
+	require.NoError(t, err)
+	require.Equal(t, "blake2b-256", sr.Hash)
+
+	_, err = NewMerkleTreeNamed("blake3", blocks...)
+	require.True(t, errors.As(err, &unknown))
+	require.Equal(t, "blake3", unknown.Name)
+}
+
+func TestMarshalJSONHashFunction(t *testing.T) {
+	tree, err := NewTreeWithOptions(paddingContents(5), WithHashStrategy(sha3.New256))
+	require.NoError(t, err)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"hash_function":"sha3-256"`)
+
+	var decoded MerkleTree
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, tree.MerkleRoot(), decoded.MerkleRoot())
+	require.Equal(t, "sha3-256", decoded.hashers.name)
+	leaf := decoded.Leaves[2].Hash
+	p, err := decoded.Prove(leaf)
+	require.NoError(t, err)
+	require.NoError(t, decoded.VerifyProof(leaf, p))
+
+	same, _ := NewTreeWithOptions(paddingContents(1), WithHashStrategy(sha3.New256))
+	require.NoError(t, json.Unmarshal(data, same))
+
+	other, _ := NewTree(paddingContents(1))
+	require.ErrorIs(t, json.Unmarshal(data, other), ErrInvalidTreeJSON)
+
+	var unknown *UnknownHashError
+	var fresh MerkleTree
+	err = json.Unmarshal([]byte(strings.Replace(string(data), "sha3-256", "blake3", 1)), &fresh)
+	require.True(t, errors.As(err, &unknown))
+	require.Nil(t, fresh.Root)
+}
+
+func TestSerializedHashNames(t *testing.T) {
+	for _, name := range []string{"sha256", "sha3-256", "blake2b-256"} {
+		hashStrategy, err := LookupHash(name)
+		require.NoError(t, err)
+		tree, err := NewTreeWithOptions(paddingContents(5), WithHashStrategy(hashStrategy))
+		require.NoError(t, err)
+		root := tree.MerkleRoot()
+		p, err := tree.Prove(tree.Leaves[3].Hash)
+		require.NoError(t, err)
+		require.Equal(t, name, p.Hash)
+
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, name, decoded.Hash)
+		require.NoError(t, decoded.Verify(root))
+
+		data, err = json.Marshal(p)
+		require.NoError(t, err)
+		decoded = Proof{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.NoError(t, decoded.Verify(root))
+
+		h, err := tree.Head()
+		require.NoError(t, err)
+		require.Equal(t, name, h.Hash)
+		data, err = h.MarshalBinary()
+		require.NoError(t, err)
+		var head TreeHead
+		require.NoError(t, head.UnmarshalBinary(data))
+		require.True(t, h.Equal(head))
+		require.NoError(t, head.VerifyProof(&decoded))
+		data, err = json.Marshal(h)
+		require.NoError(t, err)
+		head = TreeHead{}
+		require.NoError(t, json.Unmarshal(data, &head))
+		require.True(t, h.Equal(head))
+
+		data, err = json.Marshal(tree)
+		require.NoError(t, err)
+		var snapshot MerkleTree
+		require.NoError(t, json.Unmarshal(data, &snapshot))
+		require.Equal(t, root, snapshot.MerkleRoot())
+		require.Equal(t, name, snapshot.hashers.name)
+
+		flat, err := NewMerkleTreeNamed(name, Block("a"), Block("b"), Block("c"))
+		require.NoError(t, err)
+		require.NoError(t, flat.Finalize())
+		s, err := flat.Snapshot()
+		require.NoError(t, err)
+		data, err = json.Marshal(s)
+		require.NoError(t, err)
+		var decodedSnapshot FlatSnapshot
+		require.NoError(t, json.Unmarshal(data, &decodedSnapshot))
+		restored, err := RestoreFlatMerkleTree(&decodedSnapshot)
+		require.NoError(t, err)
+		h, err = restored.Head()
+		require.NoError(t, err)
+		require.Equal(t, name, h.Hash)
+	}
+
+	tree, err := NewTreeWithOptions(paddingContents(3), WithHashStrategy(sha3.New256))
+	require.NoError(t, err)
+	p, err := tree.Prove(tree.Leaves[0].Hash)
+	require.NoError(t, err)
+	h, err := tree.Head()
+	require.NoError(t, err)
+	p.Hash, h.Hash = "blake3", "blake3"
+
+	var unknown *UnknownHashError
+	data, err := p.MarshalBinary()
+	require.NoError(t, err)
+	err = (&Proof{}).UnmarshalBinary(data)
+	require.True(t, errors.As(err, &unknown))
+	require.ErrorIs(t, err, ErrInvalidProofEncoding)
+	s, err := p.EncodeString()
+	require.NoError(t, err)
+	_, err = DecodeProofString(s)
+	require.True(t, errors.As(err, &unknown))
+	data, err = json.Marshal(p)
+	require.NoError(t, err)
+	err = (&Proof{}).UnmarshalJSON(data)
+	require.True(t, errors.As(err, &unknown))
+	require.ErrorIs(t, err, ErrInvalidProofEncoding)
+
+	data, err = h.MarshalBinary()
+	require.NoError(t, err)
+	err = (&TreeHead{}).UnmarshalBinary(data)
+	require.True(t, errors.As(err, &unknown))
+	require.ErrorIs(t, err, ErrInvalidTreeHead)
+	require.Equal(t, "blake3", unknown.Name)
+	data, err = json.Marshal(h)
+	require.NoError(t, err)
+	err = (&TreeHead{}).UnmarshalJSON(data)
+	require.True(t, errors.As(err, &unknown))
+	require.ErrorIs(t, err, ErrInvalidTreeHead)
+
+	// A head only vouches for proofs of its hash function.
+	h.Hash = "sha256"
+	p.Hash = "sha3-256"
+	require.ErrorIs(t, h.VerifyProof(p), ErrInvalidProof)
+}
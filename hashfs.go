@@ -0,0 +1,137 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// HashFSChunkSize is the size of the blocks HashFS splits the content of
+// every file into.
+const HashFSChunkSize = 1 << 20
+
+// The kinds of the entries of HashFS, the first byte of their encoding.
+const (
+	fsFileEntry     = 'f'
+	fsEmptyDirEntry = 'd'
+)
+
+// HashFS commits to the files of fsys, their paths and contents, and returns
+// the root and the FlatMerkleTree of one block per entry, finalized with opts,
+// which also hash the contents and cannot be ones NewMerkleTreeFromReader
+// refuses. The root only depends on the names and the bytes of the files, and
+// is the same on every machine.
+//
+// The entries are ordered by path, compared as bytes, each path being the
+// slash-separated one of fsys, and the block of a regular file is
+//
+//	'f' || uvarint(len(path)) || path || uvarint(size) || content root
+//
+// the content root being the root of the tree of its blocks of
+// HashFSChunkSize bytes, as NewMerkleTreeFromReader builds it, or of a single
+// empty block for an empty file. Directories are committed by the paths of
+// the files they hold, and an empty directory, which has none, by the block
+// 'd' || uvarint(len(path)) || path. The root of fsys itself has no entry,
+// and an empty fsys has no root. Modes and times are not committed.
+//
+// Symbolic links, whose targets fs.FS cannot read, and other irregular files
+// are refused rather than left out of the commitment.
+func HashFS(fsys fs.FS, opts ...Option) ([]byte, *FlatMerkleTree, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if err := c.checkStreamed(); err != nil {
+		return nil, nil, err
+	}
+
+	type fsEntry struct {
+		path  string
+		block []byte
+	}
+	var entries []fsEntry
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		var entry []byte
+		switch {
+		case d.IsDir():
+			if path == "." {
+				return nil
+			}
+			children, err := fs.ReadDir(fsys, path)
+			if err != nil || len(children) > 0 {
+				return err
+			}
+			entry = lengthPrefixed([]byte{fsEmptyDirEntry}, []byte(path))
+		case d.Type().IsRegular():
+			if entry, err = hashFSFile(fsys, path, opts); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cannot hash %s: not a regular file or a directory", path)
+		}
+		entries = append(entries, fsEntry{path: path, block: entry})
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil, ErrEmptyMerkleTree
+	}
+
+	// WalkDir orders the entries of each directory by name, so "a/b" comes
+	// before "a.txt".
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	tree := NewMerkleTree()
+	for _, e := range entries {
+		if err := tree.Insert(e.block); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tree.Finalize(opts...); err != nil {
+		return nil, nil, err
+	}
+
+	root, err := tree.RootHash()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return root, tree, nil
+}
+
+// hashFSFile returns the block of the regular file at path of fsys.
+func hashFSFile(fsys fs.FS, path string, opts []Option) ([]byte, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	content, size, err := NewMerkleTreeFromReader(f, HashFSChunkSize, opts...)
+	if errors.Is(err, ErrEmptyMerkleTree) {
+		content = NewMerkleTree(Block{})
+		err = content.Finalize(opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash %s: %w", path, err)
+	}
+
+	var varint [binary.MaxVarintLen64]byte
+	var entry bytes.Buffer
+	entry.Write(lengthPrefixed([]byte{fsFileEntry}, []byte(path)))
+	entry.Write(varint[:binary.PutUvarint(varint[:], uint64(size))])
+	entry.Write(content.root)
+
+	return entry.Bytes(), nil
+}
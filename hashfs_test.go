@@ -0,0 +1,103 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fsFixture returns a small directory tree with nested, empty and
+// multi-block files and an empty directory.
+func fsFixture() fstest.MapFS {
+	return fstest.MapFS{
+		"README.md":          {Data: []byte("# fixture\n")},
+		"a.txt":              {Data: []byte("a")},
+		"a/b":                {Data: []byte("b")},
+		"assets":             {Mode: fs.ModeDir | 0o755},
+		"empty.txt":          {Data: []byte{}},
+		"src/main.go":        {Data: []byte("package main\n\nfunc main() {}\n"), Mode: 0o755},
+		"src/util/large.bin": {Data: bytes.Repeat([]byte{0xab}, 2*HashFSChunkSize+17)},
+	}
+}
+
+func TestHashFSGolden(t *testing.T) {
+	root, tree, err := HashFS(fsFixture())
+	require.NoError(t, err)
+	require.Equal(t, 7, tree.LeafCount())
+	require.Equal(t, "24e8c2bb1d36fa32f314c978648a63d02b7bce04572ef920e2bf2ea35948e6a5", hex.EncodeToString(root))
+
+	root, _, err = HashFS(fsFixture(), WithDistinctPadding(true), WithLengthPrefixedLeaves(true))
+	require.NoError(t, err)
+	require.Equal(t, "657403b8e952589602fc008b8f8d1d26b4462267b208304ced7336a1eb8fdca3", hex.EncodeToString(root))
+}
+
+func TestHashFSEntries(t *testing.T) {
+	root, tree, err := HashFS(fsFixture())
+	require.NoError(t, err)
+
+	// Paths compare as bytes: "a.txt" comes before "a/b".
+	leaf := sha256.Sum256([]byte("\x00a"))
+	content := sha256.Sum256(append(append([]byte{1}, leaf[:]...), leaf[:]...))
+	entry := append([]byte("f\x05a.txt\x01"), content[:]...)
+	index, err := tree.IndexOf(entry)
+	require.NoError(t, err)
+	require.Equal(t, 1, index)
+
+	p, err := tree.Prove(entry)
+	require.NoError(t, err)
+	require.NoError(t, VerifyChained(root, entry, &ChainedProof{Links: []*Proof{p}}))
+
+	index, err = tree.IndexOf([]byte("d\x06assets"))
+	require.NoError(t, err)
+	require.Equal(t, 3, index)
+}
+
+func TestHashFSChanges(t *testing.T) {
+	root, _, err := HashFS(fsFixture())
+	require.NoError(t, err)
+
+	changes := map[string]func(fstest.MapFS){
+		"content": func(m fstest.MapFS) { m["a/b"] = &fstest.MapFile{Data: []byte("c")} },
+		"large content": func(m fstest.MapFS) {
+			data := bytes.Repeat([]byte{0xab}, 2*HashFSChunkSize+17)
+			data[HashFSChunkSize+1] = 0
+			m["src/util/large.bin"] = &fstest.MapFile{Data: data}
+		},
+		"rename":    func(m fstest.MapFS) { m["a/c"] = m["a/b"]; delete(m, "a/b") },
+		"empty dir": func(m fstest.MapFS) { m["assets/more"] = &fstest.MapFile{Mode: fs.ModeDir} },
+		"new file":  func(m fstest.MapFS) { m["assets/logo.png"] = &fstest.MapFile{Data: []byte("png")} },
+		"remove":    func(m fstest.MapFS) { delete(m, "empty.txt") },
+	}
+	for name, change := range changes {
+		fsys := fsFixture()
+		change(fsys)
+		changed, _, err := HashFS(fsys)
+		require.NoError(t, err)
+		require.NotEqual(t, root, changed, name)
+	}
+
+	// Modes are not committed.
+	fsys := fsFixture()
+	fsys["src/main.go"].Mode = 0o600
+	same, _, err := HashFS(fsys)
+	require.NoError(t, err)
+	require.Equal(t, root, same)
+}
+
+func TestHashFSErrors(t *testing.T) {
+	_, _, err := HashFS(fstest.MapFS{})
+	require.ErrorIs(t, err, ErrEmptyMerkleTree)
+
+	fsys := fsFixture()
+	fsys["link"] = &fstest.MapFile{Data: []byte("a.txt"), Mode: fs.ModeSymlink}
+	_, _, err = HashFS(fsys)
+	require.Error(t, err)
+
+	_, _, err = HashFS(fsFixture(), WithSortedLeaves(true))
+	require.Error(t, err)
+}
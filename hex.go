@@ -0,0 +1,151 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidHex is returned when a hex string cannot be decoded into a block
+// or a hash.
+var ErrInvalidHex = errors.New("error: invalid hex string")
+
+// HexBlock decodes s, a 0x prefixed hex string, into a block. "0x" is the
+// empty block.
+func HexBlock(s string) (Block, error) {
+	data, err := decodeHex("block", s)
+	if err != nil {
+		return nil, err
+	}
+
+	return Block(data), nil
+}
+
+// ParseRoot decodes s, a 0x prefixed hex string, into a root hash. It must be
+// as long as a digest of the hash function of opts, SHA-256 by default, or the
+// size set there with WithDigestSize.
+func ParseRoot(s string, opts ...Option) ([]byte, error) {
+	c := hexConfig(opts)
+	return parseHexHash("root", s, c.newHash().Size())
+}
+
+// HexSiblings returns the hashes of the steps of the proof, from the leaf up,
+// as 0x prefixed hex strings. The omitted steps of a compressed proof are
+// empty strings.
+func (p *Proof) HexSiblings() []string {
+	siblings := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		if step.Hash != nil {
+			siblings[i] = "0x" + hex.EncodeToString(step.Hash)
+		}
+	}
+
+	return siblings
+}
+
+// VerifyHex decodes its 0x prefixed hex arguments and checks that siblingsHex
+// leads from the leaf hash leafHex to rootHex. The sibling of level i is the
+// left one when bit i of index is set, which is LayoutLevels, the layout of a
+// MerkleTree padded with PadDuplicateLast or PadSelfPair; proofs of other
+// layouts are checked with Proof.Verify, which knows their Layout. Nodes are
+// hashed with the hash function, HMAC key, domain separation, pair ordering,
+// pair hash and digest size of opts, and every hash must be as long as a
+// digest of that function, truncated to that size.
+//
+// Malformed arguments are reported with an error wrapping ErrInvalidHex that
+// names the offending one; a well formed proof that does not lead to the root
+// returns an error wrapping ErrInvalidProof.
+func VerifyHex(rootHex, leafHex string, siblingsHex []string, index int, opts ...Option) error {
+	c := hexConfig(opts)
+	size := c.newHash().Size()
+
+	root, err := parseHexHash("root", rootHex, size)
+	if err != nil {
+		return err
+	}
+	node, err := parseHexHash("leaf", leafHex, size)
+	if err != nil {
+		return err
+	}
+	if index < 0 || len(siblingsHex) < 63 && index>>len(siblingsHex) != 0 {
+		return fmt.Errorf("error: leaf index %d out of range for %d siblings", index, len(siblingsHex))
+	}
+
+	for i, s := range siblingsHex {
+		sibling, err := parseHexHash(fmt.Sprintf("sibling %d", i), s, size)
+		if err != nil {
+			return err
+		}
+
+		left, right := node, sibling
+		if index>>i&1 == 1 {
+			left, right = sibling, node
+		}
+		left, right = c.orderPair(left, right)
+		if c.pairHash != nil {
+			node = c.truncate(c.pairHash(left, right))
+			continue
+		}
+
+		h := c.newHash()
+		if c.domainSeparation {
+			h.Write(c.nodePrefixBytes())
+		}
+		h.Write(left)
+		h.Write(right)
+		node = h.Sum(nil)
+	}
+
+	if !RootEqual(node, root) {
+		return fmt.Errorf("error: hex proof root mismatch; got: 0x%x, want: %s: %w", node, rootHex, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// hexConfig returns the configuration set by opts over SHA-256.
+func hexConfig(opts []Option) config {
+	c := config{hashFunc: sha256.New}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// parseHexHash decodes s, the hash named what, checking it is size bytes
+// long.
+func parseHexHash(what, s string, size int) ([]byte, error) {
+	hash, err := decodeHex(what, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != size {
+		return nil, fmt.Errorf("%w: %s is %d bytes long, want %d", ErrInvalidHex, what, len(hash), size)
+	}
+
+	return hash, nil
+}
+
+// decodeHex decodes s, the value named what, which must be a 0x prefixed
+// string of an even number of hex digits of either case.
+func decodeHex(what, s string) ([]byte, error) {
+	if len(s) < 2 || s[0] != '0' || s[1] != 'x' && s[1] != 'X' {
+		return nil, fmt.Errorf("%w: %s lacks the 0x prefix", ErrInvalidHex, what)
+	}
+
+	digits := s[2:]
+	for i := 0; i < len(digits); i++ {
+		if c := digits[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return nil, fmt.Errorf("%w: %s has invalid hex digit %q at offset %d", ErrInvalidHex, what, c, i+2)
+		}
+	}
+	if len(digits)%2 != 0 {
+		return nil, fmt.Errorf("%w: %s has an odd number of hex digits (%d)", ErrInvalidHex, what, len(digits))
+	}
+
+	data, _ := hex.DecodeString(digits)
+
+	return data, nil
+}
@@ -0,0 +1,144 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHexBlock(t *testing.T) {
+	block, err := HexBlock("0x00ff10Ab")
+	require.NoError(t, err)
+	require.Equal(t, Block{0x00, 0xff, 0x10, 0xab}, block)
+
+	block, err = HexBlock("0X")
+	require.NoError(t, err)
+	require.NotNil(t, block)
+	require.Empty(t, block)
+
+	for s, msg := range map[string]string{
+		"":       "block lacks the 0x prefix",
+		"00ff":   "block lacks the 0x prefix",
+		"x00":    "block lacks the 0x prefix",
+		"0x0":    "block has an odd number of hex digits (1)",
+		"0x00fg": `block has invalid hex digit 'g' at offset 5`,
+		"0x 0":   `block has invalid hex digit ' ' at offset 2`,
+	} {
+		_, err := HexBlock(s)
+		require.ErrorIs(t, err, ErrInvalidHex, s)
+		require.Contains(t, err.Error(), msg, s)
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	want := make([]byte, 32)
+	want[31] = 1
+	s := "0x" + hex.EncodeToString(want)
+
+	root, err := ParseRoot(s)
+	require.NoError(t, err)
+	require.Equal(t, want, root)
+
+	_, err = ParseRoot(s[:len(s)-2])
+	require.ErrorIs(t, err, ErrInvalidHex)
+	require.Contains(t, err.Error(), "root is 31 bytes long, want 32")
+
+	_, err = ParseRoot(s, WithHashStrategy(sha512.New))
+	require.ErrorIs(t, err, ErrInvalidHex)
+	require.Contains(t, err.Error(), "root is 32 bytes long, want 64")
+
+	_, err = ParseRoot(strings.TrimPrefix(s, "0x"))
+	require.ErrorIs(t, err, ErrInvalidHex)
+}
+
+func TestHexSiblings(t *testing.T) {
+	p := &Proof{Steps: []ProofStep{{Hash: []byte{0xab, 0x01}}, {}, {Hash: []byte{}, Left: true}}}
+	require.Equal(t, []string{"0xab01", "", "0x"}, p.HexSiblings())
+}
+
+func TestVerifyHex(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{WithDomainSeparation(true)},
+		{WithSortedPairs(true)},
+		{WithHashStrategy(sha512.New), WithDomainSeparation(true)},
+	} {
+		for n := 1; n <= 9; n++ {
+			tree, err := NewTreeWithOptions(paddingContents(n), opts...)
+			require.NoError(t, err)
+			root, err := tree.RootHash()
+			require.NoError(t, err)
+			rootHex := "0x" + hex.EncodeToString(root)
+
+			for i, l := range tree.RealLeaves() {
+				p, err := tree.Prove(l.Hash)
+				require.NoError(t, err)
+
+				leafHex := "0x" + hex.EncodeToString(p.LeafHash)
+				require.NoError(t, VerifyHex(rootHex, leafHex, p.HexSiblings(), p.Index, opts...), "leaves:%d index:%d", n, i)
+
+				if len(p.Steps) > 0 && !tree.sortedPairs && !RootEqual(p.Steps[0].Hash, p.LeafHash) {
+					err := VerifyHex(rootHex, leafHex, p.HexSiblings(), p.Index^1, opts...)
+					require.ErrorIs(t, err, ErrInvalidProof, "leaves:%d index:%d", n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyHexFlatTree(t *testing.T) {
+	blocks := make([]Block, 8)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+	}
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize())
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+
+	for i, b := range blocks {
+		p, err := mt.Prove(b)
+		require.NoError(t, err)
+		leafHex := "0x" + hex.EncodeToString(p.LeafHash)
+		require.NoError(t, VerifyHex("0x"+hex.EncodeToString(root), leafHex, p.HexSiblings(), i, WithDomainSeparation(true)))
+	}
+}
+
+func TestVerifyHexErrors(t *testing.T) {
+	tree, err := NewTree(paddingContents(4))
+	require.NoError(t, err)
+	root, _ := tree.RootHash()
+	p, err := tree.Prove(tree.Leaves[1].Hash)
+	require.NoError(t, err)
+
+	rootHex := "0x" + hex.EncodeToString(root)
+	leafHex := "0x" + hex.EncodeToString(p.LeafHash)
+	siblings := p.HexSiblings()
+	require.NoError(t, VerifyHex(rootHex, leafHex, siblings, 1))
+
+	for name, c := range map[string]struct {
+		root, leaf string
+		siblings   []string
+		index      int
+		msg        string
+	}{
+		"root prefix":   {rootHex[2:], leafHex, siblings, 1, "root lacks the 0x prefix"},
+		"leaf size":     {rootHex, leafHex[:10], siblings, 1, "leaf is 4 bytes long, want 32"},
+		"odd sibling":   {rootHex, leafHex, []string{siblings[0], siblings[1] + "0"}, 1, "sibling 1 has an odd number of hex digits (65)"},
+		"bad sibling":   {rootHex, leafHex, []string{"0x" + strings.Repeat("zz", 32), siblings[1]}, 1, "sibling 0 has invalid hex digit 'z' at offset 2"},
+		"index range":   {rootHex, leafHex, siblings, 4, "leaf index 4 out of range for 2 siblings"},
+		"negative":      {rootHex, leafHex, siblings, -1, "leaf index -1 out of range"},
+		"empty sibling": {rootHex, leafHex, []string{"", siblings[1]}, 1, "sibling 0 lacks the 0x prefix"},
+	} {
+		err := VerifyHex(c.root, c.leaf, c.siblings, c.index)
+		require.Error(t, err, name)
+		require.Contains(t, err.Error(), c.msg, name)
+	}
+
+	err = VerifyHex(rootHex, leafHex, siblings[:1], 1)
+	require.ErrorIs(t, err, ErrInvalidProof)
+}
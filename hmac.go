@@ -0,0 +1,54 @@
+package merklego
+
+import (
+	"crypto/hmac"
+	"errors"
+	"hash"
+)
+
+// ErrProofKeyed is returned when a proof of a tree built with WithHMACKey is
+// checked without a key, or a proof of an unkeyed tree with one.
+var ErrProofKeyed = errors.New("error: the HMAC keying of the proof and of its verifier differ")
+
+// WithHMACKey hashes every leaf and internal node of a tree with
+// HMAC(key, ...) over its hash function, so the root and the proofs of a tree
+// reveal nothing that lets whoever lacks key test guesses of its leaves. Trees
+// over the same data with different keys have unrelated roots. On a MerkleTree
+// the item hashes are keyed into their leaves even without domain separation.
+//
+// The key is copied and never serialized: proofs, snapshots and tree JSON
+// only record that the tree is keyed, and the key is given again to verify or
+// restore them, to Proof.VerifyWithKey, VerifyFlatProof or
+// RestoreFlatMerkleTree for instance. An empty key restores unkeyed hashing.
+func WithHMACKey(key []byte) Option {
+	return func(c *config) {
+		c.hmacKey = clonePrefix(key)
+	}
+}
+
+// keyed reports whether the tree hashes with HMAC.
+func (c *config) keyed() bool {
+	return c.hmacKey != nil
+}
+
+// hmacHashFunc returns hashFunc keyed with key, or hashFunc itself when key
+// is nil.
+func hmacHashFunc(hashFunc func() hash.Hash, key []byte) func() hash.Hash {
+	if key == nil {
+		return hashFunc
+	}
+
+	return func() hash.Hash {
+		return hmac.New(hashFunc, key)
+	}
+}
+
+// VerifyWithKey checks, as Verify does, a proof of a tree built with
+// WithHMACKey(key).
+func (p *Proof) VerifyWithKey(root, key []byte) error {
+	if len(key) == 0 {
+		return p.Verify(root)
+	}
+
+	return p.verify(root, key)
+}
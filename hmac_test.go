@@ -0,0 +1,221 @@
+package merklego
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	hmacKey      = []byte("secret hmac key 0123456789")
+	otherHMACKey = []byte("another hmac key 987654321")
+)
+
+func keyedFlatTree(t *testing.T, key []byte, n int, opts ...Option) *FlatMerkleTree {
+	var blocks []Block
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, Block("block-"+string(rune('a'+i))))
+	}
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(append([]Option{WithHMACKey(key)}, opts...)...))
+
+	return mt
+}
+
+func TestHMACKeyFlatTree(t *testing.T) {
+	mt := keyedFlatTree(t, hmacKey, 5)
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+
+	// The leaves and nodes are HMACs under the key.
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte{leafNodePrefix})
+	mac.Write([]byte("block-c"))
+	p, err := mt.Prove(Block("block-c"))
+	require.NoError(t, err)
+	require.Equal(t, mac.Sum(nil), p.LeafHash)
+
+	plain := NewMerkleTree(mt.blocks[:5]...)
+	require.NoError(t, plain.Finalize())
+	plainRoot, _ := plain.RootHash()
+	other := keyedFlatTree(t, otherHMACKey, 5)
+	otherRoot, _ := other.RootHash()
+	require.NotEqual(t, plainRoot, root)
+	require.NotEqual(t, otherRoot, root)
+
+	// Proofs need the key.
+	require.True(t, p.Keyed)
+	require.NoError(t, p.VerifyWithKey(root, hmacKey))
+	require.ErrorIs(t, p.VerifyWithKey(root, otherHMACKey), ErrInvalidProof)
+	require.ErrorIs(t, p.Verify(root), ErrProofKeyed)
+	require.NoError(t, mt.VerifyProof(Block("block-c"), p))
+
+	chunks, err := mt.Proof(Block("block-c"))
+	require.NoError(t, err)
+	require.NoError(t, VerifyFlatProof(root, Block("block-c"), 2, 5, chunks, WithHMACKey(hmacKey)))
+	require.Error(t, VerifyFlatProof(root, Block("block-c"), 2, 5, chunks))
+	require.Error(t, VerifyFlatProof(root, Block("block-c"), 2, 5, chunks, WithHMACKey(otherHMACKey)))
+
+	// Snapshots record that the tree is keyed, not the key.
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.True(t, s.Keyed)
+	encoded, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), string(hmacKey))
+	_, err = RestoreFlatMerkleTree(s)
+	require.Error(t, err)
+	_, err = RestoreFlatMerkleTree(s, WithHMACKey(otherHMACKey))
+	require.Error(t, err)
+	restored, err := RestoreFlatMerkleTree(s, WithHMACKey(hmacKey))
+	require.NoError(t, err)
+	restoredRoot, _ := restored.RootHash()
+	require.Equal(t, root, restoredRoot)
+}
+
+func TestHMACKeyMerkleTree(t *testing.T) {
+	tree, err := NewTreeWithOptions(paddingContents(7), WithHMACKey(hmacKey))
+	require.NoError(t, err)
+	plain, err := NewTree(paddingContents(7))
+	require.NoError(t, err)
+	other, err := NewTreeWithOptions(paddingContents(7), WithHMACKey(otherHMACKey))
+	require.NoError(t, err)
+	require.NotEqual(t, plain.MerkleRoot(), tree.MerkleRoot())
+	require.NotEqual(t, other.MerkleRoot(), tree.MerkleRoot())
+
+	// Item hashes are keyed into the leaves.
+	itemHash, err := paddingContents(7)[3].CalculateHash()
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(itemHash)
+	leaf := tree.Leaves[3].Hash
+	require.Equal(t, mac.Sum(nil), leaf)
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithDomainSeparation(true)},
+		{WithCountCommitment(true)},
+		{WithLengthPrefixedLeaves(true), WithSortedPairs(true)},
+	} {
+		tree, err := NewTreeWithOptions(paddingContents(7), append(opts, WithHMACKey(hmacKey))...)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		leaf := tree.Leaves[3].Hash
+
+		p, err := tree.Prove(leaf)
+		require.NoError(t, err)
+		require.True(t, p.Keyed)
+		require.Equal(t, "sha256", p.Hash)
+		require.NoError(t, tree.VerifyProof(leaf, p))
+		require.NoError(t, p.VerifyWithKey(root, hmacKey))
+		require.ErrorIs(t, p.VerifyWithKey(root, otherHMACKey), ErrInvalidProof)
+		require.ErrorIs(t, p.Verify(root), ErrProofKeyed)
+
+		got, err := p.hashLeaf(itemHash, hmacKey)
+		require.NoError(t, err)
+		require.Equal(t, leaf, got)
+
+		// The keyed flag survives the encodings.
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.True(t, decoded.Keyed)
+		require.Equal(t, len(data), p.EncodedSize(ProofBinary))
+		data, err = json.Marshal(p)
+		require.NoError(t, err)
+		require.Equal(t, len(data), p.EncodedSize(ProofJSON))
+		decoded = Proof{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.NoError(t, decoded.VerifyWithKey(root, hmacKey))
+	}
+
+	unkeyed, err := plain.Prove(plain.Leaves[3].Hash)
+	require.NoError(t, err)
+	require.ErrorIs(t, unkeyed.VerifyWithKey(plain.MerkleRoot(), hmacKey), ErrProofKeyed)
+	require.NoError(t, unkeyed.VerifyWithKey(plain.MerkleRoot(), nil))
+}
+
+func TestHMACKeyTreeJSON(t *testing.T) {
+	tree, err := NewTreeWithOptions(paddingContents(5), WithHMACKey(hmacKey))
+	require.NoError(t, err)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"keyed":true`)
+	require.NotContains(t, string(data), string(hmacKey))
+
+	var zero MerkleTree
+	require.ErrorIs(t, json.Unmarshal(data, &zero), ErrInvalidTreeJSON)
+
+	receiver, err := NewTreeWithOptions(paddingContents(1), WithHMACKey(hmacKey))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, receiver))
+	leaf := receiver.Leaves[2].Hash
+	p, err := receiver.Prove(leaf)
+	require.NoError(t, err)
+	require.NoError(t, receiver.VerifyProof(leaf, p))
+	require.NoError(t, p.VerifyWithKey(tree.MerkleRoot(), hmacKey))
+
+	plain, err := NewTree(paddingContents(5))
+	require.NoError(t, err)
+	data, err = json.Marshal(plain)
+	require.NoError(t, err)
+	require.ErrorIs(t, json.Unmarshal(data, receiver), ErrInvalidTreeJSON)
+}
+
+func TestHMACKeyFlatProofs(t *testing.T) {
+	mt := keyedFlatTree(t, hmacKey, 6, WithSortedLeaves(true))
+	root, _ := mt.RootHash()
+	key := WithHMACKey(hmacKey)
+
+	absence, err := mt.ProveAbsence(Block("absent"))
+	require.NoError(t, err)
+	require.True(t, absence.Keyed)
+	require.NoError(t, VerifyAbsence(root, Block("absent"), absence, key))
+	require.ErrorIs(t, VerifyAbsence(root, Block("absent"), absence), ErrInvalidProof)
+	require.ErrorIs(t, VerifyAbsence(root, Block("absent"), absence, WithHMACKey(otherHMACKey)), ErrInvalidProof)
+
+	mt = keyedFlatTree(t, hmacKey, 6)
+	root, _ = mt.RootHash()
+
+	order, err := mt.ProveOrder(mt.blocks[1], mt.blocks[4])
+	require.NoError(t, err)
+	require.True(t, order.Keyed)
+	require.NoError(t, VerifyOrder(root, mt.blocks[1], mt.blocks[4], order, key))
+	require.ErrorIs(t, VerifyOrder(root, mt.blocks[1], mt.blocks[4], order), ErrInvalidProof)
+
+	rng, err := mt.ProveRange(1, 4)
+	require.NoError(t, err)
+	require.True(t, rng.Keyed)
+	leaves := [][]byte{mt.blocks[1], mt.blocks[2], mt.blocks[3]}
+	require.NoError(t, VerifyRange(root, 1, leaves, rng, key))
+	require.ErrorIs(t, VerifyRange(root, 1, leaves, rng), ErrInvalidProof)
+	require.ErrorIs(t, VerifyRange(root, 1, leaves, rng, WithHMACKey(otherHMACKey)), ErrInvalidProof)
+}
+
+func TestHMACKeyNotInErrors(t *testing.T) {
+	mt := keyedFlatTree(t, hmacKey, 3)
+	root, _ := mt.RootHash()
+	p, err := mt.Prove(Block("block-a"))
+	require.NoError(t, err)
+
+	var errs []error
+	errs = append(errs, p.Verify(root), p.VerifyWithKey(root, otherHMACKey), p.VerifyWithKey([]byte("root"), hmacKey))
+	_, err = p.ToICS23([]byte("k"), []byte("v"))
+	errs = append(errs, err)
+	_, err = p.Compress([][]byte{p.LeafHash})
+	errs = append(errs, err)
+	s, _ := mt.Snapshot()
+	_, err = RestoreFlatMerkleTree(s, WithHMACKey(otherHMACKey))
+	errs = append(errs, err)
+
+	for _, err := range errs {
+		require.Error(t, err)
+		require.False(t, strings.Contains(err.Error(), string(hmacKey)), err.Error())
+	}
+}
@@ -0,0 +1,287 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ICS23HashOp is the HashOp of the ICS23 commitment proof specification.
+type ICS23HashOp int32
+
+// The hash ops of ICS23, with their protobuf values.
+const (
+	ICS23NoHash     ICS23HashOp = 0
+	ICS23SHA256     ICS23HashOp = 1
+	ICS23SHA512     ICS23HashOp = 2
+	ICS23Keccak     ICS23HashOp = 3
+	ICS23RIPEMD160  ICS23HashOp = 4
+	ICS23Bitcoin    ICS23HashOp = 5
+	ICS23SHA512_256 ICS23HashOp = 6
+)
+
+// ICS23LengthOp is the LengthOp of the ICS23 commitment proof specification.
+type ICS23LengthOp int32
+
+// The length ops of ICS23, with their protobuf values.
+const (
+	ICS23NoPrefix       ICS23LengthOp = 0
+	ICS23VarProto       ICS23LengthOp = 1
+	ICS23VarRLP         ICS23LengthOp = 2
+	ICS23Fixed32Big     ICS23LengthOp = 3
+	ICS23Fixed32Little  ICS23LengthOp = 4
+	ICS23Fixed64Big     ICS23LengthOp = 5
+	ICS23Fixed64Little  ICS23LengthOp = 6
+	ICS23Require32Bytes ICS23LengthOp = 7
+	ICS23Require64Bytes ICS23LengthOp = 8
+)
+
+// ics23HashOps maps the names of the hash functions registered by this
+// package to the ICS23 hash ops computing them. ICS23 verifiers implement no
+// other hash function merkle-go trees may use, Keccak-256 included.
+var ics23HashOps = map[string]ICS23HashOp{
+	"sha256":     ICS23SHA256,
+	"sha512":     ICS23SHA512,
+	"sha512_256": ICS23SHA512_256,
+}
+
+// ICS23LeafOp mirrors the LeafOp message of ICS23: the hash of a leaf is
+// Hash(Prefix || Length(PrehashKey(key)) || Length(PrehashValue(value))).
+type ICS23LeafOp struct {
+	Hash         ICS23HashOp   `json:"hash"`
+	PrehashKey   ICS23HashOp   `json:"prehash_key"`
+	PrehashValue ICS23HashOp   `json:"prehash_value"`
+	Length       ICS23LengthOp `json:"length"`
+	Prefix       []byte        `json:"prefix,omitempty"`
+}
+
+// ICS23InnerOp mirrors the InnerOp message of ICS23: the hash of a node is
+// Hash(Prefix || child || Suffix).
+type ICS23InnerOp struct {
+	Hash   ICS23HashOp `json:"hash"`
+	Prefix []byte      `json:"prefix,omitempty"`
+	Suffix []byte      `json:"suffix,omitempty"`
+}
+
+// ICS23ExistenceProof mirrors the ExistenceProof message of ICS23, proving
+// that Key is bound to Value in the tree whose root it leads to.
+type ICS23ExistenceProof struct {
+	Key   []byte         `json:"key"`
+	Value []byte         `json:"value"`
+	Leaf  ICS23LeafOp    `json:"leaf"`
+	Path  []ICS23InnerOp `json:"path"`
+}
+
+// ToICS23 expresses p as an ICS23 existence proof of key and value, whose
+// concatenation must be the leaf data p proves: the block of a FlatMerkleTree
+// and the item hash of a MerkleTree with domain separation, which the leaf
+// prefix is prepended to. Without domain separation the leaf hash must be the
+// plain hash of key || value. ICS23 requires both key and value to be
+// non-empty.
+//
+// It returns an error for proofs ICS23 cannot express: compressed proofs,
+// proofs of trees with length prefixed leaves, a pair hash, an HMAC key or
+// truncated hashes, proofs of salted leaves and proofs whose hash function has
+// no ICS23 hash op.
+func (p *Proof) ToICS23(key, value []byte) (*ICS23ExistenceProof, error) {
+	if p.Compressed() {
+		return nil, errors.New("error: cannot express a compressed proof in ICS23")
+	}
+	if p.LengthPrefixedLeaves {
+		return nil, errors.New("error: ICS23 leaf ops cannot express length prefixed leaves")
+	}
+	if p.PairHash != "" {
+		return nil, fmt.Errorf("error: ICS23 inner ops cannot express pair hash %q", p.PairHash)
+	}
+	if p.Keyed {
+		return nil, errors.New("error: ICS23 ops cannot express HMAC-keyed hashing")
+	}
+	if len(p.Salt) > 0 {
+		return nil, errors.New("error: ICS23 leaf ops cannot express salted leaves")
+	}
+	if p.DigestSize != 0 {
+		return nil, errors.New("error: ICS23 hash ops cannot express truncated hashes")
+	}
+	op, ok := ics23HashOps[p.Hash]
+	if !ok {
+		return nil, fmt.Errorf("error: ICS23 has no hash op for hash function %q", p.Hash)
+	}
+	if len(key) == 0 || len(value) == 0 {
+		return nil, errors.New("error: ICS23 requires a non-empty key and value")
+	}
+
+	c := config{leafPrefix: p.LeafPrefix, nodePrefix: p.NodePrefix}
+	e := &ICS23ExistenceProof{
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte(nil), value...),
+		Leaf:  ICS23LeafOp{Hash: op},
+	}
+	var nodePrefix []byte
+	if p.DomainSeparation {
+		e.Leaf.Prefix = append([]byte(nil), c.leafPrefixBytes()...)
+		nodePrefix = c.nodePrefixBytes()
+	}
+
+	leaf, err := e.Leaf.apply(key, value)
+	if err != nil {
+		return nil, err
+	}
+	if !RootEqual(leaf, p.LeafHash) {
+		return nil, errors.New("error: leaf hash of the proof is not the hash of key || value")
+	}
+
+	pair, err := p.pairHasher(nil)
+	if err != nil {
+		return nil, err
+	}
+	node := p.LeafHash
+	for _, step := range p.Steps {
+		left, right, nodeLeft := node, step.Hash, !step.Left
+		if step.Left {
+			left, right = step.Hash, node
+		}
+		if p.SortedPairs && bytes.Compare(left, right) > 0 {
+			left, right, nodeLeft = right, left, !nodeLeft
+		}
+
+		inner := ICS23InnerOp{Hash: op, Prefix: append([]byte(nil), nodePrefix...)}
+		if nodeLeft {
+			inner.Suffix = append([]byte(nil), right...)
+		} else {
+			inner.Prefix = append(inner.Prefix, left...)
+		}
+		e.Path = append(e.Path, inner)
+		node = pair(left, right)
+	}
+
+	if p.CountCommitment {
+		var count [8]byte
+		binary.LittleEndian.PutUint64(count[:], uint64(p.Size))
+		e.Path = append(e.Path, ICS23InnerOp{Hash: op, Prefix: count[:]})
+	}
+
+	return e, nil
+}
+
+// Calculate returns the root e leads to, computed as the ICS23 verifiers do.
+func (e *ICS23ExistenceProof) Calculate() ([]byte, error) {
+	node, err := e.Leaf.apply(e.Key, e.Value)
+	if err != nil {
+		return nil, fmt.Errorf("error: ICS23 leaf: %w", err)
+	}
+
+	for i := range e.Path {
+		if node, err = e.Path[i].apply(node); err != nil {
+			return nil, fmt.Errorf("error: ICS23 inner op %d: %w", i, err)
+		}
+	}
+
+	return node, nil
+}
+
+// Verify checks that e leads to root, returning an error wrapping
+// ErrInvalidProof when it does not.
+func (e *ICS23ExistenceProof) Verify(root []byte) error {
+	computed, err := e.Calculate()
+	if err != nil {
+		return err
+	}
+	if !RootEqual(computed, root) {
+		return fmt.Errorf("error: ICS23 proof root mismatch; got: %x, want: %x: %w", computed, root, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+func (op *ICS23LeafOp) apply(key, value []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("leaf op needs key")
+	}
+	if len(value) == 0 {
+		return nil, errors.New("leaf op needs value")
+	}
+
+	pkey, err := prepareICS23LeafData(op.PrehashKey, op.Length, key)
+	if err != nil {
+		return nil, fmt.Errorf("prehash key: %w", err)
+	}
+	pvalue, err := prepareICS23LeafData(op.PrehashValue, op.Length, value)
+	if err != nil {
+		return nil, fmt.Errorf("prehash value: %w", err)
+	}
+
+	data := append([]byte(nil), op.Prefix...)
+	data = append(data, pkey...)
+
+	return ics23Hash(op.Hash, append(data, pvalue...))
+}
+
+func (op *ICS23InnerOp) apply(child []byte) ([]byte, error) {
+	if len(child) == 0 {
+		return nil, errors.New("inner op needs child value")
+	}
+
+	preimage := append([]byte(nil), op.Prefix...)
+	preimage = append(preimage, child...)
+
+	return ics23Hash(op.Hash, append(preimage, op.Suffix...))
+}
+
+func prepareICS23LeafData(hashOp ICS23HashOp, lengthOp ICS23LengthOp, data []byte) ([]byte, error) {
+	if hashOp != ICS23NoHash {
+		var err error
+		if data, err = ics23Hash(hashOp, data); err != nil {
+			return nil, err
+		}
+	}
+
+	switch lengthOp {
+	case ICS23NoPrefix:
+		return data, nil
+	case ICS23VarProto:
+		var varint [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(varint[:], uint64(len(data)))
+
+		return append(varint[:n:n], data...), nil
+	case ICS23Require32Bytes, ICS23Require64Bytes:
+		want := 32
+		if lengthOp == ICS23Require64Bytes {
+			want = 64
+		}
+		if len(data) != want {
+			return nil, fmt.Errorf("data was %d bytes, not %d", len(data), want)
+		}
+
+		return data, nil
+	case ICS23Fixed32Little:
+		out := make([]byte, 4, 4+len(data))
+		binary.LittleEndian.PutUint32(out, uint32(len(data)))
+
+		return append(out, data...), nil
+	}
+
+	return nil, fmt.Errorf("unsupported length op %d", lengthOp)
+}
+
+// ics23Hash hashes preimage with hashOp. Like the ICS23 verifiers, it rejects
+// NO_HASH, and it supports the hash ops ToICS23 produces.
+func ics23Hash(hashOp ICS23HashOp, preimage []byte) ([]byte, error) {
+	var h hash.Hash
+	switch hashOp {
+	case ICS23SHA256:
+		h = sha256.New()
+	case ICS23SHA512:
+		h = sha512.New()
+	case ICS23SHA512_256:
+		h = sha512.New512_256()
+	default:
+		return nil, fmt.Errorf("unsupported hash op %d", hashOp)
+	}
+	h.Write(preimage)
+
+	return h.Sum(nil), nil
+}
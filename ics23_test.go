@@ -0,0 +1,236 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	ics23 "github.com/cosmos/ics23/go"
+	"github.com/stretchr/testify/require"
+)
+
+// ics23Items are key=value items, split at the = into the key and value of
+// their ICS23 proofs.
+func ics23Items(n int) (items, keys, values [][]byte) {
+	for i := 0; i < n; i++ {
+		key, value := []byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("=value%d", i))
+		items = append(items, append(append([]byte(nil), key...), value...))
+		keys, values = append(keys, key), append(values, value)
+	}
+
+	return items, keys, values
+}
+
+func TestProofToICS23MerkleTree(t *testing.T) {
+	items, keys, values := ics23Items(7)
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithSortedPairs(true)},
+		{WithCountCommitment(true)},
+		{WithPaddingStrategy(PadPromote), WithSortedPairs(true), WithCountCommitment(true)},
+	} {
+		tree, err := NewTreeFromBytes(items, opts...)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			require.NoError(t, err)
+			e, err := p.ToICS23(keys[i], values[i])
+			require.NoError(t, err)
+			require.NoError(t, e.Verify(root))
+
+			got, err := e.Calculate()
+			require.NoError(t, err)
+			require.Equal(t, root, got)
+			require.ErrorIs(t, e.Verify(tree.Leaves[0].Hash), ErrInvalidProof)
+
+			_, err = p.ToICS23(keys[i], []byte("other"))
+			require.ErrorContains(t, err, "not the hash of key || value")
+		}
+	}
+}
+
+func TestProofToICS23DomainSeparation(t *testing.T) {
+	items, _, _ := ics23Items(5)
+
+	for _, opts := range [][]Option{
+		{WithDomainSeparation(true)},
+		{WithDomainSeparation(true), WithHashStrategy(sha512.New)},
+		{WithDomainSeparation(true), WithLeafPrefix([]byte("leaf:")), WithNodePrefix([]byte("node:")), WithSortedPairs(true)},
+	} {
+		tree, err := NewTreeFromBytes(items, opts...)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			require.NoError(t, err)
+
+			// The leaf data of a MerkleTree is the item hash.
+			data := sha256.Sum256(items[i])
+			e, err := p.ToICS23(data[:4], data[4:])
+			require.NoError(t, err)
+			require.Equal(t, p.LeafPrefix == nil, len(e.Leaf.Prefix) == 1)
+			require.NoError(t, e.Verify(root))
+		}
+	}
+}
+
+func TestProofToICS23FlatTree(t *testing.T) {
+	items, keys, values := ics23Items(6)
+	blocks := make([]Block, len(items))
+	for i, item := range items {
+		blocks[i] = item
+	}
+
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(WithDistinctPadding(true)))
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+
+	for i, b := range blocks {
+		p, err := mt.Prove(b)
+		require.NoError(t, err)
+		e, err := p.ToICS23(keys[i], values[i])
+		require.NoError(t, err)
+		require.Equal(t, []byte{leafNodePrefix}, e.Leaf.Prefix)
+		require.NoError(t, e.Verify(root))
+
+		c := config{leafPrefix: p.LeafPrefix, nodePrefix: p.NodePrefix}
+		proof, spec := referenceICS23(e, c.nodePrefixBytes(), len(root))
+		require.True(t, ics23.VerifyMembership(spec, root, proof, keys[i], values[i]))
+	}
+}
+
+func TestProofToICS23Unsupported(t *testing.T) {
+	items, keys, values := ics23Items(4)
+
+	prove := func(opts ...Option) *Proof {
+		tree, err := NewTreeFromBytes(items, opts...)
+		require.NoError(t, err)
+		p, err := tree.Prove(tree.Leaves[1].Hash)
+		require.NoError(t, err)
+
+		return p
+	}
+
+	_, err := prove(WithLengthPrefixedLeaves(true)).ToICS23(keys[1], values[1])
+	require.ErrorContains(t, err, "length prefixed leaves")
+
+	_, err = prove(WithPairHash(swappedPairHash)).ToICS23(keys[1], values[1])
+	require.ErrorContains(t, err, "pair hash")
+
+	_, err = prove(WithHashStrategy(sha256.New224)).ToICS23(keys[1], values[1])
+	require.ErrorContains(t, err, `no hash op for hash function "sha224"`)
+
+	p := prove()
+	_, err = p.ToICS23(nil, items[1])
+	require.ErrorContains(t, err, "non-empty key and value")
+
+	c, err := p.Compress([][]byte{p.LeafHash})
+	require.NoError(t, err)
+	_, err = c.ToICS23(keys[1], values[1])
+	require.ErrorContains(t, err, "compressed")
+
+	e, err := p.ToICS23(keys[1], values[1])
+	require.NoError(t, err)
+	e.Leaf.Hash = ICS23Keccak
+	_, err = e.Calculate()
+	require.ErrorContains(t, err, "unsupported hash op 3")
+}
+
+func TestICS23LeafOpLength(t *testing.T) {
+	op := ICS23LeafOp{Hash: ICS23SHA256, PrehashValue: ICS23SHA256, Length: ICS23VarProto, Prefix: []byte{0}}
+	e := &ICS23ExistenceProof{Key: []byte("food"), Value: []byte("some longer text"), Leaf: op}
+
+	value := sha256.Sum256(e.Value)
+	want := sha256.Sum256(append(append([]byte{0, 4}, "food"...), append([]byte{32}, value[:]...)...))
+	got, err := e.Calculate()
+	require.NoError(t, err)
+	require.Equal(t, want[:], got)
+
+	e.Leaf.Length = ICS23Require32Bytes
+	_, err = e.Calculate()
+	require.ErrorContains(t, err, "data was 4 bytes, not 32")
+}
+
+// referenceICS23 converts e into the proof and spec of the ICS23 reference
+// implementation. The spec pins the leaf prefix and node prefix e uses and
+// children of size hashSize.
+func referenceICS23(e *ICS23ExistenceProof, nodePrefix []byte, hashSize int) (*ics23.CommitmentProof, *ics23.ProofSpec) {
+	ep := &ics23.ExistenceProof{
+		Key:   e.Key,
+		Value: e.Value,
+		Leaf: &ics23.LeafOp{
+			Hash:         ics23.HashOp(e.Leaf.Hash),
+			PrehashKey:   ics23.HashOp(e.Leaf.PrehashKey),
+			PrehashValue: ics23.HashOp(e.Leaf.PrehashValue),
+			Length:       ics23.LengthOp(e.Leaf.Length),
+			Prefix:       e.Leaf.Prefix,
+		},
+	}
+	for _, op := range e.Path {
+		ep.Path = append(ep.Path, &ics23.InnerOp{Hash: ics23.HashOp(op.Hash), Prefix: op.Prefix, Suffix: op.Suffix})
+	}
+
+	spec := &ics23.ProofSpec{
+		LeafSpec: &ics23.LeafOp{
+			Hash:         ics23.HashOp(e.Leaf.Hash),
+			PrehashKey:   ics23.HashOp_NO_HASH,
+			PrehashValue: ics23.HashOp_NO_HASH,
+			Length:       ics23.LengthOp_NO_PREFIX,
+			Prefix:       e.Leaf.Prefix,
+		},
+		InnerSpec: &ics23.InnerSpec{
+			ChildOrder:      []int32{0, 1},
+			ChildSize:       int32(hashSize),
+			MinPrefixLength: int32(len(nodePrefix)),
+			MaxPrefixLength: int32(len(nodePrefix)),
+			Hash:            ics23.HashOp(e.Leaf.Hash),
+		},
+	}
+
+	return &ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Exist{Exist: ep}}, spec
+}
+
+// TestProofToICS23Reference checks the exported proofs of domain separated
+// trees with the ICS23 reference verifier. Without domain separation the leaf
+// prefix is empty and every inner op starts with it, which ICS23 specs reject.
+func TestProofToICS23Reference(t *testing.T) {
+	items, _, _ := ics23Items(7)
+
+	for _, opts := range [][]Option{
+		{WithDomainSeparation(true)},
+		{WithDomainSeparation(true), WithSortedPairs(true)},
+		{WithDomainSeparation(true), WithPaddingStrategy(PadPromote)},
+		{WithDomainSeparation(true), WithHashStrategy(sha512.New)},
+		{WithDomainSeparation(true), WithHashStrategy(sha512.New512_256)},
+		{WithDomainSeparation(true), WithLeafPrefix([]byte("leaf:")), WithNodePrefix([]byte("node:"))},
+	} {
+		tree, err := NewTreeFromBytes(items, opts...)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			require.NoError(t, err)
+
+			// The leaf data of a MerkleTree is the item hash.
+			data := sha256.Sum256(items[i])
+			key, value := data[:4], data[4:]
+			e, err := p.ToICS23(key, value)
+			require.NoError(t, err)
+
+			c := config{leafPrefix: p.LeafPrefix, nodePrefix: p.NodePrefix}
+			proof, spec := referenceICS23(e, c.nodePrefixBytes(), len(root))
+			require.True(t, ics23.VerifyMembership(spec, root, proof, key, value))
+			require.False(t, ics23.VerifyMembership(spec, root, proof, key, []byte("other")))
+		}
+	}
+}
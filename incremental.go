@@ -0,0 +1,325 @@
+package merklego
+
+import (
+	"errors"
+	"fmt"
+)
+
+// incrementalMaxDepth is the depth of the largest IncrementalTree, whose
+// capacity still fits a uint64.
+const incrementalMaxDepth = 63
+
+// ErrIncrementalTreeFull is returned by IncrementalTree.Append once the tree
+// holds 2^depth leaves.
+var ErrIncrementalTreeFull = errors.New("incremental tree is full")
+
+// IncrementalTree is a merkle tree of a fixed depth whose leaves are appended
+// left to right, as the Ethereum deposit contract and the commitment trees of
+// many zero-knowledge applications maintain theirs. The tree always has 2^depth
+// leaves, the ones not appended yet being zero: a zero leaf is a node of zero
+// bytes, and the root of a subtree of zero leaves is the zero hash of its
+// depth, H(zero || zero) of the level below. Leaves are nodes, appended as is
+// without being hashed, and the parent of left and right is H(left || right)
+// with no prefix.
+//
+// Its root is kept up to date from the filled subtrees, the last complete
+// left subtree of every level, in O(depth) hashes per Append. The tree also
+// retains the root of every complete subtree, about 2n hashes for n leaves, to
+// prove the leaves appended so far and to recompute the root of any earlier
+// size. WithHeadHistory keeps the heads of the last sizes.
+//
+// Nodes are hashed with SHA-256 unless opts set another hash function with
+// WithHashStrategy or WithDigestFunc, keyed with WithHMACKey if set; other
+// options do not apply. The deposit contract mixes the number of deposits into
+// its root as SSZListRoot does; Root is the root of the tree alone.
+type IncrementalTree struct {
+	config
+	depth int
+	count uint64
+	// zero[d] is the root of a subtree of 2^d zero leaves.
+	zero []TreeNode
+	// branch[d] is the root of the last complete left subtree of 2^d leaves,
+	// the filled subtrees of the deposit contract.
+	branch []TreeNode
+	// levels[d][i] is the root of the complete subtree of the leaves i*2^d to
+	// (i+1)*2^d.
+	levels [][]TreeNode
+	// history holds the heads of the last sizes, if kept.
+	history headRing
+}
+
+var _ Rooter = (*IncrementalTree)(nil)
+
+// NewIncrementalTree returns an empty IncrementalTree of 2^depth leaves, depth
+// between 1 and 63: the deposit contract uses 32.
+func NewIncrementalTree(depth int, opts ...Option) (*IncrementalTree, error) {
+	if depth < 1 || depth > incrementalMaxDepth {
+		return nil, fmt.Errorf("invalid incremental tree depth %d", depth)
+	}
+
+	t := &IncrementalTree{
+		depth:  depth,
+		zero:   make([]TreeNode, depth+1),
+		branch: make([]TreeNode, depth),
+		levels: make([][]TreeNode, depth+1),
+	}
+	for _, opt := range opts {
+		opt(&t.config)
+	}
+
+	t.zero[0] = make(TreeNode, t.newHash().Size())
+	for d := 1; d <= depth; d++ {
+		t.zero[d] = t.hashChildren(t.zero[d-1], t.zero[d-1])
+	}
+
+	t.history = newHeadRing(t.headHistory)
+	t.recordHead()
+
+	return t, nil
+}
+
+// Depth returns the depth of the tree.
+func (t *IncrementalTree) Depth() int {
+	return t.depth
+}
+
+// Size returns the number of leaves appended to the tree.
+func (t *IncrementalTree) Size() uint64 {
+	return t.count
+}
+
+// Append adds leaf, a node of the size of the hashes of the tree, as the leaf
+// at the next index, which it returns. It returns ErrIncrementalTreeFull once
+// the tree holds 2^depth leaves.
+func (t *IncrementalTree) Append(leaf []byte) (index uint64, err error) {
+	if leaf == nil {
+		return 0, ErrNilBlock
+	}
+	if len(leaf) != len(t.zero[0]) {
+		return 0, fmt.Errorf("leaf of %d bytes in a tree of %d byte hashes", len(leaf), len(t.zero[0]))
+	}
+	if t.count == 1<<uint(t.depth) {
+		return 0, ErrIncrementalTreeFull
+	}
+
+	index = t.count
+	t.count++
+
+	node := copyNode(leaf)
+	for d, size := 0, t.count; ; d, size = d+1, size>>1 {
+		t.levels[d] = append(t.levels[d], node)
+		if size&1 == 1 {
+			if d < t.depth {
+				t.branch[d] = node
+			}
+			t.recordHead()
+			return index, nil
+		}
+		node = t.hashChildren(t.branch[d], node)
+	}
+}
+
+// Root returns the root of the tree, the zero hash of its depth while it is
+// empty.
+func (t *IncrementalTree) Root() []byte {
+	if t.count == 1<<uint(t.depth) {
+		return copyNode(t.levels[t.depth][0])
+	}
+
+	node := t.zero[0]
+	for d, size := 0, t.count; d < t.depth; d, size = d+1, size>>1 {
+		if size&1 == 1 {
+			node = t.hashChildren(t.branch[d], node)
+		} else {
+			node = t.hashChildren(node, t.zero[d])
+		}
+	}
+
+	return copyNode(node)
+}
+
+// RootHash returns Root, so that an IncrementalTree is a Rooter.
+func (t *IncrementalTree) RootHash() ([]byte, error) {
+	return t.Root(), nil
+}
+
+// RootAtSize returns the root the tree had when it held its first size
+// leaves, from its head history if kept there.
+func (t *IncrementalTree) RootAtSize(size uint64) ([]byte, error) {
+	if size > t.count {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", size, t.count)
+	}
+	if root, ok := t.history.root(size); ok {
+		return root, nil
+	}
+
+	return copyNode(t.subtreeRoot(t.depth, 0, size)), nil
+}
+
+// Heads returns the heads kept by WithHeadHistory, by increasing size.
+func (t *IncrementalTree) Heads() []TreeHead {
+	return t.history.heads()
+}
+
+// recordHead keeps the current head in the head history, if any.
+func (t *IncrementalTree) recordHead() {
+	if t.history != nil {
+		h, _ := t.Head()
+		t.history.record(h)
+	}
+}
+
+// ProofAt returns the siblings of the leaf at index, from the leaf up, depth
+// of them, as VerifyIncrementalProof checks them against the current root.
+func (t *IncrementalTree) ProofAt(index uint64) ([]TreeNode, error) {
+	if index >= t.count {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", index, t.count)
+	}
+
+	return t.path(index, t.count), nil
+}
+
+// path returns the siblings of the leaf at index in the tree of the first
+// size leaves, from the leaf up.
+func (t *IncrementalTree) path(index, size uint64) []TreeNode {
+	proof := make([]TreeNode, t.depth)
+	for d := range proof {
+		proof[d] = copyNode(t.subtreeRoot(d, (index>>uint(d))^1, size))
+	}
+
+	return proof
+}
+
+// ConsistencyProof returns the proof that the tree of the first newSize leaves
+// extends the one of the first oldSize, which VerifyIncrementalConsistency
+// checks: the leaf at oldSize, the first appended since, and its siblings in
+// the newer tree, from the leaf up. The siblings on its left are the
+// filled subtrees of the older tree, those on its right hold the leaves
+// appended since. It is empty when both sizes are the same.
+func (t *IncrementalTree) ConsistencyProof(oldSize, newSize uint64) ([]TreeNode, error) {
+	if newSize > t.count {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", newSize, t.count)
+	}
+	if oldSize > newSize {
+		return nil, fmt.Errorf("invalid old tree size %d for a tree of %d leaves", oldSize, newSize)
+	}
+	if oldSize == newSize {
+		return []TreeNode{}, nil
+	}
+
+	return append([]TreeNode{copyNode(t.subtreeRoot(0, oldSize, newSize))}, t.path(oldSize, newSize)...), nil
+}
+
+// subtreeRoot returns the root of the subtree of the leaves i*2^d to
+// (i+1)*2^d in the tree of the first size leaves: retained if complete, the
+// zero hash of depth d if empty, and computed from the levels below
+// otherwise, which is the case of at most one subtree per level.
+func (t *IncrementalTree) subtreeRoot(d int, i, size uint64) TreeNode {
+	if i<<uint(d) >= size {
+		return t.zero[d]
+	}
+	if (i+1)<<uint(d) <= size {
+		return t.levels[d][i]
+	}
+
+	return t.hashChildren(t.subtreeRoot(d-1, 2*i, size), t.subtreeRoot(d-1, 2*i+1, size))
+}
+
+// hashChildren hashes two nodes into their parent, H(left || right).
+func (t *IncrementalTree) hashChildren(left, right TreeNode) TreeNode {
+	h := t.newHash()
+	h.Write(left)
+	h.Write(right)
+
+	return TreeNode(h.Sum(nil))
+}
+
+// VerifyIncrementalProof checks that proof, as returned by
+// IncrementalTree.ProofAt, shows leaf to be the leaf at index of the
+// IncrementalTree of the given depth and root, hashed as opts set. It is the
+// is_valid_merkle_branch check of the Ethereum consensus specification.
+func VerifyIncrementalProof(root, leaf []byte, index uint64, depth int, proof []TreeNode, opts ...Option) error {
+	if leaf == nil {
+		return ErrNilBlock
+	}
+	if depth < 1 || depth > incrementalMaxDepth {
+		return fmt.Errorf("invalid incremental tree depth %d", depth)
+	}
+	if index >= 1<<uint(depth) {
+		return fmt.Errorf("leaf index %d out of range [0, %d)", index, uint64(1)<<uint(depth))
+	}
+	if len(proof) != depth {
+		return fmt.Errorf("invalid proof for leaf %d: got %d siblings, want %d", index, len(proof), depth)
+	}
+
+	t := &IncrementalTree{}
+	for _, opt := range opts {
+		opt(&t.config)
+	}
+
+	node := TreeNode(leaf)
+	for d, sibling := range proof {
+		if index>>uint(d)&1 == 1 {
+			node = t.hashChildren(sibling, node)
+		} else {
+			node = t.hashChildren(node, sibling)
+		}
+	}
+
+	if !RootEqual(node, root) {
+		return fmt.Errorf("invalid proof for leaf %d; got: %X, want: %X", index, node.Bytes(), root)
+	}
+
+	return nil
+}
+
+// VerifyIncrementalConsistency checks that proof, as returned by
+// IncrementalTree.ConsistencyProof, shows the IncrementalTree of the given
+// depth, newRoot and newSize leaves to extend the one of oldRoot and oldSize
+// leaves, hashed as opts set: both roots are folded from the filled subtrees
+// of the older tree.
+func VerifyIncrementalConsistency(oldRoot, newRoot []byte, oldSize, newSize uint64, depth int, proof []TreeNode, opts ...Option) error {
+	if depth < 1 || depth > incrementalMaxDepth {
+		return fmt.Errorf("invalid incremental tree depth %d", depth)
+	}
+	if oldSize > newSize || newSize > 1<<uint(depth) {
+		return fmt.Errorf("invalid consistency proof between sizes %d and %d of a tree of depth %d", oldSize, newSize, depth)
+	}
+	if oldSize == newSize {
+		if len(proof) != 0 {
+			return fmt.Errorf("invalid consistency proof: got %d nodes for trees of the same size", len(proof))
+		}
+		if !RootEqual(oldRoot, newRoot) {
+			return fmt.Errorf("invalid consistency proof: roots %X and %X of the same size differ", oldRoot, newRoot)
+		}
+		return nil
+	}
+	if len(proof) != depth+1 {
+		return fmt.Errorf("invalid consistency proof: got %d nodes, want %d", len(proof), depth+1)
+	}
+
+	t, err := NewIncrementalTree(depth, opts...)
+	if err != nil {
+		return err
+	}
+
+	oldNode, newNode := t.zero[0], proof[0]
+	for d, sibling := range proof[1:] {
+		if oldSize>>uint(d)&1 == 1 {
+			oldNode = t.hashChildren(sibling, oldNode)
+			newNode = t.hashChildren(sibling, newNode)
+		} else {
+			oldNode = t.hashChildren(oldNode, t.zero[d])
+			newNode = t.hashChildren(newNode, sibling)
+		}
+	}
+
+	if !RootEqual(oldNode, oldRoot) {
+		return fmt.Errorf("invalid consistency proof for size %d; got: %X, want: %X", oldSize, oldNode.Bytes(), oldRoot)
+	}
+	if !RootEqual(newNode, newRoot) {
+		return fmt.Errorf("invalid consistency proof for size %d; got: %X, want: %X", newSize, newNode.Bytes(), newRoot)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// naiveIncrementalRoot returns the root of the full tree of 2^depth leaves
+// whose first ones are leaves and the others zero.
+func naiveIncrementalRoot(depth int, leaves [][]byte, hashSize int, hashFn func(data ...[]byte) []byte) []byte {
+	layer := make([][]byte, 1<<uint(depth))
+	for i := range layer {
+		layer[i] = make([]byte, hashSize)
+	}
+	copy(layer, leaves)
+
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			next[i] = hashFn(layer[2*i], layer[2*i+1])
+		}
+		layer = next
+	}
+
+	return layer[0]
+}
+
+func TestIncrementalTreeRoots(t *testing.T) {
+	tree, err := NewIncrementalTree(5)
+	require.NoError(t, err)
+
+	var leaves [][]byte
+	require.Equal(t, naiveIncrementalRoot(5, nil, 32, sum256), tree.Root())
+	for i := 0; i < 32; i++ {
+		leaf := sum256([]byte(fmt.Sprintf("leaf %d", i)))
+		index, err := tree.Append(leaf)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), index)
+		leaves = append(leaves, leaf)
+
+		root := tree.Root()
+		require.Equal(t, naiveIncrementalRoot(5, leaves, 32, sum256), root, "%d leaves", len(leaves))
+		for j, l := range leaves {
+			proof, err := tree.ProofAt(uint64(j))
+			require.NoError(t, err)
+			require.NoError(t, VerifyIncrementalProof(root, l, uint64(j), 5, proof), "leaf %d of %d", j, len(leaves))
+		}
+	}
+	require.Equal(t, uint64(32), tree.Size())
+
+	_, err = tree.Append(sum256([]byte("leaf 32")))
+	require.ErrorIs(t, err, ErrIncrementalTreeFull)
+}
+
+func TestIncrementalTreeDepositContract(t *testing.T) {
+	tree, err := NewIncrementalTree(32)
+	require.NoError(t, err)
+
+	// get_deposit_root of the deposit contract without deposits.
+	count := make([]byte, 32)
+	binary.LittleEndian.PutUint64(count, tree.Size())
+	require.Equal(t, mustHex(t, "d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e"), sum256(tree.Root(), count))
+}
+
+func TestIncrementalTreeHashStrategy(t *testing.T) {
+	sum512 := func(data ...[]byte) []byte {
+		h := sha512.New()
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+
+	tree, err := NewIncrementalTree(3, WithHashStrategy(sha512.New))
+	require.NoError(t, err)
+
+	_, err = tree.Append(sum256([]byte("short leaf")))
+	require.Error(t, err)
+
+	var leaves [][]byte
+	for i := 0; i < 5; i++ {
+		leaf := sum512([]byte(fmt.Sprintf("leaf %d", i)))
+		_, err := tree.Append(leaf)
+		require.NoError(t, err)
+		leaves = append(leaves, leaf)
+	}
+	require.Equal(t, naiveIncrementalRoot(3, leaves, 64, sum512), tree.Root())
+
+	proof, err := tree.ProofAt(4)
+	require.NoError(t, err)
+	require.NoError(t, VerifyIncrementalProof(tree.Root(), leaves[4], 4, 3, proof, WithHashStrategy(sha512.New)))
+	require.Error(t, VerifyIncrementalProof(tree.Root(), leaves[4], 4, 3, proof))
+}
+
+func TestIncrementalTreeInvalid(t *testing.T) {
+	_, err := NewIncrementalTree(0)
+	require.Error(t, err)
+	_, err = NewIncrementalTree(64)
+	require.Error(t, err)
+
+	tree, err := NewIncrementalTree(4)
+	require.NoError(t, err)
+	_, err = tree.ProofAt(0)
+	require.Error(t, err)
+
+	var leaves [][]byte
+	for i := 0; i < 6; i++ {
+		leaf := sum256([]byte(fmt.Sprintf("leaf %d", i)))
+		_, err := tree.Append(leaf)
+		require.NoError(t, err)
+		leaves = append(leaves, leaf)
+	}
+	_, err = tree.ProofAt(6)
+	require.Error(t, err)
+
+	root := tree.Root()
+	proof, err := tree.ProofAt(2)
+	require.NoError(t, err)
+	require.NoError(t, VerifyIncrementalProof(root, leaves[2], 2, 4, proof))
+	require.Error(t, VerifyIncrementalProof(root, leaves[3], 2, 4, proof))
+	require.Error(t, VerifyIncrementalProof(root, leaves[2], 3, 4, proof))
+	require.Error(t, VerifyIncrementalProof(root, leaves[2], 2, 5, append(proof, make(TreeNode, 32))))
+	require.Error(t, VerifyIncrementalProof(root, leaves[2], 2, 4, proof[1:]))
+	require.Error(t, VerifyIncrementalProof(root, leaves[2], 16, 4, proof))
+}
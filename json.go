@@ -0,0 +1,221 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxUnmarshalDepth is the deepest tree UnmarshalJSON accepts, which bounds
+// the work done on untrusted input. It allows trees of up to 2^64 leaves.
+const MaxUnmarshalDepth = 64
+
+// ErrInvalidTreeJSON is returned by UnmarshalJSON for input that does not
+// describe a well formed merkle tree.
+var ErrInvalidTreeJSON = errors.New("error: invalid merkle tree json")
+
+// jsonNode is the JSON representation of a Node. A node whose right child is
+// omitted is paired with itself, and a padding duplicate omits its children,
+// which are those of the node it mirrors.
+type jsonNode struct {
+	Hash  string    `json:"hash"`
+	Leaf  bool      `json:"leaf"`
+	Dup   bool      `json:"dup"`
+	Left  *jsonNode `json:"left,omitempty"`
+	Right *jsonNode `json:"right,omitempty"`
+	// HashFunction names the hash function of the tree as registered with
+	// RegisterHash. Only the root carries it.
+	HashFunction string `json:"hash_function,omitempty"`
+	// Keyed is set on the root of a tree built with WithHMACKey, whose key is
+	// not encoded.
+	Keyed bool `json:"keyed,omitempty"`
+	// DigestSize is set on the root of a tree built with WithDigestSize to
+	// the size of its truncated hashes.
+	DigestSize int `json:"digest_size,omitempty"`
+}
+
+// MarshalJSON encodes the structure of the tree as nested nodes holding their
+// hex encoded hash, their children and whether they are leaves or padding
+// duplicates, the root also naming the hash function of the tree when it is
+// registered, whether it is keyed with WithHMACKey and the size its hashes are
+// truncated to with WithDigestSize. Items and keys are not encoded.
+func (m *MerkleTree) MarshalJSON() ([]byte, error) {
+	if m.Root == nil {
+		return nil, ErrNoContent
+	}
+
+	j := m.Root.toJSON()
+	if m.hashers != nil {
+		j.HashFunction = m.hashers.name
+		j.DigestSize = m.hashers.truncated
+	}
+	j.Keyed = m.keyed()
+
+	return json.Marshal(j)
+}
+
+func (n *Node) toJSON() *jsonNode {
+	j := &jsonNode{
+		Hash: hex.EncodeToString(n.Hash),
+		Leaf: n.leaf,
+		Dup:  n.dup,
+	}
+	if n.leaf || n.dup {
+		return j
+	}
+
+	j.Left = n.Left.toJSON()
+	if n.Right != n.Left {
+		j.Right = n.Right.toJSON()
+	}
+
+	return j
+}
+
+// UnmarshalJSON rebuilds the nodes, parent links and leaf order of a tree
+// encoded by MarshalJSON. The leaves come back without items, as after
+// ReleaseItems. Hashes are taken as given; the tree keeps the configuration of
+// the receiver, SHA-256 with default options for a zero MerkleTree, which must
+// match the one of the encoded tree for proofs to verify. A zero MerkleTree
+// takes the hash function the input names instead; input naming a function
+// that is not registered is refused with an UnknownHashError, and input
+// naming another function than the one of the receiver with
+// ErrInvalidTreeJSON. So is the input of a tree keyed with WithHMACKey unless
+// the receiver was built with a key, which is not checked, and the other way
+// round. A zero MerkleTree takes the digest size of a tree built with
+// WithDigestSize too, other receivers must have been built with the same, and
+// every hash of such a tree must be that long.
+//
+// The input is decoded within the default DecodeOptions; use
+// DecodeOptions.UnmarshalTreeJSON to set other limits.
+func (m *MerkleTree) UnmarshalJSON(data []byte) error {
+	return m.unmarshalJSON(data, DecodeOptions{}.WithDefaults())
+}
+
+func (m *MerkleTree) unmarshalJSON(data []byte, o DecodeOptions) error {
+	if err := o.Check("MaxInputSize", uint64(len(data)), o.MaxInputSize, ErrInvalidTreeJSON); err != nil {
+		return err
+	}
+
+	var root jsonNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	if root.Keyed != m.keyed() {
+		return fmt.Errorf("%w: keyed with HMAC: %t, receiver keyed: %t", ErrInvalidTreeJSON, root.Keyed, m.keyed())
+	}
+
+	hashStrategy := sha256.New
+	if root.HashFunction != "" {
+		var err error
+		if hashStrategy, err = LookupHash(root.HashFunction); err != nil {
+			return &UnknownHashError{Name: root.HashFunction, Err: ErrInvalidTreeJSON}
+		}
+		if m.hashFunc != nil {
+			if name := hashName(m.hashFunc); name != root.HashFunction {
+				return fmt.Errorf("%w: hashed with %q, not %q", ErrInvalidTreeJSON, root.HashFunction, name)
+			}
+		}
+	}
+	if m.hashFunc == nil {
+		m.hashFunc = hashStrategy
+	}
+	if m.hashers == nil {
+		m.digestSize = root.DigestSize
+		m.hashers = newHasherPool(&m.config)
+	}
+	if m.hashers.truncated != root.DigestSize {
+		return fmt.Errorf("%w: hashes truncated to %d bytes, receiver to %d", ErrInvalidTreeJSON, root.DigestSize, m.hashers.truncated)
+	}
+
+	var leaves []*Node
+	n, err := m.fromJSON(&root, nil, 0, &leaves, o)
+	if err != nil {
+		return err
+	}
+
+	// Only the last leaf may be a padding duplicate.
+	count := len(leaves)
+	if leaves[count-1].dup {
+		count--
+	}
+	if err := o.Check("MaxTreeSize", uint64(count), o.MaxTreeSize, ErrInvalidTreeJSON); err != nil {
+		return err
+	}
+	for _, l := range leaves[:count] {
+		if l.dup {
+			return fmt.Errorf("%w: misplaced padding duplicate", ErrInvalidTreeJSON)
+		}
+	}
+
+	m.dropItems = true
+	m.setTree(n, leaves, count)
+
+	return nil
+}
+
+// fromJSON decodes j as a child of parent, appending the leaves it reaches to
+// leaves in order.
+func (m *MerkleTree) fromJSON(j *jsonNode, parent *Node, depth int, leaves *[]*Node, o DecodeOptions) (*Node, error) {
+	if depth > MaxUnmarshalDepth {
+		return nil, fmt.Errorf("%w: deeper than %d levels", ErrInvalidTreeJSON, MaxUnmarshalDepth)
+	}
+	if err := o.Check("MaxHashSize", uint64(len(j.Hash)/2), o.MaxHashSize, ErrInvalidTreeJSON); err != nil {
+		return nil, err
+	}
+
+	hash, err := hex.DecodeString(j.Hash)
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("%w: bad hash %q", ErrInvalidTreeJSON, j.Hash)
+	}
+	if m.hashers.truncated != 0 && len(hash) != m.hashers.truncated {
+		return nil, fmt.Errorf("%w: %d byte hash in a tree of %d byte hashes", ErrInvalidTreeJSON, len(hash), m.hashers.truncated)
+	}
+
+	n := &Node{Hash: hash, Parent: parent, Tree: m, leaf: j.Leaf, dup: j.Dup}
+
+	switch {
+	case j.Dup:
+		// A duplicate mirrors the left child of its parent.
+		if parent == nil || parent.Left == nil || j.Left != nil || j.Right != nil {
+			return nil, fmt.Errorf("%w: misplaced padding duplicate", ErrInvalidTreeJSON)
+		}
+		mirror := parent.Left
+		if mirror.leaf != j.Leaf {
+			return nil, fmt.Errorf("%w: misplaced padding duplicate", ErrInvalidTreeJSON)
+		}
+		n.Left, n.Right, n.height = mirror.Left, mirror.Right, mirror.height
+		if n.leaf {
+			n.index = len(*leaves)
+			*leaves = append(*leaves, n)
+		}
+	case j.Leaf:
+		if j.Left != nil || j.Right != nil {
+			return nil, fmt.Errorf("%w: leaf with children", ErrInvalidTreeJSON)
+		}
+		if err := o.Check("MaxTreeSize", uint64(len(*leaves))+1, o.MaxTreeSize, ErrInvalidTreeJSON); err != nil {
+			return nil, err
+		}
+		n.index = len(*leaves)
+		*leaves = append(*leaves, n)
+	default:
+		if j.Left == nil {
+			return nil, fmt.Errorf("%w: internal node without children", ErrInvalidTreeJSON)
+		}
+		if n.Left, err = m.fromJSON(j.Left, n, depth+1, leaves, o); err != nil {
+			return nil, err
+		}
+		n.Right = n.Left
+		if j.Right != nil {
+			if n.Right, err = m.fromJSON(j.Right, n, depth+1, leaves, o); err != nil {
+				return nil, err
+			}
+		}
+		n.height = parentHeight(n.Left, n.Right)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		for n := 1; n <= 9; n++ {
+			contents := paddingContents(n)
+			tree, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			data, err := json.Marshal(tree)
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			decoded, _ := NewTreeWithOptions(paddingContents(1), WithPaddingStrategy(strategy))
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			if !bytes.Equal(decoded.MerkleRoot(), tree.MerkleRoot()) {
+				t.Errorf("[leaves:%d strategy:%d] error: expected root %x got %x", n, strategy, tree.MerkleRoot(), decoded.MerkleRoot())
+			}
+			if len(decoded.Leaves) != len(tree.Leaves) || decoded.LeafCount() != n {
+				t.Fatalf("[leaves:%d strategy:%d] error: expected %d leaves got %d", n, strategy, len(tree.Leaves), len(decoded.Leaves))
+			}
+			for i, l := range decoded.Leaves {
+				if !bytes.Equal(l.Hash, tree.Leaves[i].Hash) || l.Item != nil {
+					t.Errorf("[leaves:%d strategy:%d index:%d] error: expected item-less leaf %x got %x", n, strategy, i, tree.Leaves[i].Hash, l.Hash)
+				}
+			}
+			if decoded.Depth() != tree.Depth() {
+				t.Errorf("[leaves:%d strategy:%d] error: expected depth %d got %d", n, strategy, tree.Depth(), decoded.Depth())
+			}
+
+			for i, c := range contents {
+				path, err := decoded.MerklePath(c)
+				if err != nil {
+					t.Fatalf("[leaves:%d strategy:%d index:%d] error: unexpected error: %v", n, strategy, i, err)
+				}
+				if err := decoded.VerifyMerklePath(c, path); err != nil {
+					t.Errorf("[leaves:%d strategy:%d index:%d] error: expected valid path got %v", n, strategy, i, err)
+				}
+			}
+
+			if again, _ := json.Marshal(decoded); !bytes.Equal(again, data) {
+				t.Errorf("[leaves:%d strategy:%d] error: expected re-encoding to match", n, strategy)
+			}
+		}
+	}
+}
+
+func TestMarshalJSONShape(t *testing.T) {
+	tree, _ := NewTree(paddingContents(1))
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	for _, key := range []string{"hash", "leaf", "dup", "left", "right"} {
+		if _, ok := root[key]; !ok {
+			t.Errorf("error: expected key %q in %s", key, data)
+		}
+	}
+	if right := root["right"].(map[string]interface{}); right["dup"] != true {
+		t.Errorf("error: expected the padding leaf to be marked dup in %s", data)
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	deep := strings.Repeat(`{"hash":"00","left":`, MaxUnmarshalDepth+1) + `{"hash":"00","leaf":true}` + strings.Repeat(`}`, MaxUnmarshalDepth+1)
+
+	for i, data := range []string{
+		`{"hash":"zz","leaf":true}`,
+		`{"hash":"","leaf":true}`,
+		`{"hash":"00"}`,
+		`{"hash":"00","leaf":true,"left":{"hash":"00","leaf":true}}`,
+		`{"hash":"00","dup":true,"leaf":true}`,
+		`{"hash":"00","left":{"hash":"01","leaf":true,"dup":true},"right":{"hash":"02","leaf":true}}`,
+		deep,
+	} {
+		var tree MerkleTree
+		if err := json.Unmarshal([]byte(data), &tree); !errors.Is(err, ErrInvalidTreeJSON) {
+			t.Errorf("[case:%d] error: expected ErrInvalidTreeJSON got %v", i, err)
+		}
+		if tree.Root != nil {
+			t.Errorf("[case:%d] error: expected the tree to be left untouched", i)
+		}
+	}
+}
+
+func TestUnmarshalJSONZeroTree(t *testing.T) {
+	contents := paddingContents(6)
+	tree, _ := NewTree(contents)
+	data, _ := json.Marshal(tree)
+
+	var decoded MerkleTree
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !bytes.Equal(decoded.MerkleRoot(), tree.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", tree.MerkleRoot(), decoded.MerkleRoot())
+	}
+	if err := decoded.RebuildTree(); err != ErrItemsReleased {
+		t.Errorf("error: expected ErrItemsReleased got %v", err)
+	}
+	if err := decoded.UpdateLeaf(contents[2], TestSHA256Content{x: "updated"}); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+}
+
+func FuzzTreeUnmarshalJSON(f *testing.F) {
+	for n := 1; n <= 5; n++ {
+		tree, _ := NewTree(paddingContents(n))
+		data, _ := json.Marshal(tree)
+		f.Add(data)
+	}
+	f.Add([]byte(`{"hash":"00","left":{"hash":"01","leaf":true},"right":{"hash":"01","leaf":true,"dup":true}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tree MerkleTree
+		if err := tree.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		if _, err := json.Marshal(&tree); err != nil {
+			t.Fatalf("error: cannot encode a decoded tree: %v", err)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package merklego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrBranchingFactor is returned by the operations of a FlatMerkleTree that
+// do not support its branching factor: the binary proofs of Proof, Prove and
+// the like for trees finalized with WithBranchingFactor above 2, and the k-ary
+// proofs of KaryProof for binary trees.
+var ErrBranchingFactor = errors.New("Operation not supported for the branching factor of the tree")
+
+// WithBranchingFactor gives every internal node of a FlatMerkleTree k
+// children, hashed as H(0x01 || child0 || ... || childk-1), so a proof is
+// log_k(n) levels deep instead of log_2(n), each of k-1 siblings. k of 2, the
+// default, builds the usual binary tree, with its roots and proofs.
+//
+// The nodes are stored as a k-ary heap whose leaves fill the last slots, as
+// in a binary tree: the children of the node at i are at k*i+1 to k*i+k.
+// Above 2, the blocks are padded to the smallest count such a tree holds, at
+// least k unless WithSingleLeafRoot roots a single block, with the distinct
+// padding leaf of WithDistinctPadding, so the root commits to the number of
+// blocks. Sorted pairs order the k children of every node; a pair hash cannot
+// hash them and is refused by Finalize. Proofs are given by KaryProof and
+// checked by VerifyKary and VerifyKaryProof, the binary ones returning
+// ErrBranchingFactor. It has no effect on a MerkleTree.
+func WithBranchingFactor(k int) Option {
+	return func(c *config) {
+		c.branchingFactor = k
+	}
+}
+
+// branching returns the branching factor of the tree, 2 unless set with
+// WithBranchingFactor.
+func (c *config) branching() int {
+	if c.branchingFactor == 0 {
+		return 2
+	}
+
+	return c.branchingFactor
+}
+
+// checkBranching returns an error for branching factors below 2.
+func (c *config) checkBranching() error {
+	if k := c.branching(); k < 2 {
+		return fmt.Errorf("invalid branching factor %d", k)
+	}
+
+	return nil
+}
+
+// checkBinary returns ErrBranchingFactor unless the tree is binary.
+func (c *config) checkBinary() error {
+	if c.branching() != 2 {
+		return ErrBranchingFactor
+	}
+
+	return nil
+}
+
+// karyDepth returns the depth of the node at position idx of a k-ary heap, the
+// root being at depth 0, and the position of the first node at that depth.
+func karyDepth(idx, k int) (depth, start int) {
+	for width := 1; idx >= start+width; width *= k {
+		start += width
+		depth++
+	}
+
+	return depth, start
+}
+
+// KaryProofStep is a level of the proof of a block of a tree of branching
+// factor k: the position of the node in the group of k children of its
+// parent, from 0, and its k-1 siblings in the order of the group.
+type KaryProofStep struct {
+	Position int
+	Siblings []TreeNode
+}
+
+// karyWidth returns the number of leaves of a tree of size blocks, size
+// padded to 1 + a multiple of k-1, the leaf counts of full k-ary trees.
+func (c *config) karyWidth(size int) int {
+	k := c.branching()
+	if size == 1 {
+		if c.singleLeafRoot {
+			return 1
+		}
+		return k
+	}
+
+	return size + (k-1-(size-1)%(k-1))%(k-1)
+}
+
+// finalizeKary pads the blocks and hashes the nodes of a tree of branching
+// factor above 2, bottom up.
+func (mt *FlatMerkleTree) finalizeKary() {
+	k := mt.branching()
+	width := mt.karyWidth(mt.count)
+	for len(mt.blocks) < width {
+		mt.blocks = append(mt.blocks, mt.blocks[mt.count-1])
+	}
+
+	first := (width - 1) / (k - 1)
+	mt.nodes = make([]TreeNode, first+width)
+	for i, b := range mt.blocks[:mt.count] {
+		mt.nodes[first+i] = mt.hashSaltedLeaf(mt.saltAt(i), b)
+	}
+	if width > mt.count {
+		h := mt.newHash()
+		h.Write([]byte{paddingNodePrefix})
+		h.Write(mt.nodes[first+mt.count-1])
+		padding := TreeNode(h.Sum(nil))
+		for j := first + mt.count; j < len(mt.nodes); j++ {
+			mt.nodes[j] = padding
+		}
+	}
+
+	for i := first - 1; i >= 0; i-- {
+		mt.nodes[i] = mt.hashGroup(mt.nodes[k*i+1 : k*i+k+1])
+	}
+	mt.root = mt.nodes[0]
+}
+
+// hashGroup hashes the children of a node into it, ordering a copy of them
+// first when the tree uses sorted pairs.
+func (mt *FlatMerkleTree) hashGroup(children []TreeNode) TreeNode {
+	if mt.sortedPairs {
+		children = append([]TreeNode(nil), children...)
+		sort.Slice(children, func(i, j int) bool {
+			return bytes.Compare(children[i], children[j]) < 0
+		})
+	}
+
+	h := mt.newHash()
+	h.Write(mt.nodePrefixBytes())
+	for _, child := range children {
+		h.Write(child)
+	}
+
+	return TreeNode(h.Sum(nil))
+}
+
+// KaryProof returns the proof of block in a tree finalized with
+// WithBranchingFactor above 2: for the leaf and each of its ancestors, from the
+// leaf up, its position among its siblings and the siblings. Binary trees get
+// ErrBranchingFactor and have Proof.
+func (mt *FlatMerkleTree) KaryProof(block Block) ([]KaryProofStep, error) {
+	if block == nil {
+		return nil, ErrNilBlock
+	}
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if mt.branching() == 2 {
+		return nil, ErrBranchingFactor
+	}
+
+	idx, err := mt.findLeaf(block)
+	if err != nil {
+		return nil, err
+	}
+
+	k := mt.branching()
+	var proof []KaryProofStep
+	for ; idx > 0; idx = (idx - 1) / k {
+		first := (idx-1)/k*k + 1
+		step := KaryProofStep{Position: idx - first, Siblings: make([]TreeNode, 0, k-1)}
+		for j := first; j < first+k; j++ {
+			if j != idx {
+				step.Siblings = append(step.Siblings, copyNode(mt.nodes[j]))
+			}
+		}
+		proof = append(proof, step)
+	}
+
+	return proof, nil
+}
+
+// VerifyKary checks proof, as returned by KaryProof, for block against the
+// root of the tree, as Verify does for binary trees.
+func (mt *FlatMerkleTree) VerifyKary(block Block, proof []KaryProofStep) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+
+	leafIdx, err := mt.findLeaf(block)
+	if err != nil {
+		return err
+	}
+	index := leafIdx - (len(mt.nodes) - len(mt.blocks))
+
+	return mt.verifyKaryLeaf(mt.root, mt.hashSaltedLeaf(mt.saltAt(index), block), index, mt.count, proof)
+}
+
+// VerifyKaryProof checks proof, as returned by KaryProof, for block, the one
+// at index, against the root of a FlatMerkleTree finalized over size blocks
+// with opts, which must hold its WithBranchingFactor. The position of the
+// node at every level only depends on index and size, and a proof whose steps
+// claim others is refused.
+func VerifyKaryProof(root []byte, block Block, index, size int, proof []KaryProofStep, opts ...Option) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+
+	return mt.verifyKaryLeaf(root, mt.hashLeaf(block), index, size, proof)
+}
+
+// verifyKaryLeaf folds proof into leaf, the leaf hash at index among size
+// blocks, and compares the result with root.
+func (mt *FlatMerkleTree) verifyKaryLeaf(root []byte, leaf TreeNode, index, size int, proof []KaryProofStep) error {
+	if err := mt.checkBranching(); err != nil {
+		return err
+	}
+	k := mt.branching()
+	if k == 2 {
+		return ErrBranchingFactor
+	}
+	if index < 0 || index >= size {
+		return fmt.Errorf("block index %d out of range [0, %d)", index, size)
+	}
+
+	var positions []int
+	for pos := (mt.karyWidth(size)-1)/(k-1) + index; pos > 0; pos = (pos - 1) / k {
+		positions = append(positions, (pos-1)%k)
+	}
+	if len(proof) != len(positions) {
+		return fmt.Errorf("invalid proof for leaf %d: got %d steps, want %d", index, len(proof), len(positions))
+	}
+
+	node := leaf
+	group := make([]TreeNode, k)
+	for i, step := range proof {
+		if step.Position != positions[i] {
+			return fmt.Errorf("invalid proof for leaf %d: step %d at position %d, want %d", index, i, step.Position, positions[i])
+		}
+		if len(step.Siblings) != k-1 {
+			return fmt.Errorf("invalid proof for leaf %d: step %d has %d siblings, want %d", index, i, len(step.Siblings), k-1)
+		}
+
+		copy(group, step.Siblings[:step.Position])
+		group[step.Position] = node
+		copy(group[step.Position+1:], step.Siblings[step.Position:])
+		node = mt.hashGroup(group)
+	}
+
+	if !RootEqual(node, root) {
+		return fmt.Errorf("invalid proof for leaf %d of %d; got: %X, want: %X", index, size, node.Bytes(), root)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// karyFixture returns the blocks of a tree of n leaves and the tree of
+// branching factor k finalized over them with opts.
+func karyFixture(t *testing.T, n, k int, opts ...Option) ([]Block, *FlatMerkleTree) {
+	blocks := make([]Block, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block %d", i))
+	}
+
+	tree := NewMerkleTree(blocks...)
+	require.NoError(t, tree.Finalize(append(opts, WithBranchingFactor(k))...))
+
+	return blocks, tree
+}
+
+func TestKaryBinaryRoots(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		blocks, tree := karyFixture(t, n, 2)
+
+		binary := NewMerkleTree(blocks...)
+		require.NoError(t, binary.Finalize())
+		require.Equal(t, binary.root, tree.root, "%d leaves", n)
+
+		_, err := tree.KaryProof(blocks[0])
+		require.ErrorIs(t, err, ErrBranchingFactor)
+	}
+}
+
+func TestKaryRoot(t *testing.T) {
+	blocks, tree := karyFixture(t, 5, 4)
+
+	leaves := make([][]byte, len(blocks))
+	for i, b := range blocks {
+		leaves[i] = sum256([]byte{leafNodePrefix}, b)
+	}
+	padding := sum256([]byte{paddingNodePrefix}, leaves[4])
+	node := sum256([]byte{internalNodePrefix}, leaves[3], leaves[4], padding, padding)
+	root := sum256([]byte{internalNodePrefix}, node, leaves[0], leaves[1], leaves[2])
+
+	require.Equal(t, root, tree.root.Bytes())
+	require.Len(t, tree.nodes, 9)
+}
+
+func TestKaryProofs(t *testing.T) {
+	sizes := map[int][]int{
+		4:  {},
+		16: {1, 2, 15, 17, 40, 100},
+	}
+	for n := 1; n <= 40; n++ {
+		sizes[4] = append(sizes[4], n)
+	}
+
+	for k, ns := range sizes {
+		for _, n := range ns {
+			blocks, tree := karyFixture(t, n, k)
+			for i, b := range blocks {
+				proof, err := tree.KaryProof(b)
+				require.NoError(t, err)
+				for _, step := range proof {
+					require.Len(t, step.Siblings, k-1)
+				}
+
+				require.NoError(t, tree.VerifyKary(b, proof), "k %d, %d leaves, leaf %d", k, n, i)
+				require.NoError(t, VerifyKaryProof(tree.root, b, i, n, proof, WithBranchingFactor(k)), "k %d, %d leaves, leaf %d", k, n, i)
+			}
+		}
+	}
+}
+
+func TestKaryProofTampering(t *testing.T) {
+	blocks, tree := karyFixture(t, 23, 4)
+	proof, err := tree.KaryProof(blocks[9])
+	require.NoError(t, err)
+	verify := func(index, size int, proof []KaryProofStep) error {
+		return VerifyKaryProof(tree.root, blocks[9], index, size, proof, WithBranchingFactor(4))
+	}
+	require.NoError(t, verify(9, 23, proof))
+
+	require.Error(t, verify(10, 23, proof))
+	require.Error(t, verify(9, 60, proof))
+	require.Error(t, verify(9, 9, proof))
+	require.Error(t, verify(9, 23, proof[1:]))
+	require.ErrorIs(t, VerifyKaryProof(tree.root, blocks[9], 9, 23, proof), ErrBranchingFactor)
+
+	moved := append([]KaryProofStep(nil), proof...)
+	moved[0] = KaryProofStep{Position: (proof[0].Position + 1) % 4, Siblings: proof[0].Siblings}
+	require.Error(t, verify(9, 23, moved))
+
+	short := append([]KaryProofStep(nil), proof...)
+	short[0] = KaryProofStep{Position: proof[0].Position, Siblings: proof[0].Siblings[1:]}
+	require.Error(t, verify(9, 23, short))
+
+	swapped := append([]KaryProofStep(nil), proof...)
+	siblings := append([]TreeNode(nil), proof[0].Siblings...)
+	siblings[0], siblings[1] = siblings[1], siblings[0]
+	swapped[0] = KaryProofStep{Position: proof[0].Position, Siblings: siblings}
+	require.Error(t, verify(9, 23, swapped))
+
+	require.Error(t, tree.VerifyKary(blocks[8], proof))
+}
+
+func TestKarySortedPairs(t *testing.T) {
+	blocks, tree := karyFixture(t, 11, 4, WithSortedPairs(true))
+	for i, b := range blocks {
+		proof, err := tree.KaryProof(b)
+		require.NoError(t, err)
+		require.NoError(t, VerifyKaryProof(tree.root, b, i, len(blocks), proof, WithBranchingFactor(4), WithSortedPairs(true)))
+	}
+}
+
+func TestKaryUnsupported(t *testing.T) {
+	blocks, tree := karyFixture(t, 10, 4)
+
+	_, err := tree.Proof(blocks[0])
+	require.ErrorIs(t, err, ErrBranchingFactor)
+	_, err = tree.Prove(blocks[0])
+	require.ErrorIs(t, err, ErrBranchingFactor)
+	_, err = tree.ProveRange(0, 2)
+	require.ErrorIs(t, err, ErrBranchingFactor)
+
+	require.Error(t, NewMerkleTree(blocks...).Finalize(WithBranchingFactor(1)))
+	require.Error(t, NewMerkleTree(blocks...).Finalize(WithBranchingFactor(4), WithPairHash(func(left, right []byte) []byte { return sum256(left, right) })))
+}
+
+func TestKarySnapshot(t *testing.T) {
+	blocks, tree := karyFixture(t, 10, 16)
+	s, err := tree.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, 16, s.BranchingFactor)
+
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	require.Equal(t, tree.root, restored.root)
+
+	proof, err := restored.KaryProof(blocks[3])
+	require.NoError(t, err)
+	require.NoError(t, restored.VerifyKary(blocks[3], proof))
+}
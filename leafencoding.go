@@ -0,0 +1,46 @@
+package merklego
+
+import "encoding/binary"
+
+// LengthPrefixedLeaf returns the canonical encoding of the leaf data: the
+// 0x00 leaf prefix, the length of data as an unsigned LEB128 varint, as
+// encoding/binary's PutUvarint writes it, then data. Trees built with
+// WithLengthPrefixedLeaves hash it in place of the bare data, so that no two
+// leaves encode to the same bytes: without the length, the leaves "ab" || "c"
+// and "a" || "bc" of content hashing several fields are one and the same.
+//
+// It is the leaf encoding to implement for other languages to match the
+// roots of this package byte for byte; testdata/length_prefixed_leaves.json
+// holds test vectors.
+func LengthPrefixedLeaf(data []byte) []byte {
+	return lengthPrefixed([]byte{leafNodePrefix}, data)
+}
+
+// lengthPrefixed returns the LengthPrefixedLeaf encoding of data with prefix
+// in place of 0x00, for trees built with WithLeafPrefix.
+func lengthPrefixed(prefix, data []byte) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varint[:], uint64(len(data)))
+
+	out := make([]byte, 0, len(prefix)+n+len(data))
+	out = append(out, prefix...)
+	out = append(out, varint[:n]...)
+
+	return append(out, data...)
+}
+
+// hashLeaf returns the leaf hash of block: the hash of its LengthPrefixedLeaf
+// encoding with WithLengthPrefixedLeaves, of block with the leaf prefix
+// otherwise.
+func (mt *FlatMerkleTree) hashLeaf(block []byte) TreeNode {
+	h := mt.newHash()
+	if mt.lengthPrefixedLeaves {
+		h.Write(lengthPrefixed(mt.leafPrefixBytes(), block))
+		return TreeNode(h.Sum(nil))
+	}
+
+	h.Write(mt.leafPrefixBytes())
+	h.Write(block)
+
+	return TreeNode(h.Sum(nil))
+}
@@ -0,0 +1,187 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLengthPrefixedLeaf(t *testing.T) {
+	require.Equal(t, []byte{0x00, 0x00}, LengthPrefixedLeaf(nil))
+	require.Equal(t, []byte{0x00, 0x01, 'a'}, LengthPrefixedLeaf([]byte("a")))
+
+	long := bytes.Repeat([]byte{0xff}, 200)
+	require.Equal(t, append([]byte{0x00, 0xc8, 0x01}, long...), LengthPrefixedLeaf(long))
+
+	// Framed fields concatenate unambiguously.
+	ab := append(LengthPrefixedLeaf([]byte("ab")), LengthPrefixedLeaf([]byte("c"))...)
+	bc := append(LengthPrefixedLeaf([]byte("a")), LengthPrefixedLeaf([]byte("bc"))...)
+	require.NotEqual(t, ab, bc)
+	require.Equal(t, []byte("ab"+"c"), []byte("a"+"bc"))
+}
+
+// leafVector is a test vector of the LengthPrefixedLeaf encoding.
+type leafVector struct {
+	Data     string `json:"data"`
+	Encoding string `json:"encoding"`
+	LeafHash string `json:"leaf_hash"`
+}
+
+// treeVector is a test vector of the root of a FlatMerkleTree finalized with
+// WithLengthPrefixedLeaves.
+type treeVector struct {
+	Blocks []string `json:"blocks"`
+	Root   string   `json:"root"`
+}
+
+// TestLengthPrefixedLeafVectors checks the vectors published for other
+// implementations. Leaf hashes are SHA-256 of the encoding; tree roots are
+// those of flat trees, whose internal nodes are SHA-256 of 0x01 || left ||
+// right and whose odd last leaf is paired with itself.
+func TestLengthPrefixedLeafVectors(t *testing.T) {
+	var vectors struct {
+		Leaves []leafVector `json:"leaves"`
+		Trees  []treeVector `json:"trees"`
+	}
+
+	for _, data := range [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("ab"),
+		[]byte("abc"),
+		bytes.Repeat([]byte{'x'}, 127),
+		bytes.Repeat([]byte{'x'}, 128),
+		bytes.Repeat([]byte{'x'}, 200),
+	} {
+		enc := LengthPrefixedLeaf(data)
+		sum := sha256.Sum256(enc)
+		vectors.Leaves = append(vectors.Leaves, leafVector{
+			Data:     hex.EncodeToString(data),
+			Encoding: hex.EncodeToString(enc),
+			LeafHash: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	for _, blocks := range [][]string{
+		{""},
+		{"a", "b"},
+		{"a", "b", "c"},
+		{"ab", "c"},
+		{"a", "bc"},
+		{"block-0", "block-1", "block-2", "block-3", "block-4"},
+	} {
+		bs := make([]Block, len(blocks))
+		v := treeVector{Blocks: make([]string, len(blocks))}
+		for i, b := range blocks {
+			bs[i] = Block(b)
+			v.Blocks[i] = hex.EncodeToString(bs[i])
+		}
+
+		mt := NewMerkleTree(bs...)
+		require.NoError(t, mt.Finalize(WithLengthPrefixedLeaves(true)))
+		root, err := mt.RootHash()
+		require.NoError(t, err)
+		v.Root = hex.EncodeToString(root)
+		vectors.Trees = append(vectors.Trees, v)
+	}
+
+	got, err := json.MarshalIndent(vectors, "", "  ")
+	require.NoError(t, err)
+	checkGolden(t, "length_prefixed_leaves.json", append(got, '\n'))
+}
+
+func TestLengthPrefixedFlatTree(t *testing.T) {
+	blocks := []Block{Block("ab"), Block("c"), Block("a"), Block("bc"), Block("d")}
+	plain := NewMerkleTree(blocks...)
+	require.NoError(t, plain.Finalize())
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(WithLengthPrefixedLeaves(true)))
+
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+	plainRoot, err := plain.RootHash()
+	require.NoError(t, err)
+	require.NotEqual(t, plainRoot, root)
+
+	for i, b := range blocks {
+		sum := sha256.Sum256(LengthPrefixedLeaf(b))
+		proof, err := mt.Proof(b)
+		require.NoError(t, err)
+		require.NoError(t, mt.Verify(b, proof))
+		require.NoError(t, VerifyFlatProof(root, b, i, len(blocks), proof, WithLengthPrefixedLeaves(true)))
+		require.Error(t, VerifyFlatProof(root, b, i, len(blocks), proof))
+		require.NoError(t, VerifyFlatLeafHash(root, sum[:], i, len(blocks), proof, WithLengthPrefixedLeaves(true)))
+
+		p, err := mt.Prove(b)
+		require.NoError(t, err)
+		require.True(t, p.LengthPrefixedLeaves)
+		require.Equal(t, sum[:], p.LeafHash)
+		require.NoError(t, mt.VerifyProof(b, p))
+		require.NoError(t, p.Verify(root))
+		leaf, err := p.hashLeaf(b, nil)
+		require.NoError(t, err)
+		require.Equal(t, p.LeafHash, leaf)
+
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, p, &decoded)
+		js, err := json.Marshal(p)
+		require.NoError(t, err)
+		require.Equal(t, len(js), p.EncodedSize(ProofJSON))
+	}
+
+	rp, err := mt.ProveRange(1, 4)
+	require.NoError(t, err)
+	require.NoError(t, VerifyRange(root, 1, [][]byte{blocks[1], blocks[2], blocks[3]}, rp))
+
+	op, err := mt.ProveOrder(blocks[0], blocks[4])
+	require.NoError(t, err)
+	require.NoError(t, VerifyOrder(root, blocks[0], blocks[4], op))
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	got, err := restored.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, root, got)
+
+	sorted := NewSortedTree(blocks[:4]...)
+	require.NoError(t, sorted.Finalize(WithLengthPrefixedLeaves(true)))
+	sortedRoot, err := sorted.RootHash()
+	require.NoError(t, err)
+	ap, err := sorted.ProveAbsence(blocks[4])
+	require.NoError(t, err)
+	require.NoError(t, VerifyAbsence(sortedRoot, blocks[4], ap))
+}
+
+func TestLengthPrefixedMerkleTree(t *testing.T) {
+	for _, ds := range []bool{false, true} {
+		tree, err := NewTreeWithOptions(paddingContents(5), WithLengthPrefixedLeaves(true), WithDomainSeparation(ds))
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i, l := range tree.RealLeaves() {
+			itemHash, err := paddingContents(5)[i].CalculateHash()
+			require.NoError(t, err)
+			sum := sha256.Sum256(LengthPrefixedLeaf(itemHash))
+			require.Equal(t, sum[:], l.Hash, "domain separation:%v index:%d", ds, i)
+
+			p, err := tree.Prove(l.Hash)
+			require.NoError(t, err)
+			require.True(t, p.LengthPrefixedLeaves)
+			require.NoError(t, tree.VerifyProof(l.Hash, p))
+			require.NoError(t, p.Verify(root))
+			leaf, err := p.hashLeaf(itemHash, nil)
+			require.NoError(t, err)
+			require.Equal(t, l.Hash, leaf)
+		}
+	}
+}
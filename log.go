@@ -0,0 +1,187 @@
+package merklego
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrLogFork is returned by LogClient.Update for a signed tree head that is
+// validly signed but not consistent with the head the client trusts: the log
+// rewrote its past, or shows different histories to different clients.
+var ErrLogFork = errors.New("error: signed tree head is inconsistent with the trusted head")
+
+// Log is an append-only log of blocks as transparency logs keep them: a
+// LogTree whose heads it signs, serving proofs that a block is in a head and
+// that a head extends an earlier one. Signed tree heads are SignedRoots of the
+// SHA-256 RFC 6962 tree; LogClient follows them. A Log can be used from
+// several goroutines.
+type Log struct {
+	mu     sync.RWMutex
+	tree   *LogTree
+	signer crypto.Signer
+	opts   crypto.SignerOpts
+}
+
+// NewLog returns an empty Log signing its heads with signer, an Ed25519 or
+// ECDSA P-256 key, and opts as SignRoot takes them.
+func NewLog(signer crypto.Signer, opts crypto.SignerOpts) (*Log, error) {
+	switch signer.Public().(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("error: unsupported signing key %T", signer.Public())
+	}
+
+	tree, err := NewLogTree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{tree: tree, signer: signer, opts: opts}, nil
+}
+
+// Append adds block to the log and returns its index.
+func (l *Log) Append(block Block) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.tree.Append(block); err != nil {
+		return 0, err
+	}
+
+	return l.tree.Size() - 1, nil
+}
+
+// Size returns the number of blocks of the log.
+func (l *Log) Size() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.tree.Size()
+}
+
+// SignedTreeHead signs the current head of the log.
+func (l *Log) SignedTreeHead() (*SignedRoot, error) {
+	l.mu.RLock()
+	root, err := l.tree.RootHash()
+	size := l.tree.Size()
+	l.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return signRoot(l.signer, l.opts, &SignedRoot{Root: root, Size: size, Hash: "sha256"})
+}
+
+// InclusionProof returns the proof of the block at index in the head of size
+// blocks, which VerifyInclusion and LogClient.VerifyInclusion check.
+func (l *Log) InclusionProof(index, size int) ([]TreeNode, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.tree.InclusionProof(index, size)
+}
+
+// ConsistencyProof returns the proof that the head of newSize blocks extends
+// the one of oldSize, which VerifyConsistency and LogClient.Update check.
+func (l *Log) ConsistencyProof(oldSize, newSize int) ([]TreeNode, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.tree.ConsistencyProof(oldSize, newSize)
+}
+
+// LogClient follows a Log from the signed tree heads it publishes, keeping the
+// latest one it verified: a new head is only accepted with a valid signature
+// and a proof that it extends the trusted one, so a client never goes back on
+// the history it has seen.
+type LogClient struct {
+	pub  crypto.PublicKey
+	head *SignedRoot
+}
+
+// NewLogClient returns a client of the log signing with the key of pub,
+// trusting head, nil to start from the empty log. A head is checked against
+// pub.
+func NewLogClient(pub crypto.PublicKey, head *SignedRoot) (*LogClient, error) {
+	c := &LogClient{pub: pub}
+	if head != nil {
+		if err := c.checkHead(head); err != nil {
+			return nil, err
+		}
+		c.head = head
+	}
+
+	return c, nil
+}
+
+// Head returns the trusted head, nil until a head is accepted.
+func (c *LogClient) Head() *SignedRoot {
+	return c.head
+}
+
+// Size returns the size of the trusted head, the oldSize of the consistency
+// proof Update needs.
+func (c *LogClient) Size() int {
+	if c.head == nil {
+		return 0
+	}
+
+	return c.head.Size
+}
+
+// Update checks head, signed by the log, and proof, the consistency proof from
+// the size of the trusted head to the one of head, and trusts head if both
+// hold. Heads smaller or older than the trusted one are refused, and a head of
+// the same size must have the same root. A validly signed head that does not
+// extend the trusted one is refused with an error wrapping ErrLogFork, which
+// evidences a misbehaving log. On error the trusted head is kept.
+func (c *LogClient) Update(head *SignedRoot, proof []TreeNode) error {
+	if err := c.checkHead(head); err != nil {
+		return err
+	}
+	if head.Size < c.Size() {
+		return fmt.Errorf("error: signed tree head of %d blocks is older than the trusted head of %d", head.Size, c.Size())
+	}
+
+	oldRoot := sha256.Sum256(nil)
+	if c.head != nil {
+		if head.Timestamp.Before(c.head.Timestamp) {
+			return fmt.Errorf("error: signed tree head of %v is older than the trusted head of %v", head.Timestamp, c.head.Timestamp)
+		}
+		copy(oldRoot[:], c.head.Root)
+	}
+	if err := VerifyConsistency(oldRoot[:], head.Root, c.Size(), head.Size, proof); err != nil {
+		return fmt.Errorf("%w: %v", ErrLogFork, err)
+	}
+	c.head = head
+
+	return nil
+}
+
+// VerifyInclusion checks that proof shows block to be at index in the trusted
+// head.
+func (c *LogClient) VerifyInclusion(block Block, index int, proof []TreeNode) error {
+	if c.head == nil {
+		return fmt.Errorf("error: no trusted signed tree head")
+	}
+
+	return VerifyInclusion(c.head.Root, block, index, c.head.Size, proof)
+}
+
+// checkHead checks the signature of head and that it is one of an RFC 6962
+// SHA-256 tree.
+func (c *LogClient) checkHead(head *SignedRoot) error {
+	if head == nil {
+		return fmt.Errorf("error: nil signed tree head")
+	}
+	if head.Hash != "sha256" || len(head.Root) != sha256.Size {
+		return fmt.Errorf("error: signed tree head of a %d byte %q root is not one of a log", len(head.Root), head.Hash)
+	}
+
+	return VerifySignedRoot(c.pub, head)
+}
@@ -0,0 +1,142 @@
+package merklego
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogClientFollowsLog(t *testing.T) {
+	ed, ec := testSigningKeys(t)
+	for _, key := range []struct {
+		signer crypto.Signer
+		opts   crypto.SignerOpts
+	}{{ed, crypto.Hash(0)}, {ec, crypto.SHA256}} {
+		log, err := NewLog(key.signer, key.opts)
+		require.NoError(t, err)
+		client, err := NewLogClient(key.signer.Public(), nil)
+		require.NoError(t, err)
+
+		var blocks []Block
+		for round := 0; round < 6; round++ {
+			for i := 0; i < round*3+1; i++ {
+				block := Block(fmt.Sprintf("entry %d", len(blocks)))
+				index, err := log.Append(block)
+				require.NoError(t, err)
+				require.Equal(t, len(blocks), index)
+				blocks = append(blocks, block)
+			}
+
+			head, err := log.SignedTreeHead()
+			require.NoError(t, err)
+			require.Equal(t, log.Size(), head.Size)
+
+			proof, err := log.ConsistencyProof(client.Size(), head.Size)
+			require.NoError(t, err)
+			require.NoError(t, client.Update(head, proof))
+			require.Equal(t, head, client.Head())
+
+			for _, index := range []int{0, len(blocks) / 2, len(blocks) - 1} {
+				proof, err := log.InclusionProof(index, client.Size())
+				require.NoError(t, err)
+				require.NoError(t, client.VerifyInclusion(blocks[index], index, proof))
+				require.Error(t, client.VerifyInclusion(Block("missing"), index, proof))
+			}
+		}
+
+		// The same head again is accepted with an empty proof.
+		head := client.Head()
+		require.NoError(t, client.Update(head, []TreeNode{}))
+	}
+}
+
+func TestLogClientDetectsFork(t *testing.T) {
+	ed, _ := testSigningKeys(t)
+	log, err := NewLog(ed, crypto.Hash(0))
+	require.NoError(t, err)
+	fork, err := NewLog(ed, crypto.Hash(0))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err := log.Append(Block(fmt.Sprintf("entry %d", i)))
+		require.NoError(t, err)
+		if i == 4 {
+			_, err = fork.Append(Block("rewritten"))
+		} else {
+			_, err = fork.Append(Block(fmt.Sprintf("entry %d", i)))
+		}
+		require.NoError(t, err)
+	}
+
+	head, err := log.SignedTreeHead()
+	require.NoError(t, err)
+	client, err := NewLogClient(ed.Public(), head)
+	require.NoError(t, err)
+
+	// A fork of the same size shows a different root.
+	forked, err := fork.SignedTreeHead()
+	require.NoError(t, err)
+	require.ErrorIs(t, client.Update(forked, []TreeNode{}), ErrLogFork)
+
+	// A fork growing further cannot prove it extends the trusted head.
+	_, err = fork.Append(Block("entry 10"))
+	require.NoError(t, err)
+	forked, err = fork.SignedTreeHead()
+	require.NoError(t, err)
+	proof, err := fork.ConsistencyProof(10, 11)
+	require.NoError(t, err)
+	require.ErrorIs(t, client.Update(forked, proof), ErrLogFork)
+	require.Equal(t, head, client.Head())
+
+	// The honest log still can.
+	_, err = log.Append(Block("entry 10"))
+	require.NoError(t, err)
+	next, err := log.SignedTreeHead()
+	require.NoError(t, err)
+	proof, err = log.ConsistencyProof(10, 11)
+	require.NoError(t, err)
+	require.NoError(t, client.Update(next, proof))
+
+	// Older heads are refused.
+	require.Error(t, client.Update(head, []TreeNode{}))
+}
+
+func TestLogClientRejectsHeads(t *testing.T) {
+	ed, ec := testSigningKeys(t)
+	log, err := NewLog(ed, crypto.Hash(0))
+	require.NoError(t, err)
+	_, err = log.Append(Block("entry 0"))
+	require.NoError(t, err)
+	head, err := log.SignedTreeHead()
+	require.NoError(t, err)
+
+	client, err := NewLogClient(ec.Public(), nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, client.Update(head, []TreeNode{}), ErrInvalidSignature)
+	require.Nil(t, client.Head())
+
+	_, err = NewLogClient(ec.Public(), head)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	client, err = NewLogClient(ed.Public(), nil)
+	require.NoError(t, err)
+	tampered := *head
+	tampered.Size = 2
+	require.ErrorIs(t, client.Update(&tampered, []TreeNode{}), ErrInvalidSignature)
+	require.Error(t, client.Update(nil, nil))
+	require.Error(t, client.VerifyInclusion(Block("entry 0"), 0, nil))
+
+	_, err = NewLog(unsupportedSigner{}, crypto.Hash(0))
+	require.Error(t, err)
+}
+
+// unsupportedSigner has a key of an unsupported type.
+type unsupportedSigner struct {
+	ed25519.PrivateKey
+}
+
+func (unsupportedSigner) Public() crypto.PublicKey {
+	return "not a key"
+}
@@ -0,0 +1,256 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// LogTree is an append-only merkle tree shaped as in RFC 6962, the structure
+// of Certificate Transparency logs: the root of n > 1 leaves hashes the root
+// of the first k leaves, k the largest power of two smaller than n, with the
+// root of the remaining ones, so nothing is padded. Leaves and internal nodes
+// are hashed as in a FlatMerkleTree, which are the RFC 6962 hashes.
+//
+// The tree stores, level by level, the hash of every complete subtree of a
+// power of two leaves, so a leaf is appended in O(log n) hashes and the root
+// or a proof of any earlier size is computed in O(log n) hashes.
+type LogTree struct {
+	// levels[l][i] is the root of the leaves i*2^l to (i+1)*2^l, levels[0]
+	// holding the leaf hashes.
+	levels [][]TreeNode
+}
+
+var _ Rooter = (*LogTree)(nil)
+
+// NewLogTree returns a LogTree holding blocks, in order.
+func NewLogTree(blocks ...Block) (*LogTree, error) {
+	t := &LogTree{}
+	for _, b := range blocks {
+		if err := t.Append(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Append adds block as the last leaf of the tree.
+func (t *LogTree) Append(block Block) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+
+	node := hashNode(block, false)
+	for l := 0; ; l++ {
+		if l == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[l] = append(t.levels[l], node)
+		if len(t.levels[l])%2 != 0 {
+			return nil
+		}
+
+		level := t.levels[l]
+		node = hashChildrenRFC(level[len(level)-2], level[len(level)-1])
+	}
+}
+
+// Size returns the number of leaves of the tree.
+func (t *LogTree) Size() int {
+	if len(t.levels) == 0 {
+		return 0
+	}
+
+	return len(t.levels[0])
+}
+
+// RootHash returns the root of the tree, the SHA-256 hash of the empty string
+// for an empty tree as in RFC 6962.
+func (t *LogTree) RootHash() ([]byte, error) {
+	if t.Size() == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:], nil
+	}
+
+	return copyNode(t.subtreeRoot(0, t.Size())), nil
+}
+
+// RootAtSize returns the root the log had when it held its first size leaves,
+// in O(log n) hashes from the complete subtrees it stores.
+func (t *LogTree) RootAtSize(size uint64) ([]byte, error) {
+	if size > uint64(t.Size()) {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", size, t.Size())
+	}
+	if size == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:], nil
+	}
+
+	return copyNode(t.subtreeRoot(0, int(size))), nil
+}
+
+// SubtreeHash returns the root of the complete subtree of the 2^level leaves
+// from index*2^level, one of the hashes the tree stores.
+func (t *LogTree) SubtreeHash(level, index int) ([]byte, error) {
+	if level < 0 || level >= len(t.levels) || index < 0 || index >= len(t.levels[level]) {
+		return nil, fmt.Errorf("no complete subtree at level %d, index %d in a tree of %d leaves", level, index, t.Size())
+	}
+
+	return copyNode(t.levels[level][index]), nil
+}
+
+// InclusionProof returns the audit path of RFC 6962, section 2.1.1, of the
+// leaf at index in the tree formed by the first size leaves.
+func (t *LogTree) InclusionProof(index, size int) ([]TreeNode, error) {
+	if size <= 0 || size > t.Size() {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", size, t.Size())
+	}
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("leaf index %d out of range [0, %d)", index, size)
+	}
+
+	proof := make([]TreeNode, 0, inclusionProofSize(uint64(index), uint64(size)))
+	proof = t.path(proof, index, 0, size)
+
+	return proof, nil
+}
+
+// ConsistencyProof returns the consistency proof of RFC 6962, section 2.1.2,
+// between the trees formed by the first oldSize and newSize leaves, which
+// VerifyConsistency checks. It is empty when oldSize is 0 or newSize.
+func (t *LogTree) ConsistencyProof(oldSize, newSize int) ([]TreeNode, error) {
+	if newSize < 0 || newSize > t.Size() {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", newSize, t.Size())
+	}
+	if oldSize < 0 || oldSize > newSize {
+		return nil, fmt.Errorf("invalid old tree size %d for a tree of %d leaves", oldSize, newSize)
+	}
+	if oldSize == 0 || oldSize == newSize {
+		return []TreeNode{}, nil
+	}
+
+	proof := make([]TreeNode, 0, consistencyProofSize(uint64(oldSize), uint64(newSize)))
+	proof = t.subproof(proof, oldSize, 0, newSize, true)
+
+	return proof, nil
+}
+
+// subproof appends SUBPROOF(m, D[start:end], complete) of RFC 6962, m counting
+// from start.
+func (t *LogTree) subproof(proof []TreeNode, m, start, end int, complete bool) []TreeNode {
+	if m == end-start {
+		if complete {
+			return proof
+		}
+		return append(proof, copyNode(t.subtreeRoot(start, end)))
+	}
+
+	k := splitPoint(end - start)
+	if m <= k {
+		proof = t.subproof(proof, m, start, start+k, complete)
+		return append(proof, copyNode(t.subtreeRoot(start+k, end)))
+	}
+
+	proof = t.subproof(proof, m-k, start+k, end, false)
+	return append(proof, copyNode(t.subtreeRoot(start, start+k)))
+}
+
+// path appends the audit path of the leaf at index within the subtree of the
+// leaves start to end, from the leaf up.
+func (t *LogTree) path(proof []TreeNode, index, start, end int) []TreeNode {
+	if end-start == 1 {
+		return proof
+	}
+
+	k := splitPoint(end - start)
+	if index < start+k {
+		proof = t.path(proof, index, start, start+k)
+		return append(proof, copyNode(t.subtreeRoot(start+k, end)))
+	}
+
+	proof = t.path(proof, index, start+k, end)
+	return append(proof, copyNode(t.subtreeRoot(start, start+k)))
+}
+
+// subtreeRoot returns the root of the leaves start to end, start being a
+// multiple of the largest power of two not above end-start, as every subtree
+// of the RFC 6962 structure is.
+func (t *LogTree) subtreeRoot(start, end int) TreeNode {
+	n := end - start
+	if n&(n-1) == 0 {
+		l := 0
+		for 1<<l < n {
+			l++
+		}
+		return t.levels[l][start>>l]
+	}
+
+	k := splitPoint(n)
+	return hashChildrenRFC(t.subtreeRoot(start, start+k), t.subtreeRoot(start+k, end))
+}
+
+// VerifyInclusion checks that proof, an audit path as returned by
+// InclusionProof, shows block to be the leaf at index of the RFC 6962 shaped
+// tree of size leaves with the given root. It follows the verification
+// algorithm of RFC 9162, section 2.1.3.2, rejecting proofs of the wrong
+// length for index and size. Errors wrap ErrInvalidProof.
+func VerifyInclusion(root []byte, block Block, index, size int, proof []TreeNode) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+	if index < 0 || index >= size {
+		return fmt.Errorf("invalid inclusion proof: leaf index %d out of range [0, %d): %w", index, size, ErrInvalidProof)
+	}
+
+	want := inclusionProofSize(uint64(index), uint64(size))
+	if len(proof) != want {
+		return fmt.Errorf("invalid inclusion proof: got %d nodes, want %d for leaf %d of %d: %w", len(proof), want, index, size, ErrInvalidProof)
+	}
+
+	r := rootFromInclusionProof(hashNode(block, false), uint64(index), uint64(size), proof)
+	if !RootEqual(r, root) {
+		return fmt.Errorf("invalid inclusion proof for block %X; got: %X, want: %X: %w", block, r.Bytes(), root, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// rootFromInclusionProof returns the root the audit path proof leads to from
+// leaf, the leaf hash at index of a tree of size leaves, proof being of the
+// length inclusionProofSize expects.
+func rootFromInclusionProof(leaf TreeNode, index, size uint64, proof []TreeNode) TreeNode {
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if fn&1 == 1 || fn == sn {
+			r = hashChildrenRFC(p, r)
+			for fn != 0 && fn&1 == 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			r = hashChildrenRFC(r, p)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+
+	return r
+}
+
+// splitPoint returns the largest power of two smaller than n, n > 1.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// hashChildrenRFC hashes two nodes into their parent as RFC 6962 does.
+func hashChildrenRFC(left, right TreeNode) TreeNode {
+	data := make([]byte, 0, len(left)+len(right))
+	data = append(data, left...)
+	data = append(data, right...)
+
+	return hashNode(data, true)
+}
@@ -0,0 +1,273 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ctInputs are the leaves of the test vectors of the certificate transparency
+// reference implementations.
+var ctInputs = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+func ctLogTree(t *testing.T) *LogTree {
+	tree, err := NewLogTree()
+	require.NoError(t, err)
+	for _, in := range ctInputs {
+		data, _ := hex.DecodeString(in)
+		require.NoError(t, tree.Append(Block(data)))
+	}
+
+	return tree
+}
+
+func TestLogTreeRoots(t *testing.T) {
+	roots := []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+
+	empty, err := NewLogTree()
+	require.NoError(t, err)
+	root, err := empty.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(root))
+
+	tree, _ := NewLogTree()
+	for i, in := range ctInputs {
+		data, _ := hex.DecodeString(in)
+		require.NoError(t, tree.Append(Block(data)))
+
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		require.Equal(t, roots[i], hex.EncodeToString(root), fmt.Sprintf("size: %d", i+1))
+	}
+
+	require.Equal(t, ErrNilBlock, tree.Append(nil))
+}
+
+func TestLogTreeSubtreeHash(t *testing.T) {
+	tree := ctLogTree(t)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+
+	h, err := tree.SubtreeHash(3, 0)
+	require.NoError(t, err)
+	require.Equal(t, root, h)
+
+	h, err = tree.SubtreeHash(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte(hashNode(Block{0x10}, false)), h)
+
+	right, err := NewLogTree()
+	require.NoError(t, err)
+	for _, in := range ctInputs[4:] {
+		data, _ := hex.DecodeString(in)
+		require.NoError(t, right.Append(Block(data)))
+	}
+	want, err := right.RootHash()
+	require.NoError(t, err)
+	h, err = tree.SubtreeHash(2, 1)
+	require.NoError(t, err)
+	require.Equal(t, want, h)
+
+	for _, c := range [][2]int{{3, 1}, {4, 0}, {-1, 0}, {0, 8}, {1, -1}} {
+		_, err := tree.SubtreeHash(c[0], c[1])
+		require.Error(t, err, fmt.Sprintf("level: %d, index: %d", c[0], c[1]))
+	}
+}
+
+func TestLogTreeRFC6962Example(t *testing.T) {
+	// The 7 leaf tree of RFC 6962, section 2.1.3:
+	//
+	//	            hash
+	//	           /    \
+	//	          k      l
+	//	         / \    / \
+	//	        g   h  i   j
+	//	       / \ / \ / \  |
+	//	       a b c d e f  d6
+	tree := ctLogTree(t)
+	leaf := func(i int) TreeNode { return tree.levels[0][i] }
+	a, b, c, d, e, f, j := leaf(0), leaf(1), leaf(2), leaf(3), leaf(4), leaf(5), leaf(6)
+	g, h, i := hashChildrenRFC(a, b), hashChildrenRFC(c, d), hashChildrenRFC(e, f)
+	k, l := hashChildrenRFC(g, h), hashChildrenRFC(i, j)
+	root := hashChildrenRFC(k, l)
+
+	testCases := []struct {
+		index int
+		path  []TreeNode
+	}{
+		{index: 0, path: []TreeNode{b, h, l}},
+		{index: 3, path: []TreeNode{c, g, l}},
+		{index: 4, path: []TreeNode{f, j, k}},
+		{index: 6, path: []TreeNode{i, k}},
+	}
+	for _, tc := range testCases {
+		proof, err := tree.InclusionProof(tc.index, 7)
+		require.NoError(t, err)
+		require.Equal(t, tc.path, proof, fmt.Sprintf("index: %d", tc.index))
+
+		data, _ := hex.DecodeString(ctInputs[tc.index])
+		require.NoError(t, VerifyInclusion(root, Block(data), tc.index, 7, proof), fmt.Sprintf("index: %d", tc.index))
+	}
+
+	// The consistency proofs of the same section.
+	for _, c := range []struct {
+		size  int
+		proof []TreeNode
+	}{
+		{size: 3, proof: []TreeNode{c, d, g, l}},
+		{size: 4, proof: []TreeNode{l}},
+		{size: 6, proof: []TreeNode{i, j, k}},
+	} {
+		proof, err := tree.ConsistencyProof(c.size, 7)
+		require.NoError(t, err)
+		require.Equal(t, c.proof, proof, fmt.Sprintf("size: %d", c.size))
+
+		old := tree.subtreeRoot(0, c.size)
+		require.NoError(t, VerifyConsistency(old, root, c.size, 7, c.proof), fmt.Sprintf("size: %d", c.size))
+	}
+}
+
+func TestLogTreeConsistencyProof(t *testing.T) {
+	blocks := make([]Block, 17)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("leaf-%d", i))
+	}
+	tree, err := NewLogTree(blocks...)
+	require.NoError(t, err)
+	leaves := rfcLeaves(17)
+
+	for n := 1; n <= 17; n++ {
+		for m := 0; m <= n; m++ {
+			msg := fmt.Sprintf("m: %d, n: %d", m, n)
+			proof, err := tree.ConsistencyProof(m, n)
+			require.NoError(t, err, msg)
+			if m == 0 || m == n {
+				require.Empty(t, proof, msg)
+			} else {
+				require.Equal(t, rfcSubproof(m, leaves[:n], true), proof, msg)
+			}
+			require.Len(t, proof, cap(proof), msg)
+
+			oldRoot := rfcRoot(leaves[:m])
+			require.NoError(t, VerifyConsistency(oldRoot, rfcRoot(leaves[:n]), m, n, proof), msg)
+		}
+	}
+
+	for _, sizes := range [][2]int{{0, 18}, {-1, 3}, {4, 3}, {1, -1}} {
+		_, err := tree.ConsistencyProof(sizes[0], sizes[1])
+		require.Error(t, err, fmt.Sprintf("sizes: %v", sizes))
+	}
+}
+
+func TestLogTreeInclusionVectors(t *testing.T) {
+	tree := ctLogTree(t)
+	root8, _ := tree.RootHash()
+
+	testCases := []struct {
+		index, size int
+		path        []string
+	}{
+		{index: 0, size: 1},
+		{index: 0, size: 8, path: []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{index: 5, size: 8, path: []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{index: 2, size: 3, path: []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{index: 1, size: 5, path: []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, tc := range testCases {
+		msg := fmt.Sprintf("index: %d, size: %d", tc.index, tc.size)
+		proof, err := tree.InclusionProof(tc.index, tc.size)
+		require.NoError(t, err, msg)
+
+		got := make([]string, len(proof))
+		for i, p := range proof {
+			got[i] = hex.EncodeToString(p)
+		}
+		if tc.path == nil {
+			tc.path = []string{}
+		}
+		require.Equal(t, tc.path, got, msg)
+
+		root := tree.subtreeRoot(0, tc.size)
+		if tc.size == 8 {
+			require.Equal(t, TreeNode(root8), root, msg)
+		}
+		data, _ := hex.DecodeString(ctInputs[tc.index])
+		require.NoError(t, VerifyInclusion(root, Block(data), tc.index, tc.size, proof), msg)
+	}
+}
+
+func TestLogTreeInclusionMatrix(t *testing.T) {
+	blocks := make([]Block, 40)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+	}
+	tree, err := NewLogTree(blocks...)
+	require.NoError(t, err)
+
+	for size := 1; size <= len(blocks); size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = hashNode(blocks[i], false)
+		}
+		root := rfcRoot(leaves)
+		require.Equal(t, TreeNode(root), tree.subtreeRoot(0, size))
+
+		for index := 0; index < size; index++ {
+			msg := fmt.Sprintf("index: %d, size: %d", index, size)
+			proof, err := tree.InclusionProof(index, size)
+			require.NoError(t, err, msg)
+			require.NoError(t, VerifyInclusion(root, blocks[index], index, size, proof), msg)
+
+			require.True(t, errors.Is(VerifyInclusion(root, Block("other"), index, size, proof), ErrInvalidProof), msg)
+			require.Error(t, VerifyInclusion(root, blocks[index], index, size, append(proof, root)), msg)
+			if len(proof) > 0 {
+				require.Error(t, VerifyInclusion(root, blocks[index], index, size, proof[1:]), msg)
+			}
+			if index > 0 {
+				require.Error(t, VerifyInclusion(root, blocks[index], index-1, size, proof), msg)
+			}
+		}
+	}
+}
+
+func TestLogTreeInclusionErrors(t *testing.T) {
+	tree := ctLogTree(t)
+	root, _ := tree.RootHash()
+
+	for _, r := range [][2]int{{0, 0}, {0, 9}, {-1, 4}, {4, 4}} {
+		_, err := tree.InclusionProof(r[0], r[1])
+		require.Error(t, err, fmt.Sprintf("index: %d, size: %d", r[0], r[1]))
+	}
+
+	require.Equal(t, ErrNilBlock, VerifyInclusion(root, nil, 0, 8, nil))
+	require.Error(t, VerifyInclusion(root, Block("a"), 0, 0, nil))
+	require.Error(t, VerifyInclusion(root, Block("a"), -1, 8, nil))
+	require.Error(t, VerifyInclusion(root, Block("a"), 8, 8, nil))
+}
@@ -1,13 +1,85 @@
 package merklego
 
 import (
+	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
-// Storable represents an item in the merkle tree.
+// ErrNoContent is returned when a tree is built from an empty content slice.
+var ErrNoContent = errors.New("error: cannot make a merkle tree without any contents.")
+
+// ErrInvalidProof is returned when a merkle path does not lead to the root of the tree.
+var ErrInvalidProof = errors.New("error: merkle path does not lead to the merkle root")
+
+// ErrItemsReleased is returned when an operation needs the items of a tree
+// that was built with WithRetainItems(false) or had ReleaseItems called.
+var ErrItemsReleased = errors.New("error: the items of the merkle tree were released; rebuild it with RebuildTreeWith")
+
+// ErrLeafHashesOnly is returned when proofs by content are requested from a
+// tree built by NewTreeFromLeafHashes.
+var ErrLeafHashesOnly = errors.New("error: the merkle tree was built from leaf hashes; request proofs by index")
+
+// ErrContentNotFound is returned when the requested content is not stored in the tree.
+var ErrContentNotFound = errors.New("error: content not found in the merkle tree")
+
+// ContentError is returned when the content at Index cannot be hashed into a leaf.
+type ContentError struct {
+	Index int
+	Err   error
+}
+
+func (e *ContentError) Error() string {
+	return fmt.Sprintf("error: cannot hash content at index %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error returned while hashing the content.
+func (e *ContentError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateError is returned by trees configured with WithRejectDuplicates
+// when the items at First and Second hash to the same leaf.
+type DuplicateError struct {
+	First, Second int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("error: duplicate content at indices %d and %d", e.First, e.Second)
+}
+
+// findDuplicate returns a DuplicateError for the first two of the n keys
+// returned by key that are equal, or nil if they are all distinct.
+func findDuplicate(n int, key func(i int) []byte) error {
+	seen := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		k := string(key(i))
+		if first, ok := seen[k]; ok {
+			return &DuplicateError{First: first, Second: i}
+		}
+		seen[k] = i
+	}
+
+	return nil
+}
+
+// ProofStep is one step of a merkle path: the hash of the sibling of the
+// current node and whether that sibling is the left operand of their parent.
+type ProofStep struct {
+	Hash []byte
+	Left bool
+}
+
+// Storable represents an item in the merkle tree. The tree locates items by
+// comparing the results of CalculateHash and never calls Equals, so
+// implementations that type-assert in Equals are safe to mix; use SafeEquals
+// to compare items of possibly different types directly.
 type Storable interface {
 	CalculateHash() ([]byte, error)
 	Equals(other Storable) (bool, error)
@@ -16,8 +88,27 @@ type Storable interface {
 type MerkleTree struct {
 	Root       *Node
 	merkleRoot []byte
-	Leaves     []*Node
-	hashFunc   func() hash.Hash
+	// Leaves holds the leaf nodes in content order. With PadDuplicateLast and
+	// an odd number of items it ends with the duplicated padding leaf; use
+	// RealLeaves to get the items' leaves only.
+	Leaves []*Node
+	config
+	hashers *hasherPool
+
+	leafCount     int
+	depth         int
+	internalCount int
+	hashesOnly    bool
+
+	// leaves maps the hash of every leaf, padding excluded, to its first
+	// position in Leaves, and repeats the hashes stored several times to
+	// their other positions, sorted.
+	leaves  map[string]int
+	repeats map[string][]int
+
+	// version is the root of the last TreeVersion, whose unchanged subtrees
+	// the next one shares, or nil since the shape of the tree changed.
+	version *versionNode
 }
 
 type Node struct {
@@ -29,16 +120,38 @@ type Node struct {
 	Tree   *MerkleTree
 	dup    bool
 	leaf   bool
+	height int
+	index  int
 }
 
 //MerkleRoot returns the unverified Merkle Root (hash of the root node) of the tree.
+// The returned slice is a copy the caller may modify.
 func (m *MerkleTree) MerkleRoot() []byte {
+	if m.merkleRoot == nil {
+		return nil
+	}
+
+	return append([]byte(nil), m.merkleRoot...)
+}
+
+// MerkleRootUnsafe returns the merkle root of the tree without copying it. The
+// returned slice is the tree's own and must be treated as read-only.
+func (m *MerkleTree) MerkleRootUnsafe() []byte {
 	return m.merkleRoot
 }
 
 func (n *Node) VerifyNode() ([]byte, error) {
 	if n.leaf {
-		return n.Item.CalculateHash()
+		if n.Item == nil {
+			return nil, ErrItemsReleased
+		}
+
+		hash, err := n.Item.CalculateHash()
+		if err != nil {
+			return nil, err
+		}
+
+		return n.Tree.leafHash(hash)
 	}
 
 	leftBytes, err := n.Left.VerifyNode()
@@ -51,71 +164,459 @@ func (n *Node) VerifyNode() ([]byte, error) {
 		return nil, err
 	}
 
-	hf := n.Tree.hashFunc()
-	if _, err := hf.Write(append(leftBytes, rightBytes...)); err != nil {
+	return n.Tree.hashPair(leftBytes, rightBytes)
+}
+
+// NewTree creates a new merkle tree with the Storable contents in content.
+func NewTree(content []Storable) (*MerkleTree, error) {
+	return NewTreeWithOptions(content)
+}
+
+// NewTreeWithHashStrategy creates a new merkle tree with the Storable contents
+// in content, hashing its nodes with hashStrategy instead of SHA-256.
+func NewTreeWithHashStrategy(content []Storable, hashStrategy func() hash.Hash, opts ...Option) (*MerkleTree, error) {
+	return NewTreeWithOptions(content, append([]Option{WithHashStrategy(hashStrategy)}, opts...)...)
+}
+
+// NewTreeFromLeafHashes creates a merkle tree whose leaves hold the given
+// hashes, in order, and no items; hashStrategy hashes the levels above them.
+// Every hash must be as long as the digests of hashStrategy, or the size set
+// with WithDigestSize. Proofs are requested by index with MerklePathAt, while
+// MerklePath returns ErrLeafHashesOnly since the tree cannot tell how content
+// maps to its leaves.
+func NewTreeFromLeafHashes(hashes [][]byte, hashStrategy func() hash.Hash, opts ...Option) (*MerkleTree, error) {
+	if len(hashes) == 0 {
+		return nil, ErrNoContent
+	}
+
+	t := newMerkleTree(append([]Option{WithHashStrategy(hashStrategy)}, opts...))
+	t.dropItems = true
+	t.hashesOnly = true
+
+	leaves := make([]*Node, len(hashes), len(hashes)+1)
+	block := make([]Node, len(hashes))
+	for i, hash := range hashes {
+		if len(hash) != t.hashers.size {
+			return nil, fmt.Errorf("error: leaf hash at index %d is %d bytes long, expected %d", i, len(hash), t.hashers.size)
+		}
+
+		n := &block[i]
+		n.Hash = append([]byte(nil), hash...)
+		n.Tree = t
+		n.leaf = true
+		n.index = i
+		leaves[i] = n
+	}
+
+	root, leaves, err := buildAbove(context.Background(), leaves, t)
+	if err != nil {
 		return nil, err
 	}
 
-	return hf.Sum(nil), nil
+	if t.rejectDuplicates {
+		if err := findDuplicate(len(hashes), func(i int) []byte { return hashes[i] }); err != nil {
+			return nil, err
+		}
+	}
+
+	t.setTree(root, leaves, len(hashes))
+
+	return t, nil
 }
 
-// NewTree creates a new merkle tree with the Storable contents in content.
-func NewTree(content []Storable) (*MerkleTree, error) {
+// NewTreeWithOptions creates a new merkle tree with the Storable contents in
+// content, configured by opts.
+func NewTreeWithOptions(content []Storable, opts ...Option) (*MerkleTree, error) {
+	return NewTreeContext(context.Background(), content, opts...)
+}
+
+// NewTreeContext creates a new merkle tree with the Storable contents in
+// content, configured by opts. The construction is abandoned once ctx is done,
+// between two leaf hashes or two levels, returning ctx.Err() wrapped with how
+// far it got.
+func NewTreeContext(ctx context.Context, content []Storable, opts ...Option) (*MerkleTree, error) {
+	t := newMerkleTree(opts)
+
+	if err := t.rebuildTreeWith(ctx, content); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// newMerkleTree returns an empty tree configured by opts.
+func newMerkleTree(opts []Option) *MerkleTree {
 	var defaultHashFunc = sha256.New
 
 	t := &MerkleTree{
-		hashFunc: defaultHashFunc,
+		config: config{
+			hashFunc: defaultHashFunc,
+		},
 	}
 
+	for _, opt := range opts {
+		opt(&t.config)
+	}
+
+	t.hashers = newHasherPool(&t.config)
+
+	return t
+}
+
+// RebuildTree rebuilds the tree from the items stored on its current leaves,
+// ignoring the duplicated padding leaf. Callers holding a pointer to the tree
+// observe the new root once it returns; on error the tree is left untouched.
+// It returns ErrItemsReleased if the tree no longer holds its items.
+func (m *MerkleTree) RebuildTree() error {
+	if m.dropItems {
+		return ErrItemsReleased
+	}
+
+	return m.RebuildTreeWith(m.items())
+}
+
+// RehashWith rebuilds the tree from its items with hashStrategy, which becomes
+// the hash function of the tree. The root afterwards equals the one of
+// NewTreeWithHashStrategy over the same items. It returns ErrItemsReleased if
+// the tree no longer holds its items, and leaves the tree untouched on error.
+func (m *MerkleTree) RehashWith(hashStrategy func() hash.Hash) error {
+	if m.dropItems {
+		return ErrItemsReleased
+	}
+
+	hashFunc, hashers := m.hashFunc, m.hashers
+	m.hashFunc = hashStrategy
+	m.hashers = newHasherPool(&m.config)
+
+	if err := m.RebuildTreeWith(m.items()); err != nil {
+		m.hashFunc, m.hashers = hashFunc, hashers
+		return err
+	}
+
+	return nil
+}
+
+// items returns the items stored on the leaves, ignoring the duplicated
+// padding leaf.
+func (m *MerkleTree) items() []Storable {
+	content := make([]Storable, 0, m.leafCount)
+	for _, l := range m.Leaves {
+		if !l.dup {
+			content = append(content, l.Item)
+		}
+	}
+
+	return content
+}
+
+// RebuildTreeWith replaces the contents of the tree with content and rebuilds it.
+// The new Root, Leaves and merkle root are only swapped in once the whole tree
+// has been built, so on error the old tree remains intact.
+func (m *MerkleTree) RebuildTreeWith(content []Storable) error {
+	return m.rebuildTreeWith(context.Background(), content)
+}
+
+func (m *MerkleTree) rebuildTreeWith(ctx context.Context, content []Storable) error {
 	if len(content) == 0 {
-		return nil, errors.New("error: cannot make a merkle tree without any contents.")
+		return ErrNoContent
 	}
 
-	root, leafs, err := buildTree(content, t)
+	root, leafs, err := buildTree(ctx, content, m)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if m.rejectDuplicates {
+		if err := findDuplicate(len(content), func(i int) []byte { return leafs[i].Hash }); err != nil {
+			return err
+		}
 	}
 
-	t.Root = root
-	t.Leaves = leafs
-	t.merkleRoot = root.Hash
+	m.setTree(root, leafs, len(content))
+	m.hashesOnly = false
 
-	return t, nil
+	return nil
+}
+
+// setTree installs a freshly built tree holding count items.
+func (m *MerkleTree) setTree(root *Node, leaves []*Node, count int) {
+	m.Root = root
+	m.Leaves = leaves
+	m.merkleRoot = root.Hash
+	m.version = nil
+	m.leafCount = count
+	m.depth, m.internalCount = m.shape(count)
+
+	m.leaves = make(map[string]int, count)
+	m.repeats = nil
+	for i := 0; i < count; i++ {
+		m.indexLeaf(leaves[i].Hash, i)
+	}
+
+	if m.dropItems {
+		m.releaseItems()
+	}
+}
+
+// ReleaseItems drops the references the leaves hold to their items so they can
+// be garbage collected. Proofs and lookups keep working by hashing the content
+// they are given; RebuildTree and VerifyNode return ErrItemsReleased, and items
+// added later by UpdateLeaf or RebuildTreeWith are released as well.
+func (m *MerkleTree) ReleaseItems() {
+	m.dropItems = true
+	m.releaseItems()
+}
+
+func (m *MerkleTree) releaseItems() {
+	for _, l := range m.Leaves {
+		l.Item = nil
+	}
+}
+
+// Clone returns a deep copy of the tree. Every Node is copied and linked to the
+// copies of its neighbours, so mutating either tree leaves the other intact;
+// items are shared, as Storables are treated as immutable.
+func (m *MerkleTree) Clone() *MerkleTree {
+	c := &MerkleTree{
+		config:        m.config,
+		hashers:       m.hashers,
+		leafCount:     m.leafCount,
+		depth:         m.depth,
+		internalCount: m.internalCount,
+		hashesOnly:    m.hashesOnly,
+	}
+
+	clones := make(map[*Node]*Node, 2*len(m.Leaves))
+	var clone func(n *Node) *Node
+	clone = func(n *Node) *Node {
+		if n == nil {
+			return nil
+		}
+		if cn, ok := clones[n]; ok {
+			return cn
+		}
+
+		cn := new(Node)
+		*cn = *n
+		clones[n] = cn
+		cn.Hash = append([]byte(nil), n.Hash...)
+		cn.Tree = c
+		cn.Parent = clone(n.Parent)
+		cn.Left = clone(n.Left)
+		cn.Right = clone(n.Right)
+
+		return cn
+	}
+
+	c.Root = clone(m.Root)
+	c.Leaves = make([]*Node, len(m.Leaves))
+	for i, l := range m.Leaves {
+		c.Leaves[i] = clone(l)
+	}
+	if c.Root != nil {
+		c.merkleRoot = c.Root.Hash
+	}
+
+	c.leaves = make(map[string]int, len(m.leaves))
+	for k, idx := range m.leaves {
+		c.leaves[k] = idx
+	}
+	c.repeats = nil
+	for k, positions := range m.repeats {
+		c.setPositions(k, append([]int{c.leaves[k]}, positions...))
+	}
+
+	return c
+}
+
+// GetLeaf returns the leaf whose hash is hash. When several items share that
+// hash the first one is returned; the duplicated padding leaf never is.
+func (m *MerkleTree) GetLeaf(hash []byte) (*Node, bool) {
+	idx, ok := m.leafIndex(hash)
+	if !ok {
+		return nil, false
+	}
+
+	return m.Leaves[idx], true
+}
+
+// ContentIndex returns the position of content among the leaves of the tree.
+// When the content is stored several times the first position is returned.
+func (m *MerkleTree) ContentIndex(content Storable) (int, error) {
+	hash, err := m.contentHash(content)
+	if err != nil {
+		return -1, err
+	}
+
+	idx, ok := m.leafIndex(hash)
+	if !ok {
+		return -1, ErrContentNotFound
+	}
+
+	return idx, nil
+}
+
+// leafIndex returns the position of the first leaf whose hash is hash.
+func (m *MerkleTree) leafIndex(hash []byte) (int, bool) {
+	idx, ok := m.leaves[string(hash)]
+	if !ok {
+		return -1, false
+	}
+
+	return idx, true
+}
+
+// indexLeaf records that the leaf at idx has the given hash, keeping the
+// positions of repeated hashes sorted.
+func (m *MerkleTree) indexLeaf(hash []byte, idx int) {
+	first, ok := m.leaves[string(hash)]
+	if !ok {
+		m.leaves[string(hash)] = idx
+		return
+	}
+
+	key := string(hash)
+	positions := append([]int{first}, m.repeats[key]...)
+	i := sort.SearchInts(positions, idx)
+	positions = append(positions, 0)
+	copy(positions[i+1:], positions[i:])
+	positions[i] = idx
+
+	m.setPositions(key, positions)
+}
+
+// unindexLeaf forgets that the leaf at idx has the given hash.
+func (m *MerkleTree) unindexLeaf(hash []byte, idx int) {
+	first, ok := m.leaves[string(hash)]
+	if !ok {
+		return
+	}
+
+	key := string(hash)
+	positions := append([]int{first}, m.repeats[key]...)
+	i := sort.SearchInts(positions, idx)
+	if i == len(positions) || positions[i] != idx {
+		return
+	}
+
+	m.setPositions(key, append(positions[:i], positions[i+1:]...))
+}
+
+// setPositions records positions, sorted, as the positions of the leaves whose
+// hash is key, forgetting the hash when there are none.
+func (m *MerkleTree) setPositions(key string, positions []int) {
+	if len(positions) == 0 {
+		delete(m.leaves, key)
+		delete(m.repeats, key)
+		return
+	}
+
+	m.leaves[key] = positions[0]
+	if len(positions) == 1 {
+		delete(m.repeats, key)
+		return
+	}
+	if m.repeats == nil {
+		m.repeats = make(map[string][]int)
+	}
+	m.repeats[key] = positions[1:]
+}
+
+// Depth returns the number of edges between the root and the deepest leaf,
+// so a tree with two leaves has a depth of 1.
+func (m *MerkleTree) Depth() int {
+	return m.depth
+}
+
+// LeafCount returns the number of items stored in the tree, excluding the
+// duplicated padding leaf.
+func (m *MerkleTree) LeafCount() int {
+	return m.leafCount
+}
+
+// InternalNodeCount returns the number of internal nodes of the tree, the
+// root included and padding duplicates excluded.
+func (m *MerkleTree) InternalNodeCount() int {
+	return m.internalCount
+}
+
+// shape returns the depth and the number of internal nodes of a tree holding
+// n items, according to the tree's padding strategy.
+func (c *config) shape(n int) (depth, internal int) {
+	if n == 1 && (c.padding == PadPromote || c.singleLeafRoot) {
+		return 0, 0
+	}
+
+	// A lone leaf is still paired, so always build at least one level.
+	for first := true; first || n > 1; first = false {
+		if c.padding == PadPromote {
+			internal += n / 2
+		} else {
+			internal += (n + 1) / 2
+		}
+		n = (n + 1) / 2
+		depth++
+	}
+
+	return depth, internal
 }
 
 // buildTree builds a new Merkle Tree with the contents from content.
 // It first builds the leaf nodes,
 // and then starts building the subsequent parents until it reaches the root.
-func buildTree(content []Storable, t *MerkleTree) (*Node, []*Node, error) {
-	var leaves []*Node
+func buildTree(ctx context.Context, content []Storable, t *MerkleTree) (*Node, []*Node, error) {
+	// Room for every leaf plus the duplicate padLevel may append.
+	leaves := make([]*Node, len(content), len(content)+1)
+	block := make([]Node, len(content))
 
-	for _, c := range content {
-		hash, err := c.CalculateHash()
-		if err != nil {
-			return nil, nil, err
+	done := ctx.Done()
+	err := parallelRange(len(content), t.workers, func(lo, hi int, stop *int32) error {
+		for i := lo; i < hi && atomic.LoadInt32(stop) == 0; i++ {
+			select {
+			case <-done:
+				return fmt.Errorf("error: cancelled before hashing the content at index %d of %d: %w", i, len(content), ctx.Err())
+			default:
+			}
+
+			hash, err := content[i].CalculateHash()
+			if err != nil {
+				return &ContentError{Index: i, Err: err}
+			}
+
+			hash, err = t.leafHash(hash)
+			if err != nil {
+				return &ContentError{Index: i, Err: err}
+			}
+
+			n := &block[i]
+			n.Hash = hash
+			n.Item = content[i]
+			n.Tree = t
+			n.leaf = true
+			n.index = i
+
+			leaves[i] = n
 		}
 
-		leaves = append(leaves, &Node{
-			Hash: hash,
-			Item: c,
-			Tree: t,
-			dup:  false,
-			leaf: true,
-		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(leaves)%2 == 1 {
-		duplicate := &Node{
-			Hash: leaves[len(leaves)-1].Hash,
-			Item: leaves[len(leaves)-1].Item,
-			Tree: t,
-			dup:  true,
-			leaf: true,
-		}
-		leaves = append(leaves, duplicate)
+	return buildAbove(ctx, leaves, t)
+}
+
+// buildAbove pads leaves and builds the levels above them, returning the root
+// and the padded leaves.
+func buildAbove(ctx context.Context, leaves []*Node, t *MerkleTree) (*Node, []*Node, error) {
+	leaves = t.padLevel(leaves)
+
+	if !t.needsPairing(leaves) {
+		return leaves[0], leaves, nil
 	}
 
-	root, err := buildIntermediate(leaves, t)
+	root, err := buildIntermediate(ctx, leaves, t)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -124,45 +625,676 @@ func buildTree(content []Storable, t *MerkleTree) (*Node, []*Node, error) {
 }
 
 // buildIntermediate builds the intermediate part of the tree, above the leaves,
-// until it reaches the root.
-func buildIntermediate(leaves []*Node, t *MerkleTree) (*Node, error) {
-	var nodes []*Node
-	for i := 0; i < len(leaves); i += 2 {
-		h := t.hashFunc()
-		var left, right int = i, i + 1
+// until it reaches the root. Levels are built iteratively, left to right; the
+// nodes of each level are allocated in a single block.
+func buildIntermediate(ctx context.Context, leaves []*Node, t *MerkleTree) (*Node, error) {
+	level := leaves
+	for height := 1; ; height++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("error: cancelled before building level %d of the tree: %w", height, err)
+		}
+
+		// ceil(n/2) parents, plus room for the duplicate padLevel may append.
+		size := (len(level) + 1) / 2
+		nodes := make([]*Node, 0, size+1)
+		block := make([]Node, size)
+		hashes := make([]byte, size*t.hashers.size)
+
+		pair := func(left, right int) (*Node, error) {
+			// The capacity stops a longer pair hash from spilling over the
+			// next node, its parent hash being appended to a new buffer.
+			off := left / 2 * t.hashers.size
+			hash, err := t.appendPair(hashes[off:off:off+t.hashers.size], level[left].Hash, level[right].Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			n := &block[left/2]
+			n.Hash = hash[:len(hash):len(hash)]
+			n.Left = level[left]
+			n.Right = level[right]
+			n.Tree = t
+			n.height = parentHeight(n.Left, n.Right)
 
-		// Avoid accessing an out-of-bounds position
-		// Also handles the cases where len(leaves) % 2 != 1
-		if i+1 == len(leaves) {
-			right = i
+			level[left].Parent = n
+			level[right].Parent = n
+
+			return n, nil
 		}
 
-		itemHash := append(leaves[left].Hash, leaves[right].Hash...)
-		if _, err := h.Write(itemHash); err != nil {
+		// Full pairs are independent of each other, so they may be hashed
+		// concurrently; their parents land at fixed positions in block.
+		err := parallelRange(len(level)/2, t.workers, func(lo, hi int, stop *int32) error {
+			for j := lo; j < hi && atomic.LoadInt32(stop) == 0; j++ {
+				if _, err := pair(2*j, 2*j+1); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
 			return nil, err
 		}
 
-		n := &Node{
-			Hash:  h.Sum(nil),
-			Left:  leaves[left],
-			Right: leaves[right],
-			Tree:  t,
+		for j := 0; j < len(level)/2; j++ {
+			nodes = append(nodes, &block[j])
 		}
 
-		nodes = append(nodes, n)
+		// Handle the last node of an odd level.
+		if i := len(level) - 1; i%2 == 0 {
+			if t.padding == PadPromote {
+				nodes = append(nodes, level[i])
+			} else {
+				n, err := pair(i, i)
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, n)
+			}
+		}
 
-		leaves[left].Parent = n
-		leaves[right].Parent = n
+		if len(nodes) == 1 {
+			return nodes[0], nil
+		}
 
-		if len(leaves) == 2 {
-			return n, nil
+		level = t.padLevel(nodes)
+	}
+}
+
+// minParallelRange is the smallest range worth splitting across workers.
+const minParallelRange = 1024
+
+// parallelRange splits [0, n) into contiguous chunks, one per worker, and runs
+// fn on each chunk concurrently. With fewer than two workers, or a range too
+// small to be worth it, fn runs once over the whole range. Once a chunk fails
+// stop is set so the others can bail out early; the error of the first failing
+// chunk, in range order, is returned.
+func parallelRange(n, workers int, fn func(lo, hi int, stop *int32) error) error {
+	var stop int32
+	if workers < 2 || n < minParallelRange {
+		return fn(0, n, &stop)
+	}
+
+	chunk := (n + workers - 1) / workers
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w*chunk < n; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			if err := fn(lo, hi, &stop); err != nil {
+				errs[w] = err
+				atomic.StoreInt32(&stop, 1)
+			}
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// padLevel applies the tree's padding strategy to a level with an odd number
+// of nodes. Only PadDuplicateLast changes the level itself, appending a
+// duplicate of its last node; the other strategies are applied while pairing.
+func (t *MerkleTree) padLevel(level []*Node) []*Node {
+	if t.padding != PadDuplicateLast || len(level)%2 == 0 || !t.needsPairing(level) {
+		return level
+	}
+
+	last := level[len(level)-1]
+	return append(level, &Node{
+		Hash:   last.Hash,
+		Item:   last.Item,
+		Left:   last.Left,
+		Right:  last.Right,
+		Tree:   t,
+		dup:    true,
+		leaf:   last.leaf,
+		height: last.height,
+		index:  len(level),
+	})
+}
+
+// needsPairing reports whether level has to be paired into a parent level. A
+// lone leaf is still paired with itself unless odd nodes are promoted or the
+// tree uses the single leaf as its root.
+func (t *MerkleTree) needsPairing(level []*Node) bool {
+	return len(level) > 1 || level[0].leaf && t.padding != PadPromote && !t.singleLeafRoot
+}
+
+// RealLeaves returns the leaves holding the items of the tree, in content
+// order, without the duplicated padding leaf.
+func (m *MerkleTree) RealLeaves() []*Node {
+	return m.Leaves[:m.leafCount]
+}
+
+// IsLeaf reports whether n is a leaf of its tree.
+func (n *Node) IsLeaf() bool {
+	return n.leaf
+}
+
+// IsDuplicate reports whether n is a padding duplicate of another node rather
+// than a node holding data of its own.
+func (n *Node) IsDuplicate() bool {
+	return n.dup
+}
+
+// Height returns the number of edges between n and the deepest leaf below it;
+// leaves have a height of 0.
+func (n *Node) Height() int {
+	return n.height
+}
+
+// LeafIndex returns the position of n in its tree's Leaves. The boolean is
+// false when n is not a leaf. The padding duplicate reports its own position,
+// right after the leaf it mirrors.
+func (n *Node) LeafIndex() (int, bool) {
+	if !n.leaf {
+		return -1, false
+	}
+
+	return n.index, true
+}
+
+// MerklePath returns the merkle path of the leaf holding content: the
+// siblings of every node on the way from that leaf up to the root.
+func (m *MerkleTree) MerklePath(content Storable) ([]ProofStep, error) {
+	if m.hashesOnly {
+		return nil, ErrLeafHashesOnly
+	}
+
+	idx, err := m.ContentIndex(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.MerklePathAt(idx)
+}
+
+// MerklePathAt returns the merkle path of the leaf at position index of
+// RealLeaves.
+func (m *MerkleTree) MerklePathAt(index int) ([]ProofStep, error) {
+	if index < 0 || index >= m.leafCount {
+		return nil, fmt.Errorf("error: leaf index %d out of range [0, %d)", index, m.leafCount)
+	}
+
+	path := make([]ProofStep, 0, m.depth)
+	for n := m.Leaves[index]; n.Parent != nil; n = n.Parent {
+		p := n.Parent
+		if p.Left == n {
+			path = append(path, ProofStep{Hash: p.Right.Hash})
+		} else {
+			path = append(path, ProofStep{Hash: p.Left.Hash, Left: true})
+		}
+	}
+
+	return detachPath(path), nil
+}
+
+// MerklePathsAll returns the merkle path of every leaf of the tree, keyed by
+// the leaf's position in RealLeaves. The paths are emitted in a single walk
+// of the tree.
+func (m *MerkleTree) MerklePathsAll() (map[int][]ProofStep, error) {
+	paths := make(map[int][]ProofStep, m.leafCount)
+	err := m.MerklePathsEach(func(index int, path []ProofStep) error {
+		paths[index] = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// MerklePathsEach calls fn with the merkle path of every leaf of the tree
+// without materializing all of them at once. It stops at the first error
+// returned by fn and returns it.
+func (m *MerkleTree) MerklePathsEach(fn func(index int, path []ProofStep) error) error {
+	return m.Root.eachPath(make([]ProofStep, 0, m.depth), fn)
+}
+
+// eachPath walks the subtree of n, carrying the steps from the root down to
+// n, and emits the path of every real leaf it reaches.
+func (n *Node) eachPath(steps []ProofStep, fn func(int, []ProofStep) error) error {
+	if n.leaf {
+		path := make([]ProofStep, len(steps))
+		for i, s := range steps {
+			path[len(steps)-1-i] = s
 		}
+		return fn(n.index, detachPath(path))
 	}
 
-	return buildIntermediate(nodes, t)
+	if err := n.Left.eachPath(append(steps, ProofStep{Hash: n.Right.Hash}), fn); err != nil {
+		return err
+	}
+	if n.Right == n.Left || n.Right.dup {
+		return nil
+	}
+
+	return n.Right.eachPath(append(steps, ProofStep{Hash: n.Left.Hash, Left: true}), fn)
 }
 
-//String returns a string representation of the node.
+// detachPath replaces the hashes of path, which belong to the nodes of the
+// tree, with copies sharing a single allocation.
+func detachPath(path []ProofStep) []ProofStep {
+	size := 0
+	for _, s := range path {
+		size += len(s.Hash)
+	}
+
+	slab := make([]byte, 0, size)
+	for i, s := range path {
+		slab = append(slab, s.Hash...)
+		path[i].Hash = slab[len(slab)-len(s.Hash) : len(slab) : len(slab)]
+	}
+
+	return path
+}
+
+// VerifyMerklePath checks that path leads from the leaf of content to the
+// merkle root of the tree, returning ErrInvalidProof when it does not.
+func (m *MerkleTree) VerifyMerklePath(content Storable, path []ProofStep) error {
+	hash, err := m.contentHash(content)
+	if err != nil {
+		return err
+	}
+
+	root, err := foldSteps(hash, path, m.hashPair)
+	if err != nil {
+		return err
+	}
+
+	if !RootEqual(root, m.merkleRoot) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// RootHash returns a copy of the merkle root of the tree, its CommittedRoot
+// with WithCountCommitment, or ErrNoContent if the tree was never built.
+func (m *MerkleTree) RootHash() ([]byte, error) {
+	if m.Root == nil {
+		return nil, ErrNoContent
+	}
+	if m.countCommitment {
+		return m.CommittedRoot()
+	}
+
+	return m.MerkleRoot(), nil
+}
+
+// CommittedRoot returns the root committing to the number of leaves of the
+// tree, H(uint64_le(LeafCount()) || MerkleRoot()) with the hash function of
+// the tree, or ErrNoContent if the tree was never built. Padding gives
+// [A, B, C] and [A, B, C, C] the same merkle root but not the same committed
+// root.
+func (m *MerkleTree) CommittedRoot() ([]byte, error) {
+	if m.Root == nil {
+		return nil, ErrNoContent
+	}
+
+	h := m.hashers.get()
+	defer m.hashers.put(h)
+
+	return commitCount(h, m.leafCount, m.merkleRoot), nil
+}
+
+// Prove returns the Proof of the leaf whose hash is leaf.
+func (m *MerkleTree) Prove(leaf []byte) (*Proof, error) {
+	idx, ok := m.leafIndex(leaf)
+	if !ok {
+		return nil, ErrContentNotFound
+	}
+
+	steps, err := m.MerklePathAt(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := m.proofHeader()
+	p.Index = idx
+	p.Size = m.leafCount
+	p.LeafHash = append([]byte(nil), leaf...)
+	p.Steps = steps
+
+	return &p, nil
+}
+
+// proofHeader returns a Proof carrying how the tree hashes, without a leaf.
+func (m *MerkleTree) proofHeader() Proof {
+	return Proof{
+		Hash:                 m.hashers.name,
+		DomainSeparation:     m.domainSeparation,
+		SortedPairs:          m.sortedPairs,
+		CountCommitment:      m.countCommitment,
+		LengthPrefixedLeaves: m.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(m.leafPrefix),
+		NodePrefix:           clonePrefix(m.nodePrefix),
+		PairHash:             pairHashName(m.pairHash),
+		Keyed:                m.keyed(),
+		DigestSize:           m.hashers.truncated,
+		Layout:               m.layout(),
+	}
+}
+
+// layout returns the layout of the leaves of the tree.
+func (m *MerkleTree) layout() ProofLayout {
+	if m.padding == PadPromote {
+		return LayoutPromote
+	}
+
+	return LayoutLevels
+}
+
+// VerifyProof checks that p proves the leaf whose hash is leaf against the
+// root of the tree, returning an error wrapping ErrInvalidProof when it does
+// not. The proof must be of the layout of the tree, its steps on the sides
+// that layout gives its Index and Size. With WithCountCommitment the proof
+// must be of a tree of as many leaves.
+func (m *MerkleTree) VerifyProof(leaf []byte, p *Proof) error {
+	if !RootEqual(leaf, p.LeafHash) {
+		return ErrInvalidProof
+	}
+	if p.Layout != m.layout() {
+		return fmt.Errorf("error: proof of layout %d for a tree of layout %d: %w", p.Layout, m.layout(), ErrInvalidProof)
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	root, err := foldSteps(leaf, p.Steps, m.hashPair)
+	if err != nil {
+		return err
+	}
+
+	want := m.merkleRoot
+	if m.countCommitment {
+		h := m.hashers.get()
+		root = commitCount(h, p.Size, root)
+		want = commitCount(h, m.leafCount, m.merkleRoot)
+		m.hashers.put(h)
+	}
+
+	if !RootEqual(root, want) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// parentHeight returns the height of the parent of left and right.
+func parentHeight(left, right *Node) int {
+	if left.height > right.height {
+		return left.height + 1
+	}
+
+	return right.height + 1
+}
+
+// UpdateLeaf replaces the content of the leaf holding old with new and
+// rehashes only the ancestors of that leaf, following the Parent links up to
+// the root. The padding duplicate, if it mirrors the updated leaf, is kept in
+// sync so the resulting root equals the one of a full rebuild.
+func (m *MerkleTree) UpdateLeaf(old, new Storable) error {
+	oldHash, err := m.contentHash(old)
+	if err != nil {
+		return err
+	}
+
+	newHash, err := m.contentHash(new)
+	if err != nil {
+		return err
+	}
+
+	idx, ok := m.leafIndex(oldHash)
+	if !ok {
+		return ErrContentNotFound
+	}
+
+	if m.rejectDuplicates {
+		if other, ok := m.leafIndex(newHash); ok && other != idx {
+			return &DuplicateError{First: other, Second: idx}
+		}
+	}
+
+	target := m.Leaves[idx]
+	if !m.dropItems {
+		target.Item = new
+	}
+	target.Hash = newHash
+	syncDuplicate(target)
+
+	m.unindexLeaf(oldHash, idx)
+	m.indexLeaf(newHash, idx)
+
+	for n := target.Parent; n != nil; n = n.Parent {
+		hash, err := m.hashPair(n.Left.Hash, n.Right.Hash)
+		if err != nil {
+			return err
+		}
+		n.Hash = hash
+		syncDuplicate(n)
+	}
+
+	m.merkleRoot = m.Root.Hash
+
+	return nil
+}
+
+// Remove drops the leaf holding content from the tree. The remaining leaves are
+// re-paired, duplicating the last one again if their count became odd, and only
+// the intermediate nodes whose subtrees changed are rehashed. Removing the only
+// element of the tree returns ErrNoContent and leaves the tree untouched.
+func (m *MerkleTree) Remove(content Storable) error {
+	hash, err := m.contentHash(content)
+	if err != nil {
+		return err
+	}
+
+	removed, ok := m.leafIndex(hash)
+	if !ok {
+		return ErrContentNotFound
+	}
+
+	if m.leafCount == 1 {
+		return ErrNoContent
+	}
+
+	real := make([]*Node, 0, m.leafCount)
+	real = append(real, m.Leaves[:removed]...)
+	real = append(real, m.Leaves[removed+1:m.leafCount]...)
+
+	leaves := m.padLevel(real)
+
+	root, err := relinkIntermediate(leaves, m)
+	if err != nil {
+		return err
+	}
+
+	for i := removed; i < len(real); i++ {
+		real[i].index = i
+	}
+	m.setTree(root, leaves, len(real))
+
+	return nil
+}
+
+// syncDuplicate copies the hash and item of n onto the padding duplicate that
+// mirrors it, if any.
+func syncDuplicate(n *Node) {
+	if p := n.Parent; p != nil && p.Left == n && p.Right.dup {
+		p.Right.Hash = n.Hash
+		p.Right.Item = n.Item
+	}
+}
+
+// relinkIntermediate rebuilds the levels above leaves, reusing every existing
+// node whose children are unchanged. Parent links are only rewired once all the
+// new nodes have been hashed, so an error leaves the current tree untouched.
+func relinkIntermediate(leaves []*Node, t *MerkleTree) (*Node, error) {
+	type link struct{ child, parent *Node }
+	var links []link
+
+	level := leaves
+	for t.needsPairing(level) {
+		next := make([]*Node, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left, right := level[i], level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			} else if t.padding == PadPromote {
+				next = append(next, left)
+				continue
+			}
+
+			if p := left.Parent; p != nil && p == right.Parent && p.Left == left && p.Right == right {
+				next = append(next, p)
+				continue
+			}
+
+			hash, err := t.hashPair(left.Hash, right.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			n := &Node{
+				Hash:   hash,
+				Left:   left,
+				Right:  right,
+				Tree:   t,
+				height: parentHeight(left, right),
+			}
+			links = append(links, link{left, n}, link{right, n})
+			next = append(next, n)
+		}
+		level = t.padLevel(next)
+	}
+
+	for _, l := range links {
+		l.child.Parent = l.parent
+	}
+	level[0].Parent = nil
+
+	return level[0], nil
+}
+
+// contentHash returns the hash content has as a leaf of the tree.
+func (m *MerkleTree) contentHash(content Storable) ([]byte, error) {
+	hash, err := content.CalculateHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.leafHash(hash)
+}
+
+// leafHash returns the leaf node hash for an item hash. With
+// WithLengthPrefixedLeaves it is the hash of its LengthPrefixedLeaf encoding;
+// otherwise, without domain separation, the item hash is used as is, cut to
+// the size set with WithDigestSize, or hashed with no prefix in a keyed tree.
+func (m *MerkleTree) leafHash(itemHash []byte) ([]byte, error) {
+	if m.lengthPrefixedLeaves {
+		h := m.hashers.get()
+		defer m.hashers.put(h)
+
+		if _, err := h.Write(lengthPrefixed(m.leafPrefixBytes(), itemHash)); err != nil {
+			return nil, err
+		}
+
+		return h.Sum(nil), nil
+	}
+	if !m.domainSeparation && !m.keyed() {
+		return truncateHash(itemHash, m.hashers.truncated), nil
+	}
+
+	h := m.hashers.get()
+	defer m.hashers.put(h)
+
+	if m.domainSeparation {
+		if _, err := h.Write(m.leafPrefixBytes()); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := h.Write(itemHash); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// hashPair hashes the concatenation of two child hashes with the tree's hash
+// strategy, prefixed with the node prefix, 0x01 by default, when domain
+// separation is enabled and ordered first when pairs are sorted, or hashes
+// them with the function set with WithPairHash.
+func (m *MerkleTree) hashPair(left, right []byte) ([]byte, error) {
+	return m.appendPair(nil, left, right)
+}
+
+// appendPair appends the parent hash of left and right to dst, using a pooled
+// hasher.
+func (m *MerkleTree) appendPair(dst, left, right []byte) ([]byte, error) {
+	left, right = m.orderPair(left, right)
+	if m.pairHash != nil {
+		return append(dst, truncateHash(m.pairHash(left, right), m.hashers.truncated)...), nil
+	}
+
+	h := m.hashers.get()
+	defer m.hashers.put(h)
+
+	if m.domainSeparation {
+		if _, err := h.Write(m.nodePrefixBytes()); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := h.Write(left); err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(right); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(dst), nil
+}
+
+// String returns a string representation of the node: its hash as truncated
+// hex, its flags and its item, if any.
 func (n *Node) String() string {
-	return fmt.Sprintf("%t %t %v %s", n.leaf, n.dup, n.Hash, n.Item)
+	s := shortHex(n.Hash)
+	if n.leaf {
+		s += " leaf"
+	}
+	if n.dup {
+		s += " dup"
+	}
+	if n.Item != nil {
+		s += fmt.Sprintf(" %v", n.Item)
+	}
+
+	return s
+}
+
+// shortHex returns the hex encoding of the first bytes of hash, which is
+// enough to tell nodes apart when debugging.
+func shortHex(hash []byte) string {
+	const prefix = 8
+	if len(hash) <= prefix {
+		return hex.EncodeToString(hash)
+	}
+
+	return hex.EncodeToString(hash[:prefix]) + "..."
 }
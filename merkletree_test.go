@@ -2,17 +2,28 @@ package merklego
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"hash"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-//TestSHA256Content implements the Content interface provided by merkletree and represents the content stored in the tree.
+// TestSHA256Content implements the Content interface provided by merkletree and represents the content stored in the tree.
 type TestSHA256Content struct {
 	x string
 }
 
-//CalculateHash hashes the values of a TestSHA256Content
+// CalculateHash hashes the values of a TestSHA256Content
 func (t TestSHA256Content) CalculateHash() ([]byte, error) {
 	h := sha256.New()
 	if _, err := h.Write([]byte(t.x)); err != nil {
@@ -22,19 +33,20 @@ func (t TestSHA256Content) CalculateHash() ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
-//Equals tests for equality of two Contents
+// Equals tests for equality of two Contents
 func (t TestSHA256Content) Equals(other Storable) (bool, error) {
 	return t.x == other.(TestSHA256Content).x, nil
 }
 
 var table = []struct {
-	testCaseId          int
-	hashStrategy        func() hash.Hash
-	hashStrategyName    string
-	defaultHashStrategy bool
-	contents            []Storable
-	expectedHash        []byte
-	notInContents       Storable
+	testCaseId            int
+	hashStrategy          func() hash.Hash
+	hashStrategyName      string
+	defaultHashStrategy   bool
+	contents              []Storable
+	expectedHash          []byte
+	expectedSeparatedHash []byte
+	notInContents         Storable
 }{
 	{
 		testCaseId:          0,
@@ -55,8 +67,9 @@ var table = []struct {
 				x: "Hola",
 			},
 		},
-		notInContents: TestSHA256Content{x: "NotInTestTable"},
-		expectedHash:  []byte{95, 48, 204, 128, 19, 59, 147, 148, 21, 110, 36, 178, 51, 240, 196, 190, 50, 178, 78, 68, 187, 51, 129, 240, 44, 123, 165, 38, 25, 208, 254, 188},
+		notInContents:         TestSHA256Content{x: "NotInTestTable"},
+		expectedHash:          []byte{95, 48, 204, 128, 19, 59, 147, 148, 21, 110, 36, 178, 51, 240, 196, 190, 50, 178, 78, 68, 187, 51, 129, 240, 44, 123, 165, 38, 25, 208, 254, 188},
+		expectedSeparatedHash: []byte{53, 3, 156, 227, 250, 235, 239, 205, 162, 125, 36, 138, 198, 108, 239, 34, 93, 82, 236, 50, 230, 171, 142, 14, 115, 109, 6, 57, 71, 106, 244, 213},
 	},
 	{
 		testCaseId:          1,
@@ -74,8 +87,9 @@ var table = []struct {
 				x: "Hey",
 			},
 		},
-		notInContents: TestSHA256Content{x: "NotInTestTable"},
-		expectedHash:  []byte{189, 214, 55, 197, 35, 237, 92, 14, 171, 121, 43, 152, 109, 177, 136, 80, 194, 57, 162, 226, 56, 2, 179, 106, 255, 38, 187, 104, 251, 63, 224, 8},
+		notInContents:         TestSHA256Content{x: "NotInTestTable"},
+		expectedHash:          []byte{189, 214, 55, 197, 35, 237, 92, 14, 171, 121, 43, 152, 109, 177, 136, 80, 194, 57, 162, 226, 56, 2, 179, 106, 255, 38, 187, 104, 251, 63, 224, 8},
+		expectedSeparatedHash: []byte{163, 148, 81, 29, 109, 100, 63, 218, 36, 52, 38, 85, 18, 96, 170, 193, 50, 234, 151, 148, 199, 246, 54, 233, 151, 52, 170, 252, 204, 26, 12, 145},
 	},
 	{
 		testCaseId:          2,
@@ -99,8 +113,9 @@ var table = []struct {
 				x: "Hola",
 			},
 		},
-		notInContents: TestSHA256Content{x: "NotInTestTable"},
-		expectedHash:  []byte{46, 216, 115, 174, 13, 210, 55, 39, 119, 197, 122, 104, 93, 144, 112, 131, 202, 151, 41, 14, 80, 143, 21, 71, 140, 169, 139, 173, 50, 37, 235, 188},
+		notInContents:         TestSHA256Content{x: "NotInTestTable"},
+		expectedHash:          []byte{46, 216, 115, 174, 13, 210, 55, 39, 119, 197, 122, 104, 93, 144, 112, 131, 202, 151, 41, 14, 80, 143, 21, 71, 140, 169, 139, 173, 50, 37, 235, 188},
+		expectedSeparatedHash: []byte{108, 247, 57, 156, 247, 99, 10, 145, 127, 235, 137, 12, 89, 10, 37, 194, 23, 231, 118, 187, 169, 81, 57, 203, 96, 227, 88, 237, 90, 43, 67, 114},
 	},
 }
 
@@ -118,3 +133,1553 @@ func TestNewTree(t *testing.T) {
 		}
 	}
 }
+
+// TestFailingContent is a Storable whose hash can never be computed.
+type TestFailingContent struct{}
+
+// CalculateHash always fails for TestFailingContent
+func (t TestFailingContent) CalculateHash() ([]byte, error) {
+	return nil, errors.New("error: cannot hash content")
+}
+
+// Equals tests for equality of two Contents
+func (t TestFailingContent) Equals(other Storable) (bool, error) {
+	_, ok := other.(TestFailingContent)
+	return ok, nil
+}
+
+func TestRebuildTree(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTree(table[i].contents)
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		held := tree
+		if err := tree.RebuildTree(); err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(held.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, held.MerkleRoot())
+		}
+		if len(held.Leaves)%2 != 0 {
+			t.Errorf("[case:%d] error: expected an even number of leaves got %d", table[i].testCaseId, len(held.Leaves))
+		}
+	}
+}
+
+func TestRebuildTreeWith(t *testing.T) {
+	tree, err := NewTree(table[0].contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	held := tree
+
+	for i := 0; i < len(table); i++ {
+		if err := tree.RebuildTreeWith(table[i].contents); err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(held.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, held.MerkleRoot())
+		}
+		if held.Root.Tree != held {
+			t.Errorf("[case:%d] error: expected root to reference the rebuilt tree", table[i].testCaseId)
+		}
+	}
+}
+
+func TestRebuildTreeWithErrorKeepsTree(t *testing.T) {
+	tree, err := NewTree(table[0].contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	root, leaves := tree.Root, tree.Leaves
+
+	failing := append([]Storable{}, table[1].contents...)
+	failing = append(failing, TestFailingContent{})
+
+	if err := tree.RebuildTreeWith(failing); err == nil {
+		t.Errorf("error: expected error for failing content")
+	}
+	if err := tree.RebuildTreeWith(nil); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+	if tree.Root != root || len(tree.Leaves) != len(leaves) {
+		t.Errorf("error: expected tree to be left untouched after a failed rebuild")
+	}
+	if bytes.Compare(tree.MerkleRoot(), table[0].expectedHash) != 0 {
+		t.Errorf("error: expected hash equal to %v got %v", table[0].expectedHash, tree.MerkleRoot())
+	}
+}
+
+func TestUpdateLeaf(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		for j := range table[i].contents {
+			tree, err := NewTree(table[i].contents)
+			if err != nil {
+				t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+
+			updated := append([]Storable{}, table[i].contents...)
+			updated[j] = TestSHA256Content{x: "Updated"}
+
+			if err := tree.UpdateLeaf(table[i].contents[j], updated[j]); err != nil {
+				t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+
+			expected, err := NewTree(updated)
+			if err != nil {
+				t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+			if bytes.Compare(tree.MerkleRoot(), expected.MerkleRoot()) != 0 {
+				t.Errorf("[case:%d] error: updating leaf %d: expected hash equal to %v got %v", table[i].testCaseId, j, expected.MerkleRoot(), tree.MerkleRoot())
+			}
+		}
+	}
+}
+
+func TestUpdateLeafNotFound(t *testing.T) {
+	tree, err := NewTree(table[0].contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	if err := tree.UpdateLeaf(table[0].notInContents, TestSHA256Content{x: "Updated"}); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if bytes.Compare(tree.MerkleRoot(), table[0].expectedHash) != 0 {
+		t.Errorf("error: expected hash equal to %v got %v", table[0].expectedHash, tree.MerkleRoot())
+	}
+}
+
+func benchmarkContents(n int) []Storable {
+	contents := make([]Storable, n)
+	for i := range contents {
+		contents[i] = TestSHA256Content{x: fmt.Sprintf("content-%d", i)}
+	}
+
+	return contents
+}
+
+func BenchmarkUpdateLeaf(b *testing.B) {
+	contents := benchmarkContents(100000)
+	tree, err := NewTree(contents)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	old, new := contents[len(contents)/2], Storable(TestSHA256Content{x: "Updated"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tree.UpdateLeaf(old, new); err != nil {
+			b.Fatal(err)
+		}
+		old, new = new, old
+	}
+}
+
+func BenchmarkRebuildTreeWith(b *testing.B) {
+	contents := benchmarkContents(100000)
+	tree, err := NewTree(contents)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	updated := append([]Storable{}, contents...)
+	updated[len(updated)/2] = TestSHA256Content{x: "Updated"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tree.RebuildTreeWith(updated); err != nil {
+			b.Fatal(err)
+		}
+		contents, updated = updated, contents
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		n := len(table[i].contents)
+		// front, middle, back; for odd counts the back is also the padded position.
+		for _, j := range []int{0, n / 2, n - 1} {
+			tree, err := NewTree(table[i].contents)
+			if err != nil {
+				t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+
+			remaining := append([]Storable{}, table[i].contents[:j]...)
+			remaining = append(remaining, table[i].contents[j+1:]...)
+
+			if err := tree.Remove(table[i].contents[j]); err != nil {
+				t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+
+			expected, err := NewTree(remaining)
+			if err != nil {
+				t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+			if bytes.Compare(tree.MerkleRoot(), expected.MerkleRoot()) != 0 {
+				t.Errorf("[case:%d] error: removing leaf %d: expected hash equal to %v got %v", table[i].testCaseId, j, expected.MerkleRoot(), tree.MerkleRoot())
+			}
+			if len(tree.Leaves) != len(expected.Leaves) {
+				t.Errorf("[case:%d] error: removing leaf %d: expected %d leaves got %d", table[i].testCaseId, j, len(expected.Leaves), len(tree.Leaves))
+			}
+
+			verified, err := tree.Root.VerifyNode()
+			if err != nil {
+				t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+			}
+			if bytes.Compare(verified, tree.MerkleRoot()) != 0 {
+				t.Errorf("[case:%d] error: removing leaf %d: tree structure does not match its root", table[i].testCaseId, j)
+			}
+			for _, l := range tree.Leaves {
+				for p := l; p.Parent != nil; p = p.Parent {
+					if p.Parent.Left != p && p.Parent.Right != p {
+						t.Errorf("[case:%d] error: removing leaf %d: broken parent link", table[i].testCaseId, j)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRemoveLastElement(t *testing.T) {
+	content := []Storable{TestSHA256Content{x: "Hello"}}
+	tree, err := NewTree(content)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	root := tree.MerkleRoot()
+
+	if err := tree.Remove(content[0]); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+	if tree.Root == nil || bytes.Compare(tree.MerkleRoot(), root) != 0 {
+		t.Errorf("error: expected tree to be left untouched")
+	}
+	if err := tree.Remove(table[0].notInContents); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+}
+
+// TestHashContent is a Storable whose hash is fixed up front.
+type TestHashContent struct {
+	hash []byte
+}
+
+// CalculateHash returns the fixed hash of a TestHashContent
+func (t TestHashContent) CalculateHash() ([]byte, error) {
+	return t.hash, nil
+}
+
+// Equals tests for equality of two Contents
+func (t TestHashContent) Equals(other Storable) (bool, error) {
+	o, ok := other.(TestHashContent)
+	return ok && bytes.Equal(t.hash, o.hash), nil
+}
+
+func TestNewTreeWithDomainSeparation(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTreeWithOptions(table[i].contents, WithDomainSeparation(true))
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(tree.MerkleRoot(), table[i].expectedSeparatedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedSeparatedHash, tree.MerkleRoot())
+		}
+
+		verified, err := tree.Root.VerifyNode()
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(verified, table[i].expectedSeparatedHash) != 0 {
+			t.Errorf("[case:%d] error: expected verified hash equal to %v got %v", table[i].testCaseId, table[i].expectedSeparatedHash, verified)
+		}
+
+		legacy, err := NewTreeWithOptions(table[i].contents, WithDomainSeparation(false))
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(legacy.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, legacy.MerkleRoot())
+		}
+	}
+}
+
+func TestDomainSeparationSecondPreimage(t *testing.T) {
+	for _, separated := range []bool{false, true} {
+		tree, err := NewTreeWithOptions(table[0].contents, WithDomainSeparation(separated))
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		// Present the two internal nodes below the root as leaves.
+		forged, err := NewTreeWithOptions([]Storable{
+			TestHashContent{hash: tree.Root.Left.Hash},
+			TestHashContent{hash: tree.Root.Right.Hash},
+		}, WithDomainSeparation(separated))
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		collides := bytes.Equal(tree.MerkleRoot(), forged.MerkleRoot())
+		if collides == separated {
+			t.Errorf("error: domain separation %t: expected collision %t got %t", separated, !separated, collides)
+		}
+	}
+}
+
+// sortedPairsRoot is a reference implementation of the sorted-pair root of contents.
+func sortedPairsRoot(contents []Storable) []byte {
+	var level [][]byte
+	for _, c := range contents {
+		h, _ := c.CalculateHash()
+		level = append(level, h)
+	}
+
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			l, r := level[i], level[i]
+			if i+1 < len(level) {
+				r = level[i+1]
+			}
+			if bytes.Compare(l, r) > 0 {
+				l, r = r, l
+			}
+			sum := sha256.Sum256(append(append([]byte{}, l...), r...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+func TestNewTreeWithSortedPairs(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTreeWithOptions(table[i].contents, WithSortedPairs(true))
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+
+		expected := sortedPairsRoot(table[i].contents)
+		if bytes.Compare(tree.MerkleRoot(), expected) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, expected, tree.MerkleRoot())
+		}
+
+		verified, err := tree.Root.VerifyNode()
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(verified, expected) != 0 {
+			t.Errorf("[case:%d] error: expected verified hash equal to %v got %v", table[i].testCaseId, expected, verified)
+		}
+	}
+}
+
+// paddingVectors holds roots over the contents "leaf-0".."leaf-(n-1)", each
+// hashed with SHA-256, so other implementations can reproduce them.
+var paddingVectors = []struct {
+	leaves    int
+	duplicate string
+	selfPair  string
+	promote   string
+}{
+	{
+		leaves:    1,
+		duplicate: "9e7009ebf33836642ae708a4a58938c98af6323b98cc4049bec9d426ff1e66c6",
+		selfPair:  "9e7009ebf33836642ae708a4a58938c98af6323b98cc4049bec9d426ff1e66c6",
+		promote:   "d2dbf006f96dd05044a8f63d8f118f23925ba4cc5750f8b6c8e287fd506c8188",
+	},
+	{
+		leaves:    3,
+		duplicate: "39313694557e76d28b720ad7f4481cb144c24c8341f8a68fc4a8363fcd1a04bb",
+		selfPair:  "39313694557e76d28b720ad7f4481cb144c24c8341f8a68fc4a8363fcd1a04bb",
+		promote:   "d67d9c98dea63cd27037f054b1991a8c5f1518df375b9c0bcdac15ba4ef853ed",
+	},
+	{
+		leaves:    5,
+		duplicate: "3ad4abec5d43ae09f5275cf7ce77d8615e1e87164b255aa7661e237b1982a5bf",
+		selfPair:  "3ad4abec5d43ae09f5275cf7ce77d8615e1e87164b255aa7661e237b1982a5bf",
+		promote:   "860a3896f4e89ce155ab1520180baa7eed0e61fd6ea331606090f564b5e8b30a",
+	},
+	{
+		leaves:    7,
+		duplicate: "7455b3f1f5709720dcbe8ba0a4e4c4853d798ad08b119ebce8b212477c422ecd",
+		selfPair:  "7455b3f1f5709720dcbe8ba0a4e4c4853d798ad08b119ebce8b212477c422ecd",
+		promote:   "cb198ed6975098c9c8e3180acecdfe4b05ecdf716c0bafcedc8b26f7306bb62e",
+	},
+}
+
+func paddingContents(n int) []Storable {
+	contents := make([]Storable, n)
+	for i := range contents {
+		contents[i] = TestSHA256Content{x: fmt.Sprintf("leaf-%d", i)}
+	}
+
+	return contents
+}
+
+func TestPaddingStrategies(t *testing.T) {
+	for _, v := range paddingVectors {
+		for strategy, expected := range map[PaddingStrategy]string{
+			PadDuplicateLast: v.duplicate,
+			PadSelfPair:      v.selfPair,
+			PadPromote:       v.promote,
+		} {
+			tree, err := NewTreeWithOptions(paddingContents(v.leaves), WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", v.leaves, strategy, err)
+			}
+			if got := hex.EncodeToString(tree.MerkleRoot()); got != expected {
+				t.Errorf("[leaves:%d strategy:%d] error: expected root %s got %s", v.leaves, strategy, expected, got)
+			}
+
+			verified, err := tree.Root.VerifyNode()
+			if err != nil {
+				t.Errorf("[leaves:%d strategy:%d] error: unexpected error: %v", v.leaves, strategy, err)
+			}
+			if hex.EncodeToString(verified) != expected {
+				t.Errorf("[leaves:%d strategy:%d] error: expected verified root %s got %x", v.leaves, strategy, expected, verified)
+			}
+		}
+	}
+}
+
+func TestPaddingStrategiesMutations(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		for n := 2; n <= 9; n++ {
+			contents := paddingContents(n)
+			for j := 0; j < n; j++ {
+				tree, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+				if err != nil {
+					t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+				}
+
+				updated := append([]Storable{}, contents...)
+				updated[j] = TestSHA256Content{x: "Updated"}
+				if err := tree.UpdateLeaf(contents[j], updated[j]); err != nil {
+					t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+				}
+				expected, _ := NewTreeWithOptions(updated, WithPaddingStrategy(strategy))
+				if bytes.Compare(tree.MerkleRoot(), expected.MerkleRoot()) != 0 {
+					t.Errorf("[leaves:%d strategy:%d] error: updating leaf %d: unexpected root", n, strategy, j)
+				}
+
+				if err := tree.Remove(updated[j]); err != nil {
+					t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+				}
+				remaining := append(append([]Storable{}, contents[:j]...), contents[j+1:]...)
+				expected, _ = NewTreeWithOptions(remaining, WithPaddingStrategy(strategy))
+				if bytes.Compare(tree.MerkleRoot(), expected.MerkleRoot()) != 0 {
+					t.Errorf("[leaves:%d strategy:%d] error: removing leaf %d: unexpected root", n, strategy, j)
+				}
+			}
+		}
+	}
+}
+
+func TestNewTreeWithSingleLeafRoot(t *testing.T) {
+	content := []Storable{TestSHA256Content{x: "Hello"}}
+	leaf, _ := content[0].CalculateHash()
+
+	tree, err := NewTreeWithOptions(content, WithSingleLeafRoot(true))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if bytes.Compare(tree.MerkleRoot(), leaf) != 0 {
+		t.Errorf("error: expected root equal to the leaf hash %v got %v", leaf, tree.MerkleRoot())
+	}
+	if len(tree.Leaves) != 1 || tree.Root != tree.Leaves[0] {
+		t.Errorf("error: expected the single leaf to be the root")
+	}
+
+	legacy, err := NewTree(content)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if bytes.Compare(legacy.MerkleRoot(), leaf) == 0 {
+		t.Errorf("error: expected the default root to differ from the leaf hash")
+	}
+
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTreeWithOptions(table[i].contents, WithSingleLeafRoot(true))
+		if err != nil {
+			t.Errorf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(tree.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, tree.MerkleRoot())
+		}
+	}
+}
+
+func syntheticContents(n int) []Storable {
+	contents := make([]Storable, n)
+	for i := range contents {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("synthetic-%d", i)))
+		contents[i] = TestHashContent{hash: sum[:]}
+	}
+
+	return contents
+}
+
+func TestNewTreeLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large tree in short mode")
+	}
+
+	const leaves = 1 << 20
+	// Roughly 2n nodes of at most 160 bytes each, including their hashes.
+	const budget = 2 * leaves * 160
+
+	contents := syntheticContents(leaves)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	tree, err := NewTree(contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if used := after.HeapAlloc - before.HeapAlloc; used > budget {
+		t.Errorf("error: expected tree to use at most %d bytes got %d", budget, used)
+	}
+	if len(tree.Leaves) != leaves {
+		t.Errorf("error: expected %d leaves got %d", leaves, len(tree.Leaves))
+	}
+}
+
+func BenchmarkBuildIntermediate(b *testing.B) {
+	tree, err := NewTree(syntheticContents(500000))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := buildIntermediate(context.Background(), tree.Leaves, tree); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNewTreeContentError(t *testing.T) {
+	failing := append([]Storable{}, table[0].contents...)
+	failing = append(failing[:2], append([]Storable{TestFailingContent{}}, failing[2:]...)...)
+
+	_, err := NewTree(failing)
+
+	var contentErr *ContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("error: expected a ContentError got %v", err)
+	}
+	if contentErr.Index != 2 {
+		t.Errorf("error: expected failing index 2 got %d", contentErr.Index)
+	}
+	if _, hashErr := (TestFailingContent{}).CalculateHash(); contentErr.Unwrap().Error() != hashErr.Error() {
+		t.Errorf("error: expected wrapped error %v got %v", hashErr, contentErr.Unwrap())
+	}
+}
+
+func BenchmarkNewTree(b *testing.B) {
+	contents := syntheticContents(1 << 20)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewTree(contents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestSlowContent is a Storable that takes a while to hash and counts how many
+// times it was hashed.
+type TestSlowContent struct {
+	x      string
+	fail   bool
+	hashed *int32
+}
+
+// CalculateHash hashes the values of a TestSlowContent after a short delay
+func (t TestSlowContent) CalculateHash() ([]byte, error) {
+	atomic.AddInt32(t.hashed, 1)
+	if t.fail {
+		return nil, errors.New("error: cannot hash content")
+	}
+
+	time.Sleep(100 * time.Microsecond)
+	sum := sha256.Sum256([]byte(t.x))
+
+	return sum[:], nil
+}
+
+// Equals tests for equality of two Contents
+func (t TestSlowContent) Equals(other Storable) (bool, error) {
+	o, ok := other.(TestSlowContent)
+	return ok && t.x == o.x, nil
+}
+
+func TestNewTreeWithWorkers(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 1023, 1024, 1025, 4099} {
+		contents := syntheticContents(n)
+		for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+			sequential, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d] error: unexpected error: %v", n, err)
+			}
+
+			for _, workers := range []int{2, 3, 8} {
+				parallel, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy), WithWorkers(workers))
+				if err != nil {
+					t.Fatalf("[leaves:%d workers:%d] error: unexpected error: %v", n, workers, err)
+				}
+				if bytes.Compare(parallel.MerkleRoot(), sequential.MerkleRoot()) != 0 {
+					t.Errorf("[leaves:%d workers:%d strategy:%d] error: expected hash equal to %v got %v", n, workers, strategy, sequential.MerkleRoot(), parallel.MerkleRoot())
+				}
+				if len(parallel.Leaves) != len(sequential.Leaves) {
+					t.Errorf("[leaves:%d workers:%d strategy:%d] error: expected %d leaves got %d", n, workers, strategy, len(sequential.Leaves), len(parallel.Leaves))
+				}
+				for i, l := range parallel.Leaves {
+					if bytes.Compare(l.Hash, sequential.Leaves[i].Hash) != 0 {
+						t.Fatalf("[leaves:%d workers:%d strategy:%d] error: leaf %d out of order", n, workers, strategy, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNewTreeWithWorkersError(t *testing.T) {
+	const n, failing = 4096, 10
+
+	var hashed int32
+	contents := make([]Storable, n)
+	for i := range contents {
+		contents[i] = TestSlowContent{x: fmt.Sprintf("content-%d", i), fail: i == failing, hashed: &hashed}
+	}
+
+	_, err := NewTreeWithOptions(contents, WithWorkers(4))
+
+	var contentErr *ContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("error: expected a ContentError got %v", err)
+	}
+	if contentErr.Index != failing {
+		t.Errorf("error: expected failing index %d got %d", failing, contentErr.Index)
+	}
+	if hashed := atomic.LoadInt32(&hashed); hashed >= n {
+		t.Errorf("error: expected workers to stop early, %d of %d contents were hashed", hashed, n)
+	}
+}
+
+func BenchmarkNewTreeWithWorkers(b *testing.B) {
+	var hashed int32
+	contents := make([]Storable, 1<<11)
+	for i := range contents {
+		contents[i] = TestSlowContent{x: fmt.Sprintf("content-%d", i), hashed: &hashed}
+	}
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := NewTreeWithOptions(contents, WithWorkers(workers)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// countInternal counts the distinct internal nodes below n that are not padding duplicates.
+func countInternal(n *Node, seen map[*Node]bool) int {
+	if n.leaf || n.dup || seen[n] {
+		return 0
+	}
+	seen[n] = true
+
+	return 1 + countInternal(n.Left, seen) + countInternal(n.Right, seen)
+}
+
+// maxDepth returns the number of edges between n and its deepest leaf.
+func maxDepth(n *Node) int {
+	if n.leaf {
+		return 0
+	}
+
+	l, r := maxDepth(n.Left), maxDepth(n.Right)
+	if l > r {
+		return l + 1
+	}
+
+	return r + 1
+}
+
+func TestTreeShape(t *testing.T) {
+	shapes := []struct {
+		leaves   int
+		strategy PaddingStrategy
+		depth    int
+		internal int
+	}{
+		{1, PadDuplicateLast, 1, 1},
+		{2, PadDuplicateLast, 1, 1},
+		{3, PadDuplicateLast, 2, 3},
+		{5, PadDuplicateLast, 3, 6},
+		{1, PadSelfPair, 1, 1},
+		{2, PadSelfPair, 1, 1},
+		{3, PadSelfPair, 2, 3},
+		{5, PadSelfPair, 3, 6},
+		{1, PadPromote, 0, 0},
+		{2, PadPromote, 1, 1},
+		{3, PadPromote, 2, 2},
+		{5, PadPromote, 3, 4},
+	}
+
+	for _, s := range shapes {
+		tree, err := NewTreeWithOptions(paddingContents(s.leaves), WithPaddingStrategy(s.strategy))
+		if err != nil {
+			t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", s.leaves, s.strategy, err)
+		}
+
+		if tree.LeafCount() != s.leaves {
+			t.Errorf("[leaves:%d strategy:%d] error: expected leaf count %d got %d", s.leaves, s.strategy, s.leaves, tree.LeafCount())
+		}
+		if tree.Depth() != s.depth || maxDepth(tree.Root) != s.depth {
+			t.Errorf("[leaves:%d strategy:%d] error: expected depth %d got %d (structure %d)", s.leaves, s.strategy, s.depth, tree.Depth(), maxDepth(tree.Root))
+		}
+		if n := countInternal(tree.Root, map[*Node]bool{}); tree.InternalNodeCount() != s.internal || n != s.internal {
+			t.Errorf("[leaves:%d strategy:%d] error: expected %d internal nodes got %d (structure %d)", s.leaves, s.strategy, s.internal, tree.InternalNodeCount(), n)
+		}
+	}
+}
+
+func TestTreeShapeAfterMutations(t *testing.T) {
+	contents := paddingContents(5)
+	tree, err := NewTree(contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	if err := tree.Remove(contents[4]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if tree.LeafCount() != 4 || tree.Depth() != 2 || tree.InternalNodeCount() != 3 {
+		t.Errorf("error: unexpected shape after remove: %d leaves, depth %d, %d internal nodes", tree.LeafCount(), tree.Depth(), tree.InternalNodeCount())
+	}
+
+	if err := tree.RebuildTreeWith(contents[:2]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if tree.LeafCount() != 2 || tree.Depth() != 1 || tree.InternalNodeCount() != 1 {
+		t.Errorf("error: unexpected shape after rebuild: %d leaves, depth %d, %d internal nodes", tree.LeafCount(), tree.Depth(), tree.InternalNodeCount())
+	}
+}
+
+func TestGetLeaf(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		tree, err := NewTree(table[i].contents)
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+
+		for j, c := range table[i].contents {
+			hash, _ := c.CalculateHash()
+
+			leaf, ok := tree.GetLeaf(hash)
+			if !ok || leaf != tree.Leaves[j] || leaf.Item != c {
+				t.Errorf("[case:%d] error: expected leaf %d for content %v", table[i].testCaseId, j, c)
+			}
+
+			idx, err := tree.ContentIndex(c)
+			if err != nil || idx != j {
+				t.Errorf("[case:%d] error: expected index %d got %d (%v)", table[i].testCaseId, j, idx, err)
+			}
+		}
+
+		missing, _ := table[i].notInContents.CalculateHash()
+		if _, ok := tree.GetLeaf(missing); ok {
+			t.Errorf("[case:%d] error: expected no leaf for content not in the tree", table[i].testCaseId)
+		}
+		if _, err := tree.ContentIndex(table[i].notInContents); err != ErrContentNotFound {
+			t.Errorf("[case:%d] error: expected ErrContentNotFound got %v", table[i].testCaseId, err)
+		}
+	}
+}
+
+func TestGetLeafRepeatedContent(t *testing.T) {
+	repeated := TestSHA256Content{x: "Repeated"}
+	contents := []Storable{TestSHA256Content{x: "Hello"}, repeated, repeated}
+	hash, _ := repeated.CalculateHash()
+
+	tree, err := NewTree(contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	// Leaves 1 and 2 hold the repeated content, leaf 3 is the padding duplicate.
+	if !tree.Leaves[3].dup || bytes.Compare(tree.Leaves[3].Hash, hash) != 0 {
+		t.Fatalf("error: expected the repeated content to be used for padding")
+	}
+
+	leaf, ok := tree.GetLeaf(hash)
+	if !ok || leaf != tree.Leaves[1] {
+		t.Errorf("error: expected the first occurrence of the repeated content")
+	}
+
+	// Once the first occurrence changes, the second one must be found, never the padding.
+	if err := tree.UpdateLeaf(repeated, TestSHA256Content{x: "Updated"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	leaf, ok = tree.GetLeaf(hash)
+	if !ok || leaf != tree.Leaves[2] || leaf.dup {
+		t.Errorf("error: expected the second occurrence of the repeated content")
+	}
+
+	if err := tree.UpdateLeaf(repeated, TestSHA256Content{x: "Updated again"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if _, ok := tree.GetLeaf(hash); ok {
+		t.Errorf("error: expected the padding duplicate not to be returned")
+	}
+	if err := tree.UpdateLeaf(repeated, TestSHA256Content{x: "Updated"}); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+
+	if err := tree.Remove(TestSHA256Content{x: "Hello"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if idx, err := tree.ContentIndex(TestSHA256Content{x: "Updated again"}); err != nil || idx != 1 {
+		t.Errorf("error: expected index 1 after removal got %d (%v)", idx, err)
+	}
+}
+
+func TestNodeMetadata(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		for n := 1; n <= 9; n++ {
+			tree, err := NewTreeWithOptions(paddingContents(n), WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			if tree.Root.Height() != tree.Depth() {
+				t.Errorf("[leaves:%d strategy:%d] error: expected root height %d got %d", n, strategy, tree.Depth(), tree.Root.Height())
+			}
+			if len(tree.RealLeaves()) != n {
+				t.Errorf("[leaves:%d strategy:%d] error: expected %d real leaves got %d", n, strategy, n, len(tree.RealLeaves()))
+			}
+
+			for i, l := range tree.Leaves {
+				idx, ok := l.LeafIndex()
+				if !ok || idx != i || !l.IsLeaf() || l.Height() != 0 {
+					t.Errorf("[leaves:%d strategy:%d] error: unexpected metadata for leaf %d: index %d %t, height %d", n, strategy, i, idx, ok, l.Height())
+				}
+				if l.IsDuplicate() != (i >= n) {
+					t.Errorf("[leaves:%d strategy:%d] error: leaf %d: expected duplicate %t", n, strategy, i, i >= n)
+				}
+			}
+
+			tree.Walk(func(node *Node, depth int) bool {
+				if node.IsLeaf() {
+					return true
+				}
+				if _, ok := node.LeafIndex(); ok {
+					t.Errorf("[leaves:%d strategy:%d] error: internal node reports a leaf index", n, strategy)
+				}
+				if node.Height() != parentHeight(node.Left, node.Right) {
+					t.Errorf("[leaves:%d strategy:%d] error: inconsistent height %d", n, strategy, node.Height())
+				}
+				return true
+			})
+		}
+	}
+}
+
+func TestLeafIndexSurvivesMutations(t *testing.T) {
+	contents := paddingContents(7)
+	tree, err := NewTree(contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	assertIndices := func(stage string) {
+		for i, l := range tree.Leaves {
+			if idx, ok := l.LeafIndex(); !ok || idx != i {
+				t.Errorf("error: %s: expected leaf index %d got %d", stage, i, idx)
+			}
+		}
+	}
+
+	if err := tree.RebuildTree(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	assertIndices("rebuild")
+
+	if err := tree.Remove(contents[2]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	assertIndices("remove")
+
+	if err := tree.UpdateLeaf(contents[5], TestSHA256Content{x: "Updated"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	assertIndices("update")
+
+	if err := tree.RebuildTree(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	assertIndices("second rebuild")
+}
+
+func TestMerklePath(t *testing.T) {
+	configs := map[string][]Option{
+		"default":    nil,
+		"separated":  {WithDomainSeparation(true)},
+		"sorted":     {WithSortedPairs(true)},
+		"selfPair":   {WithPaddingStrategy(PadSelfPair)},
+		"promote":    {WithPaddingStrategy(PadPromote)},
+		"singleLeaf": {WithSingleLeafRoot(true)},
+	}
+	for name, opts := range configs {
+		for n := 1; n <= 9; n++ {
+			contents := paddingContents(n)
+			tree, err := NewTreeWithOptions(contents, opts...)
+			if err != nil {
+				t.Fatalf("[%s leaves:%d] error: unexpected error: %v", name, n, err)
+			}
+
+			for i, c := range contents {
+				path, err := tree.MerklePath(c)
+				if err != nil {
+					t.Fatalf("[%s leaves:%d] error: unexpected error: %v", name, n, err)
+				}
+				if err := tree.VerifyMerklePath(c, path); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid path got %v", name, n, i, err)
+				}
+				if err := tree.VerifyMerklePath(TestSHA256Content{x: "forged"}, path); err != ErrInvalidProof {
+					t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof got %v", name, n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestMerklePathErrors(t *testing.T) {
+	tree, err := NewTree(paddingContents(3))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	if _, err := tree.MerklePath(TestSHA256Content{x: "missing"}); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if _, err := tree.MerklePathAt(3); err == nil {
+		t.Errorf("error: expected out of range error")
+	}
+
+	path, _ := tree.MerklePathAt(0)
+	path[0].Left = !path[0].Left
+	if err := tree.VerifyMerklePath(paddingContents(3)[0], path); err != ErrInvalidProof {
+		t.Errorf("error: expected ErrInvalidProof got %v", err)
+	}
+}
+
+func TestMerklePathsAll(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		for n := 1; n <= 17; n++ {
+			contents := paddingContents(n)
+			tree, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			paths, err := tree.MerklePathsAll()
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+			if len(paths) != n {
+				t.Errorf("[leaves:%d strategy:%d] error: expected %d paths got %d", n, strategy, n, len(paths))
+			}
+
+			for i, c := range contents {
+				expected, _ := tree.MerklePathAt(i)
+				if !reflect.DeepEqual(paths[i], expected) {
+					t.Errorf("[leaves:%d strategy:%d index:%d] error: expected path %v got %v", n, strategy, i, expected, paths[i])
+				}
+				if err := tree.VerifyMerklePath(c, paths[i]); err != nil {
+					t.Errorf("[leaves:%d strategy:%d index:%d] error: expected valid path got %v", n, strategy, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestMerklePathsEachStops(t *testing.T) {
+	tree, err := NewTree(paddingContents(5))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = tree.MerklePathsEach(func(int, []ProofStep) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("error: expected stop after 1 call got %v after %d", err, calls)
+	}
+}
+
+func TestReleaseItems(t *testing.T) {
+	contents := paddingContents(5)
+	tree, err := NewTreeWithOptions(contents, WithRetainItems(false))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	expected, _ := NewTree(contents)
+	if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), tree.MerkleRoot())
+	}
+	for i, l := range tree.Leaves {
+		if l.Item != nil {
+			t.Errorf("[index:%d] error: expected released item got %v", i, l.Item)
+		}
+	}
+
+	for i, c := range contents {
+		path, err := tree.MerklePath(c)
+		if err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", i, err)
+		}
+		if err := tree.VerifyMerklePath(c, path); err != nil {
+			t.Errorf("[index:%d] error: expected valid path got %v", i, err)
+		}
+	}
+
+	if err := tree.RebuildTree(); err != ErrItemsReleased {
+		t.Errorf("error: expected ErrItemsReleased got %v", err)
+	}
+	if _, err := tree.Root.VerifyNode(); err != ErrItemsReleased {
+		t.Errorf("error: expected ErrItemsReleased got %v", err)
+	}
+
+	if err := tree.UpdateLeaf(contents[4], TestSHA256Content{x: "updated"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if err := tree.Remove(contents[0]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	for i, l := range tree.Leaves {
+		if l.Item != nil {
+			t.Errorf("[index:%d] error: expected released item got %v", i, l.Item)
+		}
+	}
+}
+
+type TestLargeContent struct {
+	x       string
+	payload []byte
+}
+
+func (t TestLargeContent) CalculateHash() ([]byte, error) {
+	return TestSHA256Content{x: t.x}.CalculateHash()
+}
+
+func (t TestLargeContent) Equals(other Storable) (bool, error) {
+	return t.x == other.(TestLargeContent).x, nil
+}
+
+func TestReleaseItemsMemory(t *testing.T) {
+	const items, size = 64, 1 << 20
+
+	tree, err := NewTree(func() []Storable {
+		contents := make([]Storable, items)
+		for i := range contents {
+			contents[i] = TestLargeContent{x: fmt.Sprintf("item-%d", i), payload: make([]byte, size)}
+		}
+		return contents
+	}())
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	tree.ReleaseItems()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if freed := int64(before.HeapAlloc) - int64(after.HeapAlloc); freed < items*size/2 {
+		t.Errorf("error: expected releasing items to free at least %d bytes got %d", items*size/2, freed)
+	}
+	if _, err := tree.ContentIndex(TestLargeContent{x: "item-3"}); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+}
+
+func TestNewTreeWithRejectDuplicates(t *testing.T) {
+	// An odd number of distinct items still gets a padding duplicate.
+	if _, err := NewTreeWithOptions(paddingContents(5), WithRejectDuplicates(true)); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+
+	contents := append(paddingContents(4), TestSHA256Content{x: "leaf-1"})
+	if _, err := NewTree(contents); err != nil {
+		t.Errorf("error: expected duplicates to be allowed by default got %v", err)
+	}
+
+	_, err := NewTreeWithOptions(contents, WithRejectDuplicates(true))
+	var dup *DuplicateError
+	if !errors.As(err, &dup) {
+		t.Fatalf("error: expected *DuplicateError got %v", err)
+	}
+	if dup.First != 1 || dup.Second != 4 {
+		t.Errorf("error: expected duplicate at indices 1 and 4 got %d and %d", dup.First, dup.Second)
+	}
+
+	tree, _ := NewTreeWithOptions(paddingContents(4), WithRejectDuplicates(true))
+	root := tree.MerkleRoot()
+	if err := tree.UpdateLeaf(TestSHA256Content{x: "leaf-3"}, TestSHA256Content{x: "leaf-0"}); !errors.As(err, &dup) {
+		t.Errorf("error: expected *DuplicateError got %v", err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), root) {
+		t.Errorf("error: expected rejected update to leave the root untouched")
+	}
+}
+
+func TestRehashWith(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithDomainSeparation(true)}, {WithPaddingStrategy(PadPromote)}} {
+		contents := paddingContents(7)
+		tree, err := NewTreeWithOptions(contents, opts...)
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		if err := tree.RehashWith(sha512.New); err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+
+		expected, _ := NewTreeWithHashStrategy(contents, sha512.New, opts...)
+		if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+			t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), tree.MerkleRoot())
+		}
+		if len(tree.MerkleRoot()) != sha512.Size {
+			t.Errorf("error: expected a %d byte root got %d", sha512.Size, len(tree.MerkleRoot()))
+		}
+
+		path, _ := tree.MerklePath(contents[3])
+		if err := tree.VerifyMerklePath(contents[3], path); err != nil {
+			t.Errorf("error: expected valid path got %v", err)
+		}
+	}
+
+	released, _ := NewTreeWithOptions(paddingContents(3), WithRetainItems(false))
+	root := released.MerkleRoot()
+	if err := released.RehashWith(sha512.New); err != ErrItemsReleased {
+		t.Errorf("error: expected ErrItemsReleased got %v", err)
+	}
+	if !bytes.Equal(released.MerkleRoot(), root) {
+		t.Errorf("error: expected failed rehash to leave the root untouched")
+	}
+}
+
+func TestClone(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		contents := paddingContents(7)
+		tree, _ := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+		root := append([]byte(nil), tree.MerkleRoot()...)
+
+		clone := tree.Clone()
+		if !bytes.Equal(clone.MerkleRoot(), root) {
+			t.Errorf("[strategy:%d] error: expected root %x got %x", strategy, root, clone.MerkleRoot())
+		}
+
+		var originals = map[*Node]bool{}
+		tree.Walk(func(n *Node, depth int) bool {
+			originals[n] = true
+			return true
+		})
+		clone.Walk(func(n *Node, depth int) bool {
+			if originals[n] || n.Tree != clone || n.Parent != nil && originals[n.Parent] {
+				t.Errorf("[strategy:%d] error: expected clone nodes to be detached from the original", strategy)
+			}
+			return true
+		})
+
+		if err := clone.UpdateLeaf(contents[2], TestSHA256Content{x: "updated"}); err != nil {
+			t.Fatalf("[strategy:%d] error: unexpected error: %v", strategy, err)
+		}
+		if err := clone.Remove(contents[6]); err != nil {
+			t.Fatalf("[strategy:%d] error: unexpected error: %v", strategy, err)
+		}
+		if bytes.Equal(clone.MerkleRoot(), root) {
+			t.Errorf("[strategy:%d] error: expected clone root to change", strategy)
+		}
+
+		if !bytes.Equal(tree.MerkleRoot(), root) {
+			t.Errorf("[strategy:%d] error: expected original root %x got %x", strategy, root, tree.MerkleRoot())
+		}
+		for i, c := range contents {
+			path, err := tree.MerklePath(c)
+			if err != nil {
+				t.Fatalf("[strategy:%d index:%d] error: unexpected error: %v", strategy, i, err)
+			}
+			if err := tree.VerifyMerklePath(c, path); err != nil {
+				t.Errorf("[strategy:%d index:%d] error: expected valid path got %v", strategy, i, err)
+			}
+		}
+		if verified, err := tree.Root.VerifyNode(); err != nil || !bytes.Equal(verified, root) {
+			t.Errorf("[strategy:%d] error: expected original to verify got %x (%v)", strategy, verified, err)
+		}
+	}
+}
+
+// TestCancelContent calls cancel when hashed, to abort the construction of
+// the tree holding it.
+type TestCancelContent struct {
+	TestSlowContent
+	cancel context.CancelFunc
+}
+
+func (t TestCancelContent) CalculateHash() ([]byte, error) {
+	if t.cancel != nil {
+		t.cancel()
+	}
+
+	return t.TestSlowContent.CalculateHash()
+}
+
+func cancelContents(n, at int, cancel context.CancelFunc, hashed *int32) []Storable {
+	contents := make([]Storable, n)
+	for i := range contents {
+		c := TestCancelContent{TestSlowContent: TestSlowContent{x: fmt.Sprintf("leaf-%d", i), hashed: hashed}}
+		if i == at {
+			c.cancel = cancel
+		}
+		contents[i] = c
+	}
+
+	return contents
+}
+
+func TestNewTreeContext(t *testing.T) {
+	var hashed int32
+	tree, err := NewTreeContext(context.Background(), cancelContents(9, -1, nil, &hashed))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	expected, _ := NewTree(paddingContents(9))
+	if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), tree.MerkleRoot())
+	}
+}
+
+func TestNewTreeContextCancelLeaves(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		var hashed int32
+		ctx, cancel := context.WithCancel(context.Background())
+
+		start := time.Now()
+		_, err := NewTreeContext(ctx, cancelContents(20000, 3, cancel, &hashed), WithWorkers(workers))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("[workers:%d] error: expected context.Canceled got %v", workers, err)
+		}
+		if workers == 1 && !strings.Contains(err.Error(), "index 4 of 20000") {
+			t.Errorf("[workers:%d] error: expected the error to tell how far it got, got %v", workers, err)
+		}
+		if n := atomic.LoadInt32(&hashed); n > 100 {
+			t.Errorf("[workers:%d] error: expected hashing to stop promptly, hashed %d leaves", workers, n)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("[workers:%d] error: expected prompt return got %v", workers, elapsed)
+		}
+	}
+}
+
+func TestNewTreeContextCancelLevels(t *testing.T) {
+	var hashed int32
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := NewTreeContext(ctx, cancelContents(8, 7, cancel, &hashed))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error: expected context.Canceled got %v", err)
+	}
+	if !strings.Contains(err.Error(), "level 1") {
+		t.Errorf("error: expected the error to name the level it stopped at, got %v", err)
+	}
+}
+
+func TestMerkleRootIsACopy(t *testing.T) {
+	tree, _ := NewTree(paddingContents(4))
+	expected := append([]byte(nil), tree.MerkleRootUnsafe()...)
+
+	root := tree.MerkleRoot()
+	root[0] ^= 0xff
+	_ = append(root[:1], 0xff)
+	if !bytes.Equal(tree.MerkleRoot(), expected) {
+		t.Errorf("error: expected root %x got %x", expected, tree.MerkleRoot())
+	}
+
+	path, _ := tree.MerklePathAt(1)
+	path[0].Hash[0] ^= 0xff
+	paths, _ := tree.MerklePathsAll()
+	paths[2][1].Hash[0] ^= 0xff
+
+	if verified, err := tree.Root.VerifyNode(); err != nil || !bytes.Equal(verified, expected) {
+		t.Errorf("error: expected the tree to be untouched got %x (%v)", verified, err)
+	}
+	path, _ = tree.MerklePathAt(1)
+	if err := tree.VerifyMerklePath(paddingContents(4)[1], path); err != nil {
+		t.Errorf("error: expected valid path got %v", err)
+	}
+}
+
+func TestNewTreeFromLeafHashes(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithSortedPairs(true)}, {WithPaddingStrategy(PadPromote)}} {
+		for n := 1; n <= 9; n++ {
+			contents := paddingContents(n)
+			expected, _ := NewTreeWithOptions(contents, opts...)
+
+			hashes := make([][]byte, n)
+			for i, l := range expected.RealLeaves() {
+				hashes[i] = l.Hash
+			}
+
+			tree, err := NewTreeFromLeafHashes(hashes, sha256.New, opts...)
+			if err != nil {
+				t.Fatalf("[leaves:%d] error: unexpected error: %v", n, err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+				t.Errorf("[leaves:%d] error: expected root %x got %x", n, expected.MerkleRoot(), tree.MerkleRoot())
+			}
+
+			for i, c := range contents {
+				if tree.Leaves[i].Item != nil {
+					t.Errorf("[leaves:%d index:%d] error: expected no item got %v", n, i, tree.Leaves[i].Item)
+				}
+
+				path, err := tree.MerklePathAt(i)
+				if err != nil {
+					t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+				}
+				if err := expected.VerifyMerklePath(c, path); err != nil {
+					t.Errorf("[leaves:%d index:%d] error: expected valid path got %v", n, i, err)
+				}
+				if _, err := tree.MerklePath(c); err != ErrLeafHashesOnly {
+					t.Errorf("[leaves:%d index:%d] error: expected ErrLeafHashesOnly got %v", n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestNewTreeFromLeafHashesErrors(t *testing.T) {
+	if _, err := NewTreeFromLeafHashes(nil, sha256.New); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+
+	hashes := [][]byte{make([]byte, sha256.Size), make([]byte, sha512.Size)}
+	if _, err := NewTreeFromLeafHashes(hashes, sha256.New); err == nil || !strings.Contains(err.Error(), "index 1") {
+		t.Errorf("error: expected a length error naming index 1 got %v", err)
+	}
+	if _, err := NewTreeFromLeafHashes(hashes[1:], sha512.New); err != nil {
+		t.Errorf("error: unexpected error: %v", err)
+	}
+
+	tree, _ := NewTreeFromLeafHashes(hashes[:1], sha256.New)
+	if err := tree.RebuildTree(); err != ErrItemsReleased {
+		t.Errorf("error: expected ErrItemsReleased got %v", err)
+	}
+}
+
+func TestCommittedRoot(t *testing.T) {
+	hello, hi, hey := TestSHA256Content{x: "Hello"}, TestSHA256Content{x: "Hi"}, TestSHA256Content{x: "Hey"}
+	five := paddingContents(5)
+
+	for i, c := range []struct {
+		short, long []Storable
+		opts        []Option
+	}{
+		{short: []Storable{hello}, long: []Storable{hello, hello}},
+		{short: []Storable{hello, hi, hey}, long: []Storable{hello, hi, hey, hey}},
+		{short: []Storable{hello, hi, hey}, long: []Storable{hello, hi, hey, hey}, opts: []Option{WithDomainSeparation(true)}},
+		{short: []Storable{hello, hi, hey}, long: []Storable{hello, hi, hey, hey}, opts: []Option{WithHashStrategy(sha512.New)}},
+		{short: five, long: append(append([]Storable{}, five...), five[4]), opts: []Option{WithSortedPairs(true)}},
+	} {
+		opts := append([]Option{WithCountCommitment(true)}, c.opts...)
+		short, err := NewTreeWithOptions(c.short, opts...)
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", i, err)
+		}
+		long, err := NewTreeWithOptions(c.long, opts...)
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", i, err)
+		}
+
+		if !bytes.Equal(short.MerkleRoot(), long.MerkleRoot()) {
+			t.Errorf("[case:%d] error: expected the padded trees to share their merkle root", i)
+		}
+		shortRoot, _ := short.CommittedRoot()
+		longRoot, _ := long.CommittedRoot()
+		if bytes.Equal(shortRoot, longRoot) {
+			t.Errorf("[case:%d] error: expected different committed roots got %x", i, shortRoot)
+		}
+		if root, _ := short.RootHash(); !bytes.Equal(root, shortRoot) {
+			t.Errorf("[case:%d] error: expected RootHash %x got %x", i, shortRoot, root)
+		}
+
+		for j, l := range short.RealLeaves() {
+			p, err := short.Prove(l.Hash)
+			if err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if !p.CountCommitment || p.Size != len(c.short) {
+				t.Errorf("[case:%d leaf:%d] error: expected a proof committing to %d leaves got %+v", i, j, len(c.short), p)
+			}
+			if err := short.VerifyProof(l.Hash, p); err != nil {
+				t.Errorf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if err := p.Verify(shortRoot); err != nil {
+				t.Errorf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if !AllValid(shortRoot, []ProofItem{{Leaf: l.Hash, Proof: p}}, 1) {
+				t.Errorf("[case:%d leaf:%d] error: expected the batch to verify", i, j)
+			}
+
+			// The proof holds for the merkle root of the longer tree but not
+			// for its committed root.
+			if err := p.Verify(longRoot); err != ErrInvalidProof {
+				t.Errorf("[case:%d leaf:%d] error: expected ErrInvalidProof got %v", i, j, err)
+			}
+			if err := long.VerifyProof(l.Hash, p); err != ErrInvalidProof {
+				t.Errorf("[case:%d leaf:%d] error: expected ErrInvalidProof got %v", i, j, err)
+			}
+			legacy := *p
+			legacy.CountCommitment = false
+			if err := legacy.Verify(long.MerkleRoot()); err != nil {
+				t.Errorf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+
+			if c.opts != nil {
+				continue
+			}
+			data, err := p.MarshalBinary()
+			if err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			var decoded Proof
+			if err := decoded.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(&decoded, p) {
+				t.Errorf("[case:%d leaf:%d] error: expected %+v got %+v (%v)", i, j, p, &decoded, err)
+			}
+			js, _ := json.Marshal(p)
+			if p.EncodedSize(ProofJSON) != len(js) {
+				t.Errorf("[case:%d leaf:%d] error: expected json size %d got %d", i, j, len(js), p.EncodedSize(ProofJSON))
+			}
+		}
+	}
+
+	if _, err := (&MerkleTree{}).CommittedRoot(); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+}
+
+func TestMerkleTreePrefixes(t *testing.T) {
+	contents := paddingContents(5)
+	separated, err := NewTreeWithOptions(contents, WithDomainSeparation(true))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	defaults, err := NewTreeWithOptions(contents, WithLeafPrefix([]byte{0x00}), WithNodePrefix([]byte{0x01}))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if !bytes.Equal(defaults.MerkleRoot(), separated.MerkleRoot()) {
+		t.Errorf("error: expected the default prefixes to give the domain separated root")
+	}
+
+	a, err := NewTreeWithOptions(contents, WithLeafPrefix([]byte("A:leaf")), WithNodePrefix([]byte("A:node")))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	b, err := NewTreeWithOptions(contents, WithLeafPrefix([]byte("B:leaf")))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	for i, root := range [][]byte{a.MerkleRoot(), b.MerkleRoot()} {
+		if bytes.Equal(root, separated.MerkleRoot()) {
+			t.Errorf("[case:%d] error: expected prefixes to change the root", i)
+		}
+	}
+	if bytes.Equal(a.MerkleRoot(), b.MerkleRoot()) {
+		t.Errorf("error: expected trees of different prefixes to have different roots")
+	}
+
+	itemHash, _ := contents[0].CalculateHash()
+	h := sha256.New()
+	h.Write([]byte("A:leaf"))
+	h.Write(itemHash)
+	if leaf := a.RealLeaves()[0].Hash; !bytes.Equal(leaf, h.Sum(nil)) {
+		t.Errorf("error: expected leaf hash %x got %x", h.Sum(nil), leaf)
+	}
+
+	for i, tree := range []*MerkleTree{a, b} {
+		root, _ := tree.RootHash()
+		for j, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			if err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if !p.DomainSeparation {
+				t.Errorf("[case:%d leaf:%d] error: expected prefixes to enable domain separation", i, j)
+			}
+			if err := tree.VerifyProof(l.Hash, p); err != nil {
+				t.Errorf("[case:%d leaf:%d] error: expected valid proof got %v", i, j, err)
+			}
+
+			data, err := p.MarshalBinary()
+			if err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			var decoded Proof
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if !reflect.DeepEqual(p, &decoded) {
+				t.Errorf("[case:%d leaf:%d] error: expected %+v got %+v", i, j, p, &decoded)
+			}
+			if err := decoded.Verify(root); err != nil {
+				t.Errorf("[case:%d leaf:%d] error: expected valid decoded proof got %v", i, j, err)
+			}
+			if p.EncodedSize(ProofBinary) != len(data) {
+				t.Errorf("[case:%d leaf:%d] error: expected binary size %d got %d", i, j, len(data), p.EncodedSize(ProofBinary))
+			}
+
+			js, err := json.Marshal(p)
+			if err != nil {
+				t.Fatalf("[case:%d leaf:%d] error: unexpected error: %v", i, j, err)
+			}
+			if p.EncodedSize(ProofJSON) != len(js) {
+				t.Errorf("[case:%d leaf:%d] error: expected JSON size %d got %d", i, j, len(js), p.EncodedSize(ProofJSON))
+			}
+			decoded = Proof{}
+			if err := json.Unmarshal(js, &decoded); err != nil || !reflect.DeepEqual(p, &decoded) {
+				t.Errorf("[case:%d leaf:%d] error: expected %+v got %+v (%v)", i, j, p, &decoded, err)
+			}
+
+			p.NodePrefix = []byte("C:node")
+			if err := p.Verify(root); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("[case:%d leaf:%d] error: expected ErrInvalidProof for another prefix got %v", i, j, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// MerkleTreeJSNode is a step of a proof of the JavaScript merkletreejs
+// library. It decodes from JSON both as an element of getProof, an object
+// whose data is a Buffer as JSON.stringify writes it or a 0x prefixed hex
+// string, and as an element of getHexProof, a bare 0x prefixed hex string.
+type MerkleTreeJSNode struct {
+	// Position is the side of the sibling, "left" or "right". It is empty
+	// for the steps of getHexProof, which only verify with SortPairs.
+	Position string
+	Data     []byte
+}
+
+// UnmarshalJSON decodes a step of getProof or getHexProof.
+func (n *MerkleTreeJSNode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		d, err := decodeHex("proof node", s)
+		if err != nil {
+			return err
+		}
+		*n = MerkleTreeJSNode{Data: d}
+
+		return nil
+	}
+
+	var obj struct {
+		Position string          `json:"position"`
+		Data     json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("error: invalid merkletreejs proof node: %w", err)
+	}
+	if obj.Position != "left" && obj.Position != "right" {
+		return fmt.Errorf("error: invalid merkletreejs proof node position %q", obj.Position)
+	}
+
+	d, err := decodeBuffer(obj.Data)
+	if err != nil {
+		return err
+	}
+	*n = MerkleTreeJSNode{Position: obj.Position, Data: d}
+
+	return nil
+}
+
+// decodeBuffer decodes the data of a merkletreejs proof node: a Node.js
+// Buffer as JSON.stringify writes it, {"type":"Buffer","data":[...]}, or a 0x
+// prefixed hex string.
+func decodeBuffer(data json.RawMessage) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return decodeHex("proof node data", s)
+	}
+
+	var buf struct {
+		Type string `json:"type"`
+		Data []int  `json:"data"`
+	}
+	if err := json.Unmarshal(data, &buf); err != nil || buf.Type != "Buffer" {
+		return nil, errors.New("error: merkletreejs proof node data is neither a Buffer nor a hex string")
+	}
+
+	out := make([]byte, len(buf.Data))
+	for i, b := range buf.Data {
+		if b < 0 || b > 0xff {
+			return nil, fmt.Errorf("error: merkletreejs proof node data has byte %d out of range", b)
+		}
+		out[i] = byte(b)
+	}
+
+	return out, nil
+}
+
+// MerkleTreeJSOptions are the options a merkletreejs MerkleTree was built
+// with that bear on its proofs.
+type MerkleTreeJSOptions struct {
+	// HashStrategy is the hash function of the tree, SHA-256 when nil.
+	HashStrategy func() hash.Hash
+	SortPairs    bool
+	// SortLeaves does not change how a proof verifies; Index is then the
+	// position of the leaf among the sorted leaves.
+	SortLeaves bool
+	// DuplicateOdd trees hash the last node of an odd layer with itself, and
+	// getProof leaves that copy out of their proofs. Verifying them needs
+	// Index and Size.
+	DuplicateOdd bool
+	// Index is the position of the leaf and Size the number of leaves of the
+	// tree. They are only used with DuplicateOdd.
+	Index, Size int
+}
+
+// VerifyMerkleTreeJS checks proof, a proof of the merkletreejs library of the
+// leaf hash leaf, against root, hashing as a merkletreejs tree built with o.
+// It returns an error wrapping ErrInvalidProof when the proof does not lead to
+// root.
+func VerifyMerkleTreeJS(root, leaf []byte, proof []MerkleTreeJSNode, o MerkleTreeJSOptions) error {
+	hashStrategy := o.HashStrategy
+	if hashStrategy == nil {
+		hashStrategy = sha256.New
+	}
+	if o.DuplicateOdd && (o.Index < 0 || o.Index >= o.Size) {
+		return fmt.Errorf("error: leaf index %d out of range for %d leaves", o.Index, o.Size)
+	}
+
+	pair := func(left, right []byte) []byte {
+		if o.SortPairs && bytes.Compare(left, right) > 0 {
+			left, right = right, left
+		}
+		h := hashStrategy()
+		h.Write(left)
+		h.Write(right)
+
+		return h.Sum(nil)
+	}
+
+	node, next := leaf, 0
+	index, size := o.Index, o.Size
+	for next < len(proof) || o.DuplicateOdd && size > 1 {
+		if o.DuplicateOdd && index == size-1 && size%2 == 1 {
+			node = pair(node, node)
+		} else {
+			if next == len(proof) {
+				return fmt.Errorf("error: merkletreejs proof is missing steps: %w", ErrInvalidProof)
+			}
+
+			step := proof[next]
+			next++
+			switch {
+			case o.SortPairs || step.Position == "right":
+				node = pair(node, step.Data)
+			case step.Position == "left":
+				node = pair(step.Data, node)
+			default:
+				return fmt.Errorf("error: merkletreejs proof step %d has no position and pairs are not sorted", next-1)
+			}
+		}
+
+		index, size = index/2, (size+1)/2
+	}
+
+	if !RootEqual(node, root) {
+		return fmt.Errorf("error: merkletreejs proof root mismatch; got: %x, want: %x: %w", node, root, ErrInvalidProof)
+	}
+
+	return nil
+}
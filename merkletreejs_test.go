@@ -0,0 +1,105 @@
+package merklego
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// merkleTreeJSFixture is a fixture of testdata/merkletreejs, written by its
+// generate.js with the merkletreejs package.
+type merkleTreeJSFixture struct {
+	Options struct {
+		SortPairs    bool `json:"sortPairs"`
+		SortLeaves   bool `json:"sortLeaves"`
+		DuplicateOdd bool `json:"duplicateOdd"`
+	} `json:"options"`
+	Root   string `json:"root"`
+	Proofs []struct {
+		Leaf     string             `json:"leaf"`
+		Index    int                `json:"index"`
+		Size     int                `json:"size"`
+		Proof    []MerkleTreeJSNode `json:"proof"`
+		HexProof []MerkleTreeJSNode `json:"hexProof"`
+	} `json:"proofs"`
+}
+
+func TestVerifyMerkleTreeJSFixtures(t *testing.T) {
+	files, err := filepath.Glob("testdata/merkletreejs/*.json")
+	require.NoError(t, err)
+	require.Len(t, files, 8)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var f merkleTreeJSFixture
+		require.NoError(t, json.Unmarshal(data, &f), file)
+
+		root, err := ParseRoot(f.Root)
+		require.NoError(t, err)
+		for _, p := range f.Proofs {
+			leaf, err := HexBlock(p.Leaf)
+			require.NoError(t, err)
+			o := MerkleTreeJSOptions{
+				SortPairs:    f.Options.SortPairs,
+				SortLeaves:   f.Options.SortLeaves,
+				DuplicateOdd: f.Options.DuplicateOdd,
+				Index:        p.Index,
+				Size:         p.Size,
+			}
+
+			require.NoError(t, VerifyMerkleTreeJS(root, leaf, p.Proof, o), "%s leaf %d", file, p.Index)
+			if f.Options.SortPairs {
+				require.NoError(t, VerifyMerkleTreeJS(root, leaf, p.HexProof, o), "%s leaf %d", file, p.Index)
+			} else if len(p.Proof) > 0 {
+				swapped := append([]MerkleTreeJSNode(nil), p.Proof...)
+				swapped[0].Position = map[string]string{"left": "right", "right": "left"}[swapped[0].Position]
+				require.ErrorIs(t, VerifyMerkleTreeJS(root, leaf, swapped, o), ErrInvalidProof)
+			}
+
+			tampered := append([]byte(nil), leaf...)
+			tampered[0] ^= 1
+			require.ErrorIs(t, VerifyMerkleTreeJS(root, tampered, p.Proof, o), ErrInvalidProof)
+		}
+
+		// The last leaf of 11 is hashed with itself: without the flag its
+		// proof misses a step.
+		if f.Options.DuplicateOdd {
+			last := f.Proofs[len(f.Proofs)-1]
+			leaf, err := HexBlock(last.Leaf)
+			require.NoError(t, err)
+			require.ErrorIs(t, VerifyMerkleTreeJS(root, leaf, last.Proof, MerkleTreeJSOptions{SortPairs: f.Options.SortPairs}), ErrInvalidProof)
+		}
+	}
+}
+
+func TestMerkleTreeJSNode(t *testing.T) {
+	var nodes []MerkleTreeJSNode
+	require.NoError(t, json.Unmarshal([]byte(`["0x0102", {"position":"left","data":"0x03"}, {"position":"right","data":{"type":"Buffer","data":[4,255]}}]`), &nodes))
+	require.Equal(t, []MerkleTreeJSNode{
+		{Data: []byte{1, 2}},
+		{Position: "left", Data: []byte{3}},
+		{Position: "right", Data: []byte{4, 255}},
+	}, nodes)
+
+	for _, invalid := range []string{
+		`"0102"`,
+		`{"position":"up","data":"0x01"}`,
+		`{"position":"left","data":{"type":"Uint8Array","data":[1]}}`,
+		`{"position":"left","data":{"type":"Buffer","data":[256]}}`,
+		`{"position":"left","data":1}`,
+		`1`,
+	} {
+		var n MerkleTreeJSNode
+		require.Error(t, json.Unmarshal([]byte(invalid), &n), invalid)
+	}
+
+	leaf, sibling := []byte{1}, []byte{2}
+	err := VerifyMerkleTreeJS(nil, leaf, []MerkleTreeJSNode{{Data: sibling}}, MerkleTreeJSOptions{})
+	require.ErrorContains(t, err, "no position")
+	err = VerifyMerkleTreeJS(nil, leaf, nil, MerkleTreeJSOptions{DuplicateOdd: true, Index: 1, Size: 1})
+	require.ErrorContains(t, err, "out of range")
+}
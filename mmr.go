@@ -0,0 +1,436 @@
+package merklego
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// MMR is a Merkle Mountain Range: an append-only list of perfect binary trees,
+// the mountains, one per bit set in the number of leaves, from the highest on
+// the left to the lowest on the right. Appending a leaf merges the mountains of
+// equal height it completes, so no node is ever rehashed.
+//
+// The nodes are stored flat in post-order, each child before its parent, and
+// numbered from 0 by their position in that order: leaves are at positions 0,
+// 1, 3, 4, 7 and so on, and their first parents at 2, 5 and 6. The canonical
+// format of the range is the one of a FlatMerkleTree: a leaf is H(0x00 ||
+// data) and the parent of left and right H(0x01 || left || right). The root
+// bags the peaks, the roots of the mountains, from the right: the last peak is
+// its own bag, and every peak to its left is hashed with the bag of the peaks
+// to its right as a parent is with its children, H(0x01 || peak || bag). The
+// root of a range of one mountain is its peak, and the one of an empty range
+// the hash of the empty string.
+//
+// The nodes of the range of its first n leaves are the first nodes of the
+// range, so the root of any earlier size is bagged from them again.
+// WithHeadHistory keeps the heads of the last sizes.
+//
+// Nodes are hashed with SHA-256 unless opts set another hash function with
+// WithHashStrategy or WithDigestFunc, keyed with WithHMACKey if set; other
+// options do not apply.
+type MMR struct {
+	config
+	nodes  []TreeNode
+	leaves uint64
+	// history holds the heads of the last sizes, if kept.
+	history headRing
+}
+
+var _ Rooter = (*MMR)(nil)
+
+// MMRProof is the proof of a leaf of a Merkle Mountain Range of Size leaves:
+// the siblings of the leaf in its mountain, from the leaf up, then the peaks
+// bagged with the peak of its mountain, RightBag the bag of the peaks to its
+// right, nil for the last one, and LeftPeaks the peaks to its left, from the
+// nearest.
+type MMRProof struct {
+	Position  uint64
+	Size      uint64
+	Siblings  []TreeNode
+	RightBag  TreeNode
+	LeftPeaks []TreeNode
+}
+
+// NewMMR returns an empty Merkle Mountain Range hashing as opts set.
+func NewMMR(opts ...Option) *MMR {
+	m := &MMR{}
+	for _, opt := range opts {
+		opt(&m.config)
+	}
+
+	m.history = newHeadRing(m.headHistory)
+	m.recordHead()
+
+	return m
+}
+
+// Size returns the number of leaves of the range.
+func (m *MMR) Size() uint64 {
+	return m.leaves
+}
+
+// NodeCount returns the number of nodes of the range, leaves included, the
+// position the next leaf is appended at.
+func (m *MMR) NodeCount() uint64 {
+	return uint64(len(m.nodes))
+}
+
+// Append adds leaf to the range and returns the position of its node, which
+// Proof takes.
+func (m *MMR) Append(leaf []byte) (position uint64) {
+	position = uint64(len(m.nodes))
+	m.nodes = append(m.nodes, m.hashLeaf(leaf))
+	m.leaves++
+
+	// A mountain is merged for every trailing one bit of the previous count.
+	for h := 0; (m.leaves-1)>>uint(h)&1 == 1; h++ {
+		n := len(m.nodes)
+		m.nodes = append(m.nodes, m.hashChildren(m.nodes[n-1<<uint(h+1)], m.nodes[n-1]))
+	}
+	m.recordHead()
+
+	return position
+}
+
+// Root returns the root of the range, its peaks bagged from the right.
+func (m *MMR) Root() []byte {
+	return copyNode(m.bag(m.leaves))
+}
+
+// RootAtSize returns the root the range had when it held its first size
+// leaves, from its head history if kept there.
+func (m *MMR) RootAtSize(size uint64) ([]byte, error) {
+	if size > m.leaves {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", size, m.leaves)
+	}
+	if root, ok := m.history.root(size); ok {
+		return root, nil
+	}
+
+	return copyNode(m.bag(size)), nil
+}
+
+// Heads returns the heads kept by WithHeadHistory, by increasing size.
+func (m *MMR) Heads() []TreeHead {
+	return m.history.heads()
+}
+
+// recordHead keeps the current head in the head history, if any.
+func (m *MMR) recordHead() {
+	if m.history != nil {
+		h, _ := m.Head()
+		m.history.record(h)
+	}
+}
+
+// bag returns the root of the range of the first size leaves.
+func (m *MMR) bag(size uint64) TreeNode {
+	if size == 0 {
+		return m.newHash().Sum(nil)
+	}
+
+	peaks := mmrPeaks(size)
+	nodes := make([]TreeNode, len(peaks))
+	for i, p := range peaks {
+		nodes[i] = m.nodes[p.position]
+	}
+
+	return m.bagPeaks(nodes)
+}
+
+// bagPeaks bags peaks, given from the left, from the right.
+func (m *MMR) bagPeaks(peaks []TreeNode) TreeNode {
+	bag := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		bag = m.hashChildren(peaks[i], bag)
+	}
+
+	return bag
+}
+
+// RootHash returns Root, so that an MMR is a Rooter.
+func (m *MMR) RootHash() ([]byte, error) {
+	return m.Root(), nil
+}
+
+// Proof returns the proof of the leaf at position against the current root.
+func (m *MMR) Proof(position uint64) (*MMRProof, error) {
+	if position >= uint64(len(m.nodes)) || mmrHeight(position) != 0 {
+		return nil, fmt.Errorf("no leaf at position %d of a range of %d nodes", position, len(m.nodes))
+	}
+
+	peaks := mmrPeaks(m.leaves)
+	i := mmrPeakOf(peaks, position)
+	p := &MMRProof{Position: position, Size: m.leaves}
+
+	for pos, h := position, 0; pos != peaks[i].position; h++ {
+		sibling, parent := mmrFamily(pos, h)
+		p.Siblings = append(p.Siblings, copyNode(m.nodes[sibling]))
+		pos = parent
+	}
+
+	if i < len(peaks)-1 {
+		bag := m.nodes[peaks[len(peaks)-1].position]
+		for j := len(peaks) - 2; j > i; j-- {
+			bag = m.hashChildren(m.nodes[peaks[j].position], bag)
+		}
+		p.RightBag = copyNode(bag)
+	}
+	for j := i - 1; j >= 0; j-- {
+		p.LeftPeaks = append(p.LeftPeaks, copyNode(m.nodes[peaks[j].position]))
+	}
+
+	return p, nil
+}
+
+// hashLeaf hashes data into a leaf, H(0x00 || data).
+func (m *MMR) hashLeaf(data []byte) TreeNode {
+	h := m.newHash()
+	h.Write([]byte{leafNodePrefix})
+	h.Write(data)
+
+	return TreeNode(h.Sum(nil))
+}
+
+// hashChildren hashes two nodes into their parent, H(0x01 || left || right).
+func (m *MMR) hashChildren(left, right TreeNode) TreeNode {
+	h := m.newHash()
+	h.Write([]byte{internalNodePrefix})
+	h.Write(left)
+	h.Write(right)
+
+	return TreeNode(h.Sum(nil))
+}
+
+// VerifyMMRProof checks that proof, as returned by MMR.Proof, shows leaf to be
+// the leaf at proof.Position of the Merkle Mountain Range of proof.Size leaves
+// and the given root, hashed as opts set. The sides of the siblings and the
+// number of peaks only depend on the position and the size, and proofs of
+// another shape are refused.
+func VerifyMMRProof(root, leaf []byte, proof *MMRProof, opts ...Option) error {
+	if leaf == nil {
+		return ErrNilBlock
+	}
+	if proof == nil || proof.Size == 0 || proof.Size > 1<<62 {
+		return fmt.Errorf("invalid MMR proof: no leaves")
+	}
+
+	peaks := mmrPeaks(proof.Size)
+	last := peaks[len(peaks)-1]
+	if proof.Position > last.position || mmrHeight(proof.Position) != 0 {
+		return fmt.Errorf("invalid MMR proof: no leaf at position %d of %d leaves", proof.Position, proof.Size)
+	}
+
+	i := mmrPeakOf(peaks, proof.Position)
+	switch {
+	case len(proof.Siblings) != peaks[i].height:
+		return fmt.Errorf("invalid MMR proof for position %d: got %d siblings, want %d", proof.Position, len(proof.Siblings), peaks[i].height)
+	case len(proof.LeftPeaks) != i:
+		return fmt.Errorf("invalid MMR proof for position %d: got %d peaks on the left, want %d", proof.Position, len(proof.LeftPeaks), i)
+	case (proof.RightBag == nil) != (i == len(peaks)-1):
+		return fmt.Errorf("invalid MMR proof for position %d: bag of the peaks on the right does not match their count", proof.Position)
+	}
+
+	m := NewMMR(opts...)
+	node := m.hashLeaf(leaf)
+	pos := proof.Position
+	for h, sibling := range proof.Siblings {
+		s, parent := mmrFamily(pos, h)
+		if s < pos {
+			node = m.hashChildren(sibling, node)
+		} else {
+			node = m.hashChildren(node, sibling)
+		}
+		pos = parent
+	}
+
+	if proof.RightBag != nil {
+		node = m.hashChildren(node, proof.RightBag)
+	}
+	for _, peak := range proof.LeftPeaks {
+		node = m.hashChildren(peak, node)
+	}
+
+	if !RootEqual(node, root) {
+		return fmt.Errorf("invalid MMR proof for position %d; got: %X, want: %X", proof.Position, node.Bytes(), root)
+	}
+
+	return nil
+}
+
+// MMRConsistencyProof is the proof that the Merkle Mountain Range of NewSize
+// leaves extends the one of OldSize leaves: the peaks of the older range,
+// from the left, the siblings of every one of them up to the peak of the
+// newer range whose mountain holds it, from the peak up, and the peaks of the
+// newer range to the right of those mountains.
+type MMRConsistencyProof struct {
+	OldSize    uint64
+	NewSize    uint64
+	OldPeaks   []TreeNode
+	Paths      [][]TreeNode
+	RightPeaks []TreeNode
+}
+
+// ConsistencyProof returns the proof that the range of the first newSize
+// leaves extends the one of the first oldSize, which VerifyMMRConsistency
+// checks. It only holds the sizes when oldSize is 0 or newSize.
+func (m *MMR) ConsistencyProof(oldSize, newSize uint64) (*MMRConsistencyProof, error) {
+	if newSize > m.leaves {
+		return nil, fmt.Errorf("invalid tree size %d of %d leaves", newSize, m.leaves)
+	}
+	if oldSize > newSize {
+		return nil, fmt.Errorf("invalid old tree size %d for a tree of %d leaves", oldSize, newSize)
+	}
+
+	p := &MMRConsistencyProof{OldSize: oldSize, NewSize: newSize}
+	if oldSize == 0 || oldSize == newSize {
+		return p, nil
+	}
+
+	oldPeaks, newPeaks := mmrPeaks(oldSize), mmrPeaks(newSize)
+	last := 0
+	for _, peak := range oldPeaks {
+		p.OldPeaks = append(p.OldPeaks, copyNode(m.nodes[peak.position]))
+
+		last = mmrPeakOf(newPeaks, peak.position)
+		var path []TreeNode
+		for pos, h := peak.position, peak.height; pos != newPeaks[last].position; h++ {
+			sibling, parent := mmrFamily(pos, h)
+			path = append(path, copyNode(m.nodes[sibling]))
+			pos = parent
+		}
+		p.Paths = append(p.Paths, path)
+	}
+	for _, peak := range newPeaks[last+1:] {
+		p.RightPeaks = append(p.RightPeaks, copyNode(m.nodes[peak.position]))
+	}
+
+	return p, nil
+}
+
+// VerifyMMRConsistency checks that proof, as returned by
+// MMR.ConsistencyProof, shows the Merkle Mountain Range of newRoot to extend
+// the one of oldRoot, hashed as opts set: the peaks of the proof bag into
+// oldRoot, and climb, with the peaks on the right, into newRoot. Every peak
+// climbing to the same peak of the newer range must reach the same node. An
+// empty range is consistent with any range, and a range with itself.
+func VerifyMMRConsistency(oldRoot, newRoot []byte, proof *MMRConsistencyProof, opts ...Option) error {
+	if proof == nil || proof.OldSize > proof.NewSize || proof.NewSize > 1<<62 {
+		return fmt.Errorf("invalid MMR consistency proof: invalid sizes")
+	}
+
+	m := NewMMR(opts...)
+	if proof.OldSize == 0 || proof.OldSize == proof.NewSize {
+		if len(proof.OldPeaks) != 0 || len(proof.Paths) != 0 || len(proof.RightPeaks) != 0 {
+			return fmt.Errorf("invalid MMR consistency proof: nodes given between sizes %d and %d", proof.OldSize, proof.NewSize)
+		}
+		if proof.OldSize == 0 && !RootEqual(oldRoot, m.bag(0)) {
+			return fmt.Errorf("invalid MMR consistency proof: %X is not the root of an empty range", oldRoot)
+		}
+		if proof.OldSize != 0 && !RootEqual(oldRoot, newRoot) {
+			return fmt.Errorf("invalid MMR consistency proof: roots %X and %X of the same size differ", oldRoot, newRoot)
+		}
+		return nil
+	}
+
+	oldPeaks, newPeaks := mmrPeaks(proof.OldSize), mmrPeaks(proof.NewSize)
+	if len(proof.OldPeaks) != len(oldPeaks) || len(proof.Paths) != len(oldPeaks) {
+		return fmt.Errorf("invalid MMR consistency proof: got %d peaks and %d paths, want %d", len(proof.OldPeaks), len(proof.Paths), len(oldPeaks))
+	}
+	if bag := m.bagPeaks(proof.OldPeaks); !RootEqual(bag, oldRoot) {
+		return fmt.Errorf("invalid MMR consistency proof for size %d; got: %X, want: %X", proof.OldSize, bag.Bytes(), oldRoot)
+	}
+
+	peaks := make([]TreeNode, len(newPeaks))
+	last := 0
+	for j, peak := range oldPeaks {
+		last = mmrPeakOf(newPeaks, peak.position)
+		if want := newPeaks[last].height - peak.height; len(proof.Paths[j]) != want {
+			return fmt.Errorf("invalid MMR consistency proof: got %d siblings for peak %d, want %d", len(proof.Paths[j]), j, want)
+		}
+
+		node, pos := proof.OldPeaks[j], peak.position
+		for i, sibling := range proof.Paths[j] {
+			s, parent := mmrFamily(pos, peak.height+i)
+			if s < pos {
+				node = m.hashChildren(sibling, node)
+			} else {
+				node = m.hashChildren(node, sibling)
+			}
+			pos = parent
+		}
+
+		if peaks[last] != nil && !RootEqual(peaks[last], node) {
+			return fmt.Errorf("invalid MMR consistency proof: peaks climb to different nodes at position %d", pos)
+		}
+		peaks[last] = node
+	}
+
+	if len(proof.RightPeaks) != len(newPeaks)-last-1 {
+		return fmt.Errorf("invalid MMR consistency proof: got %d peaks on the right, want %d", len(proof.RightPeaks), len(newPeaks)-last-1)
+	}
+	copy(peaks[last+1:], proof.RightPeaks)
+
+	if bag := m.bagPeaks(peaks); !RootEqual(bag, newRoot) {
+		return fmt.Errorf("invalid MMR consistency proof for size %d; got: %X, want: %X", proof.NewSize, bag.Bytes(), newRoot)
+	}
+
+	return nil
+}
+
+// mmrPeak is the peak of a mountain of 2^height leaves.
+type mmrPeak struct {
+	position uint64
+	height   int
+}
+
+// mmrPeaks returns the peaks of a range of leaves leaves, from the left.
+func mmrPeaks(leaves uint64) []mmrPeak {
+	var peaks []mmrPeak
+	var offset uint64
+	for h := bits.Len64(leaves) - 1; h >= 0; h-- {
+		if leaves>>uint(h)&1 == 0 {
+			continue
+		}
+		size := uint64(1)<<uint(h+1) - 1
+		peaks = append(peaks, mmrPeak{position: offset + size - 1, height: h})
+		offset += size
+	}
+
+	return peaks
+}
+
+// mmrPeakOf returns the index of the peak of the mountain holding the node at
+// position, which must be in the range.
+func mmrPeakOf(peaks []mmrPeak, position uint64) int {
+	i := 0
+	for peaks[i].position < position {
+		i++
+	}
+
+	return i
+}
+
+// mmrHeight returns the height of the node at position, 0 for a leaf.
+func mmrHeight(position uint64) int {
+	// Positions from 1 of the peaks of the leftmost mountains are all ones;
+	// every other node is found by removing them.
+	p := position + 1
+	for p&(p+1) != 0 {
+		p -= uint64(1)<<uint(bits.Len64(p)-1) - 1
+	}
+
+	return bits.Len64(p) - 1
+}
+
+// mmrFamily returns the position of the sibling and of the parent of the node
+// at position of height h below its peak.
+func mmrFamily(position uint64, h int) (sibling, parent uint64) {
+	offset := uint64(1)<<uint(h+1) - 1
+	if mmrHeight(position+1) > h {
+		// The node is a right child, followed by its parent.
+		return position - offset, position + 1
+	}
+
+	return position + offset, position + offset + 1
+}
@@ -0,0 +1,142 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMMRLayout(t *testing.T) {
+	m := NewMMR()
+	var positions []uint64
+	for i := 0; i < 11; i++ {
+		positions = append(positions, m.Append([]byte(fmt.Sprintf("leaf %d", i))))
+	}
+	require.Equal(t, []uint64{0, 1, 3, 4, 7, 8, 10, 11, 15, 16, 18}, positions)
+	require.Equal(t, uint64(11), m.Size())
+	require.Equal(t, uint64(19), m.NodeCount())
+
+	heights := []int{0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 0, 0, 1, 0}
+	for pos, h := range heights {
+		require.Equal(t, h, mmrHeight(uint64(pos)), "position %d", pos)
+	}
+	require.Equal(t, []mmrPeak{{14, 3}, {17, 1}, {18, 0}}, mmrPeaks(11))
+}
+
+func TestMMRRoot(t *testing.T) {
+	m := NewMMR()
+	require.Equal(t, sum256(), m.Root())
+
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	for _, l := range leaves {
+		m.Append(l)
+	}
+
+	l0 := sum256([]byte{leafNodePrefix}, leaves[0])
+	l1 := sum256([]byte{leafNodePrefix}, leaves[1])
+	l2 := sum256([]byte{leafNodePrefix}, leaves[2])
+	peak := sum256([]byte{internalNodePrefix}, l0, l1)
+	require.Equal(t, sum256([]byte{internalNodePrefix}, peak, l2), m.Root())
+
+	m.Append([]byte("d"))
+	l3 := sum256([]byte{leafNodePrefix}, []byte("d"))
+	require.Equal(t, sum256([]byte{internalNodePrefix}, peak, sum256([]byte{internalNodePrefix}, l2, l3)), m.Root())
+
+	root, err := m.RootHash()
+	require.NoError(t, err)
+	require.Equal(t, m.Root(), root)
+}
+
+func TestMMRProofs(t *testing.T) {
+	m := NewMMR()
+	var positions []uint64
+	var leaves [][]byte
+	for i := 0; i < 3000; i++ {
+		leaf := []byte(fmt.Sprintf("leaf %d", i))
+		positions = append(positions, m.Append(leaf))
+		leaves = append(leaves, leaf)
+		if i%97 != 0 && i != 2999 {
+			continue
+		}
+
+		root := m.Root()
+		for _, j := range []int{0, i / 2, i - 1, i} {
+			if j < 0 {
+				continue
+			}
+			proof, err := m.Proof(positions[j])
+			require.NoError(t, err)
+			require.NoError(t, VerifyMMRProof(root, leaves[j], proof), "leaf %d of %d", j, i+1)
+		}
+	}
+
+	// A proof is for the root of its size only.
+	proof, err := m.Proof(positions[5])
+	require.NoError(t, err)
+	m.Append([]byte("leaf 3000"))
+	require.Error(t, VerifyMMRProof(m.Root(), leaves[5], proof))
+}
+
+func TestMMRProofTampering(t *testing.T) {
+	m := NewMMR()
+	var positions []uint64
+	for i := 0; i < 13; i++ {
+		positions = append(positions, m.Append([]byte(fmt.Sprintf("leaf %d", i))))
+	}
+	root := m.Root()
+	leaf := []byte("leaf 9")
+
+	proof, err := m.Proof(positions[9])
+	require.NoError(t, err)
+	require.NoError(t, VerifyMMRProof(root, leaf, proof))
+	require.Len(t, proof.Siblings, 2)
+	require.NotNil(t, proof.RightBag)
+	require.Len(t, proof.LeftPeaks, 1)
+
+	require.Error(t, VerifyMMRProof(root, []byte("leaf 8"), proof))
+
+	moved := *proof
+	moved.Position = positions[8]
+	require.Error(t, VerifyMMRProof(root, leaf, &moved))
+
+	moved.Position = 2
+	require.Error(t, VerifyMMRProof(root, leaf, &moved))
+
+	resized := *proof
+	resized.Size = 16
+	require.Error(t, VerifyMMRProof(root, leaf, &resized))
+
+	noBag := *proof
+	noBag.RightBag = nil
+	require.Error(t, VerifyMMRProof(root, leaf, &noBag))
+
+	noPeaks := *proof
+	noPeaks.LeftPeaks = nil
+	require.Error(t, VerifyMMRProof(root, leaf, &noPeaks))
+
+	short := *proof
+	short.Siblings = proof.Siblings[1:]
+	require.Error(t, VerifyMMRProof(root, leaf, &short))
+
+	require.Error(t, VerifyMMRProof(root, leaf, nil))
+
+	_, err = m.Proof(2)
+	require.Error(t, err)
+	_, err = m.Proof(m.NodeCount())
+	require.Error(t, err)
+}
+
+func TestMMRHashStrategy(t *testing.T) {
+	m := NewMMR(WithHashStrategy(sha512.New))
+	for i := 0; i < 6; i++ {
+		m.Append([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	require.Len(t, m.Root(), 64)
+
+	proof, err := m.Proof(3)
+	require.NoError(t, err)
+	require.NoError(t, VerifyMMRProof(m.Root(), []byte("leaf 2"), proof, WithHashStrategy(sha512.New)))
+	require.Error(t, VerifyMMRProof(m.Root(), []byte("leaf 2"), proof))
+}
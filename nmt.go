@@ -0,0 +1,331 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrNamespaceOrder is returned by NMT.Push for a namespace lower than the one
+// of the last leaf.
+var ErrNamespaceOrder = errors.New("namespaces must be pushed in non-decreasing order")
+
+// NMT is a namespaced merkle tree, as Celestia commits to its data with: every
+// leaf carries a namespace ID, and every node the lowest and highest
+// namespace of its subtree, so a proof can show that a set of leaves is all
+// the tree holds for a namespace, or that it holds none. Leaves are pushed in
+// non-decreasing namespace order.
+//
+// A node is minNs || maxNs || digest. A leaf of namespace ns holding data is
+// ns || ns || SHA-256(0x00 || ns || data), and the parent of left and right is
+// min || max || SHA-256(0x01 || left || right), min and max spanning the
+// namespaces of both. The tree is shaped as in RFC 6962, as a LogTree is, and
+// stored the same way, level by level; the maximum namespace is not treated
+// apart. The root of an empty tree has zero namespaces and the SHA-256 of the
+// empty string as digest.
+type NMT struct {
+	namespaceSize int
+	// leaves holds ns || data for every leaf.
+	leaves [][]byte
+	// levels[l][i] is the root of the leaves i*2^l to (i+1)*2^l.
+	levels [][]TreeNode
+}
+
+var _ Rooter = (*NMT)(nil)
+
+// NewNMT returns an empty NMT of namespace IDs of namespaceSize bytes,
+// between 1 and 255: Celestia uses 29.
+func NewNMT(namespaceSize int) (*NMT, error) {
+	if namespaceSize <= 0 || namespaceSize > 0xff {
+		return nil, fmt.Errorf("invalid namespace size %d", namespaceSize)
+	}
+
+	return &NMT{namespaceSize: namespaceSize}, nil
+}
+
+// Size returns the number of leaves of the tree.
+func (t *NMT) Size() int {
+	return len(t.leaves)
+}
+
+// Push appends data as a leaf of namespace namespaceID, which must not be
+// lower than the namespace of the last leaf.
+func (t *NMT) Push(namespaceID, data []byte) error {
+	if len(namespaceID) != t.namespaceSize {
+		return fmt.Errorf("namespace ID of %d bytes in a tree of %d byte namespaces", len(namespaceID), t.namespaceSize)
+	}
+	if n := len(t.leaves); n > 0 && bytes.Compare(namespaceID, t.leaves[n-1][:t.namespaceSize]) < 0 {
+		return fmt.Errorf("namespace %X after %X: %w", namespaceID, t.leaves[n-1][:t.namespaceSize], ErrNamespaceOrder)
+	}
+
+	leaf := make([]byte, 0, len(namespaceID)+len(data))
+	leaf = append(append(leaf, namespaceID...), data...)
+	t.leaves = append(t.leaves, leaf)
+
+	node := hashNMTLeaf(namespaceID, data)
+	for l := 0; ; l++ {
+		if l == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[l] = append(t.levels[l], node)
+		if len(t.levels[l])%2 != 0 {
+			return nil
+		}
+
+		level := t.levels[l]
+		node = hashNMTNode(level[len(level)-2], level[len(level)-1], t.namespaceSize)
+	}
+}
+
+// Root returns the lowest and highest namespaces of the tree and the digest
+// of its root.
+func (t *NMT) Root() (minNs, maxNs, digest []byte) {
+	root, _ := t.RootHash()
+	ns := t.namespaceSize
+
+	return root[:ns], root[ns : 2*ns], root[2*ns:]
+}
+
+// RootHash returns the root node of the tree, minNs || maxNs || digest as
+// Root returns them, against which namespace proofs are checked.
+func (t *NMT) RootHash() ([]byte, error) {
+	if len(t.leaves) == 0 {
+		return emptyNMTRoot(t.namespaceSize), nil
+	}
+
+	return copyNode(t.subtreeRoot(0, len(t.leaves))), nil
+}
+
+// subtreeRoot returns the root of the leaves start to end, as
+// LogTree.subtreeRoot does.
+func (t *NMT) subtreeRoot(start, end int) TreeNode {
+	n := end - start
+	if n&(n-1) == 0 {
+		l := 0
+		for 1<<l < n {
+			l++
+		}
+		return t.levels[l][start>>l]
+	}
+
+	k := splitPoint(n)
+	return hashNMTNode(t.subtreeRoot(start, start+k), t.subtreeRoot(start+k, end), t.namespaceSize)
+}
+
+// NamespaceProof proves the leaves of a namespace of an NMT, or that it has
+// none. The leaves proven are Start to End, none when they are equal.
+//
+// A proof of absence for a namespace within the range of the root holds the
+// leaf hash of the first leaf of a higher namespace, at Start, which End
+// follows. A proof for a namespace outside that range, or for an empty tree,
+// has no leaf and no nodes, the root alone showing the absence.
+type NamespaceProof struct {
+	Start, End int
+	// Size is the number of leaves of the tree.
+	Size int
+	// Nodes are the roots of the subtrees left and right of the leaves, in
+	// order.
+	Nodes [][]byte
+	// LeafHash is the leaf hash of a proof of absence within the range of
+	// the root.
+	LeafHash []byte `json:",omitempty"`
+}
+
+// ProveNamespace returns the proof of the leaves of namespace id, or of its
+// absence.
+func (t *NMT) ProveNamespace(id []byte) (*NamespaceProof, error) {
+	if len(id) != t.namespaceSize {
+		return nil, fmt.Errorf("namespace ID of %d bytes in a tree of %d byte namespaces", len(id), t.namespaceSize)
+	}
+
+	n := len(t.leaves)
+	p := &NamespaceProof{Size: n}
+	minNs, maxNs, _ := t.Root()
+	if n == 0 || bytes.Compare(id, minNs) < 0 || bytes.Compare(id, maxNs) > 0 {
+		return p, nil
+	}
+
+	start := 0
+	for start < n && bytes.Compare(t.leaves[start][:t.namespaceSize], id) < 0 {
+		start++
+	}
+	end := start
+	for end < n && bytes.Equal(t.leaves[end][:t.namespaceSize], id) {
+		end++
+	}
+	p.Start, p.End = start, end
+	if start == end {
+		// id is below the highest namespace, so start holds the first leaf
+		// of a higher one, which the nodes are taken around.
+		p.LeafHash = copyNode(t.levels[0][start])
+		end++
+	}
+	for _, node := range t.rangeNodes(nil, start, end, 0, n) {
+		p.Nodes = append(p.Nodes, copyNode(node))
+	}
+
+	return p, nil
+}
+
+// rangeNodes appends to nodes the roots of the subtrees of the leaves lo to
+// hi that lie outside start to end, left to right.
+func (t *NMT) rangeNodes(nodes []TreeNode, start, end, lo, hi int) []TreeNode {
+	if hi <= start || lo >= end {
+		return append(nodes, t.subtreeRoot(lo, hi))
+	}
+	if hi-lo == 1 {
+		return nodes
+	}
+
+	k := splitPoint(hi - lo)
+	nodes = t.rangeNodes(nodes, start, end, lo, lo+k)
+
+	return t.rangeNodes(nodes, start, end, lo+k, hi)
+}
+
+// Verify checks that p shows data to be all the leaves of namespace id in
+// the NMT of root, or, with no data, that it has no leaf of that namespace.
+// Errors wrap ErrInvalidProof.
+func (p *NamespaceProof) Verify(root, id []byte, data [][]byte) error {
+	ns := len(id)
+	if ns == 0 || len(root) != 2*ns+sha256.Size {
+		return fmt.Errorf("invalid %d byte root for %d byte namespaces: %w", len(root), ns, ErrInvalidProof)
+	}
+	if p.Size < 0 || p.Start < 0 || p.End < p.Start || p.End > p.Size || p.End-p.Start != len(data) {
+		return fmt.Errorf("invalid range [%d, %d) of %d leaves for %d leaves of data: %w", p.Start, p.End, p.Size, len(data), ErrInvalidProof)
+	}
+
+	if len(data) > 0 && p.LeafHash != nil {
+		return fmt.Errorf("proof of the leaves of namespace %X with an absence leaf: %w", id, ErrInvalidProof)
+	}
+
+	leaves := make([]TreeNode, len(data))
+	for i, d := range data {
+		leaves[i] = hashNMTLeaf(id, d)
+	}
+	start, end := p.Start, p.End
+	if len(data) == 0 {
+		if p.LeafHash == nil {
+			return p.verifyOutOfRange(root, id)
+		}
+		if len(p.LeafHash) != len(root) || bytes.Compare(p.LeafHash[:ns], id) <= 0 {
+			return fmt.Errorf("invalid absence proof leaf for namespace %X: %w", id, ErrInvalidProof)
+		}
+		if start >= p.Size {
+			return fmt.Errorf("absence proof leaf %d out of %d leaves: %w", start, p.Size, ErrInvalidProof)
+		}
+		leaves, end = []TreeNode{p.LeafHash}, start+1
+	}
+
+	next := 0
+	var computeRoot func(lo, hi int) (TreeNode, error)
+	computeRoot = func(lo, hi int) (TreeNode, error) {
+		if hi <= start || lo >= end {
+			if next == len(p.Nodes) {
+				return nil, fmt.Errorf("missing proof nodes: %w", ErrInvalidProof)
+			}
+			node := p.Nodes[next]
+			next++
+			if len(node) != len(root) {
+				return nil, fmt.Errorf("invalid %d byte proof node: %w", len(node), ErrInvalidProof)
+			}
+			// Completeness: the subtrees left of the leaves hold lower
+			// namespaces only, and the ones right of them higher ones.
+			if hi <= start && bytes.Compare(node[ns:2*ns], id) >= 0 || lo >= end && bytes.Compare(node[:ns], id) <= 0 {
+				return nil, fmt.Errorf("proof node of leaves %d to %d may hold namespace %X: %w", lo, hi, id, ErrInvalidProof)
+			}
+			return node, nil
+		}
+		if hi-lo == 1 {
+			return leaves[lo-start], nil
+		}
+
+		k := splitPoint(hi - lo)
+		left, err := computeRoot(lo, lo+k)
+		if err != nil {
+			return nil, err
+		}
+		right, err := computeRoot(lo+k, hi)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Compare(left[ns:2*ns], right[:ns]) > 0 {
+			return nil, fmt.Errorf("proof nodes out of namespace order: %w", ErrInvalidProof)
+		}
+
+		return hashNMTNode(left, right, ns), nil
+	}
+
+	computed, err := computeRoot(0, p.Size)
+	if err != nil {
+		return err
+	}
+	if next != len(p.Nodes) {
+		return fmt.Errorf("%d unused proof nodes: %w", len(p.Nodes)-next, ErrInvalidProof)
+	}
+	if !RootEqual(computed, root) {
+		return fmt.Errorf("invalid namespace proof; got: %X, want: %X: %w", computed.Bytes(), root, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// verifyOutOfRange checks a proof of absence without leaf: id must lie outside
+// the namespaces of root, or the tree be empty.
+func (p *NamespaceProof) verifyOutOfRange(root, id []byte) error {
+	ns := len(id)
+	if len(p.Nodes) != 0 {
+		return fmt.Errorf("absence proof without leaf has %d nodes: %w", len(p.Nodes), ErrInvalidProof)
+	}
+	if p.Size == 0 {
+		if !RootEqual(root, emptyNMTRoot(ns)) {
+			return fmt.Errorf("absence proof of an empty tree against a non empty root: %w", ErrInvalidProof)
+		}
+		return nil
+	}
+	if bytes.Compare(id, root[:ns]) >= 0 && bytes.Compare(id, root[ns:2*ns]) <= 0 {
+		return fmt.Errorf("namespace %X is within the range of the root: %w", id, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// hashNMTLeaf returns the leaf node of data in namespace id.
+func hashNMTLeaf(id, data []byte) TreeNode {
+	h := sha256.New()
+	h.Write([]byte{leafNodePrefix})
+	h.Write(id)
+	h.Write(data)
+
+	node := make(TreeNode, 0, 2*len(id)+sha256.Size)
+	node = append(append(node, id...), id...)
+
+	return h.Sum(node)
+}
+
+// hashNMTNode returns the parent of left and right, nodes of ns byte
+// namespaces.
+func hashNMTNode(left, right TreeNode, ns int) TreeNode {
+	maxNs := left[ns : 2*ns]
+	if bytes.Compare(right[ns:2*ns], maxNs) > 0 {
+		maxNs = right[ns : 2*ns]
+	}
+
+	h := sha256.New()
+	h.Write([]byte{internalNodePrefix})
+	h.Write(left)
+	h.Write(right)
+
+	node := make(TreeNode, 0, 2*ns+sha256.Size)
+	node = append(append(node, left[:ns]...), maxNs...)
+
+	return h.Sum(node)
+}
+
+// emptyNMTRoot returns the root of an empty NMT of ns byte namespaces.
+func emptyNMTRoot(ns int) []byte {
+	sum := sha256.Sum256(nil)
+
+	return append(make([]byte, 2*ns), sum[:]...)
+}
@@ -0,0 +1,128 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func namespace(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 8)
+}
+
+// nmtFixture returns a tree of leaves of the given namespaces, and the data
+// of every namespace.
+func nmtFixture(t *testing.T, namespaces ...byte) (*NMT, map[byte][][]byte) {
+	tree, err := NewNMT(8)
+	require.NoError(t, err)
+
+	data := map[byte][][]byte{}
+	for i, ns := range namespaces {
+		d := []byte(fmt.Sprintf("leaf %d of namespace %d", i, ns))
+		require.NoError(t, tree.Push(namespace(ns), d))
+		data[ns] = append(data[ns], d)
+	}
+
+	return tree, data
+}
+
+func TestNMTRoot(t *testing.T) {
+	tree, _ := nmtFixture(t, 1, 2)
+	leaf := func(ns byte, d string) []byte {
+		sum := sha256.Sum256(append(append([]byte{leafNodePrefix}, namespace(ns)...), d...))
+		return append(append(namespace(ns), namespace(ns)...), sum[:]...)
+	}
+	left, right := leaf(1, "leaf 0 of namespace 1"), leaf(2, "leaf 1 of namespace 2")
+	sum := sha256.Sum256(append(append([]byte{internalNodePrefix}, left...), right...))
+
+	minNs, maxNs, digest := tree.Root()
+	require.Equal(t, namespace(1), minNs)
+	require.Equal(t, namespace(2), maxNs)
+	require.Equal(t, sum[:], digest)
+
+	tree, _ = nmtFixture(t, 3, 4, 4, 9, 9)
+	minNs, maxNs, _ = tree.Root()
+	require.Equal(t, namespace(3), minNs)
+	require.Equal(t, namespace(9), maxNs)
+
+	require.ErrorIs(t, tree.Push(namespace(8), []byte("late")), ErrNamespaceOrder)
+	require.NoError(t, tree.Push(namespace(9), []byte("same namespace")))
+	require.Error(t, tree.Push([]byte{9}, []byte("short namespace")))
+	require.Equal(t, 6, tree.Size())
+
+	_, err := NewNMT(0)
+	require.Error(t, err)
+}
+
+func TestNMTProveNamespace(t *testing.T) {
+	namespaces := []byte{1, 1, 3, 3, 3, 5, 7, 7, 7, 7, 9}
+	tree, data := nmtFixture(t, namespaces...)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+
+	for ns := byte(0); ns <= 10; ns++ {
+		p, err := tree.ProveNamespace(namespace(ns))
+		require.NoError(t, err)
+		require.NoError(t, p.Verify(root, namespace(ns), data[ns]), "namespace %d", ns)
+
+		if len(data[ns]) > 0 {
+			require.Equal(t, len(data[ns]), p.End-p.Start)
+			// Dropping a leaf or claiming none is caught.
+			require.ErrorIs(t, p.Verify(root, namespace(ns), data[ns][1:]), ErrInvalidProof)
+			require.ErrorIs(t, p.Verify(root, namespace(ns), nil), ErrInvalidProof)
+			short := *p
+			short.End--
+			require.ErrorIs(t, short.Verify(root, namespace(ns), data[ns][1:]), ErrInvalidProof)
+			require.ErrorIs(t, (&NamespaceProof{Size: p.Size}).Verify(root, namespace(ns), nil), ErrInvalidProof)
+			continue
+		}
+
+		require.Equal(t, p.Start, p.End)
+		if ns == 0 || ns == 10 {
+			// Out of the range of the root.
+			require.Nil(t, p.LeafHash)
+			require.Empty(t, p.Nodes)
+		} else {
+			require.NotNil(t, p.LeafHash)
+		}
+		require.ErrorIs(t, p.Verify(root, namespace(ns), [][]byte{[]byte("forged")}), ErrInvalidProof)
+	}
+
+	// The absence proof of a namespace does not show the absence of another.
+	p, err := tree.ProveNamespace(namespace(4))
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Verify(root, namespace(3), nil), ErrInvalidProof)
+	require.ErrorIs(t, p.Verify(root, namespace(5), nil), ErrInvalidProof)
+	require.ErrorIs(t, p.Verify(root, namespace(2), nil), ErrInvalidProof)
+
+	// Proofs of a namespace do not prove its leaves with another one.
+	p, err = tree.ProveNamespace(namespace(5))
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Verify(root, namespace(6), data[5]), ErrInvalidProof)
+
+	_, err = tree.ProveNamespace([]byte{5})
+	require.Error(t, err)
+}
+
+func TestNMTEmptyNamespaceProof(t *testing.T) {
+	tree, err := NewNMT(8)
+	require.NoError(t, err)
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+	sum := sha256.Sum256(nil)
+	require.Equal(t, append(make([]byte, 16), sum[:]...), root)
+
+	p, err := tree.ProveNamespace(namespace(4))
+	require.NoError(t, err)
+	require.Zero(t, p.Size)
+	require.NoError(t, p.Verify(root, namespace(4), nil))
+	require.ErrorIs(t, p.Verify(root, namespace(4), [][]byte{[]byte("x")}), ErrInvalidProof)
+
+	// Claiming a non empty tree is empty fails.
+	full, _ := nmtFixture(t, 4)
+	fullRoot, _ := full.RootHash()
+	require.ErrorIs(t, p.Verify(fullRoot, namespace(4), nil), ErrInvalidProof)
+}
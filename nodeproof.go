@@ -0,0 +1,147 @@
+package merklego
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Node returns a copy of the node at position nodeIndex of the tree. The
+// nodes are stored as a binary heap: the root is at 0 and the children of the
+// node at i are at 2i+1 and 2i+2, so the nodes at depth d from the root start
+// at 2^d-1.
+func (mt *FlatMerkleTree) Node(nodeIndex int) (TreeNode, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if nodeIndex < 0 || nodeIndex >= len(mt.nodes) {
+		return nil, fmt.Errorf("node index %d out of range [0, %d)", nodeIndex, len(mt.nodes))
+	}
+
+	return copyNode(mt.nodes[nodeIndex]), nil
+}
+
+// ProveNode returns the proof of the node at position nodeIndex, as laid out
+// by Node: the siblings of the node and of its ancestors, from the node up.
+// The proof of a leaf is the one Proof returns for its block, and the proof
+// of the root is empty.
+func (mt *FlatMerkleTree) ProveNode(nodeIndex int) ([]TreeNode, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if nodeIndex < 0 || nodeIndex >= len(mt.nodes) {
+		return nil, fmt.Errorf("node index %d out of range [0, %d)", nodeIndex, len(mt.nodes))
+	}
+
+	return mt.nodePath(nodeIndex), nil
+}
+
+// nodePath returns the copied siblings of the node at idx and its ancestors.
+func (mt *FlatMerkleTree) nodePath(idx int) []TreeNode {
+	proof := make([]TreeNode, 0, nodeDepth(idx))
+	for ; idx > 0; idx = (idx - 1) / 2 {
+		if idx%2 == 0 {
+			proof = append(proof, copyNode(mt.nodes[idx-1]))
+		} else {
+			proof = append(proof, copyNode(mt.nodes[idx+1]))
+		}
+	}
+
+	return proof
+}
+
+// VerifyNode checks that proof leads from node, at position nodeIndex, to
+// root in a FlatMerkleTree configured by opts. Only the position is needed:
+// it fixes the depth of the node and the side of every sibling.
+func VerifyNode(root []byte, node TreeNode, nodeIndex int, proof []TreeNode, opts ...Option) error {
+	if nodeIndex < 0 {
+		return fmt.Errorf("invalid node index %d", nodeIndex)
+	}
+	if len(proof) != nodeDepth(nodeIndex) {
+		return fmt.Errorf("invalid proof for node %d: got %d chunks, want %d", nodeIndex, len(proof), nodeDepth(nodeIndex))
+	}
+
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkBinary(); err != nil {
+		return err
+	}
+
+	computed := node
+	for i, pos := 0, nodeIndex; pos > 0; i, pos = i+1, (pos-1)/2 {
+		if pos%2 == 0 {
+			computed = mt.hashChildren(proof[i], computed)
+		} else {
+			computed = mt.hashChildren(computed, proof[i])
+		}
+	}
+
+	if !RootEqual(computed, root) {
+		return fmt.Errorf("invalid proof for node %d; got: %X, want: %X", nodeIndex, computed.Bytes(), root)
+	}
+
+	return nil
+}
+
+// ContainsNode looks for hash among the nodes of the tree, leaves included.
+// A leaf is found at level 0, index being the index of its block. An internal
+// node is found at a level of 1 or more counted up from the deepest leaves,
+// index being its offset among the nodes at its depth: its position, as
+// taken by Node and ProveNode, is 2^(D-level)-1+index, D being the depth of
+// the deepest leaves. The padding duplicate is reported as the last block.
+//
+// The tree is scanned unless it was finalized with WithNodeIndex.
+func (mt *FlatMerkleTree) ContainsNode(hash []byte) (level int, index int, found bool) {
+	if !mt.finalized {
+		return 0, 0, false
+	}
+
+	pos := -1
+	if mt.positions != nil {
+		if p, ok := mt.positions[string(hash)]; ok {
+			pos = p
+		}
+	} else {
+		for p, n := range mt.nodes {
+			if RootEqual(n, hash) {
+				pos = p
+				break
+			}
+		}
+	}
+	if pos < 0 {
+		return 0, 0, false
+	}
+
+	if first := len(mt.nodes) - len(mt.blocks); pos >= first {
+		return 0, pos - first, true
+	}
+
+	if k := mt.branching(); k != 2 {
+		depth, start := karyDepth(pos, k)
+		deepest, _ := karyDepth(len(mt.nodes)-1, k)
+		return deepest - depth, pos - start, true
+	}
+
+	depth := nodeDepth(pos)
+	return nodeDepth(len(mt.nodes)-1) - depth, pos - (1<<depth - 1), true
+}
+
+// indexNodes fills positions, keeping the lowest position of repeated hashes
+// as the scan of ContainsNode does.
+func (mt *FlatMerkleTree) indexNodes() {
+	mt.positions = make(map[string]int, len(mt.nodes))
+	for p := len(mt.nodes) - 1; p >= 0; p-- {
+		mt.positions[string(mt.nodes[p])] = p
+	}
+}
+
+// nodeDepth returns the depth of the node at position idx, the root being at
+// depth 0.
+func nodeDepth(idx int) int {
+	return bits.Len(uint(idx)+1) - 1
+}
@@ -0,0 +1,133 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProveNode(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		blocks := make([]Block, 8)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		_, err := tree.ProveNode(0)
+		require.ErrorIs(t, err, ErrTreeNotFinalized)
+		require.NoError(t, tree.Finalize(WithSortedPairs(sorted)))
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		levels := map[int]int{}
+		for idx := 0; idx < 15; idx++ {
+			node, err := tree.Node(idx)
+			require.NoError(t, err)
+			proof, err := tree.ProveNode(idx)
+			require.NoError(t, err)
+			levels[len(proof)]++
+
+			require.NoError(t, VerifyNode(root, node, idx, proof, WithSortedPairs(sorted)), "node:%d", idx)
+			if idx >= 7 {
+				leafProof, err := tree.Proof(blocks[idx-7])
+				require.NoError(t, err)
+				require.Equal(t, leafProof, proof)
+				require.Equal(t, node, hashNode(blocks[idx-7], false))
+			}
+
+			if idx > 0 && !sorted {
+				// A sibling position swaps every side of the first step.
+				sibling := idx + 1
+				if idx%2 == 0 {
+					sibling = idx - 1
+				}
+				require.Error(t, VerifyNode(root, node, sibling, proof), "node:%d", idx)
+				require.Error(t, VerifyNode(root, node, idx, proof[1:]), "node:%d", idx)
+			}
+			require.Error(t, VerifyNode(root, hashNode([]byte("forged"), true), idx, proof, WithSortedPairs(sorted)))
+		}
+		require.Equal(t, map[int]int{0: 1, 1: 2, 2: 4, 3: 8}, levels)
+
+		_, err = tree.ProveNode(15)
+		require.Error(t, err)
+		_, err = tree.Node(-1)
+		require.Error(t, err)
+		require.Error(t, VerifyNode(root, root, -1, nil))
+	}
+}
+
+func TestProveNodeUnbalanced(t *testing.T) {
+	blocks := make([]Block, 5)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+	}
+	tree := NewMerkleTree(blocks...)
+	require.NoError(t, tree.Finalize())
+	root, err := tree.RootHash()
+	require.NoError(t, err)
+
+	for idx := 0; idx < len(tree.nodes); idx++ {
+		node, err := tree.Node(idx)
+		require.NoError(t, err)
+		proof, err := tree.ProveNode(idx)
+		require.NoError(t, err)
+		require.NoError(t, VerifyNode(root, node, idx, proof), "node:%d", idx)
+	}
+	for i, b := range blocks {
+		proof, err := tree.Proof(b)
+		require.NoError(t, err)
+		require.NoError(t, tree.Verify(b, proof), "block:%d", i)
+	}
+}
+
+func TestContainsNode(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		for _, n := range []int{5, 8} {
+			blocks := make([]Block, n)
+			for i := range blocks {
+				blocks[i] = Block(fmt.Sprintf("block-%d", i))
+			}
+			tree := NewMerkleTree(blocks...)
+			_, _, found := tree.ContainsNode(hashNode(blocks[0], false))
+			require.False(t, found)
+			require.NoError(t, tree.Finalize(WithNodeIndex(indexed)))
+			require.Equal(t, indexed, tree.positions != nil)
+
+			for i, b := range blocks {
+				level, index, found := tree.ContainsNode(hashNode(b, false))
+				require.True(t, found, "block:%d", i)
+				require.Equal(t, 0, level)
+				require.Equal(t, i, index)
+			}
+
+			// Every internal node is found at the position its level and
+			// index describe.
+			first := len(tree.nodes) - len(tree.blocks)
+			deepest := nodeDepth(len(tree.nodes) - 1)
+			levels := map[int]int{}
+			for pos := 0; pos < first; pos++ {
+				node, err := tree.Node(pos)
+				require.NoError(t, err)
+				level, index, found := tree.ContainsNode(node)
+				require.True(t, found, "node:%d", pos)
+				require.GreaterOrEqual(t, level, 1)
+				require.Equal(t, pos, 1<<(deepest-level)-1+index, "node:%d", pos)
+				levels[level]++
+			}
+			root, _ := tree.RootHash()
+			level, index, found := tree.ContainsNode(root)
+			require.True(t, found)
+			require.Equal(t, deepest, level)
+			require.Equal(t, 0, index)
+			if n == 8 {
+				require.Equal(t, map[int]int{1: 4, 2: 2, 3: 1}, levels)
+			}
+
+			for _, miss := range [][]byte{hashNode([]byte("missing"), false), hashNode([]byte("missing"), true), nil, blocks[0]} {
+				_, _, found := tree.ContainsNode(miss)
+				require.False(t, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package merklego
+
+import (
+	"bytes"
+	"hash"
+)
+
+// PaddingStrategy selects how a level with an odd number of nodes is paired.
+// The strategy is applied uniformly to every level of a MerkleTree, leaves
+// included.
+type PaddingStrategy int
+
+const (
+	// PadDuplicateLast appends a duplicate of the last node to every odd level,
+	// so it is hashed with its copy. This is the default and reproduces the
+	// roots of earlier releases; the duplicated leaf shows up in Leaves.
+	PadDuplicateLast PaddingStrategy = iota
+	// PadSelfPair hashes the last node of an odd level with itself without
+	// adding a duplicate node. Roots are identical to PadDuplicateLast.
+	PadSelfPair
+	// PadPromote moves the last node of an odd level up unchanged, to be paired
+	// on a higher level. A single leaf is therefore the root of its tree.
+	PadPromote
+)
+
+// Option configures how a merkle tree hashes and lays out its nodes.
+type Option func(*config)
+
+// config holds the construction settings shared by the tree types.
+type config struct {
+	hashFunc             func() hash.Hash
+	domainSeparation     bool
+	sortedPairs          bool
+	padding              PaddingStrategy
+	singleLeafRoot       bool
+	workers              int
+	dropItems            bool
+	rejectDuplicates     bool
+	sortedLeaves         bool
+	terseErrors          bool
+	nodeIndex            bool
+	distinctPadding      bool
+	countCommitment      bool
+	lengthPrefixedLeaves bool
+	leafPrefix           []byte
+	nodePrefix           []byte
+	pairHash             func(left, right []byte) []byte
+	hmacKey              []byte
+	randomSalts          int
+	digestSize           int
+	branchingFactor      int
+	headHistory          int
+}
+
+// WithHashStrategy hashes the nodes of a tree with hashStrategy instead of
+// SHA-256. The leaf hashes of a MerkleTree are still those returned by the
+// items' CalculateHash, wrapped with hashStrategy when domain separation is
+// enabled; a FlatMerkleTree hashes its blocks with it too.
+func WithHashStrategy(hashStrategy func() hash.Hash) Option {
+	return func(c *config) {
+		c.hashFunc = hashStrategy
+	}
+}
+
+// WithDomainSeparation prefixes leaf hashes with 0x00 and internal node hashes
+// with 0x01, or the prefixes set with WithLeafPrefix and WithNodePrefix,
+// before hashing, so an internal node can never be presented as a leaf (second
+// pre-image attack). Roots differ from the legacy, unprefixed ones, so the
+// option is disabled by default.
+func WithDomainSeparation(enabled bool) Option {
+	return func(c *config) {
+		c.domainSeparation = enabled
+	}
+}
+
+// WithSortedPairs orders the two child hashes lexicographically before hashing
+// them into their parent, as OpenZeppelin-style verifiers do, so proofs do not
+// need to carry the position of each sibling. It applies to both MerkleTree and
+// FlatMerkleTree.
+func WithSortedPairs(enabled bool) Option {
+	return func(c *config) {
+		c.sortedPairs = enabled
+	}
+}
+
+// orderPair returns left and right in the order they must be hashed.
+func (c *config) orderPair(left, right []byte) ([]byte, []byte) {
+	if c.sortedPairs && bytes.Compare(left, right) > 0 {
+		return right, left
+	}
+
+	return left, right
+}
+
+// WithPaddingStrategy selects how odd levels of a MerkleTree are paired.
+func WithPaddingStrategy(strategy PaddingStrategy) Option {
+	return func(c *config) {
+		c.padding = strategy
+	}
+}
+
+// WithSingleLeafRoot makes the root of a tree holding a single element the
+// leaf hash itself, as Bitcoin and RFC 6962 define it, instead of the hash of
+// the leaf paired with its duplicate. The proof for that single leaf is empty.
+// It applies to both MerkleTree and FlatMerkleTree.
+func WithSingleLeafRoot(enabled bool) Option {
+	return func(c *config) {
+		c.singleLeafRoot = enabled
+	}
+}
+
+// WithWorkers hashes the leaves of a MerkleTree, and the nodes of each of its
+// levels, across n goroutines. The resulting tree is identical to the one built
+// sequentially, which is what happens when n is lower than 2. It also sets the
+// goroutines NewMerkleTreeFromReader hashes the blocks it reads across.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+// WithRetainItems controls whether the leaves of a MerkleTree keep a reference
+// to their Storable items once the tree is built. Passing false releases them
+// as with MerkleTree.ReleaseItems. It has no effect on a FlatMerkleTree.
+func WithRetainItems(retain bool) Option {
+	return func(c *config) {
+		c.dropItems = !retain
+	}
+}
+
+// WithRejectDuplicates makes building a tree fail with a *DuplicateError when
+// two of its items hash to the same leaf. The padding duplicate added to odd
+// levels is not considered. It applies to both MerkleTree, including
+// UpdateLeaf, and FlatMerkleTree.
+func WithRejectDuplicates(enabled bool) Option {
+	return func(c *config) {
+		c.rejectDuplicates = enabled
+	}
+}
+
+// WithSortedLeaves orders the blocks of a FlatMerkleTree by leaf hash when it
+// is finalized, so the root only depends on the multiset of blocks and not on
+// their insertion order. Indexes and proofs then refer to the sorted
+// positions. It has no effect on a MerkleTree.
+func WithSortedLeaves(enabled bool) Option {
+	return func(c *config) {
+		c.sortedLeaves = enabled
+	}
+}
+
+// WithTerseErrors leaves hashes and block contents out of the errors of a
+// FlatMerkleTree and of VerifyFlatProof, which then only give the index of the
+// leaf that failed, for deployments whose errors reach untrusted logs. Errors
+// carry hashes by default. VerifyTrace still records every hash. It has no
+// effect on a MerkleTree, whose errors carry no hashes.
+func WithTerseErrors(enabled bool) Option {
+	return func(c *config) {
+		c.terseErrors = enabled
+	}
+}
+
+// WithNodeIndex makes a FlatMerkleTree index the position of every node by
+// hash when it is finalized, so ContainsNode is a map lookup instead of a
+// scan of the tree, at the cost of a map entry per node. It has no effect on
+// a MerkleTree.
+func WithNodeIndex(enabled bool) Option {
+	return func(c *config) {
+		c.nodeIndex = enabled
+	}
+}
+
+// WithDistinctPadding hashes the leaf padding an odd number of blocks of a
+// FlatMerkleTree as SHA-256 of 0x02 followed by the leaf hash of the last
+// block, instead of repeating that leaf hash. Without it [A, B, C] and
+// [A, B, C, C] have the same root, as in CVE-2012-2459, so a root does not
+// commit to the number of blocks; with it they differ. Roots change for odd
+// numbers of blocks, so the option is disabled by default. It has no effect on
+// a MerkleTree.
+func WithDistinctPadding(enabled bool) Option {
+	return func(c *config) {
+		c.distinctPadding = enabled
+	}
+}
+
+// WithCountCommitment makes the root of a MerkleTree its CommittedRoot, which
+// commits to the number of leaves, so padding can no longer give trees of
+// different sizes the same root. RootHash, Head, SignRoot and VerifyProof use
+// it and proofs carry CountCommitment; MerkleRoot stays the root of the nodes.
+// It has no effect on a FlatMerkleTree, which has WithDistinctPadding.
+func WithCountCommitment(enabled bool) Option {
+	return func(c *config) {
+		c.countCommitment = enabled
+	}
+}
+
+// WithLengthPrefixedLeaves hashes every leaf as the LengthPrefixedLeaf
+// encoding of its data, the leaf prefix then the length of the data as a
+// uvarint then the data, instead of the bare data, so leaves are framed and
+// cannot be read as one another's concatenation. The data is the block of a
+// FlatMerkleTree and the CalculateHash of a MerkleTree item, framed whether or
+// not domain separation is enabled. It is the canonical leaf encoding for
+// other implementations to match. Roots change, so the option is disabled by
+// default.
+func WithLengthPrefixedLeaves(enabled bool) Option {
+	return func(c *config) {
+		c.lengthPrefixedLeaves = enabled
+	}
+}
+
+// WithLeafPrefix prefixes leaf hashes with prefix instead of 0x00, and
+// WithNodePrefix internal node hashes instead of 0x01. Prefixes may span
+// several bytes, such as "ProtocolName:leaf", and are set per tree, so trees
+// with different prefixes coexist. On a MerkleTree they enable domain
+// separation. An empty prefix restores the default.
+func WithLeafPrefix(prefix []byte) Option {
+	return func(c *config) {
+		c.leafPrefix = clonePrefix(prefix)
+		c.domainSeparation = c.domainSeparation || c.leafPrefix != nil
+	}
+}
+
+// WithNodePrefix prefixes internal node hashes with prefix instead of 0x01, as
+// described with WithLeafPrefix.
+func WithNodePrefix(prefix []byte) Option {
+	return func(c *config) {
+		c.nodePrefix = clonePrefix(prefix)
+		c.domainSeparation = c.domainSeparation || c.nodePrefix != nil
+	}
+}
+
+// clonePrefix returns a copy of prefix, or nil if it is empty.
+func clonePrefix(prefix []byte) []byte {
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	return append([]byte(nil), prefix...)
+}
+
+// leafPrefixBytes returns the prefix of leaf hashes, 0x00 unless set with
+// WithLeafPrefix.
+func (c *config) leafPrefixBytes() []byte {
+	if len(c.leafPrefix) > 0 {
+		return c.leafPrefix
+	}
+
+	return []byte{leafNodePrefix}
+}
+
+// nodePrefixBytes returns the prefix of internal node hashes, 0x01 unless set
+// with WithNodePrefix.
+func (c *config) nodePrefixBytes() []byte {
+	if len(c.nodePrefix) > 0 {
+		return c.nodePrefix
+	}
+
+	return []byte{internalNodePrefix}
+}
+
+// WithPairHash hashes two sibling nodes into their parent with pairHash
+// instead of the hash function of the tree over their concatenation, for
+// schemes such as Bitcoin's double SHA-256. pairHash is given the children in
+// tree order, after WithSortedPairs orders them, and the node prefix of domain
+// separation is left to it. Proofs name it as registered with
+// RegisterPairHash, or "?" when it is not, in which case they can only be
+// verified by their tree. Its hashes may be longer or shorter than the ones of
+// the hash function, except in a CompactTree, which refuses them. nil restores
+// the default.
+func WithPairHash(pairHash func(left, right []byte) []byte) Option {
+	return func(c *config) {
+		c.pairHash = pairHash
+	}
+}
+
+// WithOptions applies opts in order, bundling them into a single Option for
+// presets defined outside this package.
+func WithOptions(opts ...Option) Option {
+	return func(c *config) {
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// OrderProof proves that two blocks of a FlatMerkleTree were inserted in a
+// given order. It is a multiproof of both leaves: the siblings shared by
+// their paths appear once, and their positions, hence their indexes, are
+// committed to by the root.
+type OrderProof struct {
+	// Size is the number of blocks of the tree.
+	Size                 int
+	SingleLeafRoot       bool
+	DistinctPadding      bool
+	LengthPrefixedLeaves bool
+	// LeafPrefix and NodePrefix are those of the tree, nil for the defaults.
+	LeafPrefix, NodePrefix []byte
+	// PairHash names the pair hash of the tree, as Proof.PairHash does.
+	PairHash string
+	// Hash names the hash function of the tree as registered with
+	// RegisterHash, empty for SHA-256 and "?" for an unregistered one.
+	Hash string
+	// Keyed is set for trees finalized with WithHMACKey, whose key the
+	// proof does not carry.
+	Keyed bool
+
+	IndexA, IndexB int
+	// Siblings are the hashes of the nodes outside both paths needed to
+	// rebuild the root, in the order VerifyOrder consumes them.
+	Siblings [][]byte
+}
+
+// ProveOrder returns the OrderProof of a being inserted before b. Both blocks
+// must be in the tree, the first of their occurrences counting, and must
+// differ. The tree must not use sorted pairs, which would let a leaf be
+// proven at any position.
+func (mt *FlatMerkleTree) ProveOrder(a, b Block) (*OrderProof, error) {
+	if a == nil || b == nil {
+		return nil, ErrNilBlock
+	}
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if mt.sortedPairs {
+		return nil, fmt.Errorf("cannot prove order in a tree with sorted pairs")
+	}
+	if mt.salted() {
+		return nil, ErrSaltedTree
+	}
+	if bytes.Equal(a, b) {
+		return nil, fmt.Errorf("cannot prove the order of block %X with itself", a.Bytes())
+	}
+
+	posA, err := mt.findLeaf(a)
+	if err != nil {
+		return nil, err
+	}
+	posB, err := mt.findLeaf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	first := len(mt.nodes) - len(mt.blocks)
+	p := &OrderProof{
+		Size:                 mt.count,
+		SingleLeafRoot:       mt.singleLeafRoot,
+		DistinctPadding:      mt.distinctPadding,
+		LengthPrefixedLeaves: mt.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(mt.leafPrefix),
+		NodePrefix:           clonePrefix(mt.nodePrefix),
+		PairHash:             pairHashName(mt.pairHash),
+		Hash:                 mt.hashID(),
+		Keyed:                mt.keyed(),
+		IndexA:               posA - first,
+		IndexB:               posB - first,
+	}
+	if p.IndexA > p.IndexB {
+		return nil, fmt.Errorf("block %X was inserted after block %X", a.Bytes(), b.Bytes())
+	}
+
+	_, err = mt.coverRoot(mt.orderLeaves(p, mt.nodes[posA], mt.nodes[posB], len(mt.blocks)), func(pos int) (TreeNode, error) {
+		p.Siblings = append(p.Siblings, copyNode(mt.nodes[pos]))
+		return mt.nodes[pos], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// VerifyOrder checks that a and b are blocks of the FlatMerkleTree with the
+// given root, at p.IndexA and p.IndexB, and that a was inserted before b. It
+// returns an error wrapping ErrInvalidProof when they are not. The key of a
+// keyed tree is given in opts with WithHMACKey.
+func VerifyOrder(root []byte, a, b Block, p *OrderProof, opts ...Option) error {
+	if a == nil || b == nil {
+		return ErrNilBlock
+	}
+	if bytes.Equal(a, b) {
+		return fmt.Errorf("invalid order proof: block %X ordered with itself: %w", a.Bytes(), ErrInvalidProof)
+	}
+	if p.IndexA < 0 || p.IndexA >= p.IndexB || p.IndexB >= p.Size {
+		return fmt.Errorf("invalid order proof: indexes %d and %d of %d blocks: %w", p.IndexA, p.IndexB, p.Size, ErrInvalidProof)
+	}
+
+	n := p.Size
+	if n%2 != 0 && !(n == 1 && p.SingleLeafRoot) {
+		n++
+	}
+
+	mt := &FlatMerkleTree{}
+	mt.distinctPadding, mt.lengthPrefixedLeaves = p.DistinctPadding, p.LengthPrefixedLeaves
+	mt.leafPrefix, mt.nodePrefix = p.LeafPrefix, p.NodePrefix
+	pairHash, err := pairHashOption(p.PairHash)
+	if err != nil {
+		return fmt.Errorf("invalid order proof: %v: %w", err, ErrInvalidProof)
+	}
+	pairHash(&mt.config)
+	hash, err := hashOption(p.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid order proof: %v: %w", err, ErrInvalidProof)
+	}
+	hash(&mt.config)
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkBinary(); err != nil {
+		return err
+	}
+	if mt.keyed() != p.Keyed {
+		return fmt.Errorf("invalid order proof: %v: %w", ErrProofKeyed, ErrInvalidProof)
+	}
+	next := 0
+	computed, err := mt.coverRoot(mt.orderLeaves(p, mt.hashLeaf(a), mt.hashLeaf(b), n), func(int) (TreeNode, error) {
+		if next == len(p.Siblings) {
+			return nil, fmt.Errorf("invalid order proof: missing siblings: %w", ErrInvalidProof)
+		}
+		next++
+
+		return p.Siblings[next-1], nil
+	})
+	if err != nil {
+		return err
+	}
+	if next != len(p.Siblings) {
+		return fmt.Errorf("invalid order proof: %d unused siblings: %w", len(p.Siblings)-next, ErrInvalidProof)
+	}
+
+	if !RootEqual(computed, root) {
+		return fmt.Errorf("invalid order proof; got: %X, want: %X: %w", computed.Bytes(), root, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// orderLeaves places the leaf hashes of the blocks of p in a tree of n
+// leaves, padding included, along with the padding leaf of the last block
+// when the tree has one.
+func (mt *FlatMerkleTree) orderLeaves(p *OrderProof, leafA, leafB TreeNode, n int) map[int]TreeNode {
+	leaves := map[int]TreeNode{
+		n - 1 + p.IndexA: leafA,
+		n - 1 + p.IndexB: leafB,
+	}
+	if p.IndexB == p.Size-1 && n != p.Size {
+		leaves[n-1+p.Size] = mt.paddingLeaf(leafB)
+	}
+
+	return leaves
+}
@@ -0,0 +1,79 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProveOrder(t *testing.T) {
+	for n := 2; n <= 12; n++ {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize())
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				p, err := tree.ProveOrder(blocks[i], blocks[j])
+				require.NoError(t, err)
+				require.Equal(t, i, p.IndexA)
+				require.Equal(t, j, p.IndexB)
+				require.Len(t, p.Siblings, MultiProofSize(n, []int{i, j}), "size:%d order:%d<%d", n, i, j)
+				require.NoError(t, VerifyOrder(root, blocks[i], blocks[j], p), "size:%d order:%d<%d", n, i, j)
+
+				require.ErrorIs(t, VerifyOrder(root, blocks[j], blocks[i], p), ErrInvalidProof)
+				swapped := *p
+				swapped.IndexA, swapped.IndexB = p.IndexB, p.IndexA
+				require.ErrorIs(t, VerifyOrder(root, blocks[j], blocks[i], &swapped), ErrInvalidProof)
+
+				if j > i+1 {
+					shifted := *p
+					shifted.IndexB--
+					require.ErrorIs(t, VerifyOrder(root, blocks[i], blocks[j], &shifted), ErrInvalidProof)
+				}
+				if len(p.Siblings) > 0 {
+					short := *p
+					short.Siblings = p.Siblings[1:]
+					require.ErrorIs(t, VerifyOrder(root, blocks[i], blocks[j], &short), ErrInvalidProof)
+				}
+				long := *p
+				long.Siblings = append(append([][]byte{}, p.Siblings...), root)
+				require.ErrorIs(t, VerifyOrder(root, blocks[i], blocks[j], &long), ErrInvalidProof)
+
+				_, err = tree.ProveOrder(blocks[j], blocks[i])
+				require.Error(t, err)
+			}
+		}
+	}
+}
+
+func TestProveOrderErrors(t *testing.T) {
+	tree := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	_, err := tree.ProveOrder(Block("a"), Block("b"))
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+	require.NoError(t, tree.Finalize())
+
+	_, err = tree.ProveOrder(Block("a"), Block("missing"))
+	require.Error(t, err)
+	_, err = tree.ProveOrder(Block("a"), Block("a"))
+	require.Error(t, err)
+	_, err = tree.ProveOrder(nil, Block("a"))
+	require.ErrorIs(t, err, ErrNilBlock)
+
+	p, err := tree.ProveOrder(Block("a"), Block("c"))
+	require.NoError(t, err)
+	root, _ := tree.RootHash()
+	require.ErrorIs(t, VerifyOrder(root, Block("a"), Block("a"), p), ErrInvalidProof)
+	require.ErrorIs(t, VerifyOrder(root, Block("a"), nil, p), ErrNilBlock)
+
+	sorted := NewMerkleTree(Block("a"), Block("b"))
+	require.NoError(t, sorted.Finalize(WithSortedPairs(true)))
+	_, err = sorted.ProveOrder(Block("a"), Block("b"))
+	require.Error(t, err)
+}
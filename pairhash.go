@@ -0,0 +1,105 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// unknownPairHash names, in proofs, a pair hash that was never registered.
+// Such proofs only verify against their own tree.
+const unknownPairHash = "?"
+
+// pairHashProbe holds the children hashed by every candidate pair hash to
+// recognize it by its output.
+var pairHashProbe = func() (p [2][]byte) {
+	p[0], p[1] = make([]byte, 32), make([]byte, 32)
+	for i := range p[0] {
+		p[0][i], p[1][i] = byte(i), byte(32+i)
+	}
+
+	return p
+}()
+
+type namedPairHash struct {
+	name  string
+	fn    func(left, right []byte) []byte
+	probe []byte
+}
+
+var (
+	pairHashesMu sync.RWMutex
+	pairHashes   []namedPairHash
+)
+
+// RegisterPairHash makes pairHash known under name, so the proofs of trees
+// built with WithPairHash(pairHash) name it and can be verified on their own.
+// Registering a name again replaces its function. The empty name, which
+// stands for the default pair hash, and "?", which stands for an unregistered
+// one, are reserved: registering them does nothing.
+func RegisterPairHash(name string, pairHash func(left, right []byte) []byte) {
+	if name == "" || name == unknownPairHash {
+		return
+	}
+	nh := namedPairHash{name: name, fn: pairHash, probe: pairHash(pairHashProbe[0], pairHashProbe[1])}
+
+	pairHashesMu.Lock()
+	defer pairHashesMu.Unlock()
+
+	for i := range pairHashes {
+		if pairHashes[i].name == name {
+			pairHashes[i] = nh
+			return
+		}
+	}
+	pairHashes = append(pairHashes, nh)
+}
+
+// pairHashByName returns the pair hash registered under name.
+func pairHashByName(name string) (func(left, right []byte) []byte, bool) {
+	pairHashesMu.RLock()
+	defer pairHashesMu.RUnlock()
+
+	for _, nh := range pairHashes {
+		if nh.name == name {
+			return nh.fn, true
+		}
+	}
+
+	return nil, false
+}
+
+// pairHashName returns the name pairHash was registered under, recognizing it
+// by its output: "" for nil, the default pair hash, and "?" if it is unknown.
+func pairHashName(pairHash func(left, right []byte) []byte) string {
+	if pairHash == nil {
+		return ""
+	}
+	probe := pairHash(pairHashProbe[0], pairHashProbe[1])
+
+	pairHashesMu.RLock()
+	defer pairHashesMu.RUnlock()
+
+	for _, nh := range pairHashes {
+		if bytes.Equal(nh.probe, probe) {
+			return nh.name
+		}
+	}
+
+	return unknownPairHash
+}
+
+// pairHashOption returns the option setting the pair hash named name in a
+// proof, "" standing for the default one, or an error if it is unknown.
+func pairHashOption(name string) (Option, error) {
+	if name == "" {
+		return WithPairHash(nil), nil
+	}
+
+	pairHash, ok := pairHashByName(name)
+	if !ok {
+		return nil, fmt.Errorf("error: unknown pair hash %q", name)
+	}
+
+	return WithPairHash(pairHash), nil
+}
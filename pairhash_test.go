@@ -0,0 +1,185 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// doubleSHA256 is the pair hash of Bitcoin merkle trees.
+func doubleSHA256(left, right []byte) []byte {
+	first := sha256.New()
+	first.Write(left)
+	first.Write(right)
+	second := sha256.Sum256(first.Sum(nil))
+
+	return second[:]
+}
+
+// reversedHex decodes s, a hash displayed in Bitcoin's reversed byte order.
+func reversedHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return b
+}
+
+func TestPairHashBitcoinBlock(t *testing.T) {
+	// The transactions of block 100000 and its merkle root.
+	var txids [][]byte
+	for _, s := range []string{
+		"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+		"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+		"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+		"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+	} {
+		txids = append(txids, reversedHex(t, s))
+	}
+	want := reversedHex(t, "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766")
+
+	tree, err := NewTreeFromLeafHashes(txids, sha256.New, WithPairHash(doubleSHA256))
+	require.NoError(t, err)
+	require.Equal(t, want, tree.MerkleRoot())
+
+	// Odd levels pair their last node with itself, as in Bitcoin.
+	three, err := NewTreeFromLeafHashes(txids[:3], sha256.New, WithPairHash(doubleSHA256))
+	require.NoError(t, err)
+	left, right := doubleSHA256(txids[0], txids[1]), doubleSHA256(txids[2], txids[2])
+	require.Equal(t, doubleSHA256(left, right), three.MerkleRoot())
+
+	steps, err := tree.MerklePathAt(2)
+	require.NoError(t, err)
+	p := &Proof{Hash: "sha256", PairHash: "unregistered", Index: 2, Size: 4, LeafHash: txids[2], Steps: steps}
+	require.ErrorContains(t, p.Verify(want), `unknown proof pair hash "unregistered"`)
+
+	RegisterPairHash("bitcoin-sha256d", doubleSHA256)
+	p.PairHash = "bitcoin-sha256d"
+	require.NoError(t, p.Verify(want))
+	require.Equal(t, []error{nil}, VerifyBatch(want, []ProofItem{{Leaf: txids[2], Proof: p}}, 1))
+	require.NoError(t, VerifyHex("0x"+hex.EncodeToString(want), "0x"+hex.EncodeToString(txids[2]), p.HexSiblings(), 2, WithPairHash(doubleSHA256)))
+
+	p.PairHash = ""
+	require.ErrorIs(t, p.Verify(want), ErrInvalidProof)
+}
+
+func TestPairHashProofs(t *testing.T) {
+	pairHash := func(left, right []byte) []byte {
+		h := sha256.New()
+		h.Write(left)
+		h.Write([]byte("|"))
+		h.Write(right)
+		return h.Sum(nil)
+	}
+
+	tree, err := NewTreeWithOptions(paddingContents(5), WithPairHash(pairHash))
+	require.NoError(t, err)
+	plain, err := NewTree(paddingContents(5))
+	require.NoError(t, err)
+	require.NotEqual(t, plain.MerkleRoot(), tree.MerkleRoot())
+
+	unregistered, err := NewTreeWithOptions(paddingContents(5), WithPairHash(swappedPairHash))
+	require.NoError(t, err)
+	leaf := unregistered.Leaves[1].Hash
+	p, err := unregistered.Prove(leaf)
+	require.NoError(t, err)
+	require.Equal(t, "?", p.PairHash)
+	require.NoError(t, unregistered.VerifyProof(leaf, p))
+	require.Error(t, p.Verify(unregistered.MerkleRoot()))
+
+	leaf = tree.Leaves[1].Hash
+	RegisterPairHash("sha256-separator", pairHash)
+	RegisterPairHash("?", swappedPairHash)
+	_, ok := pairHashByName("?")
+	require.False(t, ok)
+
+	p, err = tree.Prove(leaf)
+	require.NoError(t, err)
+	require.Equal(t, "sha256-separator", p.PairHash)
+	require.NoError(t, p.Verify(tree.MerkleRoot()))
+
+	data, err := p.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, len(data), p.EncodedSize(ProofBinary))
+	var decoded Proof
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, p, &decoded)
+
+	blocks := []Block{Block("a"), Block("b"), Block("c")}
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(WithPairHash(pairHash)))
+	root, _ := mt.RootHash()
+	fp, err := mt.Prove(blocks[2])
+	require.NoError(t, err)
+	require.Equal(t, "sha256-separator", fp.PairHash)
+	require.NoError(t, fp.Verify(root))
+
+	rp, err := mt.ProveRange(0, 2)
+	require.NoError(t, err)
+	require.NoError(t, VerifyRange(root, 0, [][]byte{blocks[0], blocks[1]}, rp))
+	rp.PairHash = "unregistered"
+	require.ErrorIs(t, VerifyRange(root, 0, [][]byte{blocks[0], blocks[1]}, rp), ErrInvalidProof)
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	got, _ := restored.RootHash()
+	require.Equal(t, root, got)
+	s.PairHash = "unregistered"
+	_, err = RestoreFlatMerkleTree(s)
+	require.Error(t, err)
+}
+
+func TestPairHashLength(t *testing.T) {
+	long := func(left, right []byte) []byte {
+		h := sha512.New()
+		h.Write(left)
+		h.Write(right)
+		return h.Sum(nil)
+	}
+	short := func(left, right []byte) []byte {
+		return long(left, right)[:16]
+	}
+
+	for _, pairHash := range []func(left, right []byte) []byte{long, short} {
+		for _, workers := range []int{1, 4} {
+			tree, err := NewTreeWithOptions(paddingContents(6), WithPairHash(pairHash), WithWorkers(workers))
+			require.NoError(t, err)
+			root := tree.MerkleRoot()
+			require.Len(t, root, len(pairHash(nil, nil)))
+
+			for _, leaf := range tree.Leaves {
+				p, err := tree.Prove(leaf.Hash)
+				require.NoError(t, err)
+				node := p.LeafHash
+				for _, step := range p.Steps {
+					if step.Left {
+						node = pairHash(step.Hash, node)
+					} else {
+						node = pairHash(node, step.Hash)
+					}
+				}
+				require.Equal(t, root, node)
+				require.NoError(t, tree.VerifyProof(leaf.Hash, p))
+			}
+		}
+
+		_, err := NewCompactTree(paddingContents(6), WithPairHash(pairHash))
+		require.Error(t, err)
+	}
+}
+
+// swappedPairHash hashes the children in reverse order. It is never
+// registered but under the reserved "?", which is ignored.
+func swappedPairHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(right)
+	h.Write(left)
+	return h.Sum(nil)
+}
@@ -0,0 +1,332 @@
+package merklego
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// Rooter is implemented by trees that expose their root hash.
+type Rooter interface {
+	RootHash() ([]byte, error)
+}
+
+// Prover is implemented by trees that prove the membership of a leaf. The leaf
+// is the block of a FlatMerkleTree and the leaf hash of a MerkleTree.
+type Prover interface {
+	Prove(leaf []byte) (*Proof, error)
+}
+
+// Verifier is implemented by trees that check a Proof of leaf against their
+// root.
+type Verifier interface {
+	VerifyProof(leaf []byte, p *Proof) error
+}
+
+var (
+	_ Rooter   = (*MerkleTree)(nil)
+	_ Prover   = (*MerkleTree)(nil)
+	_ Verifier = (*MerkleTree)(nil)
+	_ Rooter   = (*FlatMerkleTree)(nil)
+	_ Prover   = (*FlatMerkleTree)(nil)
+	_ Verifier = (*FlatMerkleTree)(nil)
+)
+
+// Proof is the membership proof of a leaf shared by the tree types. It carries
+// everything needed to check it against a root: the hash of the leaf node, its
+// position among the Size leaves of the tree, the steps leading from it to the
+// root and how the tree hashes its nodes.
+type Proof struct {
+	// Hash names the hash function of the tree, as registered with
+	// RegisterHash; it is empty if the function is unknown.
+	Hash string
+	// DomainSeparation is set when internal nodes are hashed with a 0x01
+	// prefix, or NodePrefix.
+	DomainSeparation bool
+	// SortedPairs is set when siblings are ordered before being hashed.
+	SortedPairs bool
+	// CountCommitment is set when the root commits to Size, as the
+	// CommittedRoot of a MerkleTree does.
+	CountCommitment bool `json:",omitempty"`
+	// LengthPrefixedLeaves is set when leaves are hashed from their
+	// LengthPrefixedLeaf encoding, as WithLengthPrefixedLeaves does.
+	LengthPrefixedLeaves bool `json:",omitempty"`
+	// LeafPrefix and NodePrefix are the prefixes of leaf and internal node
+	// hashes set with WithLeafPrefix and WithNodePrefix, nil for the default
+	// 0x00 and 0x01.
+	LeafPrefix []byte `json:",omitempty"`
+	NodePrefix []byte `json:",omitempty"`
+	// PairHash names the function hashing siblings into their parent set
+	// with WithPairHash, as registered with RegisterPairHash: it is empty
+	// for the default one and "?" for an unregistered one, whose proofs
+	// cannot be verified on their own.
+	PairHash string `json:",omitempty"`
+	// Keyed is set when the tree hashes with HMAC under a key set with
+	// WithHMACKey, which the proof does not carry; such proofs are checked
+	// with VerifyWithKey.
+	Keyed bool `json:",omitempty"`
+	// DigestSize is the size hashes are truncated to with WithDigestSize, 0
+	// for full digests.
+	DigestSize int `json:",omitempty"`
+	// Salt is the salt of the leaf of a FlatMerkleTree inserted with
+	// InsertSalted or salted by WithRandomSalts, hashed before the leaf data.
+	// It is nil for unsalted leaves.
+	Salt []byte `json:",omitempty"`
+	// Layout is the way the tree lays its leaves out, which puts the sibling
+	// of every step on one side or the other given Index and Size.
+	Layout ProofLayout `json:",omitempty"`
+
+	Index    int
+	Size     int
+	LeafHash []byte
+	// Steps lead from the leaf to the root. In a compressed proof the steps
+	// whose sibling was omitted have a nil Hash.
+	Steps []ProofStep
+}
+
+// ProofLayout is the way a tree lays its leaves out. It tells, from the index
+// of a leaf and the size of the tree, on which side the sibling of each step
+// of the proof of the leaf is.
+type ProofLayout int
+
+const (
+	// LayoutLevels pairs the nodes of every level left to right, the last one
+	// of an odd level with itself: the sibling of level i is on the left when
+	// bit i of the index is set. This is the layout of a MerkleTree padded
+	// with PadDuplicateLast or PadSelfPair.
+	LayoutLevels ProofLayout = iota
+	// LayoutPromote pairs the nodes of every level left to right, moving the
+	// last one of an odd level up unchanged, as a MerkleTree padded with
+	// PadPromote does.
+	LayoutPromote
+	// LayoutHeap stores the nodes as a binary heap whose leaves fill the last
+	// slots, their count padded to an even one, as a FlatMerkleTree does.
+	LayoutHeap
+	// LayoutReversedHeap stores the nodes as a binary heap whose leaves fill
+	// the last slots in reverse order, without padding, as the
+	// StandardMerkleTree of OpenZeppelin does.
+	LayoutReversedHeap
+)
+
+// valid reports whether l is one of the layouts above.
+func (l ProofLayout) valid() bool {
+	return l >= LayoutLevels && l <= LayoutReversedHeap
+}
+
+// sides returns, from the leaf up, whether the sibling of each step of the
+// proof of the leaf at index among size leaves is the left one. A lone leaf
+// is paired with itself where the layout pads leaves.
+func (l ProofLayout) sides(index, size int) []bool {
+	var left []bool
+	var pos uint
+	switch l {
+	case LayoutLevels, LayoutPromote:
+		for n, i := size, index; n > 1; n, i = n-n/2, i/2 {
+			if l == LayoutPromote && i == n-1 && n%2 == 1 {
+				continue
+			}
+			left = append(left, i%2 == 1)
+		}
+		if size == 1 && l == LayoutLevels {
+			left = []bool{false}
+		}
+
+		return left
+	case LayoutHeap:
+		pos = uint(size+size%2) + uint(index)
+	case LayoutReversedHeap:
+		pos = 2*uint(size) - 1 - uint(index)
+	}
+
+	// Counting the slots of the heap from 1, the bits of the position of a
+	// node below the leading one spell the path to it from the root, a set
+	// bit marking a right child.
+	for ; pos > 1; pos >>= 1 {
+		left = append(left, pos&1 == 1)
+	}
+
+	return left
+}
+
+// Verify checks that the proof leads from its leaf hash to root, returning an
+// error wrapping ErrInvalidProof when it does not. The sides of its steps must
+// be the ones Layout gives the leaf at Index among Size leaves, so the proof
+// also shows the position of the leaf, in a tree of that layout. Size is only
+// checked against the steps, unless the root commits to it with
+// CountCommitment. Compressed proofs are checked with VerifyCompressed and
+// keyed ones with VerifyWithKey.
+func (p *Proof) Verify(root []byte) error {
+	return p.verify(root, nil)
+}
+
+// verify checks the proof against root, hashing with HMAC under key when it is
+// not nil.
+func (p *Proof) verify(root, key []byte) error {
+	if p.Compressed() {
+		return fmt.Errorf("error: cannot verify a compressed proof without the known hashes")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	pair, err := p.pairHasher(key)
+	if err != nil {
+		return err
+	}
+
+	computed, _ := foldSteps(p.LeafHash, p.Steps, func(left, right []byte) ([]byte, error) {
+		return pair(left, right), nil
+	})
+	if computed, err = p.commitRoot(computed, key); err != nil {
+		return err
+	}
+	if !RootEqual(computed, root) {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// hashStrategy returns the hash function of the tree of the proof, keyed with
+// key, which must be given for keyed proofs only, and truncated to
+// DigestSize.
+func (p *Proof) hashStrategy(key []byte) (func() hash.Hash, error) {
+	if p.Keyed != (key != nil) {
+		return nil, ErrProofKeyed
+	}
+
+	hashStrategy, err := LookupHash(p.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.DigestSize < 0 || p.DigestSize > 0 && p.DigestSize >= hashStrategy().Size() {
+		return nil, fmt.Errorf("error: digest size %d out of range for hash function %q", p.DigestSize, p.Hash)
+	}
+
+	return truncatedHashFunc(hmacHashFunc(hashStrategy, key), p.DigestSize), nil
+}
+
+// pairHasher returns the function hashing two siblings into their parent the
+// way the tree of the proof does, keyed with key.
+func (p *Proof) pairHasher(key []byte) (func(left, right []byte) []byte, error) {
+	if p.PairHash != "" {
+		pairHash, ok := pairHashByName(p.PairHash)
+		if !ok {
+			return nil, fmt.Errorf("error: unknown proof pair hash %q", p.PairHash)
+		}
+
+		c := config{sortedPairs: p.SortedPairs}
+		return func(left, right []byte) []byte {
+			return truncateHash(pairHash(c.orderPair(left, right)), p.DigestSize)
+		}, nil
+	}
+
+	hashStrategy, err := p.hashStrategy(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := config{sortedPairs: p.SortedPairs, nodePrefix: p.NodePrefix}
+	return func(left, right []byte) []byte {
+		left, right = c.orderPair(left, right)
+
+		h := hashStrategy()
+		if p.DomainSeparation {
+			h.Write(c.nodePrefixBytes())
+		}
+		h.Write(left)
+		h.Write(right)
+
+		return h.Sum(nil)
+	}, nil
+}
+
+// hashLeaf returns the leaf hash of leaf in the tree of the proof: the hash of
+// its LengthPrefixedLeaf encoding with LengthPrefixedLeaves, otherwise leaf
+// itself, cut to DigestSize, without domain separation and its hash with the
+// leaf prefix with it.
+// Keyed proofs hash it with key, with no prefix without domain separation,
+// and salted ones hash Salt || leaf.
+func (p *Proof) hashLeaf(leaf, key []byte) ([]byte, error) {
+	if !p.DomainSeparation && !p.LengthPrefixedLeaves && !p.Keyed {
+		return truncateHash(leaf, p.DigestSize), nil
+	}
+	if len(p.Salt) > 0 {
+		leaf = append(append([]byte(nil), p.Salt...), leaf...)
+	}
+
+	hashStrategy, err := p.hashStrategy(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := config{leafPrefix: p.LeafPrefix}
+	h := hashStrategy()
+	if p.LengthPrefixedLeaves {
+		h.Write(lengthPrefixed(c.leafPrefixBytes(), leaf))
+
+		return h.Sum(nil), nil
+	}
+	if p.DomainSeparation {
+		h.Write(c.leafPrefixBytes())
+	}
+	h.Write(leaf)
+
+	return h.Sum(nil), nil
+}
+
+// commitRoot returns root committed to the size of the tree of the proof, as
+// CommittedRoot does, when the proof has CountCommitment, and root otherwise.
+func (p *Proof) commitRoot(root, key []byte) ([]byte, error) {
+	if !p.CountCommitment {
+		return root, nil
+	}
+
+	hashStrategy, err := p.hashStrategy(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitCount(hashStrategy(), p.Size, root), nil
+}
+
+// commitCount returns H(uint64_le(size) || root), resetting h first.
+func commitCount(h hash.Hash, size int, root []byte) []byte {
+	var count [8]byte
+	binary.LittleEndian.PutUint64(count[:], uint64(size))
+
+	h.Reset()
+	h.Write(count[:])
+	h.Write(root)
+
+	return h.Sum(nil)
+}
+
+// RootEqual reports whether a and b are the same hash. It runs in a time that
+// only depends on their length, so comparing a computed root with an expected
+// one does not reveal how many of their leading bytes match. The trees compare
+// hashes with it.
+func RootEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// foldSteps hashes leaf with the siblings of steps, in order, and returns the
+// resulting root.
+func foldSteps(leaf []byte, steps []ProofStep, pair func(left, right []byte) ([]byte, error)) ([]byte, error) {
+	hash := leaf
+	for _, step := range steps {
+		var err error
+		if step.Left {
+			hash, err = pair(step.Hash, hash)
+		} else {
+			hash, err = pair(hash, step.Hash)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,268 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+// provingTree is implemented by both tree types.
+type provingTree interface {
+	Rooter
+	Prover
+	Verifier
+}
+
+// conformanceTree is a tree built over n leaves together with the leaves to
+// prove, as its Prove method expects them.
+type conformanceTree struct {
+	name   string
+	tree   provingTree
+	leaves [][]byte
+}
+
+func conformanceTrees(t *testing.T, n int) []conformanceTree {
+	blocks := make([]Block, n)
+	leaves := make([][]byte, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		leaves[i] = blocks[i]
+	}
+
+	flat := NewMerkleTree(blocks...)
+	if err := flat.Finalize(WithSortedPairs(n%2 == 0)); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	tree, err := NewTreeWithOptions(paddingContents(n), WithDomainSeparation(true))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	hashes := make([][]byte, n)
+	for i, l := range tree.RealLeaves() {
+		hashes[i] = l.Hash
+	}
+
+	return []conformanceTree{
+		{name: "flat", tree: flat, leaves: leaves},
+		{name: "pointer", tree: tree, leaves: hashes},
+	}
+}
+
+func TestProverConformance(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			root, err := c.tree.RootHash()
+			if err != nil || len(root) == 0 {
+				t.Fatalf("[%s leaves:%d] error: expected a root got %x (%v)", c.name, n, root, err)
+			}
+
+			for i, leaf := range c.leaves {
+				p, err := c.tree.Prove(leaf)
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+				if p.Index != i {
+					t.Errorf("[%s leaves:%d index:%d] error: expected proof index %d got %d", c.name, n, i, i, p.Index)
+				}
+				if err := c.tree.VerifyProof(leaf, p); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid proof got %v", c.name, n, i, err)
+				}
+
+				other := c.leaves[(i+1)%len(c.leaves)]
+				if n > 1 && !errors.Is(c.tree.VerifyProof(other, p), ErrInvalidProof) {
+					t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof for another leaf", c.name, n, i)
+				}
+
+				if len(p.Steps) > 0 {
+					p.Steps[0].Hash = append([]byte(nil), p.Steps[0].Hash...)
+					p.Steps[0].Hash[0] ^= 0xff
+					if err := c.tree.VerifyProof(leaf, p); !errors.Is(err, ErrInvalidProof) {
+						t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof for a tampered step got %v", c.name, n, i, err)
+					}
+				}
+			}
+
+			if _, err := c.tree.Prove([]byte("missing")); err == nil {
+				t.Errorf("[%s leaves:%d] error: expected an error proving a missing leaf", c.name, n)
+			}
+		}
+	}
+}
+
+func TestRootHashUnbuilt(t *testing.T) {
+	if _, err := (&MerkleTree{}).RootHash(); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+	if _, err := NewMerkleTree(Block("blockA")).RootHash(); err == nil {
+		t.Errorf("error: expected an error for an unfinalized tree")
+	}
+}
+
+func TestProofVerify(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			root, _ := c.tree.RootHash()
+			for i, leaf := range c.leaves {
+				p, err := c.tree.Prove(leaf)
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+				if p.Hash != "sha256" || p.Size != n {
+					t.Errorf("[%s leaves:%d index:%d] error: expected a sha256 proof over %d leaves got %q over %d", c.name, n, i, n, p.Hash, p.Size)
+				}
+				if err := p.Verify(root); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid proof got %v", c.name, n, i, err)
+				}
+
+				forged := append([]byte(nil), root...)
+				forged[0] ^= 0xff
+				if err := p.Verify(forged); err != ErrInvalidProof {
+					t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof got %v", c.name, n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestProofVerifyHashStrategies(t *testing.T) {
+	tree, _ := NewTreeWithHashStrategy(paddingContents(5), sha512.New, WithSortedPairs(true))
+	p, err := tree.Prove(tree.Leaves[3].Hash)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if p.Hash != "sha512" || !p.SortedPairs || p.DomainSeparation {
+		t.Errorf("error: unexpected proof settings %q sorted=%t separated=%t", p.Hash, p.SortedPairs, p.DomainSeparation)
+	}
+	if err := p.Verify(tree.MerkleRoot()); err != nil {
+		t.Errorf("error: expected valid proof got %v", err)
+	}
+
+	custom, _ := NewTreeWithHashStrategy(paddingContents(5), fnv.New128a)
+	p, _ = custom.Prove(custom.Leaves[0].Hash)
+	if p.Hash != "" {
+		t.Errorf("error: expected an unknown hash got %q", p.Hash)
+	}
+	if err := p.Verify(custom.MerkleRoot()); err == nil {
+		t.Errorf("error: expected an error verifying with an unknown hash")
+	}
+
+	RegisterHash("test-fnv128a", fnv.New128a)
+	custom, _ = NewTreeWithHashStrategy(paddingContents(5), fnv.New128a)
+	p, _ = custom.Prove(custom.Leaves[0].Hash)
+	if p.Hash != "test-fnv128a" {
+		t.Errorf("error: expected hash test-fnv128a got %q", p.Hash)
+	}
+	if err := p.Verify(custom.MerkleRoot()); err != nil {
+		t.Errorf("error: expected valid proof got %v", err)
+	}
+}
+
+func TestRootEqual(t *testing.T) {
+	cases := []struct {
+		a, b []byte
+		want bool
+	}{
+		{a: []byte{1, 2, 3}, b: []byte{1, 2, 3}, want: true},
+		{a: []byte{1, 2, 3}, b: []byte{1, 2, 4}, want: false},
+		{a: []byte{1, 2, 3}, b: []byte{1, 2}, want: false},
+		{a: nil, b: []byte{}, want: true},
+		{a: nil, b: []byte{0}, want: false},
+	}
+	for i, c := range cases {
+		if got := RootEqual(c.a, c.b); got != c.want {
+			t.Errorf("[case:%d] error: expected %v got %v", i, c.want, got)
+		}
+	}
+}
+
+// forgedProof returns a copy of p, with its own steps, changed by forge.
+func forgedProof(p *Proof, forge func(f *Proof)) *Proof {
+	f := *p
+	f.Steps = append([]ProofStep(nil), p.Steps...)
+	forge(&f)
+
+	return &f
+}
+
+func TestProofVerifyPosition(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		trees := conformanceTrees(t, n)
+		promoted, _ := NewTreeWithOptions(paddingContents(n), WithPaddingStrategy(PadPromote))
+		hashes := make([][]byte, n)
+		for i, l := range promoted.RealLeaves() {
+			hashes[i] = l.Hash
+		}
+		trees = append(trees, conformanceTree{name: "promote", tree: promoted, leaves: hashes})
+
+		for _, c := range trees {
+			root, _ := c.tree.RootHash()
+			for i, leaf := range c.leaves {
+				p, _ := c.tree.Prove(leaf)
+				for j := -1; j <= n; j++ {
+					forged := forgedProof(p, func(f *Proof) { f.Index = j })
+					if err := forged.Verify(root); j != i && !errors.Is(err, ErrInvalidProof) {
+						t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof claiming index %d got %v", c.name, n, i, j, err)
+					}
+				}
+				for s := range p.Steps {
+					forged := forgedProof(p, func(f *Proof) { f.Steps[s].Left = !f.Steps[s].Left })
+					if err := forged.Verify(root); !errors.Is(err, ErrInvalidProof) {
+						t.Errorf("[%s leaves:%d index:%d] error: expected ErrInvalidProof flipping step %d got %v", c.name, n, i, s, err)
+					}
+				}
+			}
+		}
+	}
+
+	// The padding duplicate of C in [A, B, C] is the last leaf of [A, B, C, C],
+	// whose left sibling is itself.
+	flat := NewMerkleTree(Block("A"), Block("B"), Block("C"))
+	if err := flat.Finalize(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	root, _ := flat.RootHash()
+	p, _ := flat.Prove(Block("C"))
+	forged := forgedProof(p, func(f *Proof) {
+		f.Index, f.Size = 3, 4
+		f.Steps[0].Left = true
+	})
+	if err := forged.Verify(root); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("error: expected ErrInvalidProof for the padding duplicate got %v", err)
+	}
+}
+
+func TestProofVerifyTamperedPosition(t *testing.T) {
+	for _, n := range []int{3, 4, 6} {
+		for _, c := range conformanceTrees(t, n) {
+			root, _ := c.tree.RootHash()
+			for i, leaf := range c.leaves {
+				p, _ := c.tree.Prove(leaf)
+				for name, forge := range map[string]func(f *Proof){
+					"next index": func(f *Proof) { f.Index = (f.Index + 1) % n },
+					"no size":    func(f *Proof) { f.Size = 0 },
+					"past size":  func(f *Proof) { f.Index = f.Size },
+					"twice size": func(f *Proof) { f.Size *= 2 },
+				} {
+					forged := forgedProof(p, forge)
+					if err := forged.Verify(root); !errors.Is(err, ErrInvalidProof) {
+						t.Errorf("[%s leaves:%d index:%d %s] error: expected ErrInvalidProof got %v", c.name, n, i, name, err)
+					}
+
+					data, err := forged.MarshalBinary()
+					if err != nil {
+						continue
+					}
+					var decoded Proof
+					if err := decoded.UnmarshalBinary(data); err == nil {
+						if err := decoded.Verify(root); !errors.Is(err, ErrInvalidProof) {
+							t.Errorf("[%s leaves:%d index:%d %s] error: expected ErrInvalidProof once decoded got %v", c.name, n, i, name, err)
+						}
+					}
+				}
+			}
+		}
+	}
+}
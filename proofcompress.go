@@ -0,0 +1,177 @@
+package merklego
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingKnownHash is returned by Decompress when a sibling left out of a
+// compressed proof cannot be found among the known hashes.
+var ErrMissingKnownHash = errors.New("error: omitted proof sibling not among the known hashes")
+
+// Compressed reports whether some siblings of the proof were omitted by
+// Compress.
+func (p *Proof) Compressed() bool {
+	for _, s := range p.Steps {
+		if s.Hash == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compress returns a copy of the proof without the siblings a verifier
+// holding known, hashes of nodes it already authenticated against the root,
+// does not need. Those are the siblings of every step above the first node of
+// the path found in known, since reaching that node proves the leaf, and the
+// sibling just below it when that sibling is itself known, as the verifier can
+// find it by hashing. Omitted steps keep their direction and get a nil Hash. A
+// verifier knowing the parent of the leaf and its sibling, the immediate
+// subtree of the leaf, gets a proof without any sibling.
+func (p *Proof) Compress(known [][]byte) (*Proof, error) {
+	if p.Compressed() {
+		return nil, fmt.Errorf("error: proof is already compressed")
+	}
+
+	pair, err := p.pairHasher(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	set := knownSet(known)
+	c := *p
+	c.Steps = make([]ProofStep, len(p.Steps))
+	copy(c.Steps, p.Steps)
+
+	// Find the lowest node of the path the verifier knows, the root if none:
+	// the steps from there up can go.
+	hash, top := p.LeafHash, len(p.Steps)
+	for i, s := range p.Steps {
+		if set[string(hash)] {
+			top = i
+			break
+		}
+		hash = foldStep(pair, hash, s)
+	}
+	for i := top; i < len(c.Steps); i++ {
+		c.Steps[i].Hash = nil
+	}
+
+	// The sibling of the step reaching that node can be found by a verifier
+	// knowing it.
+	if top > 0 && set[string(p.Steps[top-1].Hash)] {
+		c.Steps[top-1].Hash = nil
+	}
+
+	return &c, nil
+}
+
+// Decompress returns a copy of a compressed proof with its omitted siblings
+// restored from known. Each of them is the known hash that, paired with the
+// node below it, gives a known node, so known must hold the nodes of the path
+// above the last sibling kept, the root included, along with their siblings.
+// It returns ErrMissingKnownHash when a sibling cannot be restored.
+func (p *Proof) Decompress(known [][]byte) (*Proof, error) {
+	pair, err := p.pairHasher(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	set := knownSet(known)
+	d := *p
+	d.Steps = make([]ProofStep, len(p.Steps))
+	copy(d.Steps, p.Steps)
+
+	hash := p.LeafHash
+	for i, s := range d.Steps {
+		if s.Hash == nil {
+			sibling, parent := findSibling(pair, set, known, hash, s.Left)
+			if sibling == nil {
+				return nil, fmt.Errorf("%w: step %d", ErrMissingKnownHash, i)
+			}
+			d.Steps[i].Hash = append([]byte(nil), sibling...)
+			hash = parent
+
+			continue
+		}
+
+		hash = foldStep(pair, hash, s)
+	}
+
+	return &d, nil
+}
+
+// VerifyCompressed checks a proof, compressed or not, against root for a
+// verifier that already authenticated the nodes in known against it. The proof
+// holds when its path reaches root or a known node, the omitted siblings being
+// looked up in known. It returns ErrInvalidProof otherwise.
+func (p *Proof) VerifyCompressed(root []byte, known [][]byte) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	pair, err := p.pairHasher(nil)
+	if err != nil {
+		return err
+	}
+
+	set := knownSet(known)
+	set[string(root)] = true
+
+	hash := p.LeafHash
+	for _, s := range p.Steps {
+		if set[string(hash)] {
+			return nil
+		}
+
+		if s.Hash == nil {
+			if _, hash = findSibling(pair, set, known, hash, s.Left); hash == nil {
+				return ErrInvalidProof
+			}
+
+			continue
+		}
+
+		hash = foldStep(pair, hash, s)
+	}
+
+	if hash, err = p.commitRoot(hash, nil); err != nil {
+		return err
+	}
+	if !set[string(hash)] {
+		return ErrInvalidProof
+	}
+
+	return nil
+}
+
+// findSibling returns the hash of known that, on the side given by left,
+// pairs with hash into a node of set, and that node.
+func findSibling(pair func(left, right []byte) []byte, set map[string]bool, known [][]byte, hash []byte, left bool) ([]byte, []byte) {
+	for _, k := range known {
+		parent := foldStep(pair, hash, ProofStep{Hash: k, Left: left})
+		if set[string(parent)] {
+			return k, parent
+		}
+	}
+
+	return nil, nil
+}
+
+func foldStep(pair func(left, right []byte) []byte, hash []byte, s ProofStep) []byte {
+	if s.Left {
+		return pair(s.Hash, hash)
+	}
+
+	return pair(hash, s.Hash)
+}
+
+func knownSet(known [][]byte) map[string]bool {
+	set := make(map[string]bool, len(known)+1)
+	for _, k := range known {
+		set[string(k)] = true
+	}
+
+	return set
+}
@@ -0,0 +1,137 @@
+package merklego
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// pathHashes returns every hash a verifier sees while checking p: the leaf,
+// the siblings and the nodes computed from them up to the root.
+func pathHashes(t *testing.T, p *Proof) [][]byte {
+	pair, err := p.pairHasher(nil)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	hashes := [][]byte{p.LeafHash}
+	hash := p.LeafHash
+	for _, s := range p.Steps {
+		hash = foldStep(pair, hash, s)
+		hashes = append(hashes, s.Hash, hash)
+	}
+
+	return hashes
+}
+
+func TestProofCompress(t *testing.T) {
+	tree, _ := NewTreeWithOptions(paddingContents(16), WithDomainSeparation(true))
+	root, _ := tree.RootHash()
+	first, _ := tree.Prove(tree.Leaves[0].Hash)
+	known := pathHashes(t, first)
+
+	cases := []struct {
+		index   int
+		omitted int
+	}{
+		// The sibling and the parent of leaf 1 are both known.
+		{index: 1, omitted: 4},
+		// Leaf 2 and 3 hash into the sibling of the parent of leaf 0.
+		{index: 2, omitted: 3},
+		{index: 5, omitted: 2},
+		{index: 15, omitted: 1},
+	}
+	for _, c := range cases {
+		p, _ := tree.Prove(tree.Leaves[c.index].Hash)
+
+		compressed, err := p.Compress(known)
+		if err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", c.index, err)
+		}
+		omitted := 0
+		for _, s := range compressed.Steps {
+			if s.Hash == nil {
+				omitted++
+			}
+		}
+		if omitted != c.omitted {
+			t.Errorf("[index:%d] error: expected %d omitted siblings got %d", c.index, c.omitted, omitted)
+		}
+		if p.Compressed() {
+			t.Errorf("[index:%d] error: expected Compress to leave the proof untouched", c.index)
+		}
+
+		if err := compressed.VerifyCompressed(root, known); err != nil {
+			t.Errorf("[index:%d] error: expected valid proof got %v", c.index, err)
+		}
+		if err := compressed.Verify(root); err == nil {
+			t.Errorf("[index:%d] error: expected Verify to refuse a compressed proof", c.index)
+		}
+
+		decompressed, err := compressed.Decompress(known)
+		if err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", c.index, err)
+		}
+		if !reflect.DeepEqual(decompressed, p) {
+			t.Errorf("[index:%d] error: expected %+v got %+v", c.index, p, decompressed)
+		}
+
+		data, err := compressed.MarshalBinary()
+		if err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", c.index, err)
+		}
+		full, _ := p.MarshalBinary()
+		if len(data) >= len(full) {
+			t.Errorf("[index:%d] error: expected fewer than %d bytes got %d", c.index, len(full), len(data))
+		}
+		var decoded Proof
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", c.index, err)
+		}
+		if !reflect.DeepEqual(&decoded, compressed) {
+			t.Errorf("[index:%d] error: expected %+v got %+v", c.index, compressed, decoded)
+		}
+	}
+}
+
+func TestProofCompressEdgeCases(t *testing.T) {
+	tree, _ := NewTreeWithOptions(paddingContents(8), WithDomainSeparation(true))
+	root, _ := tree.RootHash()
+	p, _ := tree.Prove(tree.Leaves[6].Hash)
+
+	unchanged, _ := p.Compress(nil)
+	if !reflect.DeepEqual(unchanged, p) {
+		t.Errorf("error: expected nothing to compress got %+v", unchanged)
+	}
+
+	// Knowing the immediate subtree of the leaf leaves no sibling to send.
+	parent := tree.Leaves[6].Parent
+	empty, _ := p.Compress([][]byte{parent.Hash, tree.Leaves[7].Hash})
+	for i, s := range empty.Steps {
+		if s.Hash != nil {
+			t.Errorf("[step:%d] error: expected omitted sibling got %x", i, s.Hash)
+		}
+	}
+	if err := empty.VerifyCompressed(root, [][]byte{parent.Hash, tree.Leaves[7].Hash}); err != nil {
+		t.Errorf("error: expected valid proof got %v", err)
+	}
+	if err := empty.VerifyCompressed(root, [][]byte{parent.Hash}); err != ErrInvalidProof {
+		t.Errorf("error: expected ErrInvalidProof got %v", err)
+	}
+	if _, err := empty.Decompress([][]byte{parent.Hash, tree.Leaves[7].Hash}); !errors.Is(err, ErrMissingKnownHash) {
+		t.Errorf("error: expected ErrMissingKnownHash got %v", err)
+	}
+
+	forged := *empty
+	forged.LeafHash = tree.Leaves[5].Hash
+	if err := forged.VerifyCompressed(root, [][]byte{parent.Hash, tree.Leaves[7].Hash}); err != ErrInvalidProof {
+		t.Errorf("error: expected ErrInvalidProof got %v", err)
+	}
+
+	if _, err := empty.Compress(nil); err == nil {
+		t.Errorf("error: expected an error compressing a compressed proof")
+	}
+	if err := p.VerifyCompressed(root, nil); err != nil {
+		t.Errorf("error: expected an uncompressed proof to verify got %v", err)
+	}
+}
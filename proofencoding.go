@@ -0,0 +1,510 @@
+package merklego
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// proofVersion is the version of the binary proof layout written by
+// MarshalBinary, proofVersionSalted the one of proofs with a Salt, which adds
+// it to the layout, and proofVersionLayout the one of proofs of another
+// Layout than LayoutLevels, which adds it after the salt.
+const (
+	proofVersion       = 1
+	proofVersionSalted = 2
+	proofVersionLayout = 3
+)
+
+const (
+	proofFlagDomainSeparation = 1 << iota
+	proofFlagSortedPairs
+	proofFlagCompressed
+	proofFlagCountCommitment
+	proofFlagLengthPrefixedLeaves
+	proofFlagPrefixes
+	proofFlagPairHash
+	proofFlagKeyed
+)
+
+// maxProofSteps bounds the number of steps of a decoded proof: a path longer
+// than that would belong to a tree with more leaves than can be indexed.
+const maxProofSteps = 64
+
+// maxInt is the largest value of an int.
+const maxInt = int(^uint(0) >> 1)
+
+// ErrInvalidProofEncoding is returned when decoding malformed binary proofs.
+var ErrInvalidProofEncoding = errors.New("error: invalid proof encoding")
+
+var (
+	_ encoding.BinaryMarshaler   = (*Proof)(nil)
+	_ encoding.BinaryUnmarshaler = (*Proof)(nil)
+)
+
+// MarshalBinary encodes the proof in a compact, versioned layout:
+//
+//	version     1 byte, 3 for proofs of another layout than LayoutLevels, 2
+//	            for other proofs with a salt and 1 otherwise
+//	hash size   1 byte, the length of the leaf hash and of every sibling;
+//	            below the digest size of the named hash function, it is the
+//	            DigestSize of a truncated proof
+//	flags       1 byte, bit 0 domain separation, bit 1 sorted pairs, bit 2
+//	            compressed, bit 3 count commitment, bit 4 length prefixed
+//	            leaves, bit 5 prefixes, bit 6 pair hash, bit 7 keyed
+//	hash name   1 byte length, then the name
+//	prefixes    1 byte length, then the leaf prefix, and the same for the
+//	            node prefix, an empty one standing for the default; only
+//	            present with the prefixes flag
+//	pair hash   1 byte length, then the name; only present with the pair hash
+//	            flag
+//	salt        1 byte length, then the salt; only present in versions 2
+//	            and 3, and only empty in version 3
+//	layout      1 byte, the Layout; only present in version 3
+//	index       uvarint
+//	size        uvarint
+//	steps       uvarint, the number of siblings
+//	directions  ceil(steps/8) bytes, bit i set when sibling i is on the left
+//	omitted     ceil(steps/8) bytes, bit i set when sibling i was omitted by
+//	            Compress, only present in compressed proofs
+//	leaf hash   hash size bytes
+//	siblings    hash size bytes each, back to back, omitted ones left out
+//
+// A proof of a leaf of a SHA-256 tree of a million leaves takes 692 bytes,
+// against 1534 for its JSON encoding.
+func (p *Proof) MarshalBinary() ([]byte, error) {
+	size := len(p.LeafHash)
+	if size == 0 || size > 0xff {
+		return nil, fmt.Errorf("error: cannot encode a proof with a %d byte leaf hash", size)
+	}
+	for i, s := range p.Steps {
+		if s.Hash != nil && len(s.Hash) != size {
+			return nil, fmt.Errorf("error: cannot encode step %d: %d byte hash in a proof of %d byte hashes", i, len(s.Hash), size)
+		}
+	}
+	if p.DigestSize != 0 && p.DigestSize != size {
+		return nil, fmt.Errorf("error: cannot encode a proof of %d byte digests with a %d byte leaf hash", p.DigestSize, size)
+	}
+	if len(p.Hash) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode hash name %q", p.Hash)
+	}
+	if len(p.LeafPrefix) > 0xff || len(p.NodePrefix) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode prefixes of %d and %d bytes", len(p.LeafPrefix), len(p.NodePrefix))
+	}
+	if len(p.PairHash) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode pair hash name %q", p.PairHash)
+	}
+	if len(p.Salt) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode a %d byte salt", len(p.Salt))
+	}
+	if !p.Layout.valid() {
+		return nil, fmt.Errorf("error: cannot encode layout %d", p.Layout)
+	}
+	if len(p.Steps) > maxProofSteps {
+		return nil, fmt.Errorf("error: cannot encode a proof of %d steps", len(p.Steps))
+	}
+	if p.Index < 0 || p.Size < 0 {
+		return nil, fmt.Errorf("error: cannot encode a proof of index %d in %d leaves", p.Index, p.Size)
+	}
+
+	var flags byte
+	if p.DomainSeparation {
+		flags |= proofFlagDomainSeparation
+	}
+	if p.SortedPairs {
+		flags |= proofFlagSortedPairs
+	}
+	if p.Compressed() {
+		flags |= proofFlagCompressed
+	}
+	if p.CountCommitment {
+		flags |= proofFlagCountCommitment
+	}
+	if p.LengthPrefixedLeaves {
+		flags |= proofFlagLengthPrefixedLeaves
+	}
+	if len(p.LeafPrefix) > 0 || len(p.NodePrefix) > 0 {
+		flags |= proofFlagPrefixes
+	}
+	if p.PairHash != "" {
+		flags |= proofFlagPairHash
+	}
+	if p.Keyed {
+		flags |= proofFlagKeyed
+	}
+
+	version := byte(proofVersion)
+	if p.Layout != LayoutLevels {
+		version = proofVersionLayout
+	} else if len(p.Salt) > 0 {
+		version = proofVersionSalted
+	}
+
+	out := make([]byte, 0, 9+len(p.Hash)+len(p.LeafPrefix)+len(p.NodePrefix)+len(p.PairHash)+len(p.Salt)+3*binary.MaxVarintLen64+(len(p.Steps)+7)/8+(1+len(p.Steps))*size)
+	out = append(out, version, byte(size), flags, byte(len(p.Hash)))
+	out = append(out, p.Hash...)
+	if flags&proofFlagPrefixes != 0 {
+		out = append(out, byte(len(p.LeafPrefix)))
+		out = append(out, p.LeafPrefix...)
+		out = append(out, byte(len(p.NodePrefix)))
+		out = append(out, p.NodePrefix...)
+	}
+	if flags&proofFlagPairHash != 0 {
+		out = append(out, byte(len(p.PairHash)))
+		out = append(out, p.PairHash...)
+	}
+	if version != proofVersion {
+		out = append(out, byte(len(p.Salt)))
+		out = append(out, p.Salt...)
+	}
+	if version == proofVersionLayout {
+		out = append(out, byte(p.Layout))
+	}
+	var varint [binary.MaxVarintLen64]byte
+	for _, v := range []int{p.Index, p.Size, len(p.Steps)} {
+		out = append(out, varint[:binary.PutUvarint(varint[:], uint64(v))]...)
+	}
+
+	directions := make([]byte, (len(p.Steps)+7)/8)
+	omitted := make([]byte, len(directions))
+	for i, s := range p.Steps {
+		if s.Left {
+			directions[i/8] |= 1 << (i % 8)
+		}
+		if s.Hash == nil {
+			omitted[i/8] |= 1 << (i % 8)
+		}
+	}
+	out = append(out, directions...)
+	if flags&proofFlagCompressed != 0 {
+		out = append(out, omitted...)
+	}
+
+	out = append(out, p.LeafHash...)
+	for _, s := range p.Steps {
+		out = append(out, s.Hash...)
+	}
+
+	return out, nil
+}
+
+// UnmarshalBinary decodes a proof encoded by MarshalBinary. Every length is
+// checked against the input, and input left over after the last sibling is
+// rejected, as are proofs naming a hash function that is not registered, with
+// an UnknownHashError, and proofs Validate refuses. On error the proof is
+// left untouched.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	return p.unmarshalBinary(data, DecodeOptions{}.WithDefaults())
+}
+
+// unmarshalBinary decodes data within the limits of o, checking every length
+// as soon as it is read, and checks the decoded proof as Validate does.
+func (p *Proof) unmarshalBinary(data []byte, o DecodeOptions) error {
+	var decoded Proof
+	if err := decoded.decodeBinary(data, o); err != nil {
+		return err
+	}
+	if reason := decoded.invalid(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidProofEncoding, reason)
+	}
+
+	*p = decoded
+
+	return nil
+}
+
+// decodeBinary decodes data into p as unmarshalBinary does, without checking
+// that the decoded proof is valid.
+func (p *Proof) decodeBinary(data []byte, o DecodeOptions) error {
+	if err := o.Check("MaxInputSize", uint64(len(data)), o.MaxInputSize, ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+
+	d := proofDecoder{data: data}
+
+	version := d.byte()
+	if d.err == nil && (version < proofVersion || version > proofVersionLayout) {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidProofEncoding, version)
+	}
+	size := int(d.byte())
+	if d.err == nil && size == 0 {
+		return fmt.Errorf("%w: zero hash size", ErrInvalidProofEncoding)
+	}
+	if err := o.Check("MaxHashSize", uint64(size), o.MaxHashSize, ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+	// Every bit of the flags is assigned.
+	flags := d.byte()
+	name := d.bytes(int(d.byte()))
+	var leafPrefix, nodePrefix []byte
+	if flags&proofFlagPrefixes != 0 {
+		leafPrefix = d.bytes(int(d.byte()))
+		nodePrefix = d.bytes(int(d.byte()))
+		if d.err == nil && len(leafPrefix) == 0 && len(nodePrefix) == 0 {
+			return fmt.Errorf("%w: prefixes flag without prefixes", ErrInvalidProofEncoding)
+		}
+	}
+	var pairHash []byte
+	if flags&proofFlagPairHash != 0 {
+		pairHash = d.bytes(int(d.byte()))
+		if d.err == nil && len(pairHash) == 0 {
+			return fmt.Errorf("%w: pair hash flag without a name", ErrInvalidProofEncoding)
+		}
+	}
+	var salt []byte
+	if version != proofVersion {
+		salt = d.bytes(int(d.byte()))
+		if d.err == nil && len(salt) == 0 && version == proofVersionSalted {
+			return fmt.Errorf("%w: salted proof without a salt", ErrInvalidProofEncoding)
+		}
+	}
+	layout := LayoutLevels
+	if version == proofVersionLayout {
+		layout = ProofLayout(d.byte())
+		if d.err == nil && layout == LayoutLevels {
+			return fmt.Errorf("%w: layout version without a layout", ErrInvalidProofEncoding)
+		}
+	}
+	index := d.uvarint()
+	leaves := d.uvarint()
+	steps := d.uvarint()
+	if d.err != nil {
+		return d.err
+	}
+
+	if err := o.Check("MaxProofSteps", steps, o.MaxProofSteps, ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+	if err := o.Check("MaxTreeSize", leaves, o.MaxTreeSize, ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+	if leaves > uint64(maxInt) || leaves == 0 && index != 0 || leaves > 0 && index >= leaves {
+		return fmt.Errorf("%w: index %d out of %d leaves", ErrInvalidProofEncoding, index, leaves)
+	}
+
+	directions := d.bitmap(int(steps))
+	omitted := make([]byte, len(directions))
+	kept := int(steps)
+	if flags&proofFlagCompressed != 0 {
+		omitted = d.bitmap(int(steps))
+		if d.err != nil {
+			return d.err
+		}
+		for i := 0; i < int(steps); i++ {
+			if omitted[i/8]&(1<<(i%8)) != 0 {
+				kept--
+			}
+		}
+		if kept == int(steps) {
+			return fmt.Errorf("%w: compressed proof without omitted steps", ErrInvalidProofEncoding)
+		}
+	}
+	leaf := d.bytes(size)
+	hashes := d.bytes(kept * size)
+	if d.err != nil {
+		return d.err
+	}
+	if d.off != len(data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidProofEncoding, len(data)-d.off)
+	}
+	if err := checkHashName(string(name), ErrInvalidProofEncoding); err != nil {
+		return err
+	}
+
+	decoded := Proof{
+		Hash:                 string(name),
+		DomainSeparation:     flags&proofFlagDomainSeparation != 0,
+		SortedPairs:          flags&proofFlagSortedPairs != 0,
+		CountCommitment:      flags&proofFlagCountCommitment != 0,
+		LengthPrefixedLeaves: flags&proofFlagLengthPrefixedLeaves != 0,
+		LeafPrefix:           clonePrefix(leafPrefix),
+		NodePrefix:           clonePrefix(nodePrefix),
+		PairHash:             string(pairHash),
+		Keyed:                flags&proofFlagKeyed != 0,
+		DigestSize:           proofDigestSize(string(name), size),
+		Salt:                 clonePrefix(salt),
+		Layout:               layout,
+		Index:                int(index),
+		Size:                 int(leaves),
+		LeafHash:             append([]byte(nil), leaf...),
+	}
+
+	// Copy the hashes so the proof does not pin data.
+	hashes = append([]byte(nil), hashes...)
+	if steps > 0 {
+		decoded.Steps = make([]ProofStep, steps)
+	}
+	for i := range decoded.Steps {
+		decoded.Steps[i].Left = directions[i/8]&(1<<(i%8)) != 0
+		if omitted[i/8]&(1<<(i%8)) == 0 {
+			decoded.Steps[i].Hash = hashes[:size:size]
+			hashes = hashes[size:]
+		}
+	}
+
+	*p = decoded
+
+	return nil
+}
+
+// proofDecoder reads the fields of a binary proof, recording the first read
+// past the end of the data or the first overlong varint.
+type proofDecoder struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (d *proofDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data)-d.off {
+		d.err = fmt.Errorf("%w: truncated at offset %d", ErrInvalidProofEncoding, d.off)
+		return nil
+	}
+
+	b := d.data[d.off : d.off+n]
+	d.off += n
+
+	return b
+}
+
+// bitmap reads a bitmap of n bits, rejecting set bits past the n-th.
+func (d *proofDecoder) bitmap(n int) []byte {
+	b := d.bytes((n + 7) / 8)
+	if d.err == nil && n%8 != 0 && b[len(b)-1]>>(n%8) != 0 {
+		d.err = fmt.Errorf("%w: unused bitmap bits set", ErrInvalidProofEncoding)
+		return nil
+	}
+
+	return b
+}
+
+func (d *proofDecoder) byte() byte {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (d *proofDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+	v, n := binary.Uvarint(d.data[d.off:])
+	if n <= 0 || n != binary.PutUvarint(buf[:], v) {
+		d.err = fmt.Errorf("%w: bad varint at offset %d", ErrInvalidProofEncoding, d.off)
+		return 0
+	}
+	d.off += n
+
+	return v
+}
+
+// MaxProofStringLength is the longest string DecodeProofString accepts. It
+// leaves room for the largest proof MarshalBinary can produce.
+const MaxProofStringLength = 1 << 15
+
+// CorruptProofError is returned by DecodeProofString when the string is not a
+// valid encoding of a proof.
+type CorruptProofError struct {
+	Err error
+}
+
+func (e *CorruptProofError) Error() string {
+	return fmt.Sprintf("error: corrupt proof encoding: %v", e.Err)
+}
+
+// Unwrap returns the error raised while decoding.
+func (e *CorruptProofError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidProofError is returned by Validate, and by the decoders and Verify
+// through it, for a proof that no tree could have produced.
+type InvalidProofError struct {
+	Reason string
+}
+
+func (e *InvalidProofError) Error() string {
+	return fmt.Sprintf("error: structurally invalid proof: %s", e.Reason)
+}
+
+// Unwrap returns ErrInvalidProof.
+func (e *InvalidProofError) Unwrap() error {
+	return ErrInvalidProof
+}
+
+// EncodeString returns the proof as a single URL safe token: the unpadded
+// base64url encoding of MarshalBinary.
+func (p *Proof) EncodeString() (string, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeProofString decodes a proof encoded by EncodeString. It returns a
+// *CorruptProofError for strings that do not decode, including strings longer
+// than MaxProofStringLength, and an *InvalidProofError for proofs whose
+// fields are inconsistent with each other.
+func DecodeProofString(s string) (*Proof, error) {
+	return DecodeOptions{MaxInputSize: MaxProofStringLength}.DecodeProofString(s)
+}
+
+// Validate checks that the proof describes a leaf of a tree of p.Size leaves:
+// its index is in range, its steps are the ones Layout gives that leaf, one
+// per level above it on the side of its sibling, and its DigestSize, if any,
+// is the length of its leaf hash. The last of an even number of leaves whose
+// left sibling is itself is refused in the layouts that pad leaves, being the
+// padding duplicate of a tree of one leaf less, which has the same root. It
+// returns an *InvalidProofError otherwise.
+func (p *Proof) Validate() error {
+	if reason := p.invalid(); reason != "" {
+		return &InvalidProofError{Reason: reason}
+	}
+
+	return nil
+}
+
+// invalid returns why Validate refuses the proof, or an empty string.
+func (p *Proof) invalid() string {
+	if p.Size <= 0 {
+		return fmt.Sprintf("tree size %d", p.Size)
+	}
+	if p.Index < 0 || p.Index >= p.Size {
+		return fmt.Sprintf("leaf index %d out of %d leaves", p.Index, p.Size)
+	}
+	if !p.Layout.valid() {
+		return fmt.Sprintf("unknown layout %d", p.Layout)
+	}
+
+	// A single leaf may be the root of its tree, without any step.
+	left := p.Layout.sides(p.Index, p.Size)
+	if len(p.Steps) != len(left) && !(p.Size == 1 && len(p.Steps) == 0) {
+		return fmt.Sprintf("%d steps for leaf %d of a tree of %d leaves", len(p.Steps), p.Index, p.Size)
+	}
+	for i, s := range p.Steps {
+		if s.Left != left[i] {
+			return fmt.Sprintf("sibling %d of leaf %d of %d on the wrong side", i, p.Index, p.Size)
+		}
+	}
+	if (p.Layout == LayoutLevels || p.Layout == LayoutHeap) && p.Index == p.Size-1 && p.Size%2 == 0 &&
+		len(p.Steps) > 0 && p.Steps[0].Hash != nil && RootEqual(p.Steps[0].Hash, p.LeafHash) {
+		return fmt.Sprintf("leaf %d of %d is a padding duplicate", p.Index, p.Size)
+	}
+
+	if p.DigestSize != 0 && p.DigestSize != len(p.LeafHash) {
+		return fmt.Sprintf("%d byte leaf hash in a proof of %d byte digests", len(p.LeafHash), p.DigestSize)
+	}
+
+	return ""
+}
@@ -0,0 +1,270 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProofBinaryRoundTrip(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			root, _ := c.tree.RootHash()
+			for i, leaf := range c.leaves {
+				p, _ := c.tree.Prove(leaf)
+
+				data, err := p.MarshalBinary()
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+
+				var decoded Proof
+				if err := decoded.UnmarshalBinary(data); err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+				if !reflect.DeepEqual(&decoded, p) {
+					t.Errorf("[%s leaves:%d index:%d] error: expected %+v got %+v", c.name, n, i, p, decoded)
+				}
+				if err := decoded.Verify(root); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid proof got %v", c.name, n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestProofBinaryInvalid(t *testing.T) {
+	tree, _ := NewTree(paddingContents(11))
+	p, _ := tree.Prove(tree.Leaves[9].Hash)
+	data, _ := p.MarshalBinary()
+
+	cases := map[string][]byte{
+		"empty":    {},
+		"version":  append([]byte{proofVersionSalted + 1}, data[1:]...),
+		"trailing": append(append([]byte(nil), data...), 0),
+		"overlong": {proofVersion, 32, 0, 0, 0x80, 0x00, 1, 0},
+		"index":    {proofVersion, 32, 0, 0, 3, 2, 0},
+		"hashSize": append([]byte{proofVersion, 0}, data[2:]...),
+		"steps":    {proofVersion, 32, 0, 0, 0, 1, 200},
+		"omitted":  {proofVersion, 32, proofFlagCompressed, 0, 0, 1, 1, 0, 0},
+		"bitmap":   {proofVersion, 32, proofFlagCompressed, 0, 0, 1, 1, 0, 3},
+		"prefixes": {proofVersion, 32, proofFlagPrefixes, 0, 0, 0, 0, 1, 0},
+		"prefix":   {proofVersion, 32, proofFlagPrefixes, 0, 4, 'l', 'e'},
+	}
+	for i := 1; i < len(data); i++ {
+		var decoded Proof
+		if err := decoded.UnmarshalBinary(data[:i]); !errors.Is(err, ErrInvalidProofEncoding) {
+			t.Errorf("[truncated:%d] error: expected ErrInvalidProofEncoding got %v", i, err)
+		}
+	}
+
+	// Set an unused direction bit of a proof of 4 steps.
+	bits := append([]byte(nil), data...)
+	bits[4+len(p.Hash)+3] |= 0x80
+	cases["directions"] = bits
+
+	for name, data := range cases {
+		decoded := Proof{Index: 7}
+		if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrInvalidProofEncoding) {
+			t.Errorf("[%s] error: expected ErrInvalidProofEncoding got %v", name, err)
+		}
+		if decoded.Index != 7 {
+			t.Errorf("[%s] error: expected the proof to be left untouched", name)
+		}
+	}
+}
+
+func TestProofBinaryMarshalErrors(t *testing.T) {
+	for name, p := range map[string]*Proof{
+		"noLeaf":   {},
+		"mixed":    {LeafHash: make([]byte, 32), Steps: []ProofStep{{Hash: make([]byte, 64)}}},
+		"negative": {LeafHash: make([]byte, 32), Index: -1},
+		"prefix":   {LeafHash: make([]byte, 32), Size: 1, NodePrefix: make([]byte, 256)},
+	} {
+		if _, err := p.MarshalBinary(); err == nil {
+			t.Errorf("[%s] error: expected an error", name)
+		}
+	}
+}
+
+func TestProofBinarySize(t *testing.T) {
+	const leaves = 1 << 20
+
+	steps := make([]ProofStep, 20)
+	for i := range steps {
+		steps[i] = ProofStep{Hash: bytes.Repeat([]byte{byte(i)}, 32), Left: i%3 == 0}
+	}
+	p := &Proof{Hash: "sha256", Index: leaves - 1, Size: leaves, LeafHash: make([]byte, 32), Steps: steps}
+
+	data, _ := p.MarshalBinary()
+	js, _ := json.Marshal(p)
+	if len(data) != 692 || len(js) != 1534 {
+		t.Errorf("error: expected 692 binary and 1534 json bytes got %d and %d", len(data), len(js))
+	}
+}
+
+func FuzzProofUnmarshalBinary(f *testing.F) {
+	for n := 1; n <= 5; n++ {
+		tree, _ := NewTreeWithOptions(paddingContents(n), WithSortedPairs(n%2 == 0))
+		p, _ := tree.Prove(tree.Leaves[n-1].Hash)
+		data, _ := p.MarshalBinary()
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var p Proof
+		if err := p.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		encoded, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("error: cannot encode a decoded proof: %v", err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("error: expected %x to encode back to itself got %x", data, encoded)
+		}
+	})
+}
+
+func TestProofStringRoundTrip(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			root, _ := c.tree.RootHash()
+			for i, leaf := range c.leaves {
+				p, _ := c.tree.Prove(leaf)
+
+				s, err := p.EncodeString()
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+				if strings.ContainsAny(s, "+/=") {
+					t.Errorf("[%s leaves:%d index:%d] error: expected a URL safe token got %s", c.name, n, i, s)
+				}
+
+				decoded, err := DecodeProofString(s)
+				if err != nil {
+					t.Fatalf("[%s leaves:%d index:%d] error: unexpected error: %v", c.name, n, i, err)
+				}
+				if !reflect.DeepEqual(decoded, p) {
+					t.Errorf("[%s leaves:%d index:%d] error: expected %+v got %+v", c.name, n, i, p, decoded)
+				}
+				if err := decoded.Verify(root); err != nil {
+					t.Errorf("[%s leaves:%d index:%d] error: expected valid proof got %v", c.name, n, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeProofStringErrors(t *testing.T) {
+	encode := func(p *Proof) string {
+		s, err := p.EncodeString()
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+		return s
+	}
+	hash := make([]byte, 32)
+
+	corrupt := map[string]string{
+		"base64":    "not base64!",
+		"padded":    encode(&Proof{Size: 1, LeafHash: hash}) + "==",
+		"binary":    base64.RawURLEncoding.EncodeToString([]byte{9, 9, 9}),
+		"truncated": encode(&Proof{Size: 1, LeafHash: hash})[:10],
+		"oversized": strings.Repeat("A", MaxProofStringLength+4),
+	}
+	for name, s := range corrupt {
+		var target *CorruptProofError
+		if _, err := DecodeProofString(s); !errors.As(err, &target) {
+			t.Errorf("[%s] error: expected *CorruptProofError got %v", name, err)
+		}
+	}
+
+	invalid := map[string]*Proof{
+		"size":  {LeafHash: hash},
+		"steps": {Size: 2, LeafHash: hash, Steps: []ProofStep{{Hash: hash}, {Hash: hash}}},
+	}
+	for name, p := range invalid {
+		var target *InvalidProofError
+		if _, err := DecodeProofString(encode(p)); !errors.As(err, &target) {
+			t.Errorf("[%s] error: expected *InvalidProofError got %v", name, err)
+		}
+	}
+}
+
+func FuzzDecodeProofString(f *testing.F) {
+	tree, _ := NewTree(paddingContents(5))
+	p, _ := tree.Prove(tree.Leaves[2].Hash)
+	s, _ := p.EncodeString()
+	f.Add(s)
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		p, err := DecodeProofString(s)
+		if err != nil {
+			var corrupt *CorruptProofError
+			var invalid *InvalidProofError
+			if !errors.As(err, &corrupt) && !errors.As(err, &invalid) {
+				t.Fatalf("error: expected a typed error got %v", err)
+			}
+			return
+		}
+
+		if _, err := p.EncodeString(); err != nil {
+			t.Fatalf("error: cannot encode a decoded proof: %v", err)
+		}
+	})
+}
+
+func TestDecodeForgedPositions(t *testing.T) {
+	for _, n := range []int{3, 5} {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		if err := tree.Finalize(); err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+		p, _ := tree.Prove(blocks[n-1])
+
+		for name, forge := range map[string]func(f *Proof){
+			"first index": func(f *Proof) { f.Index = 0 },
+			"flipped":     func(f *Proof) { f.Steps[1].Left = !f.Steps[1].Left },
+			// The padding duplicate of the last block, the left sibling of
+			// itself in a tree of one block more.
+			"padding": func(f *Proof) {
+				f.Index, f.Size = n, n+1
+				f.Steps[0].Left = true
+			},
+		} {
+			forged := forgedProof(p, forge)
+
+			data, err := forged.MarshalBinary()
+			if err != nil {
+				t.Fatalf("[leaves:%d %s] error: unexpected error: %v", n, name, err)
+			}
+			var decoded Proof
+			if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrInvalidProofEncoding) {
+				t.Errorf("[leaves:%d %s] error: expected ErrInvalidProofEncoding got %v", n, name, err)
+			}
+
+			js, _ := json.Marshal(forged)
+			if err := decoded.UnmarshalJSON(js); !errors.Is(err, ErrInvalidProofEncoding) {
+				t.Errorf("[leaves:%d %s] error: expected ErrInvalidProofEncoding decoding JSON got %v", n, name, err)
+			}
+
+			s, _ := forged.EncodeString()
+			var invalid *InvalidProofError
+			if _, err := DecodeProofString(s); !errors.As(err, &invalid) {
+				t.Errorf("[leaves:%d %s] error: expected *InvalidProofError got %v", n, name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package merklego
+
+import (
+	"container/heap"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+)
+
+// ProofFormat is an encoding of a Proof, as measured by EncodedSize.
+type ProofFormat int
+
+const (
+	// ProofJSON is the encoding of json.Marshal.
+	ProofJSON ProofFormat = iota
+	// ProofBinary is the encoding of MarshalBinary.
+	ProofBinary
+	// ProofBase64 is the encoding of EncodeString.
+	ProofBase64
+)
+
+// ProofSize returns the number of sibling hashes in the proof of a leaf of a
+// MerkleTree of leafCount leaves built with the default options, where every
+// leaf sits at the same depth. It is also the length of the longest proof of
+// a FlatMerkleTree of leafCount blocks, whose leaves may sit one level higher.
+// It returns 0 for an empty tree.
+func ProofSize(leafCount int) int {
+	if leafCount <= 0 {
+		return 0
+	}
+
+	depth := 1
+	for 1<<depth < leafCount {
+		depth++
+	}
+
+	return depth
+}
+
+// MultiProofSize returns the number of sibling hashes needed to prove the
+// blocks at indices together in a FlatMerkleTree of leafCount blocks built
+// without WithSingleLeafRoot: the nodes outside the union of their paths
+// whose hashes the paths consume. Duplicate indices count once; the padding
+// duplicate is derived from the last block, as in a RangeProof, whose
+// Siblings hold that many hashes. It returns -1 if an index is out of range.
+func MultiProofSize(leafCount int, indices []int) int {
+	n := leafCount
+	if n%2 != 0 {
+		n++
+	}
+
+	seen := make(map[int]bool, len(indices)+1)
+	queue := make(positionHeap, 0, len(indices)+1)
+	add := func(pos int) {
+		if !seen[pos] {
+			seen[pos] = true
+			queue = append(queue, pos)
+		}
+	}
+	for _, i := range indices {
+		if i < 0 || i >= leafCount {
+			return -1
+		}
+		add(n - 1 + i)
+		if i == leafCount-1 && n != leafCount {
+			add(n - 1 + leafCount)
+		}
+	}
+	if len(queue) == 0 {
+		return 0
+	}
+	heap.Init(&queue)
+
+	// Walk the cover as rangeRoot does, deepest position first.
+	siblings := 0
+	for {
+		pos := heap.Pop(&queue).(int)
+		if pos == 0 {
+			return siblings
+		}
+		if pos%2 == 0 && len(queue) > 0 && queue[0] == pos-1 {
+			heap.Pop(&queue)
+		} else {
+			siblings++
+		}
+
+		heap.Push(&queue, (pos-1)/2)
+	}
+}
+
+// EncodedSize returns the length of the encoding of the proof in format
+// without encoding it, or -1 if MarshalBinary would reject the proof for
+// ProofBinary and ProofBase64, or for an unknown format.
+func (p *Proof) EncodedSize(format ProofFormat) int {
+	switch format {
+	case ProofJSON:
+		return p.jsonSize()
+	case ProofBinary:
+		return p.binarySize()
+	case ProofBase64:
+		size := p.binarySize()
+		if size < 0 {
+			return -1
+		}
+
+		return base64.RawURLEncoding.EncodedLen(size)
+	}
+
+	return -1
+}
+
+// binarySize mirrors the layout and the checks of MarshalBinary.
+func (p *Proof) binarySize() int {
+	size := len(p.LeafHash)
+	if size == 0 || size > 0xff || len(p.Hash) > 0xff || len(p.Steps) > maxProofSteps || p.Index < 0 || p.Size < 0 {
+		return -1
+	}
+	if p.DigestSize != 0 && p.DigestSize != size {
+		return -1
+	}
+	if len(p.LeafPrefix) > 0xff || len(p.NodePrefix) > 0xff || len(p.PairHash) > 0xff || len(p.Salt) > 0xff || !p.Layout.valid() {
+		return -1
+	}
+
+	bitmap := (len(p.Steps) + 7) / 8
+	total := 4 + len(p.Hash) + bitmap + size
+	if p.Compressed() {
+		total += bitmap
+	}
+	if len(p.LeafPrefix) > 0 || len(p.NodePrefix) > 0 {
+		total += 2 + len(p.LeafPrefix) + len(p.NodePrefix)
+	}
+	if p.PairHash != "" {
+		total += 1 + len(p.PairHash)
+	}
+	if len(p.Salt) > 0 || p.Layout != LayoutLevels {
+		total += 1 + len(p.Salt)
+	}
+	if p.Layout != LayoutLevels {
+		total++
+	}
+	var varint [binary.MaxVarintLen64]byte
+	for _, v := range []int{p.Index, p.Size, len(p.Steps)} {
+		total += binary.PutUvarint(varint[:], uint64(v))
+	}
+	for _, s := range p.Steps {
+		if s.Hash != nil && len(s.Hash) != size {
+			return -1
+		}
+		total += len(s.Hash)
+	}
+
+	return total
+}
+
+// jsonSize mirrors the output of encoding/json for the fields of Proof.
+func (p *Proof) jsonSize() int {
+	name, _ := json.Marshal(p.Hash)
+
+	total := len(`{"Hash":`) + len(name) +
+		len(`,"DomainSeparation":`) + jsonBoolSize(p.DomainSeparation) +
+		len(`,"SortedPairs":`) + jsonBoolSize(p.SortedPairs) +
+		len(`,"Index":`) + len(strconv.Itoa(p.Index)) +
+		len(`,"Size":`) + len(strconv.Itoa(p.Size)) +
+		len(`,"LeafHash":`) + jsonBytesSize(p.LeafHash) +
+		len(`,"Steps":`) + len(`}`)
+	if p.CountCommitment {
+		total += len(`,"CountCommitment":true`)
+	}
+	if p.LengthPrefixedLeaves {
+		total += len(`,"LengthPrefixedLeaves":true`)
+	}
+	if len(p.LeafPrefix) > 0 {
+		total += len(`,"LeafPrefix":`) + jsonBytesSize(p.LeafPrefix)
+	}
+	if len(p.NodePrefix) > 0 {
+		total += len(`,"NodePrefix":`) + jsonBytesSize(p.NodePrefix)
+	}
+	if p.PairHash != "" {
+		pairHash, _ := json.Marshal(p.PairHash)
+		total += len(`,"PairHash":`) + len(pairHash)
+	}
+	if p.Keyed {
+		total += len(`,"Keyed":true`)
+	}
+	if p.DigestSize != 0 {
+		total += len(`,"DigestSize":`) + len(strconv.Itoa(p.DigestSize))
+	}
+	if len(p.Salt) > 0 {
+		total += len(`,"Salt":`) + jsonBytesSize(p.Salt)
+	}
+	if p.Layout != LayoutLevels {
+		total += len(`,"Layout":`) + len(strconv.Itoa(int(p.Layout)))
+	}
+	if p.Steps == nil {
+		return total + len(`null`)
+	}
+
+	total += len(`[]`)
+	for i, s := range p.Steps {
+		if i > 0 {
+			total += len(`,`)
+		}
+		total += len(`{"Hash":`) + jsonBytesSize(s.Hash) + len(`,"Left":`) + jsonBoolSize(s.Left) + len(`}`)
+	}
+
+	return total
+}
+
+func jsonBoolSize(b bool) int {
+	if b {
+		return len(`true`)
+	}
+
+	return len(`false`)
+}
+
+func jsonBytesSize(b []byte) int {
+	if b == nil {
+		return len(`null`)
+	}
+
+	return len(`""`) + base64.StdEncoding.EncodedLen(len(b))
+}
@@ -0,0 +1,152 @@
+package merklego
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofSize(t *testing.T) {
+	require.Equal(t, 0, ProofSize(0))
+	for n := 1; n <= 33; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			longest := 0
+			for i, leaf := range c.leaves {
+				p, err := c.tree.Prove(leaf)
+				require.NoError(t, err)
+				if c.name == "pointer" {
+					require.Len(t, p.Steps, ProofSize(n), "leaves:%d index:%d", n, i)
+				}
+				if len(p.Steps) > longest {
+					longest = len(p.Steps)
+				}
+			}
+			require.Equal(t, ProofSize(n), longest, "%s leaves:%d", c.name, n)
+		}
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		for _, c := range conformanceTrees(t, n) {
+			for i, leaf := range c.leaves {
+				p, err := c.tree.Prove(leaf)
+				require.NoError(t, err)
+
+				proofs := []*Proof{p}
+				if root, _ := c.tree.RootHash(); len(p.Steps) > 1 {
+					compressed, err := p.Compress([][]byte{root, p.Steps[len(p.Steps)-1].Hash})
+					require.NoError(t, err)
+					proofs = append(proofs, compressed)
+				}
+
+				for _, p := range proofs {
+					name := fmt.Sprintf("%s leaves:%d index:%d compressed:%v", c.name, n, i, p.Compressed())
+
+					data, err := json.Marshal(p)
+					require.NoError(t, err)
+					require.Equal(t, len(data), p.EncodedSize(ProofJSON), name)
+
+					data, err = p.MarshalBinary()
+					require.NoError(t, err)
+					require.Equal(t, len(data), p.EncodedSize(ProofBinary), name)
+
+					s, err := p.EncodeString()
+					require.NoError(t, err)
+					require.Equal(t, len(s), p.EncodedSize(ProofBase64), name)
+				}
+			}
+		}
+	}
+
+	odd := &Proof{Hash: "<sha\"256>", Index: 1 << 20, Size: 1<<20 + 1, LeafHash: []byte{1}}
+	data, err := json.Marshal(odd)
+	require.NoError(t, err)
+	require.Equal(t, len(data), odd.EncodedSize(ProofJSON))
+
+	invalid := &Proof{Hash: "sha256", Size: 1}
+	require.Equal(t, -1, invalid.EncodedSize(ProofBinary))
+	require.Equal(t, -1, invalid.EncodedSize(ProofBase64))
+	require.Equal(t, -1, odd.EncodedSize(ProofFormat(42)))
+}
+
+// coverSize counts the siblings of a multiproof of indices from the union of
+// the paths of the leaves, the padding duplicate included with the last one.
+func coverSize(leafCount int, indices []int) int {
+	n := leafCount + leafCount%2
+	paths := map[int]bool{}
+	for _, i := range indices {
+		leaves := []int{n - 1 + i}
+		if i == leafCount-1 && n != leafCount {
+			leaves = append(leaves, n-1+leafCount)
+		}
+		for _, pos := range leaves {
+			for ; pos > 0; pos = (pos - 1) / 2 {
+				paths[pos] = true
+			}
+		}
+	}
+
+	siblings := 0
+	for pos := range paths {
+		sibling := pos + 1
+		if pos%2 == 0 {
+			sibling = pos - 1
+		}
+		if !paths[sibling] {
+			siblings++
+		}
+	}
+
+	return siblings
+}
+
+func TestMultiProofSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 1; n <= 40; n++ {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize())
+
+		for start := 0; start < n; start++ {
+			for end := start + 1; end <= n; end++ {
+				p, err := tree.ProveRange(start, end)
+				require.NoError(t, err)
+
+				indices := make([]int, 0, end-start)
+				for i := start; i < end; i++ {
+					indices = append(indices, i)
+				}
+				require.Equal(t, len(p.Siblings), MultiProofSize(n, indices), "leaves:%d range:[%d, %d)", n, start, end)
+			}
+		}
+
+		for i := 0; i < n; i++ {
+			proof, err := tree.Proof(blocks[i])
+			require.NoError(t, err)
+			if i == n-1 && n%2 != 0 {
+				// The single proof carries the padding duplicate.
+				proof = proof[1:]
+			}
+			require.Equal(t, len(proof), MultiProofSize(n, []int{i, i}), "leaves:%d index:%d", n, i)
+		}
+
+		for k := 0; k < 50; k++ {
+			indices := make([]int, 1+rng.Intn(n))
+			for i := range indices {
+				indices[i] = rng.Intn(n)
+			}
+			require.Equal(t, coverSize(n, indices), MultiProofSize(n, indices), "leaves:%d indices:%v", n, indices)
+		}
+	}
+
+	require.Equal(t, 0, MultiProofSize(4, nil))
+	require.Equal(t, -1, MultiProofSize(4, []int{4}))
+	require.Equal(t, -1, MultiProofSize(4, []int{-1}))
+}
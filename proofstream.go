@@ -0,0 +1,164 @@
+package merklego
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ExportProofs writes the Proof of every block of the tree to w in insertion
+// order, one record per proof:
+//
+//	ProofJSON    the JSON encoding of the proof, then a newline
+//	ProofBinary  the length of MarshalBinary as a uvarint, then the encoding
+//	ProofBase64  EncodeString, then a newline
+//
+// Each proof is built and written before the next one, so only one is held
+// at a time whatever the size of the tree.
+func (mt *FlatMerkleTree) ExportProofs(w io.Writer, format ProofFormat) error {
+	if !mt.finalized {
+		return ErrTreeNotFinalized
+	}
+	if format != ProofJSON && format != ProofBinary && format != ProofBase64 {
+		return fmt.Errorf("unknown proof format %d", format)
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var varint [binary.MaxVarintLen64]byte
+	first := len(mt.nodes) - len(mt.blocks)
+	for i := 0; i < mt.count; i++ {
+		p := mt.proofAt(first + i)
+
+		var err error
+		switch format {
+		case ProofJSON:
+			err = enc.Encode(p)
+		case ProofBinary:
+			var data []byte
+			if data, err = p.MarshalBinary(); err == nil {
+				bw.Write(varint[:binary.PutUvarint(varint[:], uint64(len(data)))])
+				_, err = bw.Write(data)
+			}
+		case ProofBase64:
+			var s string
+			if s, err = p.EncodeString(); err == nil {
+				bw.WriteString(s)
+				err = bw.WriteByte('\n')
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("cannot export the proof of block %d: %w", i, err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadProofs decodes the records written by ExportProofs in format from r,
+// calling fn with each proof in turn. Every proof is checked with Validate.
+// It stops at the first error, returned as is when raised by fn, and returns
+// nil at the end of r.
+func ReadProofs(r io.Reader, format ProofFormat, fn func(p *Proof) error) error {
+	next, err := proofRecords(r, format)
+	if err != nil {
+		return err
+	}
+
+	for record := 0; ; record++ {
+		p, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read proof record %d: %w", record, err)
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+}
+
+// maxProofRecordSize bounds the length of a JSON record read by ReadProofs,
+// newline included. The JSON encoding of the largest proof MarshalBinary can
+// encode fits in it.
+const maxProofRecordSize = 1 << 16
+
+// proofRecords returns a function decoding the next record of r, which
+// returns io.EOF when r ends between records.
+func proofRecords(r io.Reader, format ProofFormat) (func() (*Proof, error), error) {
+	switch format {
+	case ProofJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxProofRecordSize)
+		return func() (*Proof, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+
+			p := &Proof{}
+			if err := p.UnmarshalJSON(scanner.Bytes()); err != nil {
+				return nil, err
+			}
+
+			return p, nil
+		}, nil
+	case ProofBinary:
+		br := bufio.NewReader(r)
+		return func() (*Proof, error) {
+			size, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+			if size > MaxProofStringLength {
+				return nil, fmt.Errorf("%w: %d byte record", ErrInvalidProofEncoding, size)
+			}
+
+			data := make([]byte, size)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return nil, noEOF(err)
+			}
+			p := &Proof{}
+			if err := p.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+
+			return p, nil
+		}, nil
+	case ProofBase64:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, MaxProofStringLength+1)
+		return func() (*Proof, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+
+			return DecodeProofString(scanner.Text())
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown proof format %d", format)
+}
+
+// noEOF turns the end of the input in the middle of a record into an
+// io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,90 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportProofs(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 16, 33} {
+		blocks := make([]Block, n)
+		for i := range blocks {
+			blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		}
+		tree := NewMerkleTree(blocks...)
+		require.NoError(t, tree.Finalize(WithSortedPairs(n%2 == 1)))
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+
+		for _, format := range []ProofFormat{ProofJSON, ProofBinary, ProofBase64} {
+			var buf bytes.Buffer
+			require.NoError(t, tree.ExportProofs(&buf, format))
+
+			size := 0
+			for i := range blocks {
+				p, err := tree.Prove(blocks[i])
+				require.NoError(t, err)
+				size += p.EncodedSize(format)
+				if format != ProofBinary {
+					size++
+				} else {
+					size += binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(p.EncodedSize(format)))
+				}
+			}
+			require.Equal(t, size, buf.Len(), "leaves:%d format:%d", n, format)
+
+			var read []*Proof
+			require.NoError(t, ReadProofs(bytes.NewReader(buf.Bytes()), format, func(p *Proof) error {
+				read = append(read, p)
+				return nil
+			}))
+			require.Len(t, read, n)
+			for i, p := range read {
+				want, err := tree.Prove(blocks[i])
+				require.NoError(t, err)
+				require.Equal(t, want, p)
+				require.NoError(t, p.Verify(root))
+			}
+
+			if n > 1 {
+				truncated := buf.Bytes()[:buf.Len()-3]
+				err := ReadProofs(bytes.NewReader(truncated), format, func(*Proof) error { return nil })
+				require.Error(t, err, "leaves:%d format:%d", n, format)
+
+				stop := errors.New("stop")
+				calls := 0
+				err = ReadProofs(bytes.NewReader(buf.Bytes()), format, func(*Proof) error {
+					calls++
+					return stop
+				})
+				require.Equal(t, stop, err)
+				require.Equal(t, 1, calls)
+			}
+		}
+	}
+}
+
+func TestExportProofsErrors(t *testing.T) {
+	tree := NewMerkleTree(Block("a"), Block("b"))
+	require.ErrorIs(t, tree.ExportProofs(io.Discard, ProofJSON), ErrTreeNotFinalized)
+	require.NoError(t, tree.Finalize())
+	require.Error(t, tree.ExportProofs(io.Discard, ProofFormat(42)))
+	require.Error(t, ReadProofs(strings.NewReader(""), ProofFormat(42), func(*Proof) error { return nil }))
+
+	require.NoError(t, ReadProofs(strings.NewReader(""), ProofBinary, func(*Proof) error {
+		t.Fatal("error: unexpected record")
+		return nil
+	}))
+
+	require.ErrorIs(t, ReadProofs(bytes.NewReader([]byte{0xff, 0xff, 0x03}), ProofBinary, func(*Proof) error { return nil }), ErrInvalidProofEncoding)
+	require.ErrorIs(t, ReadProofs(bytes.NewReader([]byte{4, 1, 2}), ProofBinary, func(*Proof) error { return nil }), io.ErrUnexpectedEOF)
+	require.Error(t, ReadProofs(strings.NewReader(`{"Hash":"sha256","Size":0}`+"\n"), ProofJSON, func(*Proof) error { return nil }))
+	require.Error(t, ReadProofs(strings.NewReader("!!\n"), ProofBase64, func(*Proof) error { return nil }))
+}
@@ -0,0 +1,144 @@
+// Package merkleproto holds the protobuf messages of merkle-go proofs and
+// roots, generated from merkle.proto, and converters from and to the types of
+// the core package. It is a separate package so the core does not depend on
+// protobuf.
+package merkleproto
+
+import (
+	"errors"
+	"fmt"
+
+	merklego "github.com/evalir/merkle-go"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative merkle.proto
+
+// ErrInvalidMessage is returned when converting a message that does not hold
+// a valid proof.
+var ErrInvalidMessage = errors.New("error: invalid merkle proof message")
+
+// ProofToProto converts p into its wire message. All the hashes of p must be
+// as long as its leaf hash. Proofs with CountCommitment, LengthPrefixedLeaves,
+// prefixes, a PairHash, Keyed, a Salt, a DigestSize or another Layout than
+// merklego.LayoutLevels are refused, the message having no field for them.
+func ProofToProto(p *merklego.Proof) (*MerkleProof, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if p.CountCommitment {
+		return nil, fmt.Errorf("error: cannot convert a proof committing to its leaf count")
+	}
+	if p.LengthPrefixedLeaves {
+		return nil, fmt.Errorf("error: cannot convert a proof of length prefixed leaves")
+	}
+	if len(p.LeafPrefix) > 0 || len(p.NodePrefix) > 0 {
+		return nil, fmt.Errorf("error: cannot convert a proof with custom prefixes")
+	}
+	if p.PairHash != "" {
+		return nil, fmt.Errorf("error: cannot convert a proof of pair hash %q", p.PairHash)
+	}
+	if p.Keyed {
+		return nil, fmt.Errorf("error: cannot convert a proof of an HMAC-keyed tree")
+	}
+	if len(p.Salt) > 0 {
+		return nil, fmt.Errorf("error: cannot convert a proof of a salted leaf")
+	}
+	if p.DigestSize != 0 {
+		return nil, fmt.Errorf("error: cannot convert a proof of truncated hashes")
+	}
+	if p.Layout != merklego.LayoutLevels {
+		return nil, fmt.Errorf("error: cannot convert a proof of layout %d", p.Layout)
+	}
+
+	m := &MerkleProof{
+		Hash:             p.Hash,
+		DomainSeparation: p.DomainSeparation,
+		SortedPairs:      p.SortedPairs,
+		Index:            uint64(p.Index),
+		Size:             uint64(p.Size),
+		LeafHash:         append([]byte(nil), p.LeafHash...),
+		Siblings:         make([][]byte, len(p.Steps)),
+		Directions:       make([]byte, (len(p.Steps)+7)/8),
+	}
+	for i, s := range p.Steps {
+		if len(s.Hash) != len(p.LeafHash) {
+			return nil, fmt.Errorf("error: cannot convert step %d: %d byte hash in a proof of %d byte hashes", i, len(s.Hash), len(p.LeafHash))
+		}
+
+		m.Siblings[i] = append([]byte(nil), s.Hash...)
+		if s.Left {
+			m.Directions[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return m, nil
+}
+
+// ProofFromProto converts m into a proof, checking that its hashes share one
+// length, that its directions cover exactly its siblings and that the proof
+// is structurally valid, within the default merklego.DecodeOptions. A proof
+// naming a hash function that is not registered is refused with a
+// *merklego.UnknownHashError.
+func ProofFromProto(m *MerkleProof) (*merklego.Proof, error) {
+	size := len(m.GetLeafHash())
+	if size == 0 {
+		return nil, fmt.Errorf("%w: empty leaf hash", ErrInvalidMessage)
+	}
+
+	o := merklego.DecodeOptions{}.WithDefaults()
+	if err := o.Check("MaxHashSize", uint64(size), o.MaxHashSize, ErrInvalidMessage); err != nil {
+		return nil, err
+	}
+
+	steps := len(m.GetSiblings())
+	if err := o.Check("MaxProofSteps", uint64(steps), o.MaxProofSteps, ErrInvalidMessage); err != nil {
+		return nil, err
+	}
+	if len(m.GetDirections()) != (steps+7)/8 {
+		return nil, fmt.Errorf("%w: %d direction bytes for %d siblings", ErrInvalidMessage, len(m.GetDirections()), steps)
+	}
+	if steps%8 != 0 && m.Directions[len(m.Directions)-1]>>(steps%8) != 0 {
+		return nil, fmt.Errorf("%w: unused direction bits set", ErrInvalidMessage)
+	}
+
+	const maxInt = int(^uint(0) >> 1)
+	if m.GetIndex() > uint64(maxInt) || m.GetSize() > uint64(maxInt) {
+		return nil, fmt.Errorf("%w: index %d out of %d leaves", ErrInvalidMessage, m.GetIndex(), m.GetSize())
+	}
+	if err := o.Check("MaxTreeSize", m.GetSize(), o.MaxTreeSize, ErrInvalidMessage); err != nil {
+		return nil, err
+	}
+	if m.GetHash() != "" {
+		if _, err := merklego.LookupHash(m.GetHash()); err != nil {
+			return nil, &merklego.UnknownHashError{Name: m.GetHash(), Err: ErrInvalidMessage}
+		}
+	}
+
+	p := &merklego.Proof{
+		Hash:             m.GetHash(),
+		DomainSeparation: m.GetDomainSeparation(),
+		SortedPairs:      m.GetSortedPairs(),
+		Index:            int(m.GetIndex()),
+		Size:             int(m.GetSize()),
+		LeafHash:         append([]byte(nil), m.GetLeafHash()...),
+	}
+	if steps > 0 {
+		p.Steps = make([]merklego.ProofStep, steps)
+	}
+	for i, sibling := range m.GetSiblings() {
+		if len(sibling) != size {
+			return nil, fmt.Errorf("%w: %d byte sibling %d in a proof of %d byte hashes", ErrInvalidMessage, len(sibling), i, size)
+		}
+
+		p.Steps[i] = merklego.ProofStep{
+			Hash: append([]byte(nil), sibling...),
+			Left: m.Directions[i/8]&(1<<(i%8)) != 0,
+		}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
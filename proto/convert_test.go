@@ -0,0 +1,202 @@
+package merkleproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	merklego "github.com/evalir/merkle-go"
+	"google.golang.org/protobuf/proto"
+)
+
+func testTree(t *testing.T, n int) *merklego.MerkleTree {
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("leaf-%d", i))
+	}
+
+	tree, err := merklego.NewTreeFromBytes(items, merklego.WithDomainSeparation(true))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	return tree
+}
+
+func TestProofRoundTrip(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		tree := testTree(t, n)
+		for i, l := range tree.RealLeaves() {
+			p, err := tree.Prove(l.Hash)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+
+			m, err := ProofToProto(p)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+			data, err := proto.Marshal(m)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+
+			var decoded MerkleProof
+			if err := proto.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+			back, err := ProofFromProto(&decoded)
+			if err != nil {
+				t.Fatalf("[leaves:%d index:%d] error: unexpected error: %v", n, i, err)
+			}
+			if !reflect.DeepEqual(back, p) {
+				t.Errorf("[leaves:%d index:%d] error: expected %+v got %+v", n, i, p, back)
+			}
+			if err := back.Verify(tree.MerkleRoot()); err != nil {
+				t.Errorf("[leaves:%d index:%d] error: expected valid proof got %v", n, i, err)
+			}
+		}
+	}
+}
+
+// The field numbers of the messages are a wire contract shared with other
+// languages; these fixtures must never change.
+func TestWireFixtures(t *testing.T) {
+	p := &merklego.Proof{
+		Hash:             "sha256",
+		DomainSeparation: true,
+		Index:            2,
+		Size:             3,
+		LeafHash:         bytes.Repeat([]byte{0xaa}, 4),
+		Steps: []merklego.ProofStep{
+			{Hash: bytes.Repeat([]byte{0xbb}, 4)},
+			{Hash: bytes.Repeat([]byte{0xcc}, 4), Left: true},
+		},
+	}
+	m, err := ProofToProto(p)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	root := &SignedRoot{Root: []byte{1, 2}, Size: 3, Hash: "sha256", Signature: []byte{3}, KeyId: "k"}
+
+	for name, fixture := range map[string]struct {
+		msg  proto.Message
+		want string
+	}{
+		"proof": {m, "0a067368613235361001200228033204aaaaaaaa3a04bbbbbbbb3a04cccccccc420102"},
+		"root":  {root, "0a02010210031a067368613235362201032a016b"},
+	} {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(fixture.msg)
+		if err != nil {
+			t.Fatalf("[%s] error: unexpected error: %v", name, err)
+		}
+		if got := hex.EncodeToString(data); got != fixture.want {
+			t.Errorf("[%s] error: expected wire bytes %s got %s", name, fixture.want, got)
+		}
+	}
+}
+
+func TestProofFromProtoInvalid(t *testing.T) {
+	valid := func() *MerkleProof {
+		tree := testTree(t, 5)
+		p, _ := tree.Prove(tree.Leaves[4].Hash)
+		m, _ := ProofToProto(p)
+		return m
+	}
+
+	for name, mutate := range map[string]func(m *MerkleProof){
+		"leafHash":   func(m *MerkleProof) { m.LeafHash = nil },
+		"sibling":    func(m *MerkleProof) { m.Siblings[1] = m.Siblings[1][:5] },
+		"directions": func(m *MerkleProof) { m.Directions = append(m.Directions, 0) },
+		"unusedBits": func(m *MerkleProof) { m.Directions[0] |= 0x80 },
+		"index":      func(m *MerkleProof) { m.Index = m.Size },
+		"steps": func(m *MerkleProof) {
+			m.Size = 2
+			m.Index = 0
+		},
+	} {
+		m := valid()
+		mutate(m)
+
+		_, err := ProofFromProto(m)
+		var invalid *merklego.InvalidProofError
+		if !errors.Is(err, ErrInvalidMessage) && !errors.As(err, &invalid) {
+			t.Errorf("[%s] error: expected an invalid message error got %v", name, err)
+		}
+	}
+}
+
+func TestProofFromProtoLimits(t *testing.T) {
+	tree := testTree(t, 5)
+	p, _ := tree.Prove(tree.Leaves[4].Hash)
+
+	for limit, mutate := range map[string]func(m *MerkleProof){
+		"MaxHashSize": func(m *MerkleProof) { m.LeafHash = make([]byte, merklego.DefaultMaxHashSize+1) },
+		"MaxProofSteps": func(m *MerkleProof) {
+			m.Siblings = make([][]byte, merklego.DefaultMaxProofSteps+1)
+			m.Directions = make([]byte, (len(m.Siblings)+7)/8)
+		},
+		"MaxTreeSize": func(m *MerkleProof) { m.Size = merklego.DefaultMaxTreeSize + 1 },
+	} {
+		m, _ := ProofToProto(p)
+		mutate(m)
+
+		_, err := ProofFromProto(m)
+		var target *merklego.DecodeLimitError
+		if !errors.As(err, &target) || target.Limit != limit || !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("[%s] error: expected a %s limit error got %v", limit, limit, err)
+		}
+	}
+}
+
+func FuzzProofFromProto(f *testing.F) {
+	for n := 1; n <= 5; n++ {
+		tree, _ := merklego.NewTreeFromBytes([][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}[:n])
+		p, _ := tree.Prove(tree.Leaves[n-1].Hash)
+		m, _ := ProofToProto(p)
+		data, _ := proto.Marshal(m)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var m MerkleProof
+		if err := proto.Unmarshal(data, &m); err != nil {
+			return
+		}
+
+		p, err := ProofFromProto(&m)
+		if err != nil {
+			var invalid *merklego.InvalidProofError
+			if !errors.Is(err, ErrInvalidMessage) && !errors.As(err, &invalid) {
+				t.Fatalf("error: expected an invalid message error got %v", err)
+			}
+			return
+		}
+
+		if _, err := ProofToProto(p); err != nil {
+			t.Fatalf("error: cannot encode a decoded proof: %v", err)
+		}
+	})
+}
+
+func TestUnknownHash(t *testing.T) {
+	tree := testTree(t, 4)
+	p, err := tree.Prove(tree.Leaves[1].Hash)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	m, err := ProofToProto(p)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	m.Hash = "blake3"
+	var unknown *merklego.UnknownHashError
+	if _, err := ProofFromProto(m); !errors.As(err, &unknown) || !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("error: expected an unknown hash error got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package merklego
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// RangeProof proves that a contiguous range of blocks belongs to a
+// FlatMerkleTree. It carries the leaf hashes of the range and the hashes of
+// the nodes bordering it, which are only found along the authentication paths
+// of its first and last leaves, so it holds O(k + log n) hashes for k of n
+// blocks.
+type RangeProof struct {
+	// Size is the number of blocks of the tree.
+	Size                 int
+	SortedPairs          bool
+	SingleLeafRoot       bool
+	DistinctPadding      bool
+	LengthPrefixedLeaves bool
+	// LeafPrefix and NodePrefix are those of the tree, nil for the defaults.
+	LeafPrefix, NodePrefix []byte
+	// PairHash names the pair hash of the tree, as Proof.PairHash does.
+	PairHash string
+	// Hash names the hash function of the tree as registered with
+	// RegisterHash, empty for SHA-256 and "?" for an unregistered one.
+	Hash string
+	// Keyed is set for trees finalized with WithHMACKey, whose key the
+	// proof does not carry.
+	Keyed bool
+
+	LeafHashes [][]byte
+	// Siblings are the hashes of the nodes outside the range needed to
+	// rebuild the root, in the order VerifyRange consumes them.
+	Siblings [][]byte
+}
+
+// ProveRange returns the RangeProof of the blocks inserted at indexes start to
+// end, end excluded.
+func (mt *FlatMerkleTree) ProveRange(start, end int) (*RangeProof, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if mt.salted() {
+		return nil, ErrSaltedTree
+	}
+	if start < 0 || end > mt.count || start >= end {
+		return nil, fmt.Errorf("invalid block range [%d, %d) of %d blocks", start, end, mt.count)
+	}
+
+	first := len(mt.nodes) - len(mt.blocks)
+	p := &RangeProof{
+		Size:                 mt.count,
+		SortedPairs:          mt.sortedPairs,
+		SingleLeafRoot:       mt.singleLeafRoot,
+		DistinctPadding:      mt.distinctPadding,
+		LengthPrefixedLeaves: mt.lengthPrefixedLeaves,
+		LeafPrefix:           clonePrefix(mt.leafPrefix),
+		NodePrefix:           clonePrefix(mt.nodePrefix),
+		PairHash:             pairHashName(mt.pairHash),
+		Hash:                 mt.hashID(),
+		Keyed:                mt.keyed(),
+		LeafHashes:           make([][]byte, end-start),
+	}
+	for i := range p.LeafHashes {
+		p.LeafHashes[i] = copyNode(mt.nodes[first+start+i])
+	}
+
+	leaves := mt.padRange(p.LeafHashes, end, mt.count, len(mt.blocks))
+	_, err := mt.rangeRoot(len(mt.blocks), start, leaves, func(pos int) (TreeNode, error) {
+		p.Siblings = append(p.Siblings, copyNode(mt.nodes[pos]))
+		return mt.nodes[pos], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// VerifyRange checks that leaves are the blocks at indexes start onwards of
+// the FlatMerkleTree with the given root, rebuilding the root from them and
+// p. It returns an error wrapping ErrInvalidProof when they are not. The key
+// of a keyed tree is given in opts with WithHMACKey.
+func VerifyRange(root []byte, start int, leaves [][]byte, p *RangeProof, opts ...Option) error {
+	if len(leaves) == 0 || len(leaves) != len(p.LeafHashes) {
+		return fmt.Errorf("invalid range proof: %d leaves for %d leaf hashes: %w", len(leaves), len(p.LeafHashes), ErrInvalidProof)
+	}
+	if start < 0 || start+len(leaves) > p.Size {
+		return fmt.Errorf("invalid range proof: range [%d, %d) of %d blocks: %w", start, start+len(leaves), p.Size, ErrInvalidProof)
+	}
+
+	mt := &FlatMerkleTree{}
+	mt.sortedPairs, mt.singleLeafRoot, mt.distinctPadding = p.SortedPairs, p.SingleLeafRoot, p.DistinctPadding
+	mt.lengthPrefixedLeaves, mt.leafPrefix, mt.nodePrefix = p.LengthPrefixedLeaves, p.LeafPrefix, p.NodePrefix
+	pairHash, err := pairHashOption(p.PairHash)
+	if err != nil {
+		return fmt.Errorf("invalid range proof: %v: %w", err, ErrInvalidProof)
+	}
+	pairHash(&mt.config)
+	hash, err := hashOption(p.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid range proof: %v: %w", err, ErrInvalidProof)
+	}
+	hash(&mt.config)
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkBinary(); err != nil {
+		return err
+	}
+	if mt.keyed() != p.Keyed {
+		return fmt.Errorf("invalid range proof: %v: %w", ErrProofKeyed, ErrInvalidProof)
+	}
+
+	for i, leaf := range leaves {
+		if leaf == nil {
+			return ErrNilBlock
+		}
+		if !RootEqual(mt.hashLeaf(leaf), p.LeafHashes[i]) {
+			return fmt.Errorf("invalid range proof for block %X: leaf hash mismatch: %w", leaf, ErrInvalidProof)
+		}
+	}
+
+	n := p.Size
+	if n%2 != 0 && !(n == 1 && p.SingleLeafRoot) {
+		n++
+	}
+
+	next := 0
+	computed, err := mt.rangeRoot(n, start, mt.padRange(p.LeafHashes, start+len(leaves), p.Size, n), func(int) (TreeNode, error) {
+		if next == len(p.Siblings) {
+			return nil, fmt.Errorf("invalid range proof: missing siblings: %w", ErrInvalidProof)
+		}
+		next++
+
+		return p.Siblings[next-1], nil
+	})
+	if err != nil {
+		return err
+	}
+	if next != len(p.Siblings) {
+		return fmt.Errorf("invalid range proof: %d unused siblings: %w", len(p.Siblings)-next, ErrInvalidProof)
+	}
+
+	if !RootEqual(computed, root) {
+		return fmt.Errorf("invalid range proof; got: %X, want: %X: %w", computed.Bytes(), root, ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// padRange appends the padding leaf to the leaf hashes of a range ending at
+// end when the tree of size blocks was padded to n leaves and the range holds
+// its last block.
+func (mt *FlatMerkleTree) padRange(leaves [][]byte, end, size, n int) [][]byte {
+	if end != size || n == size {
+		return leaves
+	}
+
+	return append(leaves[:len(leaves):len(leaves)], mt.paddingLeaf(leaves[len(leaves)-1]))
+}
+
+// rangeRoot rebuilds the root of a tree of n leaves, padding included, from
+// the hashes of the leaves at indexes start onwards, as coverRoot does.
+func (mt *FlatMerkleTree) rangeRoot(n, start int, leaves [][]byte, sibling func(pos int) (TreeNode, error)) (TreeNode, error) {
+	hashes := make(map[int]TreeNode, len(leaves))
+	for i, leaf := range leaves {
+		hashes[n-1+start+i] = leaf
+	}
+
+	return mt.coverRoot(hashes, sibling)
+}
+
+// coverRoot rebuilds the root from the hashes of the nodes at the positions
+// of known. The nodes are stored as a binary heap, as in Finalize, and hashed
+// deepest position first, every node outside the union of the paths of known
+// whose hash is needed being asked to sibling. known must not hold a node
+// along with one of its ancestors, and is filled with the nodes computed.
+func (mt *FlatMerkleTree) coverRoot(known map[int]TreeNode, sibling func(pos int) (TreeNode, error)) (TreeNode, error) {
+	hashes := known
+	queue := make(positionHeap, 0, len(known))
+	for pos := range known {
+		queue = append(queue, pos)
+	}
+	heap.Init(&queue)
+
+	for {
+		pos := heap.Pop(&queue).(int)
+		if pos == 0 {
+			return hashes[0], nil
+		}
+
+		// A left sibling in the cover is the next deepest position; a right
+		// one would have been taken before pos.
+		var other TreeNode
+		if pos%2 == 0 && len(queue) > 0 && queue[0] == pos-1 {
+			other = hashes[heap.Pop(&queue).(int)]
+		} else {
+			var err error
+			if pos%2 == 0 {
+				other, err = sibling(pos - 1)
+			} else {
+				other, err = sibling(pos + 1)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		parent := (pos - 1) / 2
+		if pos%2 == 0 {
+			hashes[parent] = mt.hashChildren(other, hashes[pos])
+		} else {
+			hashes[parent] = mt.hashChildren(hashes[pos], other)
+		}
+		heap.Push(&queue, parent)
+	}
+}
+
+// positionHeap is a max-heap of node positions.
+type positionHeap []int
+
+func (h positionHeap) Len() int            { return len(h) }
+func (h positionHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h positionHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+
+func (h *positionHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
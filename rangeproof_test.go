@@ -0,0 +1,119 @@
+package merklego
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func rangeTree(t *testing.T, n int, opts ...Option) (*FlatMerkleTree, [][]byte) {
+	blocks := make([]Block, n)
+	leaves := make([][]byte, n)
+	for i := range blocks {
+		blocks[i] = Block(fmt.Sprintf("block-%d", i))
+		leaves[i] = blocks[i]
+	}
+
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(opts...))
+
+	return mt, leaves
+}
+
+func TestRangeProofAllRanges(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		for _, sorted := range []bool{false, true} {
+			mt, leaves := rangeTree(t, n, WithSortedPairs(sorted))
+			root, err := mt.RootHash()
+			require.NoError(t, err)
+
+			depth := 0
+			for 1<<depth < len(mt.nodes) {
+				depth++
+			}
+
+			for start := 0; start < n; start++ {
+				for end := start + 1; end <= n; end++ {
+					msg := fmt.Sprintf("blocks: %d, sorted: %v, range: [%d, %d)", n, sorted, start, end)
+
+					p, err := mt.ProveRange(start, end)
+					require.NoError(t, err, msg)
+					require.Len(t, p.LeafHashes, end-start, msg)
+					require.LessOrEqual(t, len(p.Siblings), 2*depth, msg)
+					require.NoError(t, VerifyRange(root, start, leaves[start:end], p), msg)
+				}
+			}
+		}
+	}
+}
+
+func TestRangeProofCases(t *testing.T) {
+	testCases := []struct {
+		name       string
+		blocks     int
+		start, end int
+	}{
+		{name: "aligned", blocks: 16, start: 8, end: 16},
+		{name: "aligned inner subtree", blocks: 32, start: 4, end: 8},
+		{name: "unaligned", blocks: 20, start: 3, end: 11},
+		{name: "padded tail", blocks: 21, start: 17, end: 21},
+		{name: "padded last block", blocks: 21, start: 20, end: 21},
+		{name: "whole tree", blocks: 21, start: 0, end: 21},
+		{name: "large", blocks: 5000, start: 1000, end: 2000},
+	}
+
+	for _, tc := range testCases {
+		mt, leaves := rangeTree(t, tc.blocks)
+		root, _ := mt.RootHash()
+
+		p, err := mt.ProveRange(tc.start, tc.end)
+		require.NoError(t, err, tc.name)
+		require.NoError(t, VerifyRange(root, tc.start, leaves[tc.start:tc.end], p), tc.name)
+
+		// The leaves are checked against the proof and their positions.
+		require.Error(t, VerifyRange(root, tc.start+1, leaves[tc.start:tc.end], p), tc.name)
+		tampered := append([][]byte(nil), leaves[tc.start:tc.end]...)
+		tampered[len(tampered)-1] = []byte("other")
+		require.True(t, errors.Is(VerifyRange(root, tc.start, tampered, p), ErrInvalidProof), tc.name)
+
+		if len(p.LeafHashes) > 1 {
+			forged := *p
+			forged.LeafHashes = append([][]byte(nil), p.LeafHashes...)
+			forged.LeafHashes[0] = p.LeafHashes[len(p.LeafHashes)-1]
+			require.Error(t, VerifyRange(root, tc.start, leaves[tc.start:tc.end], &forged), tc.name)
+		}
+
+		if len(p.Siblings) > 0 {
+			short := *p
+			short.Siblings = p.Siblings[1:]
+			require.True(t, errors.Is(VerifyRange(root, tc.start, leaves[tc.start:tc.end], &short), ErrInvalidProof), tc.name)
+		}
+		long := *p
+		long.Siblings = append(append([][]byte(nil), p.Siblings...), root)
+		require.True(t, errors.Is(VerifyRange(root, tc.start, leaves[tc.start:tc.end], &long), ErrInvalidProof), tc.name)
+	}
+}
+
+func TestRangeProofErrors(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"))
+	_, err := mt.ProveRange(0, 1)
+	require.Equal(t, ErrTreeNotFinalized, err)
+	require.NoError(t, mt.Finalize())
+
+	for _, r := range [][2]int{{-1, 1}, {0, 4}, {2, 2}, {2, 1}} {
+		_, err := mt.ProveRange(r[0], r[1])
+		require.Error(t, err, fmt.Sprintf("range: %v", r))
+	}
+
+	single, leaves := rangeTree(t, 1, WithSingleLeafRoot(true))
+	root, _ := single.RootHash()
+	p, err := single.ProveRange(0, 1)
+	require.NoError(t, err)
+	require.Empty(t, p.Siblings)
+	require.NoError(t, VerifyRange(root, 0, leaves, p))
+
+	p.SingleLeafRoot = false
+	require.Error(t, VerifyRange(root, 0, leaves, p))
+}
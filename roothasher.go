@@ -0,0 +1,177 @@
+package merklego
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// RootHasher computes the root of a FlatMerkleTree from its leaves, written
+// one at a time, without building the tree: it only keeps the roots of the
+// complete subtrees not merged yet, at most two per level, so the root of n
+// leaves takes O(log n) memory instead of the 2n nodes of the tree. It gives
+// no proofs.
+//
+// The nodes of a FlatMerkleTree are stored as a binary heap whose leaves fill
+// the last slots: unless their count is a power of two, the first leaves sit
+// one level above the others, right of them, and where a leaf goes depends on
+// how many there are. NewRootHasher is thus given the number of leaves to
+// come, and Sum returns the root once they are all written.
+type RootHasher struct {
+	// mt carries the configuration of the tree and its hashing helpers; it is
+	// never finalized.
+	mt    *FlatMerkleTree
+	size  int
+	count int
+	// shallow is the number of leaves one level above the others, the first
+	// ones, and offset the position of the first of them on that level.
+	shallow int
+	offset  int
+	// front holds the pending subtrees of the shallow leaves, back the ones of
+	// the others, left to right.
+	front []pendingNode
+	back  []pendingNode
+	last  TreeNode
+}
+
+// pendingNode is the root of the complete subtree of the leaves index*2^level
+// to (index+1)*2^level of the heap, counted on its deepest level.
+type pendingNode struct {
+	level int
+	index int
+	hash  TreeNode
+}
+
+// NewRootHasher returns a RootHasher of the root of a FlatMerkleTree of size
+// blocks finalized with opts. Options that need every block at once,
+// WithSortedLeaves, WithRejectDuplicates and WithRandomSalts, are refused, as
+// are branching factors other than 2; salted blocks are written with
+// WriteLeafHash.
+func NewRootHasher(size int, opts ...Option) (*RootHasher, error) {
+	if size <= 0 {
+		return nil, ErrEmptyMerkleTree
+	}
+
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+	if err := mt.checkStreamed(); err != nil {
+		return nil, err
+	}
+
+	r := &RootHasher{mt: mt, size: size}
+	if width := size + size%2; !(size == 1 && mt.singleLeafRoot) {
+		// The heap of the 2*width-1 nodes is depth levels deep, and its last
+		// level holds 2*width-2^depth leaves, the others being on the level
+		// above, right of the width-2^(depth-1) parents of the deepest ones.
+		depth := bits.Len(uint(2*width-1)) - 1
+		r.shallow = 1<<uint(depth) - width
+		r.offset = width - 1<<uint(depth-1)
+	}
+
+	return r, nil
+}
+
+// checkStreamed returns an error for the settings a tree hashed a block at a
+// time cannot have: branching factors other than 2, and options that need
+// every block at once.
+func (c *config) checkStreamed() error {
+	if err := c.checkBinary(); err != nil {
+		return err
+	}
+	if c.sortedLeaves || c.rejectDuplicates || c.randomSalts > 0 {
+		return fmt.Errorf("blocks hashed as they are read cannot be sorted, deduplicated or salted")
+	}
+
+	return nil
+}
+
+// Size returns the number of leaves of the tree.
+func (r *RootHasher) Size() int {
+	return r.size
+}
+
+// Count returns the number of leaves written so far.
+func (r *RootHasher) Count() int {
+	return r.count
+}
+
+// Write hashes leaf into the leaf hash of the block at the next index, as
+// Insert then Finalize do, and writes it.
+func (r *RootHasher) Write(leaf Block) error {
+	if leaf == nil {
+		return ErrNilBlock
+	}
+
+	return r.WriteLeafHash(r.mt.hashLeaf(leaf))
+}
+
+// WriteLeafHash writes h, the leaf hash of the block at the next index, such as
+// the one of a salted block.
+func (r *RootHasher) WriteLeafHash(h []byte) error {
+	if len(h) == 0 {
+		return fmt.Errorf("empty leaf hash")
+	}
+	if r.count == r.size {
+		return fmt.Errorf("leaf %d written to a root hasher of %d leaves", r.count, r.size)
+	}
+
+	r.push(r.count, copyNode(h))
+	r.count++
+	if r.count == r.size && r.size%2 == 1 && !(r.size == 1 && r.mt.singleLeafRoot) {
+		r.push(r.size, r.mt.paddingLeaf(r.last))
+	}
+
+	return nil
+}
+
+// push adds leaf, the leaf at index of the padded blocks, to its pending
+// subtrees.
+func (r *RootHasher) push(index int, leaf TreeNode) {
+	r.last = leaf
+	if index < r.shallow {
+		r.front = r.merge(r.front, pendingNode{level: 1, index: r.offset + index, hash: leaf})
+		return
+	}
+
+	r.back = r.merge(r.back, pendingNode{index: index - r.shallow, hash: leaf})
+}
+
+// merge appends node to the pending subtrees of pending, hashing the last two
+// into their parent as long as they are siblings.
+func (r *RootHasher) merge(pending []pendingNode, node pendingNode) []pendingNode {
+	pending = append(pending, node)
+	for n := len(pending); n >= 2; n = len(pending) {
+		left, right := pending[n-2], pending[n-1]
+		if left.level != right.level || left.index%2 != 0 || right.index != left.index+1 {
+			break
+		}
+		pending[n-2] = pendingNode{
+			level: left.level + 1,
+			index: left.index / 2,
+			hash:  r.mt.hashChildren(left.hash, right.hash),
+		}
+		pending = pending[:n-1]
+	}
+
+	return pending
+}
+
+// Sum returns the root of the tree, once all its leaves are written. It does
+// not change the state of the hasher.
+func (r *RootHasher) Sum() ([]byte, error) {
+	if r.count < r.size {
+		return nil, fmt.Errorf("invalid root hash: %d of %d leaves written", r.count, r.size)
+	}
+
+	// The deepest leaves are left of the shallow ones.
+	pending := append([]pendingNode(nil), r.back...)
+	for _, node := range r.front {
+		pending = r.merge(pending, node)
+	}
+	if len(pending) != 1 {
+		return nil, fmt.Errorf("invalid root hash: %d subtrees left unmerged", len(pending))
+	}
+
+	return copyNode(pending[0].hash).Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package merklego
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootHasherMatchesTree(t *testing.T) {
+	optionSets := map[string][]Option{
+		"default":          nil,
+		"distinct padding": {WithDistinctPadding(true)},
+		"single leaf root": {WithSingleLeafRoot(true)},
+		"sorted pairs":     {WithSortedPairs(true)},
+		"length prefixed":  {WithLengthPrefixedLeaves(true), WithLeafPrefix([]byte("leaf")), WithNodePrefix([]byte("node"))},
+		"sha512 hmac":      {WithHashStrategy(sha512.New), WithHMACKey([]byte("key"))},
+	}
+
+	for name, opts := range optionSets {
+		for n := 1; n <= 130; n++ {
+			tree := NewMerkleTree()
+			r, err := NewRootHasher(n, opts...)
+			require.NoError(t, err)
+			for i := 0; i < n; i++ {
+				block := Block(fmt.Sprintf("block %d", i))
+				require.NoError(t, tree.Insert(block))
+				require.NoError(t, r.Write(block))
+			}
+			require.NoError(t, tree.Finalize(opts...))
+
+			want, err := tree.RootHash()
+			require.NoError(t, err)
+			got, err := r.Sum()
+			require.NoError(t, err)
+			require.Equal(t, want, got, "%s, %d leaves", name, n)
+		}
+	}
+}
+
+func TestRootHasherLeafHashes(t *testing.T) {
+	const n = 37
+	tree := NewMerkleTree()
+	for i := 0; i < n; i++ {
+		require.NoError(t, tree.InsertSalted(Block(fmt.Sprintf("block %d", i)), []byte{byte(i), 's'}))
+	}
+	require.NoError(t, tree.Finalize())
+
+	r, err := NewRootHasher(n)
+	require.NoError(t, err)
+	first := len(tree.nodes) - len(tree.blocks)
+	for i := 0; i < n; i++ {
+		require.NoError(t, r.WriteLeafHash(tree.nodes[first+i]))
+	}
+	want, err := tree.RootHash()
+	require.NoError(t, err)
+	got, err := r.Sum()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestRootHasherErrors(t *testing.T) {
+	_, err := NewRootHasher(0)
+	require.ErrorIs(t, err, ErrEmptyMerkleTree)
+	_, err = NewRootHasher(4, WithBranchingFactor(4))
+	require.ErrorIs(t, err, ErrBranchingFactor)
+	for _, opt := range []Option{WithSortedLeaves(true), WithRejectDuplicates(true), WithRandomSalts(16)} {
+		_, err = NewRootHasher(4, opt)
+		require.Error(t, err)
+	}
+
+	r, err := NewRootHasher(3)
+	require.NoError(t, err)
+	require.ErrorIs(t, r.Write(nil), ErrNilBlock)
+	require.Error(t, r.WriteLeafHash(nil))
+	require.NoError(t, r.Write(Block("a")))
+	require.NoError(t, r.Write(Block("b")))
+	_, err = r.Sum()
+	require.Error(t, err)
+	require.Equal(t, 2, r.Count())
+
+	require.NoError(t, r.Write(Block("c")))
+	require.Error(t, r.Write(Block("d")))
+	first, err := r.Sum()
+	require.NoError(t, err)
+	again, err := r.Sum()
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+}
+
+func TestRootHasherMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams 10M leaves")
+	}
+
+	const n = 10_000_000
+	r, err := NewRootHasher(n)
+	require.NoError(t, err)
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	var block [8]byte
+	var peak uint64
+	for i := 0; i < n; i++ {
+		binary.BigEndian.PutUint64(block[:], uint64(i))
+		if err := r.Write(block[:]); err != nil {
+			t.Fatal(err)
+		}
+		if i%(n/20) == n/20-1 {
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > base && stats.HeapAlloc-base > peak {
+				peak = stats.HeapAlloc - base
+			}
+		}
+	}
+	root, err := r.Sum()
+	require.NoError(t, err)
+	require.Len(t, root, 32)
+	require.Less(t, peak, uint64(32<<10), "the hasher retained %d bytes", peak)
+}
@@ -0,0 +1,63 @@
+package merklego
+
+import "sort"
+
+// WithHeadHistory keeps the heads of the last k sizes of an IncrementalTree or
+// an MMR, recorded as leaves are appended, so that RootAtSize returns the
+// roots of those sizes without hashing; the roots of older sizes are
+// recomputed from the nodes the tree retains. Recording a head computes the
+// root, O(log n) hashes per Append. It has no effect on other trees.
+func WithHeadHistory(k int) Option {
+	return func(c *config) {
+		c.headHistory = k
+	}
+}
+
+// headRing holds the heads of the last sizes of an append-only tree, the head
+// of size s at s modulo its length. A nil ring keeps nothing.
+type headRing []TreeHead
+
+// newHeadRing returns a ring of k heads, nil unless k is positive.
+func newHeadRing(k int) headRing {
+	if k <= 0 {
+		return nil
+	}
+
+	return make(headRing, k)
+}
+
+// record keeps h, replacing the head of the size k below its own.
+func (r headRing) record(h TreeHead) {
+	if len(r) == 0 {
+		return
+	}
+
+	r[h.Size%uint64(len(r))] = h
+}
+
+// root returns the root of the head of size, if it is kept.
+func (r headRing) root(size uint64) ([]byte, bool) {
+	if len(r) == 0 {
+		return nil, false
+	}
+
+	h := r[size%uint64(len(r))]
+	if h.Root == nil || h.Size != size {
+		return nil, false
+	}
+
+	return copyNode(h.Root), true
+}
+
+// heads returns the heads kept, by increasing size.
+func (r headRing) heads() []TreeHead {
+	var heads []TreeHead
+	for _, h := range r {
+		if h.Root != nil {
+			heads = append(heads, TreeHead{Size: h.Size, Root: copyNode(h.Root), Timestamp: h.Timestamp, Hash: h.Hash})
+		}
+	}
+	sort.Slice(heads, func(i, j int) bool { return heads[i].Size < heads[j].Size })
+
+	return heads
+}
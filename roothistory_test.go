@@ -0,0 +1,192 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootAtSize(t *testing.T) {
+	for _, history := range []int{0, 1, 7} {
+		incremental, err := NewIncrementalTree(7, WithHeadHistory(history))
+		require.NoError(t, err)
+		mmr := NewMMR(WithHeadHistory(history))
+		log, err := NewLogTree()
+		require.NoError(t, err)
+
+		incrementalRoots := [][]byte{incremental.Root()}
+		mmrRoots := [][]byte{mmr.Root()}
+		logRoots := [][]byte{mustRootHash(t, log)}
+		for i := 0; i < 100; i++ {
+			leaf := sum256([]byte(fmt.Sprintf("leaf %d", i)))
+			_, err := incremental.Append(leaf)
+			require.NoError(t, err)
+			mmr.Append(leaf)
+			require.NoError(t, log.Append(leaf))
+
+			incrementalRoots = append(incrementalRoots, incremental.Root())
+			mmrRoots = append(mmrRoots, mmr.Root())
+			logRoots = append(logRoots, mustRootHash(t, log))
+		}
+
+		for size := range incrementalRoots {
+			root, err := incremental.RootAtSize(uint64(size))
+			require.NoError(t, err)
+			require.Equal(t, incrementalRoots[size], root, "incremental tree of %d leaves", size)
+
+			root, err = mmr.RootAtSize(uint64(size))
+			require.NoError(t, err)
+			require.Equal(t, mmrRoots[size], root, "range of %d leaves", size)
+
+			root, err = log.RootAtSize(uint64(size))
+			require.NoError(t, err)
+			require.Equal(t, logRoots[size], root, "log of %d leaves", size)
+		}
+
+		_, err = incremental.RootAtSize(101)
+		require.Error(t, err)
+		_, err = mmr.RootAtSize(101)
+		require.Error(t, err)
+		_, err = log.RootAtSize(101)
+		require.Error(t, err)
+	}
+}
+
+func mustRootHash(t *testing.T, r Rooter) []byte {
+	root, err := r.RootHash()
+	require.NoError(t, err)
+
+	return root
+}
+
+func TestHeadHistory(t *testing.T) {
+	tree, err := NewIncrementalTree(5, WithHeadHistory(4))
+	require.NoError(t, err)
+	mmr := NewMMR(WithHeadHistory(4))
+	for i := 0; i < 10; i++ {
+		leaf := sum256([]byte(fmt.Sprintf("leaf %d", i)))
+		_, err := tree.Append(leaf)
+		require.NoError(t, err)
+		mmr.Append(leaf)
+	}
+
+	for _, heads := range [][]TreeHead{tree.Heads(), mmr.Heads()} {
+		require.Len(t, heads, 4)
+		for i, h := range heads {
+			require.Equal(t, uint64(7+i), h.Size)
+			require.Equal(t, "sha256", h.Hash)
+		}
+	}
+	require.Equal(t, tree.Root(), tree.Heads()[3].Root)
+	require.Equal(t, mmr.Root(), mmr.Heads()[3].Root)
+
+	// Heads are served from the ring, older sizes recomputed.
+	tree.history[8%4].Root = []byte("kept")
+	root, err := tree.RootAtSize(8)
+	require.NoError(t, err)
+	require.Equal(t, []byte("kept"), root)
+	root, err = tree.RootAtSize(4)
+	require.NoError(t, err)
+	require.NotEqual(t, []byte("kept"), root)
+
+	require.Empty(t, NewMMR().Heads())
+}
+
+func TestIncrementalConsistency(t *testing.T) {
+	tree, err := NewIncrementalTree(4)
+	require.NoError(t, err)
+	for i := 0; i < 16; i++ {
+		_, err := tree.Append(sum256([]byte(fmt.Sprintf("leaf %d", i))))
+		require.NoError(t, err)
+	}
+
+	for n := uint64(0); n <= 16; n++ {
+		for m := uint64(0); m <= n; m++ {
+			proof, err := tree.ConsistencyProof(m, n)
+			require.NoError(t, err)
+			oldRoot, err := tree.RootAtSize(m)
+			require.NoError(t, err)
+			newRoot, err := tree.RootAtSize(n)
+			require.NoError(t, err)
+			require.NoError(t, VerifyIncrementalConsistency(oldRoot, newRoot, m, n, 4, proof), "sizes %d and %d", m, n)
+
+			if m < n {
+				require.Error(t, VerifyIncrementalConsistency(newRoot, newRoot, m, n, 4, proof))
+				require.Error(t, VerifyIncrementalConsistency(oldRoot, oldRoot, m, n, 4, proof))
+				require.Error(t, VerifyIncrementalConsistency(oldRoot, newRoot, m, n, 4, proof[1:]))
+			}
+		}
+	}
+
+	// A fork rewriting an old leaf is detected.
+	fork, err := NewIncrementalTree(4)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leaf := sum256([]byte(fmt.Sprintf("leaf %d", i)))
+		if i == 3 {
+			leaf = sum256([]byte("forked"))
+		}
+		_, err := fork.Append(leaf)
+		require.NoError(t, err)
+	}
+	oldRoot, err := tree.RootAtSize(6)
+	require.NoError(t, err)
+	proof, err := fork.ConsistencyProof(6, 10)
+	require.NoError(t, err)
+	require.Error(t, VerifyIncrementalConsistency(oldRoot, fork.Root(), 6, 10, 4, proof))
+
+	_, err = tree.ConsistencyProof(5, 17)
+	require.Error(t, err)
+	_, err = tree.ConsistencyProof(6, 5)
+	require.Error(t, err)
+}
+
+func TestMMRConsistency(t *testing.T) {
+	m := NewMMR()
+	for i := 0; i < 40; i++ {
+		m.Append([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	for n := uint64(0); n <= 40; n++ {
+		for o := uint64(0); o <= n; o++ {
+			proof, err := m.ConsistencyProof(o, n)
+			require.NoError(t, err)
+			oldRoot, err := m.RootAtSize(o)
+			require.NoError(t, err)
+			newRoot, err := m.RootAtSize(n)
+			require.NoError(t, err)
+			require.NoError(t, VerifyMMRConsistency(oldRoot, newRoot, proof), "sizes %d and %d", o, n)
+
+			if o > 0 && o < n {
+				require.Error(t, VerifyMMRConsistency(newRoot, newRoot, proof))
+				require.Error(t, VerifyMMRConsistency(oldRoot, oldRoot, proof))
+			}
+		}
+	}
+
+	proof, err := m.ConsistencyProof(11, 29)
+	require.NoError(t, err)
+	oldRoot, err := m.RootAtSize(11)
+	require.NoError(t, err)
+	newRoot, err := m.RootAtSize(29)
+	require.NoError(t, err)
+
+	resized := *proof
+	resized.NewSize = 32
+	require.Error(t, VerifyMMRConsistency(oldRoot, newRoot, &resized))
+
+	noRight := *proof
+	noRight.RightPeaks = nil
+	require.Error(t, VerifyMMRConsistency(oldRoot, newRoot, &noRight))
+
+	shortPath := *proof
+	shortPath.Paths = append([][]TreeNode{proof.Paths[0][1:]}, proof.Paths[1:]...)
+	require.Error(t, VerifyMMRConsistency(oldRoot, newRoot, &shortPath))
+
+	require.Error(t, VerifyMMRConsistency(oldRoot, newRoot, nil))
+	require.Error(t, VerifyMMRConsistency(oldRoot, newRoot, &MMRConsistencyProof{OldSize: 0, NewSize: 29}))
+
+	_, err = m.ConsistencyProof(3, 41)
+	require.Error(t, err)
+}
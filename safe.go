@@ -0,0 +1,96 @@
+package merklego
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SafeTree shares a MerkleTree between goroutines. Readers work on immutable
+// snapshots of the tree, while writers build a new tree, or mutate a Clone of
+// the current one, and swap it in atomically, so reads never wait on a
+// rebuild. Writers are serialized with each other.
+type SafeTree struct {
+	mu   sync.Mutex
+	tree atomic.Value // *MerkleTree
+}
+
+// NewSafeTree returns a SafeTree serving tree, which must not be mutated by
+// the caller afterwards.
+func NewSafeTree(tree *MerkleTree) *SafeTree {
+	s := &SafeTree{}
+	s.tree.Store(tree)
+
+	return s
+}
+
+// Tree returns the current snapshot of the tree. Use it to run several reads
+// against the same version of the tree; it must not be mutated.
+func (s *SafeTree) Tree() *MerkleTree {
+	return s.tree.Load().(*MerkleTree)
+}
+
+// MerkleRoot returns the merkle root of the current tree.
+func (s *SafeTree) MerkleRoot() []byte {
+	return s.Tree().MerkleRoot()
+}
+
+// ContentIndex returns the position of content in the current tree.
+func (s *SafeTree) ContentIndex(content Storable) (int, error) {
+	return s.Tree().ContentIndex(content)
+}
+
+// MerklePath returns the merkle path of content in the current tree.
+func (s *SafeTree) MerklePath(content Storable) ([]ProofStep, error) {
+	return s.Tree().MerklePath(content)
+}
+
+// VerifyMerklePath checks path against the root of the current tree.
+func (s *SafeTree) VerifyMerklePath(content Storable, path []ProofStep) error {
+	return s.Tree().VerifyMerklePath(content, path)
+}
+
+// RebuildTreeWith builds a tree over content with the configuration of the
+// current one and swaps it in. On error the current tree is kept.
+func (s *SafeTree) RebuildTreeWith(content []Storable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.Tree()
+	next := &MerkleTree{config: cur.config, hashers: cur.hashers}
+	if err := next.RebuildTreeWith(content); err != nil {
+		return err
+	}
+	s.tree.Store(next)
+
+	return nil
+}
+
+// UpdateLeaf replaces old with new in a copy of the current tree and swaps
+// it in.
+func (s *SafeTree) UpdateLeaf(old, new Storable) error {
+	return s.Update(func(m *MerkleTree) error {
+		return m.UpdateLeaf(old, new)
+	})
+}
+
+// Remove drops content from a copy of the current tree and swaps it in.
+func (s *SafeTree) Remove(content Storable) error {
+	return s.Update(func(m *MerkleTree) error {
+		return m.Remove(content)
+	})
+}
+
+// Update calls fn with a Clone of the current tree and swaps the clone in if
+// fn succeeds.
+func (s *SafeTree) Update(fn func(m *MerkleTree) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.Tree().Clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	s.tree.Store(next)
+
+	return nil
+}
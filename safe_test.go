@@ -0,0 +1,97 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSafeTreeConcurrent(t *testing.T) {
+	versions := make([][]Storable, 4)
+	roots := map[string]bool{}
+	for v := range versions {
+		versions[v] = make([]Storable, 16)
+		for i := range versions[v] {
+			versions[v][i] = TestSHA256Content{x: fmt.Sprintf("v%d-leaf-%d", v, i)}
+		}
+
+		tree, _ := NewTree(versions[v])
+		roots[string(tree.MerkleRoot())] = true
+	}
+
+	initial, _ := NewTree(versions[0])
+	safe := NewSafeTree(initial)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if err := safe.RebuildTreeWith(versions[i%len(versions)]); err != nil {
+				t.Errorf("error: unexpected error: %v", err)
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if !roots[string(safe.MerkleRoot())] {
+					t.Errorf("error: unexpected root %x", safe.MerkleRoot())
+					return
+				}
+
+				snapshot := safe.Tree()
+				content := snapshot.Leaves[i%16].Item
+				path, err := snapshot.MerklePath(content)
+				if err != nil {
+					t.Errorf("error: unexpected error: %v", err)
+					return
+				}
+				if err := snapshot.VerifyMerklePath(content, path); err != nil {
+					t.Errorf("error: expected valid path got %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestSafeTreeUpdate(t *testing.T) {
+	contents := paddingContents(5)
+	initial, _ := NewTree(contents)
+	root := initial.MerkleRoot()
+	safe := NewSafeTree(initial)
+
+	snapshot := safe.Tree()
+	if err := safe.UpdateLeaf(contents[1], TestSHA256Content{x: "updated"}); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if err := safe.Remove(contents[4]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	expected, _ := NewTree([]Storable{contents[0], TestSHA256Content{x: "updated"}, contents[2], contents[3]})
+	if !bytes.Equal(safe.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), safe.MerkleRoot())
+	}
+	if !bytes.Equal(snapshot.MerkleRoot(), root) {
+		t.Errorf("error: expected the old snapshot to keep root %x got %x", root, snapshot.MerkleRoot())
+	}
+
+	if err := safe.Remove(TestSHA256Content{x: "missing"}); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if err := safe.RebuildTreeWith(nil); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+	if !bytes.Equal(safe.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected failed writes to keep root %x got %x", expected.MerkleRoot(), safe.MerkleRoot())
+	}
+}
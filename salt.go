@@ -0,0 +1,131 @@
+package merklego
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptySalt is returned when a block is inserted with an empty salt.
+var ErrEmptySalt = errors.New("Salt cannot be empty; insert unsalted blocks with Insert")
+
+// ErrSaltedTree is returned by the proofs that only hold blocks, such as
+// absence, order and range proofs, for trees with salted leaves.
+var ErrSaltedTree = errors.New("Merkle tree has salted leaves; prove its blocks one by one with Prove")
+
+// WithRandomSalts makes Finalize salt every block of a FlatMerkleTree that was
+// inserted without a salt with size random bytes, read from crypto/rand, as
+// InsertSalted does. Each recipient of a proof then holds the salt of its own
+// block only and cannot confirm guesses of the others. A size of 0 disables
+// it. It has no effect on a MerkleTree.
+func WithRandomSalts(size int) Option {
+	return func(c *config) {
+		c.randomSalts = size
+	}
+}
+
+// InsertSalted inserts block on a non finalized tree, salted with salt: its
+// leaf hash is H(prefix || salt || block) instead of H(prefix || block), so it
+// cannot be confirmed by whoever does not hold salt. The salt is carried by
+// the Proof of block only, and is returned by Salt. Salts of 16 or more random
+// bytes are recommended.
+func (mt *FlatMerkleTree) InsertSalted(block Block, salt []byte) error {
+	if len(salt) == 0 {
+		return ErrEmptySalt
+	}
+	if err := mt.Insert(block); err != nil {
+		return err
+	}
+
+	mt.padSalts()
+	mt.salts[len(mt.blocks)-1] = append([]byte(nil), salt...)
+
+	return nil
+}
+
+// Salt returns a copy of the salt of the first leaf holding block, nil if it
+// is not salted.
+func (mt *FlatMerkleTree) Salt(block Block) ([]byte, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+
+	idx, err := mt.findLeaf(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return clonePrefix(mt.saltAt(idx - (len(mt.nodes) - len(mt.blocks)))), nil
+}
+
+// salted reports whether any block of the tree is salted.
+func (mt *FlatMerkleTree) salted() bool {
+	return mt.salts != nil
+}
+
+// padSalts extends the salts to one per block, unsalted blocks having none.
+func (mt *FlatMerkleTree) padSalts() {
+	for len(mt.salts) < len(mt.blocks) {
+		mt.salts = append(mt.salts, nil)
+	}
+}
+
+// saltAt returns the salt of the block at index, nil if it is not salted.
+func (mt *FlatMerkleTree) saltAt(index int) []byte {
+	if index >= len(mt.salts) {
+		return nil
+	}
+
+	return mt.salts[index]
+}
+
+// fillSalts gives the blocks inserted without a salt random ones, as
+// WithRandomSalts sets.
+func (mt *FlatMerkleTree) fillSalts() error {
+	if mt.randomSalts <= 0 {
+		return nil
+	}
+
+	mt.padSalts()
+	for i, salt := range mt.salts {
+		if salt != nil {
+			continue
+		}
+
+		salt = make([]byte, mt.randomSalts)
+		if _, err := rand.Read(salt); err != nil {
+			return fmt.Errorf("cannot salt block %d: %w", i, err)
+		}
+		mt.salts[i] = salt
+	}
+
+	return nil
+}
+
+// hashSaltedLeaf returns the leaf hash of block salted with salt, the one of
+// salt || block, or of block alone when salt is empty.
+func (mt *FlatMerkleTree) hashSaltedLeaf(salt, block []byte) TreeNode {
+	if len(salt) == 0 {
+		return mt.hashLeaf(block)
+	}
+
+	data := make([]byte, 0, len(salt)+len(block))
+	data = append(data, salt...)
+
+	return mt.hashLeaf(append(data, block...))
+}
+
+// VerifySaltedFlatProof checks proof for block, salted with salt, as
+// VerifyFlatProof does for an unsalted block.
+func VerifySaltedFlatProof(root []byte, block Block, salt []byte, index, size int, proof []TreeNode, opts ...Option) error {
+	if block == nil {
+		return ErrNilBlock
+	}
+
+	mt := &FlatMerkleTree{}
+	for _, opt := range opts {
+		opt(&mt.config)
+	}
+
+	return mt.verifyPath(root, block, salt, index, size, proof)
+}
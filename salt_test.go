@@ -0,0 +1,193 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func saltedTree(t *testing.T, n int, opts ...Option) (*FlatMerkleTree, [][]byte) {
+	mt := NewMerkleTree()
+	var salts [][]byte
+	for i := 0; i < n; i++ {
+		salt := []byte(fmt.Sprintf("salt-%02d-0123456789", i))
+		require.NoError(t, mt.InsertSalted(Block(fmt.Sprintf("block-%d", i)), salt))
+		salts = append(salts, salt)
+	}
+	require.NoError(t, mt.Finalize(opts...))
+
+	return mt, salts
+}
+
+func TestInsertSalted(t *testing.T) {
+	mt, salts := saltedTree(t, 5)
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+
+	unsalted := NewMerkleTree()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, unsalted.Insert(Block(fmt.Sprintf("block-%d", i))))
+	}
+	require.NoError(t, unsalted.Finalize())
+	unsaltedRoot, _ := unsalted.RootHash()
+	require.NotEqual(t, unsaltedRoot, root)
+
+	for i, salt := range salts {
+		block := Block(fmt.Sprintf("block-%d", i))
+		p, err := mt.Prove(block)
+		require.NoError(t, err)
+
+		// The leaf hash is H(0x00 || salt || block).
+		want := sha256.Sum256(append(append([]byte{leafNodePrefix}, salt...), block...))
+		require.Equal(t, want[:], p.LeafHash)
+		require.Equal(t, salt, p.Salt)
+		require.NoError(t, p.Verify(root))
+		require.NoError(t, mt.VerifyProof(block, p))
+
+		got, err := mt.Salt(block)
+		require.NoError(t, err)
+		require.Equal(t, salt, got)
+
+		chunks, err := mt.Proof(block)
+		require.NoError(t, err)
+		require.NoError(t, mt.Verify(block, chunks))
+		require.NoError(t, VerifySaltedFlatProof(root, block, salt, i, 5, chunks))
+
+		// Without its salt, or with the salt of another leaf, the block does
+		// not verify.
+		other := salts[(i+1)%len(salts)]
+		require.Error(t, VerifyFlatProof(root, block, i, 5, chunks))
+		require.Error(t, VerifySaltedFlatProof(root, block, other, i, 5, chunks))
+		stripped := *p
+		stripped.Salt = nil
+		require.ErrorIs(t, mt.VerifyProof(block, &stripped), ErrInvalidProof)
+		stripped.Salt = other
+		require.ErrorIs(t, mt.VerifyProof(block, &stripped), ErrInvalidProof)
+	}
+}
+
+func TestSaltNotInOtherProofs(t *testing.T) {
+	mt, salts := saltedTree(t, 7)
+
+	for i := range salts {
+		p, err := mt.Prove(Block(fmt.Sprintf("block-%d", i)))
+		require.NoError(t, err)
+
+		binary, err := p.MarshalBinary()
+		require.NoError(t, err)
+		text, err := json.Marshal(p)
+		require.NoError(t, err)
+		for j, salt := range salts {
+			if j == i {
+				require.True(t, bytes.Contains(binary, salt))
+				continue
+			}
+			require.False(t, bytes.Contains(binary, salt), "salt %d in the proof of %d", j, i)
+			require.NotContains(t, string(text), string(salt))
+			require.NotContains(t, string(text), string(mustJSONBytes(t, salt)))
+		}
+	}
+}
+
+func mustJSONBytes(t *testing.T, b []byte) []byte {
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+
+	return bytes.Trim(data, `"`)
+}
+
+func TestRandomSalts(t *testing.T) {
+	build := func() (*FlatMerkleTree, []byte) {
+		mt := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+		require.NoError(t, mt.InsertSalted(Block("d"), []byte("chosen salt")))
+		require.NoError(t, mt.Finalize(WithRandomSalts(16)))
+		root, err := mt.RootHash()
+		require.NoError(t, err)
+
+		return mt, root
+	}
+
+	mt, root := build()
+	_, other := build()
+	require.NotEqual(t, other, root)
+
+	seen := map[string]bool{}
+	for _, b := range []string{"a", "b", "c"} {
+		p, err := mt.Prove(Block(b))
+		require.NoError(t, err)
+		require.Len(t, p.Salt, 16)
+		require.False(t, seen[string(p.Salt)])
+		seen[string(p.Salt)] = true
+		require.NoError(t, p.Verify(root))
+	}
+	salt, err := mt.Salt(Block("d"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("chosen salt"), salt)
+}
+
+func TestSaltedTreeEncodings(t *testing.T) {
+	mt, _ := saltedTree(t, 6, WithSortedLeaves(true))
+	root, _ := mt.RootHash()
+
+	for i := 0; i < 6; i++ {
+		p, err := mt.Prove(Block(fmt.Sprintf("block-%d", i)))
+		require.NoError(t, err)
+		require.NoError(t, p.Verify(root))
+
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, byte(proofVersionLayout), data[0])
+		require.Equal(t, len(data), p.EncodedSize(ProofBinary))
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, p, &decoded)
+
+		data, err = json.Marshal(p)
+		require.NoError(t, err)
+		require.Equal(t, len(data), p.EncodedSize(ProofJSON))
+	}
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, s.Salts, 6)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	restoredRoot, _ := restored.RootHash()
+	require.Equal(t, root, restoredRoot)
+	s.Salts = s.Salts[1:]
+	_, err = RestoreFlatMerkleTree(s)
+	require.Error(t, err)
+
+	// Unsalted proofs of LayoutLevels keep the first version of the layout.
+	plain, err := NewTree(paddingContents(2))
+	require.NoError(t, err)
+	p, err := plain.Prove(plain.Leaves[0].Hash)
+	require.NoError(t, err)
+	data, err := p.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, byte(proofVersion), data[0])
+}
+
+func TestSaltedTreeErrors(t *testing.T) {
+	mt := NewMerkleTree()
+	require.ErrorIs(t, mt.InsertSalted(Block("a"), nil), ErrEmptySalt)
+	require.ErrorIs(t, mt.InsertSalted(nil, []byte("salt")), ErrNilBlock)
+
+	mt, _ = saltedTree(t, 4, WithSortedLeaves(true))
+	_, err := mt.ProveAbsence(Block("absent"))
+	require.ErrorIs(t, err, ErrSaltedTree)
+	_, err = mt.ProveOrder(Block("block-0"), Block("block-1"))
+	require.ErrorIs(t, err, ErrSaltedTree)
+	_, err = mt.ProveRange(0, 2)
+	require.ErrorIs(t, err, ErrSaltedTree)
+	require.ErrorIs(t, mt.InsertSalted(Block("late"), []byte("salt")), ErrTreeAlreadyFinalized)
+
+	p, err := mt.Prove(Block("block-0"))
+	require.NoError(t, err)
+	_, err = p.ToICS23([]byte("k"), []byte("v"))
+	require.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package merklego
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// signedRootPrefix starts every signed root payload, so a signature over it
+// cannot be mistaken for one over another kind of message.
+const signedRootPrefix = "merkle-go signed root v1\x00"
+
+// ErrInvalidSignature is returned by VerifySignedRoot when the signature does
+// not match the signed root and the key.
+var ErrInvalidSignature = errors.New("error: invalid root signature")
+
+// SignedRoot is the root of a tree of Size leaves, hashed with the function
+// named Hash, vouched for by the holder of a key at Timestamp.
+type SignedRoot struct {
+	Root      []byte
+	Size      int
+	Hash      string
+	Timestamp time.Time
+	Signature []byte
+}
+
+// Payload returns the bytes covered by the signature:
+//
+//	prefix     the 24 bytes "merkle-go signed root v1", then a zero byte
+//	hash name  1 byte length, then the name
+//	size       8 bytes, big endian
+//	timestamp  8 bytes, big endian two's complement, milliseconds since the
+//	           Unix epoch
+//	root       1 byte length, then the root
+//
+// Ed25519 keys sign the payload itself and ECDSA P-256 keys its SHA-256
+// digest, the signature being ASN.1 DER encoded.
+func (sr *SignedRoot) Payload() ([]byte, error) {
+	if len(sr.Hash) > 0xff || len(sr.Root) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode a %d byte root of hash %q", len(sr.Root), sr.Hash)
+	}
+	if sr.Size < 0 {
+		return nil, fmt.Errorf("error: cannot encode tree size %d", sr.Size)
+	}
+
+	out := make([]byte, 0, len(signedRootPrefix)+18+len(sr.Hash)+len(sr.Root))
+	out = append(out, signedRootPrefix...)
+	out = append(out, byte(len(sr.Hash)))
+	out = append(out, sr.Hash...)
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], uint64(sr.Size))
+	out = append(out, num[:]...)
+	binary.BigEndian.PutUint64(num[:], uint64(sr.Timestamp.UnixMilli()))
+	out = append(out, num[:]...)
+	out = append(out, byte(len(sr.Root)))
+	out = append(out, sr.Root...)
+
+	return out, nil
+}
+
+// SignRoot signs the root of the tree with signer, an Ed25519 or ECDSA P-256
+// key. opts must be crypto.Hash(0) for Ed25519 and crypto.SHA256 for ECDSA.
+func (m *MerkleTree) SignRoot(signer crypto.Signer, opts crypto.SignerOpts) (*SignedRoot, error) {
+	root, err := m.RootHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return signRoot(signer, opts, &SignedRoot{Root: root, Size: m.leafCount, Hash: m.hashers.name})
+}
+
+// SignRoot signs the root of the tree with signer, as MerkleTree.SignRoot
+// does. The tree must be finalized.
+func (mt *FlatMerkleTree) SignRoot(signer crypto.Signer, opts crypto.SignerOpts) (*SignedRoot, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+
+	return signRoot(signer, opts, &SignedRoot{Root: copyNode(mt.root).Bytes(), Size: mt.count, Hash: mt.hashProofName()})
+}
+
+// signRoot stamps sr with the current time, to the millisecond, and signs it.
+func signRoot(signer crypto.Signer, opts crypto.SignerOpts, sr *SignedRoot) (*SignedRoot, error) {
+	sr.Timestamp = time.Now().Truncate(time.Millisecond)
+	payload, err := sr.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := payload
+	switch pub := signer.Public().(type) {
+	case ed25519.PublicKey:
+		if opts.HashFunc() != 0 {
+			return nil, fmt.Errorf("error: ed25519 signs the unhashed root, got %v", opts.HashFunc())
+		}
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() || opts.HashFunc() != crypto.SHA256 {
+			return nil, fmt.Errorf("error: ecdsa roots are signed with P-256 and SHA-256")
+		}
+		sum := sha256.Sum256(payload)
+		digest = sum[:]
+	default:
+		return nil, fmt.Errorf("error: unsupported signing key %T", pub)
+	}
+
+	sr.Signature, err = signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sr, nil
+}
+
+// VerifySignedRoot checks the signature of sr against pub, an ed25519.PublicKey
+// or a P-256 *ecdsa.PublicKey. It returns ErrInvalidSignature when the
+// signature does not match.
+func VerifySignedRoot(pub crypto.PublicKey, sr *SignedRoot) error {
+	payload, err := sr.Payload()
+	if err != nil {
+		return err
+	}
+
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		if len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("error: %d byte ed25519 public key", len(pub))
+		}
+		if !ed25519.Verify(pub, payload, sr.Signature) {
+			return ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return fmt.Errorf("error: unsupported ecdsa curve %s", pub.Curve.Params().Name)
+		}
+		digest := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(pub, digest[:], sr.Signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("error: unsupported public key %T", pub)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package merklego
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSigningKeys(t *testing.T) (ed25519.PrivateKey, *ecdsa.PrivateKey) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	d, ok := new(big.Int).SetString("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721", 16)
+	require.True(t, ok)
+	ec := &ecdsa.PrivateKey{D: d}
+	ec.Curve = elliptic.P256()
+	ec.X, ec.Y = ec.Curve.ScalarBaseMult(d.Bytes())
+
+	return ed25519.NewKeyFromSeed(seed), ec
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestSignedRootVectors(t *testing.T) {
+	ed, ec := testSigningKeys(t)
+	root := sha256.Sum256([]byte("root"))
+	sr := &SignedRoot{Root: root[:], Size: 7, Hash: "sha256", Timestamp: time.UnixMilli(1700000000000)}
+
+	payload, err := sr.Payload()
+	require.NoError(t, err)
+	require.Equal(t, "6d65726b6c652d676f207369676e656420726f6f74207631000673686132353600000000000000070000018bcfe56800204813494d137e1631bba301d5acab6e7bb7aa74ce1185d456565ef51d737677b2", hex.EncodeToString(payload))
+	require.Equal(t, "03a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b8", hex.EncodeToString(ed.Public().(ed25519.PublicKey)))
+
+	sr.Signature = mustHex(t, "83cc601859004e437b623a6b5ff38dfef8fdfbd041291be3734e67b71c349fc25b6f4e6dcf883476bfbc0bf51f43c112f69486009c98195338e0218bface6b0e")
+	require.Equal(t, sr.Signature, ed25519.Sign(ed, payload))
+	require.NoError(t, VerifySignedRoot(ed.Public(), sr))
+
+	ecSigned := *sr
+	ecSigned.Signature = mustHex(t, "3045022013a4aa213d2e0ece6a1de12c2a23c3a7b5c4dbeee0e535a014bb3e94d85a017c0221008c82af1d25cf244605d52e846ea4724e27f14c63044177a3d34c9dca42457dac")
+	require.NoError(t, VerifySignedRoot(ec.Public(), &ecSigned))
+
+	require.ErrorIs(t, VerifySignedRoot(ec.Public(), sr), ErrInvalidSignature)
+	require.ErrorIs(t, VerifySignedRoot(ed.Public(), &ecSigned), ErrInvalidSignature)
+}
+
+func TestSignRoot(t *testing.T) {
+	ed, ec := testSigningKeys(t)
+
+	flat := NewMerkleTree(Block("a"), Block("b"), Block("c"))
+	_, err := flat.SignRoot(ed, crypto.Hash(0))
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+	require.NoError(t, flat.Finalize())
+
+	tree, err := NewTree(paddingContents(5))
+	require.NoError(t, err)
+
+	signers := []struct {
+		signer crypto.Signer
+		opts   crypto.SignerOpts
+	}{
+		{ed, crypto.Hash(0)},
+		{ec, crypto.SHA256},
+	}
+	for _, s := range signers {
+		for _, tr := range []interface {
+			Rooter
+			SignRoot(crypto.Signer, crypto.SignerOpts) (*SignedRoot, error)
+		}{flat, tree} {
+			before := time.Now().Truncate(time.Millisecond)
+			sr, err := tr.SignRoot(s.signer, s.opts)
+			require.NoError(t, err)
+
+			root, _ := tr.RootHash()
+			require.Equal(t, root, sr.Root)
+			require.Equal(t, "sha256", sr.Hash)
+			require.False(t, sr.Timestamp.Before(before))
+			require.NoError(t, VerifySignedRoot(s.signer.Public(), sr))
+
+			for _, tamper := range []func(sr *SignedRoot){
+				func(sr *SignedRoot) { sr.Size++ },
+				func(sr *SignedRoot) { sr.Timestamp = sr.Timestamp.Add(time.Millisecond) },
+				func(sr *SignedRoot) { sr.Root = append([]byte{1}, sr.Root[1:]...) },
+				func(sr *SignedRoot) { sr.Hash = "sha512" },
+			} {
+				forged := *sr
+				tamper(&forged)
+				require.ErrorIs(t, VerifySignedRoot(s.signer.Public(), &forged), ErrInvalidSignature)
+			}
+		}
+	}
+
+	sr, err := tree.SignRoot(ed, crypto.Hash(0))
+	require.NoError(t, err)
+	require.Equal(t, 5, sr.Size)
+
+	_, err = tree.SignRoot(ed, crypto.SHA256)
+	require.Error(t, err)
+	_, err = tree.SignRoot(ec, crypto.Hash(0))
+	require.Error(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = tree.SignRoot(p384, crypto.SHA256)
+	require.Error(t, err)
+	require.Error(t, VerifySignedRoot(p384.Public(), sr))
+	require.Error(t, VerifySignedRoot("key", sr))
+}
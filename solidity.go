@@ -0,0 +1,88 @@
+package merklego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrNotSolidityCompatible is returned when exporting a proof that the usual
+// Solidity verifiers, such as OpenZeppelin's MerkleProof.verify, cannot check.
+var ErrNotSolidityCompatible = errors.New("error: proof cannot be verified by a Solidity merkle verifier")
+
+// solidityWordSize is the size of a bytes32 value and of an ABI word.
+const solidityWordSize = 32
+
+// checkSolidity returns an error wrapping ErrNotSolidityCompatible unless p
+// can be checked by MerkleProof.verify(bytes32[] proof, bytes32 root, bytes32
+// leaf): leaf and siblings of 32 bytes hashed with keccak-256 as sorted
+// pairs, with nothing else to the hashing of the nodes or to the root.
+func (p *Proof) checkSolidity() error {
+	switch {
+	case p.Hash != "keccak256":
+		return fmt.Errorf("%w: hash function %q is not keccak256", ErrNotSolidityCompatible, p.Hash)
+	case !p.SortedPairs:
+		return fmt.Errorf("%w: pairs are not sorted", ErrNotSolidityCompatible)
+	case p.DomainSeparation:
+		return fmt.Errorf("%w: nodes are hashed with domain separation", ErrNotSolidityCompatible)
+	case p.CountCommitment:
+		return fmt.Errorf("%w: the root commits to the leaf count", ErrNotSolidityCompatible)
+	case p.PairHash != "":
+		return fmt.Errorf("%w: pairs are hashed with %q", ErrNotSolidityCompatible, p.PairHash)
+	case p.Keyed:
+		return fmt.Errorf("%w: nodes are keyed with HMAC", ErrNotSolidityCompatible)
+	case p.DigestSize != 0:
+		return fmt.Errorf("%w: hashes are truncated to %d bytes", ErrNotSolidityCompatible, p.DigestSize)
+	case p.Compressed():
+		return fmt.Errorf("%w: compressed proof", ErrNotSolidityCompatible)
+	case len(p.LeafHash) != solidityWordSize:
+		return fmt.Errorf("%w: %d byte leaf hash", ErrNotSolidityCompatible, len(p.LeafHash))
+	}
+	for i, s := range p.Steps {
+		if len(s.Hash) != solidityWordSize {
+			return fmt.Errorf("%w: %d byte sibling at step %d", ErrNotSolidityCompatible, len(s.Hash), i)
+		}
+	}
+
+	return nil
+}
+
+// ToBytes32Slice returns the siblings of the proof, from the leaf up, as the
+// bytes32[] proof argument of OpenZeppelin's MerkleProof.verify, whose leaf
+// argument is LeafHash. The sides of the siblings are left out: the verifier
+// sorts each pair. It returns an error wrapping ErrNotSolidityCompatible for
+// proofs of trees such a verifier cannot check; trees built in the
+// EthereumMode of the eth package produce proofs it can.
+func (p *Proof) ToBytes32Slice() ([][32]byte, error) {
+	if err := p.checkSolidity(); err != nil {
+		return nil, err
+	}
+
+	out := make([][32]byte, len(p.Steps))
+	for i, s := range p.Steps {
+		copy(out[i][:], s.Hash)
+	}
+
+	return out, nil
+}
+
+// EncodeABICalldata returns the ABI encoding of the siblings of the proof as
+// a single bytes32[] value, as ethers' AbiCoder.encode(["bytes32[]"], [proof])
+// and Solidity's abi.encode(proof) write it: the offset of the array, 32, its
+// length, then every sibling, each as a 32 byte word. It fails as
+// ToBytes32Slice does.
+func (p *Proof) EncodeABICalldata() ([]byte, error) {
+	siblings, err := p.ToBytes32Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2*solidityWordSize, (2+len(siblings))*solidityWordSize)
+	binary.BigEndian.PutUint64(out[solidityWordSize-8:], solidityWordSize)
+	binary.BigEndian.PutUint64(out[2*solidityWordSize-8:], uint64(len(siblings)))
+	for _, s := range siblings {
+		out = append(out, s[:]...)
+	}
+
+	return out, nil
+}
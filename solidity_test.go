@@ -0,0 +1,142 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+)
+
+// solidityTree returns a tree of n leaf hashes built as Solidity verifiers
+// expect, the EthereumMode of the eth package.
+func solidityTree(t *testing.T, n int) *MerkleTree {
+	RegisterHash("keccak256", sha3.NewLegacyKeccak256)
+
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		h := sha3.NewLegacyKeccak256()
+		h.Write([]byte(fmt.Sprintf("leaf-%d", i)))
+		hashes[i] = h.Sum(nil)
+	}
+	tree, err := NewTreeFromLeafHashes(hashes, sha3.NewLegacyKeccak256,
+		WithSortedPairs(true), WithDomainSeparation(false), WithPaddingStrategy(PadPromote))
+	require.NoError(t, err)
+
+	return tree
+}
+
+// solidityProcessProof is OpenZeppelin's MerkleProof.processProof: it folds
+// the proof into leaf, hashing each pair in ascending order with keccak-256.
+func solidityProcessProof(proof [][32]byte, leaf [32]byte) [32]byte {
+	computed := leaf
+	for _, sibling := range proof {
+		a, b := computed, sibling
+		if bytes.Compare(b[:], a[:]) < 0 {
+			a, b = b, a
+		}
+		h := sha3.NewLegacyKeccak256()
+		h.Write(a[:])
+		h.Write(b[:])
+		copy(computed[:], h.Sum(nil))
+	}
+
+	return computed
+}
+
+// decodeBytes32Array decodes the ABI encoding of a single bytes32[] value.
+func decodeBytes32Array(t *testing.T, data []byte) [][32]byte {
+	require.Zero(t, len(data)%32)
+	require.GreaterOrEqual(t, len(data), 64)
+	require.Equal(t, uint64(32), binary.BigEndian.Uint64(data[24:32]))
+	require.Equal(t, make([]byte, 24), data[:24])
+	n := binary.BigEndian.Uint64(data[56:64])
+	require.Equal(t, make([]byte, 24), data[32:56])
+	require.Equal(t, int(n), len(data)/32-2)
+
+	out := make([][32]byte, n)
+	for i := range out {
+		copy(out[i][:], data[64+32*i:])
+	}
+
+	return out
+}
+
+func TestProofSolidityExport(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		tree := solidityTree(t, n)
+		var root [32]byte
+		copy(root[:], tree.MerkleRoot())
+
+		for i := 0; i < n; i++ {
+			proof, err := tree.Prove(tree.Leaves[i].Hash)
+			require.NoError(t, err)
+			require.Equal(t, "keccak256", proof.Hash)
+			require.NoError(t, proof.Verify(root[:]))
+
+			siblings, err := proof.ToBytes32Slice()
+			require.NoError(t, err)
+			require.Len(t, siblings, len(proof.Steps))
+			var leaf [32]byte
+			copy(leaf[:], proof.LeafHash)
+			require.Equal(t, root, solidityProcessProof(siblings, leaf), "leaves:%d index:%d", n, i)
+
+			data, err := proof.EncodeABICalldata()
+			require.NoError(t, err)
+			require.Equal(t, siblings, decodeBytes32Array(t, data))
+		}
+	}
+
+	// The encoding of ethers.AbiCoder.defaultAbiCoder().encode(["bytes32[]"], [[0x01.., 0x02..]]).
+	proof := &Proof{Hash: "keccak256", SortedPairs: true, Size: 3, LeafHash: make([]byte, 32), Steps: []ProofStep{
+		{Hash: bytes.Repeat([]byte{1}, 32)},
+		{Hash: bytes.Repeat([]byte{2}, 32), Left: true},
+	}}
+	data, err := proof.EncodeABICalldata()
+	require.NoError(t, err)
+	want := append(append(append(make([]byte, 31), 0x20), append(make([]byte, 31), 2)...), append(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)...)...)
+	require.Equal(t, want, data)
+
+	proof.Steps = nil
+	data, err = proof.EncodeABICalldata()
+	require.NoError(t, err)
+	require.Equal(t, append(append(make([]byte, 31), 0x20), make([]byte, 32)...), data)
+}
+
+func TestProofSolidityIncompatible(t *testing.T) {
+	valid := func() *Proof {
+		return &Proof{Hash: "keccak256", SortedPairs: true, Size: 2, LeafHash: make([]byte, 32), Steps: []ProofStep{{Hash: make([]byte, 32)}}}
+	}
+	_, err := valid().ToBytes32Slice()
+	require.NoError(t, err)
+
+	for name, change := range map[string]func(p *Proof){
+		"sha256":            func(p *Proof) { p.Hash = "sha256" },
+		"unsorted":          func(p *Proof) { p.SortedPairs = false },
+		"domain separation": func(p *Proof) { p.DomainSeparation = true },
+		"count commitment":  func(p *Proof) { p.CountCommitment = true },
+		"pair hash":         func(p *Proof) { p.PairHash = "sha256d" },
+		"keyed":             func(p *Proof) { p.Keyed = true },
+		"truncated":         func(p *Proof) { p.DigestSize = 20 },
+		"compressed":        func(p *Proof) { p.Steps[0].Hash = nil },
+		"short leaf":        func(p *Proof) { p.LeafHash = p.LeafHash[:20] },
+		"short sibling":     func(p *Proof) { p.Steps[0].Hash = p.Steps[0].Hash[:31] },
+	} {
+		p := valid()
+		change(p)
+		_, err := p.ToBytes32Slice()
+		require.ErrorIs(t, err, ErrNotSolidityCompatible, name)
+		_, err = p.EncodeABICalldata()
+		require.ErrorIs(t, err, ErrNotSolidityCompatible, name)
+	}
+
+	// The default configuration of a tree cannot be checked in Solidity.
+	tree, err := NewTree(paddingContents(4))
+	require.NoError(t, err)
+	p, err := tree.Prove(tree.Leaves[1].Hash)
+	require.NoError(t, err)
+	_, err = p.ToBytes32Slice()
+	require.ErrorIs(t, err, ErrNotSolidityCompatible)
+}
@@ -0,0 +1,126 @@
+package merklego
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// sszMaxDepth is the depth of a tree of 2^64 chunks, the largest limit.
+const sszMaxDepth = 64
+
+var (
+	sszZeroMu sync.Mutex
+	// sszZeroHashes holds, by the digest of hashProbe under a hash function,
+	// the roots of the all-zero subtrees of every depth under that function.
+	sszZeroHashes = map[string][][32]byte{}
+)
+
+// sszZeroLadder returns the roots of the subtrees of 2^d zero chunks, d from 0
+// to sszMaxDepth, hashed as c does. They are computed once per hash function.
+func (c *config) sszZeroLadder() ([][32]byte, error) {
+	h := c.newHash()
+	if h.Size() != 32 {
+		return nil, fmt.Errorf("error: SSZ merkleization needs a 32-byte hash, got %d bytes", h.Size())
+	}
+	h.Write(hashProbe)
+	key := string(h.Sum(nil))
+
+	sszZeroMu.Lock()
+	defer sszZeroMu.Unlock()
+
+	if ladder, ok := sszZeroHashes[key]; ok {
+		return ladder, nil
+	}
+
+	ladder := make([][32]byte, sszMaxDepth+1)
+	for d := 1; d <= sszMaxDepth; d++ {
+		ladder[d] = c.sszHashPair(ladder[d-1], ladder[d-1])
+	}
+	sszZeroHashes[key] = ladder
+
+	return ladder, nil
+}
+
+// sszHashPair hashes two chunks into their parent, H(left || right) with no
+// prefix.
+func (c *config) sszHashPair(left, right [32]byte) (out [32]byte) {
+	h := c.newHash()
+	h.Write(left[:])
+	h.Write(right[:])
+	h.Sum(out[:0])
+
+	return out
+}
+
+// SSZChunkRoot returns the root of chunks merkleized as the SSZ specification
+// does: the chunks are the leaves, unhashed, of a tree of the next power of
+// two of limit leaves, padded with zero chunks, whose internal nodes are
+// H(left || right). A limit of 0 stands for the number of chunks, which is how
+// SSZ vectors are merkleized; an empty list of limit 0 is the zero chunk.
+//
+// Nodes are hashed with SHA-256 unless opts set another 32-byte hash function
+// with WithHashStrategy or WithDigestFunc, keyed with WithHMACKey if set; other
+// options do not apply.
+func SSZChunkRoot(chunks [][32]byte, limit uint64, opts ...Option) ([32]byte, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if limit == 0 {
+		limit = uint64(len(chunks))
+	}
+	if uint64(len(chunks)) > limit {
+		return [32]byte{}, fmt.Errorf("error: %d SSZ chunks exceed the limit of %d", len(chunks), limit)
+	}
+
+	zero, err := c.sszZeroLadder()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	depth := 0
+	if limit > 1 {
+		depth = bits.Len64(limit - 1)
+	}
+
+	layer := make([][32]byte, len(chunks), len(chunks)+1)
+	copy(layer, chunks)
+	for d := 0; d < depth && len(layer) > 0; d++ {
+		if len(layer)%2 != 0 {
+			layer = append(layer, zero[d])
+		}
+		for i := 0; i < len(layer)/2; i++ {
+			layer[i] = c.sszHashPair(layer[2*i], layer[2*i+1])
+		}
+		layer = layer[:len(layer)/2]
+	}
+
+	if len(layer) == 0 {
+		return zero[depth], nil
+	}
+
+	return layer[0], nil
+}
+
+// SSZListRoot returns the root of an SSZ list of length elements packed into
+// chunks: SSZChunkRoot of chunks and limit, the limit in chunks of the list,
+// with the length mixed in as H(root || uint256_le(length)).
+func SSZListRoot(chunks [][32]byte, length, limit uint64, opts ...Option) ([32]byte, error) {
+	root, err := SSZChunkRoot(chunks, limit, opts...)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	var mixin [32]byte
+	binary.LittleEndian.PutUint64(mixin[:8], length)
+
+	return c.sszHashPair(root, mixin), nil
+}
@@ -0,0 +1,156 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The helpers and expected roots below are those of the SSZ typing tests of
+// the consensus specs, as ported to Go by protolambda/ztyp: chunk right-pads
+// hex to a chunk and h hashes two hex chunks.
+func sszChunk(s string) string {
+	var c [32]byte
+	b, _ := hex.DecodeString(s)
+	copy(c[:], b)
+
+	return hex.EncodeToString(c[:])
+}
+
+func sszH(a, b string) string {
+	ab, _ := hex.DecodeString(a)
+	bb, _ := hex.DecodeString(b)
+	sum := sha256.Sum256(append(ab, bb...))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func sszMerge(a string, branch []string) string {
+	for _, b := range branch {
+		a = sszH(a, b)
+	}
+
+	return a
+}
+
+// sszChunks splits hex, the SSZ serialization of packed elements, into
+// zero-padded chunks.
+func sszChunks(t *testing.T, s string) [][32]byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	var chunks [][32]byte
+	for len(b) > 0 {
+		var c [32]byte
+		n := copy(c[:], b)
+		chunks = append(chunks, c)
+		b = b[n:]
+	}
+
+	return chunks
+}
+
+func TestSSZZeroHashes(t *testing.T) {
+	// The zero hashes of the deposit contract.
+	for d, want := range []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b",
+		"db56114e00fdd4c1f85c892bf35ac9a89289aaecb1ebd0a96cde606a748b5d71",
+		"c78009fdf07fc56a11f122370658a353aaa542ed63e44c4bc15ff4cd105ab33c",
+		"536d98837f2dd165a55d5eeae91485954472d56f246df256bf3cae19352a123c",
+	} {
+		root, err := SSZChunkRoot(nil, 1<<d)
+		require.NoError(t, err)
+		require.Equal(t, want, hex.EncodeToString(root[:]))
+	}
+
+	// The root of the empty deposit tree, a list of limit 2^32.
+	root, err := SSZListRoot(nil, 0, 1<<32)
+	require.NoError(t, err)
+	require.Equal(t, "d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e", hex.EncodeToString(root[:]))
+}
+
+func TestSSZSpecVectors(t *testing.T) {
+	zero := []string{sszChunk("")}
+	for d := 1; d < 8; d++ {
+		zero = append(zero, sszH(zero[d-1], zero[d-1]))
+	}
+	ff := strings.Repeat("ff", 32)
+
+	vectors := []struct {
+		name          string
+		data          string
+		length, limit uint64
+		list          bool
+		root          string
+	}{
+		{"bitvector TTFTFTFF", "2b", 0, 1, false, sszChunk("2b")},
+		{"bitlist TTFTFTFF", "2b", 8, 1, true, sszH(sszChunk("2b"), sszChunk("08"))},
+		{"bitlist empty", "", 0, 1, true, sszH(sszChunk(""), sszChunk("00"))},
+		{"long bitvector", strings.Repeat("ff", 64), 0, 2, false, sszH(ff, ff)},
+		{"long bitlist", "03", 2, 2, true, sszH(sszH(sszChunk("03"), sszChunk("")), sszChunk("02"))},
+		{"long bitlist filled", strings.Repeat("ff", 64), 512, 2, true, sszH(sszH(ff, ff), sszChunk("0002"))},
+		{"odd bitvector filled", strings.Repeat("ff", 64) + "01", 0, 3, false, sszH(sszH(ff, ff), sszH(sszChunk("01"), sszChunk("")))},
+		{"odd bitlist filled", strings.Repeat("ff", 64) + "01", 513, 3, true, sszH(sszH(sszH(ff, ff), sszH(sszChunk("01"), sszChunk(""))), sszChunk("0102"))},
+		{"small [4567, 0123]::2", "67452301", 0, 1, false, sszChunk("67452301")},
+		{"uint16 list", "bbaaadc0ffee", 3, 2, true, sszH(sszH(sszChunk("bbaaadc0ffee"), sszChunk("")), sszChunk("03000000"))},
+		{"uint32 list", "bbaa0000adc00000ffee0000", 3, 16, true, sszH(sszMerge(sszChunk("bbaa0000adc00000ffee0000"), zero[0:4]), sszChunk("03000000"))},
+		{"uint16 list 1024 empty", "", 0, 64, true, sszH(zero[6], sszChunk("00000000"))},
+		// Not in the suite: a List[uint64, 32] of 1 to 6, written the same way.
+		{"uint64 list", "0100000000000000020000000000000003000000000000000400000000000000" + "05000000000000000600000000000000", 6, 8, true,
+			sszH(sszMerge(sszH("0100000000000000020000000000000003000000000000000400000000000000", sszChunk("05000000000000000600000000000000")), zero[1:3]), sszChunk("06"))},
+		{"bytes32 list", "bbaa" + strings.Repeat("00", 30) + "adc0" + strings.Repeat("00", 30) + "ffee" + strings.Repeat("00", 30), 3, 64, true,
+			sszH(sszMerge(sszH(sszH(sszChunk("bbaa"), sszChunk("adc0")), sszH(sszChunk("ffee"), sszChunk(""))), zero[2:6]), sszChunk("03000000"))},
+	}
+
+	for _, v := range vectors {
+		chunks := sszChunks(t, v.data)
+
+		var root [32]byte
+		var err error
+		if v.list {
+			root, err = SSZListRoot(chunks, v.length, v.limit)
+		} else {
+			root, err = SSZChunkRoot(chunks, v.limit)
+		}
+		require.NoError(t, err, v.name)
+		require.Equal(t, v.root, hex.EncodeToString(root[:]), v.name)
+	}
+}
+
+func TestSSZChunkRoot(t *testing.T) {
+	chunks := sszChunks(t, strings.Repeat("ab", 32*5))
+
+	// A limit of 0 merkleizes the chunks as a vector.
+	vector, err := SSZChunkRoot(chunks, 0)
+	require.NoError(t, err)
+	padded, err := SSZChunkRoot(chunks, 8)
+	require.NoError(t, err)
+	require.Equal(t, vector, padded)
+	wider, err := SSZChunkRoot(chunks, 9)
+	require.NoError(t, err)
+	require.NotEqual(t, vector, wider)
+
+	root, err := SSZChunkRoot(nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, [32]byte{}, root)
+
+	_, err = SSZChunkRoot(chunks, 4)
+	require.ErrorContains(t, err, "5 SSZ chunks exceed the limit of 4")
+	_, err = SSZListRoot(chunks, 5, 4)
+	require.Error(t, err)
+
+	// The ladder of the largest limit.
+	_, err = SSZChunkRoot(chunks, 1<<64-1)
+	require.NoError(t, err)
+
+	other, err := SSZChunkRoot(chunks, 8, WithHashStrategy(sha512.New512_256))
+	require.NoError(t, err)
+	require.NotEqual(t, padded, other)
+	_, err = SSZChunkRoot(chunks, 8, WithHashStrategy(sha512.New))
+	require.ErrorContains(t, err, "32-byte hash")
+}
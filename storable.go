@@ -0,0 +1,169 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"fmt"
+)
+
+// BytesStorable is a Storable holding raw bytes, so plain byte slices can be
+// stored in a MerkleTree without a wrapper type of their own.
+//
+// Its hash is the SHA-256 digest of the bytes, the default hash strategy of
+// NewTree. FlatMerkleTree hashes its leaves as SHA-256(0x00 || block) instead,
+// and hashes internal nodes with a 0x01 prefix, so a MerkleTree built from
+// BytesStorable items never has the same root as a FlatMerkleTree built from
+// the same blocks, with or without WithDomainSeparation.
+type BytesStorable []byte
+
+// CalculateHash returns the SHA-256 digest of b.
+func (b BytesStorable) CalculateHash() ([]byte, error) {
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// Equals reports whether other is a BytesStorable holding the same bytes.
+func (b BytesStorable) Equals(other Storable) (bool, error) {
+	o, ok := other.(BytesStorable)
+	if !ok {
+		return false, nil
+	}
+
+	return bytes.Equal(b, o), nil
+}
+
+// NewTreeFromBytes creates a new merkle tree storing each of items as a
+// BytesStorable.
+func NewTreeFromBytes(items [][]byte, opts ...Option) (*MerkleTree, error) {
+	content := make([]Storable, len(items))
+	for i, item := range items {
+		content[i] = BytesStorable(item)
+	}
+
+	return NewTreeWithOptions(content, opts...)
+}
+
+// StorableFunc adapts a pair of functions into a Storable, for content whose
+// type cannot be given methods of its own.
+type StorableFunc struct {
+	HashFn   func() ([]byte, error)
+	EqualsFn func(other Storable) (bool, error)
+}
+
+// CalculateHash returns the result of HashFn.
+func (s StorableFunc) CalculateHash() ([]byte, error) {
+	return s.HashFn()
+}
+
+// Equals returns the result of EqualsFn. Without an EqualsFn, s equals other
+// when both hash to the same value.
+func (s StorableFunc) Equals(other Storable) (bool, error) {
+	if s.EqualsFn != nil {
+		return s.EqualsFn(other)
+	}
+
+	return sameHash(s, other)
+}
+
+// NewStorable returns a StorableFunc for data whose hash is the SHA-256 digest
+// of the bytes encode produces for it. It equals any Storable hashing to the
+// same value.
+func NewStorable(data any, encode func(any) ([]byte, error)) StorableFunc {
+	s := StorableFunc{
+		HashFn: func() ([]byte, error) {
+			b, err := encode(data)
+			if err != nil {
+				return nil, err
+			}
+
+			sum := sha256.Sum256(b)
+			return sum[:], nil
+		},
+	}
+	s.EqualsFn = func(other Storable) (bool, error) {
+		return sameHash(s, other)
+	}
+
+	return s
+}
+
+// SafeEquals reports whether a equals b according to a.Equals. A panic raised
+// by Equals, such as a failed type assertion on an item of another type, is
+// returned as an error instead.
+func SafeEquals(a, b Storable) (ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok, err = false, fmt.Errorf("error: cannot compare %T with %T: %v", a, b, r)
+		}
+	}()
+
+	return a.Equals(b)
+}
+
+// sameHash reports whether a and b hash to the same value.
+func sameHash(a, b Storable) (bool, error) {
+	ha, err := a.CalculateHash()
+	if err != nil {
+		return false, err
+	}
+
+	hb, err := b.CalculateHash()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(ha, hb), nil
+}
+
+// binaryStorable is a Storable over an encoding.BinaryMarshaler.
+type binaryStorable struct {
+	m encoding.BinaryMarshaler
+}
+
+// WrapBinaryMarshaler returns a Storable whose hash is the SHA-256 digest, the
+// default hash strategy, of the bytes m marshals to. Two wrapped values are
+// equal when they marshal to the same bytes. Marshaling errors are returned by
+// CalculateHash, and so by NewTree along with the index of the item.
+func WrapBinaryMarshaler(m encoding.BinaryMarshaler) Storable {
+	return binaryStorable{m: m}
+}
+
+// WrapAll wraps every element of ms with WrapBinaryMarshaler.
+func WrapAll[T encoding.BinaryMarshaler](ms []T) []Storable {
+	content := make([]Storable, len(ms))
+	for i, m := range ms {
+		content[i] = WrapBinaryMarshaler(m)
+	}
+
+	return content
+}
+
+func (b binaryStorable) CalculateHash() ([]byte, error) {
+	data, err := b.m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+func (b binaryStorable) Equals(other Storable) (bool, error) {
+	o, ok := other.(binaryStorable)
+	if !ok {
+		return false, nil
+	}
+
+	data, err := b.m.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+
+	otherData, err := o.m.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(data, otherData), nil
+}
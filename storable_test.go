@@ -0,0 +1,233 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNewTreeFromBytes(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		items := make([][]byte, len(table[i].contents))
+		for j, c := range table[i].contents {
+			items[j] = []byte(c.(TestSHA256Content).x)
+		}
+
+		tree, err := NewTreeFromBytes(items)
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(tree.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, tree.MerkleRoot())
+		}
+
+		idx, err := tree.ContentIndex(BytesStorable(items[len(items)-1]))
+		if err != nil || idx != len(items)-1 {
+			t.Errorf("[case:%d] error: expected index %d got %d (%v)", table[i].testCaseId, len(items)-1, idx, err)
+		}
+	}
+
+	if _, err := NewTreeFromBytes(nil); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+}
+
+func TestBytesStorableEquals(t *testing.T) {
+	b := BytesStorable("Hello")
+
+	if ok, err := b.Equals(BytesStorable("Hello")); !ok || err != nil {
+		t.Errorf("error: expected equal contents got %t (%v)", ok, err)
+	}
+	if ok, err := b.Equals(BytesStorable("Hi")); ok || err != nil {
+		t.Errorf("error: expected different contents got %t (%v)", ok, err)
+	}
+	if ok, err := b.Equals(TestSHA256Content{x: "Hello"}); ok || err != nil {
+		t.Errorf("error: expected a different type not to be equal got %t (%v)", ok, err)
+	}
+}
+
+func TestBytesStorableRootsNotComparableToFlat(t *testing.T) {
+	blocks := []Block{Block("blockA"), Block("blockB"), Block("blockC"), Block("blockD")}
+
+	flat := NewMerkleTree(blocks...)
+	if err := flat.Finalize(); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	flatRoot, _ := flat.RootHash()
+
+	items := make([][]byte, len(blocks))
+	for i, b := range blocks {
+		items[i] = b
+	}
+
+	for _, separated := range []bool{false, true} {
+		tree, err := NewTreeFromBytes(items, WithDomainSeparation(separated))
+		if err != nil {
+			t.Fatalf("error: unexpected error: %v", err)
+		}
+		if bytes.Equal(tree.MerkleRoot(), flatRoot) {
+			t.Errorf("error: domain separation %t: expected roots to differ", separated)
+		}
+	}
+}
+
+type testRecord struct {
+	ID   int
+	Name string
+}
+
+func TestStorableFunc(t *testing.T) {
+	records := []testRecord{{1, "Hello"}, {2, "Hi"}, {3, "Hey"}}
+
+	content := []Storable{
+		NewStorable(records[0], json.Marshal),
+		TestSHA256Content{x: "Hola"},
+		NewStorable(records[1], json.Marshal),
+		BytesStorable("Greetings"),
+		NewStorable(records[2], json.Marshal),
+	}
+
+	tree, err := NewTree(content)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	verified, err := tree.Root.VerifyNode()
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if bytes.Compare(verified, tree.MerkleRoot()) != 0 {
+		t.Errorf("error: expected verified hash equal to %v got %v", tree.MerkleRoot(), verified)
+	}
+
+	for i, c := range content {
+		if idx, err := tree.ContentIndex(c); err != nil || idx != i {
+			t.Errorf("error: expected index %d got %d (%v)", i, idx, err)
+		}
+	}
+
+	encoded, _ := json.Marshal(records[1])
+	if ok, err := content[2].Equals(BytesStorable(encoded)); !ok || err != nil {
+		t.Errorf("error: expected contents with the same encoding to be equal got %t (%v)", ok, err)
+	}
+	if ok, err := content[2].Equals(content[0]); ok || err != nil {
+		t.Errorf("error: expected different records not to be equal got %t (%v)", ok, err)
+	}
+
+	failing := NewStorable(func() {}, json.Marshal)
+	if _, err := NewTree([]Storable{content[0], failing}); err == nil {
+		t.Errorf("error: expected the encoding error to be returned")
+	}
+}
+
+func TestStorableFuncCustomEquals(t *testing.T) {
+	s := StorableFunc{
+		HashFn: func() ([]byte, error) { return []byte("hash"), nil },
+		EqualsFn: func(other Storable) (bool, error) {
+			return false, errors.New("error: not comparable")
+		},
+	}
+
+	if _, err := s.Equals(s); err == nil {
+		t.Errorf("error: expected EqualsFn to be used")
+	}
+
+	s.EqualsFn = nil
+	if ok, err := s.Equals(s); !ok || err != nil {
+		t.Errorf("error: expected hash comparison without EqualsFn got %t (%v)", ok, err)
+	}
+}
+
+// testMarshaler is an encoding.BinaryMarshaler that can be made to fail.
+type testMarshaler struct {
+	data string
+	fail bool
+}
+
+func (m testMarshaler) MarshalBinary() ([]byte, error) {
+	if m.fail {
+		return nil, errors.New("error: cannot marshal")
+	}
+
+	return []byte(m.data), nil
+}
+
+func TestWrapBinaryMarshaler(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		ms := make([]testMarshaler, len(table[i].contents))
+		for j, c := range table[i].contents {
+			ms[j] = testMarshaler{data: c.(TestSHA256Content).x}
+		}
+
+		tree, err := NewTree(WrapAll(ms))
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(tree.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, tree.MerkleRoot())
+		}
+	}
+
+	a := WrapBinaryMarshaler(testMarshaler{data: "Hello"})
+	if ok, err := a.Equals(WrapBinaryMarshaler(testMarshaler{data: "Hello"})); !ok || err != nil {
+		t.Errorf("error: expected equal contents got %t (%v)", ok, err)
+	}
+	if ok, err := a.Equals(WrapBinaryMarshaler(testMarshaler{data: "Hi"})); ok || err != nil {
+		t.Errorf("error: expected different contents got %t (%v)", ok, err)
+	}
+	if ok, err := a.Equals(BytesStorable("Hello")); ok || err != nil {
+		t.Errorf("error: expected a different type not to be equal got %t (%v)", ok, err)
+	}
+}
+
+func TestWrapBinaryMarshalerError(t *testing.T) {
+	ms := []testMarshaler{{data: "Hello"}, {data: "Hi"}, {fail: true}, {data: "Hola"}}
+
+	_, err := NewTree(WrapAll(ms))
+
+	var contentErr *ContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("error: expected a ContentError got %v", err)
+	}
+	if contentErr.Index != 2 {
+		t.Errorf("error: expected failing index 2 got %d", contentErr.Index)
+	}
+}
+
+func TestMixedStorableLookups(t *testing.T) {
+	tree, err := NewTree(paddingContents(4))
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	other := BytesStorable("not stored")
+	if _, err := tree.ContentIndex(other); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if _, err := tree.MerklePath(other); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if err := tree.UpdateLeaf(other, BytesStorable("new")); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+	if err := tree.Remove(other); err != ErrContentNotFound {
+		t.Errorf("error: expected ErrContentNotFound got %v", err)
+	}
+
+	// Items of another type hashing to a stored value are found by their hash.
+	if idx, err := tree.ContentIndex(BytesStorable("leaf-2")); err != nil || idx != 2 {
+		t.Errorf("error: expected index 2 got %d (%v)", idx, err)
+	}
+}
+
+func TestSafeEquals(t *testing.T) {
+	a := TestSHA256Content{x: "Hello"}
+
+	if ok, err := SafeEquals(a, TestSHA256Content{x: "Hello"}); !ok || err != nil {
+		t.Errorf("error: expected equal contents got %t (%v)", ok, err)
+	}
+	if ok, err := SafeEquals(a, BytesStorable("Hello")); ok || err == nil {
+		t.Errorf("error: expected an error comparing mixed types got %t (%v)", ok, err)
+	}
+}
@@ -0,0 +1,135 @@
+package merklego
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// SyncSource serves the node hashes of a tree, typically a remote replica
+// reached over RPC, to reconcile it with a local tree with FindDivergence.
+//
+// The nodes are numbered as the binary heap of a FlatMerkleTree: the root is
+// the node 0 of level 0, and the children of the node i of a level are the
+// nodes 2i and 2i+1 of the level below. The last level of a tree whose number
+// of leaves is not a power of two only holds the children of the first nodes
+// of the level above.
+type SyncSource interface {
+	// NodeHashes returns the hashes of the count nodes of level from the node
+	// start.
+	NodeHashes(level, start, count int) ([][]byte, error)
+}
+
+var _ SyncSource = (*FlatMerkleTree)(nil)
+
+// NodeHashes returns the hashes of the count nodes of level from the node
+// start, as SyncSource serves them.
+func (mt *FlatMerkleTree) NodeHashes(level, start, count int) ([][]byte, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+	if level < 0 || level >= 62 || 1<<uint(level)-1 >= len(mt.nodes) {
+		return nil, fmt.Errorf("invalid level %d of a tree of %d nodes", level, len(mt.nodes))
+	}
+
+	first := 1<<uint(level) - 1
+	width := len(mt.nodes) - first
+	if width > first+1 {
+		width = first + 1
+	}
+	if start < 0 || count < 0 || start+count > width {
+		return nil, fmt.Errorf("nodes [%d, %d) out of range [0, %d) of level %d", start, start+count, width, level)
+	}
+
+	hashes := make([][]byte, count)
+	for i := range hashes {
+		hashes[i] = copyNode(mt.nodes[first+start+i])
+	}
+
+	return hashes, nil
+}
+
+// FindDivergence returns the indexes of the blocks on which local, a finalized
+// binary tree, and remote differ, in increasing order. Remote must serve a
+// tree of the same size and hashing, such as a replica of the same data: the
+// nodes of trees of other sizes do not cover the same blocks, and the indexes
+// found are meaningless when remote does not fail to serve the nodes asked.
+//
+// FindDivergence walks down both trees a level at a time from their roots,
+// only asking remote for the children of the nodes that differ, in one call
+// per run of consecutive nodes on each level: a single differing block takes
+// one call per level, about log2(n).
+func FindDivergence(local *FlatMerkleTree, remote SyncSource) ([]int, error) {
+	if !local.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := local.checkBinary(); err != nil {
+		return nil, err
+	}
+	root, err := remote.NodeHashes(0, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch the remote root: %w", err)
+	}
+	if len(root) != 1 {
+		return nil, fmt.Errorf("remote returned %d hashes for its root", len(root))
+	}
+
+	var diff []int
+	pending, err := local.divergent(0, 0, root, &diff)
+	if err != nil {
+		return nil, err
+	}
+	for level := 1; len(pending) > 0; level++ {
+		var next []int
+		for len(pending) > 0 {
+			// Children of consecutive nodes are consecutive.
+			run := 1
+			for run < len(pending) && pending[run] == pending[run-1]+1 {
+				run++
+			}
+			start, count := 2*pending[0], 2*run
+			hashes, err := remote.NodeHashes(level, start, count)
+			if err != nil {
+				return nil, fmt.Errorf("cannot fetch the remote nodes [%d, %d) of level %d: %w", start, start+count, level, err)
+			}
+			if len(hashes) != count {
+				return nil, fmt.Errorf("remote returned %d hashes for the %d nodes [%d, %d) of level %d", len(hashes), count, start, start+count, level)
+			}
+			nodes, err := local.divergent(level, start, hashes, &diff)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, nodes...)
+			pending = pending[run:]
+		}
+		pending = next
+	}
+	sort.Ints(diff)
+
+	return diff, nil
+}
+
+// divergent compares hashes with the nodes of level from start. It appends to
+// diff the indexes of the differing leaves, padding aside, and returns the
+// differing internal nodes, by increasing position on the level.
+func (mt *FlatMerkleTree) divergent(level, start int, hashes [][]byte, diff *[]int) ([]int, error) {
+	first := len(mt.nodes) - len(mt.blocks)
+	var nodes []int
+	for i, h := range hashes {
+		pos := 1<<uint(level) - 1 + start + i
+		switch {
+		case pos >= len(mt.nodes):
+			return nil, fmt.Errorf("remote has node %d of level %d, missing from a tree of %d blocks", start+i, level, mt.count)
+		case bytes.Equal(mt.nodes[pos], h):
+		case pos < first:
+			nodes = append(nodes, start+i)
+		case pos-first < mt.count:
+			*diff = append(*diff, pos-first)
+		}
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,102 @@
+package merklego
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource is an in-memory remote counting the calls made to it.
+type countingSource struct {
+	tree  *FlatMerkleTree
+	calls int
+	err   error
+}
+
+func (s *countingSource) NodeHashes(level, start, count int) ([][]byte, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s.tree.NodeHashes(level, start, count)
+}
+
+func TestNodeHashes(t *testing.T) {
+	tree := diffTree(t, 5)
+
+	root, err := tree.NodeHashes(0, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{tree.root}, root)
+
+	// The 6 padded leaves of 11 nodes leave 4 nodes on the last level.
+	last, err := tree.NodeHashes(3, 0, 4)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{tree.nodes[7], tree.nodes[8], tree.nodes[9], tree.nodes[10]}, last)
+
+	_, err = tree.NodeHashes(3, 2, 3)
+	require.Error(t, err)
+	_, err = tree.NodeHashes(4, 0, 1)
+	require.Error(t, err)
+	_, err = tree.NodeHashes(-1, 0, 1)
+	require.Error(t, err)
+	_, err = NewMerkleTree(Block("a")).NodeHashes(0, 0, 1)
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+}
+
+func TestFindDivergence(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 100, 1 << 12, 5000} {
+		local := diffTree(t, n)
+
+		remote := &countingSource{tree: diffTree(t, n)}
+		diff, err := FindDivergence(local, remote)
+		require.NoError(t, err)
+		require.Empty(t, diff)
+		require.Equal(t, 1, remote.calls)
+
+		for _, i := range []int{0, n / 2, n - 1} {
+			remote := &countingSource{tree: diffTree(t, n, i)}
+			diff, err := FindDivergence(local, remote)
+			require.NoError(t, err)
+			require.Equal(t, []int{i}, diff)
+			// A call for the root then one per level below it.
+			require.LessOrEqual(t, remote.calls, bits.Len(uint(n))+1, "%d blocks, block %d", n, i)
+		}
+	}
+}
+
+func TestFindDivergenceRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for k := 0; k < 100; k++ {
+		n := 1 + rng.Intn(300)
+		var changed []int
+		for i := 0; i < n; i++ {
+			if rng.Intn(20) == 0 {
+				changed = append(changed, i)
+			}
+		}
+
+		local, remote := diffTree(t, n), diffTree(t, n, changed...)
+		want, err := Diff(local, remote)
+		require.NoError(t, err)
+		diff, err := FindDivergence(local, remote)
+		require.NoError(t, err)
+		require.Equal(t, want, diff)
+	}
+}
+
+func TestFindDivergenceErrors(t *testing.T) {
+	local := diffTree(t, 8)
+
+	failure := errors.New("connection reset")
+	_, err := FindDivergence(local, &countingSource{tree: local, err: failure})
+	require.ErrorIs(t, err, failure)
+
+	_, err = FindDivergence(diffTree(t, 20, 19), local)
+	require.Error(t, err)
+	_, err = FindDivergence(NewMerkleTree(Block("a")), local)
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+}
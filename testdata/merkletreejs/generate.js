@@ -0,0 +1,42 @@
+// Generates a fixture for every combination of the sortPairs, sortLeaves and
+// duplicateOdd options of a merkletreejs MerkleTree over SHA-256 leaf hashes,
+// with its root and the proof of every leaf as JSON.stringify writes getProof,
+// with Buffer data, and as getHexProof returns it.
+//
+//	npm install merkletreejs@0.3.11 && node generate.js
+
+const crypto = require('crypto');
+const fs = require('fs');
+const { MerkleTree } = require('merkletreejs');
+
+const sha256 = (data) => crypto.createHash('sha256').update(data).digest();
+
+const names = ['ant', 'bee', 'cat', 'dog', 'eel', 'fox', 'gnu', 'hen', 'ibex', 'jay', 'koi'];
+
+for (const sortPairs of [false, true]) {
+  for (const sortLeaves of [false, true]) {
+    for (const duplicateOdd of [false, true]) {
+      const options = { sortPairs, sortLeaves, duplicateOdd };
+      const tree = new MerkleTree(names.map((n) => sha256(Buffer.from(n))), sha256, options);
+      const leaves = tree.getLeaves();
+
+      const fixture = {
+        options,
+        root: tree.getHexRoot(),
+        proofs: leaves.map((leaf, index) => {
+          const p = {
+            leaf: '0x' + leaf.toString('hex'),
+            index,
+            size: leaves.length,
+            proof: JSON.parse(JSON.stringify(tree.getProof(leaf, index))),
+          };
+          if (sortPairs) p.hexProof = tree.getHexProof(leaf, index);
+          return p;
+        }),
+      };
+
+      const name = Object.keys(options).filter((k) => options[k]).join('-') || 'default';
+      fs.writeFileSync(`${name}.json`, JSON.stringify(fixture, null, 2) + '\n');
+    }
+  }
+}
@@ -0,0 +1,29 @@
+"""Generates fixture.json: the pieces root and the piece layer libtorrent
+computes for fixture.bin in a BitTorrent v2 torrent of 32 KiB pieces, read
+back from the bencoded torrent it creates.
+
+    python3 generate.py  # needs the libtorrent 2.x python bindings
+"""
+
+import json
+
+import libtorrent as lt
+
+PIECE_LENGTH = 32 << 10
+
+fs = lt.file_storage()
+lt.add_files(fs, 'fixture.bin')
+ct = lt.create_torrent(fs, PIECE_LENGTH, flags=lt.create_torrent.v2_only)
+lt.set_piece_hashes(ct, '.')
+torrent = lt.bdecode(lt.bencode(ct.generate()))
+
+root = torrent[b'info'][b'file tree'][b'fixture.bin'][b''][b'pieces root']
+layer = torrent.get(b'piece layers', {}).get(root, b'')
+
+with open('fixture.json', 'w') as f:
+    json.dump({
+        'pieceLength': PIECE_LENGTH,
+        'piecesRoot': root.hex(),
+        'pieceLayer': [layer[i:i + 32].hex() for i in range(0, len(layer), 32)],
+    }, f, indent=2)
+    f.write('\n')
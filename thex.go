@@ -0,0 +1,141 @@
+package merklego
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// THEXSegmentSize is the size of the segments a THEX tree splits a file into,
+// the last one being shorter.
+const THEXSegmentSize = 1024
+
+// thexEncoding is the base32 encoding of Tiger tree hashes: RFC 4648 base32
+// without padding, 39 characters for a 24 byte root.
+var thexEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// THEXTree is the Tiger tree hash (TTH) of a file, as the Tree Hash EXchange
+// format defines it and DC++ and ed2k style networks identify files with: the
+// file is split into THEXSegmentSize byte segments, hashed with Tiger as
+// Tiger(0x00 || segment), and pairs of nodes are hashed as
+// Tiger(0x01 || left || right) level by level, the last node of a level with
+// an odd number of them being promoted to the next one unhashed. An empty file
+// has a single empty segment.
+//
+// This is the structure of RFC 6962, which a LogTree has, over Tiger.
+type THEXTree struct {
+	root     []byte
+	size     int64
+	segments int
+}
+
+var _ Rooter = (*THEXTree)(nil)
+
+// NewTHEXTree reads r to its end and returns its Tiger tree. The file is
+// streamed, holding one segment and a node per level of the tree at a time.
+func NewTHEXTree(r io.Reader) (*THEXTree, error) {
+	h := newTiger()
+	t := &THEXTree{}
+
+	// pending[l] is the root of the last complete subtree of 2^l segments
+	// not yet paired, nil when there is none.
+	var pending [][]byte
+	segment := make([]byte, THEXSegmentSize)
+	for {
+		n, err := io.ReadFull(r, segment)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return nil, fmt.Errorf("cannot read segment %d: %w", t.segments, err)
+		}
+		// Only an empty file has an empty segment.
+		if n == 0 && t.segments > 0 {
+			break
+		}
+
+		t.size += int64(n)
+		t.segments++
+
+		h.Reset()
+		h.Write([]byte{leafNodePrefix})
+		h.Write(segment[:n])
+		node := h.Sum(nil)
+		for l := 0; ; l++ {
+			if l == len(pending) {
+				pending = append(pending, nil)
+			}
+			if pending[l] == nil {
+				pending[l] = node
+				break
+			}
+
+			node = thexPair(h, pending[l], node)
+			pending[l] = nil
+		}
+
+		if last {
+			break
+		}
+	}
+
+	// Promote the unpaired nodes, from the lowest level up.
+	for _, node := range pending {
+		switch {
+		case node == nil:
+		case t.root == nil:
+			t.root = node
+		default:
+			t.root = thexPair(h, node, t.root)
+		}
+	}
+
+	return t, nil
+}
+
+// thexPair hashes left and right into their parent with h.
+func thexPair(h hash.Hash, left, right []byte) []byte {
+	h.Reset()
+	h.Write([]byte{internalNodePrefix})
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// RootHash returns the 24 byte Tiger tree hash of the file.
+func (t *THEXTree) RootHash() ([]byte, error) {
+	return copyNode(t.root), nil
+}
+
+// Size returns the size of the file in bytes.
+func (t *THEXTree) Size() int64 {
+	return t.size
+}
+
+// LeafCount returns the number of segments of the file, 1 for an empty one.
+func (t *THEXTree) LeafCount() int {
+	return t.segments
+}
+
+// TTH returns the root in its usual base32 form, as found in magnet links
+// after "urn:tree:tiger:".
+func (t *THEXTree) TTH() string {
+	return thexEncoding.EncodeToString(t.root)
+}
+
+// ErrInvalidTTH is returned by ParseTTH for strings that are not the base32
+// form of a Tiger tree hash.
+var ErrInvalidTTH = errors.New("error: invalid tiger tree hash")
+
+// ParseTTH decodes s, a Tiger tree hash in the base32 form TTH returns, into
+// a root. Lower case is accepted.
+func ParseTTH(s string) ([]byte, error) {
+	root, err := thexEncoding.DecodeString(strings.ToUpper(s))
+	if err != nil || len(root) != tigerSize {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTTH, s)
+	}
+
+	return root, nil
+}
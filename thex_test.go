@@ -0,0 +1,123 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiger(t *testing.T) {
+	for input, want := range map[string]string{
+		"":      "3293ac630c13f0245f92bbb1766e16167a4e58492dde73f3",
+		"abc":   "2aab1484e8c158f2bfb8c5ff41b57a525129131c957b5f93",
+		"Tiger": "dd00230799f5009fec6debc838bb6a27df2b9d6f110c7937",
+	} {
+		h := newTiger()
+		h.Write([]byte(input))
+		require.Equal(t, want, hex.EncodeToString(h.Sum(nil)), input)
+	}
+
+	// Writes split across blocks hash as a single one, and Sum does not
+	// consume the state.
+	data := bytes.Repeat([]byte("0123456789"), 30)
+	whole := newTiger()
+	whole.Write(data)
+	split := newTiger()
+	for _, chunk := range [][]byte{data[:1], data[1:63], data[63:64], data[64:200], data[200:]} {
+		split.Write(chunk)
+		split.Sum(nil)
+	}
+	require.Equal(t, whole.Sum(nil), split.Sum(nil))
+}
+
+// thexVectors are the Tiger tree hashes of the THEX draft test vectors.
+var thexVectors = []struct {
+	data string
+	tth  string
+}{
+	{"", "LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLNQ"},
+	{"\x00", "VK54ZIEEVTWNAUI5D5RDFIL37LX2IQNSTAXFKSA"},
+	{strings.Repeat("A", 1024), "L66Q4YVNAFWVS23X2HJIRA5ZJ7WXR3F26RSASFA"},
+	{strings.Repeat("A", 1025), "PZMRYHGY6LTBEH63ZWAHDORHSYTLO4LEFUIKHWY"},
+}
+
+func TestTHEXVectors(t *testing.T) {
+	for _, v := range thexVectors {
+		tree, err := NewTHEXTree(strings.NewReader(v.data))
+		require.NoError(t, err)
+		require.Equal(t, v.tth, tree.TTH(), "%d bytes", len(v.data))
+		require.Equal(t, int64(len(v.data)), tree.Size())
+
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		parsed, err := ParseTTH(strings.ToLower(v.tth))
+		require.NoError(t, err)
+		require.Equal(t, root, parsed)
+
+		// Reading byte by byte does not change the segments.
+		slow, err := NewTHEXTree(iotest.OneByteReader(strings.NewReader(v.data)))
+		require.NoError(t, err)
+		require.Equal(t, v.tth, slow.TTH())
+	}
+
+	_, err := ParseTTH("LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLN")
+	require.ErrorIs(t, err, ErrInvalidTTH)
+	_, err = ParseTTH("LWPNACQDBZRYXW3VHJVCJ64QBZNGHOHHHZWCLN1")
+	require.ErrorIs(t, err, ErrInvalidTTH)
+}
+
+// thexReference hashes segments level by level, promoting the last node of
+// odd levels, as the THEX draft describes the tree.
+func thexReference(data []byte) []byte {
+	var level [][]byte
+	for off := 0; off == 0 || off < len(data); off += THEXSegmentSize {
+		end := off + THEXSegmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		h := newTiger()
+		h.Write([]byte{leafNodePrefix})
+		h.Write(data[off:end])
+		level = append(level, h.Sum(nil))
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, thexPair(newTiger(), level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+func TestTHEXPromotion(t *testing.T) {
+	for _, segments := range []int{2, 3, 5, 6, 7, 11, 16, 17} {
+		data := bytes.Repeat([]byte{byte(segments)}, segments*THEXSegmentSize-100)
+		tree, err := NewTHEXTree(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, segments, tree.LeafCount())
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		require.Equal(t, thexReference(data), root, "%d segments", segments)
+	}
+
+	tree, err := NewTHEXTree(bytes.NewReader(make([]byte, 4*THEXSegmentSize)))
+	require.NoError(t, err)
+	require.Equal(t, 4, tree.LeafCount())
+
+	failure := errors.New("read failure")
+	_, err = NewTHEXTree(iotest.TimeoutReader(bytes.NewReader(make([]byte, 3000))))
+	require.Error(t, err)
+	_, err = NewTHEXTree(iotest.ErrReader(failure))
+	require.ErrorIs(t, err, failure)
+}
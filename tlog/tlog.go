@@ -0,0 +1,114 @@
+// Package merkletlog makes a LogTree the builder side of a transparency log
+// whose clients use golang.org/x/mod/sumdb/tlog, the log of the Go checksum
+// database. tlog hashes records as SHA-256(0x00 || data) and nodes as
+// SHA-256(0x01 || left || right) in the RFC 6962 structure, as LogTree does,
+// so the roots and proofs of a LogTree are those of tlog once converted to its
+// types. It is a separate package so the core does not depend on x/mod.
+package merkletlog
+
+import (
+	"fmt"
+
+	merklego "github.com/evalir/merkle-go"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// HashReader returns a tlog.HashReader serving the hashes tlog stores, by
+// their StoredHashIndex, from tree, so tlog.TreeHash, tlog.ProveRecord and
+// tlog.ProveTree can run over it.
+func HashReader(tree *merklego.LogTree) tlog.HashReader {
+	return tlog.HashReaderFunc(func(indexes []int64) ([]tlog.Hash, error) {
+		hashes := make([]tlog.Hash, len(indexes))
+		for i, index := range indexes {
+			level, n := tlog.SplitStoredHashIndex(index)
+			h, err := tree.SubtreeHash(level, int(n))
+			if err != nil {
+				return nil, err
+			}
+			if hashes[i], err = toHash(h); err != nil {
+				return nil, err
+			}
+		}
+
+		return hashes, nil
+	})
+}
+
+// Head returns the tlog.Tree of tree, its size and root. tlog gives the empty
+// tree the zero hash where RFC 6962, and RootHash, hash the empty string, so
+// Head does too.
+func Head(tree *merklego.LogTree) (tlog.Tree, error) {
+	if tree.Size() == 0 {
+		return tlog.Tree{}, nil
+	}
+
+	root, err := tree.RootHash()
+	if err != nil {
+		return tlog.Tree{}, err
+	}
+	h, err := toHash(root)
+	if err != nil {
+		return tlog.Tree{}, err
+	}
+
+	return tlog.Tree{N: int64(tree.Size()), Hash: h}, nil
+}
+
+// ProveRecord returns the proof that the tree of the first t leaves of tree
+// contains the record n, as tlog.ProveRecord does, for tlog.CheckRecord.
+func ProveRecord(tree *merklego.LogTree, t, n int64) (tlog.RecordProof, error) {
+	proof, err := tree.InclusionProof(int(n), int(t))
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := toHashes(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	return tlog.RecordProof(hashes), nil
+}
+
+// ProveTree returns the proof that the tree of the first t leaves of tree
+// contains the tree of its first n leaves as a prefix, as tlog.ProveTree does,
+// for tlog.CheckTree.
+func ProveTree(tree *merklego.LogTree, t, n int64) (tlog.TreeProof, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid old tree size %d", n)
+	}
+
+	proof, err := tree.ConsistencyProof(int(n), int(t))
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := toHashes(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	return tlog.TreeProof(hashes), nil
+}
+
+func toHashes(nodes []merklego.TreeNode) ([]tlog.Hash, error) {
+	hashes := make([]tlog.Hash, len(nodes))
+	for i, node := range nodes {
+		var err error
+		if hashes[i], err = toHash(node); err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, nil
+}
+
+func toHash(b []byte) (tlog.Hash, error) {
+	var h tlog.Hash
+	if len(b) != tlog.HashSize {
+		return h, fmt.Errorf("hash has %d bytes, want %d", len(b), tlog.HashSize)
+	}
+	copy(h[:], b)
+
+	return h, nil
+}
@@ -0,0 +1,125 @@
+package merkletlog
+
+import (
+	"fmt"
+	"testing"
+
+	merklego "github.com/evalir/merkle-go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// storage is a tlog.HashReader over the hashes tlog itself stores.
+type storage []tlog.Hash
+
+func (s storage) ReadHashes(indexes []int64) ([]tlog.Hash, error) {
+	hashes := make([]tlog.Hash, len(indexes))
+	for i, index := range indexes {
+		if index >= int64(len(s)) {
+			return nil, fmt.Errorf("no stored hash %d", index)
+		}
+		hashes[i] = s[index]
+	}
+
+	return hashes, nil
+}
+
+func TestTlogCompat(t *testing.T) {
+	const size = 37
+
+	tree, err := merklego.NewLogTree()
+	require.NoError(t, err)
+	var stored storage
+	var records [][]byte
+
+	for i := int64(0); i < size; i++ {
+		record := []byte(fmt.Sprintf("record %d", i))
+		records = append(records, record)
+		require.NoError(t, tree.Append(record))
+
+		hashes, err := tlog.StoredHashes(i, record, stored)
+		require.NoError(t, err)
+		stored = append(stored, hashes...)
+
+		want, err := tlog.TreeHash(i+1, stored)
+		require.NoError(t, err)
+		head, err := Head(tree)
+		require.NoError(t, err)
+		require.Equal(t, tlog.Tree{N: i + 1, Hash: want}, head)
+	}
+
+	for index := range stored {
+		want, err := stored.ReadHashes([]int64{int64(index)})
+		require.NoError(t, err)
+		got, err := HashReader(tree).ReadHashes([]int64{int64(index)})
+		require.NoError(t, err)
+		require.Equal(t, want, got, fmt.Sprintf("index: %d", index))
+	}
+
+	for n := int64(1); n <= size; n++ {
+		th, err := tlog.TreeHash(n, stored)
+		require.NoError(t, err)
+
+		for i := int64(0); i < n; i++ {
+			want, err := tlog.ProveRecord(n, i, stored)
+			require.NoError(t, err)
+			got, err := ProveRecord(tree, n, i)
+			require.NoError(t, err)
+			require.Equal(t, []tlog.Hash(want), []tlog.Hash(got), fmt.Sprintf("tree: %d, record: %d", n, i))
+			require.NoError(t, tlog.CheckRecord(got, n, th, i, tlog.RecordHash(records[i])))
+		}
+
+		for old := int64(1); old <= n; old++ {
+			oh, err := tlog.TreeHash(old, stored)
+			require.NoError(t, err)
+
+			want, err := tlog.ProveTree(n, old, stored)
+			require.NoError(t, err)
+			got, err := ProveTree(tree, n, old)
+			require.NoError(t, err)
+			require.Equal(t, len(want), len(got), fmt.Sprintf("tree: %d, old: %d", n, old))
+			if len(want) > 0 {
+				require.Equal(t, []tlog.Hash(want), []tlog.Hash(got))
+			}
+			require.NoError(t, tlog.CheckTree(got, n, th, old, oh))
+		}
+	}
+}
+
+func TestTlogCompatReader(t *testing.T) {
+	tree, err := merklego.NewLogTree([]byte("a"), []byte("b"), []byte("c"))
+	require.NoError(t, err)
+
+	th, err := tlog.TreeHash(3, HashReader(tree))
+	require.NoError(t, err)
+	head, err := Head(tree)
+	require.NoError(t, err)
+	require.Equal(t, head.Hash, th)
+
+	p, err := tlog.ProveRecord(3, 2, HashReader(tree))
+	require.NoError(t, err)
+	require.NoError(t, tlog.CheckRecord(p, 3, th, 2, tlog.RecordHash([]byte("c"))))
+	require.Error(t, tlog.CheckRecord(p, 3, th, 1, tlog.RecordHash([]byte("c"))))
+
+	_, err = HashReader(tree).ReadHashes([]int64{tlog.StoredHashIndex(1, 1)})
+	require.Error(t, err)
+}
+
+func TestTlogCompatErrors(t *testing.T) {
+	empty, err := merklego.NewLogTree()
+	require.NoError(t, err)
+	head, err := Head(empty)
+	require.NoError(t, err)
+	require.Equal(t, tlog.Tree{}, head)
+
+	tree, err := merklego.NewLogTree([]byte("a"), []byte("b"))
+	require.NoError(t, err)
+	_, err = ProveRecord(tree, 2, 2)
+	require.Error(t, err)
+	_, err = ProveRecord(tree, 3, 0)
+	require.Error(t, err)
+	_, err = ProveTree(tree, 2, 0)
+	require.Error(t, err)
+	_, err = ProveTree(tree, 1, 2)
+	require.Error(t, err)
+}
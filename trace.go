@@ -0,0 +1,132 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// VerifyStep is a step of a VerifyTrace, hashing the node reached so far
+// with a proof chunk into its parent.
+type VerifyStep struct {
+	Level   int
+	Node    TreeNode
+	Sibling TreeNode
+	// Left is set when the sibling is hashed on the left of the node.
+	Left     bool
+	Computed TreeNode
+	// Expected is the parent held by the tree, nil past the root.
+	Expected TreeNode
+}
+
+// Match reports whether the step computed the parent held by the tree.
+func (s *VerifyStep) Match() bool {
+	return s.Expected != nil && RootEqual(s.Computed, s.Expected)
+}
+
+// VerifyTrace records how a proof was checked against a FlatMerkleTree.
+type VerifyTrace struct {
+	Block Block
+	Index int
+	Leaf  TreeNode
+	Root  TreeNode
+	Steps []VerifyStep
+	// Diverged is the level of the first step computing a parent the tree
+	// does not hold, len(Steps) when the proof stops below the root, and -1
+	// when the proof is valid.
+	Diverged int
+}
+
+// VerifyTrace checks proof for block as Verify does, returning the same error,
+// along with the trace of every step of the verification, valid or not. The
+// trace is nil when the block is not in the tree.
+func (mt *FlatMerkleTree) VerifyTrace(block Block, proof []TreeNode) (*VerifyTrace, error) {
+	if !mt.finalized {
+		return nil, ErrTreeNotFinalized
+	}
+	if err := mt.checkBinary(); err != nil {
+		return nil, err
+	}
+
+	idx, err := mt.findLeaf(block)
+	if err != nil {
+		return nil, err
+	}
+
+	index := idx - (len(mt.nodes) - len(mt.blocks))
+	t := &VerifyTrace{
+		Block:    block,
+		Index:    index,
+		Leaf:     mt.hashSaltedLeaf(mt.saltAt(index), block),
+		Root:     mt.root,
+		Diverged: -1,
+	}
+
+	left, _ := mt.proofSides(index, mt.count)
+	node := t.Leaf
+	for i, chunk := range proof {
+		step := VerifyStep{Level: i, Node: node, Sibling: chunk, Left: i < len(left) && left[i]}
+		if step.Left {
+			step.Computed = mt.hashChildren(chunk, node)
+		} else {
+			step.Computed = mt.hashChildren(node, chunk)
+		}
+		if idx > 0 {
+			idx = (idx - 1) / 2
+			step.Expected = mt.nodes[idx]
+		} else {
+			idx = -1
+		}
+
+		if t.Diverged < 0 && !step.Match() {
+			t.Diverged = i
+		}
+		t.Steps = append(t.Steps, step)
+		node = step.Computed
+	}
+	if t.Diverged < 0 && idx != 0 {
+		t.Diverged = len(t.Steps)
+	}
+
+	return t, mt.Verify(block, proof)
+}
+
+// String returns the trace as a table with a row per step, giving the side
+// the sibling was hashed on, every hash in full hex and whether the computed
+// parent matches the one held by the tree.
+func (t *VerifyTrace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "block %s, leaf %d, leaf hash %s\n", hex.EncodeToString(t.Block), t.Index, hex.EncodeToString(t.Leaf))
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "level\tsibling\tnode\tsibling hash\tcomputed\texpected\tstatus")
+	for i := range t.Steps {
+		s := &t.Steps[i]
+		side, status := "right", "ok"
+		if s.Left {
+			side = "left"
+		}
+		if !s.Match() {
+			status = "MISMATCH"
+		}
+		expected := "-"
+		if s.Expected != nil {
+			expected = hex.EncodeToString(s.Expected)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Level, side,
+			hex.EncodeToString(s.Node), hex.EncodeToString(s.Sibling), hex.EncodeToString(s.Computed), expected, status)
+	}
+	w.Flush()
+
+	switch {
+	case t.Diverged < 0:
+		fmt.Fprintf(&b, "valid, root %s\n", hex.EncodeToString(t.Root))
+	case t.Diverged == len(t.Steps):
+		fmt.Fprintf(&b, "proof ends below the root %s\n", hex.EncodeToString(t.Root))
+	default:
+		fmt.Fprintf(&b, "diverged at level %d, root %s\n", t.Diverged, hex.EncodeToString(t.Root))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package merklego
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyTrace(t *testing.T) {
+	mt := NewMerkleTree(Block("blockA"), Block("blockB"), Block("blockC"), Block("blockD"), Block("blockE"))
+	require.NoError(t, mt.Finalize())
+
+	proof, err := mt.Proof(Block("blockE"))
+	require.NoError(t, err)
+
+	trace, err := mt.VerifyTrace(Block("blockE"), proof)
+	require.NoError(t, err)
+	require.Equal(t, -1, trace.Diverged)
+	require.Len(t, trace.Steps, len(proof))
+	for i, s := range trace.Steps {
+		require.True(t, s.Match(), "level %d", i)
+	}
+	require.Equal(t, mt.root, trace.Steps[len(trace.Steps)-1].Computed)
+
+	tampered := append([]TreeNode(nil), proof...)
+	tampered[1] = append(TreeNode(nil), proof[1]...)
+	tampered[1][0] ^= 1
+	bad, err := mt.VerifyTrace(Block("blockE"), tampered)
+	require.Error(t, err)
+	require.Equal(t, 1, bad.Diverged)
+	require.True(t, bad.Steps[0].Match())
+	require.False(t, bad.Steps[1].Match())
+
+	var out bytes.Buffer
+	out.WriteString(trace.String())
+	out.WriteString(bad.String())
+	checkGolden(t, "verify_trace.golden", out.Bytes())
+
+	short, err := mt.VerifyTrace(Block("blockE"), proof[:2])
+	require.Error(t, err)
+	require.Equal(t, 2, short.Diverged)
+
+	long, err := mt.VerifyTrace(Block("blockE"), append(append([]TreeNode(nil), proof...), proof[0]))
+	require.Error(t, err)
+	require.Equal(t, len(proof), long.Diverged)
+	require.Nil(t, long.Steps[len(proof)].Expected)
+
+	missing, err := mt.VerifyTrace(Block("blockZ"), proof)
+	require.Error(t, err)
+	require.Nil(t, missing)
+
+	single := NewMerkleTree(Block("blockA"))
+	require.NoError(t, single.Finalize(WithSingleLeafRoot(true)))
+	empty, err := single.VerifyTrace(Block("blockA"), nil)
+	require.NoError(t, err)
+	require.Equal(t, -1, empty.Diverged)
+}
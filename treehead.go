@@ -0,0 +1,273 @@
+package merklego
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// treeHeadVersion is the version of the binary layout of tree heads without a
+// hash name, and treeHeadVersionNamed the one of heads with one, which adds it
+// to the layout.
+const (
+	treeHeadVersion      = 1
+	treeHeadVersionNamed = 2
+)
+
+// ErrInvalidTreeHead is returned when decoding malformed tree heads.
+var ErrInvalidTreeHead = errors.New("error: invalid tree head encoding")
+
+// TreeHead is a snapshot of a tree: its root when it held Size leaves, taken
+// at Timestamp. Timestamps are kept to the millisecond, the precision of the
+// encodings. Hash names the hash function of the tree as its proofs do, ""
+// when it was not registered with RegisterHash.
+type TreeHead struct {
+	Size      uint64
+	Root      []byte
+	Timestamp time.Time
+	Hash      string
+}
+
+var (
+	_ encoding.BinaryMarshaler   = TreeHead{}
+	_ encoding.BinaryUnmarshaler = (*TreeHead)(nil)
+	_ json.Marshaler             = TreeHead{}
+	_ json.Unmarshaler           = (*TreeHead)(nil)
+)
+
+// Head returns the current head of the tree.
+func (m *MerkleTree) Head() (TreeHead, error) {
+	root, err := m.RootHash()
+	if err != nil {
+		return TreeHead{}, err
+	}
+
+	return newTreeHead(m.leafCount, root, m.hashers.name), nil
+}
+
+// Head returns the head of the tree, which must be finalized.
+func (mt *FlatMerkleTree) Head() (TreeHead, error) {
+	if !mt.finalized {
+		return TreeHead{}, ErrTreeNotFinalized
+	}
+
+	return newTreeHead(mt.count, copyNode(mt.root).Bytes(), mt.hashProofName()), nil
+}
+
+// Head returns the current head of the log.
+func (t *LogTree) Head() (TreeHead, error) {
+	root, err := t.RootHash()
+	if err != nil {
+		return TreeHead{}, err
+	}
+
+	return newTreeHead(t.Size(), root, "sha256"), nil
+}
+
+// Head returns the current head of the tree.
+func (t *IncrementalTree) Head() (TreeHead, error) {
+	return newTreeHead(int(t.count), t.Root(), t.hashProofName()), nil
+}
+
+// Head returns the current head of the range.
+func (m *MMR) Head() (TreeHead, error) {
+	return newTreeHead(int(m.leaves), m.Root(), m.hashProofName()), nil
+}
+
+func newTreeHead(size int, root []byte, hash string) TreeHead {
+	return TreeHead{Size: uint64(size), Root: root, Timestamp: time.Now().Truncate(time.Millisecond), Hash: hash}
+}
+
+// Equal reports whether h and o have the same size, root, timestamp and hash
+// name.
+func (h TreeHead) Equal(o TreeHead) bool {
+	return h.Size == o.Size && RootEqual(h.Root, o.Root) && h.Timestamp.Equal(o.Timestamp) && h.Hash == o.Hash
+}
+
+// Compare orders heads by size, then by timestamp. It returns -1, 0 or 1 as h
+// comes before, with or after o; heads of the same size and time compare
+// equal whatever their roots.
+func (h TreeHead) Compare(o TreeHead) int {
+	switch {
+	case h.Size < o.Size, h.Size == o.Size && h.Timestamp.Before(o.Timestamp):
+		return -1
+	case h.Size > o.Size, h.Size == o.Size && h.Timestamp.After(o.Timestamp):
+		return 1
+	}
+
+	return 0
+}
+
+// IsAncestorCandidate reports whether h may be an earlier head of the tree of
+// o, judging by their sizes alone: h is smaller, or as large with the same
+// root. Only a consistency proof settles it.
+func (h TreeHead) IsAncestorCandidate(o TreeHead) bool {
+	return h.Size < o.Size || h.Size == o.Size && RootEqual(h.Root, o.Root)
+}
+
+// VerifyProof checks that p proves a leaf of the tree of h, hashed with the
+// function h names if it names one.
+func (h TreeHead) VerifyProof(p *Proof) error {
+	if uint64(p.Size) != h.Size {
+		return fmt.Errorf("error: proof of a tree of %d leaves against a head of %d: %w", p.Size, h.Size, ErrInvalidProof)
+	}
+	if h.Hash != "" && p.Hash != h.Hash {
+		return fmt.Errorf("error: proof hashed with %q against a head hashed with %q: %w", p.Hash, h.Hash, ErrInvalidProof)
+	}
+
+	return p.Verify(h.Root)
+}
+
+// VerifyInclusion checks that proof proves block at index in the LogTree of
+// h, as VerifyInclusion does.
+func (h TreeHead) VerifyInclusion(block Block, index int, proof []TreeNode) error {
+	if h.Size > uint64(maxInt) {
+		return fmt.Errorf("error: tree head size %d overflows int: %w", h.Size, ErrInvalidProof)
+	}
+
+	return VerifyInclusion(h.Root, block, index, int(h.Size), proof)
+}
+
+// VerifyConsistency checks that proof proves the LogTree of h to be a prefix
+// of the one of newer, as VerifyConsistency does.
+func (h TreeHead) VerifyConsistency(newer TreeHead, proof []TreeNode) error {
+	if h.Size > uint64(maxInt) || newer.Size > uint64(maxInt) {
+		return fmt.Errorf("error: tree head sizes %d and %d overflow int: %w", h.Size, newer.Size, ErrInvalidProof)
+	}
+
+	return VerifyConsistency(h.Root, newer.Root, int(h.Size), int(newer.Size), proof)
+}
+
+// MarshalBinary encodes the head as:
+//
+//	version    1 byte, 2 for heads with a hash name and 1 otherwise
+//	hash name  1 byte length, then the name; only present in version 2
+//	size       8 bytes, big endian
+//	timestamp  8 bytes, big endian two's complement, milliseconds since the
+//	           Unix epoch
+//	root       1 byte length, then the root
+func (h TreeHead) MarshalBinary() ([]byte, error) {
+	if len(h.Root) > 0xff || len(h.Hash) > 0xff {
+		return nil, fmt.Errorf("error: cannot encode a %d byte root of hash %q", len(h.Root), h.Hash)
+	}
+
+	out := make([]byte, 0, 19+len(h.Hash)+len(h.Root))
+	if h.Hash == "" {
+		out = append(out, treeHeadVersion)
+	} else {
+		out = append(out, treeHeadVersionNamed, byte(len(h.Hash)))
+		out = append(out, h.Hash...)
+	}
+	var num [8]byte
+	binary.BigEndian.PutUint64(num[:], h.Size)
+	out = append(out, num[:]...)
+	binary.BigEndian.PutUint64(num[:], uint64(h.Timestamp.UnixMilli()))
+	out = append(out, num[:]...)
+	out = append(out, byte(len(h.Root)))
+
+	return append(out, h.Root...), nil
+}
+
+// UnmarshalBinary decodes a head encoded by MarshalBinary, within the default
+// DecodeOptions. A head naming a hash function that is not registered is
+// refused with an UnknownHashError. On error the head is left untouched.
+func (h *TreeHead) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: 0 bytes", ErrInvalidTreeHead)
+	}
+	var name []byte
+	switch data[0] {
+	case treeHeadVersion:
+		data = data[1:]
+	case treeHeadVersionNamed:
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return fmt.Errorf("%w: truncated hash name", ErrInvalidTreeHead)
+		}
+		name, data = data[2:2+int(data[1])], data[2+int(data[1]):]
+		if len(name) == 0 {
+			return fmt.Errorf("%w: version %d without a hash name", ErrInvalidTreeHead, treeHeadVersionNamed)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidTreeHead, data[0])
+	}
+
+	if len(data) < 17 {
+		return fmt.Errorf("%w: %d bytes after the version", ErrInvalidTreeHead, len(data))
+	}
+	o := DecodeOptions{}.WithDefaults()
+	if err := o.Check("MaxHashSize", uint64(data[16]), o.MaxHashSize, ErrInvalidTreeHead); err != nil {
+		return err
+	}
+	if len(data) != 17+int(data[16]) {
+		return fmt.Errorf("%w: %d byte root in %d bytes", ErrInvalidTreeHead, data[16], len(data))
+	}
+	if err := checkHashName(string(name), ErrInvalidTreeHead); err != nil {
+		return err
+	}
+
+	*h = TreeHead{
+		Size:      binary.BigEndian.Uint64(data),
+		Timestamp: time.UnixMilli(int64(binary.BigEndian.Uint64(data[8:]))),
+		Root:      append([]byte(nil), data[17:]...),
+		Hash:      string(name),
+	}
+
+	return nil
+}
+
+// jsonTreeHead is the JSON representation of a TreeHead.
+type jsonTreeHead struct {
+	Size      uint64 `json:"size"`
+	Root      string `json:"root"`
+	Timestamp int64  `json:"timestamp"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+// MarshalJSON encodes the head as an object holding its size, its hex encoded
+// root, its timestamp in milliseconds since the Unix epoch and its hash name,
+// left out when empty, in that order and without whitespace.
+func (h TreeHead) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTreeHead{
+		Size:      h.Size,
+		Root:      hex.EncodeToString(h.Root),
+		Timestamp: h.Timestamp.UnixMilli(),
+		Hash:      h.Hash,
+	})
+}
+
+// UnmarshalJSON decodes a head encoded by MarshalJSON, within the default
+// DecodeOptions, rejecting unknown fields and, with an UnknownHashError, hash
+// names that are not registered.
+func (h *TreeHead) UnmarshalJSON(data []byte) error {
+	o := DecodeOptions{}.WithDefaults()
+	if err := o.Check("MaxInputSize", uint64(len(data)), o.MaxInputSize, ErrInvalidTreeHead); err != nil {
+		return err
+	}
+
+	var j jsonTreeHead
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTreeHead, err)
+	}
+
+	if err := o.Check("MaxHashSize", uint64(len(j.Root)/2), o.MaxHashSize, ErrInvalidTreeHead); err != nil {
+		return err
+	}
+	root, err := hex.DecodeString(j.Root)
+	if err != nil {
+		return fmt.Errorf("%w: bad root %q", ErrInvalidTreeHead, j.Root)
+	}
+	if err := checkHashName(j.Hash, ErrInvalidTreeHead); err != nil {
+		return err
+	}
+
+	*h = TreeHead{Size: j.Size, Root: root, Timestamp: time.UnixMilli(j.Timestamp), Hash: j.Hash}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeHeadEncoding(t *testing.T) {
+	h := TreeHead{
+		Size:      42,
+		Root:      []byte{0xde, 0xad, 0xbe, 0xef},
+		Timestamp: time.UnixMilli(1700000000123),
+	}
+
+	for i := 0; i < 3; i++ {
+		data, err := h.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, "01000000000000002a0000018bcfe5687b04deadbeef", hex.EncodeToString(data))
+
+		var decoded TreeHead
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.True(t, h.Equal(decoded))
+
+		data, err = json.Marshal(h)
+		require.NoError(t, err)
+		require.Equal(t, `{"size":42,"root":"deadbeef","timestamp":1700000000123}`, string(data))
+
+		decoded = TreeHead{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.True(t, h.Equal(decoded))
+	}
+
+	h.Hash = "sha256"
+	data, err := h.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, "0206736861323536000000000000002a0000018bcfe5687b04deadbeef", hex.EncodeToString(data))
+	var decoded TreeHead
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.True(t, h.Equal(decoded))
+	data, err = json.Marshal(h)
+	require.NoError(t, err)
+	require.Equal(t, `{"size":42,"root":"deadbeef","timestamp":1700000000123,"hash":"sha256"}`, string(data))
+
+	for _, data := range []string{"", "02000000000000002a0000018bcfe5687b00", "0206736861", "0206736861323536000000000000002a0000018bcfe5687b04deadbe", "01000000000000002a0000018bcfe5687b04deadbe", "01000000000000002a0000018bcfe5687b00ff"} {
+		b, _ := hex.DecodeString(data)
+		require.ErrorIs(t, (&TreeHead{}).UnmarshalBinary(b), ErrInvalidTreeHead, data)
+	}
+	for _, data := range []string{`{"size":1,"root":"zz","timestamp":0}`, `{"size":1,"root":"","timestamp":0,"extra":1}`, `[]`} {
+		require.ErrorIs(t, (&TreeHead{}).UnmarshalJSON([]byte(data)), ErrInvalidTreeHead, data)
+	}
+
+	_, err = TreeHead{Root: make([]byte, 256)}.MarshalBinary()
+	require.Error(t, err)
+}
+
+func TestTreeHeadOrdering(t *testing.T) {
+	at := time.UnixMilli(1700000000000)
+	a := TreeHead{Size: 3, Root: []byte{1}, Timestamp: at}
+	b := TreeHead{Size: 5, Root: []byte{2}, Timestamp: at}
+	later := TreeHead{Size: 3, Root: []byte{3}, Timestamp: at.Add(time.Second)}
+
+	require.Equal(t, -1, a.Compare(b))
+	require.Equal(t, 1, b.Compare(a))
+	require.Equal(t, -1, a.Compare(later))
+	require.Equal(t, 0, a.Compare(a))
+
+	require.True(t, a.IsAncestorCandidate(b))
+	require.False(t, b.IsAncestorCandidate(a))
+	require.True(t, a.IsAncestorCandidate(a))
+	require.False(t, a.IsAncestorCandidate(later))
+
+	require.True(t, a.Equal(TreeHead{Size: 3, Root: []byte{1}, Timestamp: at.In(time.UTC)}))
+	require.False(t, a.Equal(later))
+}
+
+func TestTreeHeadVerify(t *testing.T) {
+	log := &LogTree{}
+	var heads []TreeHead
+	for i := 0; i < 9; i++ {
+		require.NoError(t, log.Append(Block(fmt.Sprintf("leaf-%d", i))))
+		h, err := log.Head()
+		require.NoError(t, err)
+		heads = append(heads, h)
+	}
+
+	last := heads[len(heads)-1]
+	for i, h := range heads {
+		require.True(t, h.IsAncestorCandidate(last))
+
+		proof := rfcSubproof(int(h.Size), rfcLeaves(int(last.Size)), true)
+		require.NoError(t, h.VerifyConsistency(last, proof), "size:%d", h.Size)
+		if i > 0 {
+			require.ErrorIs(t, heads[i-1].VerifyConsistency(last, proof), ErrInvalidProof)
+		}
+
+		inclusion, err := log.InclusionProof(i, int(h.Size))
+		require.NoError(t, err)
+		require.NoError(t, h.VerifyInclusion(Block(fmt.Sprintf("leaf-%d", i)), i, inclusion))
+	}
+	require.ErrorIs(t, TreeHead{Size: 1 << 63}.VerifyInclusion(Block("leaf-0"), 0, nil), ErrInvalidProof)
+
+	for _, c := range conformanceTrees(t, 6) {
+		h, err := c.tree.(interface{ Head() (TreeHead, error) }).Head()
+		require.NoError(t, err)
+		require.EqualValues(t, 6, h.Size)
+
+		p, err := c.tree.Prove(c.leaves[2])
+		require.NoError(t, err)
+		require.NoError(t, h.VerifyProof(p), c.name)
+
+		h.Size++
+		require.ErrorIs(t, h.VerifyProof(p), ErrInvalidProof, c.name)
+	}
+
+	flat := NewMerkleTree(Block("a"))
+	_, err := flat.Head()
+	require.ErrorIs(t, err, ErrTreeNotFinalized)
+}
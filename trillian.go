@@ -0,0 +1,39 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// VerifyTrillianInclusion checks an inclusion proof as a Trillian log serves
+// it: proof holds the sibling hashes, from the leaf up, showing leafHash, the
+// RFC 6962 hash SHA-256(0x00 || data) of a leaf, to be the leaf at index of
+// the tree of treeSize leaves with the given root. It is the check of
+// VerifyInclusion, for callers holding leaf hashes rather than leaf data, and
+// rejects leaf hashes that are not SHA-256 digests and proofs of the wrong
+// length for index and treeSize. Errors wrap ErrInvalidProof.
+func VerifyTrillianInclusion(root []byte, leafHash []byte, index, treeSize uint64, proof [][]byte) error {
+	if index >= treeSize {
+		return fmt.Errorf("invalid inclusion proof: leaf index %d out of range [0, %d): %w", index, treeSize, ErrInvalidProof)
+	}
+	if len(leafHash) != sha256.Size {
+		return fmt.Errorf("invalid inclusion proof: leaf hash has %d bytes, want %d: %w", len(leafHash), sha256.Size, ErrInvalidProof)
+	}
+
+	want := inclusionProofSize(index, treeSize)
+	if len(proof) != want {
+		return fmt.Errorf("invalid inclusion proof: got %d nodes, want %d for leaf %d of %d: %w", len(proof), want, index, treeSize, ErrInvalidProof)
+	}
+
+	nodes := make([]TreeNode, len(proof))
+	for i, p := range proof {
+		nodes[i] = p
+	}
+
+	r := rootFromInclusionProof(leafHash, index, treeSize, nodes)
+	if !RootEqual(r, root) {
+		return fmt.Errorf("invalid inclusion proof for leaf hash %X; got: %X, want: %X: %w", leafHash, r.Bytes(), root, ErrInvalidProof)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The vectors and probes below are those of proof/verify_test.go of
+// github.com/transparency-dev/merkle, over the leaves ctInputs, with the
+// leaf indexes counted from 0 instead of 1.
+var (
+	trillianSomeHash  = "abacaba000000000000000000000000000000000000000000060061e00123456"
+	trillianEmptyRoot = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	trillianInclusionProofs = []struct {
+		index, size uint64
+		proof       []string
+	}{
+		{0, 1, nil},
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{1, 5, []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	trillianRoots = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func trillianLeafHash(data []byte) []byte {
+	return hashNode(data, false)
+}
+
+// trillianProbe is a corrupted set of parameters of VerifyTrillianInclusion.
+type trillianProbe struct {
+	index, size uint64
+	root, leaf  []byte
+	proof       [][]byte
+	desc        string
+}
+
+// corruptTrillianProof returns the probes of corruptInclusionProof of the
+// transparency-dev suite, none of which may verify.
+func corruptTrillianProof(t *testing.T, index, size uint64, proof [][]byte, root, leaf []byte) []trillianProbe {
+	extend := func(hashes ...[]byte) [][]byte {
+		return append(append([][]byte(nil), proof...), hashes...)
+	}
+	prepend := func(hashes ...[]byte) [][]byte {
+		return append(append([][]byte(nil), hashes...), proof...)
+	}
+
+	probes := []trillianProbe{
+		{index - 1, size, root, leaf, proof, "index - 1"},
+		{index + 1, size, root, leaf, proof, "index + 1"},
+		{index ^ 2, size, root, leaf, proof, "index ^ 2"},
+		{index, size * 2, root, leaf, proof, "size * 2"},
+		{index, size / 2, root, leaf, proof, "size / 2"},
+		{index, size, root, []byte("WrongLeaf"), proof, "wrong leaf"},
+		{index, size, mustHex(t, trillianEmptyRoot), leaf, proof, "empty root"},
+		{index, size, mustHex(t, trillianSomeHash), leaf, proof, "random root"},
+		{index, size, root, leaf, extend([]byte{}), "trailing garbage"},
+		{index, size, root, leaf, extend(root), "trailing root"},
+		{index, size, root, leaf, prepend([]byte{}), "preceding garbage"},
+		{index, size, root, leaf, prepend(root), "preceding root"},
+	}
+
+	for i := range proof {
+		wrong := prepend()
+		wrong[i] = append([]byte(nil), wrong[i]...)
+		wrong[i][0] ^= 8
+		probes = append(probes, trillianProbe{index, size, root, leaf, wrong, fmt.Sprintf("modified proof[%d] bit 3", i)})
+	}
+	if len(proof) > 0 {
+		probes = append(probes, trillianProbe{index, size, root, leaf, proof[:len(proof)-1], "removed component"})
+	}
+	if len(proof) > 1 {
+		wrong := append([][]byte{proof[0], mustHex(t, trillianSomeHash)}, proof[1:]...)
+		probes = append(probes, trillianProbe{index, size, root, leaf, wrong, "inserted component"})
+	}
+
+	return probes
+}
+
+func TestVerifyTrillianInclusionVectors(t *testing.T) {
+	for _, v := range trillianInclusionProofs {
+		msg := fmt.Sprintf("index: %d, size: %d", v.index, v.size)
+		data := mustHex(t, ctInputs[v.index])
+		leaf := trillianLeafHash(data)
+		root := mustHex(t, trillianRoots[v.size-1])
+		proof := make([][]byte, len(v.proof))
+		for i, s := range v.proof {
+			proof[i] = mustHex(t, s)
+		}
+
+		require.NoError(t, VerifyTrillianInclusion(root, leaf, v.index, v.size, proof), msg)
+		for _, p := range corruptTrillianProof(t, v.index, v.size, proof, root, leaf) {
+			err := VerifyTrillianInclusion(p.root, p.leaf, p.index, p.size, p.proof)
+			require.ErrorIs(t, err, ErrInvalidProof, msg+", probe: "+p.desc)
+		}
+	}
+}
+
+func TestVerifyTrillianInclusionSingleEntry(t *testing.T) {
+	hash := trillianLeafHash([]byte("data"))
+	empty := []byte{}
+
+	require.NoError(t, VerifyTrillianInclusion(hash, hash, 0, 1, [][]byte{}))
+	require.Error(t, VerifyTrillianInclusion(hash, empty, 0, 1, [][]byte{}))
+	require.Error(t, VerifyTrillianInclusion(empty, hash, 0, 1, [][]byte{}))
+	require.Error(t, VerifyTrillianInclusion(empty, empty, 0, 1, [][]byte{}))
+
+	someHash := mustHex(t, trillianSomeHash)
+	emptyRoot := mustHex(t, trillianEmptyRoot)
+	for _, p := range [][2]uint64{{0, 0}, {0, 1}, {1, 0}, {2, 1}} {
+		msg := fmt.Sprintf("index: %d, size: %d", p[0], p[1])
+		require.Error(t, VerifyTrillianInclusion(empty, someHash, p[0], p[1], nil), msg)
+		require.Error(t, VerifyTrillianInclusion(emptyRoot, empty, p[0], p[1], nil), msg)
+		require.Error(t, VerifyTrillianInclusion(emptyRoot, someHash, p[0], p[1], nil), msg)
+	}
+}
+
+func TestVerifyTrillianInclusionSizes(t *testing.T) {
+	// Every leaf of every size up to 70, so the sizes 1, 2^k - 1, 2^k and
+	// 2^k + 1 and the last leaf, the partial right subtree, are all covered.
+	tree, err := NewLogTree()
+	require.NoError(t, err)
+	for i := 0; i < 70; i++ {
+		require.NoError(t, tree.Append(Block(fmt.Sprintf("leaf-%d", i))))
+	}
+
+	for size := 1; size <= 70; size++ {
+		sized, err := NewLogTree()
+		require.NoError(t, err)
+		for i := 0; i < size; i++ {
+			require.NoError(t, sized.Append(Block(fmt.Sprintf("leaf-%d", i))))
+		}
+		root, err := sized.RootHash()
+		require.NoError(t, err)
+
+		for index := 0; index < size; index++ {
+			msg := fmt.Sprintf("index: %d, size: %d", index, size)
+			nodes, err := tree.InclusionProof(index, size)
+			require.NoError(t, err, msg)
+			proof := make([][]byte, len(nodes))
+			for i, n := range nodes {
+				proof[i] = n
+			}
+
+			leaf := sha256.Sum256(append([]byte{leafNodePrefix}, fmt.Sprintf("leaf-%d", index)...))
+			require.NoError(t, VerifyTrillianInclusion(root, leaf[:], uint64(index), uint64(size), proof), msg)
+			if size > 1 {
+				other := (index + 1) % size
+				require.ErrorIs(t, VerifyTrillianInclusion(root, leaf[:], uint64(other), uint64(size), proof), ErrInvalidProof, msg)
+			}
+		}
+	}
+}
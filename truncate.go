@@ -0,0 +1,94 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// WithDigestSize truncates every hash of a tree to its first n bytes: leaves,
+// internal nodes, the root and committed roots are hashed in full and then
+// cut, shrinking proofs at the cost of collision resistance, which falls to
+// n*4 bits. The leaves of a MerkleTree taken as their item hash are cut too.
+// Proofs record the size, in Proof.DigestSize, and check it: a proof of a
+// truncated tree does not verify against the root of a full size one, nor the
+// other way round. Size checks of decoders and verifiers of a tree built with
+// it, such as ParseRoot or NewTreeFromLeafHashes, expect n bytes. A size of 0,
+// or one at least the digest size of the hash function, keeps full digests.
+func WithDigestSize(n int) Option {
+	return func(c *config) {
+		c.digestSize = n
+	}
+}
+
+// truncatedHash cuts the digests of the hash.Hash it wraps to size bytes.
+type truncatedHash struct {
+	hash.Hash
+	size int
+}
+
+func (t *truncatedHash) Sum(b []byte) []byte {
+	return append(b, t.Hash.Sum(nil)[:t.size]...)
+}
+
+func (t *truncatedHash) Size() int {
+	return t.size
+}
+
+// truncatedHashFunc returns hashFunc with its digests cut to size bytes, or
+// hashFunc itself when size is 0.
+func truncatedHashFunc(hashFunc func() hash.Hash, size int) func() hash.Hash {
+	if size == 0 {
+		return hashFunc
+	}
+
+	return func() hash.Hash {
+		return &truncatedHash{Hash: hashFunc(), size: size}
+	}
+}
+
+// truncatedSize returns the size set with WithDigestSize when it cuts the
+// digests of the hash function of the tree, and 0 otherwise.
+func (c *config) truncatedSize() int {
+	if c.digestSize <= 0 {
+		return 0
+	}
+
+	hashFunc := c.hashFunc
+	if hashFunc == nil {
+		hashFunc = sha256.New
+	}
+	if c.digestSize >= hashFunc().Size() {
+		return 0
+	}
+
+	return c.digestSize
+}
+
+// truncate cuts b, a hash computed outside the hashers of the tree such as an
+// item hash or the output of a pair hash, to the size set with
+// WithDigestSize.
+func (c *config) truncate(b []byte) []byte {
+	return truncateHash(b, c.truncatedSize())
+}
+
+// truncateHash cuts b to size bytes, leaving it whole when size is not
+// positive or b is not longer.
+func truncateHash(b []byte, size int) []byte {
+	if size <= 0 || len(b) <= size {
+		return b
+	}
+
+	return b[:size]
+}
+
+// proofDigestSize returns the DigestSize of a decoded proof of size byte
+// hashes whose hash function is named name: size when it is shorter than the
+// digests of that function, and 0 when it is not or the function is unknown.
+func proofDigestSize(name string, size int) int {
+	hashStrategy, ok := hashByName(name)
+	if !ok || size >= hashStrategy().Size() {
+		return 0
+	}
+
+	return size
+}
@@ -0,0 +1,209 @@
+package merklego
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestSizeFlatTree(t *testing.T) {
+	var blocks []Block
+	for i := 0; i < 7; i++ {
+		blocks = append(blocks, Block(fmt.Sprintf("block-%d", i)))
+	}
+	mt := NewMerkleTree(blocks...)
+	require.NoError(t, mt.Finalize(WithDigestSize(16)))
+	root, err := mt.RootHash()
+	require.NoError(t, err)
+	require.Len(t, root, 16)
+
+	full := NewMerkleTree(blocks...)
+	require.NoError(t, full.Finalize())
+	fullRoot, _ := full.RootHash()
+
+	for i, block := range blocks {
+		p, err := mt.Prove(block)
+		require.NoError(t, err)
+		require.Equal(t, 16, p.DigestSize)
+		leaf := sha256.Sum256(append([]byte{leafNodePrefix}, block...))
+		require.Equal(t, leaf[:16], p.LeafHash)
+		for _, s := range p.Steps {
+			require.Len(t, s.Hash, 16)
+		}
+		require.NoError(t, p.Verify(root))
+		require.NoError(t, mt.VerifyProof(block, p))
+
+		// The size survives the encodings.
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, len(data), p.EncodedSize(ProofBinary))
+		var decoded Proof
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		require.Equal(t, p, &decoded)
+		data, err = json.Marshal(p)
+		require.NoError(t, err)
+		require.Equal(t, len(data), p.EncodedSize(ProofJSON))
+		decoded = Proof{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.NoError(t, decoded.Verify(root))
+
+		chunks, err := mt.Proof(block)
+		require.NoError(t, err)
+		require.NoError(t, VerifyFlatProof(root, block, i, 7, chunks, WithDigestSize(16)))
+		require.Error(t, VerifyFlatProof(root, block, i, 7, chunks))
+		require.NoError(t, VerifyFlatLeafHash(root, p.LeafHash, i, 7, chunks, WithDigestSize(16)))
+
+		// Truncated and full size proofs and roots do not mix.
+		require.ErrorIs(t, p.Verify(fullRoot), ErrInvalidProof)
+		require.ErrorIs(t, full.VerifyProof(block, p), ErrInvalidProof)
+		fullProof, err := full.Prove(block)
+		require.NoError(t, err)
+		require.Zero(t, fullProof.DigestSize)
+		require.ErrorIs(t, fullProof.Verify(root), ErrInvalidProof)
+		require.ErrorIs(t, mt.VerifyProof(block, fullProof), ErrInvalidProof)
+		cut := *fullProof
+		cut.DigestSize = 16
+		require.Error(t, cut.Verify(root))
+	}
+
+	s, err := mt.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, 16, s.DigestSize)
+	restored, err := RestoreFlatMerkleTree(s)
+	require.NoError(t, err)
+	restoredRoot, _ := restored.RootHash()
+	require.Equal(t, root, restoredRoot)
+	s.DigestSize = 0
+	_, err = RestoreFlatMerkleTree(s)
+	require.Error(t, err)
+}
+
+func TestDigestSizeMerkleTree(t *testing.T) {
+	full, err := NewTree(paddingContents(7))
+	require.NoError(t, err)
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithDomainSeparation(true)},
+		{WithCountCommitment(true)},
+		{WithSortedPairs(true), WithLengthPrefixedLeaves(true)},
+		{WithPairHash(doubleSHA256)},
+		{WithHMACKey(hmacKey)},
+	} {
+		tree, err := NewTreeWithOptions(paddingContents(7), append(opts, WithDigestSize(20))...)
+		require.NoError(t, err)
+		root, err := tree.RootHash()
+		require.NoError(t, err)
+		require.Len(t, root, 20)
+		require.Len(t, tree.MerkleRoot(), 20)
+
+		for _, l := range tree.Leaves {
+			require.Len(t, l.Hash, 20)
+			p, err := tree.Prove(l.Hash)
+			require.NoError(t, err)
+			require.Equal(t, 20, p.DigestSize)
+			require.NoError(t, tree.VerifyProof(l.Hash, p))
+			if tree.keyed() {
+				require.NoError(t, p.VerifyWithKey(root, hmacKey))
+			} else if tree.pairHash == nil {
+				require.NoError(t, p.Verify(root))
+			}
+			require.ErrorIs(t, full.VerifyProof(l.Hash, p), ErrInvalidProof)
+		}
+	}
+
+	// Item hashes taken as leaves are cut too.
+	tree, err := NewTreeWithOptions(paddingContents(3), WithDigestSize(20))
+	require.NoError(t, err)
+	itemHash, err := paddingContents(3)[1].CalculateHash()
+	require.NoError(t, err)
+	require.Equal(t, itemHash[:20], tree.Leaves[1].Hash)
+	p, err := tree.Prove(tree.Leaves[1].Hash)
+	require.NoError(t, err)
+	got, err := p.hashLeaf(itemHash, nil)
+	require.NoError(t, err)
+	require.Equal(t, tree.Leaves[1].Hash, got)
+
+	// A size at least the digest size keeps full digests.
+	same, err := NewTreeWithOptions(paddingContents(7), WithDigestSize(64))
+	require.NoError(t, err)
+	require.Equal(t, full.MerkleRoot(), same.MerkleRoot())
+	p, err = same.Prove(same.Leaves[0].Hash)
+	require.NoError(t, err)
+	require.Zero(t, p.DigestSize)
+}
+
+func TestDigestSizeTreeJSON(t *testing.T) {
+	tree, err := NewTreeWithOptions(paddingContents(5), WithDigestSize(20))
+	require.NoError(t, err)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"digest_size":20`)
+
+	var decoded MerkleTree
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, tree.MerkleRoot(), decoded.MerkleRoot())
+	leaf := decoded.Leaves[2].Hash
+	p, err := decoded.Prove(leaf)
+	require.NoError(t, err)
+	require.Equal(t, 20, p.DigestSize)
+	require.NoError(t, p.Verify(tree.MerkleRoot()))
+
+	receiver, err := NewTreeWithOptions(paddingContents(1), WithDigestSize(20))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, receiver))
+	plain, err := NewTree(paddingContents(1))
+	require.NoError(t, err)
+	require.ErrorIs(t, json.Unmarshal(data, plain), ErrInvalidTreeJSON)
+
+	fullData, err := json.Marshal(plain)
+	require.NoError(t, err)
+	require.ErrorIs(t, json.Unmarshal(fullData, receiver), ErrInvalidTreeJSON)
+}
+
+func TestDigestSizeChecks(t *testing.T) {
+	cut := WithDigestSize(16)
+
+	short := make([][]byte, 3)
+	long := make([][]byte, 3)
+	for i := range short {
+		h := sha256.Sum256([]byte{byte(i)})
+		short[i], long[i] = h[:16], h[:]
+	}
+	tree, err := NewTreeFromLeafHashes(short, sha256.New, cut)
+	require.NoError(t, err)
+	require.Len(t, tree.MerkleRoot(), 16)
+	_, err = NewTreeFromLeafHashes(long, sha256.New, cut)
+	require.Error(t, err)
+	_, err = NewTreeFromLeafHashes(short, sha256.New)
+	require.Error(t, err)
+
+	rootHex := "0x" + hex.EncodeToString(tree.MerkleRoot())
+	_, err = ParseRoot(rootHex, cut)
+	require.NoError(t, err)
+	_, err = ParseRoot(rootHex)
+	require.ErrorIs(t, err, ErrInvalidHex)
+
+	p, err := tree.Prove(short[0])
+	require.NoError(t, err)
+	require.Equal(t, 16, p.DigestSize)
+	require.NoError(t, p.Verify(tree.MerkleRoot()))
+	require.NoError(t, VerifyHex(rootHex, "0x"+hex.EncodeToString(short[0]), p.HexSiblings(), 0, cut))
+	require.Error(t, VerifyHex(rootHex, "0x"+hex.EncodeToString(short[0]), p.HexSiblings(), 0))
+
+	for _, size := range []int{-1, 32, 40} {
+		bad := *p
+		bad.DigestSize = size
+		require.Error(t, bad.Verify(tree.MerkleRoot()), "size %d", size)
+	}
+	bad := *p
+	bad.DigestSize = 20
+	_, err = bad.MarshalBinary()
+	require.Error(t, err)
+	require.Equal(t, -1, bad.EncodedSize(ProofBinary))
+	require.Error(t, bad.Validate())
+}
@@ -0,0 +1,97 @@
+package merklego
+
+// TreeOf is a MerkleTree over values of type T, hashed by a function instead
+// of through the Storable interface. Its methods take T values directly and
+// never require a user-written Equals.
+type TreeOf[T any] struct {
+	*MerkleTree
+	hashFn func(T) ([]byte, error)
+}
+
+// typedStorable wraps a T and its hash function into a Storable.
+type typedStorable[T any] struct {
+	item   T
+	hashFn func(T) ([]byte, error)
+}
+
+func (s typedStorable[T]) CalculateHash() ([]byte, error) {
+	return s.hashFn(s.item)
+}
+
+// Equals reports whether other hashes to the same value as s.
+func (s typedStorable[T]) Equals(other Storable) (bool, error) {
+	return sameHash(s, other)
+}
+
+// NewTreeOf creates a new merkle tree over items, hashing each of them with
+// hashFn.
+func NewTreeOf[T any](items []T, hashFn func(T) ([]byte, error), opts ...Option) (*TreeOf[T], error) {
+	t := &TreeOf[T]{hashFn: hashFn}
+
+	tree, err := NewTreeWithOptions(t.wrapAll(items), opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.MerkleTree = tree
+
+	return t, nil
+}
+
+// Item returns the item stored at position index of RealLeaves.
+func (t *TreeOf[T]) Item(index int) (T, bool) {
+	var zero T
+	if index < 0 || index >= t.leafCount {
+		return zero, false
+	}
+
+	s, ok := t.Leaves[index].Item.(typedStorable[T])
+	if !ok {
+		return zero, false
+	}
+
+	return s.item, true
+}
+
+// Index returns the position of item among the leaves of the tree.
+func (t *TreeOf[T]) Index(item T) (int, error) {
+	return t.ContentIndex(t.wrap(item))
+}
+
+// MerklePath returns the merkle path of the leaf holding item.
+func (t *TreeOf[T]) MerklePath(item T) ([]ProofStep, error) {
+	return t.MerkleTree.MerklePath(t.wrap(item))
+}
+
+// VerifyMerklePath checks that path leads from the leaf of item to the merkle
+// root of the tree.
+func (t *TreeOf[T]) VerifyMerklePath(item T, path []ProofStep) error {
+	return t.MerkleTree.VerifyMerklePath(t.wrap(item), path)
+}
+
+// UpdateLeaf replaces old with new and rehashes the path up to the root.
+func (t *TreeOf[T]) UpdateLeaf(old, new T) error {
+	return t.MerkleTree.UpdateLeaf(t.wrap(old), t.wrap(new))
+}
+
+// Remove drops item from the tree.
+func (t *TreeOf[T]) Remove(item T) error {
+	return t.MerkleTree.Remove(t.wrap(item))
+}
+
+// RebuildTreeWith replaces the items of the tree and rebuilds it.
+func (t *TreeOf[T]) RebuildTreeWith(items []T) error {
+	return t.MerkleTree.RebuildTreeWith(t.wrapAll(items))
+}
+
+func (t *TreeOf[T]) wrap(item T) Storable {
+	return typedStorable[T]{item: item, hashFn: t.hashFn}
+}
+
+func (t *TreeOf[T]) wrapAll(items []T) []Storable {
+	content := make([]Storable, len(items))
+	for i, item := range items {
+		content[i] = t.wrap(item)
+	}
+
+	return content
+}
@@ -0,0 +1,116 @@
+package merklego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+type testAccount struct {
+	name    string
+	balance int
+}
+
+func hashAccount(a testAccount) ([]byte, error) {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", a.name, a.balance)))
+	return h[:], nil
+}
+
+func testAccounts(n int) []testAccount {
+	accounts := make([]testAccount, n)
+	for i := range accounts {
+		accounts[i] = testAccount{name: fmt.Sprintf("account-%d", i), balance: i * 10}
+	}
+
+	return accounts
+}
+
+func TestNewTreeOf(t *testing.T) {
+	for i := 0; i < len(table); i++ {
+		items := make([]string, len(table[i].contents))
+		for j, c := range table[i].contents {
+			items[j] = c.(TestSHA256Content).x
+		}
+
+		tree, err := NewTreeOf(items, func(s string) ([]byte, error) {
+			return TestSHA256Content{x: s}.CalculateHash()
+		})
+		if err != nil {
+			t.Fatalf("[case:%d] error: unexpected error: %v", table[i].testCaseId, err)
+		}
+		if bytes.Compare(tree.MerkleRoot(), table[i].expectedHash) != 0 {
+			t.Errorf("[case:%d] error: expected hash equal to %v got %v", table[i].testCaseId, table[i].expectedHash, tree.MerkleRoot())
+		}
+	}
+
+	if _, err := NewTreeOf(nil, hashAccount); err != ErrNoContent {
+		t.Errorf("error: expected ErrNoContent got %v", err)
+	}
+}
+
+func TestTreeOfMerklePath(t *testing.T) {
+	accounts := testAccounts(7)
+	tree, err := NewTreeOf(accounts, hashAccount)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	for i, a := range accounts {
+		path, err := tree.MerklePath(a)
+		if err != nil {
+			t.Fatalf("[index:%d] error: unexpected error: %v", i, err)
+		}
+		if err := tree.VerifyMerklePath(a, path); err != nil {
+			t.Errorf("[index:%d] error: expected valid path got %v", i, err)
+		}
+
+		forged := a
+		forged.balance++
+		if err := tree.VerifyMerklePath(forged, path); err != ErrInvalidProof {
+			t.Errorf("[index:%d] error: expected ErrInvalidProof got %v", i, err)
+		}
+
+		if idx, err := tree.Index(a); err != nil || idx != i {
+			t.Errorf("[index:%d] error: expected index %d got %d (%v)", i, i, idx, err)
+		}
+		if item, ok := tree.Item(i); !ok || item != a {
+			t.Errorf("[index:%d] error: expected item %v got %v", i, a, item)
+		}
+	}
+
+	if _, ok := tree.Item(len(accounts)); ok {
+		t.Errorf("error: expected no item out of range")
+	}
+}
+
+func TestTreeOfMutations(t *testing.T) {
+	accounts := testAccounts(5)
+	tree, err := NewTreeOf(accounts, hashAccount)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	updated := testAccount{name: "account-2", balance: 99}
+	if err := tree.UpdateLeaf(accounts[2], updated); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if err := tree.Remove(accounts[4]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	expected, _ := NewTreeOf([]testAccount{accounts[0], accounts[1], updated, accounts[3]}, hashAccount)
+	if !bytes.Equal(tree.MerkleRoot(), expected.MerkleRoot()) {
+		t.Errorf("error: expected root %x got %x", expected.MerkleRoot(), tree.MerkleRoot())
+	}
+	if item, _ := tree.Item(2); item != updated {
+		t.Errorf("error: expected item %v got %v", updated, item)
+	}
+
+	if err := tree.RebuildTreeWith(accounts[:2]); err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+	if tree.LeafCount() != 2 {
+		t.Errorf("error: expected 2 leaves got %d", tree.LeafCount())
+	}
+}
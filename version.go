@@ -0,0 +1,154 @@
+package merklego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrVersionReleased is returned by the methods of a released TreeVersion.
+var ErrVersionReleased = errors.New("error: tree version released")
+
+// versionNode is an immutable node of a TreeVersion, shared by every version
+// in which its subtree is unchanged.
+type versionNode struct {
+	hash        []byte
+	left, right *versionNode
+	// leaves is the number of leaves under the node, padding excluded.
+	leaves int
+}
+
+// TreeVersion is an immutable version of a MerkleTree, taken by Snapshot. It
+// serves the root and the proofs of the tree as it was, while the tree keeps
+// changing. Versions share the nodes of the subtrees that did not change
+// between them, so a version taken after k updates of leaves, by UpdateLeaf,
+// only holds O(k log n) nodes of its own. A TreeVersion can be read from
+// several goroutines until it is released.
+type TreeVersion struct {
+	root     *versionNode
+	size     int
+	hash     []byte
+	header   Proof
+	released bool
+}
+
+// Snapshot returns the current version of the tree. The first snapshot, and
+// the first one after the shape of the tree changed, by Remove or a rebuild,
+// copies the hashes of every node; later ones only copy the paths from the
+// root to the leaves updated since the previous snapshot, sharing the other
+// nodes with it. The tree holds on to its last version to share it with the
+// next.
+func (m *MerkleTree) Snapshot() *TreeVersion {
+	if m.Root == nil {
+		return &TreeVersion{}
+	}
+
+	m.version = m.Root.versionOf(m.version, map[*Node]*versionNode{})
+	hash, _ := m.RootHash()
+
+	return &TreeVersion{root: m.version, size: m.leafCount, hash: hash, header: m.proofHeader()}
+}
+
+// versionOf returns the version of the subtree of n, sharing prev, the version
+// of the node at its position in the previous snapshot, or its subtrees if
+// their hashes are unchanged. memo holds the versions of the nodes already
+// visited, so the padding duplicate of a node shares its children.
+func (n *Node) versionOf(prev *versionNode, memo map[*Node]*versionNode) *versionNode {
+	if v, ok := memo[n]; ok {
+		return v
+	}
+	if prev != nil && bytes.Equal(prev.hash, n.Hash) {
+		memo[n] = prev
+		return prev
+	}
+
+	v := &versionNode{hash: append([]byte(nil), n.Hash...)}
+	if n.leaf {
+		v.leaves = 1
+	} else {
+		var left, right *versionNode
+		if prev != nil {
+			left, right = prev.left, prev.right
+		}
+		v.left = n.Left.versionOf(left, memo)
+		v.right = n.Right.versionOf(right, memo)
+		v.leaves = v.left.leaves + v.right.leaves
+	}
+	if n.dup {
+		v.leaves = 0
+	}
+	memo[n] = v
+
+	return v
+}
+
+// Size returns the number of leaves of the version, padding excluded.
+func (v *TreeVersion) Size() int {
+	return v.size
+}
+
+// Root returns the root of the version, as RootHash returned it when the
+// version was taken.
+func (v *TreeVersion) Root() ([]byte, error) {
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), v.hash...), nil
+}
+
+// Proof returns the proof of the leaf at index in the version, as Prove would
+// have returned it when the version was taken.
+func (v *TreeVersion) Proof(index int) (*Proof, error) {
+	if err := v.check(); err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= v.size {
+		return nil, fmt.Errorf("error: leaf index %d out of range [0, %d)", index, v.size)
+	}
+
+	var steps []ProofStep
+	n, i := v.root, index
+	for n.left != nil {
+		if i < n.left.leaves {
+			steps = append(steps, ProofStep{Hash: n.right.hash})
+			n = n.left
+		} else {
+			steps = append(steps, ProofStep{Hash: n.left.hash, Left: true})
+			i -= n.left.leaves
+			n = n.right
+		}
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+
+	p := v.header
+	p.LeafPrefix, p.NodePrefix = clonePrefix(p.LeafPrefix), clonePrefix(p.NodePrefix)
+	p.Index = index
+	p.Size = v.size
+	p.LeafHash = append([]byte(nil), n.hash...)
+	p.Steps = detachPath(steps)
+
+	return &p, nil
+}
+
+// Release drops the nodes of the version, which can be garbage collected
+// unless other versions share them. The version is unusable afterwards.
+func (v *TreeVersion) Release() {
+	v.root = nil
+	v.hash = nil
+	v.released = true
+}
+
+// check returns an error unless the version can be read.
+func (v *TreeVersion) check() error {
+	if v.released {
+		return ErrVersionReleased
+	}
+	if v.root == nil {
+		return ErrNoContent
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package merklego
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// versionNodes adds the nodes reachable from n to seen.
+func versionNodes(n *versionNode, seen map[*versionNode]bool) {
+	if n == nil || seen[n] {
+		return
+	}
+	seen[n] = true
+	versionNodes(n.left, seen)
+	versionNodes(n.right, seen)
+}
+
+func TestTreeVersions(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadPromote} {
+		contents := paddingContents(1023)
+		tree, err := NewTreeWithOptions(contents, WithPaddingStrategy(strategy))
+		require.NoError(t, err)
+
+		var versions []*TreeVersion
+		var roots [][]byte
+		var states [][]Storable
+		for round := 0; round < 20; round++ {
+			versions = append(versions, tree.Snapshot())
+			root, err := tree.RootHash()
+			require.NoError(t, err)
+			roots = append(roots, root)
+			states = append(states, append([]Storable(nil), contents...))
+
+			// Three updates per version, the last leaf, mirrored by the
+			// padding, among them.
+			for _, i := range []int{round * 7 % 1023, round*131%1023 + 1, 1022} {
+				next := TestSHA256Content{x: fmt.Sprintf("leaf-%d-v%d", i, round)}
+				require.NoError(t, tree.UpdateLeaf(contents[i], next))
+				contents[i] = next
+			}
+		}
+
+		for v, version := range versions {
+			root, err := version.Root()
+			require.NoError(t, err)
+			require.Equal(t, roots[v], root)
+			require.Equal(t, 1023, version.Size())
+
+			for _, i := range []int{0, v * 7 % 1023, 511, 1022} {
+				p, err := version.Proof(i)
+				require.NoError(t, err)
+				require.Equal(t, i, p.Index)
+				require.NoError(t, p.Verify(root), "version %d, leaf %d", v, i)
+
+				want, err := NewTreeWithOptions(states[v], WithPaddingStrategy(strategy))
+				require.NoError(t, err)
+				leaf, err := want.contentHash(states[v][i])
+				require.NoError(t, err)
+				wantProof, err := want.Prove(leaf)
+				require.NoError(t, err)
+				require.Equal(t, wantProof, p)
+			}
+		}
+
+		// Every version after the first holds the paths of its updates only.
+		seen := map[*versionNode]bool{}
+		versionNodes(versions[0].root, seen)
+		base := len(seen)
+		for _, version := range versions[1:] {
+			versionNodes(version.root, seen)
+		}
+		require.LessOrEqual(t, len(seen)-base, 19*3*(tree.depth+1))
+	}
+}
+
+func TestTreeVersionRelease(t *testing.T) {
+	contents := paddingContents(8)
+	tree, err := NewTree(contents)
+	require.NoError(t, err)
+
+	v := tree.Snapshot()
+	require.NoError(t, tree.Remove(contents[3]))
+	after := tree.Snapshot()
+	require.Equal(t, 7, after.Size())
+
+	root, err := v.Root()
+	require.NoError(t, err)
+	p, err := v.Proof(3)
+	require.NoError(t, err)
+	require.NoError(t, p.Verify(root))
+
+	_, err = v.Proof(8)
+	require.Error(t, err)
+
+	v.Release()
+	_, err = v.Root()
+	require.ErrorIs(t, err, ErrVersionReleased)
+	_, err = v.Proof(0)
+	require.ErrorIs(t, err, ErrVersionReleased)
+
+	root, err = after.Root()
+	require.NoError(t, err)
+	p, err = after.Proof(6)
+	require.NoError(t, err)
+	require.NoError(t, p.Verify(root))
+
+	_, err = (&MerkleTree{}).Snapshot().Root()
+	require.ErrorIs(t, err, ErrNoContent)
+}
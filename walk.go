@@ -0,0 +1,62 @@
+package merklego
+
+// children returns the distinct children of n, left to right. Leaves and
+// padding duplicates have none: a duplicate shares the children of the node it
+// mirrors, which are visited through that node.
+func (n *Node) children() []*Node {
+	if n.leaf || n.dup {
+		return nil
+	}
+
+	if n.Left == n.Right {
+		return []*Node{n.Left}
+	}
+
+	return []*Node{n.Left, n.Right}
+}
+
+// Walk visits every node of the tree in pre-order, depth first and left to
+// right, calling fn with the node and its depth, the root being at depth 0.
+// A node paired with itself is visited once. The walk stops as soon as fn
+// returns false.
+func (m *MerkleTree) Walk(fn func(n *Node, depth int) bool) {
+	if m.Root != nil {
+		walk(m.Root, 0, fn)
+	}
+}
+
+func walk(n *Node, depth int, fn func(n *Node, depth int) bool) bool {
+	if !fn(n, depth) {
+		return false
+	}
+
+	for _, c := range n.children() {
+		if !walk(c, depth+1, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WalkLevels visits the tree breadth first, calling fn once per level with
+// the nodes of that level from left to right, starting with the root at level
+// 0. The walk stops as soon as fn returns false.
+func (m *MerkleTree) WalkLevels(fn func(level int, nodes []*Node) bool) {
+	if m.Root == nil {
+		return
+	}
+
+	nodes := []*Node{m.Root}
+	for level := 0; len(nodes) > 0; level++ {
+		if !fn(level, nodes) {
+			return
+		}
+
+		var next []*Node
+		for _, n := range nodes {
+			next = append(next, n.children()...)
+		}
+		nodes = next
+	}
+}
@@ -0,0 +1,106 @@
+package merklego
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func ExampleMerkleTree_WalkLevels() {
+	tree, err := NewTree(table[2].contents)
+	if err != nil {
+		panic(err)
+	}
+
+	tree.WalkLevels(func(level int, nodes []*Node) bool {
+		fmt.Printf("level %d:", level)
+		for _, n := range nodes {
+			fmt.Printf(" %s", hex.EncodeToString(n.Hash)[:8])
+			if n.dup {
+				fmt.Print("(dup)")
+			}
+		}
+		fmt.Println()
+		return true
+	})
+	// Output:
+	// level 0: 2ed873ae
+	// level 1: 28b727c1 6b7ef5ab
+	// level 2: d123f97d b2d1eb58 57e8ad35 57e8ad35(dup)
+	// level 3: 185f8db3 3639efcd 581d4374 9eabb141 e633f4fc e633f4fc(dup)
+}
+
+func TestWalk(t *testing.T) {
+	for _, strategy := range []PaddingStrategy{PadDuplicateLast, PadSelfPair, PadPromote} {
+		for n := 1; n <= 9; n++ {
+			tree, err := NewTreeWithOptions(paddingContents(n), WithPaddingStrategy(strategy))
+			if err != nil {
+				t.Fatalf("[leaves:%d strategy:%d] error: unexpected error: %v", n, strategy, err)
+			}
+
+			var visited []*Node
+			var leaves []*Node
+			tree.Walk(func(node *Node, depth int) bool {
+				if node == tree.Root && depth != 0 {
+					t.Errorf("[leaves:%d strategy:%d] error: expected root at depth 0 got %d", n, strategy, depth)
+				}
+				if node.leaf && depth > tree.Depth() {
+					t.Errorf("[leaves:%d strategy:%d] error: leaf deeper than the tree: %d", n, strategy, depth)
+				}
+				if node.leaf {
+					leaves = append(leaves, node)
+				}
+				visited = append(visited, node)
+				return true
+			})
+
+			if len(leaves) != len(tree.Leaves) {
+				t.Fatalf("[leaves:%d strategy:%d] error: expected %d leaves got %d", n, strategy, len(tree.Leaves), len(leaves))
+			}
+			for i, l := range leaves {
+				if l != tree.Leaves[i] {
+					t.Errorf("[leaves:%d strategy:%d] error: leaf %d visited out of order", n, strategy, i)
+				}
+			}
+
+			var byLevel []*Node
+			tree.WalkLevels(func(level int, nodes []*Node) bool {
+				for _, node := range nodes {
+					if node.leaf && !node.dup && strategy != PadPromote && level != tree.Depth() {
+						t.Errorf("[leaves:%d strategy:%d] error: expected leaves at level %d got %d", n, strategy, tree.Depth(), level)
+					}
+				}
+				byLevel = append(byLevel, nodes...)
+				return true
+			})
+			if len(byLevel) != len(visited) {
+				t.Errorf("[leaves:%d strategy:%d] error: walks visited %d and %d nodes", n, strategy, len(visited), len(byLevel))
+			}
+		}
+	}
+}
+
+func TestWalkStops(t *testing.T) {
+	tree, err := NewTree(table[2].contents)
+	if err != nil {
+		t.Fatalf("error: unexpected error: %v", err)
+	}
+
+	visits := 0
+	tree.Walk(func(n *Node, depth int) bool {
+		visits++
+		return visits < 3
+	})
+	if visits != 3 {
+		t.Errorf("error: expected the walk to stop after 3 nodes got %d", visits)
+	}
+
+	levels := 0
+	tree.WalkLevels(func(level int, nodes []*Node) bool {
+		levels++
+		return level < 1
+	})
+	if levels != 2 {
+		t.Errorf("error: expected the walk to stop after 2 levels got %d", levels)
+	}
+}